@@ -1,23 +1,34 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"time"
 
+	"github.com/ophum/humstack/pkg/agents/core/garbagecollector"
 	"github.com/ophum/humstack/pkg/agents/core/group"
 	"github.com/ophum/humstack/pkg/agents/core/namespace"
 	"github.com/ophum/humstack/pkg/agents/core/network"
+	"github.com/ophum/humstack/pkg/agents/core/nodelifecycle"
+	"github.com/ophum/humstack/pkg/agents/core/scheduler"
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/agents/leaderelection"
 	"github.com/ophum/humstack/pkg/agents/system/blockstorage"
 	"github.com/ophum/humstack/pkg/agents/system/image"
 	"github.com/ophum/humstack/pkg/agents/system/node"
 	"github.com/ophum/humstack/pkg/agents/system/nodenetwork"
 	"github.com/ophum/humstack/pkg/agents/system/virtualmachine"
 	"github.com/ophum/humstack/pkg/agents/system/virtualrouter"
+	"github.com/ophum/humstack/pkg/api/auth"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
 )
@@ -34,15 +45,45 @@ type Config struct {
 	AgentMode        AgentMode `yaml:"agentMode"`
 	ApiServerAddress string    `yaml:"apiServerAddress"`
 	ApiServerPort    int32     `yaml:"apiServerPort"`
-	LimitMemory      string    `yaml:"limitMemory"`
-	LimitVcpus       string    `yaml:"limitVcpus"`
 	NodeAddress      string    `yaml:"nodeAddress"`
 
+	// nodeのlabels。VMのnodeSelectorで指定する
+	Labels map[string]string `yaml:"labels"`
+
+	// apiserverにhttpsで接続する場合のCAバンドルとクライアント証明書
+	// クライアント証明書のCommonNameは `node:<ホスト名>` にする
+	ApiServerTLS tlsutil.ClientConfig `yaml:"apiServerTLS"`
+
+	// Core, Allモードのcoreのagentが使うクライアント証明書
+	// ノードの証明書では他のノードをNotReadyにできないので、CommonNameは `core:<ホスト名>` にする
+	// caFile, insecureSkipVerifyを省略した場合はapiServerTLSの値を使う
+	CoreApiServerTLS tlsutil.ClientConfig `yaml:"coreApiServerTLS"`
+
+	// apiserverへの1回のリクエストのタイムアウト(省略時は30s)
+	ApiServerTimeout time.Duration `yaml:"apiServerTimeout"`
+
+	// ノードのリソースのうちVMに割り当てない量とovercommitの倍率
+	NodeAgentConfig node.NodeAgentConfig `yaml:"nodeAgentConfig"`
+
 	BlockStorageAgentConfig blockstorage.BlockStorageAgentConfig `yaml:"blockStorageAgentConfig"`
 
 	NetworkAgentConfig nodenetwork.NetworkAgentConfig `yaml:"networkAgentConfig"`
 
 	ImageAgentConfig image.ImageAgentConfig `yaml:"imageAgentConfig"`
+
+	VirtualMachineAgentConfig virtualmachine.VirtualMachineAgentConfig `yaml:"virtualMachineAgentConfig"`
+
+	// Core, Allモードで動くschedulerの設定
+	SchedulerAgentConfig scheduler.SchedulerAgentConfig `yaml:"schedulerAgentConfig"`
+
+	// Core, Allモードで動く、ノードの停止を検知するagentの設定
+	NodeLifecycleAgentConfig nodelifecycle.NodeLifecycleAgentConfig `yaml:"nodeLifecycleAgentConfig"`
+
+	// 各agentの状態を返すAPI(systemdなどから確認する)
+	HealthAPI health.HealthAPIConfig `yaml:"healthAPI"`
+
+	// Core, Allモードのagentが複数ある場合に1つだけ動作させるための設定
+	LeaderElection leaderelection.LeaderElectionConfig `yaml:"leaderElection"`
 }
 
 var (
@@ -68,6 +109,46 @@ func init() {
 		log.Fatal("failed decode config")
 	}
 
+	if config.HealthAPI.ListenAddress == "" {
+		config.HealthAPI.ListenAddress = "localhost"
+	}
+	if config.HealthAPI.ListenPort == 0 {
+		config.HealthAPI.ListenPort = 8084
+	}
+	if config.HealthAPI.StaleAfter == 0 {
+		config.HealthAPI.StaleAfter = time.Minute
+	}
+
+	if config.LeaderElection.LeaseName == "" {
+		config.LeaderElection.LeaseName = leaderelection.DefaultLeaseName
+	}
+	if config.LeaderElection.LeaseDuration == 0 {
+		config.LeaderElection.LeaseDuration = leaderelection.DefaultLeaseDuration
+	}
+	if config.LeaderElection.RenewDeadline == 0 {
+		config.LeaderElection.RenewDeadline = leaderelection.DefaultRenewDeadline
+	}
+	if config.LeaderElection.RetryPeriod == 0 {
+		config.LeaderElection.RetryPeriod = leaderelection.DefaultRetryPeriod
+	}
+	// leaderをやめる前に他のagentがleaseを取得できないようにする
+	if config.LeaderElection.RenewDeadline >= config.LeaderElection.LeaseDuration {
+		log.Fatal("leaderElection.renewDeadline must be less than leaderElection.leaseDuration")
+	}
+
+	// 容量はblockstorageとimageを置くディレクトリのファイルシステムで調べる
+	config.NodeAgentConfig.BlockStorageDirPath = config.BlockStorageAgentConfig.BlockStorageDirPath
+	config.NodeAgentConfig.ImageDirPath = config.ImageAgentConfig.ImageDirPath
+	if err := config.NodeAgentConfig.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := config.SchedulerAgentConfig.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := config.NodeLifecycleAgentConfig.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
 	log.Println(config)
 }
 
@@ -77,49 +158,143 @@ func main() {
 		log.Fatal(err)
 	}
 
-	client := client.NewClients(config.ApiServerAddress, config.ApiServerPort)
 	hostname, err := os.Hostname()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// クライアント証明書は自身のノードのものでなければならない
+	client, err := newClient(&config.ApiServerTLS, auth.NodeCommonName(hostname), "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	healthRegistry := health.NewRegistry(config.HealthAPI.StaleAfter)
+	go func() {
+		if err := healthRegistry.HealthAPI(&config.HealthAPI); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	recorder := event.NewRecorder(client, hostname, logger.With(zap.Namespace("EventRecorder")))
+
 	nodeAgent := node.NewNodeAgent(&system.Node{
 		Meta: meta.Meta{
-			ID:   hostname,
-			Name: hostname,
+			ID:     hostname,
+			Name:   hostname,
+			Labels: config.Labels,
 			Annotations: map[string]string{
-				"agentMode": string(config.AgentMode),
+				"agentMode":               string(config.AgentMode),
+				node.NodeV0AnnotationArch: node.Arch(),
 			},
 		},
 		Spec: system.NodeSpec{
-			Address:     config.NodeAddress,
-			LimitMemory: config.LimitMemory,
-			LimitVcpus:  config.LimitVcpus,
+			Address: config.NodeAddress,
 		},
 	}, client,
+		&config.NodeAgentConfig,
 		logger.With(zap.Namespace("NodeAgent")),
 	)
-	go nodeAgent.Run()
+	nodeAgent.SetHealthReporter(healthRegistry.Reporter("NodeAgent"))
+	nodeAgent.SetEventRecorder(recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go nodeAgent.Run(ctx)
+
+	// Core, Allモード以外では終了時に待つものがない
+	electorDone := make(chan struct{})
+	close(electorDone)
 
 	if config.AgentMode == AgentModeAll || config.AgentMode == AgentModeCore {
+		// ノードの証明書を使っている場合はcoreの証明書に切り替える
+		coreTLS, coreCommonName := &config.ApiServerTLS, auth.NodeCommonName(hostname)
+		if config.ApiServerTLS.CertFile != "" {
+			if config.CoreApiServerTLS.CertFile == "" {
+				log.Fatalf("coreApiServerTLS is required in %s mode when apiServerTLS has a client certificate", config.AgentMode)
+			}
+			t := config.CoreApiServerTLS
+			if t.CAFile == "" {
+				t.CAFile = config.ApiServerTLS.CAFile
+				t.InsecureSkipVerify = config.ApiServerTLS.InsecureSkipVerify
+			}
+			coreTLS, coreCommonName = &t, auth.CoreCommonName(hostname)
+		}
+		// apiserverのログでagentを区別できるように、agentごとにUser-Agentを変える
+		coreRecorder := event.NewRecorder(mustNewClient(coreTLS, coreCommonName, "EventRecorder"), hostname, logger.With(zap.Namespace("EventRecorder")))
+
+		elector := leaderelection.NewLeaderElector(
+			mustNewClient(coreTLS, coreCommonName, "LeaderElector"),
+			hostname,
+			&config.LeaderElection,
+			logger.With(zap.Namespace("LeaderElector")),
+		)
+		elector.SetEventRecorder(coreRecorder)
+
+		electorDone = make(chan struct{})
+		go func() {
+			elector.Run(ctx)
+			close(electorDone)
+		}()
+
 		grAgent := group.NewGroupAgent(
-			client,
+			mustNewClient(coreTLS, coreCommonName, "GroupAgent"),
 			logger.With(zap.Namespace("GroupAgent")),
 		)
 
 		nsAgent := namespace.NewNamespaceAgent(
-			client,
+			mustNewClient(coreTLS, coreCommonName, "NamespaceAgent"),
 			logger.With(zap.Namespace("NamespaceAgent")),
 		)
 
 		netAgent := network.NewNetworkAgent(
-			client,
+			mustNewClient(coreTLS, coreCommonName, "NetworkAgent"),
 			logger.With(zap.Namespace("NetworkAgent")),
 		)
 
-		go grAgent.Run()
-		go nsAgent.Run()
-		go netAgent.Run()
+		gcAgent := garbagecollector.NewGarbageCollectorAgent(
+			mustNewClient(coreTLS, coreCommonName, "GarbageCollectorAgent"),
+			logger.With(zap.Namespace("GarbageCollectorAgent")),
+		)
+
+		schedAgent := scheduler.NewSchedulerAgent(
+			mustNewClient(coreTLS, coreCommonName, "SchedulerAgent"),
+			&config.SchedulerAgentConfig,
+			logger.With(zap.Namespace("SchedulerAgent")),
+		)
+
+		nodeLifecycleAgent := nodelifecycle.NewNodeLifecycleAgent(
+			mustNewClient(coreTLS, coreCommonName, "NodeLifecycleAgent"),
+			&config.NodeLifecycleAgentConfig,
+			logger.With(zap.Namespace("NodeLifecycleAgent")),
+		)
+
+		grAgent.SetHealthReporter(healthRegistry.Reporter("GroupAgent"))
+		nsAgent.SetHealthReporter(healthRegistry.Reporter("NamespaceAgent"))
+		netAgent.SetHealthReporter(healthRegistry.Reporter("NetworkAgent"))
+		gcAgent.SetHealthReporter(healthRegistry.Reporter("GarbageCollectorAgent"))
+		schedAgent.SetHealthReporter(healthRegistry.Reporter("SchedulerAgent"))
+		nodeLifecycleAgent.SetHealthReporter(healthRegistry.Reporter("NodeLifecycleAgent"))
+
+		grAgent.SetEventRecorder(coreRecorder)
+		nsAgent.SetEventRecorder(coreRecorder)
+		netAgent.SetEventRecorder(coreRecorder)
+		gcAgent.SetEventRecorder(coreRecorder)
+		schedAgent.SetEventRecorder(coreRecorder)
+		nodeLifecycleAgent.SetEventRecorder(coreRecorder)
+
+		grAgent.SetLeaderElector(elector)
+		nsAgent.SetLeaderElector(elector)
+		netAgent.SetLeaderElector(elector)
+		gcAgent.SetLeaderElector(elector)
+		schedAgent.SetLeaderElector(elector)
+		nodeLifecycleAgent.SetLeaderElector(elector)
+
+		go grAgent.Run(ctx)
+		go nsAgent.Run(ctx)
+		go netAgent.Run(ctx)
+		go gcAgent.Run(ctx)
+		go schedAgent.Run(ctx)
+		go nodeLifecycleAgent.Run(ctx)
 	}
 
 	if config.AgentMode == AgentModeAll || config.AgentMode == AgentModeSystem {
@@ -143,6 +318,7 @@ func main() {
 
 		vmAgent := virtualmachine.NewVirtualMachineAgent(
 			client,
+			&config.VirtualMachineAgentConfig,
 			logger.With(zap.Namespace("VirtualMachineAgent")),
 		)
 
@@ -154,14 +330,26 @@ func main() {
 			logger.With(zap.Namespace("VirtualRouterAgent")),
 		)
 
+		bsAgent.SetHealthReporter(healthRegistry.Reporter("BlockStorageAgent"))
+		imAgent.SetHealthReporter(healthRegistry.Reporter("ImageAgent"))
+		vmAgent.SetHealthReporter(healthRegistry.Reporter("VirtualMachineAgent"))
+		vrAgent.SetHealthReporter(healthRegistry.Reporter("VirtualRouterAgent"))
+		nodeNetAgent.SetHealthReporter(healthRegistry.Reporter("NodeNetworkAgent"))
+
+		bsAgent.SetEventRecorder(recorder)
+		imAgent.SetEventRecorder(recorder)
+		vmAgent.SetEventRecorder(recorder)
+		vrAgent.SetEventRecorder(recorder)
+		nodeNetAgent.SetEventRecorder(recorder)
+
 		log.Println(config.ImageAgentConfig.DownloadAPI)
-		go bsAgent.Run()
+		go bsAgent.Run(ctx)
 		go bsAgent.DownloadAPI(&config.BlockStorageAgentConfig.DownloadAPI)
-		go imAgent.Run()
+		go imAgent.Run(ctx)
 		go imAgent.DownloadAPI(&config.ImageAgentConfig.DownloadAPI)
-		go vmAgent.Run()
-		go vrAgent.Run()
-		go nodeNetAgent.Run()
+		go vmAgent.Run(ctx)
+		go vrAgent.Run(ctx)
+		go nodeNetAgent.Run(ctx)
 
 	}
 
@@ -170,4 +358,48 @@ func main() {
 	signal.Notify(done, os.Interrupt)
 
 	<-done
+
+	// leaderの場合は他のagentがすぐに引き継げるようにleaseを解放してから終了する
+	cancel()
+	<-electorDone
+}
+
+// mustNewClient はnewClientに失敗した場合に終了する
+func mustNewClient(c *tlsutil.ClientConfig, commonName, component string) *client.Clients {
+	cl, err := newClient(c, commonName, component)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cl
+}
+
+// newClient はtlsの設定でapiserverに接続するclientを作る
+// クライアント証明書を指定した場合はCommonNameがcommonNameであることを確認する
+// componentを指定した場合はUser-Agentの末尾に付ける
+func newClient(c *tlsutil.ClientConfig, commonName, component string) (*client.Clients, error) {
+	tlsConfig, err := tlsutil.NewClientTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tlsutil.LoadClientCertificate(c)
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil && cert.Subject.CommonName != commonName {
+		return nil, fmt.Errorf("client certificate CommonName must be `%s`, but got `%s`", commonName, cert.Subject.CommonName)
+	}
+
+	userAgent := client.DefaultUserAgent()
+	if component != "" {
+		userAgent += " " + component
+	}
+
+	return client.NewClientsWithConfig(&client.Config{
+		Address:         config.ApiServerAddress,
+		Port:            config.ApiServerPort,
+		TLSClientConfig: tlsConfig,
+		Timeout:         config.ApiServerTimeout,
+		UserAgent:       userAgent,
+	}), nil
 }
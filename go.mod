@@ -8,11 +8,14 @@ require (
 	github.com/gin-gonic/gin v1.6.3
 	github.com/go-resty/resty/v2 v2.3.0
 	github.com/google/uuid v1.1.1
+	github.com/gorilla/websocket v1.4.0
 	github.com/koding/websocketproxy v0.0.0-20181220232114-7ed82d81a28c
 	github.com/n0stack/n0stack v0.2.134
 	github.com/olekukonko/tablewriter v0.0.1
 	github.com/ophum/ictsc2019-n0stack v0.0.0-20200525081808-7b9228014455
 	github.com/pkg/errors v0.9.1
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/prometheus/client_golang v1.7.1
 	github.com/r3labs/sse v0.0.0-20201007160420-c638e5516aa7
 	github.com/rakyll/statik v0.1.7
 	github.com/spf13/cobra v1.0.0
@@ -21,5 +24,6 @@ require (
 	github.com/vishvananda/netlink v1.1.0
 	go.uber.org/zap v1.10.0
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a
+	gopkg.in/cenkalti/backoff.v1 v1.1.0
 	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,721 @@
+package garbagecollector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/agents/leaderelection"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type GarbageCollectorAgent struct {
+	client   client.Interface
+	logger   *zap.Logger
+	health   *health.Reporter
+	recorder *event.Recorder
+	elector  *leaderelection.LeaderElector
+}
+
+// object は種類によらずリソースを扱えるようにしたもの
+type object struct {
+	meta *meta.Meta
+	// update はapiserverから取得し直したリソースをmutateで変更して更新する。mutateがfalseを返した場合は更新しない
+	// 一覧を取得した後にagentが変更した内容を、一覧のリソースで上書きしないようにする
+	update func(mutate func(m *meta.Meta) bool) error
+	delete func(policy meta.DeletionPropagation) error
+}
+
+// 競合した場合に取得し直して更新する回数
+const updateRetries = 5
+
+func NewGarbageCollectorAgent(client client.Interface, logger *zap.Logger) *GarbageCollectorAgent {
+	return &GarbageCollectorAgent{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (a *GarbageCollectorAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *GarbageCollectorAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *GarbageCollectorAgent) SetLeaderElector(elector *leaderelection.LeaderElector) {
+	a.elector = elector
+}
+
+func (a *GarbageCollectorAgent) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// leaderでない場合は処理しない
+			if !a.elector.IsLeader() {
+				a.health.Reconciled()
+				continue
+			}
+
+			objects, err := a.listObjects()
+			if err != nil {
+				// 一部のリソースが取得できていない状態で処理すると
+				// 所有者が存在するリソースを消してしまうので何もしない
+				a.logger.Error(
+					"list objects",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
+			}
+
+			for _, obj := range objects {
+				if err := a.collect(obj, objects); err != nil {
+					a.logger.Error(
+						"collect garbage",
+						zap.String("apiType", string(obj.meta.APIType)),
+						zap.String("id", obj.meta.ID),
+						zap.String("msg", err.Error()),
+						zap.Time("time", time.Now()),
+					)
+					a.recorder.Event(*obj.meta, core.EventTypeWarning, "GarbageCollectionFailed", err.Error())
+				}
+			}
+
+			a.health.Reconciled()
+		}
+	}
+}
+
+func (a *GarbageCollectorAgent) collect(obj *object, objects []*object) error {
+	if obj.meta.IsDeleting() {
+		return a.finalize(obj, objects)
+	}
+
+	// 削除中のgroup, namespaceに所属するリソースは所有者がいなくても削除する
+	if parent := findDeletingParent(obj, objects); parent != nil {
+		policy := meta.DeletionPropagationBackground
+		if parent.meta.HasFinalizer(meta.FinalizerForegroundDeletion) {
+			policy = meta.DeletionPropagationForeground
+		}
+		if err := obj.delete(policy); err != nil && !meta.IsNotFound(err) {
+			return errors.Wrap(err, "delete member")
+		}
+		return nil
+	}
+
+	if len(obj.meta.OwnerReferences) == 0 {
+		return nil
+	}
+
+	// 所有者が1つでも残っている場合は削除しない
+	policy := meta.DeletionPropagationBackground
+	for _, ref := range obj.meta.OwnerReferences {
+		// 不正な参照の場合は消さないようにする
+		if ref.ID == "" {
+			return nil
+		}
+
+		owner := findOwner(ref, objects)
+		if owner == nil {
+			// 一覧は種類ごとに取得しているので、取得した後に作られた所有者が含まれていないことがある
+			// 削除する前に直接取得して、存在しないかuidが違う場合のみ所有者がいないものとする
+			exists, err := a.ownerExists(ref)
+			if err != nil {
+				return errors.Wrap(err, "get owner")
+			}
+			if exists {
+				return nil
+			}
+			continue
+		}
+
+		if !owner.meta.IsDeleting() || !owner.meta.HasFinalizer(meta.FinalizerForegroundDeletion) {
+			return nil
+		}
+		// 所有者がForegroundで削除中の場合は依存するリソースもForegroundで削除する
+		policy = meta.DeletionPropagationForeground
+	}
+
+	// 他のagentなどが既に削除している場合は何もしない
+	if err := obj.delete(policy); err != nil && !meta.IsNotFound(err) {
+		return errors.Wrap(err, "delete dependent")
+	}
+	return nil
+}
+
+// finalize は削除中のリソースのgarbage collectorが管理するfinalizerを外す
+// Foregroundの場合は依存するリソースが、group, namespaceの場合は所属するリソースも全て消えたら外す
+func (a *GarbageCollectorAgent) finalize(obj *object, objects []*object) error {
+	hasDependents, hasMembers := false, false
+	for _, o := range objects {
+		if o.meta.IsOwnedBy(*obj.meta) {
+			hasDependents = true
+		}
+		if isMember(o.meta, obj.meta) {
+			hasMembers = true
+		}
+	}
+
+	finalizers := []string{}
+	if !hasDependents && obj.meta.HasFinalizer(meta.FinalizerForegroundDeletion) {
+		finalizers = append(finalizers, meta.FinalizerForegroundDeletion)
+	}
+	if !hasDependents && !hasMembers && obj.meta.HasFinalizer(meta.FinalizerGarbageCollector) {
+		finalizers = append(finalizers, meta.FinalizerGarbageCollector)
+	}
+	if len(finalizers) == 0 {
+		return nil
+	}
+
+	err := obj.update(func(m *meta.Meta) bool {
+		changed := false
+		for _, f := range finalizers {
+			if m.HasFinalizer(f) {
+				m.RemoveFinalizer(f)
+				changed = true
+			}
+		}
+		return changed
+	})
+	return errors.Wrap(err, "remove finalizer")
+}
+
+// updateWithRetry はresourceVersionが競合した場合に、取得し直して更新する
+// 既に削除されている場合は何もしない
+func updateWithRetry(f func() error) error {
+	var err error
+	for i := 0; i < updateRetries; i++ {
+		if err = f(); !meta.IsConflict(err) {
+			break
+		}
+	}
+	if meta.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// isMember はmがparentのgroupまたはnamespaceに所属するリソースかを返す
+func isMember(m, parent *meta.Meta) bool {
+	switch parent.APIType {
+	case meta.APITypeGroupV0:
+		return m.APIType != meta.APITypeGroupV0 && m.Group == parent.ID
+	case meta.APITypeNamespaceV0:
+		return m.APIType != meta.APITypeNamespaceV0 && m.Group == parent.Group && m.Namespace == parent.ID
+	}
+	return false
+}
+
+// findDeletingParent はobjが所属するgroup, namespaceのうち、garbage collectorのfinalizerを付けて削除中のものを返す
+func findDeletingParent(obj *object, objects []*object) *object {
+	for _, o := range objects {
+		if o.meta.IsDeleting() &&
+			o.meta.HasFinalizer(meta.FinalizerGarbageCollector) &&
+			isMember(obj.meta, o.meta) {
+			return o
+		}
+	}
+	return nil
+}
+
+func findOwner(ref meta.OwnerReference, objects []*object) *object {
+	for _, o := range objects {
+		if ref.Refers(*o.meta) {
+			return o
+		}
+	}
+	return nil
+}
+
+func (a *GarbageCollectorAgent) ownerExists(ref meta.OwnerReference) (bool, error) {
+	owner, err := a.getOwner(ref)
+	if meta.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return ref.Refers(*owner), nil
+}
+
+// getOwner はrefが指すリソースのmetaをapiserverから取得する
+func (a *GarbageCollectorAgent) getOwner(ref meta.OwnerReference) (*meta.Meta, error) {
+	ctx := context.TODO()
+	switch ref.APIType {
+	case meta.APITypeNodeV0:
+		node, err := a.client.SystemV0().Node().Get(ctx, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &node.Meta, nil
+	case meta.APITypeExternalIPPoolV0:
+		eippool, err := a.client.CoreV0().ExternalIPPool().Get(ctx, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &eippool.Meta, nil
+	case meta.APITypeExternalIPV0:
+		eip, err := a.client.CoreV0().ExternalIP().Get(ctx, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &eip.Meta, nil
+	case meta.APITypeGroupV0:
+		group, err := a.client.CoreV0().Group().Get(ctx, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &group.Meta, nil
+	case meta.APITypeImageV0:
+		image, err := a.client.SystemV0().Image().Get(ctx, ref.Group, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &image.Meta, nil
+	case meta.APITypeImageEntityV0:
+		imageEntity, err := a.client.SystemV0().ImageEntity().Get(ctx, ref.Group, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &imageEntity.Meta, nil
+	case meta.APITypeImageTagV0:
+		imageTag, err := a.client.SystemV0().ImageTag().Get(ctx, ref.Group, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &imageTag.Meta, nil
+	case meta.APITypeNamespaceV0:
+		ns, err := a.client.CoreV0().Namespace().Get(ctx, ref.Group, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &ns.Meta, nil
+	case meta.APITypeNetworkV0:
+		net, err := a.client.CoreV0().Network().Get(ctx, ref.Group, ref.Namespace, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &net.Meta, nil
+	case meta.APITypeNodeNetworkV0:
+		nodeNet, err := a.client.SystemV0().NodeNetwork().Get(ctx, ref.Group, ref.Namespace, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &nodeNet.Meta, nil
+	case meta.APITypeBlockStorageV0:
+		bs, err := a.client.SystemV0().BlockStorage().Get(ctx, ref.Group, ref.Namespace, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &bs.Meta, nil
+	case meta.APITypeVirtualMachineV0:
+		vm, err := a.client.SystemV0().VirtualMachine().Get(ctx, ref.Group, ref.Namespace, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &vm.Meta, nil
+	case meta.APITypeVirtualRouterV0:
+		vr, err := a.client.SystemV0().VirtualRouter().Get(ctx, ref.Group, ref.Namespace, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &vr.Meta, nil
+	}
+
+	// 確認できない所有者の場合は消さないようにする
+	return nil, fmt.Errorf("unknown owner apiType `%s`", ref.APIType)
+}
+
+func (a *GarbageCollectorAgent) listObjects() ([]*object, error) {
+	objects := []*object{}
+
+	nodeList, err := a.client.SystemV0().Node().List(context.TODO())
+	if err != nil {
+		return nil, errors.Wrap(err, "get node list")
+	}
+	for _, node := range nodeList {
+		node := node
+		objects = append(objects, &object{
+			meta: &node.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.SystemV0().Node().Get(context.TODO(), node.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.SystemV0().Node().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.SystemV0().Node().DeleteWithPropagation(context.TODO(), node.ID, policy)
+			},
+		})
+	}
+
+	eippoolList, err := a.client.CoreV0().ExternalIPPool().List(context.TODO())
+	if err != nil {
+		return nil, errors.Wrap(err, "get externalippool list")
+	}
+	for _, eippool := range eippoolList {
+		eippool := eippool
+		objects = append(objects, &object{
+			meta: &eippool.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.CoreV0().ExternalIPPool().Get(context.TODO(), eippool.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.CoreV0().ExternalIPPool().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.CoreV0().ExternalIPPool().DeleteWithPropagation(context.TODO(), eippool.ID, policy)
+			},
+		})
+	}
+
+	eipList, err := a.client.CoreV0().ExternalIP().List(context.TODO())
+	if err != nil {
+		return nil, errors.Wrap(err, "get externalip list")
+	}
+	for _, eip := range eipList {
+		eip := eip
+		objects = append(objects, &object{
+			meta: &eip.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.CoreV0().ExternalIP().Get(context.TODO(), eip.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.CoreV0().ExternalIP().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.CoreV0().ExternalIP().DeleteWithPropagation(context.TODO(), eip.ID, policy)
+			},
+		})
+	}
+
+	grList, err := a.client.CoreV0().Group().List(context.TODO())
+	if err != nil {
+		return nil, errors.Wrap(err, "get group list")
+	}
+	for _, group := range grList {
+		group := group
+		objects = append(objects, &object{
+			meta: &group.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.CoreV0().Group().Get(context.TODO(), group.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.CoreV0().Group().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.CoreV0().Group().DeleteWithPropagation(context.TODO(), group.ID, policy)
+			},
+		})
+
+		groupObjects, err := a.listGroupObjects(group.ID)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, groupObjects...)
+	}
+
+	return objects, nil
+}
+
+func (a *GarbageCollectorAgent) listGroupObjects(groupID string) ([]*object, error) {
+	objects := []*object{}
+
+	imageList, err := a.client.SystemV0().Image().List(context.TODO(), groupID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get image list")
+	}
+	for _, image := range imageList {
+		image := image
+		objects = append(objects, &object{
+			meta: &image.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.SystemV0().Image().Get(context.TODO(), image.Group, image.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.SystemV0().Image().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.SystemV0().Image().DeleteWithPropagation(context.TODO(), image.Group, image.ID, policy)
+			},
+		})
+	}
+
+	imageEntityList, err := a.client.SystemV0().ImageEntity().List(context.TODO(), groupID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get imageentity list")
+	}
+	for _, imageEntity := range imageEntityList {
+		imageEntity := imageEntity
+		objects = append(objects, &object{
+			meta: &imageEntity.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.SystemV0().ImageEntity().Get(context.TODO(), imageEntity.Group, imageEntity.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.SystemV0().ImageEntity().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.SystemV0().ImageEntity().DeleteWithPropagation(context.TODO(), imageEntity.Group, imageEntity.ID, policy)
+			},
+		})
+	}
+
+	imageTagList, err := a.client.SystemV0().ImageTag().List(context.TODO(), groupID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get imagetag list")
+	}
+	for _, imageTag := range imageTagList {
+		imageTag := imageTag
+		objects = append(objects, &object{
+			meta: &imageTag.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.SystemV0().ImageTag().Get(context.TODO(), imageTag.Group, imageTag.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.SystemV0().ImageTag().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.SystemV0().ImageTag().DeleteWithPropagation(context.TODO(), imageTag.Group, imageTag.ID, policy)
+			},
+		})
+	}
+
+	nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), groupID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get namespace list")
+	}
+	for _, ns := range nsList {
+		ns := ns
+		objects = append(objects, &object{
+			meta: &ns.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.CoreV0().Namespace().Get(context.TODO(), ns.Group, ns.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.CoreV0().Namespace().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.CoreV0().Namespace().DeleteWithPropagation(context.TODO(), ns.Group, ns.ID, policy)
+			},
+		})
+
+		nsObjects, err := a.listNamespaceObjects(groupID, ns.ID)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, nsObjects...)
+	}
+
+	return objects, nil
+}
+
+func (a *GarbageCollectorAgent) listNamespaceObjects(groupID, namespaceID string) ([]*object, error) {
+	objects := []*object{}
+
+	netList, err := a.client.CoreV0().Network().List(context.TODO(), groupID, namespaceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get network list")
+	}
+	for _, net := range netList {
+		net := net
+		objects = append(objects, &object{
+			meta: &net.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.CoreV0().Network().Get(context.TODO(), net.Group, net.Namespace, net.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.CoreV0().Network().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.CoreV0().Network().DeleteWithPropagation(context.TODO(), net.Group, net.Namespace, net.ID, policy)
+			},
+		})
+	}
+
+	nodeNetList, err := a.client.SystemV0().NodeNetwork().List(context.TODO(), groupID, namespaceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get nodenetwork list")
+	}
+	for _, nodeNet := range nodeNetList {
+		nodeNet := nodeNet
+		objects = append(objects, &object{
+			meta: &nodeNet.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.SystemV0().NodeNetwork().Get(context.TODO(), nodeNet.Group, nodeNet.Namespace, nodeNet.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.SystemV0().NodeNetwork().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.SystemV0().NodeNetwork().DeleteWithPropagation(context.TODO(), nodeNet.Group, nodeNet.Namespace, nodeNet.ID, policy)
+			},
+		})
+	}
+
+	bsList, err := a.client.SystemV0().BlockStorage().List(context.TODO(), groupID, namespaceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get blockstorage list")
+	}
+	for _, bs := range bsList {
+		bs := bs
+		objects = append(objects, &object{
+			meta: &bs.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.SystemV0().BlockStorage().Get(context.TODO(), bs.Group, bs.Namespace, bs.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.SystemV0().BlockStorage().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.SystemV0().BlockStorage().DeleteWithPropagation(context.TODO(), bs.Group, bs.Namespace, bs.ID, policy)
+			},
+		})
+	}
+
+	vmList, err := a.client.SystemV0().VirtualMachine().List(context.TODO(), groupID, namespaceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get virtualmachine list")
+	}
+	for _, vm := range vmList {
+		vm := vm
+		objects = append(objects, &object{
+			meta: &vm.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.SystemV0().VirtualMachine().Get(context.TODO(), vm.Group, vm.Namespace, vm.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.SystemV0().VirtualMachine().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.SystemV0().VirtualMachine().DeleteWithPropagation(context.TODO(), vm.Group, vm.Namespace, vm.ID, policy)
+			},
+		})
+	}
+
+	vrList, err := a.client.SystemV0().VirtualRouter().List(context.TODO(), groupID, namespaceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get virtualrouter list")
+	}
+	for _, vr := range vrList {
+		vr := vr
+		objects = append(objects, &object{
+			meta: &vr.Meta,
+			update: func(mutate func(m *meta.Meta) bool) error {
+				return updateWithRetry(func() error {
+					latest, err := a.client.SystemV0().VirtualRouter().Get(context.TODO(), vr.Group, vr.Namespace, vr.ID)
+					if err != nil {
+						return err
+					}
+					if !mutate(&latest.Meta) {
+						return nil
+					}
+					_, err = a.client.SystemV0().VirtualRouter().Update(context.TODO(), latest)
+					return err
+				})
+			},
+			delete: func(policy meta.DeletionPropagation) error {
+				return a.client.SystemV0().VirtualRouter().DeleteWithPropagation(context.TODO(), vr.Group, vr.Namespace, vr.ID, policy)
+			},
+		})
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,248 @@
+package garbagecollector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/fake"
+	"go.uber.org/zap"
+)
+
+func collectAll(t *testing.T, a *GarbageCollectorAgent) {
+	objects, err := a.listObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objects {
+		if err := a.collect(obj, objects); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newObjects() (*core.Network, *system.NodeNetwork) {
+	net := &core.Network{
+		Meta: meta.Meta{
+			ID:        "net1",
+			UID:       "net1-uid",
+			Group:     "group1",
+			Namespace: "ns1",
+			APIType:   meta.APITypeNetworkV0,
+		},
+	}
+	nodeNet := &system.NodeNetwork{
+		Meta: meta.Meta{
+			ID:        "net1-node1",
+			Group:     "group1",
+			Namespace: "ns1",
+			OwnerReferences: []meta.OwnerReference{
+				meta.NewOwnerReference(net.Meta),
+			},
+		},
+	}
+	return net, nodeNet
+}
+
+func TestCollectOrphan(t *testing.T) {
+	_, nodeNet := newObjects()
+	clients := fake.NewClients(
+		&core.Group{Meta: meta.Meta{ID: "group1"}},
+		&core.Namespace{Meta: meta.Meta{ID: "ns1", Group: "group1"}},
+		nodeNet,
+	)
+	a := NewGarbageCollectorAgent(clients, zap.NewNop())
+
+	// 所有者がいないので削除する
+	collectAll(t, a)
+
+	deleted := false
+	for _, action := range clients.Actions() {
+		if action.Verb == fake.VerbDelete && action.APIType == meta.APITypeNodeNetworkV0 {
+			if action.ID != nodeNet.ID || action.Policy != meta.DeletionPropagationBackground {
+				t.Fatalf("unexpected action: %+v", action)
+			}
+			deleted = true
+		}
+	}
+	if !deleted {
+		t.Fatal("orphan is not deleted")
+	}
+}
+
+func TestCollectForeground(t *testing.T) {
+	ctx := context.Background()
+	net, nodeNet := newObjects()
+	clients := fake.NewClients(
+		&core.Group{Meta: meta.Meta{ID: "group1"}},
+		&core.Namespace{Meta: meta.Meta{ID: "ns1", Group: "group1"}},
+		net,
+		nodeNet,
+	)
+	a := NewGarbageCollectorAgent(clients, zap.NewNop())
+
+	// 所有者がいる間は何もしない
+	collectAll(t, a)
+	for _, action := range clients.Actions() {
+		if action.Verb != fake.VerbList {
+			t.Fatalf("unexpected action: %+v", action)
+		}
+	}
+
+	if err := clients.CoreV0().Network().DeleteWithPropagation(ctx, net.Group, net.Namespace, net.ID, meta.DeletionPropagationForeground); err != nil {
+		t.Fatal(err)
+	}
+
+	// 依存するリソースもForegroundで削除する
+	collectAll(t, a)
+	deleting, err := clients.SystemV0().NodeNetwork().Get(ctx, nodeNet.Group, nodeNet.Namespace, nodeNet.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deleting.IsDeleting() || !deleting.HasFinalizer(meta.FinalizerForegroundDeletion) {
+		t.Fatalf("unexpected meta: %+v", deleting.Meta)
+	}
+
+	// 依存するリソースが消えてから所有者のfinalizerを外す
+	collectAll(t, a)
+	if _, err := clients.SystemV0().NodeNetwork().Get(ctx, nodeNet.Group, nodeNet.Namespace, nodeNet.ID); !meta.IsNotFound(err) {
+		t.Fatalf("expected NotFound, but got %v", err)
+	}
+	if _, err := clients.CoreV0().Network().Get(ctx, net.Group, net.Namespace, net.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	collectAll(t, a)
+	if _, err := clients.CoreV0().Network().Get(ctx, net.Group, net.Namespace, net.ID); !meta.IsNotFound(err) {
+		t.Fatalf("expected NotFound, but got %v", err)
+	}
+}
+
+func TestCollectOwnerNotInList(t *testing.T) {
+	net, nodeNet := newObjects()
+	clients := fake.NewClients(
+		&core.Group{Meta: meta.Meta{ID: "group1"}},
+		&core.Namespace{Meta: meta.Meta{ID: "ns1", Group: "group1"}},
+		net,
+		nodeNet,
+	)
+	a := NewGarbageCollectorAgent(clients, zap.NewNop())
+
+	objects, err := a.listObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 所有者を一覧した後に作られた場合と同じ状態にする
+	withoutOwner := []*object{}
+	var dependent *object
+	for _, obj := range objects {
+		if obj.meta.APIType == meta.APITypeNetworkV0 {
+			continue
+		}
+		if obj.meta.ID == nodeNet.ID {
+			dependent = obj
+		}
+		withoutOwner = append(withoutOwner, obj)
+	}
+
+	// 所有者が存在する場合は削除しない
+	if err := a.collect(dependent, withoutOwner); err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range clients.Actions() {
+		if action.Verb == fake.VerbDelete {
+			t.Fatalf("unexpected action: %+v", action)
+		}
+	}
+
+	// uidが違う場合は同じIDで作り直された別のリソースなので削除する
+	dependent.meta.OwnerReferences[0].UID = "old-uid"
+	if err := a.collect(dependent, withoutOwner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clients.SystemV0().NodeNetwork().Get(context.Background(), nodeNet.Group, nodeNet.Namespace, nodeNet.ID); !meta.IsNotFound(err) {
+		t.Fatalf("expected NotFound, but got %v", err)
+	}
+}
+
+func TestCollectNamespaceMembers(t *testing.T) {
+	ctx := context.Background()
+	net, _ := newObjects()
+	clients := fake.NewClients(
+		&core.Group{Meta: meta.Meta{ID: "group1"}},
+		&core.Namespace{Meta: meta.Meta{ID: "ns1", Group: "group1"}},
+		net,
+	)
+	a := NewGarbageCollectorAgent(clients, zap.NewNop())
+
+	// Backgroundで削除してもnamespaceは所属するリソースが消えるまで残る
+	if err := clients.CoreV0().Namespace().Delete(ctx, "group1", "ns1"); err != nil {
+		t.Fatal(err)
+	}
+	collectAll(t, a)
+	if _, err := clients.CoreV0().Network().Get(ctx, net.Group, net.Namespace, net.ID); !meta.IsNotFound(err) {
+		t.Fatalf("expected NotFound, but got %v", err)
+	}
+	if _, err := clients.CoreV0().Namespace().Get(ctx, "group1", "ns1"); err != nil {
+		t.Fatal(err)
+	}
+
+	collectAll(t, a)
+	if _, err := clients.CoreV0().Namespace().Get(ctx, "group1", "ns1"); !meta.IsNotFound(err) {
+		t.Fatalf("expected NotFound, but got %v", err)
+	}
+}
+
+func TestFinalizeKeepsAgentChanges(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	clients := fake.NewClients(
+		&core.Group{Meta: meta.Meta{ID: "group1"}},
+		&core.Namespace{Meta: meta.Meta{ID: "ns1", Group: "group1"}},
+		&system.VirtualMachine{Meta: meta.Meta{
+			ID:                "vm1",
+			Group:             "group1",
+			Namespace:         "ns1",
+			APIType:           meta.APITypeVirtualMachineV0,
+			Annotations:       map[string]string{"virtualmachinev0/node_name": "node1"},
+			Finalizers:        []string{meta.FinalizerForegroundDeletion, "virtualmachinev0"},
+			DeletionTimestamp: &now,
+		}},
+	)
+	a := NewGarbageCollectorAgent(clients, zap.NewNop())
+
+	objects, err := a.listObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 一覧を取得した後にagentが変更する
+	vm, err := clients.SystemV0().VirtualMachine().Get(ctx, "group1", "ns1", "vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.Annotations["virtualmachinev0/node_name"] = "node2"
+	if _, err := clients.SystemV0().VirtualMachine().Update(ctx, vm); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, obj := range objects {
+		if err := a.collect(obj, objects); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vm, err = clients.SystemV0().VirtualMachine().Get(ctx, "group1", "ns1", "vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.HasFinalizer(meta.FinalizerForegroundDeletion) || !vm.HasFinalizer("virtualmachinev0") {
+		t.Fatalf("unexpected finalizers: %v", vm.Finalizers)
+	}
+	if vm.Annotations["virtualmachinev0/node_name"] != "node2" {
+		t.Fatalf("agent change is overwritten: %v", vm.Annotations)
+	}
+}
@@ -0,0 +1,154 @@
+package garbagecollector_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	humtesting "github.com/ophum/humstack/pkg/testing"
+)
+
+func TestCollectImageEntities(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	h.CreateNamespace("group1", "ns1")
+	ctx := context.Background()
+	c := h.Clients.SystemV0()
+
+	for _, id := range []string{"entity1", "entity2", "entity3"} {
+		if _, err := c.ImageEntity().Create(ctx, &system.ImageEntity{
+			Meta: meta.Meta{ID: id, Name: id, Group: "group1"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	image, err := c.Image().Create(ctx, &system.Image{
+		Meta: meta.Meta{ID: "ubuntu", Name: "ubuntu", Group: "group1"},
+		Spec: system.ImageSpec{
+			EntityMap: map[string]string{"20.04": "entity1", "18.04": "entity3"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ImageTag().Create(ctx, &system.ImageTag{
+		Meta: meta.Meta{Group: "group1"},
+		Spec: system.ImageTagSpec{ImageName: "ubuntu", Tag: "latest", ImageEntityID: "entity2"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"entity1", "entity2"} {
+		entity, err := c.ImageEntity().Get(ctx, "group1", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !entity.IsOwnedBy(image.Meta) {
+			t.Fatalf("%s is not owned by image: %+v", id, entity.OwnerReferences)
+		}
+
+		// 所有者を含まないマニフェストでupdateしても所有者は消えない
+		entity.OwnerReferences = nil
+		entity, err = c.ImageEntity().Update(ctx, entity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !entity.IsOwnedBy(image.Meta) {
+			t.Fatalf("owner of %s is removed by update", id)
+		}
+	}
+
+	// EntityMapから外したimageEntityはimageを削除しても残す
+	delete(image.Spec.EntityMap, "18.04")
+	image, err = c.Image().Update(ctx, image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity3, err := c.ImageEntity().Get(ctx, "group1", "entity3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entity3.IsOwnedBy(image.Meta) {
+		t.Fatalf("entity3 is still owned by image: %+v", entity3.OwnerReferences)
+	}
+
+	bs, err := c.BlockStorage().Create(ctx, &system.BlockStorage{
+		Meta: meta.Meta{ID: "bs1", Name: "bs1", Group: "group1", Namespace: "ns1"},
+		Spec: system.BlockStorageSpec{RequestSize: "1G", LimitSize: "1G"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns, err := h.Clients.CoreV0().Namespace().Get(ctx, "group1", "ns1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bs.IsOwnedBy(ns.Meta) {
+		t.Fatalf("blockstorage is not owned by namespace: %+v", bs.OwnerReferences)
+	}
+
+	// imageを削除するとtagとimageEntityも削除される
+	if err := c.Image().Delete(ctx, "group1", "ubuntu"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"entity1", "entity2"} {
+		id := id
+		h.WaitForNotFound("imageentity "+id, func(ctx context.Context) error {
+			_, err := c.ImageEntity().Get(ctx, "group1", id)
+			return err
+		})
+	}
+	if _, err := c.ImageEntity().Get(ctx, "group1", "entity3"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectGroupMembers(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	h.CreateNamespace("group1", "ns1")
+	ctx := context.Background()
+
+	if _, err := h.Clients.CoreV0().Network().Create(ctx, &core.Network{
+		Meta: meta.Meta{ID: "net1", Name: "net1", Group: "group1", Namespace: "ns1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Clients.SystemV0().BlockStorage().Create(ctx, &system.BlockStorage{
+		Meta: meta.Meta{ID: "bs1", Name: "bs1", Group: "group1", Namespace: "ns1"},
+		Spec: system.BlockStorageSpec{RequestSize: "1G", LimitSize: "1G"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Clients.SystemV0().ImageEntity().Create(ctx, &system.ImageEntity{
+		Meta: meta.Meta{ID: "entity1", Name: "entity1", Group: "group1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backgroundで削除してもgroupに所属するリソースは削除される
+	if err := h.Clients.CoreV0().Group().DeleteWithPropagation(ctx, "group1", meta.DeletionPropagationBackground); err != nil {
+		t.Fatal(err)
+	}
+	h.WaitForNotFound("network net1", func(ctx context.Context) error {
+		_, err := h.Clients.CoreV0().Network().Get(ctx, "group1", "ns1", "net1")
+		return err
+	})
+	h.WaitForNotFound("blockstorage bs1", func(ctx context.Context) error {
+		_, err := h.Clients.SystemV0().BlockStorage().Get(ctx, "group1", "ns1", "bs1")
+		return err
+	})
+	h.WaitForNotFound("imageentity entity1", func(ctx context.Context) error {
+		_, err := h.Clients.SystemV0().ImageEntity().Get(ctx, "group1", "entity1")
+		return err
+	})
+	h.WaitForNotFound("namespace ns1", func(ctx context.Context) error {
+		_, err := h.Clients.CoreV0().Namespace().Get(ctx, "group1", "ns1")
+		return err
+	})
+	h.WaitForNotFound("group group1", func(ctx context.Context) error {
+		_, err := h.Clients.CoreV0().Group().Get(ctx, "group1")
+		return err
+	})
+}
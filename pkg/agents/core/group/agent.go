@@ -1,62 +1,111 @@
 package group
 
 import (
+	"context"
 	"time"
 
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/agents/leaderelection"
+	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/client"
 	"go.uber.org/zap"
 )
 
 type GroupAgent struct {
-	client *client.Clients
-	logger *zap.Logger
+	client   client.Interface
+	logger   *zap.Logger
+	health   *health.Reporter
+	recorder *event.Recorder
+	elector  *leaderelection.LeaderElector
 }
 
-func NewGroupAgent(client *client.Clients, logger *zap.Logger) *GroupAgent {
+const (
+	GroupV0FinalizerName = "groupv0/group-agent"
+)
+
+func NewGroupAgent(client client.Interface, logger *zap.Logger) *GroupAgent {
 	return &GroupAgent{
 		client: client,
 		logger: logger,
 	}
 }
 
-func (a *GroupAgent) Run() {
+func (a *GroupAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *GroupAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *GroupAgent) SetLeaderElector(elector *leaderelection.LeaderElector) {
+	a.elector = elector
+}
+
+func (a *GroupAgent) Run(ctx context.Context) {
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			// leaderでない場合は処理しない
+			if !a.elector.IsLeader() {
+				a.health.Reconciled()
+				continue
+			}
+
+			grList, err := a.client.CoreV0().Group().List(context.TODO())
 			if err != nil {
 				a.logger.Error(
 					"get group list",
 					zap.String("msg", err.Error()),
 					zap.Time("time", time.Now()),
 				)
+				continue
 			}
 
 			for _, group := range grList {
-				if group.DeleteState != meta.DeleteStateDelete {
+				if !group.IsDeleting() {
+					// namespaceを削除するまでgroupが消えないようにする
+					if group.AddFinalizer(GroupV0FinalizerName) {
+						if _, err := a.client.CoreV0().Group().Update(context.TODO(), group); err != nil {
+							a.logger.Error(
+								"add group finalizer",
+								zap.String("msg", err.Error()),
+								zap.Time("time", time.Now()),
+							)
+						}
+					}
+					continue
+				}
+
+				if !group.HasFinalizer(GroupV0FinalizerName) {
 					continue
 				}
-				// DeleteStateにDeleteが入っていてnamespaceが存在する場合
-				// namespaceにDeleteStateをセットする
 
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+				// 削除中でnamespaceが存在する場合
+				// namespaceを削除する
+				nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), group.ID)
 				if err != nil {
 					a.logger.Error(
 						"get namespace list",
 						zap.String("msg", err.Error()),
 						zap.Time("time", time.Now()),
 					)
+					continue
 				}
 
 				// 存在しない場合
 				if len(nsList) == 0 {
-					if err := a.client.CoreV0().Group().Delete(group.ID); err != nil {
+					group.RemoveFinalizer(GroupV0FinalizerName)
+					if _, err := a.client.CoreV0().Group().Update(context.TODO(), group); err != nil {
 						a.logger.Error(
-							"delete group",
+							"remove group finalizer",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
@@ -65,20 +114,22 @@ func (a *GroupAgent) Run() {
 				}
 
 				for _, ns := range nsList {
-					// すでにセットされている場合は更新しない
-					if ns.DeleteState == meta.DeleteStateDelete {
+					// すでに削除中の場合は何もしない
+					if ns.IsDeleting() {
 						continue
 					}
-					ns.DeleteState = meta.DeleteStateDelete
-					if _, err := a.client.CoreV0().Namespace().Update(ns); err != nil {
+					if err := a.client.CoreV0().Namespace().Delete(context.TODO(), ns.Group, ns.ID); err != nil && !meta.IsNotFound(err) {
 						a.logger.Error(
-							"update namespace",
+							"delete namespace",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
+						a.recorder.Eventf(group.Meta, core.EventTypeWarning, "DeleteFailed", "delete namespace `%s`: %s", ns.ID, err.Error())
 					}
 				}
 			}
+
+			a.health.Reconciled()
 		}
 	}
 }
@@ -1,8 +1,12 @@
 package namespace
 
 import (
+	"context"
 	"time"
 
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/agents/leaderelection"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/client"
@@ -10,35 +14,63 @@ import (
 )
 
 type NamespaceAgent struct {
-	client *client.Clients
-	logger *zap.Logger
+	client   client.Interface
+	logger   *zap.Logger
+	health   *health.Reporter
+	recorder *event.Recorder
+	elector  *leaderelection.LeaderElector
 }
 
-func NewNamespaceAgent(client *client.Clients, logger *zap.Logger) *NamespaceAgent {
+const (
+	NamespaceV0FinalizerName = "namespacev0/namespace-agent"
+)
+
+func NewNamespaceAgent(client client.Interface, logger *zap.Logger) *NamespaceAgent {
 	return &NamespaceAgent{
 		client: client,
 		logger: logger,
 	}
 }
 
-func (a *NamespaceAgent) Run() {
+func (a *NamespaceAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *NamespaceAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *NamespaceAgent) SetLeaderElector(elector *leaderelection.LeaderElector) {
+	a.elector = elector
+}
+
+func (a *NamespaceAgent) Run(ctx context.Context) {
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			// leaderでない場合は処理しない
+			if !a.elector.IsLeader() {
+				a.health.Reconciled()
+				continue
+			}
+
+			grList, err := a.client.CoreV0().Group().List(context.TODO())
 			if err != nil {
 				a.logger.Error(
 					"get group list",
 					zap.String("msg", err.Error()),
 					zap.Time("time", time.Now()),
 				)
+				continue
 			}
 
 			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+				nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), group.ID)
 				if err != nil {
 					a.logger.Error(
 						"get namespace list",
@@ -48,63 +80,83 @@ func (a *NamespaceAgent) Run() {
 				}
 
 				for _, ns := range nsList {
-					if ns.DeleteState != meta.DeleteStateDelete {
+					if !ns.IsDeleting() {
+						// 所属するリソースを削除するまでnamespaceが消えないようにする
+						if ns.AddFinalizer(NamespaceV0FinalizerName) {
+							if _, err := a.client.CoreV0().Namespace().Update(context.TODO(), ns); err != nil {
+								a.logger.Error(
+									"add namespace finalizer",
+									zap.String("msg", err.Error()),
+									zap.Time("time", time.Now()),
+								)
+							}
+						}
 						continue
 					}
-					// namespaceに所属するリソースにDeleteStateをセットする
+
+					if !ns.HasFinalizer(NamespaceV0FinalizerName) {
+						continue
+					}
+					// namespaceに所属するリソースを削除する
 					// virtualmachine, blockstorage, network, virtualrouter
 
 					isDeletable := true
-					if n, err := a.virtualMachinesSetDeleteState(ns); err != nil {
+					if n, err := a.deleteVirtualMachines(ns); err != nil {
 						a.logger.Error(
-							"set virtualmachines delete state",
+							"delete virtualmachines",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
+						a.recorder.Eventf(ns.Meta, core.EventTypeWarning, "DeleteFailed", "delete virtualmachines: %s", err.Error())
 					} else if n != 0 {
 						isDeletable = false
 					}
-					if n, err := a.blockStoragesSetDeleteState(ns); err != nil {
+					if n, err := a.deleteBlockStorages(ns); err != nil {
 						a.logger.Error(
-							"set blockstorages delete state",
+							"delete blockstorages",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
+						a.recorder.Eventf(ns.Meta, core.EventTypeWarning, "DeleteFailed", "delete blockstorages: %s", err.Error())
 					} else if n != 0 {
 						isDeletable = false
 					}
-					if n, err := a.networksSetDeleteState(ns); err != nil {
+					if n, err := a.deleteNetworks(ns); err != nil {
 						a.logger.Error(
-							"set network delete state",
+							"delete networks",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
+						a.recorder.Eventf(ns.Meta, core.EventTypeWarning, "DeleteFailed", "delete networks: %s", err.Error())
 					} else if n != 0 {
 						isDeletable = false
 					}
-					if n, err := a.nodeNetworksSetDeleteState(ns); err != nil {
+					if n, err := a.deleteNodeNetworks(ns); err != nil {
 						a.logger.Error(
-							"set node network delete state",
+							"delete node networks",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
+						a.recorder.Eventf(ns.Meta, core.EventTypeWarning, "DeleteFailed", "delete node networks: %s", err.Error())
 					} else if n != 0 {
 						isDeletable = false
 					}
-					if n, err := a.virtualRoutersSetDeleteState(ns); err != nil {
+					if n, err := a.deleteVirtualRouters(ns); err != nil {
 						a.logger.Error(
-							"set virtualrouter delete state",
+							"delete virtualrouters",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
+						a.recorder.Eventf(ns.Meta, core.EventTypeWarning, "DeleteFailed", "delete virtualrouters: %s", err.Error())
 					} else if n != 0 {
 						isDeletable = false
 					}
 
 					if isDeletable {
-						if err := a.client.CoreV0().Namespace().Delete(ns.Group, ns.ID); err != nil {
+						ns.RemoveFinalizer(NamespaceV0FinalizerName)
+						if _, err := a.client.CoreV0().Namespace().Update(context.TODO(), ns); err != nil {
 							a.logger.Error(
-								"delete namespace",
+								"remove namespace finalizer",
 								zap.String("msg", err.Error()),
 								zap.Time("time", time.Now()),
 							)
@@ -112,89 +164,122 @@ func (a *NamespaceAgent) Run() {
 					}
 				}
 			}
+
+			a.health.Reconciled()
 		}
 	}
 }
 
-func (a *NamespaceAgent) virtualMachinesSetDeleteState(ns *core.Namespace) (int, error) {
-	vmList, err := a.client.SystemV0().VirtualMachine().List(ns.Group, ns.ID)
+func (a *NamespaceAgent) deleteVirtualMachines(ns *core.Namespace) (int, error) {
+	vmList, err := a.client.SystemV0().VirtualMachine().List(context.TODO(), ns.Group, ns.ID)
 	if err != nil {
 		return -1, err
 	}
 
 	for _, vm := range vmList {
-		if vm.DeleteState == meta.DeleteStateDelete {
+		if vm.IsDeleting() {
 			continue
 		}
 
-		_ = a.client.SystemV0().VirtualMachine().DeleteState(vm.Group, vm.Namespace, vm.ID)
+		if err := a.client.SystemV0().VirtualMachine().Delete(context.TODO(), vm.Group, vm.Namespace, vm.ID); err != nil && !meta.IsNotFound(err) {
+			a.logger.Error(
+				"delete virtualmachine",
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+		}
 	}
 
 	return len(vmList), nil
 }
 
-func (a *NamespaceAgent) blockStoragesSetDeleteState(ns *core.Namespace) (int, error) {
-	bsList, err := a.client.SystemV0().BlockStorage().List(ns.Group, ns.ID)
+func (a *NamespaceAgent) deleteBlockStorages(ns *core.Namespace) (int, error) {
+	bsList, err := a.client.SystemV0().BlockStorage().List(context.TODO(), ns.Group, ns.ID)
 	if err != nil {
 		return -1, err
 	}
 
 	for _, bs := range bsList {
-		if bs.DeleteState == meta.DeleteStateDelete {
+		if bs.IsDeleting() {
 			continue
 		}
 
-		_ = a.client.SystemV0().BlockStorage().DeleteState(bs.Group, bs.Namespace, bs.ID)
+		if err := a.client.SystemV0().BlockStorage().Delete(context.TODO(), bs.Group, bs.Namespace, bs.ID); err != nil && !meta.IsNotFound(err) {
+			a.logger.Error(
+				"delete blockstorage",
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+		}
 	}
 
 	return len(bsList), nil
 }
 
-func (a *NamespaceAgent) networksSetDeleteState(ns *core.Namespace) (int, error) {
-	netList, err := a.client.CoreV0().Network().List(ns.Group, ns.ID)
+func (a *NamespaceAgent) deleteNetworks(ns *core.Namespace) (int, error) {
+	netList, err := a.client.CoreV0().Network().List(context.TODO(), ns.Group, ns.ID)
 	if err != nil {
 		return -1, err
 	}
 
 	for _, net := range netList {
-		if net.DeleteState == meta.DeleteStateDelete {
+		if net.IsDeleting() {
 			continue
 		}
 
-		_ = a.client.CoreV0().Network().DeleteState(net.Group, net.Namespace, net.ID)
+		if err := a.client.CoreV0().Network().Delete(context.TODO(), net.Group, net.Namespace, net.ID); err != nil && !meta.IsNotFound(err) {
+			a.logger.Error(
+				"delete network",
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+		}
 	}
 
 	return len(netList), nil
 }
 
-func (a *NamespaceAgent) nodeNetworksSetDeleteState(ns *core.Namespace) (int, error) {
-	netList, err := a.client.SystemV0().NodeNetwork().List(ns.Group, ns.ID)
+func (a *NamespaceAgent) deleteNodeNetworks(ns *core.Namespace) (int, error) {
+	netList, err := a.client.SystemV0().NodeNetwork().List(context.TODO(), ns.Group, ns.ID)
 	if err != nil {
 		return -1, err
 	}
 
 	for _, net := range netList {
-		if net.DeleteState == meta.DeleteStateDelete {
+		if net.IsDeleting() {
 			continue
 		}
 
-		_ = a.client.SystemV0().NodeNetwork().DeleteState(net.Group, net.Namespace, net.ID)
+		if err := a.client.SystemV0().NodeNetwork().Delete(context.TODO(), net.Group, net.Namespace, net.ID); err != nil && !meta.IsNotFound(err) {
+			a.logger.Error(
+				"delete nodenetwork",
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+		}
 	}
 
 	return len(netList), nil
 }
-func (a *NamespaceAgent) virtualRoutersSetDeleteState(ns *core.Namespace) (int, error) {
-	vrList, err := a.client.SystemV0().VirtualRouter().List(ns.Group, ns.ID)
+
+func (a *NamespaceAgent) deleteVirtualRouters(ns *core.Namespace) (int, error) {
+	vrList, err := a.client.SystemV0().VirtualRouter().List(context.TODO(), ns.Group, ns.ID)
 	if err != nil {
 		return -1, err
 	}
 
 	for _, vr := range vrList {
-		if vr.DeleteState == meta.DeleteStateDelete {
+		if vr.IsDeleting() {
 			continue
 		}
 
-		_ = a.client.SystemV0().VirtualRouter().DeleteState(vr.Group, vr.Namespace, vr.ID)
+		if err := a.client.SystemV0().VirtualRouter().Delete(context.TODO(), vr.Group, vr.Namespace, vr.ID); err != nil && !meta.IsNotFound(err) {
+			a.logger.Error(
+				"delete virtualrouter",
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+		}
 	}
 
 	return len(vrList), nil
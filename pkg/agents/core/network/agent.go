@@ -1,39 +1,66 @@
 package network
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/agents/leaderelection"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
-	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"strings"
 )
 
 type NetworkAgent struct {
-	client *client.Clients
-	logger *zap.Logger
+	client   client.Interface
+	logger   *zap.Logger
+	health   *health.Reporter
+	recorder *event.Recorder
+	elector  *leaderelection.LeaderElector
 }
 
-func NewNetworkAgent(client *client.Clients, logger *zap.Logger) *NetworkAgent {
+func NewNetworkAgent(client client.Interface, logger *zap.Logger) *NetworkAgent {
 	return &NetworkAgent{
 		client: client,
 		logger: logger,
 	}
 }
 
-func (a *NetworkAgent) Run() {
+func (a *NetworkAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *NetworkAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *NetworkAgent) SetLeaderElector(elector *leaderelection.LeaderElector) {
+	a.elector = elector
+}
+
+func (a *NetworkAgent) Run(ctx context.Context) {
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			// leaderでない場合は処理しない
+			if !a.elector.IsLeader() {
+				a.health.Reconciled()
+				continue
+			}
+
+			grList, err := a.client.CoreV0().Group().List(context.TODO())
 			if err != nil {
 				a.logger.Error(
 					"get group list",
@@ -44,7 +71,7 @@ func (a *NetworkAgent) Run() {
 			}
 
 			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+				nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), group.ID)
 				if err != nil {
 					a.logger.Error(
 						"get namespace list",
@@ -55,7 +82,7 @@ func (a *NetworkAgent) Run() {
 				}
 
 				for _, ns := range nsList {
-					netList, err := a.client.CoreV0().Network().List(group.ID, ns.ID)
+					netList, err := a.client.CoreV0().Network().List(context.TODO(), group.ID, ns.ID)
 
 					if err != nil {
 						a.logger.Error(
@@ -75,6 +102,7 @@ func (a *NetworkAgent) Run() {
 								zap.String("msg", err.Error()),
 								zap.Time("time", time.Now()),
 							)
+							a.recorder.Event(net.Meta, core.EventTypeWarning, "SyncFailed", err.Error())
 							continue
 						}
 
@@ -82,7 +110,7 @@ func (a *NetworkAgent) Run() {
 							continue
 						}
 
-						_, err = a.client.CoreV0().Network().Update(net)
+						_, err = a.client.CoreV0().Network().Update(context.TODO(), net)
 						if err != nil {
 							a.logger.Error(
 								"update network",
@@ -94,65 +122,40 @@ func (a *NetworkAgent) Run() {
 					}
 				}
 			}
+
+			a.health.Reconciled()
 		}
 	}
 }
 
 func (a *NetworkAgent) syncNetwork(net *core.Network) error {
-	nodeList, err := a.client.SystemV0().Node().List()
+	// 削除中の場合は作成しない
+	// nodeNetworkはgarbage collectorが削除する
+	if net.IsDeleting() {
+		return nil
+	}
+
+	nodeList, err := a.client.SystemV0().Node().List(context.TODO())
 	if err != nil {
 		return err
 	}
 
-	// 削除処理
-	if net.DeleteState == meta.DeleteStateDelete {
-		isDelete := true
-		for _, node := range nodeList {
-			nodeNet, err := a.client.SystemV0().NodeNetwork().Get(net.Group, net.Namespace, getNodeNetworkID(net.ID, node.ID))
-			if err != nil {
-				a.logger.Error(
-					"set delete state",
-					zap.String("msg", err.Error()),
-					zap.Time("time", time.Now()),
-				)
-				continue
-			}
-
-			if nodeNet.ID != "" {
-				isDelete = false
-				if nodeNet.DeleteState == meta.DeleteStateDelete {
-					continue
-				}
-				if err := a.client.SystemV0().NodeNetwork().DeleteState(net.Group, net.Namespace, getNodeNetworkID(net.ID, node.ID)); err != nil {
-					a.logger.Error(
-						"set delete state",
-						zap.String("msg", err.Error()),
-						zap.Time("time", time.Now()),
-					)
-					continue
-				}
-			}
-		}
-
-		if isDelete {
-			if err := a.client.CoreV0().Network().Delete(net.Group, net.Namespace, net.ID); err != nil {
-				return errors.Wrap(err, "delete network")
-			}
-		}
-		return nil
-	}
 	// 各ノードに作られていなければ作成する
+	notReadyNodes := []string{}
 	for _, node := range nodeList {
-		nodeNet, err := a.client.SystemV0().NodeNetwork().Get(net.Group, net.Namespace, fmt.Sprintf("%s_%s", net.ID, node.ID))
-		if err != nil {
+		nodeNet, err := a.client.SystemV0().NodeNetwork().Get(context.TODO(), net.Group, net.Namespace, fmt.Sprintf("%s_%s", net.ID, node.ID))
+		if err != nil && !meta.IsNotFound(err) {
 			a.logger.Error(
 				"get node network",
 				zap.String("msg", err.Error()),
 				zap.Time("time", time.Now()),
 			)
+			notReadyNodes = append(notReadyNodes, node.ID)
 			continue
 		}
-		if nodeNet.ID == "" {
+		// 存在しない場合は作成する(作成直後はReadyでない)
+		if meta.IsNotFound(err) {
+			notReadyNodes = append(notReadyNodes, node.ID)
 			nodeNet := &system.NodeNetwork{
 				Meta: meta.Meta{
 					ID:          fmt.Sprintf("%s_%s", net.ID, node.ID),
@@ -161,9 +164,7 @@ func (a *NetworkAgent) syncNetwork(net *core.Network) error {
 					Group:       net.Group,
 					Annotations: net.Spec.Template.Annotations,
 					OwnerReferences: []meta.OwnerReference{
-						{
-							Meta: net.Meta,
-						},
+						meta.NewOwnerReference(net.Meta),
 					},
 				},
 				Spec: net.Spec.Template.Spec,
@@ -173,17 +174,34 @@ func (a *NetworkAgent) syncNetwork(net *core.Network) error {
 				nodeNet.Annotations = map[string]string{}
 			}
 			nodeNet.Annotations["nodenetworkv0/node_name"] = node.ID
-			if _, err := a.client.SystemV0().NodeNetwork().Create(nodeNet); err != nil {
+			if _, err := a.client.SystemV0().NodeNetwork().Create(context.TODO(), nodeNet); err != nil {
 				a.logger.Error(
 					"create node network",
 					zap.String("msg", err.Error()),
 					zap.Time("time", time.Now()),
 				)
-				continue
 			}
+			continue
+		}
+		if !meta.IsConditionTrue(nodeNet.Status.Conditions, system.NodeNetworkConditionReady) {
+			notReadyNodes = append(notReadyNodes, node.ID)
 		}
 	}
 
+	// 全ノードのnodeNetworkがReadyになったらReadyとする
+	condition := meta.Condition{
+		Type:               core.NetworkConditionReady,
+		Status:             meta.ConditionTrue,
+		Reason:             "NodeNetworksReady",
+		ObservedGeneration: net.Generation,
+	}
+	if len(notReadyNodes) > 0 {
+		condition.Status = meta.ConditionFalse
+		condition.Reason = "NodeNetworkNotReady"
+		condition.Message = fmt.Sprintf("node networks on %s are not ready.", strings.Join(notReadyNodes, ", "))
+	}
+	meta.SetCondition(&net.Status.Conditions, condition)
+
 	return setHash(net)
 }
 
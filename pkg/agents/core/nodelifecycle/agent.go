@@ -0,0 +1,409 @@
+package nodelifecycle
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/agents/leaderelection"
+	"github.com/ophum/humstack/pkg/agents/system/node"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// system agent, schedulerと同じannotation
+const (
+	annotationVirtualMachineNodeName         = "virtualmachinev0/node_name"
+	annotationVirtualMachinePreviousNodeName = "virtualmachinev0/previous_node_name"
+	annotationBlockStorageNodeName           = "blockstoragev0/node_name"
+	annotationBlockStorageType               = "blockstoragev0/type"
+	annotationVirtualRouterNodeName          = "virtualrouterv0/node_name"
+
+	blockStorageTypeCeph = "Ceph"
+)
+
+const (
+	reasonNodeStatusUnknown = "NodeStatusUnknown"
+	reasonNodeNotReady      = "NodeNotReady"
+	reasonNodeReady         = "NodeReady"
+	reasonRescheduled       = "Rescheduled"
+	reasonFenced            = "Fenced"
+	reasonFenceFailed       = "FenceFailed"
+)
+
+// NodeLifecycleAgent はleaseを更新しなくなったノードをNotReadyにし、
+// そのノードのVM, blockstorage, virtualrouterのNodeReady conditionをFalseにする
+type NodeLifecycleAgent struct {
+	client   client.Interface
+	config   *NodeLifecycleAgentConfig
+	logger   *zap.Logger
+	health   *health.Reporter
+	recorder *event.Recorder
+	elector  *leaderelection.LeaderElector
+	now      func() time.Time
+
+	// ノードごとに最後に観測したleaseのrenewTimeと、それを観測した時刻
+	// apiserverとの時計のずれに影響されないように、変化がない期間を自身の時計で測る
+	observed map[string]*observedLease
+
+	// fenceCommandが成功したノードと、その時のReady conditionのlastTransitionTime
+	// 同じNotReadyの期間に何度も実行しないようにする
+	fenced    map[string]time.Time
+	fenceNode func(ctx context.Context, nodeName string) error
+}
+
+type observedLease struct {
+	renewTime  time.Time
+	observedAt time.Time
+}
+
+func NewNodeLifecycleAgent(client client.Interface, config *NodeLifecycleAgentConfig, logger *zap.Logger) *NodeLifecycleAgent {
+	a := &NodeLifecycleAgent{
+		client:   client,
+		config:   config,
+		logger:   logger,
+		now:      time.Now,
+		observed: map[string]*observedLease{},
+		fenced:   map[string]time.Time{},
+	}
+	a.fenceNode = a.runFenceCommand
+	return a
+}
+
+func (a *NodeLifecycleAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *NodeLifecycleAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *NodeLifecycleAgent) SetLeaderElector(elector *leaderelection.LeaderElector) {
+	a.elector = elector
+}
+
+func (a *NodeLifecycleAgent) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// leaderでない場合は処理しない
+			// leaderになった時に古い観測結果でNotReadyにしないように、観測し直す
+			if !a.elector.IsLeader() {
+				a.observed = map[string]*observedLease{}
+				a.health.Reconciled()
+				continue
+			}
+
+			if err := a.reconcile(ctx); err != nil {
+				a.logger.Error(
+					"reconcile",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
+			}
+
+			a.health.Reconciled()
+		}
+	}
+}
+
+func (a *NodeLifecycleAgent) reconcile(ctx context.Context) error {
+	nodes, err := a.client.SystemV0().Node().List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "list nodes")
+	}
+
+	nodeByID := map[string]*system.Node{}
+	for _, n := range nodes {
+		if err := a.monitorNode(ctx, n); err != nil {
+			a.logger.Error(
+				"monitor node",
+				zap.String("node", n.ID),
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+		}
+		nodeByID[n.ID] = n
+	}
+
+	// 削除されたノードの観測結果は使わない
+	for nodeName := range a.observed {
+		if _, ok := nodeByID[nodeName]; !ok {
+			delete(a.observed, nodeName)
+		}
+	}
+	for nodeName := range a.fenced {
+		if _, ok := nodeByID[nodeName]; !ok {
+			delete(a.fenced, nodeName)
+		}
+	}
+
+	grList, err := a.client.CoreV0().Group().List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "list groups")
+	}
+	for _, group := range grList {
+		nsList, err := a.client.CoreV0().Namespace().List(ctx, group.ID)
+		if err != nil {
+			return errors.Wrap(err, "list namespaces")
+		}
+		for _, ns := range nsList {
+			if err := a.syncNamespace(ctx, group.ID, ns.ID, nodeByID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// monitorNode はleaseがgracePeriodの間更新されていないノードをNotReadyにする
+// Readyに戻すのはノードのagentが行う
+func (a *NodeLifecycleAgent) monitorNode(ctx context.Context, n *system.Node) error {
+	lease, err := a.client.CoreV0().Lease().Get(ctx, node.LeaseName(n.ID))
+	if err != nil && !meta.IsNotFound(err) {
+		return err
+	}
+
+	// leaseが無い場合も、観測を始めてからgracePeriodの間は待つ
+	renewTime := time.Time{}
+	if lease != nil && lease.Spec.RenewTime != nil {
+		renewTime = *lease.Spec.RenewTime
+	}
+
+	now := a.now()
+	o, ok := a.observed[n.ID]
+	if !ok || !o.renewTime.Equal(renewTime) {
+		a.observed[n.ID] = &observedLease{
+			renewTime:  renewTime,
+			observedAt: now,
+		}
+		return nil
+	}
+	if now.Sub(o.observedAt) < a.config.GracePeriod || !isNodeReady(n) {
+		return nil
+	}
+
+	message := fmt.Sprintf("node agent stopped renewing lease for %s.", a.config.GracePeriod)
+	n.Status.State = system.NodeStateNotReady
+	meta.SetCondition(&n.Status.Conditions, meta.Condition{
+		Type:               system.NodeConditionReady,
+		Status:             meta.ConditionUnknown,
+		Reason:             reasonNodeStatusUnknown,
+		Message:            message,
+		LastTransitionTime: now,
+		ObservedGeneration: n.Generation,
+	})
+	if _, err := a.client.SystemV0().Node().Update(ctx, n); err != nil {
+		return errors.Wrap(err, "update node status")
+	}
+	a.recorder.Event(n.Meta, core.EventTypeWarning, reasonNodeNotReady, message)
+	return nil
+}
+
+func isNodeReady(n *system.Node) bool {
+	return n.Status.State == system.NodeStateReady &&
+		meta.IsConditionTrue(n.Status.Conditions, system.NodeConditionReady)
+}
+
+// nodeReadyCondition はノードの状態からNodeReady conditionを作る
+// Readyのノードでconditionがまだ無い場合はfalseを返し、conditionを追加しない
+func nodeReadyCondition(conditions []meta.Condition, conditionType meta.ConditionType, n *system.Node, generation int64) (meta.Condition, bool) {
+	if isNodeReady(n) {
+		if meta.FindCondition(conditions, conditionType) == nil {
+			return meta.Condition{}, false
+		}
+		return meta.Condition{
+			Type:               conditionType,
+			Status:             meta.ConditionTrue,
+			Reason:             reasonNodeReady,
+			ObservedGeneration: generation,
+		}, true
+	}
+	return meta.Condition{
+		Type:               conditionType,
+		Status:             meta.ConditionFalse,
+		Reason:             reasonNodeNotReady,
+		Message:            fmt.Sprintf("node `%s` is not ready.", n.ID),
+		ObservedGeneration: generation,
+	}, true
+}
+
+func (a *NodeLifecycleAgent) syncNamespace(ctx context.Context, groupID, namespaceID string, nodeByID map[string]*system.Node) error {
+	bsList, err := a.client.SystemV0().BlockStorage().List(ctx, groupID, namespaceID)
+	if err != nil {
+		return errors.Wrap(err, "list blockstorages")
+	}
+	bsByID := map[string]*system.BlockStorage{}
+	for _, bs := range bsList {
+		bsByID[bs.ID] = bs
+
+		n, ok := nodeByID[bs.Annotations[annotationBlockStorageNodeName]]
+		if !ok || bs.IsDeleting() {
+			continue
+		}
+		c, ok := nodeReadyCondition(bs.Status.Conditions, system.BlockStorageConditionNodeReady, n, bs.Generation)
+		if !ok || !meta.SetCondition(&bs.Status.Conditions, c) {
+			continue
+		}
+		if _, err := a.client.SystemV0().BlockStorage().Update(ctx, bs); err != nil {
+			return errors.Wrap(err, "update blockstorage status")
+		}
+	}
+
+	vmList, err := a.client.SystemV0().VirtualMachine().List(ctx, groupID, namespaceID)
+	if err != nil {
+		return errors.Wrap(err, "list virtualmachines")
+	}
+	for _, vm := range vmList {
+		n, ok := nodeByID[vm.Annotations[annotationVirtualMachineNodeName]]
+		if !ok || vm.IsDeleting() {
+			continue
+		}
+		if a.shouldRestart(vm, n, bsByID) && a.fence(ctx, n) {
+			if err := a.restart(ctx, vm, n); err != nil {
+				return err
+			}
+			continue
+		}
+
+		c, ok := nodeReadyCondition(vm.Status.Conditions, system.VirtualMachineConditionNodeReady, n, vm.Generation)
+		if !ok || !meta.SetCondition(&vm.Status.Conditions, c) {
+			continue
+		}
+		if _, err := a.client.SystemV0().VirtualMachine().Update(ctx, vm); err != nil {
+			return errors.Wrap(err, "update virtualmachine status")
+		}
+	}
+
+	vrList, err := a.client.SystemV0().VirtualRouter().List(ctx, groupID, namespaceID)
+	if err != nil {
+		return errors.Wrap(err, "list virtualrouters")
+	}
+	for _, vr := range vrList {
+		n, ok := nodeByID[vr.Annotations[annotationVirtualRouterNodeName]]
+		if !ok || vr.IsDeleting() {
+			continue
+		}
+		c, ok := nodeReadyCondition(vr.Status.Conditions, system.VirtualRouterConditionNodeReady, n, vr.Generation)
+		if !ok || !meta.SetCondition(&vr.Status.Conditions, c) {
+			continue
+		}
+		if _, err := a.client.SystemV0().VirtualRouter().Update(ctx, vr); err != nil {
+			return errors.Wrap(err, "update virtualrouter status")
+		}
+	}
+	return nil
+}
+
+// shouldRestart はNotReadyのノードで起動していたVMを別のノードで起動し直すかを返す
+// Localのblockstorageはノードと一緒に使えなくなるので、全てのblockstorageがCephのVMのみ対象にする
+func (a *NodeLifecycleAgent) shouldRestart(vm *system.VirtualMachine, n *system.Node, bsByID map[string]*system.BlockStorage) bool {
+	if !a.config.RestartCephVirtualMachines ||
+		vm.Spec.ActionState != system.VirtualMachineActionStatePowerOn ||
+		len(vm.Spec.BlockStorageIDs) == 0 ||
+		isNodeReady(n) {
+		return false
+	}
+
+	c := meta.FindCondition(n.Status.Conditions, system.NodeConditionReady)
+	if c == nil || a.now().Sub(c.LastTransitionTime) < a.config.RestartDelay {
+		return false
+	}
+
+	for _, id := range vm.Spec.BlockStorageIDs {
+		bs, ok := bsByID[id]
+		if !ok || bs.Annotations[annotationBlockStorageType] != blockStorageTypeCeph {
+			return false
+		}
+	}
+	return true
+}
+
+// fence はfenceCommandでNotReadyのノードが同じディスクに書き込めないようにし、成功した場合にtrueを返す
+// 失敗した場合はVMを起動し直さず、次の周期に実行し直す
+func (a *NodeLifecycleAgent) fence(ctx context.Context, n *system.Node) bool {
+	c := meta.FindCondition(n.Status.Conditions, system.NodeConditionReady)
+	if c == nil {
+		return false
+	}
+	if t, ok := a.fenced[n.ID]; ok && t.Equal(c.LastTransitionTime) {
+		return true
+	}
+
+	if err := a.fenceNode(ctx, n.ID); err != nil {
+		a.logger.Error(
+			"fence node",
+			zap.String("node", n.ID),
+			zap.String("msg", err.Error()),
+			zap.Time("time", time.Now()),
+		)
+		a.recorder.Eventf(n.Meta, core.EventTypeWarning, reasonFenceFailed, "fenceCommand failed: %s", err.Error())
+		return false
+	}
+	a.fenced[n.ID] = c.LastTransitionTime
+	a.recorder.Event(n.Meta, core.EventTypeNormal, reasonFenced, "fenceCommand succeeded.")
+	return true
+}
+
+func (a *NodeLifecycleAgent) runFenceCommand(ctx context.Context, nodeName string) error {
+	ctx, cancel := context.WithTimeout(ctx, a.config.FenceTimeout)
+	defer cancel()
+
+	args := append(append([]string{}, a.config.FenceCommand[1:]...), nodeName)
+	out, err := exec.CommandContext(ctx, a.config.FenceCommand[0], args...).CombinedOutput()
+	if err != nil && len(out) != 0 {
+		return errors.Wrap(err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+// restart はVMのノードの割り当てを外し、schedulerが別のノードに割り当てるようにする
+// 元のノードが復帰した場合に同じCephのディスクを使うqemuが残らないように、
+// previous_node_nameに元のノードを追加して元のノードのagentにプロセスを停止させる
+// 移した先のノードも停止した場合に備えて、停止を確認していない全てのノードをカンマ区切りで残す
+func (a *NodeLifecycleAgent) restart(ctx context.Context, vm *system.VirtualMachine, n *system.Node) error {
+	delete(vm.Annotations, annotationVirtualMachineNodeName)
+	vm.Annotations[annotationVirtualMachinePreviousNodeName] = appendNodeName(vm.Annotations[annotationVirtualMachinePreviousNodeName], n.ID)
+	vm.Status.State = system.VirtualMachineStatePending
+	meta.RemoveCondition(&vm.Status.Conditions, system.VirtualMachineConditionNodeReady)
+	meta.SetCondition(&vm.Status.Conditions, meta.Condition{
+		Type:               system.VirtualMachineConditionBooted,
+		Status:             meta.ConditionFalse,
+		Reason:             reasonNodeNotReady,
+		Message:            fmt.Sprintf("node `%s` is not ready.", n.ID),
+		ObservedGeneration: vm.Generation,
+	})
+
+	if _, err := a.client.SystemV0().VirtualMachine().Update(ctx, vm); err != nil {
+		return errors.Wrap(err, "reschedule virtualmachine")
+	}
+	a.recorder.Eventf(vm.Meta, core.EventTypeWarning, reasonRescheduled, "restarting on another node because node `%s` is not ready.", n.ID)
+	return nil
+}
+
+// appendNodeName はカンマ区切りのノード名の一覧にnameを追加する。既に含まれている場合はそのまま返す
+func appendNodeName(names, name string) string {
+	if names == "" {
+		return name
+	}
+	for _, n := range strings.Split(names, ",") {
+		if n == name {
+			return names
+		}
+	}
+	return names + "," + name
+}
@@ -0,0 +1,329 @@
+package nodelifecycle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ophum/humstack/pkg/agents/system/node"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/fake"
+	"go.uber.org/zap"
+)
+
+func newNode(id string) *system.Node {
+	return &system.Node{
+		Meta: meta.Meta{ID: id, Name: id},
+		Status: system.NodeStatus{
+			State: system.NodeStateReady,
+			Conditions: []meta.Condition{
+				{Type: system.NodeConditionReady, Status: meta.ConditionTrue},
+			},
+		},
+	}
+}
+
+func newLease(nodeName string, renewTime time.Time) *core.Lease {
+	return &core.Lease{
+		Meta: meta.Meta{ID: node.LeaseName(nodeName), Name: node.LeaseName(nodeName)},
+		Spec: core.LeaseSpec{
+			HolderIdentity:       nodeName,
+			LeaseDurationSeconds: 40,
+			RenewTime:            &renewTime,
+		},
+	}
+}
+
+func newVM(id, nodeName string, bsIDs ...string) *system.VirtualMachine {
+	return &system.VirtualMachine{
+		Meta: meta.Meta{
+			ID:        id,
+			Name:      id,
+			Group:     "group1",
+			Namespace: "ns1",
+			Annotations: map[string]string{
+				annotationVirtualMachineNodeName: nodeName,
+			},
+		},
+		Spec: system.VirtualMachineSpec{
+			BlockStorageIDs: bsIDs,
+			ActionState:     system.VirtualMachineActionStatePowerOn,
+		},
+	}
+}
+
+func newBlockStorage(id, nodeName, bsType string) *system.BlockStorage {
+	return &system.BlockStorage{
+		Meta: meta.Meta{
+			ID:        id,
+			Name:      id,
+			Group:     "group1",
+			Namespace: "ns1",
+			Annotations: map[string]string{
+				annotationBlockStorageNodeName: nodeName,
+				annotationBlockStorageType:     bsType,
+			},
+		},
+	}
+}
+
+func newClients(objects ...interface{}) *fake.Clients {
+	return fake.NewClients(append([]interface{}{
+		&core.Group{Meta: meta.Meta{ID: "group1"}},
+		&core.Namespace{Meta: meta.Meta{ID: "ns1", Group: "group1"}},
+	}, objects...)...)
+}
+
+// newAgent はnowを進められるagentを返す
+func newAgent(clients *fake.Clients, config *NodeLifecycleAgentConfig, now *time.Time) *NodeLifecycleAgent {
+	if err := config.Validate(); err != nil {
+		panic(err)
+	}
+	a := NewNodeLifecycleAgent(clients, config, zap.NewNop())
+	a.now = func() time.Time { return *now }
+	return a
+}
+
+func reconcile(t *testing.T, a *NodeLifecycleAgent) {
+	t.Helper()
+
+	if err := a.reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getNode(t *testing.T, clients *fake.Clients, id string) *system.Node {
+	t.Helper()
+
+	n, err := clients.SystemV0().Node().Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func getVM(t *testing.T, clients *fake.Clients, id string) *system.VirtualMachine {
+	t.Helper()
+
+	vm, err := clients.SystemV0().VirtualMachine().Get(context.Background(), "group1", "ns1", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+func TestNodeNotReady(t *testing.T) {
+	now := time.Now()
+	clients := newClients(
+		newNode("node1"),
+		newNode("node2"),
+		newLease("node1", now),
+		newLease("node2", now),
+		newVM("vm1", "node1"),
+		newVM("vm2", "node2"),
+		newBlockStorage("bs1", "node1", "Local"),
+		&system.VirtualRouter{Meta: meta.Meta{
+			ID:          "vr1",
+			Group:       "group1",
+			Namespace:   "ns1",
+			Annotations: map[string]string{annotationVirtualRouterNodeName: "node1"},
+		}},
+	)
+	a := newAgent(clients, &NodeLifecycleAgentConfig{}, &now)
+	reconcile(t, a)
+
+	// node2だけleaseを更新し続ける
+	renew := func(nodeName string) {
+		lease, err := clients.CoreV0().Lease().Get(context.Background(), node.LeaseName(nodeName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		renewTime := now
+		lease.Spec.RenewTime = &renewTime
+		if _, err := clients.CoreV0().Lease().Update(context.Background(), lease); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now = now.Add(time.Second * 30)
+	renew("node2")
+	reconcile(t, a)
+	if n := getNode(t, clients, "node1"); n.Status.State != system.NodeStateReady {
+		t.Fatalf("node1 must be ready within gracePeriod: %+v", n.Status)
+	}
+
+	now = now.Add(time.Second * 15)
+	renew("node2")
+	reconcile(t, a)
+
+	n := getNode(t, clients, "node1")
+	c := meta.FindCondition(n.Status.Conditions, system.NodeConditionReady)
+	if n.Status.State != system.NodeStateNotReady || c == nil || c.Status != meta.ConditionUnknown || c.Reason != reasonNodeStatusUnknown {
+		t.Fatalf("unexpected node1 status: %+v", n.Status)
+	}
+	if n := getNode(t, clients, "node2"); n.Status.State != system.NodeStateReady {
+		t.Fatalf("unexpected node2 status: %+v", n.Status)
+	}
+
+	// NotReadyのノードのリソースにconditionを付ける
+	c = meta.FindCondition(getVM(t, clients, "vm1").Status.Conditions, system.VirtualMachineConditionNodeReady)
+	if c == nil || c.Status != meta.ConditionFalse || c.Message != "node `node1` is not ready." {
+		t.Fatalf("unexpected vm1 condition: %+v", c)
+	}
+	if conditions := getVM(t, clients, "vm2").Status.Conditions; len(conditions) != 0 {
+		t.Fatalf("unexpected vm2 conditions: %+v", conditions)
+	}
+	bs, err := clients.SystemV0().BlockStorage().Get(context.Background(), "group1", "ns1", "bs1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := meta.FindCondition(bs.Status.Conditions, system.BlockStorageConditionNodeReady); c == nil || c.Status != meta.ConditionFalse {
+		t.Fatalf("unexpected bs1 condition: %+v", c)
+	}
+	vr, err := clients.SystemV0().VirtualRouter().Get(context.Background(), "group1", "ns1", "vr1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := meta.FindCondition(vr.Status.Conditions, system.VirtualRouterConditionNodeReady); c == nil || c.Status != meta.ConditionFalse {
+		t.Fatalf("unexpected vr1 condition: %+v", c)
+	}
+
+	// ノードのagentが復帰してReadyに戻すとconditionもTrueにする
+	now = now.Add(time.Second * 5)
+	renew("node1")
+	n.Status.State = system.NodeStateReady
+	meta.SetCondition(&n.Status.Conditions, meta.Condition{Type: system.NodeConditionReady, Status: meta.ConditionTrue})
+	if _, err := clients.SystemV0().Node().Update(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	reconcile(t, a)
+	if !meta.IsConditionTrue(getVM(t, clients, "vm1").Status.Conditions, system.VirtualMachineConditionNodeReady) {
+		t.Fatalf("unexpected vm1 conditions: %+v", getVM(t, clients, "vm1").Status.Conditions)
+	}
+}
+
+func TestRestartCephVirtualMachines(t *testing.T) {
+	now := time.Now()
+	notReady := newNode("node1")
+	notReady.Status.State = system.NodeStateNotReady
+	notReady.Status.Conditions = []meta.Condition{{
+		Type:               system.NodeConditionReady,
+		Status:             meta.ConditionUnknown,
+		LastTransitionTime: now,
+	}}
+
+	clients := newClients(
+		notReady,
+		newVM("ceph", "node1", "ceph1", "ceph2"),
+		newVM("local", "node1", "ceph3", "local1"),
+		newBlockStorage("ceph1", "node1", blockStorageTypeCeph),
+		newBlockStorage("ceph2", "node1", blockStorageTypeCeph),
+		newBlockStorage("ceph3", "node1", blockStorageTypeCeph),
+		newBlockStorage("local1", "node1", "Local"),
+	)
+	a := newAgent(clients, &NodeLifecycleAgentConfig{RestartCephVirtualMachines: true, FenceCommand: []string{"fence"}}, &now)
+	fenced := []string{}
+	a.fenceNode = func(_ context.Context, nodeName string) error {
+		fenced = append(fenced, nodeName)
+		return nil
+	}
+
+	// restartDelayの間は待つ
+	now = now.Add(time.Minute)
+	reconcile(t, a)
+	if got := getVM(t, clients, "ceph").Annotations[annotationVirtualMachineNodeName]; got != "node1" {
+		t.Fatalf("virtualmachine must not be restarted within restartDelay: %s", got)
+	}
+
+	now = now.Add(DefaultRestartDelay)
+	reconcile(t, a)
+
+	vm := getVM(t, clients, "ceph")
+	if vm.Annotations[annotationVirtualMachineNodeName] != "" ||
+		vm.Annotations[annotationVirtualMachinePreviousNodeName] != "node1" ||
+		vm.Status.State != system.VirtualMachineStatePending {
+		t.Fatalf("unexpected virtualmachine: %+v", vm)
+	}
+
+	// 同じNotReadyの期間では一度だけフェンシングする
+	if len(fenced) != 1 || fenced[0] != "node1" {
+		t.Fatalf("unexpected fenced nodes: %v", fenced)
+	}
+
+	// Localのblockstorageを使っているVMは動かせない
+	vm = getVM(t, clients, "local")
+	if vm.Annotations[annotationVirtualMachineNodeName] != "node1" {
+		t.Fatalf("unexpected virtualmachine: %+v", vm)
+	}
+	if c := meta.FindCondition(vm.Status.Conditions, system.VirtualMachineConditionNodeReady); c == nil || c.Status != meta.ConditionFalse {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+}
+
+func TestRestartKeepsUnfencedNodes(t *testing.T) {
+	now := time.Now()
+	notReady := newNode("node2")
+	notReady.Status.State = system.NodeStateNotReady
+	notReady.Status.Conditions = []meta.Condition{{
+		Type:               system.NodeConditionReady,
+		Status:             meta.ConditionUnknown,
+		LastTransitionTime: now,
+	}}
+
+	// node1から移したVMがnode2でも止まった。node1はまだ停止を確認していない
+	vm := newVM("ceph", "node2", "ceph1")
+	vm.Annotations[annotationVirtualMachinePreviousNodeName] = "node1"
+	clients := newClients(
+		notReady,
+		vm,
+		newBlockStorage("ceph1", "node2", blockStorageTypeCeph),
+	)
+	a := newAgent(clients, &NodeLifecycleAgentConfig{RestartCephVirtualMachines: true, FenceCommand: []string{"fence"}}, &now)
+	a.fenceNode = func(context.Context, string) error { return nil }
+
+	now = now.Add(DefaultRestartDelay + time.Minute)
+	reconcile(t, a)
+
+	vm = getVM(t, clients, "ceph")
+	if vm.Annotations[annotationVirtualMachineNodeName] != "" ||
+		vm.Annotations[annotationVirtualMachinePreviousNodeName] != "node1,node2" {
+		t.Fatalf("unexpected annotations: %v", vm.Annotations)
+	}
+}
+
+func TestRestartRequiresFencing(t *testing.T) {
+	config := &NodeLifecycleAgentConfig{RestartCephVirtualMachines: true}
+	if err := config.Validate(); err == nil {
+		t.Fatal("restartCephVirtualMachines without fenceCommand must be rejected")
+	}
+
+	now := time.Now()
+	notReady := newNode("node1")
+	notReady.Status.State = system.NodeStateNotReady
+	notReady.Status.Conditions = []meta.Condition{{
+		Type:               system.NodeConditionReady,
+		Status:             meta.ConditionUnknown,
+		LastTransitionTime: now,
+	}}
+	clients := newClients(
+		notReady,
+		newVM("ceph", "node1", "ceph1"),
+		newBlockStorage("ceph1", "node1", blockStorageTypeCeph),
+	)
+	a := newAgent(clients, &NodeLifecycleAgentConfig{RestartCephVirtualMachines: true, FenceCommand: []string{"false"}}, &now)
+
+	// フェンシングに失敗した場合は起動し直さない
+	now = now.Add(DefaultRestartDelay + time.Minute)
+	reconcile(t, a)
+	if got := getVM(t, clients, "ceph").Annotations[annotationVirtualMachineNodeName]; got != "node1" {
+		t.Fatalf("virtualmachine must not be restarted without fencing: %s", got)
+	}
+
+	a.config.FenceCommand = []string{"true"}
+	reconcile(t, a)
+	if got := getVM(t, clients, "ceph").Annotations[annotationVirtualMachineNodeName]; got != "" {
+		t.Fatalf("virtualmachine is not restarted: %s", got)
+	}
+}
@@ -0,0 +1,57 @@
+package nodelifecycle
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultGracePeriod  = time.Second * 40
+	DefaultRestartDelay = time.Minute * 5
+	DefaultFenceTimeout = time.Minute
+)
+
+type NodeLifecycleAgentConfig struct {
+	// ノードのleaseがこの時間更新されなければNotReadyにする(省略時は40s)
+	GracePeriod time.Duration `yaml:"gracePeriod"`
+
+	// trueの場合、NotReadyのノードで動いていた全てのblockstorageがCephのVMを別のノードで起動し直す
+	// ノードが本当に停止しているか確認できないため、有効にする場合はfenceCommandが必要
+	RestartCephVirtualMachines bool `yaml:"restartCephVirtualMachines"`
+	// NotReadyになってからVMを起動し直すまでの時間(省略時は5m)
+	RestartDelay time.Duration `yaml:"restartDelay"`
+
+	// VMを起動し直す前に、最後の引数にNotReadyのノード名を付けて実行するコマンド
+	// IPMIで電源を落とす、Cephのclientをblocklistに追加するなどで、元のノードが同じディスクに書き込めないようにすること
+	// 0以外で終了した場合はVMを起動し直さず、次の周期に実行し直す
+	FenceCommand []string `yaml:"fenceCommand"`
+	// fenceCommandのタイムアウト(省略時は1m)
+	FenceTimeout time.Duration `yaml:"fenceTimeout"`
+}
+
+// Validate は省略された値を埋め、不正な値の場合はエラーを返す
+func (c *NodeLifecycleAgentConfig) Validate() error {
+	if c.GracePeriod == 0 {
+		c.GracePeriod = DefaultGracePeriod
+	}
+	if c.RestartDelay == 0 {
+		c.RestartDelay = DefaultRestartDelay
+	}
+	if c.FenceTimeout == 0 {
+		c.FenceTimeout = DefaultFenceTimeout
+	}
+	if c.GracePeriod < 0 {
+		return fmt.Errorf("gracePeriod must be positive")
+	}
+	if c.RestartDelay < 0 {
+		return fmt.Errorf("restartDelay must be positive")
+	}
+	if c.FenceTimeout < 0 {
+		return fmt.Errorf("fenceTimeout must be positive")
+	}
+	// 分断されたノードのqemuが書き込み続けている状態で起動し直さないようにする
+	if c.RestartCephVirtualMachines && len(c.FenceCommand) == 0 {
+		return fmt.Errorf("fenceCommand is required when restartCephVirtualMachines is enabled")
+	}
+	return nil
+}
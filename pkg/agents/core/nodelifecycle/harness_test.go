@@ -0,0 +1,63 @@
+package nodelifecycle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	humtesting "github.com/ophum/humstack/pkg/testing"
+	"go.uber.org/zap"
+)
+
+// mTLSの場合、ノードの証明書では他のノードをNotReadyにできず、coreの証明書ではできる
+func TestNodeNotReadyWithTLS(t *testing.T) {
+	h := humtesting.Start(t, &humtesting.Options{
+		NodeNames:         []string{"node1", "node2"},
+		DisableCoreAgents: true,
+		TLS:               true,
+	})
+	ctx := context.Background()
+
+	// fakeのsystem agentはleaseを更新しないので、gracePeriodが過ぎるとNotReadyになる
+	now := time.Now()
+	config := &NodeLifecycleAgentConfig{GracePeriod: time.Minute}
+	notReady := func(commonName string) bool {
+		if err := config.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		a := NewNodeLifecycleAgent(h.NewClients(commonName), config, zap.NewNop())
+		a.now = func() time.Time { return now }
+
+		if err := a.reconcile(ctx); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(config.GracePeriod)
+		if err := a.reconcile(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := h.Clients.SystemV0().Node().Get(ctx, "node2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n.Status.State == system.NodeStateNotReady
+	}
+
+	if notReady(auth.NodeCommonName("node1")) {
+		t.Fatal("node certificate must not update another node")
+	}
+	n, err := h.NewClients(auth.NodeCommonName("node1")).SystemV0().Node().Get(ctx, "node2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.NewClients(auth.NodeCommonName("node1")).SystemV0().Node().Update(ctx, n); !meta.IsForbidden(err) {
+		t.Fatalf("expected Forbidden, but got %v", err)
+	}
+
+	if !notReady(auth.CoreCommonName("node1")) {
+		t.Fatal("core certificate must update another node")
+	}
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+// matchesTerm はcandidateがownerのaffinityTermの対象になるかどうかを返す
+// owner自身は対象にしない
+func matchesTerm(term *system.VirtualMachineAffinityTerm, owner, candidate *system.VirtualMachine) bool {
+	if owner.Group == candidate.Group && owner.Namespace == candidate.Namespace && owner.ID == candidate.ID {
+		return false
+	}
+
+	switch term.Scope {
+	case system.VirtualMachineAffinityScopeGroup:
+		if owner.Group != candidate.Group {
+			return false
+		}
+	default:
+		if owner.Group != candidate.Group || owner.Namespace != candidate.Namespace {
+			return false
+		}
+	}
+	return term.LabelSelector.Matches(candidate.Labels)
+}
+
+func matchesAny(term *system.VirtualMachineAffinityTerm, owner *system.VirtualMachine, vms []*system.VirtualMachine) bool {
+	for _, vm := range vms {
+		if matchesTerm(term, owner, vm) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSelfAffinity はrequiredのaffinityに一致するVMがまだどこにも配置されておらず、
+// VM自身がtermに一致する場合にtrueを返す
+// 同じlabelのVMをまとめて配置する場合、最初のVMはどのノードにも配置できなくなってしまうため
+func isSelfAffinity(term *system.VirtualMachineAffinityTerm, vm *system.VirtualMachine, infos []*nodeInfo) bool {
+	for _, info := range infos {
+		if matchesAny(term, vm, info.vms) {
+			return false
+		}
+	}
+	return term.LabelSelector.Matches(vm.Labels)
+}
+
+func filterNodeSelector(req *request, n *nodeInfo) string {
+	selector := meta.LabelSelector{MatchLabels: req.vm.Spec.NodeSelector}
+	if !selector.Matches(n.node.Labels) {
+		return "node(s) didn't match node selector"
+	}
+	return ""
+}
+
+func filterAffinity(req *request, n *nodeInfo) string {
+	for i := range req.vm.Spec.Affinity.Required {
+		if req.selfAffinity[i] {
+			continue
+		}
+		if !matchesAny(&req.vm.Spec.Affinity.Required[i], req.vm, n.vms) {
+			return "node(s) didn't match virtualmachine affinity"
+		}
+	}
+	return ""
+}
+
+// filterAntiAffinity はVMのantiAffinityに加えて、
+// ノードに配置済みのVMのantiAffinityにVMが一致する場合も除く
+func filterAntiAffinity(req *request, n *nodeInfo) string {
+	for i := range req.vm.Spec.AntiAffinity.Required {
+		if matchesAny(&req.vm.Spec.AntiAffinity.Required[i], req.vm, n.vms) {
+			return "node(s) didn't match virtualmachine anti-affinity"
+		}
+	}
+
+	for _, vm := range n.vms {
+		for i := range vm.Spec.AntiAffinity.Required {
+			if matchesTerm(&vm.Spec.AntiAffinity.Required[i], vm, req.vm) {
+				return "node(s) didn't match existing virtualmachines anti-affinity"
+			}
+		}
+	}
+	return ""
+}
+
+// affinityScore はpreferredのaffinity, antiAffinityを満たす重みの割合を-1から1で返す
+func affinityScore(req *request, n *nodeInfo) float64 {
+	var total, sum int32
+	for i := range req.vm.Spec.Affinity.Preferred {
+		term := &req.vm.Spec.Affinity.Preferred[i]
+		total += term.Weight
+		if matchesAny(&term.VirtualMachineAffinityTerm, req.vm, n.vms) {
+			sum += term.Weight
+		}
+	}
+	for i := range req.vm.Spec.AntiAffinity.Preferred {
+		term := &req.vm.Spec.AntiAffinity.Preferred[i]
+		total += term.Weight
+		if matchesAny(&term.VirtualMachineAffinityTerm, req.vm, n.vms) {
+			sum -= term.Weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(sum) / float64(total)
+}
@@ -0,0 +1,432 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/agents/leaderelection"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// virtualmachine, blockstorage agentと同じannotation
+const (
+	annotationVirtualMachineNodeName = "virtualmachinev0/node_name"
+	annotationVirtualMachineArch     = "virtualmachinev0/arch"
+	annotationBlockStorageNodeName   = "blockstoragev0/node_name"
+	annotationBlockStorageType       = "blockstoragev0/type"
+
+	blockStorageTypeLocal = "Local"
+)
+
+const (
+	reasonScheduled            = "Scheduled"
+	reasonUnschedulable        = "Unschedulable"
+	reasonInvalidRequest       = "InvalidRequest"
+	reasonLocalStorageConflict = "LocalStorageConflict"
+)
+
+// SchedulerAgent はノードが割り当てられていないVMをReadyのノードに割り当てる
+// 割り当てたノードはvirtualmachinev0/node_nameに書き込み、そのノードのagentがVMを起動する
+type SchedulerAgent struct {
+	client   client.Interface
+	config   *SchedulerAgentConfig
+	logger   *zap.Logger
+	health   *health.Reporter
+	recorder *event.Recorder
+	elector  *leaderelection.LeaderElector
+}
+
+func NewSchedulerAgent(client client.Interface, config *SchedulerAgentConfig, logger *zap.Logger) *SchedulerAgent {
+	return &SchedulerAgent{
+		client: client,
+		config: config,
+		logger: logger,
+	}
+}
+
+func (a *SchedulerAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *SchedulerAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *SchedulerAgent) SetLeaderElector(elector *leaderelection.LeaderElector) {
+	a.elector = elector
+}
+
+func (a *SchedulerAgent) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// leaderでない場合は処理しない
+			if !a.elector.IsLeader() {
+				a.health.Reconciled()
+				continue
+			}
+
+			if err := a.schedule(ctx); err != nil {
+				a.logger.Error(
+					"schedule",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
+			}
+
+			a.health.Reconciled()
+		}
+	}
+}
+
+// objects はスケジューリングに使う全てのリソース
+type objects struct {
+	vms    []*system.VirtualMachine
+	bsList []*system.BlockStorage
+
+	// group/namespace/idをキーにしたblockstorage
+	bsByKey map[string]*system.BlockStorage
+}
+
+func blockStorageKey(groupID, namespaceID, id string) string {
+	return groupID + "/" + namespaceID + "/" + id
+}
+
+func isLocalBlockStorage(bs *system.BlockStorage) bool {
+	return bs.Annotations[annotationBlockStorageType] == blockStorageTypeLocal
+}
+
+// schedule はノードが割り当てられていない全てのVMを割り当てる
+func (a *SchedulerAgent) schedule(ctx context.Context) error {
+	nodes, err := a.client.SystemV0().Node().List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "list nodes")
+	}
+	objs, err := a.listObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	infos := a.newNodeInfos(nodes, objs)
+	for _, vm := range objs.vms {
+		if vm.IsDeleting() {
+			continue
+		}
+
+		var err error
+		if nodeName := vm.Annotations[annotationVirtualMachineNodeName]; nodeName != "" {
+			// 手動で割り当てられたVMのblockstorageもVMと同じノードに配置する
+			err = a.placeBlockStorages(ctx, vm, nodeName, objs, infos)
+		} else if vm.Spec.ActionState == system.VirtualMachineActionStatePowerOn {
+			// 停止しているVMはノードのリソースとして数えないので、起動するまで割り当てない
+			err = a.scheduleOne(ctx, vm, objs, infos)
+		}
+		if err != nil {
+			a.logger.Error(
+				"schedule virtualmachine",
+				zap.String("group", vm.Group),
+				zap.String("namespace", vm.Namespace),
+				zap.String("id", vm.ID),
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+		}
+	}
+	return nil
+}
+
+func (a *SchedulerAgent) listObjects(ctx context.Context) (*objects, error) {
+	grList, err := a.client.CoreV0().Group().List(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "list groups")
+	}
+
+	objs := &objects{
+		vms:     []*system.VirtualMachine{},
+		bsList:  []*system.BlockStorage{},
+		bsByKey: map[string]*system.BlockStorage{},
+	}
+	for _, group := range grList {
+		nsList, err := a.client.CoreV0().Namespace().List(ctx, group.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "list namespaces")
+		}
+
+		for _, ns := range nsList {
+			vmList, err := a.client.SystemV0().VirtualMachine().List(ctx, group.ID, ns.ID)
+			if err != nil {
+				return nil, errors.Wrap(err, "list virtualmachines")
+			}
+			objs.vms = append(objs.vms, vmList...)
+
+			bsList, err := a.client.SystemV0().BlockStorage().List(ctx, group.ID, ns.ID)
+			if err != nil {
+				return nil, errors.Wrap(err, "list blockstorages")
+			}
+			for _, bs := range bsList {
+				objs.bsList = append(objs.bsList, bs)
+				objs.bsByKey[blockStorageKey(group.ID, ns.ID, bs.ID)] = bs
+			}
+		}
+	}
+	return objs, nil
+}
+
+// newNodeInfos はノードごとに割り当て済みのVMとLocalのblockstorageのrequestを合計する
+// 数え方はnode agentのRequestedVcpus, RequestedMemory, RequestedDiskと同じにする
+func (a *SchedulerAgent) newNodeInfos(nodes []*system.Node, objs *objects) []*nodeInfo {
+	infos := []*nodeInfo{}
+	byID := map[string]*nodeInfo{}
+	for _, n := range nodes {
+		info, err := newNodeInfo(n)
+		if err != nil {
+			a.logger.Error(
+				"parse node limits",
+				zap.String("node", n.ID),
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+			continue
+		}
+		infos = append(infos, info)
+		byID[n.ID] = info
+	}
+
+	for _, vm := range objs.vms {
+		info, ok := byID[vm.Annotations[annotationVirtualMachineNodeName]]
+		if !ok {
+			continue
+		}
+		// 削除中のVMはaffinityの対象にしない
+		if !vm.IsDeleting() {
+			info.vms = append(info.vms, vm)
+		}
+		if vm.Spec.ActionState == system.VirtualMachineActionStatePowerOff {
+			continue
+		}
+		requests, err := getVirtualMachineRequests(vm)
+		if err != nil {
+			continue
+		}
+		info.requested = info.requested.add(requests)
+	}
+
+	for _, bs := range objs.bsList {
+		info, ok := byID[bs.Annotations[annotationBlockStorageNodeName]]
+		if !ok || !isLocalBlockStorage(bs) {
+			continue
+		}
+		requests, err := getBlockStorageRequests(bs)
+		if err != nil {
+			continue
+		}
+		info.requested = info.requested.add(requests)
+	}
+
+	// 点数が同じ場合に毎回同じノードを選ぶようにする
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].node.ID < infos[j].node.ID
+	})
+	return infos
+}
+
+// getRequest はVMと、VMが使うLocalのblockstorageから配置に必要なものを求める
+func getRequest(vm *system.VirtualMachine, objs *objects) (*request, error) {
+	requests, err := getVirtualMachineRequests(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &request{
+		vm:         vm,
+		resources:  requests,
+		localNodes: map[string][]string{},
+	}
+	for _, id := range vm.Spec.BlockStorageIDs {
+		// 存在しないblockstorageはvirtualmachine agentがエラーにする
+		bs, ok := objs.bsByKey[blockStorageKey(vm.Group, vm.Namespace, id)]
+		if !ok || !isLocalBlockStorage(bs) || bs.IsDeleting() {
+			continue
+		}
+
+		if nodeName := bs.Annotations[annotationBlockStorageNodeName]; nodeName != "" {
+			req.localNodes[nodeName] = append(req.localNodes[nodeName], bs.ID)
+			continue
+		}
+
+		requests, err := getBlockStorageRequests(bs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "blockstorage `%s`", bs.ID)
+		}
+		req.resources = req.resources.add(requests)
+		req.unplaced = append(req.unplaced, bs)
+	}
+	return req, nil
+}
+
+// localStorageConflictMessage はLocalのblockstorageがどのノードに分かれているかを返す
+// e.g. local blockstorages are placed on different nodes: bs1 on node1, bs2 on node2.
+func localStorageConflictMessage(localNodes map[string][]string) string {
+	list := []string{}
+	for nodeName, ids := range localNodes {
+		for _, id := range ids {
+			list = append(list, fmt.Sprintf("%s on %s", id, nodeName))
+		}
+	}
+	sort.Strings(list)
+	return fmt.Sprintf("local blockstorages are placed on different nodes: %s.", strings.Join(list, ", "))
+}
+
+func (a *SchedulerAgent) scheduleOne(ctx context.Context, vm *system.VirtualMachine, objs *objects, infos []*nodeInfo) error {
+	req, err := getRequest(vm, objs)
+	if err != nil {
+		return a.setUnschedulable(ctx, vm, reasonInvalidRequest, err.Error())
+	}
+	// 配置済みのLocalのblockstorageが複数のノードにある場合はどこに配置しても起動できない
+	if len(req.localNodes) > 1 {
+		return a.setUnschedulable(ctx, vm, reasonLocalStorageConflict, localStorageConflictMessage(req.localNodes))
+	}
+	for i := range vm.Spec.Affinity.Required {
+		req.selfAffinity = append(req.selfAffinity, isSelfAffinity(&vm.Spec.Affinity.Required[i], vm, infos))
+	}
+
+	var best *nodeInfo
+	var bestScore float64
+	reasons := map[string]int{}
+	for _, info := range infos {
+		if reason := runFilters(req, info); reason != "" {
+			reasons[reason]++
+			continue
+		}
+
+		score := a.config.ScoringStrategy.score(req, info) + affinityScore(req, info)
+		if best == nil || score > bestScore {
+			best, bestScore = info, score
+		}
+	}
+
+	if best == nil {
+		return a.setUnschedulable(ctx, vm, reasonUnschedulable, unschedulableMessage(len(infos), reasons))
+	}
+	return a.bind(ctx, req, best)
+}
+
+func runFilters(req *request, info *nodeInfo) string {
+	for _, f := range filters {
+		if reason := f(req, info); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// bind はVMとLocalのblockstorageをノードに割り当てる
+// blockstorageを先に割り当て、途中で失敗した場合も次の周期で同じノードに割り当てられるようにする
+func (a *SchedulerAgent) bind(ctx context.Context, req *request, info *nodeInfo) error {
+	for _, bs := range req.unplaced {
+		if err := a.bindBlockStorage(ctx, bs, req.vm, info.node.ID); err != nil {
+			return err
+		}
+	}
+
+	vm := req.vm
+	if vm.Annotations == nil {
+		vm.Annotations = map[string]string{}
+	}
+	vm.Annotations[annotationVirtualMachineNodeName] = info.node.ID
+
+	message := fmt.Sprintf("assigned to node `%s`.", info.node.ID)
+	meta.SetCondition(&vm.Status.Conditions, meta.Condition{
+		Type:               system.VirtualMachineConditionScheduled,
+		Status:             meta.ConditionTrue,
+		Reason:             reasonScheduled,
+		Message:            message,
+		ObservedGeneration: vm.Generation,
+	})
+
+	if _, err := a.client.SystemV0().VirtualMachine().Update(ctx, vm); err != nil {
+		return errors.Wrap(err, "bind virtualmachine")
+	}
+
+	// 同じ周期で割り当てるVMが割り当て済みのrequestを考慮できるようにする
+	info.requested = info.requested.add(req.resources)
+	info.vms = append(info.vms, vm)
+	a.recorder.Event(vm.Meta, core.EventTypeNormal, reasonScheduled, message)
+	return nil
+}
+
+func (a *SchedulerAgent) bindBlockStorage(ctx context.Context, bs *system.BlockStorage, vm *system.VirtualMachine, nodeName string) error {
+	if bs.Annotations == nil {
+		bs.Annotations = map[string]string{}
+	}
+	bs.Annotations[annotationBlockStorageNodeName] = nodeName
+
+	if _, err := a.client.SystemV0().BlockStorage().Update(ctx, bs); err != nil {
+		return errors.Wrapf(err, "bind blockstorage `%s`", bs.ID)
+	}
+	a.recorder.Eventf(bs.Meta, core.EventTypeNormal, reasonScheduled, "assigned to node `%s` with virtualmachine `%s`.", nodeName, vm.ID)
+	return nil
+}
+
+// placeBlockStorages はノードが決まっているVMのLocalのblockstorageをそのノードに配置する
+// VMのノードは変えられないので、容量が足りなくても配置する
+func (a *SchedulerAgent) placeBlockStorages(ctx context.Context, vm *system.VirtualMachine, nodeName string, objs *objects, infos []*nodeInfo) error {
+	req, err := getRequest(vm, objs)
+	if err != nil {
+		return err
+	}
+
+	for _, bs := range req.unplaced {
+		if err := a.bindBlockStorage(ctx, bs, vm, nodeName); err != nil {
+			return err
+		}
+		requests, _ := getBlockStorageRequests(bs)
+		for _, info := range infos {
+			if info.node.ID == nodeName {
+				info.requested = info.requested.add(requests)
+			}
+		}
+	}
+	return nil
+}
+
+// setUnschedulable はvmをPendingのままにし、割り当てられない理由をconditionに残す
+// 理由が変わった場合のみ更新し、イベントを記録する
+func (a *SchedulerAgent) setUnschedulable(ctx context.Context, vm *system.VirtualMachine, reason, message string) error {
+	changed := meta.SetCondition(&vm.Status.Conditions, meta.Condition{
+		Type:               system.VirtualMachineConditionScheduled,
+		Status:             meta.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: vm.Generation,
+	})
+	if vm.Status.State != system.VirtualMachineStatePending {
+		vm.Status.State = system.VirtualMachineStatePending
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	if _, err := a.client.SystemV0().VirtualMachine().Update(ctx, vm); err != nil {
+		return errors.Wrap(err, "update virtualmachine status")
+	}
+	a.recorder.Event(vm.Meta, core.EventTypeWarning, "FailedScheduling", message)
+	return nil
+}
@@ -0,0 +1,475 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/agents/system/node"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/fake"
+	"go.uber.org/zap"
+)
+
+func newNode(id, vcpus, memory string) *system.Node {
+	return &system.Node{
+		Meta: meta.Meta{ID: id, Name: id},
+		Spec: system.NodeSpec{
+			LimitVcpus:  vcpus,
+			LimitMemory: memory,
+		},
+		Status: system.NodeStatus{
+			State: system.NodeStateReady,
+			Conditions: []meta.Condition{
+				{Type: system.NodeConditionReady, Status: meta.ConditionTrue},
+			},
+		},
+	}
+}
+
+func newVM(id, nodeName, vcpus, memory string) *system.VirtualMachine {
+	vm := &system.VirtualMachine{
+		Meta: meta.Meta{
+			ID:          id,
+			Name:        id,
+			Group:       "group1",
+			Namespace:   "ns1",
+			Annotations: map[string]string{},
+		},
+		Spec: system.VirtualMachineSpec{
+			RequestVcpus:  vcpus,
+			LimitVcpus:    vcpus,
+			RequestMemory: memory,
+			LimitMemory:   memory,
+			ActionState:   system.VirtualMachineActionStatePowerOn,
+		},
+	}
+	if nodeName != "" {
+		vm.Annotations[annotationVirtualMachineNodeName] = nodeName
+	}
+	return vm
+}
+
+func newClients(objects ...interface{}) *fake.Clients {
+	return fake.NewClients(append([]interface{}{
+		&core.Group{Meta: meta.Meta{ID: "group1"}},
+		&core.Namespace{Meta: meta.Meta{ID: "ns1", Group: "group1"}},
+	}, objects...)...)
+}
+
+func schedule(t *testing.T, clients *fake.Clients, strategy ScoringStrategy) {
+	t.Helper()
+
+	a := NewSchedulerAgent(clients, &SchedulerAgentConfig{ScoringStrategy: strategy}, zap.NewNop())
+	if err := a.schedule(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getVM(t *testing.T, clients *fake.Clients, id string) *system.VirtualMachine {
+	t.Helper()
+
+	vm, err := clients.SystemV0().VirtualMachine().Get(context.Background(), "group1", "ns1", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+func TestScheduleScoringStrategy(t *testing.T) {
+	tests := []struct {
+		strategy ScoringStrategy
+		want     string
+	}{
+		{strategy: ScoringStrategyLeastAllocated, want: "node2"},
+		{strategy: ScoringStrategyMostAllocated, want: "node1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			clients := newClients(
+				newNode("node1", "16", "64G"),
+				newNode("node2", "16", "64G"),
+				newVM("running", "node1", "8000m", "32G"),
+				newVM("vm1", "", "1000m", "1G"),
+			)
+			schedule(t, clients, tt.strategy)
+
+			vm := getVM(t, clients, "vm1")
+			if got := vm.Annotations[annotationVirtualMachineNodeName]; got != tt.want {
+				t.Fatalf("expected %s, but got %s", tt.want, got)
+			}
+			if !meta.IsConditionTrue(vm.Status.Conditions, system.VirtualMachineConditionScheduled) {
+				t.Fatalf("unexpected conditions: %+v", vm.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestScheduleUnschedulable(t *testing.T) {
+	notReady := newNode("node1", "16", "64G")
+	notReady.Status.State = system.NodeStateNotReady
+	arm := newNode("node2", "16", "64G")
+	arm.Annotations = map[string]string{node.NodeV0AnnotationArch: "aarch64"}
+	small := newNode("node3", "16", "1G")
+
+	clients := newClients(notReady, arm, small, newVM("vm1", "", "1000m", "2G"))
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	vm := getVM(t, clients, "vm1")
+	if got := vm.Annotations[annotationVirtualMachineNodeName]; got != "" {
+		t.Fatalf("unexpected node: %s", got)
+	}
+	if vm.Status.State != system.VirtualMachineStatePending {
+		t.Fatalf("unexpected state: %s", vm.Status.State)
+	}
+	c := meta.FindCondition(vm.Status.Conditions, system.VirtualMachineConditionScheduled)
+	if c == nil || c.Status != meta.ConditionFalse || c.Reason != reasonUnschedulable {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+	want := "0/3 nodes are available: 1 insufficient memory, 1 node(s) didn't match arch x86_64, 1 node(s) not ready."
+	if c.Message != want {
+		t.Fatalf("unexpected message: %s", c.Message)
+	}
+
+	// 理由が変わらない場合は更新しない
+	clients.ClearActions()
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+	for _, action := range clients.Actions() {
+		if action.Verb == fake.VerbUpdate {
+			t.Fatalf("unexpected action: %+v", action)
+		}
+	}
+}
+
+func TestScheduleCapacity(t *testing.T) {
+	// 同じ周期で割り当てたVMのrequestも数える
+	clients := newClients(
+		newNode("node1", "2000m", "4G"),
+		newVM("vm1", "", "2000m", "1G"),
+		newVM("vm2", "", "2000m", "1G"),
+	)
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	if got := getVM(t, clients, "vm1").Annotations[annotationVirtualMachineNodeName]; got != "node1" {
+		t.Fatalf("expected node1, but got %s", got)
+	}
+	vm2 := getVM(t, clients, "vm2")
+	if got := vm2.Annotations[annotationVirtualMachineNodeName]; got != "" {
+		t.Fatalf("unexpected node: %s", got)
+	}
+	c := meta.FindCondition(vm2.Status.Conditions, system.VirtualMachineConditionScheduled)
+	if c == nil || c.Message != "0/1 nodes are available: 1 insufficient vcpus." {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+}
+
+func TestScheduleSkip(t *testing.T) {
+	powerOff := newVM("poweroff", "", "1000m", "1G")
+	powerOff.Spec.ActionState = system.VirtualMachineActionStatePowerOff
+	invalid := newVM("invalid", "", "1core", "1G")
+
+	clients := newClients(newNode("node1", "16", "64G"), powerOff, invalid)
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	// 停止しているVMは割り当てない
+	if vm := getVM(t, clients, "poweroff"); vm.Annotations[annotationVirtualMachineNodeName] != "" || len(vm.Status.Conditions) != 0 {
+		t.Fatalf("unexpected virtualmachine: %+v", vm)
+	}
+
+	c := meta.FindCondition(getVM(t, clients, "invalid").Status.Conditions, system.VirtualMachineConditionScheduled)
+	if c == nil || c.Reason != reasonInvalidRequest {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+}
+
+func newBlockStorage(id, nodeName, bsType, size string) *system.BlockStorage {
+	bs := &system.BlockStorage{
+		Meta: meta.Meta{
+			ID:        id,
+			Name:      id,
+			Group:     "group1",
+			Namespace: "ns1",
+			Annotations: map[string]string{
+				annotationBlockStorageType: bsType,
+			},
+		},
+		Spec: system.BlockStorageSpec{
+			RequestSize: size,
+			LimitSize:   size,
+		},
+	}
+	if nodeName != "" {
+		bs.Annotations[annotationBlockStorageNodeName] = nodeName
+	}
+	return bs
+}
+
+func getBlockStorage(t *testing.T, clients *fake.Clients, id string) *system.BlockStorage {
+	t.Helper()
+
+	bs, err := clients.SystemV0().BlockStorage().Get(context.Background(), "group1", "ns1", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bs
+}
+
+func TestScheduleLocalBlockStorage(t *testing.T) {
+	node1 := newNode("node1", "16", "64G")
+	node1.Spec.LimitDisk = "5G"
+	node2 := newNode("node2", "16", "64G")
+	node2.Spec.LimitDisk = "100G"
+	// node1の方が空いているが、ディスクが足りない
+	vm := newVM("vm1", "", "1000m", "1G")
+	vm.Spec.BlockStorageIDs = []string{"local", "ceph"}
+
+	clients := newClients(
+		node1,
+		node2,
+		newVM("running", "node2", "8000m", "32G"),
+		vm,
+		newBlockStorage("local", "", blockStorageTypeLocal, "10G"),
+		newBlockStorage("ceph", "", "Ceph", "10G"),
+	)
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	if got := getVM(t, clients, "vm1").Annotations[annotationVirtualMachineNodeName]; got != "node2" {
+		t.Fatalf("expected node2, but got %s", got)
+	}
+	if got := getBlockStorage(t, clients, "local").Annotations[annotationBlockStorageNodeName]; got != "node2" {
+		t.Fatalf("expected local blockstorage on node2, but got %s", got)
+	}
+	if got := getBlockStorage(t, clients, "ceph").Annotations[annotationBlockStorageNodeName]; got != "" {
+		t.Fatalf("unexpected ceph blockstorage node: %s", got)
+	}
+}
+
+func TestSchedulePlacedLocalBlockStorage(t *testing.T) {
+	// 配置済みのLocalのblockstorageがあるノードに割り当てる
+	vm := newVM("vm1", "", "1000m", "1G")
+	vm.Spec.BlockStorageIDs = []string{"bs1"}
+	clients := newClients(
+		newNode("node1", "16", "64G"),
+		newNode("node2", "16", "64G"),
+		newVM("running", "node2", "8000m", "32G"),
+		vm,
+		newBlockStorage("bs1", "node2", blockStorageTypeLocal, "10G"),
+	)
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	if got := getVM(t, clients, "vm1").Annotations[annotationVirtualMachineNodeName]; got != "node2" {
+		t.Fatalf("expected node2, but got %s", got)
+	}
+}
+
+func TestScheduleLocalStorageConflict(t *testing.T) {
+	vm := newVM("vm1", "", "1000m", "1G")
+	vm.Spec.BlockStorageIDs = []string{"bs1", "bs2"}
+	clients := newClients(
+		newNode("node1", "16", "64G"),
+		newNode("node2", "16", "64G"),
+		vm,
+		newBlockStorage("bs1", "node1", blockStorageTypeLocal, "10G"),
+		newBlockStorage("bs2", "node2", blockStorageTypeLocal, "10G"),
+	)
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	vm = getVM(t, clients, "vm1")
+	if got := vm.Annotations[annotationVirtualMachineNodeName]; got != "" {
+		t.Fatalf("unexpected node: %s", got)
+	}
+	c := meta.FindCondition(vm.Status.Conditions, system.VirtualMachineConditionScheduled)
+	if c == nil || c.Reason != reasonLocalStorageConflict ||
+		c.Message != "local blockstorages are placed on different nodes: bs1 on node1, bs2 on node2." {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+}
+
+func TestScheduleManuallyPlacedVirtualMachine(t *testing.T) {
+	// 手動で割り当てられたVMのblockstorageはVMのノードに配置する
+	vm := newVM("vm1", "node2", "1000m", "1G")
+	vm.Spec.BlockStorageIDs = []string{"bs1"}
+	clients := newClients(
+		newNode("node1", "16", "64G"),
+		newNode("node2", "16", "64G"),
+		vm,
+		newBlockStorage("bs1", "", blockStorageTypeLocal, "10G"),
+	)
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	if got := getBlockStorage(t, clients, "bs1").Annotations[annotationBlockStorageNodeName]; got != "node2" {
+		t.Fatalf("expected node2, but got %s", got)
+	}
+}
+
+func TestScheduleNodeSelector(t *testing.T) {
+	node2 := newNode("node2", "16", "64G")
+	node2.Labels = map[string]string{"zone": "b"}
+	vm := newVM("vm1", "", "1000m", "1G")
+	vm.Spec.NodeSelector = map[string]string{"zone": "b"}
+
+	clients := newClients(newNode("node1", "16", "64G"), node2, newVM("running", "node2", "8000m", "32G"), vm)
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	if got := getVM(t, clients, "vm1").Annotations[annotationVirtualMachineNodeName]; got != "node2" {
+		t.Fatalf("expected node2, but got %s", got)
+	}
+}
+
+func webTerm(scope system.VirtualMachineAffinityScope) system.VirtualMachineAffinityTerm {
+	return system.VirtualMachineAffinityTerm{
+		LabelSelector: meta.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		Scope:         scope,
+	}
+}
+
+func TestScheduleAffinity(t *testing.T) {
+	web := newVM("web", "node2", "8000m", "32G")
+	web.Labels = map[string]string{"app": "web"}
+	vm := newVM("vm1", "", "1000m", "1G")
+	vm.Spec.Affinity.Required = []system.VirtualMachineAffinityTerm{webTerm("")}
+
+	clients := newClients(newNode("node1", "16", "64G"), newNode("node2", "16", "64G"), web, vm)
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	if got := getVM(t, clients, "vm1").Annotations[annotationVirtualMachineNodeName]; got != "node2" {
+		t.Fatalf("expected node2, but got %s", got)
+	}
+}
+
+func TestScheduleSelfAffinity(t *testing.T) {
+	// 一致するVMがまだない場合、自身が一致すれば配置できる
+	vm1 := newVM("vm1", "", "1000m", "1G")
+	vm1.Labels = map[string]string{"app": "web"}
+	vm1.Spec.Affinity.Required = []system.VirtualMachineAffinityTerm{webTerm("")}
+	vm2 := newVM("vm2", "", "1000m", "1G")
+	vm2.Labels = map[string]string{"app": "web"}
+	vm2.Spec.Affinity.Required = []system.VirtualMachineAffinityTerm{webTerm("")}
+	// 自身も一致しない場合は配置しない
+	vm3 := newVM("vm3", "", "1000m", "1G")
+	vm3.Spec.Affinity.Required = []system.VirtualMachineAffinityTerm{{
+		LabelSelector: meta.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+	}}
+
+	clients := newClients(newNode("node1", "16", "64G"), newNode("node2", "16", "64G"), vm1, vm2, vm3)
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	node1 := getVM(t, clients, "vm1").Annotations[annotationVirtualMachineNodeName]
+	if node1 == "" || getVM(t, clients, "vm2").Annotations[annotationVirtualMachineNodeName] != node1 {
+		t.Fatalf("expected vm1 and vm2 on the same node")
+	}
+	c := meta.FindCondition(getVM(t, clients, "vm3").Status.Conditions, system.VirtualMachineConditionScheduled)
+	if c == nil || c.Message != "0/2 nodes are available: 2 node(s) didn't match virtualmachine affinity." {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+}
+
+func TestScheduleAntiAffinity(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing func(vm *system.VirtualMachine)
+		vm       func(vm *system.VirtualMachine)
+		want     string
+	}{
+		{
+			name: "required",
+			vm: func(vm *system.VirtualMachine) {
+				vm.Spec.AntiAffinity.Required = []system.VirtualMachineAffinityTerm{webTerm("")}
+			},
+			want: "node2",
+		},
+		{
+			// 配置済みのVMのantiAffinityも満たす
+			name: "existing",
+			existing: func(vm *system.VirtualMachine) {
+				vm.Spec.AntiAffinity.Required = []system.VirtualMachineAffinityTerm{webTerm("")}
+			},
+			vm: func(vm *system.VirtualMachine) {
+				vm.Labels = map[string]string{"app": "web"}
+			},
+			want: "node2",
+		},
+		{
+			name: "preferred",
+			vm: func(vm *system.VirtualMachine) {
+				vm.Spec.AntiAffinity.Preferred = []system.WeightedVirtualMachineAffinityTerm{
+					{Weight: 50, VirtualMachineAffinityTerm: webTerm("")},
+				}
+			},
+			want: "node2",
+		},
+		{
+			// 別のgroupのVMはGroupでも対象にしない
+			name: "other group",
+			existing: func(vm *system.VirtualMachine) {
+				vm.Group = "group2"
+			},
+			vm: func(vm *system.VirtualMachine) {
+				vm.Spec.AntiAffinity.Required = []system.VirtualMachineAffinityTerm{
+					webTerm(system.VirtualMachineAffinityScopeGroup),
+				}
+			},
+			want: "node1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// node1の方が空いているが、一致するVMがある
+			web := newVM("web", "node1", "1000m", "1G")
+			web.Labels = map[string]string{"app": "web"}
+			if tt.existing != nil {
+				tt.existing(web)
+			}
+			vm := newVM("vm1", "", "1000m", "1G")
+			tt.vm(vm)
+
+			clients := newClients(
+				&core.Group{Meta: meta.Meta{ID: "group2"}},
+				&core.Namespace{Meta: meta.Meta{ID: "ns1", Group: "group2"}},
+				newNode("node1", "16", "64G"),
+				newNode("node2", "16", "64G"),
+				newVM("running", "node2", "4000m", "16G"),
+				web,
+				vm,
+			)
+			schedule(t, clients, ScoringStrategyLeastAllocated)
+
+			if got := getVM(t, clients, "vm1").Annotations[annotationVirtualMachineNodeName]; got != tt.want {
+				t.Fatalf("expected %s, but got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestScheduleCordonedNode(t *testing.T) {
+	cordoned := newNode("node1", "16", "64G")
+	cordoned.Spec.Unschedulable = true
+
+	clients := newClients(cordoned, newVM("vm1", "", "1000m", "1G"))
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	c := meta.FindCondition(getVM(t, clients, "vm1").Status.Conditions, system.VirtualMachineConditionScheduled)
+	if c == nil || c.Message != "0/1 nodes are available: 1 node(s) were unschedulable." {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+}
+
+func TestScheduleAllocatable(t *testing.T) {
+	// allocatableがある場合はspecのlimitより優先する
+	detected := newNode("node1", "16", "64G")
+	detected.Status.Allocatable = system.NodeAllocatable{
+		Vcpus:  "2000m",
+		Memory: "4G",
+	}
+
+	clients := newClients(detected, newVM("vm1", "", "4000m", "1G"))
+	schedule(t, clients, ScoringStrategyLeastAllocated)
+
+	c := meta.FindCondition(getVM(t, clients, "vm1").Status.Conditions, system.VirtualMachineConditionScheduled)
+	if c == nil || c.Message != "0/1 nodes are available: 1 insufficient vcpus." {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+}
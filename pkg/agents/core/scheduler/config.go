@@ -0,0 +1,29 @@
+package scheduler
+
+import "fmt"
+
+type ScoringStrategy string
+
+const (
+	// 割り当て済みのリソースが少ないノードを優先し、VMを分散させる
+	ScoringStrategyLeastAllocated ScoringStrategy = "LeastAllocated"
+	// 割り当て済みのリソースが多いノードを優先し、VMを詰め込む
+	ScoringStrategyMostAllocated ScoringStrategy = "MostAllocated"
+)
+
+type SchedulerAgentConfig struct {
+	// 配置できるノードが複数ある場合の選び方(省略時はLeastAllocated)
+	ScoringStrategy ScoringStrategy `yaml:"scoringStrategy"`
+}
+
+// Validate は省略された値を埋め、不正な値の場合はエラーを返す
+func (c *SchedulerAgentConfig) Validate() error {
+	switch c.ScoringStrategy {
+	case "":
+		c.ScoringStrategy = ScoringStrategyLeastAllocated
+	case ScoringStrategyLeastAllocated, ScoringStrategyMostAllocated:
+	default:
+		return fmt.Errorf("unknown scoringStrategy `%s`", c.ScoringStrategy)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ophum/humstack/pkg/agents/system/node"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/utils/quantity"
+)
+
+// archが指定されていないVM, ノードはx86_64として扱う
+const defaultArch = "x86_64"
+
+// nodeInfo はスケジューリングに使うノードの状態
+type nodeInfo struct {
+	node *system.Node
+
+	// 割り当て済みのVMとLocalのblockstorageのrequestの合計
+	requested resources
+
+	// ノードの上限。0の場合は上限なしとして扱う
+	limit resources
+
+	// 割り当て済みのVM。停止しているVMもaffinityの対象にする
+	vms []*system.VirtualMachine
+}
+
+// resources はvcpu(ミリ単位)、メモリとディスク(バイト)の量
+type resources struct {
+	milliVcpus int64
+	memory     int64
+	disk       int64
+}
+
+func (r resources) add(o resources) resources {
+	return resources{
+		milliVcpus: r.milliVcpus + o.milliVcpus,
+		memory:     r.memory + o.memory,
+		disk:       r.disk + o.disk,
+	}
+}
+
+func newNodeInfo(n *system.Node) (*nodeInfo, error) {
+	// node agentが検出したallocatableを使う
+	// 設定していない古いagentのノードはspecのlimitを使う
+	limitVcpus, err := quantity.ParseMilliVcpus(firstNonEmpty(n.Status.Allocatable.Vcpus, n.Spec.LimitVcpus))
+	if err != nil {
+		return nil, err
+	}
+	limitMemory, err := quantity.ParseBytes(firstNonEmpty(n.Status.Allocatable.Memory, n.Spec.LimitMemory))
+	if err != nil {
+		return nil, err
+	}
+	limitDisk, err := quantity.ParseBytes(firstNonEmpty(n.Status.Allocatable.Disk, n.Spec.LimitDisk))
+	if err != nil {
+		return nil, err
+	}
+	return &nodeInfo{
+		node: n,
+		vms:  []*system.VirtualMachine{},
+		limit: resources{
+			milliVcpus: limitVcpus,
+			memory:     limitMemory,
+			disk:       limitDisk,
+		},
+	}, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// request はVMを配置するために必要なもの
+type request struct {
+	vm *system.VirtualMachine
+
+	// VMとまだ配置されていないLocalのblockstorageの量
+	resources resources
+
+	// 配置済みのLocalのblockstorageのノードごとのid
+	localNodes map[string][]string
+
+	// VMと一緒に配置するLocalのblockstorage
+	unplaced []*system.BlockStorage
+
+	// requiredのaffinityごとに、VM自身が一致するため満たしたとみなすかどうか
+	selfAffinity []bool
+}
+
+func getVirtualMachineRequests(vm *system.VirtualMachine) (resources, error) {
+	vcpus, err := quantity.ParseMilliVcpus(vm.Spec.RequestVcpus)
+	if err != nil {
+		return resources{}, err
+	}
+	memory, err := quantity.ParseBytes(vm.Spec.RequestMemory)
+	if err != nil {
+		return resources{}, err
+	}
+	return resources{
+		milliVcpus: vcpus,
+		memory:     memory,
+	}, nil
+}
+
+func getBlockStorageRequests(bs *system.BlockStorage) (resources, error) {
+	disk, err := quantity.ParseBytes(bs.Spec.RequestSize)
+	if err != nil {
+		return resources{}, err
+	}
+	return resources{
+		disk: disk,
+	}, nil
+}
+
+// filter はVMをノードに配置できない場合にその理由を返す
+// 理由はノードをまたいで数えるので、ノードごとに異なる内容を含めない
+type filter func(req *request, n *nodeInfo) string
+
+var filters = []filter{
+	filterNodeReady,
+	filterNodeUnschedulable,
+	filterArch,
+	filterNodeSelector,
+	filterAffinity,
+	filterAntiAffinity,
+	filterLocalStorage,
+	filterCapacity,
+}
+
+func filterNodeReady(req *request, n *nodeInfo) string {
+	if n.node.Status.State != system.NodeStateReady ||
+		!meta.IsConditionTrue(n.node.Status.Conditions, system.NodeConditionReady) {
+		return "node(s) not ready"
+	}
+	return ""
+}
+
+func filterNodeUnschedulable(req *request, n *nodeInfo) string {
+	if n.node.Spec.Unschedulable {
+		return "node(s) were unschedulable"
+	}
+	return ""
+}
+
+func filterArch(req *request, n *nodeInfo) string {
+	arch := req.vm.Annotations[annotationVirtualMachineArch]
+	if arch == "" {
+		arch = defaultArch
+	}
+	nodeArch := n.node.Annotations[node.NodeV0AnnotationArch]
+	if nodeArch == "" {
+		nodeArch = defaultArch
+	}
+	if arch != nodeArch {
+		return fmt.Sprintf("node(s) didn't match arch %s", arch)
+	}
+	return ""
+}
+
+// filterLocalStorage はLocalのblockstorageが別のノードに分かれないようにする
+func filterLocalStorage(req *request, n *nodeInfo) string {
+	if _, ok := req.localNodes[n.node.ID]; len(req.localNodes) != 0 && !ok {
+		return "node(s) didn't have the local blockstorages"
+	}
+	return ""
+}
+
+func filterCapacity(req *request, n *nodeInfo) string {
+	total := n.requested.add(req.resources)
+	if n.limit.milliVcpus != 0 && total.milliVcpus > n.limit.milliVcpus {
+		return "insufficient vcpus"
+	}
+	if n.limit.memory != 0 && total.memory > n.limit.memory {
+		return "insufficient memory"
+	}
+	if req.resources.disk != 0 && n.limit.disk != 0 && total.disk > n.limit.disk {
+		return "insufficient disk"
+	}
+	return ""
+}
+
+// unschedulableMessage は配置できなかった理由をまとめる
+// e.g. 0/3 nodes are available: 1 insufficient memory, 2 node(s) not ready.
+func unschedulableMessage(numNodes int, reasons map[string]int) string {
+	list := []string{}
+	for reason, count := range reasons {
+		list = append(list, fmt.Sprintf("%d %s", count, reason))
+	}
+	sort.Strings(list)
+
+	if len(list) == 0 {
+		return fmt.Sprintf("0/%d nodes are available.", numNodes)
+	}
+	return fmt.Sprintf("0/%d nodes are available: %s.", numNodes, strings.Join(list, ", "))
+}
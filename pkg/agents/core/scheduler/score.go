@@ -0,0 +1,34 @@
+package scheduler
+
+// score はVMを配置した後のノードの割り当て率から点数を返す
+// 点数が高いノードほど優先する
+func (s ScoringStrategy) score(req *request, n *nodeInfo) float64 {
+	total := n.requested.add(req.resources)
+
+	// 上限が設定されていないリソースは比較できないので除く
+	// ディスクはLocalのblockstorageを一緒に配置する場合のみ比較する
+	ratios := []float64{}
+	if n.limit.milliVcpus != 0 {
+		ratios = append(ratios, float64(total.milliVcpus)/float64(n.limit.milliVcpus))
+	}
+	if n.limit.memory != 0 {
+		ratios = append(ratios, float64(total.memory)/float64(n.limit.memory))
+	}
+	if req.resources.disk != 0 && n.limit.disk != 0 {
+		ratios = append(ratios, float64(total.disk)/float64(n.limit.disk))
+	}
+	if len(ratios) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, r := range ratios {
+		sum += r
+	}
+	allocated := sum / float64(len(ratios))
+
+	if s == ScoringStrategyMostAllocated {
+		return allocated
+	}
+	return 1 - allocated
+}
@@ -0,0 +1,122 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client"
+	"go.uber.org/zap"
+)
+
+// 記録に時間がかかってagentの処理が止まらないようにする
+const recordTimeout = time.Second * 10
+
+type eventClient interface {
+	Get(ctx context.Context, eventID string) (*core.Event, error)
+	Create(ctx context.Context, event *core.Event) (*core.Event, error)
+	Update(ctx context.Context, event *core.Event) (*core.Event, error)
+}
+
+// Recorder は各agentがリソースに関するイベントを記録するためのもの
+// nilの場合は何もしない
+type Recorder struct {
+	client   eventClient
+	nodeName string
+	logger   *zap.Logger
+	now      func() time.Time
+
+	mutex sync.Mutex
+}
+
+func NewRecorder(client client.Interface, nodeName string, logger *zap.Logger) *Recorder {
+	return &Recorder{
+		client:   client.CoreV0().Event(),
+		nodeName: nodeName,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Event はobjectのイベントを記録する
+// 同じノードから同じ内容のイベントが記録済みの場合は回数と最終発生時刻を更新する
+// 記録に失敗してもagentの処理は続けられるようにログに出すだけにする
+func (r *Recorder) Event(object meta.Meta, eventType core.EventType, reason, message string) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	if err := r.record(ctx, object, eventType, reason, message); err != nil {
+		r.logger.Error(
+			"record event",
+			zap.String("apiType", string(object.APIType)),
+			zap.String("id", object.ID),
+			zap.String("reason", reason),
+			zap.String("msg", err.Error()),
+			zap.Time("time", time.Now()),
+		)
+	}
+}
+
+func (r *Recorder) Eventf(object meta.Meta, eventType core.EventType, reason, format string, args ...interface{}) {
+	r.Event(object, eventType, reason, fmt.Sprintf(format, args...))
+}
+
+func (r *Recorder) record(ctx context.Context, object meta.Meta, eventType core.EventType, reason, message string) error {
+	now := r.now()
+	ref := meta.NewObjectReference(object)
+	id := eventID(ref, r.nodeName, eventType, reason, message)
+
+	e, err := r.client.Get(ctx, id)
+	if err != nil && !meta.IsNotFound(err) {
+		return err
+	}
+
+	if meta.IsNotFound(err) {
+		_, err := r.client.Create(ctx, &core.Event{
+			Meta: meta.Meta{
+				ID:   id,
+				Name: fmt.Sprintf("%s.%s", object.ID, reason),
+			},
+			Spec: core.EventSpec{
+				InvolvedObject: ref,
+				Reason:         reason,
+				Message:        message,
+				Type:           eventType,
+				Count:          1,
+				FirstTimestamp: now,
+				LastTimestamp:  now,
+				ReportingNode:  r.nodeName,
+			},
+		})
+		return err
+	}
+
+	e.Spec.Count++
+	e.Spec.LastTimestamp = now
+	_, err = r.client.Update(ctx, e)
+	return err
+}
+
+// eventID は同じ内容のイベントをまとめるためのidを返す
+func eventID(ref meta.ObjectReference, nodeName string, eventType core.EventType, reason, message string) string {
+	h := fnv.New64a()
+	for _, s := range []string{
+		string(ref.APIType), ref.Group, ref.Namespace, ref.ID, ref.UID,
+		nodeName, string(eventType), reason, message,
+	} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s.%016x", ref.ID, h.Sum64())
+}
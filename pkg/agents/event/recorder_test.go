@@ -0,0 +1,80 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"go.uber.org/zap"
+)
+
+type fakeEventClient struct {
+	events map[string]*core.Event
+}
+
+func (c *fakeEventClient) Get(ctx context.Context, eventID string) (*core.Event, error) {
+	if e, ok := c.events[eventID]; ok {
+		copied := *e
+		return &copied, nil
+	}
+	return nil, meta.NewNotFound("Event `%s` is not found.", eventID)
+}
+
+func (c *fakeEventClient) Create(ctx context.Context, event *core.Event) (*core.Event, error) {
+	c.events[event.ID] = event
+	return event, nil
+}
+
+func (c *fakeEventClient) Update(ctx context.Context, event *core.Event) (*core.Event, error) {
+	c.events[event.ID] = event
+	return event, nil
+}
+
+func TestRecorderAggregatesSameEvents(t *testing.T) {
+	c := &fakeEventClient{events: map[string]*core.Event{}}
+	now := time.Unix(100, 0)
+	r := &Recorder{
+		client:   c,
+		nodeName: "node1",
+		logger:   zap.NewNop(),
+		now:      func() time.Time { return now },
+	}
+
+	vm := meta.Meta{
+		ID:        "vm1",
+		Group:     "group1",
+		Namespace: "ns1",
+		APIType:   meta.APITypeVirtualMachineV0,
+	}
+
+	r.Event(vm, core.EventTypeWarning, "SyncFailed", "failed")
+	now = now.Add(time.Minute)
+	r.Event(vm, core.EventTypeWarning, "SyncFailed", "failed")
+	r.Event(vm, core.EventTypeNormal, "Started", "started")
+
+	if len(c.events) != 2 {
+		t.Fatalf("unexpected number of events: %d", len(c.events))
+	}
+
+	for _, e := range c.events {
+		if e.Spec.Reason != "SyncFailed" {
+			continue
+		}
+		if e.Spec.Count != 2 {
+			t.Fatalf("unexpected count: %d", e.Spec.Count)
+		}
+		if !e.Spec.FirstTimestamp.Equal(time.Unix(100, 0)) || !e.Spec.LastTimestamp.Equal(now) {
+			t.Fatalf("unexpected timestamps: %s, %s", e.Spec.FirstTimestamp, e.Spec.LastTimestamp)
+		}
+		if e.Spec.InvolvedObject.ID != "vm1" || e.Spec.ReportingNode != "node1" {
+			t.Fatalf("unexpected event: %+v", e.Spec)
+		}
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	r.Event(meta.Meta{ID: "vm1"}, core.EventTypeNormal, "Started", "started")
+}
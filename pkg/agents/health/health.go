@@ -0,0 +1,137 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/version"
+)
+
+type HealthAPIConfig struct {
+	ListenAddress string `yaml:"listenAddress"`
+	ListenPort    int32  `yaml:"listenPort"`
+	// この時間以上reconcileが完了していないagentがあれば異常とする
+	StaleAfter time.Duration `yaml:"staleAfter"`
+}
+
+// Reporter は各agentがreconcileの完了を報告するためのもの
+// nilの場合は何もしない
+type Reporter struct {
+	name      string
+	startedAt time.Time
+
+	mutex            sync.RWMutex
+	lastReconciledAt time.Time
+}
+
+// Reconciled はreconcileが一巡した時に呼ぶ
+func (r *Reporter) Reconciled() {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastReconciledAt = time.Now()
+}
+
+type AgentStatus struct {
+	Name             string     `json:"name"`
+	LastReconciledAt *time.Time `json:"lastReconciledAt"`
+	Healthy          bool       `json:"healthy"`
+}
+
+func (r *Reporter) status(now time.Time, staleAfter time.Duration) AgentStatus {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	status := AgentStatus{
+		Name: r.name,
+	}
+
+	// 起動直後はまだ一度も完了していなくても異常としない
+	last := r.startedAt
+	if !r.lastReconciledAt.IsZero() {
+		t := r.lastReconciledAt
+		status.LastReconciledAt = &t
+		last = t
+	}
+	status.Healthy = now.Sub(last) <= staleAfter
+
+	return status
+}
+
+type Registry struct {
+	staleAfter time.Duration
+
+	mutex     sync.RWMutex
+	reporters map[string]*Reporter
+}
+
+func NewRegistry(staleAfter time.Duration) *Registry {
+	return &Registry{
+		staleAfter: staleAfter,
+		reporters:  map[string]*Reporter{},
+	}
+}
+
+// Reporter はnameのagent用のReporterを返す
+func (r *Registry) Reporter(name string) *Reporter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if reporter, ok := r.reporters[name]; ok {
+		return reporter
+	}
+
+	reporter := &Reporter{
+		name:      name,
+		startedAt: time.Now(),
+	}
+	r.reporters[name] = reporter
+	return reporter
+}
+
+func (r *Registry) Statuses() []AgentStatus {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := time.Now()
+	statuses := []AgentStatus{}
+	for _, reporter := range r.reporters {
+		statuses = append(statuses, reporter.status(now, r.staleAfter))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+func (r *Registry) Healthz(ctx *gin.Context) {
+	code := http.StatusOK
+	statuses := r.Statuses()
+	for _, s := range statuses {
+		if !s.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	ctx.JSON(code, gin.H{
+		"agents": statuses,
+	})
+}
+
+func (r *Registry) HealthAPI(config *HealthAPIConfig) error {
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	router.GET("/healthz", r.Healthz)
+	router.GET("/version", version.Handler)
+
+	return http.ListenAndServe(fmt.Sprintf("%s:%d", config.ListenAddress, config.ListenPort), router)
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistryStatuses(t *testing.T) {
+	r := NewRegistry(time.Millisecond * 10)
+
+	fresh := r.Reporter("fresh")
+	stale := r.Reporter("stale")
+	fresh.Reconciled()
+
+	statuses := r.Statuses()
+	if len(statuses) != 2 {
+		t.Fatalf("unexpected statuses: %v", statuses)
+	}
+	// 起動直後はreconcileしていなくても正常
+	for _, s := range statuses {
+		if !s.Healthy {
+			t.Fatalf("%s should be healthy", s.Name)
+		}
+	}
+	if statuses[1].LastReconciledAt != nil {
+		t.Fatal("stale agent has not reconciled yet")
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	fresh.Reconciled()
+
+	statuses = r.Statuses()
+	if !statuses[0].Healthy || statuses[0].Name != "fresh" {
+		t.Fatalf("fresh should be healthy: %v", statuses[0])
+	}
+	if statuses[1].Healthy || statuses[1].Name != "stale" {
+		t.Fatalf("stale should be unhealthy: %v", statuses[1])
+	}
+
+	// 同じ名前では同じReporterを返す
+	if r.Reporter("stale") != stale {
+		t.Fatal("expected same reporter")
+	}
+
+	// nilのReporterは何もしない
+	var nilReporter *Reporter
+	nilReporter.Reconciled()
+}
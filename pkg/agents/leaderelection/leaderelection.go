@@ -0,0 +1,254 @@
+package leaderelection
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultLeaseName     = "core-agent"
+	DefaultLeaseDuration = time.Second * 15
+	DefaultRenewDeadline = time.Second * 10
+	DefaultRetryPeriod   = time.Second * 2
+)
+
+type LeaderElectionConfig struct {
+	// 同じleaseを使うagentの中から1つだけleaderになる
+	LeaseName string `yaml:"leaseName"`
+	// leaderがこの時間更新しなければ他のagentがleaseを取得できる
+	LeaseDuration time.Duration `yaml:"leaseDuration"`
+	// leaderがこの時間更新できなければleaderをやめる
+	// leaseDurationより短くしなければならない
+	RenewDeadline time.Duration `yaml:"renewDeadline"`
+	// leaseの取得・更新を試みる間隔
+	RetryPeriod time.Duration `yaml:"retryPeriod"`
+}
+
+type leaseClient interface {
+	Get(ctx context.Context, leaseID string) (*core.Lease, error)
+	Create(ctx context.Context, lease *core.Lease) (*core.Lease, error)
+	Update(ctx context.Context, lease *core.Lease) (*core.Lease, error)
+}
+
+// LeaderElector はleaseを使って複数のagentの中からleaderを1つ選ぶ
+// nilの場合は常にleaderとして扱う
+type LeaderElector struct {
+	client   leaseClient
+	identity string
+	config   LeaderElectionConfig
+	logger   *zap.Logger
+	recorder *event.Recorder
+	now      func() time.Time
+
+	mutex sync.RWMutex
+	// 最後にleaseを取得・更新できた時刻
+	renewedAt time.Time
+	isLeader  bool
+
+	// 他のagentが保持しているleaseを最後に観測した内容と時刻
+	// apiserverとの時計のずれに影響されないように、変化がない期間を自身の時計で測る
+	observedHolder    string
+	observedRenewTime time.Time
+	observedAt        time.Time
+}
+
+func NewLeaderElector(client client.Interface, identity string, config *LeaderElectionConfig, logger *zap.Logger) *LeaderElector {
+	return &LeaderElector{
+		client:   client.CoreV0().Lease(),
+		identity: identity,
+		config:   *config,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+func (e *LeaderElector) SetEventRecorder(recorder *event.Recorder) {
+	e.recorder = recorder
+}
+
+// IsLeader はこのagentがleaderであるかを返す
+// renewDeadline以上leaseを更新できていない場合はleaderでないとする
+func (e *LeaderElector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.isLeaderLocked(e.now())
+}
+
+func (e *LeaderElector) isLeaderLocked(now time.Time) bool {
+	return e.isLeader && now.Sub(e.renewedAt) < e.config.RenewDeadline
+}
+
+// Run はctxがキャンセルされるまでleaseの取得・更新を続ける
+// キャンセルされた時にleaderであれば、他のagentがすぐに引き継げるようにleaseを解放する
+func (e *LeaderElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.RetryPeriod)
+	defer ticker.Stop()
+
+	for {
+		e.tryAcquireOrRenew(ctx)
+
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *LeaderElector) tryAcquireOrRenew(ctx context.Context) {
+	// renewDeadlineを過ぎた応答では更新できてもleaderになれないため待たない
+	ctx, cancel := context.WithTimeout(ctx, e.config.RenewDeadline)
+	defer cancel()
+
+	// リクエストを送る前の時刻を更新時刻とし、apiserverが判断する期限より先に切れるようにする
+	now := e.now()
+	acquired, err := e.acquireOrRenew(ctx, now)
+	if err != nil {
+		e.logger.Error(
+			"acquire or renew lease",
+			zap.String("lease", e.config.LeaseName),
+			zap.String("msg", err.Error()),
+			zap.Time("time", time.Now()),
+		)
+	}
+
+	e.mutex.Lock()
+	wasLeader := e.isLeaderLocked(now)
+	if acquired {
+		e.isLeader = true
+		e.renewedAt = now
+	}
+	e.isLeader = e.isLeaderLocked(e.now())
+	isLeader := e.isLeader
+	e.mutex.Unlock()
+
+	object := meta.Meta{
+		ID:      e.config.LeaseName,
+		Name:    e.config.LeaseName,
+		APIType: meta.APITypeLeaseV0,
+	}
+	if !wasLeader && isLeader {
+		e.logger.Info(
+			"became leader",
+			zap.String("lease", e.config.LeaseName),
+			zap.Time("time", time.Now()),
+		)
+		e.recorder.Eventf(object, core.EventTypeNormal, "LeaderElected", "`%s` became leader", e.identity)
+	} else if wasLeader && !isLeader {
+		e.logger.Warn(
+			"lost leadership",
+			zap.String("lease", e.config.LeaseName),
+			zap.Time("time", time.Now()),
+		)
+		e.recorder.Eventf(object, core.EventTypeWarning, "LeaderLost", "`%s` lost leadership", e.identity)
+	}
+}
+
+// acquireOrRenew はleaseを取得・更新できた場合にtrueを返す
+// 他のagentが期限内のleaseを保持している場合はfalseを返す
+func (e *LeaderElector) acquireOrRenew(ctx context.Context, now time.Time) (bool, error) {
+	lease, err := e.client.Get(ctx, e.config.LeaseName)
+	if err != nil && !meta.IsNotFound(err) {
+		return false, err
+	}
+
+	// 存在しない場合は作成する
+	if meta.IsNotFound(err) {
+		_, err := e.client.Create(ctx, &core.Lease{
+			Meta: meta.Meta{
+				ID:   e.config.LeaseName,
+				Name: e.config.LeaseName,
+			},
+			Spec: core.LeaseSpec{
+				HolderIdentity:       e.identity,
+				LeaseDurationSeconds: e.leaseDurationSeconds(),
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if lease.Spec.HolderIdentity != e.identity && !e.observedExpired(lease, now) {
+		return false, nil
+	}
+
+	lease.Spec.HolderIdentity = e.identity
+	lease.Spec.LeaseDurationSeconds = e.leaseDurationSeconds()
+	if _, err := e.client.Update(ctx, lease); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// observedExpired は他のagentが保持しているleaseの期限が切れているかを返す
+// holderとrenewTimeがleaseDurationの間変化していなければ期限切れとする
+func (e *LeaderElector) observedExpired(lease *core.Lease, now time.Time) bool {
+	if lease.Spec.HolderIdentity == "" {
+		return true
+	}
+
+	renewTime := time.Time{}
+	if lease.Spec.RenewTime != nil {
+		renewTime = *lease.Spec.RenewTime
+	}
+	if lease.Spec.HolderIdentity != e.observedHolder || !renewTime.Equal(e.observedRenewTime) {
+		e.observedHolder = lease.Spec.HolderIdentity
+		e.observedRenewTime = renewTime
+		e.observedAt = now
+	}
+
+	duration := time.Duration(lease.Spec.LeaseDurationSeconds) * time.Second
+	return !now.Before(e.observedAt.Add(duration))
+}
+
+// release はleaderであればleaseを解放する
+// Runのctxはキャンセル済みのため別のctxを使う
+func (e *LeaderElector) release() {
+	e.mutex.Lock()
+	isLeader := e.isLeader
+	e.isLeader = false
+	e.mutex.Unlock()
+
+	if !isLeader {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.RetryPeriod)
+	defer cancel()
+
+	lease, err := e.client.Get(ctx, e.config.LeaseName)
+	if err == nil && lease.Spec.HolderIdentity == e.identity {
+		lease.Spec.HolderIdentity = ""
+		_, err = e.client.Update(ctx, lease)
+	}
+	if err != nil {
+		e.logger.Error(
+			"release lease",
+			zap.String("lease", e.config.LeaseName),
+			zap.String("msg", err.Error()),
+			zap.Time("time", time.Now()),
+		)
+	}
+}
+
+func (e *LeaderElector) leaseDurationSeconds() int32 {
+	seconds := int32(e.config.LeaseDuration / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
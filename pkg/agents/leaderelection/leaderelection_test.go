@@ -0,0 +1,171 @@
+package leaderelection
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"go.uber.org/zap"
+)
+
+// fakeLeaseClient はapiserverと同じ条件でleaseの取得を判定する
+type fakeLeaseClient struct {
+	lease *core.Lease
+	now   *time.Time
+	err   error
+}
+
+func (c *fakeLeaseClient) Get(ctx context.Context, leaseID string) (*core.Lease, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.lease == nil {
+		return nil, meta.NewNotFound("Lease `%s` is not found.", leaseID)
+	}
+	copied := *c.lease
+	return &copied, nil
+}
+
+func (c *fakeLeaseClient) Create(ctx context.Context, lease *core.Lease) (*core.Lease, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.lease != nil {
+		return nil, fmt.Errorf("already exists")
+	}
+	now := *c.now
+	lease.Spec.RenewTime = &now
+	c.lease = lease
+	return lease, nil
+}
+
+func (c *fakeLeaseClient) Update(ctx context.Context, lease *core.Lease) (*core.Lease, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	holder := lease.Spec.HolderIdentity
+	if holder != "" && holder != c.lease.Spec.HolderIdentity && !c.lease.IsExpired(*c.now) {
+		return nil, fmt.Errorf("held by %s", c.lease.Spec.HolderIdentity)
+	}
+	now := *c.now
+	lease.Spec.RenewTime = &now
+	if holder == "" {
+		lease.Spec.RenewTime = nil
+	}
+	c.lease = lease
+	return lease, nil
+}
+
+func newTestElector(c *fakeLeaseClient, identity string) *LeaderElector {
+	return &LeaderElector{
+		client:   c,
+		identity: identity,
+		config: LeaderElectionConfig{
+			LeaseName:     DefaultLeaseName,
+			LeaseDuration: DefaultLeaseDuration,
+			RenewDeadline: DefaultRenewDeadline,
+			RetryPeriod:   DefaultRetryPeriod,
+		},
+		logger: zap.NewNop(),
+		now:    func() time.Time { return *c.now },
+	}
+}
+
+func TestOnlyOneLeader(t *testing.T) {
+	now := time.Unix(100, 0)
+	c := &fakeLeaseClient{now: &now}
+	e1 := newTestElector(c, "node1")
+	e2 := newTestElector(c, "node2")
+
+	e1.tryAcquireOrRenew(context.Background())
+	e2.tryAcquireOrRenew(context.Background())
+	if !e1.IsLeader() || e2.IsLeader() {
+		t.Fatalf("expected only node1 to be leader")
+	}
+
+	// node1が更新し続けている間はnode2はleaderになれない
+	for i := 0; i < 20; i++ {
+		now = now.Add(DefaultRetryPeriod)
+		e1.tryAcquireOrRenew(context.Background())
+		e2.tryAcquireOrRenew(context.Background())
+		if !e1.IsLeader() || e2.IsLeader() {
+			t.Fatalf("expected only node1 to be leader at %d", i)
+		}
+	}
+}
+
+func TestFailover(t *testing.T) {
+	now := time.Unix(100, 0)
+	c := &fakeLeaseClient{now: &now}
+	e1 := newTestElector(c, "node1")
+	e2 := newTestElector(c, "node2")
+
+	e1.tryAcquireOrRenew(context.Background())
+	e2.tryAcquireOrRenew(context.Background())
+
+	// node1が停止した場合はleaseDuration経過後にnode2が引き継ぐ
+	now = now.Add(DefaultRenewDeadline)
+	if e1.IsLeader() {
+		t.Fatalf("expected node1 to stop being leader after renewDeadline")
+	}
+	e2.tryAcquireOrRenew(context.Background())
+	if e2.IsLeader() {
+		t.Fatalf("expected node2 not to be leader before leaseDuration")
+	}
+
+	now = now.Add(DefaultLeaseDuration)
+	e2.tryAcquireOrRenew(context.Background())
+	if !e2.IsLeader() {
+		t.Fatalf("expected node2 to be leader after leaseDuration")
+	}
+}
+
+func TestRelease(t *testing.T) {
+	now := time.Unix(100, 0)
+	c := &fakeLeaseClient{now: &now}
+	e1 := newTestElector(c, "node1")
+	e2 := newTestElector(c, "node2")
+
+	e1.tryAcquireOrRenew(context.Background())
+	e1.release()
+	if e1.IsLeader() {
+		t.Fatalf("expected node1 not to be leader after release")
+	}
+
+	// 解放された場合は期限を待たずに引き継げる
+	e2.tryAcquireOrRenew(context.Background())
+	if !e2.IsLeader() {
+		t.Fatalf("expected node2 to be leader after release")
+	}
+}
+
+func TestLoseLeadershipWhenRenewFails(t *testing.T) {
+	now := time.Unix(100, 0)
+	c := &fakeLeaseClient{now: &now}
+	e := newTestElector(c, "node1")
+
+	e.tryAcquireOrRenew(context.Background())
+	c.err = fmt.Errorf("connection refused")
+
+	now = now.Add(DefaultRetryPeriod)
+	e.tryAcquireOrRenew(context.Background())
+	if !e.IsLeader() {
+		t.Fatalf("expected node1 to be leader until renewDeadline")
+	}
+
+	now = now.Add(DefaultRenewDeadline)
+	e.tryAcquireOrRenew(context.Background())
+	if e.IsLeader() {
+		t.Fatalf("expected node1 to lose leadership after renewDeadline")
+	}
+}
+
+func TestNilElectorIsLeader(t *testing.T) {
+	var e *LeaderElector
+	if !e.IsLeader() {
+		t.Fatalf("expected nil elector to be leader")
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
@@ -17,12 +20,14 @@ import (
 )
 
 type BlockStorageAgent struct {
-	client                     *client.Clients
+	client                     client.Interface
 	config                     *BlockStorageAgentConfig
 	localBlockStorageDirectory string
 	localImageDirectory        string
 	parallelSemaphore          *semaphore.Weighted
 	logger                     *zap.Logger
+	health                     *health.Reporter
+	recorder                   *event.Recorder
 }
 
 const (
@@ -30,12 +35,16 @@ const (
 	BlockStorageV0AnnotationNodeName = "blockstoragev0/node_name"
 )
 
+const (
+	BlockStorageV0FinalizerName = "blockstoragev0/blockstorage-agent"
+)
+
 const (
 	BlockStorageV0BlockStorageTypeLocal = "Local"
 	BlockStorageV0BlockStorageTypeCeph  = "Ceph"
 )
 
-func NewBlockStorageAgent(client *client.Clients, config *BlockStorageAgentConfig, logger *zap.Logger) *BlockStorageAgent {
+func NewBlockStorageAgent(client client.Interface, config *BlockStorageAgentConfig, logger *zap.Logger) *BlockStorageAgent {
 	return &BlockStorageAgent{
 		client:                     client,
 		config:                     config,
@@ -46,7 +55,15 @@ func NewBlockStorageAgent(client *client.Clients, config *BlockStorageAgentConfi
 	}
 }
 
-func (a *BlockStorageAgent) Run() {
+func (a *BlockStorageAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *BlockStorageAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *BlockStorageAgent) Run(ctx context.Context) {
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
@@ -58,7 +75,7 @@ func (a *BlockStorageAgent) Run() {
 			zap.Time("time", time.Now()))
 	}
 	// init
-	grList, err := a.client.CoreV0().Group().List()
+	grList, err := a.client.CoreV0().Group().List(context.TODO())
 	if err != nil {
 		a.logger.Error(
 			"get group list",
@@ -67,7 +84,7 @@ func (a *BlockStorageAgent) Run() {
 		)
 	}
 	for _, group := range grList {
-		nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+		nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), group.ID)
 		if err != nil {
 			a.logger.Error(
 				"get namespace list",
@@ -77,7 +94,7 @@ func (a *BlockStorageAgent) Run() {
 			continue
 		}
 		for _, ns := range nsList {
-			bsList, err := a.client.SystemV0().BlockStorage().List(group.ID, ns.ID)
+			bsList, err := a.client.SystemV0().BlockStorage().List(context.TODO(), group.ID, ns.ID)
 			if err != nil {
 				a.logger.Error(
 					"get blockstorage list",
@@ -90,7 +107,7 @@ func (a *BlockStorageAgent) Run() {
 				switch bs.Status.State {
 				case system.BlockStorageStateCopying, system.BlockStorageStateDownloading, system.BlockStorageStateDeleting, system.BlockStorageStateQueued:
 					bs.Status.State = ""
-					if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+					if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 						a.logger.Panic(
 							"init state Copying or Downloading or Deleting or Queued => ``",
 							zap.String("msg", err.Error()),
@@ -104,8 +121,10 @@ func (a *BlockStorageAgent) Run() {
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			grList, err := a.client.CoreV0().Group().List(context.TODO())
 			if err != nil {
 				a.logger.Error(
 					"get group list",
@@ -117,7 +136,7 @@ func (a *BlockStorageAgent) Run() {
 
 			wg := sync.WaitGroup{}
 			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+				nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), group.ID)
 				if err != nil {
 					a.logger.Error(
 						"get namespace list",
@@ -128,7 +147,7 @@ func (a *BlockStorageAgent) Run() {
 				}
 
 				for _, ns := range nsList {
-					bsList, err := a.client.SystemV0().BlockStorage().List(group.ID, ns.ID)
+					bsList, err := a.client.SystemV0().BlockStorage().List(context.TODO(), group.ID, ns.ID)
 					if err != nil {
 						a.logger.Error(
 							"get blockstorage list",
@@ -138,7 +157,7 @@ func (a *BlockStorageAgent) Run() {
 						continue
 					}
 
-					vmList, err := a.client.SystemV0().VirtualMachine().List(group.ID, ns.ID)
+					vmList, err := a.client.SystemV0().VirtualMachine().List(context.TODO(), group.ID, ns.ID)
 					if err != nil {
 						a.logger.Error(
 							"get virtualmahine list",
@@ -155,7 +174,7 @@ func (a *BlockStorageAgent) Run() {
 					}
 
 					for _, bs := range bsList {
-						if bs.DeleteState != meta.DeleteStateDelete && bs.Status.State == system.BlockStorageStateQueued {
+						if !bs.IsDeleting() && bs.Status.State == system.BlockStorageStateQueued {
 							continue
 						}
 
@@ -171,7 +190,7 @@ func (a *BlockStorageAgent) Run() {
 
 						//if bs.Status.State == "" {
 						//	bs.Status.State = system.BlockStorageStateQueued
-						//	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+						//	if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 						//		a.logger.Error(
 						//			"update blockstorage state",
 						//			zap.String("msg", err.Error()),
@@ -190,7 +209,9 @@ func (a *BlockStorageAgent) Run() {
 							oldHash := bs.ResourceHash
 
 							// state check
-							if bs.Status.State != system.BlockStorageStateDeleting &&
+							// 他のノードのBSはそのノードのagentが更新する
+							if bs.Annotations[BlockStorageV0AnnotationNodeName] == nodeName &&
+								bs.Status.State != system.BlockStorageStateDeleting &&
 								bs.Status.State != system.BlockStorageStatePending &&
 								bs.Status.State != system.BlockStorageStateQueued {
 
@@ -205,7 +226,7 @@ func (a *BlockStorageAgent) Run() {
 
 								if bs.Status.State != system.BlockStorageStateUsed && isUsed {
 									bs.Status.State = system.BlockStorageStateUsed
-									_, err := a.client.SystemV0().BlockStorage().Update(bs)
+									_, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs)
 									if err != nil {
 										a.logger.Error(
 											"update blockstorage",
@@ -216,7 +237,7 @@ func (a *BlockStorageAgent) Run() {
 									}
 								} else if bs.Status.State == system.BlockStorageStateUsed && !isUsed {
 									bs.Status.State = system.BlockStorageStateActive
-									bs, err = a.client.SystemV0().BlockStorage().Update(bs)
+									bs, err = a.client.SystemV0().BlockStorage().Update(context.TODO(), bs)
 									if err != nil {
 										a.logger.Error(
 											"update blockstorage",
@@ -241,6 +262,8 @@ func (a *BlockStorageAgent) Run() {
 										zap.String("msg", err.Error()),
 										zap.Time("time", time.Now()),
 									)
+									a.recorder.Event(bs.Meta, core.EventTypeWarning, "SyncFailed", err.Error())
+									a.saveCondition(bs, oldHash, err)
 									return
 								}
 
@@ -256,6 +279,14 @@ func (a *BlockStorageAgent) Run() {
 										zap.String("msg", err.Error()),
 										zap.Time("time", time.Now()),
 									)
+									a.recorder.Event(bs.Meta, core.EventTypeWarning, "SyncFailed", err.Error())
+									a.saveCondition(bs, oldHash, err)
+									return
+								}
+							}
+
+							if setProvisionedCondition(bs, nil) {
+								if err := setHash(bs); err != nil {
 									return
 								}
 							}
@@ -264,7 +295,7 @@ func (a *BlockStorageAgent) Run() {
 								return
 							}
 
-							_, err := a.client.SystemV0().BlockStorage().Update(bs)
+							_, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs)
 							if err != nil {
 								a.logger.Error(
 									"update blockstorage",
@@ -278,10 +309,68 @@ func (a *BlockStorageAgent) Run() {
 				}
 			}
 			wg.Wait()
+
+			a.health.Reconciled()
 		}
 	}
 }
 
+// ディスクを削除するまでblockstorageが消えないようにする
+func (a *BlockStorageAgent) addFinalizer(bs *system.BlockStorage) error {
+	if bs.IsDeleting() || !bs.AddFinalizer(BlockStorageV0FinalizerName) {
+		return nil
+	}
+	_, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs)
+	return err
+}
+
+func (a *BlockStorageAgent) removeFinalizer(bs *system.BlockStorage) error {
+	bs.RemoveFinalizer(BlockStorageV0FinalizerName)
+	_, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs)
+	return err
+}
+
+// saveCondition は同期に失敗した場合もconditionを保存する
+func (a *BlockStorageAgent) saveCondition(bs *system.BlockStorage, oldHash string, syncErr error) {
+	setProvisionedCondition(bs, syncErr)
+	if err := setHash(bs); err != nil || bs.ResourceHash == oldHash {
+		return
+	}
+
+	if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
+		a.logger.Error(
+			"update blockstorage conditions",
+			zap.String("msg", err.Error()),
+			zap.Time("time", time.Now()),
+		)
+	}
+}
+
+// setProvisionedCondition はstateと同期の結果からProvisionedのconditionを設定する
+// 変更があった場合にtrueを返す
+func setProvisionedCondition(bs *system.BlockStorage, syncErr error) bool {
+	condition := meta.Condition{
+		Type:               system.BlockStorageConditionProvisioned,
+		Status:             meta.ConditionFalse,
+		Reason:             string(bs.Status.State),
+		ObservedGeneration: bs.Generation,
+	}
+
+	switch {
+	case syncErr != nil:
+		condition.Reason = "SyncFailed"
+		condition.Message = syncErr.Error()
+	case bs.Status.State == system.BlockStorageStateActive, bs.Status.State == system.BlockStorageStateUsed:
+		condition.Status = meta.ConditionTrue
+		condition.Reason = "Provisioned"
+	case bs.Status.State == "":
+		condition.Status = meta.ConditionUnknown
+		condition.Reason = "Unknown"
+	}
+
+	return meta.SetCondition(&bs.Status.Conditions, condition)
+}
+
 func setHash(bs *system.BlockStorage) error {
 	bs.ResourceHash = ""
 	resourceJSON, err := json.Marshal(bs)
@@ -1,6 +1,10 @@
+//go:build ceph
+// +build ceph
+
 package blockstorage
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -12,7 +16,7 @@ import (
 
 	"github.com/ceph/go-ceph/rados"
 	"github.com/ceph/go-ceph/rbd"
-	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/pkg/errors"
 )
@@ -32,8 +36,12 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		return nil
 	}
 
+	if err := a.addFinalizer(bs); err != nil {
+		return err
+	}
+
 	// 削除処理
-	if bs.DeleteState == meta.DeleteStateDelete {
+	if bs.IsDeleting() {
 		return a.deleteCephBlockStorage(bs)
 	}
 
@@ -80,7 +88,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 			if !poolOk || !imageOk {
 				bs.Annotations["ceph-pool-name"] = a.config.CephBackend.PoolName
 				bs.Annotations["ceph-image-name"] = imageNameWithGroupAndNS
-				if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+				if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 					return err
 				}
 			}
@@ -94,7 +102,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 
 	bs.Annotations["ceph-pool-name"] = a.config.CephBackend.PoolName
 	bs.Annotations["ceph-image-name"] = imageNameWithGroupAndNS
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 		return err
 	}
 
@@ -112,7 +120,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		cmd := exec.Command(command, args...)
 		if _, err := cmd.CombinedOutput(); err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
@@ -210,31 +218,23 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 			return err
 		}
 
-		image, err := a.client.SystemV0().Image().Get(bs.Group, bs.Spec.From.BaseImage.ImageName)
+		// tagが指すimageEntityを探す
+		imageEntity, err := a.client.SystemV0().ResolveImageEntityID(context.TODO(), bs.Group, bs.Spec.From.BaseImage.ImageName, bs.Spec.From.BaseImage.Tag)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
 		}
 
-		imageEntity, ok := image.Spec.EntityMap[bs.Spec.From.BaseImage.Tag]
-		if !ok {
-			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
-				return err
-			}
-			return fmt.Errorf("Image Entity not found")
-		}
-
 		// imageEntityがlocalにある場合
 		// TODO: imageEntityがCephにある場合
 		srcDirPath := filepath.Join(a.localImageDirectory, bs.Group)
 		if !fileIsExists(srcDirPath) {
 			if err := os.MkdirAll(srcDirPath, 0755); err != nil {
 				bs.Status.State = system.BlockStorageStateError
-				if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+				if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 					return err
 				}
 				return err
@@ -252,7 +252,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 				}
 				defer src.Close()
 
-				stream, err := a.client.SystemV0().Image().Download(bs.Group, bs.Spec.From.BaseImage.ImageName, bs.Spec.From.BaseImage.Tag)
+				stream, err := a.client.SystemV0().Image().Download(context.TODO(), bs.Group, bs.Spec.From.BaseImage.ImageName, bs.Spec.From.BaseImage.Tag)
 				if err != nil {
 					return err
 				}
@@ -358,9 +358,10 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		bs.Status.State == system.BlockStorageStateDownloading {
 		bs.Status.State = system.BlockStorageStateActive
 
-		if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+		if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 			return err
 		}
+		a.recorder.Event(bs.Meta, core.EventTypeNormal, "Provisioned", "blockstorage is active.")
 	}
 	return setHash(bs)
 }
@@ -375,7 +376,7 @@ func (a *BlockStorageAgent) deleteCephBlockStorage(bs *system.BlockStorage) erro
 	}
 
 	bs.Status.State = system.BlockStorageStateDeleting
-	_, err := a.client.SystemV0().BlockStorage().Update(bs)
+	_, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs)
 	if err != nil {
 		return err
 	}
@@ -408,11 +409,7 @@ func (a *BlockStorageAgent) deleteCephBlockStorage(bs *system.BlockStorage) erro
 		}
 	}
 
-	err = a.client.SystemV0().BlockStorage().Delete(bs.Group, bs.Namespace, bs.ID)
-	if err != nil {
-		return err
-	}
-	return nil
+	return a.removeFinalizer(bs)
 }
 
 func (a BlockStorageAgent) cephImageIsExists(bs *system.BlockStorage) bool {
@@ -462,7 +459,7 @@ func (a BlockStorageAgent) newCephConn() (*rados.Conn, error) {
 
 func (a BlockStorageAgent) setStateError(bs *system.BlockStorage) error {
 	bs.Status.State = system.BlockStorageStateError
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 		return err
 	}
 	return nil
@@ -470,7 +467,7 @@ func (a BlockStorageAgent) setStateError(bs *system.BlockStorage) error {
 
 func (a BlockStorageAgent) setStateCopying(bs *system.BlockStorage) error {
 	bs.Status.State = system.BlockStorageStateCopying
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 		return err
 	}
 	return nil
@@ -478,7 +475,7 @@ func (a BlockStorageAgent) setStateCopying(bs *system.BlockStorage) error {
 
 func (a BlockStorageAgent) setStateDownloading(bs *system.BlockStorage) error {
 	bs.Status.State = system.BlockStorageStateDownloading
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 		return err
 	}
 	return nil
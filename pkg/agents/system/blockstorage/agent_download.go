@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 )
 
 func (a *BlockStorageAgent) DownloadAPI(config *BlockStorageAgentDownloadAPIConfig) error {
@@ -47,7 +48,7 @@ func (a *BlockStorageAgent) DownloadAPI(config *BlockStorageAgentDownloadAPIConf
 		}
 	})
 
-	if err := r.Run(fmt.Sprintf("%s:%d", config.AdvertiseAddress, config.ListenPort)); err != nil {
+	if err := tlsutil.ListenAndServe(fmt.Sprintf("%s:%d", config.AdvertiseAddress, config.ListenPort), r, &config.TLS); err != nil {
 		return err
 	}
 
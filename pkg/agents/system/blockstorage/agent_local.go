@@ -1,6 +1,7 @@
 package blockstorage
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -10,7 +11,7 @@ import (
 	"path/filepath"
 	"strconv"
 
-	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/pkg/errors"
 )
@@ -26,8 +27,12 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		return nil
 	}
 
+	if err := a.addFinalizer(bs); err != nil {
+		return err
+	}
+
 	// 削除処理
-	if bs.DeleteState == meta.DeleteStateDelete {
+	if bs.IsDeleting() {
 		if bs.Status.State != "" &&
 			bs.Status.State != system.BlockStorageStateError &&
 			bs.Status.State != system.BlockStorageStateActive &&
@@ -35,7 +40,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 			return nil
 		}
 		bs.Status.State = system.BlockStorageStateDeleting
-		_, err := a.client.SystemV0().BlockStorage().Update(bs)
+		_, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs)
 		if err != nil {
 			return err
 		}
@@ -47,12 +52,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 			}
 		}
 
-		err = a.client.SystemV0().BlockStorage().Delete(bs.Group, bs.Namespace, bs.ID)
-		if err != nil {
-			return err
-		}
-
-		return nil
+		return a.removeFinalizer(bs)
 	}
 
 	if fileIsExists(path) {
@@ -90,21 +90,21 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		cmd := exec.Command(command, args...)
 		if _, err := cmd.CombinedOutput(); err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
 		}
 	case system.BlockStorageFromTypeHTTP:
 		bs.Status.State = system.BlockStorageStateDownloading
-		if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+		if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 			return err
 		}
 
 		res, err := http.Get(bs.Spec.From.HTTP.URL)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
@@ -114,7 +114,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		file, err := os.Create(path)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
@@ -129,7 +129,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		}
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
@@ -138,7 +138,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		err = file.Close()
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
@@ -153,7 +153,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		cmd := exec.Command(command, args...)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return errors.Wrap(err, string(out))
@@ -161,34 +161,26 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 	case system.BlockStorageFromTypeBaseImage:
 
 		bs.Status.State = system.BlockStorageStateCopying
-		if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+		if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 			return err
 		}
 
-		image, err := a.client.SystemV0().Image().Get(bs.Group, bs.Spec.From.BaseImage.ImageName)
+		// tagが指すimageEntityを探す
+		imageEntity, err := a.client.SystemV0().ResolveImageEntityID(context.TODO(), bs.Group, bs.Spec.From.BaseImage.ImageName, bs.Spec.From.BaseImage.Tag)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
 		}
 
-		imageEntity, ok := image.Spec.EntityMap[bs.Spec.From.BaseImage.Tag]
-		if !ok {
-			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
-				return err
-			}
-			return fmt.Errorf("Image Entity not found")
-		}
-
 		srcDirPath := filepath.Join(a.localImageDirectory, bs.Group)
 		if !fileIsExists(srcDirPath) {
 			err := os.MkdirAll(srcDirPath, 0755)
 			if err != nil {
 				bs.Status.State = system.BlockStorageStateError
-				if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+				if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 					return err
 				}
 				return err
@@ -203,17 +195,17 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 				src, err := os.Create(srcPath)
 				if err != nil {
 					bs.Status.State = system.BlockStorageStateError
-					if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+					if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 						return err
 					}
 					return err
 				}
 				defer src.Close()
 
-				stream, err := a.client.SystemV0().Image().Download(bs.Group, bs.Spec.From.BaseImage.ImageName, bs.Spec.From.BaseImage.Tag)
+				stream, err := a.client.SystemV0().Image().Download(context.TODO(), bs.Group, bs.Spec.From.BaseImage.ImageName, bs.Spec.From.BaseImage.Tag)
 				if err != nil {
 					bs.Status.State = system.BlockStorageStateError
-					if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+					if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 						return err
 					}
 					return err
@@ -222,7 +214,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 
 				if _, err := io.Copy(src, stream); err != nil {
 					bs.Status.State = system.BlockStorageStateError
-					if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+					if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 						return err
 					}
 					return err
@@ -231,7 +223,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 			}()
 			if err != nil {
 				bs.Status.State = system.BlockStorageStateError
-				if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+				if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 					return err
 				}
 				return err
@@ -240,7 +232,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		src, err := os.Open(srcPath)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
@@ -250,7 +242,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		dest, err := os.Create(path)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
@@ -259,7 +251,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 
 		if _, err := io.Copy(dest, src); err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
@@ -275,7 +267,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		if _, err := cmd.CombinedOutput(); err != nil {
 			log.Println(err.Error())
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 				return err
 			}
 			return err
@@ -287,6 +279,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 	}
 
 	bs.Annotations["bs-download-host"] = fmt.Sprintf("%s:%d", a.config.DownloadAPI.AdvertiseAddress, a.config.DownloadAPI.ListenPort)
+	bs.Annotations["bs-download-scheme"] = a.config.DownloadAPI.TLS.Scheme()
 	// ここに来た時点で処理は終わっているのでActiveにする
 	if bs.Status.State == "" ||
 		bs.Status.State == system.BlockStorageStatePending ||
@@ -294,9 +287,10 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		bs.Status.State == system.BlockStorageStateDownloading {
 		bs.Status.State = system.BlockStorageStateActive
 
-		if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+		if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 			return err
 		}
+		a.recorder.Event(bs.Meta, core.EventTypeNormal, "Provisioned", "blockstorage is active.")
 	}
 	return setHash(bs)
 }
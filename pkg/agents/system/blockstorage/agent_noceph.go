@@ -0,0 +1,16 @@
+//go:build !ceph
+// +build !ceph
+
+package blockstorage
+
+import (
+	"fmt"
+
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+// syncCephBlockStorage はcephタグなしでビルドした場合はエラーを返す
+// librados, librbdがない環境でもagentのパッケージをビルド、テストできるようにするためのもの
+func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error {
+	return fmt.Errorf("ceph backend is not supported: build with `-tags ceph`")
+}
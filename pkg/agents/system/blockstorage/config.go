@@ -1,9 +1,13 @@
 package blockstorage
 
+import "github.com/ophum/humstack/pkg/utils/tlsutil"
+
 type BlockStorageAgentDownloadAPIConfig struct {
 	AdvertiseAddress string `yaml:"advertiseAddress"`
 	ListenAddress    string `yaml:"listenAddress"`
 	ListenPort       int32  `yaml:"listenPort"`
+	// 指定した場合はTLSで待ち受ける
+	TLS tlsutil.ServerConfig `yaml:"tls"`
 }
 
 type BlockStorageAgentCephBackendConfig struct {
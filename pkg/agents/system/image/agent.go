@@ -1,6 +1,7 @@
 package image
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/json"
@@ -10,9 +11,10 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/ceph/go-ceph/rados"
-	"github.com/ceph/go-ceph/rbd"
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
 	"github.com/ophum/humstack/pkg/agents/system/blockstorage"
+	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
@@ -21,23 +23,30 @@ import (
 )
 
 type ImageAgent struct {
-	client                     *client.Clients
+	client                     client.Interface
 	logger                     *zap.Logger
 	nodeName                   string
 	config                     *ImageAgentConfig
 	localImageDirectory        string
 	localBlockStorageDirectory string
+	health                     *health.Reporter
+	recorder                   *event.Recorder
 }
 
 const (
-	ImageEntityV0AnnotationType = "imageentityv0/type"
+	ImageEntityV0AnnotationType     = "imageentityv0/type"
+	ImageEntityV0AnnotationNodeName = "imageentityv0/node_name"
+)
+
+const (
+	ImageEntityV0FinalizerName = "imageentityv0/image-agent"
 )
 
 const (
 	ImageEntityV0ImageEntityTypeLocal = "Local"
 )
 
-func NewImageAgent(client *client.Clients, config *ImageAgentConfig, logger *zap.Logger) *ImageAgent {
+func NewImageAgent(client client.Interface, config *ImageAgentConfig, logger *zap.Logger) *ImageAgent {
 	nodeName, err := os.Hostname()
 	if err != nil {
 		logger.Panic(
@@ -56,15 +65,25 @@ func NewImageAgent(client *client.Clients, config *ImageAgentConfig, logger *zap
 	}
 }
 
-func (a *ImageAgent) Run() {
+func (a *ImageAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *ImageAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *ImageAgent) Run(ctx context.Context) {
 
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			grList, err := a.client.CoreV0().Group().List(context.TODO())
 			if err != nil {
 				a.logger.Error(
 					"get group list",
@@ -75,7 +94,7 @@ func (a *ImageAgent) Run() {
 			}
 
 			for _, group := range grList {
-				imageEntityList, err := a.client.SystemV0().ImageEntity().List(group.ID)
+				imageEntityList, err := a.client.SystemV0().ImageEntity().List(context.TODO(), group.ID)
 				if err != nil {
 					a.logger.Error(
 						"get imageentity list",
@@ -88,11 +107,31 @@ func (a *ImageAgent) Run() {
 				for _, imageEntity := range imageEntityList {
 					oldHash := imageEntity.ResourceHash
 
+					// 削除処理
+					// コピー元のBSが消えていても削除できるように作成したノードで処理する
+					if imageEntity.IsDeleting() {
+						if !imageEntity.HasFinalizer(ImageEntityV0FinalizerName) ||
+							imageEntity.Annotations[ImageEntityV0AnnotationNodeName] != a.nodeName {
+							continue
+						}
+
+						if err := a.deleteLocalImageEntity(imageEntity); err != nil {
+							a.logger.Error(
+								"delete local imageentity",
+								zap.String("msg", err.Error()),
+								zap.Time("time", time.Now()),
+							)
+							a.recorder.Event(imageEntity.Meta, core.EventTypeWarning, "DeleteFailed", err.Error())
+						}
+						continue
+					}
+
 					if imageEntity.Status.State == system.ImageEntityStateAvailable {
 						continue
 					}
 
 					bs, err := a.client.SystemV0().BlockStorage().Get(
+						context.TODO(),
 						imageEntity.Group,
 						imageEntity.Spec.Source.Namespace,
 						imageEntity.Spec.Source.BlockStorageID)
@@ -119,7 +158,7 @@ func (a *ImageAgent) Run() {
 					}
 
 					// とりあえずPending以外になってたら何もしない
-					if imageEntity.Status.State != "" && imageEntity.Status.State != system.ImageEntityStatePending && imageEntity.DeleteState != meta.DeleteStateDelete {
+					if imageEntity.Status.State != "" && imageEntity.Status.State != system.ImageEntityStatePending {
 						continue
 					}
 
@@ -136,6 +175,17 @@ func (a *ImageAgent) Run() {
 								zap.String("msg", err.Error()),
 								zap.Time("time", time.Now()),
 							)
+							a.recorder.Event(imageEntity.Meta, core.EventTypeWarning, "SyncFailed", err.Error())
+
+							// 失敗した理由をconditionに残す
+							setCondition(imageEntity, meta.ConditionFalse, "SyncFailed", err.Error())
+							if _, err := a.client.SystemV0().ImageEntity().Update(context.TODO(), imageEntity); err != nil {
+								a.logger.Error(
+									"update imageentity conditions",
+									zap.String("msg", err.Error()),
+									zap.Time("time", time.Now()),
+								)
+							}
 							continue
 						}
 					}
@@ -144,7 +194,7 @@ func (a *ImageAgent) Run() {
 						continue
 					}
 
-					if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+					if _, err := a.client.SystemV0().ImageEntity().Update(context.TODO(), imageEntity); err != nil {
 						a.logger.Error(
 							"update imageentity",
 							zap.String("msg", err.Error()),
@@ -154,39 +204,16 @@ func (a *ImageAgent) Run() {
 					}
 				}
 			}
+
+			a.health.Reconciled()
 		}
 	}
 }
 
 // 同じノードにあるBSを元にイメージを作成する
 func (a *ImageAgent) syncLocalImageEntity(imageEntity *system.ImageEntity, bs *system.BlockStorage) error {
-
-	if imageEntity.DeleteState == meta.DeleteStateDelete {
-		if imageEntity.Status.State != system.ImageEntityStateAvailable {
-			return nil
-		}
-
-		imageEntity.Status.State = system.ImageEntityStateDeleting
-		if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
-			return err
-		}
-
-		path := filepath.Join(a.localImageDirectory, imageEntity.Group, imageEntity.ID)
-		if fileIsExists(path) {
-			if err := os.Remove(path); err != nil {
-				return err
-			}
-		}
-
-		if err := a.client.SystemV0().ImageEntity().Delete(imageEntity.Group, imageEntity.ID); err != nil {
-			return err
-		}
-
-		return nil
-	}
-
 	imageEntity.Status.State = system.ImageEntityStatePending
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().Update(context.TODO(), imageEntity); err != nil {
 		return err
 	}
 
@@ -195,11 +222,11 @@ func (a *ImageAgent) syncLocalImageEntity(imageEntity *system.ImageEntity, bs *s
 	//}
 
 	imageEntity.Status.State = system.ImageEntityStateCopying
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().Update(context.TODO(), imageEntity); err != nil {
 		return err
 	}
 	bs.Status.State = system.BlockStorageStateCopying
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 		return err
 	}
 
@@ -211,42 +238,13 @@ func (a *ImageAgent) syncLocalImageEntity(imageEntity *system.ImageEntity, bs *s
 			return fmt.Errorf("ceph-image-name not found")
 		}
 
-		conn, err := a.newCephConn()
-		if err != nil {
-			return errors.Wrap(err, "new ceph conn")
-		}
-		defer conn.Shutdown()
-
-		ioctx, err := conn.OpenIOContext(a.config.CephBackend.PoolName)
+		image, imageSize, err := a.openCephImage(imageName)
 		if err != nil {
-			return errors.Wrap(err, "open io context")
-		}
-		defer ioctx.Destroy()
-
-		if image, err := rbd.OpenImageReadOnly(ioctx, imageName, ""); err != nil {
-			return errors.Wrapf(err, "open rbd image `%s`", imageName)
-		} else {
-			defer image.Close()
-			src = image
-
-			limitSize, err := image.GetSize()
-			if err != nil {
-				return err
-			}
-
-			sum := uint64(0)
-			if err := image.DiffIterate(rbd.DiffIterateConfig{
-				Offset: 0,
-				Length: limitSize,
-				Callback: func(o, l uint64, e int, x interface{}) int {
-					sum += l
-					return 0
-				},
-			}); err != nil {
-				return errors.Wrap(err, "calc rbd size")
-			}
-			size = int64(sum)
+			return err
 		}
+		defer image.Close()
+		src = image
+		size = imageSize
 	} else {
 		srcPath := filepath.Join(a.localBlockStorageDirectory, bs.Group, bs.Namespace, bs.ID)
 		s, err := os.Open(srcPath)
@@ -293,19 +291,53 @@ func (a *ImageAgent) syncLocalImageEntity(imageEntity *system.ImageEntity, bs *s
 		imageEntity.Annotations = map[string]string{}
 	}
 	imageEntity.Annotations["image-entity-download-host"] = fmt.Sprintf("%s:%d", a.config.DownloadAPI.AdvertiseAddress, a.config.DownloadAPI.ListenPort)
+	imageEntity.Annotations["image-entity-download-scheme"] = a.config.DownloadAPI.TLS.Scheme()
+	imageEntity.Annotations[ImageEntityV0AnnotationNodeName] = a.nodeName
+	// イメージファイルを削除するまでimageEntityが消えないようにする
+	imageEntity.AddFinalizer(ImageEntityV0FinalizerName)
 	imageEntity.Status.State = system.ImageEntityStateAvailable
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	setCondition(imageEntity, meta.ConditionTrue, "Available", "")
+	if _, err := a.client.SystemV0().ImageEntity().Update(context.TODO(), imageEntity); err != nil {
 		return err
 	}
+	a.recorder.Eventf(imageEntity.Meta, core.EventTypeNormal, "Available", "image was created from blockstorage `%s`.", bs.ID)
 
 	bs.Status.State = system.BlockStorageStateActive
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().Update(context.TODO(), bs); err != nil {
 		return err
 	}
 
 	return setHash(imageEntity)
 }
 
+func (a *ImageAgent) deleteLocalImageEntity(imageEntity *system.ImageEntity) error {
+	imageEntity.Status.State = system.ImageEntityStateDeleting
+	if _, err := a.client.SystemV0().ImageEntity().Update(context.TODO(), imageEntity); err != nil {
+		return err
+	}
+
+	path := filepath.Join(a.localImageDirectory, imageEntity.Group, imageEntity.ID)
+	if fileIsExists(path) {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	imageEntity.RemoveFinalizer(ImageEntityV0FinalizerName)
+	_, err := a.client.SystemV0().ImageEntity().Update(context.TODO(), imageEntity)
+	return err
+}
+
+func setCondition(imageEntity *system.ImageEntity, status meta.ConditionStatus, reason, message string) {
+	meta.SetCondition(&imageEntity.Status.Conditions, meta.Condition{
+		Type:               system.ImageEntityConditionImageReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: imageEntity.Generation,
+	})
+}
+
 func setHash(imageEntity *system.ImageEntity) error {
 	imageEntity.ResourceHash = ""
 	resourceJSON, err := json.Marshal(imageEntity)
@@ -318,23 +350,6 @@ func setHash(imageEntity *system.ImageEntity) error {
 	return nil
 }
 
-// TODO: BlockStorageAgentにも同じ実装がある
-func (a ImageAgent) newCephConn() (*rados.Conn, error) {
-	cephConn, err := rados.NewConn()
-	if err != nil {
-		return nil, err
-	}
-
-	if err := cephConn.ReadConfigFile(a.config.CephBackend.ConfigPath); err != nil {
-		return nil, err
-	}
-
-	if err := cephConn.Connect(); err != nil {
-		return nil, err
-	}
-	return cephConn, nil
-}
-
 func fileIsExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
@@ -0,0 +1,86 @@
+//go:build ceph
+// +build ceph
+
+package image
+
+import (
+	"io"
+
+	"github.com/ceph/go-ceph/rados"
+	"github.com/ceph/go-ceph/rbd"
+	"github.com/pkg/errors"
+)
+
+// cephImage は読み込みが終わったらioctxとconnも閉じる
+type cephImage struct {
+	*rbd.Image
+	conn  *rados.Conn
+	ioctx *rados.IOContext
+}
+
+func (i *cephImage) Close() error {
+	err := i.Image.Close()
+	i.ioctx.Destroy()
+	i.conn.Shutdown()
+	return err
+}
+
+// openCephImage はrbd imageを読み込み専用で開き、使用している領域のサイズと一緒に返す
+func (a *ImageAgent) openCephImage(imageName string) (io.ReadCloser, int64, error) {
+	conn, err := a.newCephConn()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "new ceph conn")
+	}
+
+	ioctx, err := conn.OpenIOContext(a.config.CephBackend.PoolName)
+	if err != nil {
+		conn.Shutdown()
+		return nil, 0, errors.Wrap(err, "open io context")
+	}
+
+	image, err := rbd.OpenImageReadOnly(ioctx, imageName, "")
+	if err != nil {
+		ioctx.Destroy()
+		conn.Shutdown()
+		return nil, 0, errors.Wrapf(err, "open rbd image `%s`", imageName)
+	}
+	ci := &cephImage{Image: image, conn: conn, ioctx: ioctx}
+
+	limitSize, err := image.GetSize()
+	if err != nil {
+		ci.Close()
+		return nil, 0, err
+	}
+
+	sum := uint64(0)
+	if err := image.DiffIterate(rbd.DiffIterateConfig{
+		Offset: 0,
+		Length: limitSize,
+		Callback: func(o, l uint64, e int, x interface{}) int {
+			sum += l
+			return 0
+		},
+	}); err != nil {
+		ci.Close()
+		return nil, 0, errors.Wrap(err, "calc rbd size")
+	}
+
+	return ci, int64(sum), nil
+}
+
+// TODO: BlockStorageAgentにも同じ実装がある
+func (a ImageAgent) newCephConn() (*rados.Conn, error) {
+	cephConn, err := rados.NewConn()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cephConn.ReadConfigFile(a.config.CephBackend.ConfigPath); err != nil {
+		return nil, err
+	}
+
+	if err := cephConn.Connect(); err != nil {
+		return nil, err
+	}
+	return cephConn, nil
+}
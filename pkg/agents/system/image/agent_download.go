@@ -1,6 +1,7 @@
 package image
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 	"go.uber.org/zap"
 )
 
@@ -25,13 +27,8 @@ func (a *ImageAgent) DownloadAPI(config *ImageAgentDownloadAPIConfig) error {
 		imageID := ctx.Param("image_id")
 		tag := ctx.Param("tag")
 
-		image, err := a.client.SystemV0().Image().Get(groupID, imageID)
+		imageEntityID, err := a.client.SystemV0().ResolveImageEntityID(context.TODO(), groupID, imageID, tag)
 		if err != nil {
-			ctx.String(http.StatusInternalServerError, "%v", err)
-			return
-		}
-		imageEntityID, ok := image.Spec.EntityMap[tag]
-		if !ok {
 			ctx.String(http.StatusNotFound, "notfound")
 			return
 		}
@@ -64,7 +61,7 @@ func (a *ImageAgent) DownloadAPI(config *ImageAgentDownloadAPIConfig) error {
 		}
 	})
 
-	if err := r.Run(fmt.Sprintf("%s:%d", config.AdvertiseAddress, config.ListenPort)); err != nil {
+	if err := tlsutil.ListenAndServe(fmt.Sprintf("%s:%d", config.AdvertiseAddress, config.ListenPort), r, &config.TLS); err != nil {
 		return err
 	}
 
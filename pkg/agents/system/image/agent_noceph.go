@@ -0,0 +1,14 @@
+//go:build !ceph
+// +build !ceph
+
+package image
+
+import (
+	"fmt"
+	"io"
+)
+
+// openCephImage はcephタグなしでビルドした場合は使えない
+func (a *ImageAgent) openCephImage(imageName string) (io.ReadCloser, int64, error) {
+	return nil, 0, fmt.Errorf("ceph backend is not supported: build with `-tags ceph`")
+}
@@ -1,8 +1,12 @@
 package image
 
+import "github.com/ophum/humstack/pkg/utils/tlsutil"
+
 type ImageAgentDownloadAPIConfig struct {
 	AdvertiseAddress string `yaml:"advertiseAddress"`
 	ListenPort       int32  `yaml:"listenPort"`
+	// 指定した場合はTLSで待ち受ける
+	TLS tlsutil.ServerConfig `yaml:"tls"`
 }
 
 type ImageAgentCephBackendConfig struct {
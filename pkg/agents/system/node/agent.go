@@ -1,39 +1,99 @@
 package node
 
 import (
-	"fmt"
-	"strconv"
+	"context"
+	"reflect"
+	"runtime"
 	"time"
 
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/utils/quantity"
 	"go.uber.org/zap"
 )
 
+const (
+	// ノードのCPUアーキテクチャ。qemu-system-<arch>の名前を使う
+	NodeV0AnnotationArch = "nodev0/arch"
+)
+
+const (
+	// ノードのagentが動いていることを示すleaseのidの接頭辞
+	NodeLeasePrefix = "node-"
+	// leaseに書き込む期限。ノードをNotReadyにするまでの時間はcoreのnodelifecycle agentの設定で決める
+	NodeLeaseDuration = time.Second * 40
+)
+
+// LeaseName はノードのheartbeatに使うleaseのidを返す
+func LeaseName(nodeName string) string {
+	return NodeLeasePrefix + nodeName
+}
+
+// Arch はagentが動作しているCPUアーキテクチャを
+// virtualmachinev0/archと同じ名前で返す
+func Arch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	}
+	return runtime.GOARCH
+}
+
 type NodeAgent struct {
-	client   *client.Clients
+	client   client.Interface
 	NodeInfo *system.Node
+	config   *NodeAgentConfig
+	detector *capacityDetector
 	logger   *zap.Logger
+	health   *health.Reporter
+	recorder *event.Recorder
 }
 
-func NewNodeAgent(node *system.Node, client *client.Clients, logger *zap.Logger) *NodeAgent {
+func NewNodeAgent(node *system.Node, client client.Interface, config *NodeAgentConfig, logger *zap.Logger) *NodeAgent {
 	return &NodeAgent{
 		NodeInfo: node,
 		client:   client,
+		config:   config,
+		detector: newCapacityDetector(),
 		logger:   logger,
 	}
 }
 
-func (a *NodeAgent) Run() {
+func (a *NodeAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *NodeAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *NodeAgent) Run(ctx context.Context) {
 
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			node, err := a.client.SystemV0().Node().Get(a.NodeInfo.Name)
-			if err != nil {
+			// nodeの更新に失敗しても、agentが動いていることは伝える
+			if err := a.renewLease(ctx); err != nil {
+				a.logger.Error(
+					"renew node lease",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+			}
+
+			node, err := a.client.SystemV0().Node().Get(context.TODO(), a.NodeInfo.Name)
+			if err != nil && !meta.IsNotFound(err) {
 				a.logger.Error(
 					"get node",
 					zap.String("msg", err.Error()),
@@ -42,8 +102,8 @@ func (a *NodeAgent) Run() {
 				continue
 			}
 
-			if node.ID == "" {
-				node, err = a.client.SystemV0().Node().Create(a.NodeInfo)
+			if meta.IsNotFound(err) {
+				node, err = a.client.SystemV0().Node().Create(context.TODO(), a.NodeInfo)
 				if err != nil {
 					a.logger.Error(
 						"create node",
@@ -54,20 +114,7 @@ func (a *NodeAgent) Run() {
 				}
 
 				a.NodeInfo = node
-			}
-
-			if node.Status.State == system.NodeStateNotReady ||
-				node.Status.State == "" {
-				node.Status.State = system.NodeStateReady
-				node, err = a.client.SystemV0().Node().Update(node)
-				if err != nil {
-					a.logger.Error(
-						"update node",
-						zap.String("msg", err.Error()),
-						zap.Time("time", time.Now()),
-					)
-					continue
-				}
+				a.recorder.Event(node.Meta, core.EventTypeNormal, "Registered", "node was registered.")
 			}
 
 			res, err := a.getUsedResources()
@@ -77,31 +124,173 @@ func (a *NodeAgent) Run() {
 					zap.String("msg", err.Error()),
 					zap.Time("time", time.Now()),
 				)
-			} else {
-				if node.Status.RequestedVcpus != res[ResourceTypeRequestVcpus] ||
-					node.Status.RequestedMemory != res[ResourceTypeRequestMemory] ||
-					node.Status.RequestedDisk != res[ResourceTypeRequestDisk] {
-					node.Status.RequestedVcpus = res[ResourceTypeRequestVcpus]
-					node.Status.RequestedMemory = res[ResourceTypeRequestMemory]
-					node.Status.RequestedDisk = res[ResourceTypeRequestDisk]
-
-					node, err = a.client.SystemV0().Node().Update(node)
-					if err != nil {
-						a.logger.Error(
-							"update node",
-							zap.String("msg", err.Error()),
-							zap.Time("time", time.Now()),
-						)
-						continue
-					}
+			}
 
+			// 検出に失敗した場合は前回の値を残す
+			capacity, alloc, err := a.detectCapacity()
+			if err != nil {
+				a.logger.Error(
+					"detect node capacity",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+			}
+
+			becameReady := false
+			node, err = a.updateNode(context.TODO(), func(node *system.Node) bool {
+				changed := a.setMeta(node)
+				becameReady = setReady(node)
+				if res != nil && setRequested(node, res) {
+					changed = true
+				}
+				if capacity != nil && setCapacity(node, capacity, alloc) {
+					changed = true
 				}
+				return changed || becameReady
+			})
+			if err != nil {
+				a.logger.Error(
+					"update node",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
+			}
+			if becameReady {
+				a.recorder.Event(node.Meta, core.EventTypeNormal, "NodeReady", "node is ready.")
 			}
 
 			a.NodeInfo = node
+
+			a.health.Reconciled()
+		}
+	}
+
+}
+
+// renewLease はノードのleaseを更新する
+// renewTimeはapiserverが更新した時刻を設定する
+func (a *NodeAgent) renewLease(ctx context.Context) error {
+	leaseName := LeaseName(a.NodeInfo.Name)
+	lease, err := a.client.CoreV0().Lease().Get(ctx, leaseName)
+	if err != nil && !meta.IsNotFound(err) {
+		return err
+	}
+
+	if meta.IsNotFound(err) {
+		_, err := a.client.CoreV0().Lease().Create(ctx, &core.Lease{
+			Meta: meta.Meta{
+				ID:   leaseName,
+				Name: leaseName,
+			},
+			Spec: core.LeaseSpec{
+				HolderIdentity:       a.NodeInfo.Name,
+				LeaseDurationSeconds: int32(NodeLeaseDuration / time.Second),
+			},
+		})
+		return err
+	}
+
+	lease.Spec.HolderIdentity = a.NodeInfo.Name
+	lease.Spec.LeaseDurationSeconds = int32(NodeLeaseDuration / time.Second)
+	_, err = a.client.CoreV0().Lease().Update(ctx, lease)
+	return err
+}
+
+// maxConflictRetries はnodeの更新が他のクライアントと競合した場合に取得し直す回数
+const maxConflictRetries = 3
+
+// updateNode は最新のnodeを取得してmutateで変更し、mutateがtrueを返した場合に更新する
+// humcliのcordonやnodelifecycle agentの変更を上書きしないように、取得したresourceVersionで更新し、
+// 競合した場合は取得し直してやり直す
+func (a *NodeAgent) updateNode(ctx context.Context, mutate func(node *system.Node) bool) (*system.Node, error) {
+	for i := 0; ; i++ {
+		node, err := a.client.SystemV0().Node().Get(ctx, a.NodeInfo.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !mutate(node) {
+			return node, nil
 		}
+
+		updated, err := a.client.SystemV0().Node().Update(ctx, node)
+		if meta.IsConflict(err) && i < maxConflictRetries {
+			continue
+		}
+		return updated, err
+	}
+}
+
+// setMeta は登録済みのノードにもarchと設定ファイルのlabelsを反映する。humcliで付けた他のlabelは残す
+func (a *NodeAgent) setMeta(node *system.Node) bool {
+	changed := false
+	if arch, ok := a.NodeInfo.Annotations[NodeV0AnnotationArch]; ok && node.Annotations[NodeV0AnnotationArch] != arch {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[NodeV0AnnotationArch] = arch
+		changed = true
+	}
+	if mergeLabels(&node.Labels, a.NodeInfo.Labels) {
+		changed = true
+	}
+	return changed
+}
+
+// setReady はagentが動いているノードをReadyにする。Readyでなかった場合にtrueを返す
+func setReady(node *system.Node) bool {
+	if node.Status.State != system.NodeStateNotReady &&
+		node.Status.State != "" &&
+		meta.IsConditionTrue(node.Status.Conditions, system.NodeConditionReady) {
+		return false
+	}
+
+	node.Status.State = system.NodeStateReady
+	meta.SetCondition(&node.Status.Conditions, meta.Condition{
+		Type:               system.NodeConditionReady,
+		Status:             meta.ConditionTrue,
+		Reason:             "AgentReady",
+		ObservedGeneration: node.Generation,
+	})
+	return true
+}
+
+// setRequested はノードに割り当てられたVM, blockstorageの要求量をstatusに設定する
+func setRequested(node *system.Node, res map[ResourceType]string) bool {
+	if node.Status.RequestedVcpus == res[ResourceTypeRequestVcpus] &&
+		node.Status.RequestedMemory == res[ResourceTypeRequestMemory] &&
+		node.Status.RequestedDisk == res[ResourceTypeRequestDisk] {
+		return false
 	}
+	node.Status.RequestedVcpus = res[ResourceTypeRequestVcpus]
+	node.Status.RequestedMemory = res[ResourceTypeRequestMemory]
+	node.Status.RequestedDisk = res[ResourceTypeRequestDisk]
+	return true
+}
 
+// detectCapacity はノードのリソースを検出し、capacityとallocatableを返す
+func (a *NodeAgent) detectCapacity() (*system.NodeCapacity, *system.NodeAllocatable, error) {
+	capacity, err := a.detector.detect(a.config.BlockStorageDirPath, a.config.ImageDirPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	alloc, err := allocatable(capacity, a.config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return capacity, alloc, nil
+}
+
+// setCapacity はstatusのcapacityとallocatableを設定する
+// 値が変わった場合にtrueを返す
+func setCapacity(node *system.Node, capacity *system.NodeCapacity, alloc *system.NodeAllocatable) bool {
+	if reflect.DeepEqual(node.Status.Capacity, *capacity) &&
+		node.Status.Allocatable == *alloc {
+		return false
+	}
+	node.Status.Capacity = *capacity
+	node.Status.Allocatable = *alloc
+	return true
 }
 
 func (a *NodeAgent) GetNodeInfo() *system.Node {
@@ -121,26 +310,26 @@ const (
 
 func (a *NodeAgent) getUsedResources() (map[ResourceType]string, error) {
 
-	grList, err := a.client.CoreV0().Group().List()
+	grList, err := a.client.CoreV0().Group().List(context.TODO())
 	if err != nil {
 		return nil, err
 	}
 
-	var vcpusRequests float64 = 0
-	var vcpusLimits float64 = 0
+	var vcpusRequests int64 = 0
+	var vcpusLimits int64 = 0
 	var memoryRequests int64 = 0
 	var memoryLimits int64 = 0
 	var diskRequests int64 = 0
 	var diskLimits int64 = 0
 
 	for _, group := range grList {
-		nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+		nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), group.ID)
 		if err != nil {
 			return nil, err
 		}
 
 		for _, ns := range nsList {
-			vmList, err := a.client.SystemV0().VirtualMachine().List(group.ID, ns.ID)
+			vmList, err := a.client.SystemV0().VirtualMachine().List(context.TODO(), group.ID, ns.ID)
 			if err != nil {
 				return nil, err
 			}
@@ -153,32 +342,32 @@ func (a *NodeAgent) getUsedResources() (map[ResourceType]string, error) {
 					continue
 				}
 
-				vcpusRequest, err := strconv.ParseFloat(withUnitToWithoutUnit(vm.Spec.RequestVcpus), 64)
+				vcpusRequest, err := quantity.ParseMilliVcpus(vm.Spec.RequestVcpus)
 				if err != nil {
 					return nil, err
 				}
 				vcpusRequests += vcpusRequest
 
-				vcpusLimit, err := strconv.ParseFloat(withUnitToWithoutUnit(vm.Spec.LimitVcpus), 64)
+				vcpusLimit, err := quantity.ParseMilliVcpus(vm.Spec.LimitVcpus)
 				if err != nil {
 					return nil, err
 				}
 				vcpusLimits += vcpusLimit
 
-				memoryRequest, err := strconv.ParseInt(withUnitToWithoutUnit(vm.Spec.RequestMemory), 10, 64)
+				memoryRequest, err := quantity.ParseBytes(vm.Spec.RequestMemory)
 				if err != nil {
 					return nil, err
 				}
 				memoryRequests += memoryRequest
 
-				memoryLimit, err := strconv.ParseInt(withUnitToWithoutUnit(vm.Spec.LimitMemory), 10, 64)
+				memoryLimit, err := quantity.ParseBytes(vm.Spec.LimitMemory)
 				if err != nil {
 					return nil, err
 				}
 				memoryLimits += memoryLimit
 			}
 
-			bsList, err := a.client.SystemV0().BlockStorage().List(group.ID, ns.ID)
+			bsList, err := a.client.SystemV0().BlockStorage().List(context.TODO(), group.ID, ns.ID)
 			if err != nil {
 				return nil, err
 			}
@@ -189,13 +378,13 @@ func (a *NodeAgent) getUsedResources() (map[ResourceType]string, error) {
 					continue
 				}
 
-				diskRequest, err := strconv.ParseInt(withUnitToWithoutUnit(bs.Spec.RequestSize), 10, 64)
+				diskRequest, err := quantity.ParseBytes(bs.Spec.RequestSize)
 				if err != nil {
 					return nil, err
 				}
 				diskRequests += diskRequest
 
-				diskLimit, err := strconv.ParseInt(withUnitToWithoutUnit(bs.Spec.LimitSize), 10, 64)
+				diskLimit, err := quantity.ParseBytes(bs.Spec.LimitSize)
 				if err != nil {
 					return nil, err
 				}
@@ -205,59 +394,26 @@ func (a *NodeAgent) getUsedResources() (map[ResourceType]string, error) {
 	}
 
 	return map[ResourceType]string{
-		ResourceTypeRequestVcpus:  parseVcpus(vcpusRequests),
-		ResourceTypeRequestMemory: parseMemory(memoryRequests),
-		ResourceTypeRequestDisk:   parseMemory(diskRequests),
-		ResourceTypeLimitVcpus:    parseVcpus(vcpusLimits),
-		ResourceTypeLimitMemory:   parseMemory(memoryLimits),
-		ResourceTypeLimitDisk:     parseMemory(diskLimits),
+		ResourceTypeRequestVcpus:  quantity.FormatMilliVcpus(vcpusRequests),
+		ResourceTypeRequestMemory: quantity.FormatBytes(memoryRequests),
+		ResourceTypeRequestDisk:   quantity.FormatBytes(diskRequests),
+		ResourceTypeLimitVcpus:    quantity.FormatMilliVcpus(vcpusLimits),
+		ResourceTypeLimitMemory:   quantity.FormatBytes(memoryLimits),
+		ResourceTypeLimitDisk:     quantity.FormatBytes(diskLimits),
 	}, nil
 }
 
-func parseVcpus(n float64) string {
-	return fmt.Sprintf("%dm", int64(n*1000))
-}
-
-func parseMemory(b int64) string {
-	if b%(1024*1024*1024) == 0 {
-		return fmt.Sprintf("%dG", b/(1024*1024*1024))
-	} else if b%(1024*1024) == 0 {
-		return fmt.Sprintf("%dM", b/(1024*1024))
-	} else if b%1024 == 0 {
-		return fmt.Sprintf("%dK", b/1024)
-	} else {
-		return fmt.Sprintf("%d", b)
-	}
-}
-
-// copy from virtualmachine/agent.go
-const (
-	UnitGigabyte = 'G'
-	UnitMegabyte = 'M'
-	UnitKilobyte = 'K'
-	UnitMilli    = 'm'
-)
-
-func withUnitToWithoutUnit(numberWithUnit string) string {
-	length := len(numberWithUnit)
-	if numberWithUnit[length-1] >= '0' && numberWithUnit[length-1] <= '9' {
-		return numberWithUnit
-	}
-
-	number, err := strconv.ParseInt(numberWithUnit[:length-1], 10, 64)
-	if err != nil {
-		return "0"
-	}
-
-	switch numberWithUnit[length-1] {
-	case UnitGigabyte:
-		return fmt.Sprintf("%d", number*1024*1024*1024)
-	case UnitMegabyte:
-		return fmt.Sprintf("%d", number*1024*1024)
-	case UnitKilobyte:
-		return fmt.Sprintf("%d", number*1024)
-	case UnitMilli:
-		return fmt.Sprintf("%f", float64(number)/1000)
+func mergeLabels(labels *map[string]string, desired map[string]string) bool {
+	changed := false
+	for k, v := range desired {
+		if value, ok := (*labels)[k]; ok && value == v {
+			continue
+		}
+		if *labels == nil {
+			*labels = map[string]string{}
+		}
+		(*labels)[k] = v
+		changed = true
 	}
-	return "0"
+	return changed
 }
@@ -0,0 +1,264 @@
+package node
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/utils/quantity"
+)
+
+const gigabyte = 1024 * 1024 * 1024
+
+// capacityDetector はノードのリソースを/procや/sysから調べる
+// テストのためにパスや関数を差し替えられるようにしている
+type capacityDetector struct {
+	procPath string
+	sysPath  string
+	devPath  string
+	numCPU   func() int
+	statfs   func(path string) (total, free int64, err error)
+}
+
+func newCapacityDetector() *capacityDetector {
+	return &capacityDetector{
+		procPath: "/proc",
+		sysPath:  "/sys",
+		devPath:  "/dev",
+		numCPU:   runtime.NumCPU,
+		statfs:   statfs,
+	}
+}
+
+func statfs(path string) (int64, int64, error) {
+	st := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return int64(st.Blocks) * int64(st.Bsize), int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+func (d *capacityDetector) detect(blockStorageDirPath, imageDirPath string) (*system.NodeCapacity, error) {
+	memory, err := d.memTotal()
+	if err != nil {
+		return nil, err
+	}
+	hugePages, err := d.hugePages()
+	if err != nil {
+		return nil, err
+	}
+	model, flags, err := d.cpuInfo()
+	if err != nil {
+		return nil, err
+	}
+	bsDisk, err := d.disk(blockStorageDirPath)
+	if err != nil {
+		return nil, err
+	}
+	imageDisk, err := d.disk(imageDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(filepath.Join(d.devPath, "kvm"))
+	return &system.NodeCapacity{
+		Vcpus:            quantity.FormatMilliVcpus(int64(d.numCPU()) * 1000),
+		Memory:           quantity.FormatBytes(memory),
+		HugePages:        hugePages,
+		KVM:              err == nil,
+		CPUModel:         model,
+		CPUFlags:         flags,
+		BlockStorageDisk: bsDisk,
+		ImageDisk:        imageDisk,
+	}, nil
+}
+
+// memTotal は/proc/meminfoのMemTotalをバイトで返す
+func (d *capacityDetector) memTotal() (int64, error) {
+	f, err := os.Open(filepath.Join(d.procPath, "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// MemTotal:       16314500 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid MemTotal `%s`", fields[1])
+		}
+		return kb * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("MemTotal not found in meminfo")
+}
+
+// hugePages は/sys/kernel/mm/hugepages/hugepages-<size>kB/からページサイズごとの数を返す
+// hugepagesが無効なカーネルでは空を返す
+func (d *capacityDetector) hugePages() ([]system.NodeHugePages, error) {
+	dirs, err := filepath.Glob(filepath.Join(d.sysPath, "kernel/mm/hugepages/hugepages-*kB"))
+	if err != nil {
+		return nil, err
+	}
+
+	hugePages := []system.NodeHugePages{}
+	for _, dir := range dirs {
+		sizeKB, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(dir), "hugepages-"), "kB"), 10, 64)
+		if err != nil {
+			continue
+		}
+		total, err := readInt(filepath.Join(dir, "nr_hugepages"))
+		if err != nil {
+			return nil, err
+		}
+		free, err := readInt(filepath.Join(dir, "free_hugepages"))
+		if err != nil {
+			return nil, err
+		}
+		hugePages = append(hugePages, system.NodeHugePages{
+			PageSize: quantity.FormatBytes(sizeKB * 1024),
+			Total:    total,
+			Free:     free,
+		})
+	}
+	return hugePages, nil
+}
+
+// cpuInfo は/proc/cpuinfoの最初のプロセッサのモデル名とフラグを返す
+// armではflagsの代わりにFeaturesを使う
+func (d *capacityDetector) cpuInfo() (string, []string, error) {
+	f, err := os.Open(filepath.Join(d.procPath, "cpuinfo"))
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	model := ""
+	var flags []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch key {
+		case "model name":
+			if model == "" {
+				model = value
+			}
+		case "flags", "Features":
+			if flags == nil {
+				flags = strings.Fields(value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, err
+	}
+
+	if flags == nil {
+		flags = []string{}
+	}
+	sort.Strings(flags)
+	return model, flags, nil
+}
+
+// disk はpathがあるファイルシステムの容量を返す
+// 空き容量はVMのディスクの書き込みで頻繁に変わるため、ノードの更新を減らすようにGB単位に切り捨てる
+func (d *capacityDetector) disk(path string) (system.NodeDisk, error) {
+	if path == "" {
+		return system.NodeDisk{}, nil
+	}
+	total, free, err := d.statfs(path)
+	if err != nil {
+		return system.NodeDisk{}, err
+	}
+	return system.NodeDisk{
+		Path:  path,
+		Total: quantity.FormatBytes(total / gigabyte * gigabyte),
+		Free:  quantity.FormatBytes(free / gigabyte * gigabyte),
+	}, nil
+}
+
+func readInt(path string) (int64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// allocatable はcapacityから予約分とhugepagesを引き、overcommitの倍率をかける
+func allocatable(capacity *system.NodeCapacity, config *NodeAgentConfig) (*system.NodeAllocatable, error) {
+	vcpus, err := quantity.ParseMilliVcpus(capacity.Vcpus)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := quantity.ParseBytes(capacity.Memory)
+	if err != nil {
+		return nil, err
+	}
+	reservedVcpus, err := quantity.ParseMilliVcpus(config.ReservedVcpus)
+	if err != nil {
+		return nil, err
+	}
+	reservedMemory, err := quantity.ParseBytes(config.ReservedMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	// hugepagesに確保したメモリは通常のVMには使えない
+	for _, hp := range capacity.HugePages {
+		pageSize, err := quantity.ParseBytes(hp.PageSize)
+		if err != nil {
+			return nil, err
+		}
+		memory -= pageSize * hp.Total
+	}
+
+	res := &system.NodeAllocatable{
+		Vcpus:  quantity.FormatMilliVcpus(overcommit(vcpus, reservedVcpus, config.VcpuOvercommitRatio)),
+		Memory: quantity.FormatBytes(overcommit(memory, reservedMemory, config.MemoryOvercommitRatio)),
+	}
+
+	// blockStorageDirPathがない場合はLocalのblockstorageの上限を設けない
+	if capacity.BlockStorageDisk.Total != "" {
+		disk, err := quantity.ParseBytes(capacity.BlockStorageDisk.Total)
+		if err != nil {
+			return nil, err
+		}
+		reservedDisk, err := quantity.ParseBytes(config.ReservedDisk)
+		if err != nil {
+			return nil, err
+		}
+		res.Disk = quantity.FormatBytes(overcommit(disk, reservedDisk, config.DiskOvercommitRatio))
+	}
+	return res, nil
+}
+
+// overcommit は(total - reserved) * ratioを返す
+// schedulerは0を上限なしとして扱うため、予約分の方が多い場合も最小の単位を残す
+func overcommit(total, reserved int64, ratio float64) int64 {
+	n := int64(float64(total-reserved) * ratio)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,194 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestDetector(t *testing.T) *capacityDetector {
+	t.Helper()
+
+	root, err := ioutil.TempDir("", "capacity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	writeFile(t, filepath.Join(root, "proc/meminfo"), `MemTotal:       16777216 kB
+MemFree:         8388608 kB
+`)
+	writeFile(t, filepath.Join(root, "proc/cpuinfo"), `processor	: 0
+model name	: Intel(R) Xeon(R) CPU E5-2650 v2 @ 2.60GHz
+flags		: vmx sse4_2 fpu
+
+processor	: 1
+model name	: Intel(R) Xeon(R) CPU E5-2650 v2 @ 2.60GHz
+flags		: vmx sse4_2 fpu
+`)
+	writeFile(t, filepath.Join(root, "sys/kernel/mm/hugepages/hugepages-2048kB/nr_hugepages"), "512\n")
+	writeFile(t, filepath.Join(root, "sys/kernel/mm/hugepages/hugepages-2048kB/free_hugepages"), "256\n")
+	writeFile(t, filepath.Join(root, "sys/kernel/mm/hugepages/hugepages-1048576kB/nr_hugepages"), "0\n")
+	writeFile(t, filepath.Join(root, "sys/kernel/mm/hugepages/hugepages-1048576kB/free_hugepages"), "0\n")
+	writeFile(t, filepath.Join(root, "dev/kvm"), "")
+
+	return &capacityDetector{
+		procPath: filepath.Join(root, "proc"),
+		sysPath:  filepath.Join(root, "sys"),
+		devPath:  filepath.Join(root, "dev"),
+		numCPU:   func() int { return 8 },
+		statfs: func(path string) (int64, int64, error) {
+			// 空き容量は切り捨てる
+			return 100 * gigabyte, 50*gigabyte + 12345, nil
+		},
+	}
+}
+
+func TestDetect(t *testing.T) {
+	d := newTestDetector(t)
+
+	capacity, err := d.detect("/var/lib/humstack/blockstorages", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &system.NodeCapacity{
+		Vcpus:  "8000m",
+		Memory: "16G",
+		HugePages: []system.NodeHugePages{
+			{PageSize: "1G", Total: 0, Free: 0},
+			{PageSize: "2M", Total: 512, Free: 256},
+		},
+		KVM:      true,
+		CPUModel: "Intel(R) Xeon(R) CPU E5-2650 v2 @ 2.60GHz",
+		CPUFlags: []string{"fpu", "sse4_2", "vmx"},
+		BlockStorageDisk: system.NodeDisk{
+			Path:  "/var/lib/humstack/blockstorages",
+			Total: "100G",
+			Free:  "50G",
+		},
+	}
+	if !reflect.DeepEqual(capacity, expected) {
+		t.Fatalf("expected %+v, but got %+v", expected, capacity)
+	}
+
+	if err := os.Remove(filepath.Join(d.devPath, "kvm")); err != nil {
+		t.Fatal(err)
+	}
+	capacity, err = d.detect("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity.KVM {
+		t.Fatal("expected kvm to be unavailable")
+	}
+}
+
+func TestDetectArm(t *testing.T) {
+	d := newTestDetector(t)
+	writeFile(t, filepath.Join(d.procPath, "cpuinfo"), `processor	: 0
+BogoMIPS	: 48.00
+Features	: fp asimd evtstrm
+CPU implementer	: 0x41
+`)
+
+	capacity, err := d.detect("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity.CPUModel != "" || !reflect.DeepEqual(capacity.CPUFlags, []string{"asimd", "evtstrm", "fp"}) {
+		t.Fatalf("unexpected cpu: %s %v", capacity.CPUModel, capacity.CPUFlags)
+	}
+}
+
+func TestAllocatable(t *testing.T) {
+	capacity := &system.NodeCapacity{
+		Vcpus:  "8000m",
+		Memory: "16G",
+		HugePages: []system.NodeHugePages{
+			{PageSize: "2M", Total: 1024},
+		},
+		BlockStorageDisk: system.NodeDisk{Total: "100G"},
+	}
+
+	tests := []struct {
+		name     string
+		config   NodeAgentConfig
+		expected system.NodeAllocatable
+	}{
+		{
+			name:     "default",
+			config:   NodeAgentConfig{},
+			expected: system.NodeAllocatable{Vcpus: "8000m", Memory: "14G", Disk: "100G"},
+		},
+		{
+			name: "reserved and overcommit",
+			config: NodeAgentConfig{
+				ReservedVcpus:         "1",
+				ReservedMemory:        "2G",
+				ReservedDisk:          "10G",
+				VcpuOvercommitRatio:   4,
+				MemoryOvercommitRatio: 1.5,
+			},
+			expected: system.NodeAllocatable{Vcpus: "28000m", Memory: "18G", Disk: "90G"},
+		},
+		{
+			// 0は上限なしになるため最小の単位を残す
+			name: "reserved exceeds capacity",
+			config: NodeAgentConfig{
+				ReservedVcpus:  "16",
+				ReservedMemory: "32G",
+			},
+			expected: system.NodeAllocatable{Vcpus: "1m", Memory: "1", Disk: "100G"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := allocatable(capacity, &tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.expected {
+				t.Fatalf("expected %+v, but got %+v", tt.expected, *got)
+			}
+		})
+	}
+}
+
+func TestNodeAgentConfigValidate(t *testing.T) {
+	invalid := []NodeAgentConfig{
+		{ReservedVcpus: "two"},
+		{ReservedMemory: "2T"},
+		{ReservedDisk: "-1G"},
+		{MemoryOvercommitRatio: -1},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Fatalf("expected error: %+v", c)
+		}
+	}
+
+	// schedulerと同じ形式を受け付ける
+	c := NodeAgentConfig{ReservedVcpus: "1.5", ReservedMemory: "512M"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,54 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/ophum/humstack/pkg/utils/quantity"
+)
+
+type NodeAgentConfig struct {
+	// VMに割り当てずにOSやagentのために残しておく量(省略時は0)
+	ReservedVcpus  string `yaml:"reservedVcpus"`
+	ReservedMemory string `yaml:"reservedMemory"`
+	ReservedDisk   string `yaml:"reservedDisk"`
+
+	// 予約分を引いた量にかける倍率(省略時は1)
+	VcpuOvercommitRatio   float64 `yaml:"vcpuOvercommitRatio"`
+	MemoryOvercommitRatio float64 `yaml:"memoryOvercommitRatio"`
+	DiskOvercommitRatio   float64 `yaml:"diskOvercommitRatio"`
+
+	// 容量を調べるディレクトリ。blockStorageAgentConfigの値を使う
+	BlockStorageDirPath string `yaml:"-"`
+	ImageDirPath        string `yaml:"-"`
+}
+
+// Validate は省略された値を埋め、不正な値の場合はエラーを返す
+func (c *NodeAgentConfig) Validate() error {
+	if n, err := quantity.ParseMilliVcpus(c.ReservedVcpus); err != nil || n < 0 {
+		return fmt.Errorf("invalid reservedVcpus `%s`", c.ReservedVcpus)
+	}
+	if n, err := quantity.ParseBytes(c.ReservedMemory); err != nil || n < 0 {
+		return fmt.Errorf("invalid reservedMemory `%s`", c.ReservedMemory)
+	}
+	if n, err := quantity.ParseBytes(c.ReservedDisk); err != nil || n < 0 {
+		return fmt.Errorf("invalid reservedDisk `%s`", c.ReservedDisk)
+	}
+
+	ratios := []struct {
+		name  string
+		ratio *float64
+	}{
+		{name: "vcpuOvercommitRatio", ratio: &c.VcpuOvercommitRatio},
+		{name: "memoryOvercommitRatio", ratio: &c.MemoryOvercommitRatio},
+		{name: "diskOvercommitRatio", ratio: &c.DiskOvercommitRatio},
+	}
+	for _, r := range ratios {
+		if *r.ratio == 0 {
+			*r.ratio = 1
+		}
+		if *r.ratio < 0 {
+			return fmt.Errorf("%s must be positive", r.name)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package nodenetwork
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
@@ -9,16 +10,22 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
 	"go.uber.org/zap"
 )
 
 type NodeNetworkAgent struct {
-	client *client.Clients
-	config *NetworkAgentConfig
-	node   string
-	logger *zap.Logger
+	client   client.Interface
+	config   *NetworkAgentConfig
+	node     string
+	logger   *zap.Logger
+	health   *health.Reporter
+	recorder *event.Recorder
 }
 
 const (
@@ -26,13 +33,17 @@ const (
 	NodeNetworkV0AnnotationNodeName    = "nodenetworkv0/node_name"
 )
 
+const (
+	NodeNetworkV0FinalizerName = "nodenetworkv0/nodenetwork-agent"
+)
+
 const (
 	NodeNetworkV0NetworkTypeVXLAN  = "VXLAN"
 	NodeNetworkV0NetworkTypeVLAN   = "VLAN"
 	NodeNetworkV0NetworkTypeBridge = "Bridge"
 )
 
-func NewNodeNetworkAgent(client *client.Clients, config *NetworkAgentConfig, logger *zap.Logger) *NodeNetworkAgent {
+func NewNodeNetworkAgent(client client.Interface, config *NetworkAgentConfig, logger *zap.Logger) *NodeNetworkAgent {
 	node, err := os.Hostname()
 	if err != nil {
 		log.Fatal(err)
@@ -45,14 +56,24 @@ func NewNodeNetworkAgent(client *client.Clients, config *NetworkAgentConfig, log
 	}
 }
 
-func (a *NodeNetworkAgent) Run() {
+func (a *NodeNetworkAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *NodeNetworkAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *NodeNetworkAgent) Run(ctx context.Context) {
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			grList, err := a.client.CoreV0().Group().List(context.TODO())
 			if err != nil {
 				a.logger.Error(
 					"get group list",
@@ -63,7 +84,7 @@ func (a *NodeNetworkAgent) Run() {
 			}
 
 			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+				nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), group.ID)
 				if err != nil {
 					a.logger.Error(
 						"get namespace list",
@@ -74,7 +95,7 @@ func (a *NodeNetworkAgent) Run() {
 				}
 
 				for _, ns := range nsList {
-					vmList, err := a.client.SystemV0().VirtualMachine().List(group.ID, ns.ID)
+					vmList, err := a.client.SystemV0().VirtualMachine().List(context.TODO(), group.ID, ns.ID)
 					if err != nil {
 						a.logger.Error(
 							"get virtualmachine list",
@@ -98,7 +119,7 @@ func (a *NodeNetworkAgent) Run() {
 						}
 					}
 
-					vrList, err := a.client.SystemV0().VirtualRouter().List(group.ID, ns.ID)
+					vrList, err := a.client.SystemV0().VirtualRouter().List(context.TODO(), group.ID, ns.ID)
 					if err != nil {
 						a.logger.Error(
 							"get virtualrouter list",
@@ -124,7 +145,7 @@ func (a *NodeNetworkAgent) Run() {
 						}
 					}
 
-					netList, err := a.client.SystemV0().NodeNetwork().List(group.ID, ns.ID)
+					netList, err := a.client.SystemV0().NodeNetwork().List(context.TODO(), group.ID, ns.ID)
 					if err != nil {
 						a.logger.Error(
 							"get network list",
@@ -138,6 +159,12 @@ func (a *NodeNetworkAgent) Run() {
 						if nodeName, ok := net.Annotations[NodeNetworkV0AnnotationNodeName]; ok && nodeName != a.node {
 							continue
 						}
+						if net.IsDeleting() && !net.HasFinalizer(NodeNetworkV0FinalizerName) {
+							continue
+						}
+						// ノード上のインターフェースを削除するまでnodeNetworkが消えないようにする
+						net.AddFinalizer(NodeNetworkV0FinalizerName)
+
 						oldHash := net.ResourceHash
 						net.Status.AttachedInterfaces = attachedInterfacesToNet[net.ID]
 						switch net.Annotations[NodeNetworkV0AnnotationNetworkType] {
@@ -149,6 +176,8 @@ func (a *NodeNetworkAgent) Run() {
 									zap.String("msg", err.Error()),
 									zap.Time("time", time.Now()),
 								)
+								a.recorder.Event(net.Meta, core.EventTypeWarning, "SyncFailed", err.Error())
+								a.saveCondition(net, oldHash, err)
 								continue
 							}
 						case NodeNetworkV0NetworkTypeVXLAN:
@@ -159,6 +188,8 @@ func (a *NodeNetworkAgent) Run() {
 									zap.String("msg", err.Error()),
 									zap.Time("time", time.Now()),
 								)
+								a.recorder.Event(net.Meta, core.EventTypeWarning, "SyncFailed", err.Error())
+								a.saveCondition(net, oldHash, err)
 								continue
 							}
 						case NodeNetworkV0NetworkTypeVLAN:
@@ -169,15 +200,24 @@ func (a *NodeNetworkAgent) Run() {
 									zap.String("msg", err.Error()),
 									zap.Time("time", time.Now()),
 								)
+								a.recorder.Event(net.Meta, core.EventTypeWarning, "SyncFailed", err.Error())
+								a.saveCondition(net, oldHash, err)
 								continue
 							}
 
 						}
 
+						if !net.IsDeleting() {
+							setCondition(net, meta.ConditionTrue, "Synced", "")
+							if err := setHash(net); err != nil {
+								continue
+							}
+						}
+
 						if net.ResourceHash == oldHash {
 							continue
 						}
-						_, err = a.client.SystemV0().NodeNetwork().Update(net)
+						_, err = a.client.SystemV0().NodeNetwork().Update(context.TODO(), net)
 						if err != nil {
 							a.logger.Error(
 								"update network",
@@ -189,10 +229,44 @@ func (a *NodeNetworkAgent) Run() {
 					}
 				}
 			}
+
+			a.health.Reconciled()
 		}
 	}
 }
 
+func (a *NodeNetworkAgent) removeFinalizer(network *system.NodeNetwork) error {
+	network.RemoveFinalizer(NodeNetworkV0FinalizerName)
+	_, err := a.client.SystemV0().NodeNetwork().Update(context.TODO(), network)
+	return err
+}
+
+// saveCondition は同期に失敗した場合もconditionを保存する
+func (a *NodeNetworkAgent) saveCondition(network *system.NodeNetwork, oldHash string, syncErr error) {
+	setCondition(network, meta.ConditionFalse, "SyncFailed", syncErr.Error())
+	if err := setHash(network); err != nil || network.ResourceHash == oldHash {
+		return
+	}
+
+	if _, err := a.client.SystemV0().NodeNetwork().Update(context.TODO(), network); err != nil {
+		a.logger.Error(
+			"update network conditions",
+			zap.String("msg", err.Error()),
+			zap.Time("time", time.Now()),
+		)
+	}
+}
+
+func setCondition(network *system.NodeNetwork, status meta.ConditionStatus, reason, message string) {
+	meta.SetCondition(&network.Status.Conditions, meta.Condition{
+		Type:               system.NodeNetworkConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: network.Generation,
+	})
+}
+
 func setHash(network *system.NodeNetwork) error {
 	network.ResourceHash = ""
 	resourceJSON, err := json.Marshal(network)
@@ -6,7 +6,6 @@ import (
 	"github.com/n0stack/n0stack/n0core/pkg/driver/iproute2"
 	"github.com/n0stack/n0stack/n0core/pkg/driver/iptables"
 	"github.com/ophum/humstack/pkg/agents/system/nodenetwork/utils"
-	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/pkg/errors"
 )
@@ -18,13 +17,13 @@ func (a *NodeNetworkAgent) syncBridgeNetwork(network *system.NodeNetwork) error
 		return err
 	}
 
-	if network.DeleteState == meta.DeleteStateDelete {
+	if network.IsDeleting() {
 		if err := br.Delete(); err != nil {
 			return errors.Wrap(err, "delete bridge")
 		}
 
-		if err := a.client.SystemV0().NodeNetwork().Delete(network.Group, network.Namespace, network.ID); err != nil {
-			return errors.Wrap(err, "delete node network")
+		if err := a.removeFinalizer(network); err != nil {
+			return errors.Wrap(err, "remove node network finalizer")
 		}
 		return nil
 	}
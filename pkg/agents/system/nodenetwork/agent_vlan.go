@@ -3,12 +3,10 @@ package nodenetwork
 import (
 	"fmt"
 	"strconv"
-	"time"
 
 	"github.com/n0stack/n0stack/n0core/pkg/driver/iproute2"
 	"github.com/ophum/humstack/pkg/agents/system/nodenetwork/utils"
 	"github.com/ophum/humstack/pkg/agents/system/nodenetwork/vlan"
-	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/pkg/errors"
 	"github.com/vishvananda/netlink"
@@ -39,14 +37,7 @@ func (a *NodeNetworkAgent) syncVLANNetwork(network *system.NodeNetwork) error {
 		} else {
 			if bridgeName != attachedBr.Attrs().Name {
 				// vlan id is already used
-				network.Status.Logs = append(network.Status.Logs, system.NodeNetworkStatusLog{
-					NodeID:   a.node,
-					Datetime: time.Now().String(),
-					Log:      fmt.Sprintf("vlan id `%s` is already used.", network.Spec.ID),
-				})
-				if _, err := a.client.SystemV0().NodeNetwork().Update(network); err != nil {
-					return err
-				}
+				// 呼び出し元でSyncFailedのイベントとして記録される
 				return fmt.Errorf("vlan id `%s` is already used.", network.Spec.ID)
 			}
 		}
@@ -78,7 +69,7 @@ func (a *NodeNetworkAgent) syncVLANNetwork(network *system.NodeNetwork) error {
 	}
 
 	// 削除処理
-	if network.DeleteState == meta.DeleteStateDelete {
+	if network.IsDeleting() {
 		if err := vlan.Delete(); err != nil {
 			return errors.Wrap(err, "delete vlan")
 		}
@@ -86,8 +77,8 @@ func (a *NodeNetworkAgent) syncVLANNetwork(network *system.NodeNetwork) error {
 		if err := netlink.LinkDel(br); err != nil {
 			return errors.Wrap(err, "delete bridge")
 		}
-		if err := a.client.SystemV0().NodeNetwork().Delete(network.Group, network.Namespace, network.ID); err != nil {
-			return errors.Wrap(err, "delete node network")
+		if err := a.removeFinalizer(network); err != nil {
+			return errors.Wrap(err, "remove node network finalizer")
 		}
 		return nil
 	}
@@ -7,7 +7,6 @@ import (
 	"github.com/n0stack/n0stack/n0core/pkg/driver/iproute2"
 	"github.com/ophum/humstack/pkg/agents/system/nodenetwork/utils"
 	"github.com/ophum/humstack/pkg/agents/system/nodenetwork/vxlan"
-	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/pkg/errors"
 	"github.com/vishvananda/netlink"
@@ -42,7 +41,7 @@ func (a *NodeNetworkAgent) syncVXLANNetwork(network *system.NodeNetwork) error {
 		return err
 	}
 
-	if network.DeleteState == meta.DeleteStateDelete {
+	if network.IsDeleting() {
 		br, err := iproute2.NewBridge(bridgeName)
 		if err != nil {
 			return err
@@ -55,8 +54,8 @@ func (a *NodeNetworkAgent) syncVXLANNetwork(network *system.NodeNetwork) error {
 			return errors.Wrap(err, "delete vxlan")
 		}
 
-		if err := a.client.SystemV0().NodeNetwork().Delete(network.Group, network.Namespace, network.ID); err != nil {
-			return errors.Wrap(err, "delete node network")
+		if err := a.removeFinalizer(network); err != nil {
+			return errors.Wrap(err, "remove node network finalizer")
 		}
 		return nil
 	}
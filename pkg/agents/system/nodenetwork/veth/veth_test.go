@@ -1,18 +1,24 @@
 package veth
 
 import (
+	"os"
 	"testing"
 
 	"github.com/vishvananda/netlink"
 )
 
 func TestVethAdd(t *testing.T) {
-	v, err := NewVeth("test", "test_peer")
-	if err != nil {
-		t.Fatal(err)
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
 	}
 
+	// 既存のbridgeに接続するので、bridgeがない環境では実行しない
 	l, err := netlink.LinkByName("hum-br-055d73a0")
+	if err != nil {
+		t.Skipf("bridge not found: %s", err.Error())
+	}
+
+	v, err := NewVeth("test", "test_peer")
 	if err != nil {
 		t.Fatal(err)
 	}
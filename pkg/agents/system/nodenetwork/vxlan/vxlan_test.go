@@ -3,15 +3,20 @@ package vxlan
 import (
 	"log"
 	"net"
+	"os"
 	"testing"
 
 	"github.com/vishvananda/netlink"
 )
 
 func TestVxlanAdd(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+
 	dev, err := netlink.LinkByName("enp0s31f6")
 	if err != nil {
-		t.Fatal(err)
+		t.Skipf("device not found: %s", err.Error())
 	}
 	log.Println(dev.Attrs().Index)
 
@@ -1,6 +1,7 @@
 package virtualmachine
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
@@ -17,7 +18,10 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/n0stack/n0stack/n0core/pkg/driver/iproute2"
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
 	"github.com/ophum/humstack/pkg/agents/system/nodenetwork/utils"
+	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
@@ -27,17 +31,26 @@ import (
 )
 
 type VirtualMachineAgent struct {
-	client        *client.Clients
+	client        client.Interface
+	config        *VirtualMachineAgentConfig
 	logger        *zap.Logger
 	nodeName      string
 	vncDisplayMap map[int32]bool
+	health        *health.Reporter
+	recorder      *event.Recorder
 }
 
 const (
 	VirtualMachineV0AnnotationNodeName = "virtualmachinev0/node_name"
+	// NotReadyのノードから別のノードに移したVMの、qemuの停止を確認していない元のノード(カンマ区切り)
+	VirtualMachineV0AnnotationPreviousNodeName = "virtualmachinev0/previous_node_name"
 )
 
-func NewVirtualMachineAgent(client *client.Clients, logger *zap.Logger) *VirtualMachineAgent {
+const (
+	VirtualMachineV0FinalizerName = "virtualmachinev0/virtualmachine-agent"
+)
+
+func NewVirtualMachineAgent(client client.Interface, config *VirtualMachineAgentConfig, logger *zap.Logger) *VirtualMachineAgent {
 
 	nodeName, err := os.Hostname()
 	if err != nil {
@@ -45,21 +58,32 @@ func NewVirtualMachineAgent(client *client.Clients, logger *zap.Logger) *Virtual
 	}
 	return &VirtualMachineAgent{
 		client:        client,
+		config:        config,
 		logger:        logger,
 		nodeName:      nodeName,
 		vncDisplayMap: map[int32]bool{},
 	}
 }
 
-func (a *VirtualMachineAgent) Run() {
+func (a *VirtualMachineAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *VirtualMachineAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *VirtualMachineAgent) Run(ctx context.Context) {
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
 			usedDisplayMap := map[int32]bool{}
-			grList, err := a.client.CoreV0().Group().List()
+			grList, err := a.client.CoreV0().Group().List(context.TODO())
 			if err != nil {
 				a.logger.Error(
 					"get group list",
@@ -70,7 +94,7 @@ func (a *VirtualMachineAgent) Run() {
 			}
 
 			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+				nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), group.ID)
 				if err != nil {
 					a.logger.Error(
 						"get namespace list",
@@ -81,7 +105,7 @@ func (a *VirtualMachineAgent) Run() {
 				}
 
 				for _, ns := range nsList {
-					vmList, err := a.client.SystemV0().VirtualMachine().List(group.ID, ns.ID)
+					vmList, err := a.client.SystemV0().VirtualMachine().List(context.TODO(), group.ID, ns.ID)
 					if err != nil {
 						a.logger.Error(
 							"get virtualmachine list",
@@ -93,10 +117,25 @@ func (a *VirtualMachineAgent) Run() {
 
 					for _, vm := range vmList {
 						oldHash := vm.ResourceHash
+						previous, fence := removeNodeName(vm.Annotations[VirtualMachineV0AnnotationPreviousNodeName], a.nodeName)
 						if vm.Annotations[VirtualMachineV0AnnotationNodeName] != a.nodeName {
+							if fence {
+								if err := a.fenceVirtualMachine(vm); err != nil {
+									a.logger.Error(
+										"fence virtualmachine",
+										zap.String("msg", err.Error()),
+										zap.Time("time", time.Now()),
+									)
+								}
+							}
 							continue
 						}
 
+						// 元のノードに戻ってきたVMはこのノードのqemuを使うので停止しない
+						if fence {
+							setPreviousNodeNames(vm, previous)
+						}
+
 						err = a.syncVirtualMachine(vm)
 						if err != nil {
 							a.logger.Error(
@@ -104,6 +143,18 @@ func (a *VirtualMachineAgent) Run() {
 								zap.String("msg", err.Error()),
 								zap.Time("time", time.Now()),
 							)
+							a.recorder.Event(vm.Meta, core.EventTypeWarning, "SyncFailed", err.Error())
+
+							// 失敗した段階のconditionを保存する
+							if err := setHash(vm); err == nil && vm.ResourceHash != oldHash {
+								if _, err := a.client.SystemV0().VirtualMachine().Update(context.TODO(), vm); err != nil {
+									a.logger.Error(
+										"update virtualmachine conditions",
+										zap.String("msg", err.Error()),
+										zap.Time("time", time.Now()),
+									)
+								}
+							}
 							continue
 						}
 
@@ -126,7 +177,7 @@ func (a *VirtualMachineAgent) Run() {
 							continue
 						}
 
-						_, err := a.client.SystemV0().VirtualMachine().Update(vm)
+						_, err := a.client.SystemV0().VirtualMachine().Update(context.TODO(), vm)
 						if err != nil {
 							a.logger.Error(
 								"update virtualmachine",
@@ -153,6 +204,8 @@ func (a *VirtualMachineAgent) Run() {
 					}
 				}
 			}
+
+			a.health.Reconciled()
 		}
 	}
 }
@@ -162,10 +215,11 @@ func (a *VirtualMachineAgent) powerOffVirtualMachine(vm *system.VirtualMachine)
 	if err != nil {
 		return err
 	}
+	setCondition(vm, system.VirtualMachineConditionBooted, meta.ConditionFalse, "PowerOff", "virtualmachine is powered off.")
 	if pid == -1 {
 		if vm.Status.State != system.VirtualMachineStateStopped {
 			vm.Status.State = system.VirtualMachineStateStopped
-			_, err := a.client.SystemV0().VirtualMachine().Update(vm)
+			_, err := a.client.SystemV0().VirtualMachine().Update(context.TODO(), vm)
 			if err != nil {
 				return err
 			}
@@ -174,7 +228,7 @@ func (a *VirtualMachineAgent) powerOffVirtualMachine(vm *system.VirtualMachine)
 	}
 
 	vm.Status.State = system.VirtualMachineStateStopping
-	_, err = a.client.SystemV0().VirtualMachine().Update(vm)
+	_, err = a.client.SystemV0().VirtualMachine().Update(context.TODO(), vm)
 	if err != nil {
 		return err
 	}
@@ -193,10 +247,75 @@ func (a *VirtualMachineAgent) powerOffVirtualMachine(vm *system.VirtualMachine)
 	displayNumber, err := strconv.ParseInt(displayNumberString, 10, 64)
 	delete(a.vncDisplayMap, int32(displayNumber))
 	vm.Status.State = system.VirtualMachineStateStopped
-	_, err = a.client.SystemV0().VirtualMachine().Update(vm)
+	_, err = a.client.SystemV0().VirtualMachine().Update(context.TODO(), vm)
+	if err != nil {
+		return err
+	}
+
+	a.recorder.Event(vm.Meta, core.EventTypeNormal, "Stopped", "qemu process was killed.")
+	return nil
+}
+
+// fenceVirtualMachine はノードが停止している間に別のノードに移されたVMのqemuが残っていれば停止する
+// 同じCephのディスクを2つのqemuが使わないようにするため、statusは移した先のノードのagentに任せる
+func (a *VirtualMachineAgent) fenceVirtualMachine(vm *system.VirtualMachine) error {
+	pid, err := getPID(vm.Spec.UUID)
+	if err != nil {
+		return err
+	}
+	if pid != -1 {
+		p, err := os.FindProcess(int(pid))
+		if err != nil {
+			return err
+		}
+		if err := p.Kill(); err != nil {
+			return err
+		}
+		a.recorder.Eventf(vm.Meta, core.EventTypeWarning, "Fenced", "qemu process on the previous node `%s` was killed.", a.nodeName)
+	}
+
+	// 停止を確認したので、次の周期から確認しないようにする
+	vm, err = a.client.SystemV0().VirtualMachine().Get(context.TODO(), vm.Group, vm.Namespace, vm.ID)
+	if err != nil {
+		return err
+	}
+	// 他のノードの停止は確認していないので、このノードだけ外す
+	previous, ok := removeNodeName(vm.Annotations[VirtualMachineV0AnnotationPreviousNodeName], a.nodeName)
+	if !ok {
+		return nil
+	}
+	setPreviousNodeNames(vm, previous)
+	_, err = a.client.SystemV0().VirtualMachine().Update(context.TODO(), vm)
 	return err
 }
 
+// removeNodeName はカンマ区切りのノード名の一覧からnameを外す。nameが含まれていた場合にtrueを返す
+func removeNodeName(names, name string) (string, bool) {
+	if names == "" {
+		return names, false
+	}
+
+	found := false
+	rest := []string{}
+	for _, n := range strings.Split(names, ",") {
+		if n == name {
+			found = true
+			continue
+		}
+		rest = append(rest, n)
+	}
+	return strings.Join(rest, ","), found
+}
+
+// setPreviousNodeNames はprevious_node_nameを設定する。空の場合はannotationを消す
+func setPreviousNodeNames(vm *system.VirtualMachine, names string) {
+	if names == "" {
+		delete(vm.Annotations, VirtualMachineV0AnnotationPreviousNodeName)
+		return
+	}
+	vm.Annotations[VirtualMachineV0AnnotationPreviousNodeName] = names
+}
+
 func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) error {
 	pid, err := getPID(vm.Spec.UUID)
 	if err != nil {
@@ -205,10 +324,12 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 
 	// すでにqemuが起動している
 	if pid != -1 {
+		setCondition(vm, system.VirtualMachineConditionBooted, meta.ConditionTrue, "Running", fmt.Sprintf("qemu process is running with pid %d.", pid))
+
 		// stateがRunning以外ならRunningにする
 		if vm.Status.State != system.VirtualMachineStateRunning {
 			vm.Status.State = system.VirtualMachineStateRunning
-			if _, err := a.client.SystemV0().VirtualMachine().Update(vm); err != nil {
+			if _, err := a.client.SystemV0().VirtualMachine().Update(context.TODO(), vm); err != nil {
 				return errors.Wrap(err, "update vm state")
 			}
 		}
@@ -216,18 +337,27 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 	}
 
 	vm.Status.State = system.VirtualMachineStatePending
-	if _, err = a.client.SystemV0().VirtualMachine().Update(vm); err != nil {
+	if _, err = a.client.SystemV0().VirtualMachine().Update(context.TODO(), vm); err != nil {
 		return err
 	}
 
 	disks := []string{}
 	for _, bsID := range vm.Spec.BlockStorageIDs {
-		bs, err := a.client.SystemV0().BlockStorage().Get(vm.Group, vm.Namespace, bsID)
+		bs, err := a.client.SystemV0().BlockStorage().Get(context.TODO(), vm.Group, vm.Namespace, bsID)
+		if meta.IsNotFound(err) {
+			setCondition(vm, system.VirtualMachineConditionStorageReady, meta.ConditionFalse, "BlockStorageNotFound",
+				fmt.Sprintf("blockstorage `%s` is not found.", bsID))
+			vm.Status.State = system.VirtualMachineStatePending
+			return err
+		}
 		if err != nil {
+			setCondition(vm, system.VirtualMachineConditionStorageReady, meta.ConditionUnknown, "BlockStorageUnavailable", err.Error())
 			return err
 		}
 
 		if bs.Status.State != system.BlockStorageStateActive {
+			setCondition(vm, system.VirtualMachineConditionStorageReady, meta.ConditionFalse, "BlockStorageNotActive",
+				fmt.Sprintf("blockstorage `%s` is `%s`.", bsID, bs.Status.State))
 			vm.Status.State = system.VirtualMachineStatePending
 			return fmt.Errorf("BlockStorage is not active")
 		}
@@ -236,12 +366,16 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 		if t, ok := bs.Annotations["blockstoragev0/type"]; ok && t == "Ceph" {
 			cephPoolName, ok := bs.Annotations["ceph-pool-name"]
 			if !ok {
+				setCondition(vm, system.VirtualMachineConditionStorageReady, meta.ConditionFalse, "CephImageNotReady",
+					fmt.Sprintf("ceph pool of blockstorage `%s` is not set.", bsID))
 				vm.Status.State = system.VirtualMachineStatePending
 				return fmt.Errorf("BlockStorage is not active")
 			}
 
 			cephImageName, ok := bs.Annotations["ceph-image-name"]
 			if !ok {
+				setCondition(vm, system.VirtualMachineConditionStorageReady, meta.ConditionFalse, "CephImageNotReady",
+					fmt.Sprintf("ceph image of blockstorage `%s` is not set.", bsID))
 				vm.Status.State = system.VirtualMachineStatePending
 				return fmt.Errorf("BlockStorage is not active")
 			}
@@ -258,6 +392,8 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 		}
 	}
 
+	setCondition(vm, system.VirtualMachineConditionStorageReady, meta.ConditionTrue, "BlockStoragesActive", "all blockstorages are active.")
+
 	vcpus := withUnitToWithoutUnit(vm.Spec.LimitVcpus)
 	vcpusInt, err := strconv.ParseInt(vcpus, 10, 64)
 	if err != nil {
@@ -271,16 +407,26 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 			nic.MacAddress = generateMacAddress(vm.ID + nic.NetworkID)
 		}
 
-		n, err := a.client.CoreV0().Network().Get(vm.Group, vm.Namespace, nic.NetworkID)
+		n, err := a.client.CoreV0().Network().Get(context.TODO(), vm.Group, vm.Namespace, nic.NetworkID)
+		if meta.IsNotFound(err) {
+			setCondition(vm, system.VirtualMachineConditionNetworkReady, meta.ConditionFalse, "NetworkNotFound",
+				fmt.Sprintf("network `%s` is not found.", nic.NetworkID))
+			vm.Status.State = system.VirtualMachineStatePending
+			return err
+		}
 		if err != nil {
+			setCondition(vm, system.VirtualMachineConditionNetworkReady, meta.ConditionUnknown, "NetworkUnavailable", err.Error())
 			return err
 		}
 		net, err := a.getNodeNetwork(vm.Group, vm.Namespace, n.ID, a.nodeName)
 		if err != nil {
+			setCondition(vm, system.VirtualMachineConditionNetworkReady, meta.ConditionFalse, "NodeNetworkNotFound", err.Error())
 			return err
 		}
 
 		if _, ok := net.Annotations["nodenetworkv0/bridge_name"]; !ok {
+			setCondition(vm, system.VirtualMachineConditionNetworkReady, meta.ConditionFalse, "NodeNetworkNotReady",
+				fmt.Sprintf("bridge of network `%s` is not created on this node.", nic.NetworkID))
 			return fmt.Errorf("network is not active")
 		}
 		tapName := utils.GenerateName("hum-vm-", net.Annotations["nodenetworkv0/bridge_name"]+vm.ID)
@@ -305,6 +451,8 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 		)
 	}
 
+	setCondition(vm, system.VirtualMachineConditionNetworkReady, meta.ConditionTrue, "NodeNetworksReady", "all networks are ready on this node.")
+
 	_, err = uuid.Parse(vm.Spec.UUID)
 	if err != nil {
 		id, err := uuid.NewRandom()
@@ -339,7 +487,7 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 	networkConfigConfigs := []cloudinit.NetworkConfigConfig{}
 	for i, nic := range vm.Spec.NICs {
 		// 上のやつと統合するべき
-		n, err := a.client.CoreV0().Network().Get(vm.Group, vm.Namespace, nic.NetworkID)
+		n, err := a.client.CoreV0().Network().Get(context.TODO(), vm.Group, vm.Namespace, nic.NetworkID)
 		if err != nil {
 			return err
 		}
@@ -389,6 +537,13 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 		break
 	}
 
+	vncOption := fmt.Sprintf("0.0.0.0:%d,websocket=%d", displayNumber, 6900+displayNumber)
+	vncScheme := "ws"
+	if a.config.VNCTLSCredsDir != "" {
+		vncOption += ",tls-creds=vnctls0"
+		vncScheme = "wss"
+	}
+
 	command := "qemu-system-x86_64"
 	args := []string{
 		"-enable-kvm",
@@ -400,7 +555,7 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 		"-nodefaults",
 		"-vnc",
 		// とりあえず6900以降をWebSocketに使う
-		fmt.Sprintf("0.0.0.0:%d,websocket=%d", displayNumber, 6900+displayNumber),
+		vncOption,
 		"-smp",
 		fmt.Sprintf("%s,sockets=1,cores=%s,threads=1", vcpus, vcpus),
 		"-cpu",
@@ -462,11 +617,18 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 		}
 
 	}
+	if a.config.VNCTLSCredsDir != "" {
+		args = append(args,
+			"-object",
+			fmt.Sprintf("tls-creds-x509,id=vnctls0,dir=%s,endpoint=server,verify-peer=no", a.config.VNCTLSCredsDir),
+		)
+	}
 	args = append(args, disks...)
 	args = append(args, nics...)
 
 	cmd := exec.Command(command, args...)
-	if _, err := cmd.CombinedOutput(); err != nil {
+	if out, err := cmd.CombinedOutput(); err != nil {
+		setCondition(vm, system.VirtualMachineConditionBooted, meta.ConditionFalse, "QemuFailed", strings.TrimSpace(string(out)))
 		return errors.Wrap(err, fmt.Sprint(command, args))
 	}
 
@@ -496,7 +658,7 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 		return errors.Wrap(err, "get pid")
 	}
 
-	node, err := a.client.SystemV0().Node().Get(a.nodeName)
+	node, err := a.client.SystemV0().Node().Get(context.TODO(), a.nodeName)
 	if err != nil {
 		return errors.Wrap(err, "get node")
 	}
@@ -504,12 +666,20 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(vm *system.VirtualMachine) e
 	vm.Annotations["virtualmachinev0/pid"] = fmt.Sprint(pid)
 	vm.Annotations["virtualmachinev0/vnc_display_number"] = fmt.Sprint(displayNumber)
 	vm.Annotations["virtualmachinev0/vnc_websocket_host"] = fmt.Sprintf("%s:%d", node.Spec.Address, displayNumber+6900)
+	vm.Annotations["virtualmachinev0/vnc_websocket_scheme"] = vncScheme
 	vm.Status.State = system.VirtualMachineStateRunning
+	setCondition(vm, system.VirtualMachineConditionBooted, meta.ConditionTrue, "Started", fmt.Sprintf("qemu process started with pid %d.", pid))
+
+	a.recorder.Eventf(vm.Meta, core.EventTypeNormal, "Started", "qemu process started with pid %d.", pid)
 	return nil
 }
 
 func (a *VirtualMachineAgent) syncVirtualMachine(vm *system.VirtualMachine) error {
-	if vm.DeleteState == meta.DeleteStateDelete {
+	if vm.IsDeleting() {
+		if !vm.HasFinalizer(VirtualMachineV0FinalizerName) {
+			return nil
+		}
+
 		err := a.powerOffVirtualMachine(vm)
 		if err != nil {
 			return errors.Wrap(err, "poweroff vm")
@@ -520,9 +690,10 @@ func (a *VirtualMachineAgent) syncVirtualMachine(vm *system.VirtualMachine) erro
 			return errors.Wrap(err, "delete cloudinit data")
 		}
 
-		err = a.client.SystemV0().VirtualMachine().Delete(vm.Group, vm.Namespace, vm.ID)
+		vm.RemoveFinalizer(VirtualMachineV0FinalizerName)
+		_, err = a.client.SystemV0().VirtualMachine().Update(context.TODO(), vm)
 		if err != nil {
-			return errors.Wrap(err, "delete client vm")
+			return errors.Wrap(err, "remove vm finalizer")
 		}
 		return nil
 	}
@@ -531,6 +702,9 @@ func (a *VirtualMachineAgent) syncVirtualMachine(vm *system.VirtualMachine) erro
 		return nil
 	}
 
+	// qemuプロセスを停止するまでvmが消えないようにする
+	vm.AddFinalizer(VirtualMachineV0FinalizerName)
+
 	switch vm.Spec.ActionState {
 	case system.VirtualMachineActionStatePowerOn:
 		err := a.powerOnVirtualMachine(vm)
@@ -567,7 +741,7 @@ func getPID(uuid string) (int64, error) {
 }
 
 func (a *VirtualMachineAgent) getNodeNetwork(groupID, namespaceID, networkID, nodeID string) (*system.NodeNetwork, error) {
-	nodeNetList, err := a.client.SystemV0().NodeNetwork().List(groupID, namespaceID)
+	nodeNetList, err := a.client.SystemV0().NodeNetwork().List(context.TODO(), groupID, namespaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -575,7 +749,7 @@ func (a *VirtualMachineAgent) getNodeNetwork(groupID, namespaceID, networkID, no
 	for _, nodeNet := range nodeNetList {
 		isOwned := false
 		for _, owner := range nodeNet.OwnerReferences {
-			if owner.Meta.ID == networkID {
+			if owner.APIType == meta.APITypeNetworkV0 && owner.ID == networkID {
 				isOwned = true
 				break
 			}
@@ -590,6 +764,16 @@ func (a *VirtualMachineAgent) getNodeNetwork(groupID, namespaceID, networkID, no
 	return nil, fmt.Errorf("NodeNetwork not found")
 }
 
+func setCondition(vm *system.VirtualMachine, conditionType meta.ConditionType, status meta.ConditionStatus, reason, message string) {
+	meta.SetCondition(&vm.Status.Conditions, meta.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: vm.Generation,
+	})
+}
+
 func setHash(vm *system.VirtualMachine) error {
 	vm.ResourceHash = ""
 	resourceJSON, err := json.Marshal(vm)
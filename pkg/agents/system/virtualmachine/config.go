@@ -0,0 +1,7 @@
+package virtualmachine
+
+type VirtualMachineAgentConfig struct {
+	// 指定した場合はVNC websocketをTLS(wss)で待ち受ける
+	// ca-cert.pem, server-cert.pem, server-key.pemを配置する
+	VNCTLSCredsDir string `yaml:"vncTLSCredsDir"`
+}
@@ -1,6 +1,7 @@
 package virtualrouter
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
@@ -11,7 +12,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/agents/health"
 	"github.com/ophum/humstack/pkg/agents/system/nodenetwork/utils"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
 	"github.com/vishvananda/netlink"
@@ -19,19 +24,21 @@ import (
 )
 
 type VirtualRouterAgent struct {
-	client *client.Clients
+	client client.Interface
 	logger *zap.Logger
 
 	externalBridge  string
 	floatingIPCIDR  string
 	usedFloatingIPs map[string]bool
+	health          *health.Reporter
+	recorder        *event.Recorder
 }
 
 const (
 	VirtualRouterV0AnnotationNodeName = "virtualrouterv0/node_name"
 )
 
-func NewVirtualRouterAgent(client *client.Clients, externalBridge string, floatingIPCIDR string, usedFloatingIPs []string, logger *zap.Logger) *VirtualRouterAgent {
+func NewVirtualRouterAgent(client client.Interface, externalBridge string, floatingIPCIDR string, usedFloatingIPs []string, logger *zap.Logger) *VirtualRouterAgent {
 	return &VirtualRouterAgent{
 		client:          client,
 		logger:          logger,
@@ -41,7 +48,15 @@ func NewVirtualRouterAgent(client *client.Clients, externalBridge string, floati
 	}
 }
 
-func (a *VirtualRouterAgent) Run() {
+func (a *VirtualRouterAgent) SetHealthReporter(reporter *health.Reporter) {
+	a.health = reporter
+}
+
+func (a *VirtualRouterAgent) SetEventRecorder(recorder *event.Recorder) {
+	a.recorder = recorder
+}
+
+func (a *VirtualRouterAgent) Run(ctx context.Context) {
 	ticker := time.NewTicker(time.Second * 5)
 	defer ticker.Stop()
 
@@ -56,8 +71,10 @@ func (a *VirtualRouterAgent) Run() {
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			grList, err := a.client.CoreV0().Group().List(context.TODO())
 			if err != nil {
 				a.logger.Error(
 					"get group list",
@@ -68,7 +85,7 @@ func (a *VirtualRouterAgent) Run() {
 			}
 
 			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+				nsList, err := a.client.CoreV0().Namespace().List(context.TODO(), group.ID)
 				if err != nil {
 					a.logger.Error(
 						"get namespace list",
@@ -79,7 +96,7 @@ func (a *VirtualRouterAgent) Run() {
 				}
 
 				for _, ns := range nsList {
-					vrList, err := a.client.SystemV0().VirtualRouter().List(group.ID, ns.ID)
+					vrList, err := a.client.SystemV0().VirtualRouter().List(context.TODO(), group.ID, ns.ID)
 					if err != nil {
 						a.logger.Error(
 							"get virtualrouter list",
@@ -92,6 +109,14 @@ func (a *VirtualRouterAgent) Run() {
 					for _, vr := range vrList {
 						oldHash := vr.ResourceHash
 						if vr.Annotations[VirtualRouterV0AnnotationNodeName] != nodeName {
+							// drainなどで別のノードに移されたvirtualrouterのnetnsを削除する
+							if err := a.cleanupVirtualRouter(vr); err != nil {
+								a.logger.Error(
+									"cleanup virtualrouter",
+									zap.String("msg", err.Error()),
+									zap.Time("time", time.Now()),
+								)
+							}
 							continue
 						}
 
@@ -102,6 +127,20 @@ func (a *VirtualRouterAgent) Run() {
 								zap.String("msg", err.Error()),
 								zap.Time("time", time.Now()),
 							)
+							a.recorder.Event(vr.Meta, core.EventTypeWarning, "SyncFailed", err.Error())
+
+							// 失敗した理由をconditionに残す
+							setCondition(vr, meta.ConditionFalse, "SyncFailed", err.Error())
+							if err := setHash(vr); err != nil || vr.ResourceHash == oldHash {
+								continue
+							}
+							if _, err := a.client.SystemV0().VirtualRouter().Update(context.TODO(), vr); err != nil {
+								a.logger.Error(
+									"update virtualrouter conditions",
+									zap.String("msg", err.Error()),
+									zap.Time("time", time.Now()),
+								)
+							}
 							continue
 						}
 
@@ -109,7 +148,7 @@ func (a *VirtualRouterAgent) Run() {
 							continue
 						}
 
-						_, err := a.client.SystemV0().VirtualRouter().Update(vr)
+						_, err := a.client.SystemV0().VirtualRouter().Update(context.TODO(), vr)
 						if err != nil {
 							a.logger.Error(
 								"update virtualrouter",
@@ -121,6 +160,8 @@ func (a *VirtualRouterAgent) Run() {
 					}
 				}
 			}
+
+			a.health.Reconciled()
 		}
 	}
 }
@@ -155,7 +196,7 @@ func (a *VirtualRouterAgent) syncVirtualRouter(vr *system.VirtualRouter) error {
 	}
 
 	for _, e := range vr.Spec.ExternalIPs {
-		eip, err := a.client.CoreV0().ExternalIP().Get(e.ExternalIPID)
+		eip, err := a.client.CoreV0().ExternalIP().Get(context.TODO(), e.ExternalIPID)
 		if err != nil {
 			return err
 		}
@@ -204,7 +245,7 @@ func (a *VirtualRouterAgent) syncVirtualRouter(vr *system.VirtualRouter) error {
 			"ip", "a", "add", nic.IPv4Address, "dev", rtBrVeth,
 		})
 
-		n, err := a.client.CoreV0().Network().Get(vr.Group, vr.Namespace, nic.NetworkID)
+		n, err := a.client.CoreV0().Network().Get(context.TODO(), vr.Group, vr.Namespace, nic.NetworkID)
 		if err != nil {
 			return err
 		}
@@ -242,9 +283,25 @@ func (a *VirtualRouterAgent) syncVirtualRouter(vr *system.VirtualRouter) error {
 	}
 
 	vr.Status.State = system.VirtualRouterStateRunning
+	setCondition(vr, meta.ConditionTrue, "Running", "")
 	return setHash(vr)
 }
 
+// cleanupVirtualRouter はvirtualrouterのnetnsがこのノードに残っていれば削除する
+// netns内のvethを削除すると対になるブリッジ側のvethも削除される
+func (a *VirtualRouterAgent) cleanupVirtualRouter(vr *system.VirtualRouter) error {
+	netnsName := utils.GenerateName("netns-", vr.Group+vr.Namespace+vr.ID)
+	if !netnsIsExists(netnsName) {
+		return nil
+	}
+
+	if err := netnsDel(netnsName); err != nil {
+		return err
+	}
+	a.recorder.Eventf(vr.Meta, core.EventTypeNormal, "CleanedUp", "netns `%s` was deleted from the previous node.", netnsName)
+	return nil
+}
+
 func netnsIsExists(name string) bool {
 	_, err := os.Stat(filepath.Join("/var/run/netns", name))
 	return err == nil
@@ -288,6 +345,16 @@ func netnsExec(name string, command []string) error {
 	return cmd.Run()
 }
 
+func setCondition(vr *system.VirtualRouter, status meta.ConditionStatus, reason, message string) {
+	meta.SetCondition(&vr.Status.Conditions, meta.Condition{
+		Type:               system.VirtualRouterConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: vr.Generation,
+	})
+}
+
 func setHash(vr *system.VirtualRouter) error {
 	vr.ResourceHash = ""
 	resourceJSON, err := json.Marshal(vr)
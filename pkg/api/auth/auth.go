@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+const (
+	// ノードのagentが使うクライアント証明書のCommonNameは `node:<ノード名>` とする
+	NodeCommonNamePrefix = "node:"
+	// Core, Allモードのagentがcoreのagentで使うクライアント証明書のCommonNameは `core:<ノード名>` とする
+	// ノードの証明書と違い、他のノードのnodeリソースも変更できる
+	CoreCommonNamePrefix = "core:"
+
+	contextKeyIdentity = "humstack/identity"
+)
+
+type Identity struct {
+	Name string
+	// ノードの証明書の場合はノード名が入る
+	NodeName string
+}
+
+func NodeCommonName(nodeName string) string {
+	return NodeCommonNamePrefix + nodeName
+}
+
+func CoreCommonName(nodeName string) string {
+	return CoreCommonNamePrefix + nodeName
+}
+
+// ClientCertAuthentication は検証済みのクライアント証明書からidentityをセットする
+func ClientCertAuthentication() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		state := ctx.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+			ctx.Next()
+			return
+		}
+
+		cn := state.VerifiedChains[0][0].Subject.CommonName
+		identity := &Identity{
+			Name: cn,
+		}
+		if strings.HasPrefix(cn, NodeCommonNamePrefix) {
+			identity.NodeName = strings.TrimPrefix(cn, NodeCommonNamePrefix)
+		}
+		ctx.Set(contextKeyIdentity, identity)
+		ctx.Next()
+	}
+}
+
+func GetIdentity(ctx *gin.Context) (*Identity, bool) {
+	v, ok := ctx.Get(contextKeyIdentity)
+	if !ok {
+		return nil, false
+	}
+	identity, ok := v.(*Identity)
+	return identity, ok
+}
+
+// AuthorizeNode はノードの証明書で別のノードを変更しようとしている場合に403を返す
+// coreの証明書などノード以外の証明書は制限しない
+func AuthorizeNode(ctx *gin.Context, nodeID string) bool {
+	identity, ok := GetIdentity(ctx)
+	if !ok || identity.NodeName == "" || identity.NodeName == nodeID {
+		return true
+	}
+
+	meta.ResponseJSON(ctx, http.StatusForbidden, fmt.Errorf("Error: `%s` can't modify node `%s`.", identity.Name, nodeID), nil)
+	return false
+}
+
+// AuthorizeNodeObject はノードの証明書で、そのノードに割り当てられていないリソースを変更しようとしている場合に403を返す
+// nodeNamesには保存されているリソースと更新後のリソースの `*_node_name` annotationを渡す
+// coreの証明書などノード以外の証明書は制限しない
+func AuthorizeNodeObject(ctx *gin.Context, kind, id string, nodeNames ...string) bool {
+	identity, ok := GetIdentity(ctx)
+	if !ok || identity.NodeName == "" {
+		return true
+	}
+
+	for _, nodeName := range nodeNames {
+		if nodeName != identity.NodeName {
+			meta.ResponseJSON(ctx, http.StatusForbidden, fmt.Errorf("Error: `%s` can't modify %s `%s` assigned to node `%s`.", identity.Name, kind, id, nodeName), nil)
+			return false
+		}
+	}
+	return true
+}
+
+// ノードの証明書で作成できるリソース
+var nodeCreatableResources = map[string]bool{
+	"nodes":  true,
+	"leases": true,
+	"events": true,
+}
+
+// ノードの証明書で更新できるリソース
+// node, lease, event以外はハンドラでAuthorizeNodeObjectを使い、そのノードに割り当てられたものか確認する
+var nodeUpdatableResources = map[string]bool{
+	"nodes":           true,
+	"leases":          true,
+	"events":          true,
+	"virtualmachines": true,
+	"blockstorages":   true,
+	"virtualrouters":  true,
+	"imageentities":   true,
+	"nodenetworks":    true,
+}
+
+// RestrictNode はノードの証明書で行えるリクエストを、ノードのagentが使うものだけに制限する
+// ノードの証明書が漏れても、network, externalipなどの共有のリソースを変更されないようにする
+// 削除はcoreのagentとユーザーが行うので、ノードの証明書では許可しない
+func RestrictNode() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		identity, ok := GetIdentity(ctx)
+		if !ok || identity.NodeName == "" {
+			ctx.Next()
+			return
+		}
+
+		resource := resourceName(ctx.FullPath())
+		allowed := false
+		switch ctx.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			allowed = true
+		case http.MethodPost:
+			allowed = nodeCreatableResources[resource]
+		case http.MethodPut:
+			allowed = nodeUpdatableResources[resource]
+		}
+		if !allowed {
+			meta.ResponseJSON(ctx, http.StatusForbidden, fmt.Errorf("Error: `%s` can't %s %s.", identity.Name, ctx.Request.Method, resource), nil)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// resourceName はルートのパスからリソースの種類を返す
+// `/api/v0/groups/:group_id/namespaces/:namespace_id/blockstorages/:block_storage_id/status` の場合は `blockstorages` を返す
+func resourceName(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if strings.HasPrefix(segments[i], ":") || segments[i] == "status" {
+			continue
+		}
+		return segments[i]
+	}
+	return ""
+}
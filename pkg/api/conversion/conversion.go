@@ -0,0 +1,279 @@
+package conversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	systemv1 "github.com/ophum/humstack/pkg/api/system/v1"
+	"github.com/pkg/errors"
+)
+
+type Version string
+
+const (
+	V0 Version = "v0"
+	V1 Version = "v1"
+
+	// StorageVersion はstoreに保存する際のバージョン
+	StorageVersion = V1
+)
+
+// kind はv0とv1で形が異なるリソース
+type kind struct {
+	// storeのkeyの先頭
+	keyPrefix string
+	apiTypes  map[Version]meta.APIType
+	toV1      func(data []byte) (interface{}, error)
+	toV0      func(data []byte) (interface{}, error)
+	key       func(m meta.Meta) string
+}
+
+var kinds = []*kind{
+	{
+		keyPrefix: "blockstorage",
+		apiTypes: map[Version]meta.APIType{
+			V0: meta.APITypeBlockStorageV0,
+			V1: meta.APITypeBlockStorageV1,
+		},
+		toV1: func(data []byte) (interface{}, error) {
+			in := &system.BlockStorage{}
+			if err := json.Unmarshal(data, in); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertBlockStorageFromV0(in), nil
+		},
+		toV0: func(data []byte) (interface{}, error) {
+			in := &systemv1.BlockStorage{}
+			if err := json.Unmarshal(data, in); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertBlockStorageToV0(in), nil
+		},
+		key: func(m meta.Meta) string {
+			return filepath.Join("blockstorage", m.Group, m.Namespace, m.ID)
+		},
+	},
+	{
+		keyPrefix: "imageentities",
+		apiTypes: map[Version]meta.APIType{
+			V0: meta.APITypeImageEntityV0,
+			V1: meta.APITypeImageEntityV1,
+		},
+		toV1: func(data []byte) (interface{}, error) {
+			in := &system.ImageEntity{}
+			if err := json.Unmarshal(data, in); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertImageEntityFromV0(in), nil
+		},
+		toV0: func(data []byte) (interface{}, error) {
+			in := &systemv1.ImageEntity{}
+			if err := json.Unmarshal(data, in); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertImageEntityToV0(in), nil
+		},
+		key: func(m meta.Meta) string {
+			return filepath.Join("imageentities", m.Group, m.ID)
+		},
+	},
+	{
+		keyPrefix: "virtualmachine",
+		apiTypes: map[Version]meta.APIType{
+			V0: meta.APITypeVirtualMachineV0,
+			V1: meta.APITypeVirtualMachineV1,
+		},
+		toV1: func(data []byte) (interface{}, error) {
+			in := &system.VirtualMachine{}
+			if err := json.Unmarshal(data, in); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertVirtualMachineFromV0(in), nil
+		},
+		toV0: func(data []byte) (interface{}, error) {
+			in := &systemv1.VirtualMachine{}
+			if err := json.Unmarshal(data, in); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertVirtualMachineToV0(in), nil
+		},
+		key: func(m meta.Meta) string {
+			return filepath.Join("virtualmachine", m.Group, m.Namespace, m.ID)
+		},
+	},
+	{
+		keyPrefix: "virtualrouter",
+		apiTypes: map[Version]meta.APIType{
+			V0: meta.APITypeVirtualRouterV0,
+			V1: meta.APITypeVirtualRouterV1,
+		},
+		toV1: func(data []byte) (interface{}, error) {
+			in := &system.VirtualRouter{}
+			if err := json.Unmarshal(data, in); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertVirtualRouterFromV0(in), nil
+		},
+		toV0: func(data []byte) (interface{}, error) {
+			in := &systemv1.VirtualRouter{}
+			if err := json.Unmarshal(data, in); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertVirtualRouterToV0(in), nil
+		},
+		key: func(m meta.Meta) string {
+			return filepath.Join("virtualrouter", m.Group, m.Namespace, m.ID)
+		},
+	},
+}
+
+// renamedAPITypes はv0とv1で形が変わらないリソースのv0とv1のapiType
+// storeにはv0の形のまま保存し、v1のAPIではapiTypeだけを書き換える
+var renamedAPITypes = map[meta.APIType]meta.APIType{
+	meta.APITypeGroupV0:          meta.APITypeGroupV1,
+	meta.APITypeNamespaceV0:      meta.APITypeNamespaceV1,
+	meta.APITypeNetworkV0:        meta.APITypeNetworkV1,
+	meta.APITypeExternalIPPoolV0: meta.APITypeExternalIPPoolV1,
+	meta.APITypeExternalIPV0:     meta.APITypeExternalIPV1,
+	meta.APITypeEventV0:          meta.APITypeEventV1,
+	meta.APITypeLeaseV0:          meta.APITypeLeaseV1,
+	meta.APITypeNodeV0:           meta.APITypeNodeV1,
+	meta.APITypeNodeNetworkV0:    meta.APITypeNodeNetworkV1,
+	meta.APITypeImageV0:          meta.APITypeImageV1,
+	meta.APITypeImageTagV0:       meta.APITypeImageTagV1,
+}
+
+// renameAPIType は形が変わらないリソースのapiTypeをversionのものにする
+// それ以外のapiTypeはそのまま返す
+func renameAPIType(apiType meta.APIType, version Version) meta.APIType {
+	for v0, v1 := range renamedAPITypes {
+		if apiType != v0 && apiType != v1 {
+			continue
+		}
+		if version == V1 {
+			return v1
+		}
+		return v0
+	}
+	return apiType
+}
+
+// renameObject はJSONをデコードしたobjのmeta.apiTypeをversionのものにする
+// objが配列の場合は各要素を書き換える
+func renameObject(obj interface{}, version Version) {
+	switch o := obj.(type) {
+	case []interface{}:
+		for _, e := range o {
+			renameObject(e, version)
+		}
+	case map[string]interface{}:
+		m, ok := o["meta"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		if apiType, ok := m["apiType"].(string); ok {
+			m["apiType"] = string(renameAPIType(meta.APIType(apiType), version))
+		}
+	}
+}
+
+// renameJSON はJSONのリソースのapiTypeをversionのものにする
+func renameJSON(data []byte, version Version) ([]byte, error) {
+	obj := meta.Object{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	if renameAPIType(obj.Meta.APIType, version) == obj.Meta.APIType {
+		return data, nil
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	renameObject(v, version)
+	return json.Marshal(v)
+}
+
+// findKind はstoreのkeyからリソースの種類を探す
+// バージョンによって形が変わらないリソースの場合はnilを返す
+func findKind(key string) *kind {
+	prefix := strings.SplitN(key, "/", 2)[0]
+	for _, k := range kinds {
+		if k.keyPrefix == prefix {
+			return k
+		}
+	}
+	return nil
+}
+
+// versionOf は保存されているデータのバージョンをapiTypeから判定する
+// apiTypeが空の古いデータはv0として扱う
+func (k *kind) versionOf(data []byte) (Version, error) {
+	obj := meta.Object{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return "", err
+	}
+	for v, apiType := range k.apiTypes {
+		if obj.Meta.APIType == apiType {
+			return v, nil
+		}
+	}
+	return V0, nil
+}
+
+func (k *kind) convert(data []byte, from, to Version) ([]byte, error) {
+	if from == to {
+		return data, nil
+	}
+
+	var out interface{}
+	var err error
+	switch to {
+	case V1:
+		out, err = k.toV1(data)
+	case V0:
+		out, err = k.toV0(data)
+	default:
+		return nil, errors.Errorf("unknown version `%s`", to)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "convert %s from %s to %s", k.keyPrefix, from, to)
+	}
+
+	return json.Marshal(out)
+}
+
+// Convert はkeyに保存されているデータをversionの形に変換する
+// 形が変わらないリソースはapiTypeだけを書き換える
+func Convert(key string, data []byte, version Version) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	k := findKind(key)
+	if k == nil {
+		return renameJSON(data, version)
+	}
+
+	from, err := k.versionOf(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.convert(data, from, version)
+}
+
+// APIType はkeyのリソースのversionでのapiTypeを返す
+func APIType(key string, version Version, apiType meta.APIType) meta.APIType {
+	k := findKind(key)
+	if k == nil {
+		return renameAPIType(apiType, version)
+	}
+	return k.apiTypes[version]
+}
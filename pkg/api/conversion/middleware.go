@@ -0,0 +1,112 @@
+package conversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+// RenameAPIType はv0とv1で形が変わらないリソースのv0のハンドラをversionのAPIで提供するためのmiddleware
+// リクエストのbodyのapiTypeをv0に、レスポンスのbodyのapiTypeをversionに書き換える
+// JSON以外のレスポンス(ダウンロードやwebsocket)はそのまま返す
+func RenameAPIType(version Version) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := renameRequest(ctx.Request); err != nil {
+			meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+			ctx.Abort()
+			return
+		}
+
+		w := &renameWriter{
+			ResponseWriter: ctx.Writer,
+			version:        version,
+		}
+		ctx.Writer = w
+		ctx.Next()
+		ctx.Writer = w.ResponseWriter
+		w.flush()
+	}
+}
+
+// renameRequest はリクエストのbodyのapiTypeをv0にする
+// JSONとして読めないbodyはそのままハンドラに渡し、ハンドラでエラーにする
+func renameRequest(req *http.Request) error {
+	if req.Body == nil || req.ContentLength == 0 {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&v); err == nil {
+		renameObject(v, V0)
+		if renamed, err := json.Marshal(v); err == nil {
+			body = renamed
+		}
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	return nil
+}
+
+// renameWriter はJSONのレスポンスを溜めておき、ハンドラの処理後にapiTypeを書き換えて書き込む
+type renameWriter struct {
+	gin.ResponseWriter
+	version Version
+
+	buf bytes.Buffer
+	// 最初の書き込みの時点でJSONでなければ溜めずにそのまま書き込む
+	decided     bool
+	passThrough bool
+}
+
+func (w *renameWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.passThrough = !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+	}
+	if w.passThrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *renameWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// flush は溜めたレスポンスの `data` に含まれるリソースのapiTypeを書き換えて書き込む
+func (w *renameWriter) flush() {
+	if w.buf.Len() == 0 {
+		return
+	}
+
+	body := w.buf.Bytes()
+	res := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&res); err == nil {
+		if data, ok := res["data"].(map[string]interface{}); ok {
+			for _, v := range data {
+				renameObject(v, w.version)
+			}
+		}
+		if renamed, err := json.Marshal(res); err == nil {
+			body = renamed
+		}
+	}
+
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,191 @@
+package conversion
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/pkg/errors"
+)
+
+// Store は保存されているデータをversionの形で読み書きする
+// 同じstoreをv0とv1のハンドラで共有するために使う
+type Store struct {
+	store   store.Store
+	version Version
+}
+
+func NewStore(s store.Store, version Version) *Store {
+	return &Store{
+		store:   s,
+		version: version,
+	}
+}
+
+func (s *Store) List(prefix string, f func(n int) []interface{}) error {
+	k := findKind(prefix)
+	if k == nil {
+		return s.store.List(prefix, f)
+	}
+
+	rawList := []*json.RawMessage{}
+	if err := s.store.List(prefix, func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			raw := &json.RawMessage{}
+			rawList = append(rawList, raw)
+			m = append(m, raw)
+		}
+		return m
+	}); err != nil {
+		return err
+	}
+
+	m := f(len(rawList))
+	for i, raw := range rawList {
+		from, err := k.versionOf(*raw)
+		if err != nil {
+			return err
+		}
+		data, err := k.convert(*raw, from, s.version)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, m[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) Get(key string, v interface{}) error {
+	k := findKind(key)
+	if k == nil {
+		return s.store.Get(key, v)
+	}
+
+	raw := json.RawMessage{}
+	if err := s.store.Get(key, &raw); err != nil {
+		return err
+	}
+
+	from, err := k.versionOf(raw)
+	if err != nil {
+		return err
+	}
+	data, err := k.convert(raw, from, s.version)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// Put はdataをStorageVersionに変換して書き込む
+// 変換できないデータは書き込まないので、ハンドラはPutの前にCheckでエラーを返しておく
+func (s *Store) Put(key string, data interface{}) {
+	raw, err := s.encode(key, data)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	s.store.Put(key, raw)
+}
+
+// encode はバージョンによって形が変わるリソースをStorageVersionのJSONにする
+// それ以外のリソースはそのまま返す
+func (s *Store) encode(key string, data interface{}) (interface{}, error) {
+	k := findKind(key)
+	if k == nil {
+		return data, nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "marshal `%s`", key)
+	}
+
+	raw, err = k.convert(raw, s.version, StorageVersion)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(raw), nil
+}
+
+// Check はPutでdataをStorageVersionに変換して書き込めるかを確認する
+// conversion.Store以外のstoreでは何もしない
+func Check(s store.Store, key string, data interface{}) error {
+	cs, ok := s.(*Store)
+	if !ok {
+		return nil
+	}
+	_, err := cs.encode(key, data)
+	return err
+}
+
+func (s *Store) Delete(key string) {
+	s.store.Delete(key)
+}
+
+func (s *Store) Lock(key string) {
+	s.store.Lock(key)
+}
+
+func (s *Store) Unlock(key string) {
+	s.store.Unlock(key)
+}
+
+func (s *Store) Keys(prefix string) ([]string, error) {
+	return s.store.Keys(prefix)
+}
+
+// Migrate はStorageVersionでないデータをStorageVersionに書き換える
+// 書き換えたリソースの数を返す
+func Migrate(s store.Store) (int, error) {
+	n := 0
+	for _, k := range kinds {
+		keys, err := s.Keys(k.keyPrefix + "/")
+		if err != nil {
+			return n, errors.Wrapf(err, "list %s keys", k.keyPrefix)
+		}
+
+		for _, key := range keys {
+			migrated, err := migrate(s, k, key)
+			if err != nil {
+				return n, errors.Wrapf(err, "migrate `%s`", key)
+			}
+			if migrated {
+				n++
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func migrate(s store.Store, k *kind, key string) (bool, error) {
+	s.Lock(key)
+	defer s.Unlock(key)
+
+	raw := json.RawMessage{}
+	if err := s.Get(key, &raw); err != nil {
+		return false, err
+	}
+
+	from, err := k.versionOf(raw)
+	if err != nil {
+		return false, err
+	}
+	if from == StorageVersion {
+		return false, nil
+	}
+
+	data, err := k.convert(raw, from, StorageVersion)
+	if err != nil {
+		return false, err
+	}
+
+	s.Put(key, json.RawMessage(data))
+	return true, nil
+}
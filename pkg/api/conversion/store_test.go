@@ -0,0 +1,160 @@
+package conversion
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	systemv1 "github.com/ophum/humstack/pkg/api/system/v1"
+	"github.com/ophum/humstack/pkg/store/leveldb"
+	"github.com/ophum/humstack/pkg/store/memory"
+)
+
+func TestMigrateAndServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conversion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := leveldb.NewLevelDBStore(dir, make(chan string, 100), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// v0で保存されている古いデータ
+	key := "virtualmachine/group1/ns1/vm1"
+	s.Put(key, &system.VirtualMachine{
+		Meta: meta.Meta{
+			ID:        "vm1",
+			Group:     "group1",
+			Namespace: "ns1",
+			APIType:   meta.APITypeVirtualMachineV0,
+			Annotations: map[string]string{
+				"virtualmachinev0/node_name": "node1",
+			},
+		},
+	})
+
+	n, err := Migrate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("unexpected migrated count: %d", n)
+	}
+
+	stored := systemv1.VirtualMachine{}
+	if err := s.Get(key, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.APIType != meta.APITypeVirtualMachineV1 || stored.Spec.Placement.NodeName != "node1" {
+		t.Fatalf("unexpected stored object: %+v", stored)
+	}
+
+	// 2回目は何もしない
+	if n, err := Migrate(s); err != nil || n != 0 {
+		t.Fatalf("unexpected second migration: %d, %v", n, err)
+	}
+
+	// v0のハンドラからはannotationとして見える
+	sv0 := NewStore(s, V0)
+	vm := system.VirtualMachine{}
+	if err := sv0.Get(key, &vm); err != nil {
+		t.Fatal(err)
+	}
+	if vm.APIType != meta.APITypeVirtualMachineV0 || vm.Annotations["virtualmachinev0/node_name"] != "node1" {
+		t.Fatalf("unexpected v0 object: %+v", vm)
+	}
+
+	// v0での書き込みもv1で保存される
+	vm.Annotations["virtualmachinev0/node_name"] = "node2"
+	sv0.Put(key, vm)
+
+	sv1 := NewStore(s, V1)
+	list := []*systemv1.VirtualMachine{}
+	if err := sv1.List("virtualmachine/group1/ns1", func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			vm := &systemv1.VirtualMachine{}
+			list = append(list, vm)
+			m = append(m, vm)
+		}
+		return m
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Spec.Placement.NodeName != "node2" {
+		t.Fatalf("unexpected v1 list: %+v", list)
+	}
+
+	// 形の変わらないリソースはそのまま読み書きする
+	sv1.Put("group/group1", map[string]string{"id": "group1"})
+	group := map[string]string{}
+	if err := sv0.Get("group/group1", &group); err != nil || group["id"] != "group1" {
+		t.Fatalf("unexpected group: %v, %v", group, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := NewStore(memory.NewMemoryStore(), V0)
+	key := "virtualmachine/group1/ns1/vm1"
+
+	if err := Check(s, key, &system.VirtualMachine{
+		Meta: meta.Meta{ID: "vm1", APIType: meta.APITypeVirtualMachineV0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// v0のVirtualMachineとして読めないデータは変換できない
+	invalid := map[string]interface{}{
+		"meta": map[string]interface{}{"id": "vm1"},
+		"spec": "invalid",
+	}
+	if err := Check(s, key, invalid); err == nil {
+		t.Fatal("expected an error")
+	}
+	s.Put(key, invalid)
+	if keys, _ := s.Keys(key); len(keys) != 0 {
+		t.Fatalf("expected nothing to be stored, but got %v", keys)
+	}
+
+	// 形が変わらないリソースは確認しない
+	if err := Check(s, "group/group1", invalid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConvertRenamesAPIType(t *testing.T) {
+	key := "namespace/group1/ns1"
+	data := []byte(`{"meta":{"id":"ns1","apiType":"corev0/namespace","generation":1},"spec":{}}`)
+
+	v1, err := Convert(key, data, V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns := core.Namespace{}
+	if err := json.Unmarshal(v1, &ns); err != nil {
+		t.Fatal(err)
+	}
+	if ns.APIType != meta.APITypeNamespaceV1 || ns.ID != "ns1" || ns.Generation != 1 {
+		t.Fatalf("unexpected v1 namespace: %+v", ns)
+	}
+	if got := APIType(key, V1, meta.APITypeNamespaceV0); got != meta.APITypeNamespaceV1 {
+		t.Fatalf("unexpected apiType: %s", got)
+	}
+
+	// v0の場合は書き換えない
+	v0, err := Convert(key, data, V0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v0) != string(data) {
+		t.Fatalf("unexpected v0 data: %s", v0)
+	}
+}
@@ -0,0 +1,40 @@
+package event
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type EventHandlerInterface interface {
+	FindAll(ctx *gin.Context)
+	Find(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+const (
+	basePath = "events"
+)
+
+type EventHandler struct {
+	router *gin.RouterGroup
+	ehi    EventHandlerInterface
+}
+
+func NewEventHandler(router *gin.RouterGroup, ehi EventHandlerInterface) *EventHandler {
+	return &EventHandler{
+		router: router,
+		ehi:    ehi,
+	}
+}
+
+func (h *EventHandler) RegisterHandlers() {
+	ev := h.router.Group(basePath)
+	{
+		ev.GET("", h.ehi.FindAll)
+		ev.GET("/:event_id", h.ehi.Find)
+		ev.POST("", h.ehi.Create)
+		ev.PUT("/:event_id", h.ehi.Update)
+		ev.DELETE("/:event_id", h.ehi.Delete)
+	}
+}
@@ -0,0 +1,253 @@
+package v0
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/core/event"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+type EventHandler struct {
+	event.EventHandlerInterface
+
+	store store.Store
+}
+
+func NewEventHandler(store store.Store) *EventHandler {
+	return &EventHandler{
+		store: store,
+	}
+}
+
+func (h *EventHandler) list() []*core.Event {
+	eventList := []*core.Event{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			event := &core.Event{}
+			eventList = append(eventList, event)
+			m = append(m, event)
+		}
+		return m
+	}
+
+	h.store.List(getKey("")+"/", f)
+	return eventList
+}
+
+// FindAll はクエリで指定されたリソースのイベントのみを返す
+// apiType, group, namespace, id のうち指定されたものだけで絞り込む
+func (h *EventHandler) FindAll(ctx *gin.Context) {
+	apiType := meta.APIType(ctx.Query("apiType"))
+	groupID := ctx.Query("group")
+	namespaceID := ctx.Query("namespace")
+	id := ctx.Query("id")
+
+	eventList := []*core.Event{}
+	for _, e := range h.list() {
+		obj := e.Spec.InvolvedObject
+		if (apiType != "" && obj.APIType != apiType) ||
+			(groupID != "" && obj.Group != groupID) ||
+			(namespaceID != "" && obj.Namespace != namespaceID) ||
+			(id != "" && obj.ID != id) {
+			continue
+		}
+		eventList = append(eventList, e)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"events": eventList,
+	})
+}
+
+func (h *EventHandler) Find(ctx *gin.Context) {
+	eventID := getEventID(ctx)
+
+	var event core.Event
+	err := h.store.Get(getKey(eventID), &event)
+	if err != nil && err.Error() == "Not Found" {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Event `%s` is not found.", eventID), nil)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"event": event,
+	})
+}
+
+func (h *EventHandler) Create(ctx *gin.Context) {
+	var request core.Event
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	// ノードの証明書では自身が報告するイベントのみ作成できる
+	if !auth.AuthorizeNode(ctx, request.Spec.ReportingNode) {
+		return
+	}
+
+	if err := validate(&request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	key := getKey(request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var e core.Event
+	err = h.store.Get(key, &e)
+	if err == nil {
+		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Event `%s` is already exists.", request.ID), nil)
+		return
+	}
+
+	now := time.Now()
+	if request.Spec.FirstTimestamp.IsZero() {
+		request.Spec.FirstTimestamp = now
+	}
+	if request.Spec.LastTimestamp.IsZero() {
+		request.Spec.LastTimestamp = request.Spec.FirstTimestamp
+	}
+	if request.Spec.Count < 1 {
+		request.Spec.Count = 1
+	}
+
+	request.APIType = meta.APITypeEventV0
+	request.UID = uuid.New().String()
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
+		"event": request,
+	})
+}
+
+func (h *EventHandler) Update(ctx *gin.Context) {
+	eventID := getEventID(ctx)
+
+	var request core.Event
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if eventID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change Event ID."), nil)
+		return
+	}
+
+	if !auth.AuthorizeNode(ctx, request.Spec.ReportingNode) {
+		return
+	}
+
+	if err := validate(&request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	key := getKey(eventID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var e core.Event
+	err = h.store.Get(key, &e)
+	if err != nil && err.Error() == "Not Found" {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: Event `%s` is not found.", eventID), nil)
+		return
+	}
+
+	// uid, deletionTimestamp, 初回の発生時刻はサーバー側で管理する
+	request.APIType = meta.APITypeEventV0
+	request.UID = e.UID
+	request.DeletionTimestamp = e.DeletionTimestamp
+	request.KeepFinalizers(e.Finalizers)
+	request.Spec.FirstTimestamp = e.Spec.FirstTimestamp
+	if request.Spec.LastTimestamp.IsZero() {
+		request.Spec.LastTimestamp = time.Now()
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"event": request,
+	})
+}
+
+func (h *EventHandler) Delete(ctx *gin.Context) {
+	eventID := getEventID(ctx)
+
+	key := getKey(eventID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"event": nil,
+	})
+}
+
+// DeleteExpired は最後に発生してからttl以上経過したイベントを削除する
+// 削除したイベントの数を返す
+func (h *EventHandler) DeleteExpired(ttl time.Duration) int {
+	expiredAt := time.Now().Add(-ttl)
+
+	n := 0
+	for _, e := range h.list() {
+		if e.Spec.LastTimestamp.After(expiredAt) {
+			continue
+		}
+
+		key := getKey(e.ID)
+		h.store.Lock(key)
+		h.store.Delete(key)
+		h.store.Unlock(key)
+		n++
+	}
+	return n
+}
+
+func validate(event *core.Event) error {
+	if event.ID == "" {
+		return fmt.Errorf("Error: id is empty.")
+	}
+
+	if event.Spec.InvolvedObject.ID == "" {
+		return fmt.Errorf("Error: involvedObject.id is empty.")
+	}
+
+	switch event.Spec.Type {
+	case core.EventTypeNormal, core.EventTypeWarning:
+	default:
+		return fmt.Errorf("Error: type must be `%s` or `%s`.", core.EventTypeNormal, core.EventTypeWarning)
+	}
+
+	if event.Spec.Reason == "" {
+		return fmt.Errorf("Error: reason is empty.")
+	}
+	return nil
+}
+
+func getEventID(ctx *gin.Context) string {
+	return ctx.Param("event_id")
+}
+
+func getKey(name string) string {
+	return filepath.Join("event", name)
+}
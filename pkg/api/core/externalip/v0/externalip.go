@@ -5,10 +5,14 @@ import (
 	"log"
 	"net/http"
 	"path/filepath"
+	"reflect"
+	"sort"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/externalip"
+	"github.com/ophum/humstack/pkg/api/core/externalippool"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/store"
 )
@@ -20,9 +24,15 @@ type ExternalIPHandler struct {
 }
 
 func NewExternalIPHandler(store store.Store) *ExternalIPHandler {
-	return &ExternalIPHandler{
+	h := &ExternalIPHandler{
 		store: store,
 	}
+
+	// 使用中のアドレスを記録する前に作られたexternalipがあるので、起動時に作り直す
+	if n := h.rebuildUsedAddresses(); n != 0 {
+		log.Printf("rebuilt used addresses of %d externalippools", n)
+	}
+	return h
 }
 
 func (h *ExternalIPHandler) FindAll(ctx *gin.Context) {
@@ -77,6 +87,9 @@ func (h *ExternalIPHandler) Create(ctx *gin.Context) {
 	}
 
 	key := getKey(request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var eip core.ExternalIP
 	err = h.store.Get(key, &eip)
 	if err == nil {
@@ -84,11 +97,18 @@ func (h *ExternalIPHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// アドレスが指定されていなければプールから割り当てる
+	if code, err := h.assignAddresses(&request, meta.IsDryRun(ctx)); err != nil {
+		meta.ResponseJSON(ctx, code, err, nil)
+		return
+	}
 
 	request.APIType = meta.APITypeExternalIPV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"externalip": request,
@@ -111,6 +131,9 @@ func (h *ExternalIPHandler) Update(ctx *gin.Context) {
 	}
 
 	key := getKey(request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var eip core.ExternalIP
 	err = h.store.Get(key, &eip)
 	if err != nil && err.Error() == "Not Found" {
@@ -118,10 +141,34 @@ func (h *ExternalIPHandler) Update(ctx *gin.Context) {
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// 割り当てたアドレスは変更できない
+	// 省略された場合は割り当て済みのアドレスを引き継ぐ
+	if request.Spec.IPv4Address == "" {
+		request.Spec.IPv4Address = eip.Spec.IPv4Address
+	}
+	if request.Spec.IPv6Address == "" {
+		request.Spec.IPv6Address = eip.Spec.IPv6Address
+	}
+	if request.Spec.PoolID != eip.Spec.PoolID ||
+		request.Spec.IPv4Address != eip.Spec.IPv4Address ||
+		request.Spec.IPv6Address != eip.Spec.IPv6Address {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: can't change poolID or address."), nil)
+		return
+	}
 
-	h.store.Put(key, request)
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.UID = eip.UID
+	request.Generation = meta.NextGeneration(eip.Generation, eip.Spec, request.Spec)
+	request.DeletionTimestamp = eip.DeletionTimestamp
+	request.KeepFinalizers(eip.Finalizers)
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+
+		// finalizerが外れて削除された場合はアドレスを解放する
+		if request.IsRemovable() {
+			h.releaseAddresses(&request)
+		}
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"externalip": request,
@@ -135,7 +182,225 @@ func (h *ExternalIPHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var eip core.ExternalIP
+	if err := h.store.Get(key, &eip); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ExternalIP `%s` is not found.", eipID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		eip.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(eip.Finalizers) != 0 {
+		eip.MarkDeletion()
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, eip)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"externalip": eip,
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+		h.releaseAddresses(&eip)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"externalip": nil,
+	})
+}
+
+// assignAddresses はプールのアドレスをexternalipに割り当て、使用中として記録する
+// アドレスが指定されている場合はプールの範囲内で未使用であるかを確認する
+// dryRunの場合はアドレスを選ぶだけで記録しない
+// 失敗した場合はレスポンスのステータスコードとエラーを返す
+func (h *ExternalIPHandler) assignAddresses(eip *core.ExternalIP, dryRun bool) (int, error) {
+	if eip.Spec.PoolID == "" {
+		return http.StatusOK, nil
+	}
+
+	key := getPoolKey(eip.Spec.PoolID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var pool core.ExternalIPPool
+	if err := h.store.Get(key, &pool); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Error: externalippool `%s` is not found.", eip.Spec.PoolID)
+	}
+
+	if pool.Status.UsedIPv4Addresses == nil {
+		pool.Status.UsedIPv4Addresses = map[string]core.ExternalIPPoolUsed{}
+	}
+	if pool.Status.UsedIPv6Addresses == nil {
+		pool.Status.UsedIPv6Addresses = map[string]core.ExternalIPPoolUsed{}
+	}
+
+	ipv4Address, ipv4Prefix, code, err := assignAddress(
+		pool.Spec.IPv4CIDR, eip.Spec.IPv4Address, eip.Spec.IPv4Prefix,
+		pool.Status.UsedIPv4Addresses, pool.Spec.DefaultGateway)
+	if err != nil {
+		return code, err
+	}
+
+	ipv6Address, ipv6Prefix, code, err := assignAddress(
+		pool.Spec.IPv6CIDR, eip.Spec.IPv6Address, eip.Spec.IPv6Prefix,
+		pool.Status.UsedIPv6Addresses, pool.Spec.DefaultGateway)
+	if err != nil {
+		return code, err
+	}
+
+	eip.Spec.IPv4Address, eip.Spec.IPv4Prefix = ipv4Address, ipv4Prefix
+	eip.Spec.IPv6Address, eip.Spec.IPv6Prefix = ipv6Address, ipv6Prefix
+	if ipv4Address != "" {
+		pool.Status.UsedIPv4Addresses[ipv4Address] = core.ExternalIPPoolUsed{UsedExternalIPID: eip.ID}
+	}
+	if ipv6Address != "" {
+		pool.Status.UsedIPv6Addresses[ipv6Address] = core.ExternalIPPoolUsed{UsedExternalIPID: eip.ID}
+	}
+	if !dryRun {
+		h.store.Put(key, pool)
+	}
+
+	return http.StatusOK, nil
+}
+
+// assignAddress はcidrからアドレスを1つ選ぶ
+// cidrが空の場合はプールにそのアドレスファミリーがないため、アドレスの指定はエラーにする
+func assignAddress(cidr, address string, prefix int32, used map[string]core.ExternalIPPoolUsed, reserved ...string) (string, int32, int, error) {
+	if cidr == "" {
+		if address != "" {
+			return "", 0, http.StatusBadRequest, fmt.Errorf("Error: externalippool doesn't have cidr for `%s`.", address)
+		}
+		return "", prefix, http.StatusOK, nil
+	}
+
+	if address == "" {
+		a, err := externalippool.Allocate(cidr, used, reserved...)
+		if err != nil {
+			return "", 0, http.StatusConflict, fmt.Errorf("Error: %s", err.Error())
+		}
+		address = a
+	} else {
+		a, err := externalippool.Validate(cidr, address)
+		if err != nil {
+			return "", 0, http.StatusBadRequest, fmt.Errorf("Error: %s", err.Error())
+		}
+		if u, ok := used[a]; ok {
+			return "", 0, http.StatusConflict, fmt.Errorf("Error: `%s` is already used by externalip `%s`.", a, u.UsedExternalIPID)
+		}
+		address = a
+	}
+
+	if prefix == 0 {
+		p, err := externalippool.Prefix(cidr)
+		if err != nil {
+			return "", 0, http.StatusBadRequest, err
+		}
+		prefix = p
+	}
+	return address, prefix, http.StatusOK, nil
+}
+
+// rebuildUsedAddresses は保存されているexternalipからプールの使用中のアドレスを作り直し、変更したプールの数を返す
+// 同じアドレスを複数のexternalipが使っている場合は、IDが先のものを記録する
+func (h *ExternalIPHandler) rebuildUsedAddresses() int {
+	eipList := []*core.ExternalIP{}
+	h.store.List(getKey("")+"/", func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			eip := &core.ExternalIP{}
+			eipList = append(eipList, eip)
+			m = append(m, eip)
+		}
+		return m
+	})
+	sort.Slice(eipList, func(i, j int) bool {
+		return eipList[i].ID < eipList[j].ID
+	})
+
+	poolList := []*core.ExternalIPPool{}
+	h.store.List(getPoolKey("")+"/", func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			pool := &core.ExternalIPPool{}
+			poolList = append(poolList, pool)
+			m = append(m, pool)
+		}
+		return m
+	})
+
+	rebuilt := 0
+	for _, pool := range poolList {
+		ipv4 := map[string]core.ExternalIPPoolUsed{}
+		ipv6 := map[string]core.ExternalIPPoolUsed{}
+		for _, eip := range eipList {
+			if eip.Spec.PoolID != pool.ID {
+				continue
+			}
+			if a := eip.Spec.IPv4Address; a != "" {
+				if _, ok := ipv4[a]; !ok {
+					ipv4[a] = core.ExternalIPPoolUsed{UsedExternalIPID: eip.ID}
+				}
+			}
+			if a := eip.Spec.IPv6Address; a != "" {
+				if _, ok := ipv6[a]; !ok {
+					ipv6[a] = core.ExternalIPPoolUsed{UsedExternalIPID: eip.ID}
+				}
+			}
+		}
+
+		if reflect.DeepEqual(ipv4, nonNil(pool.Status.UsedIPv4Addresses)) &&
+			reflect.DeepEqual(ipv6, nonNil(pool.Status.UsedIPv6Addresses)) {
+			continue
+		}
+
+		key := getPoolKey(pool.ID)
+		h.store.Lock(key)
+		pool.Status.UsedIPv4Addresses = ipv4
+		pool.Status.UsedIPv6Addresses = ipv6
+		h.store.Put(key, *pool)
+		h.store.Unlock(key)
+		rebuilt++
+	}
+	return rebuilt
+}
+
+func nonNil(used map[string]core.ExternalIPPoolUsed) map[string]core.ExternalIPPoolUsed {
+	if used == nil {
+		return map[string]core.ExternalIPPoolUsed{}
+	}
+	return used
+}
+
+// releaseAddresses はexternalipが使用していたアドレスをプールから解放する
+func (h *ExternalIPHandler) releaseAddresses(eip *core.ExternalIP) {
+	if eip.Spec.PoolID == "" {
+		return
+	}
+
+	key := getPoolKey(eip.Spec.PoolID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var pool core.ExternalIPPool
+	if err := h.store.Get(key, &pool); err != nil {
+		return
+	}
+
+	// 別のexternalipが使っている場合は解放しない
+	if u, ok := pool.Status.UsedIPv4Addresses[eip.Spec.IPv4Address]; ok && u.UsedExternalIPID == eip.ID {
+		delete(pool.Status.UsedIPv4Addresses, eip.Spec.IPv4Address)
+	}
+	if u, ok := pool.Status.UsedIPv6Addresses[eip.Spec.IPv6Address]; ok && u.UsedExternalIPID == eip.ID {
+		delete(pool.Status.UsedIPv6Addresses, eip.Spec.IPv6Address)
+	}
+	h.store.Put(key, pool)
 }
 
 func getExternalIPID(ctx *gin.Context) string {
@@ -145,3 +410,7 @@ func getExternalIPID(ctx *gin.Context) string {
 func getKey(id string) string {
 	return filepath.Join("externalip", id)
 }
+
+func getPoolKey(id string) string {
+	return filepath.Join("externalippool", id)
+}
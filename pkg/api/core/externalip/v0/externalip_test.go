@@ -0,0 +1,63 @@
+package v0
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store/memory"
+)
+
+func TestRebuildUsedAddresses(t *testing.T) {
+	s := memory.NewMemoryStore()
+	s.Put(getPoolKey("pool1"), core.ExternalIPPool{
+		Meta: meta.Meta{ID: "pool1", APIType: meta.APITypeExternalIPPoolV0},
+		Spec: core.ExternalIPPoolSpec{IPv4CIDR: "192.0.2.0/29"},
+	})
+	// 使用中のアドレスを記録する前に作られたexternalip
+	s.Put(getKey("eip1"), core.ExternalIP{
+		Meta: meta.Meta{ID: "eip1", APIType: meta.APITypeExternalIPV0},
+		Spec: core.ExternalIPSpec{PoolID: "pool1", IPv4Address: "192.0.2.1", IPv4Prefix: 29},
+	})
+
+	h := NewExternalIPHandler(s)
+
+	var pool core.ExternalIPPool
+	if err := s.Get(getPoolKey("pool1"), &pool); err != nil {
+		t.Fatal(err)
+	}
+	if u, ok := pool.Status.UsedIPv4Addresses["192.0.2.1"]; !ok || u.UsedExternalIPID != "eip1" {
+		t.Fatalf("unexpected used addresses: %+v", pool.Status.UsedIPv4Addresses)
+	}
+
+	// 割り当て済みのアドレスは自動で割り当てない
+	gin.SetMode(gin.TestMode)
+	body, err := json.Marshal(&core.ExternalIP{
+		Meta: meta.Meta{ID: "eip2"},
+		Spec: core.ExternalIPSpec{PoolID: "pool1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v0/externalips", bytes.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	h.Create(ctx)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status: %d %s", w.Code, w.Body.String())
+	}
+
+	var eip core.ExternalIP
+	if err := s.Get(getKey("eip2"), &eip); err != nil {
+		t.Fatal(err)
+	}
+	if eip.Spec.IPv4Address == "" || eip.Spec.IPv4Address == "192.0.2.1" {
+		t.Fatalf("unexpected address: %s", eip.Spec.IPv4Address)
+	}
+}
@@ -0,0 +1,156 @@
+package externalippool
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+
+	"github.com/ophum/humstack/pkg/api/core"
+)
+
+// addressRange はcidrのうちexternalipとして割り当てられるアドレスの範囲
+type addressRange struct {
+	first  *big.Int
+	last   *big.Int
+	prefix int
+	isIPv4 bool
+}
+
+func parseRange(cidr string) (*addressRange, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	isIPv4 := ipnet.IP.To4() != nil
+	network := ipnet.IP
+	if isIPv4 {
+		network = network.To4()
+	}
+
+	first := new(big.Int).SetBytes(network)
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	last := new(big.Int).Add(first, size)
+	last.Sub(last, big.NewInt(1))
+
+	// ネットワークアドレスは割り当てない
+	// IPv4の場合はブロードキャストアドレスも割り当てない(/31, /32は除く)
+	if isIPv4 {
+		if bits-ones > 1 {
+			first.Add(first, big.NewInt(1))
+			last.Sub(last, big.NewInt(1))
+		}
+	} else if bits-ones > 0 {
+		first.Add(first, big.NewInt(1))
+	}
+
+	return &addressRange{
+		first:  first,
+		last:   last,
+		prefix: ones,
+		isIPv4: isIPv4,
+	}, nil
+}
+
+func (r *addressRange) toIP(n *big.Int) net.IP {
+	size := net.IPv6len
+	if r.isIPv4 {
+		size = net.IPv4len
+	}
+	buf := make([]byte, size)
+	b := n.Bytes()
+	copy(buf[size-len(b):], b)
+	return net.IP(buf)
+}
+
+func (r *addressRange) contains(ip net.IP) bool {
+	if r.isIPv4 {
+		ip = ip.To4()
+	} else if ip.To4() != nil {
+		return false
+	}
+	if ip == nil {
+		return false
+	}
+
+	n := new(big.Int).SetBytes(ip)
+	return n.Cmp(r.first) >= 0 && n.Cmp(r.last) <= 0
+}
+
+// Prefix はcidrのプレフィックス長を返す
+func Prefix(cidr string) (int32, error) {
+	r, err := parseRange(cidr)
+	if err != nil {
+		return 0, err
+	}
+	return int32(r.prefix), nil
+}
+
+// Allocate はcidrの中で使われていないアドレスを先頭から探して返す
+// reservedに含まれるアドレス(デフォルトゲートウェイなど)は割り当てない
+func Allocate(cidr string, used map[string]core.ExternalIPPoolUsed, reserved ...string) (string, error) {
+	r, err := parseRange(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	skip := map[string]bool{}
+	for _, a := range reserved {
+		if ip := net.ParseIP(a); ip != nil {
+			skip[ip.String()] = true
+		}
+	}
+
+	one := big.NewInt(1)
+	for n := new(big.Int).Set(r.first); n.Cmp(r.last) <= 0; n.Add(n, one) {
+		address := r.toIP(n).String()
+		if _, ok := used[address]; ok || skip[address] {
+			continue
+		}
+		return address, nil
+	}
+
+	return "", fmt.Errorf("no free address in `%s`", cidr)
+}
+
+// Validate はaddressがcidrの中で割り当て可能なアドレスであるかを確認する
+// 正規化したアドレスを返す
+func Validate(cidr, address string) (string, error) {
+	r, err := parseRange(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", fmt.Errorf("`%s` is not an ip address", address)
+	}
+
+	if !r.contains(ip) {
+		return "", fmt.Errorf("`%s` is not assignable in `%s`", address, cidr)
+	}
+	return ip.String(), nil
+}
+
+// Utilization はcidrのアドレスの使用状況を返す
+func Utilization(cidr string, used map[string]core.ExternalIPPoolUsed) core.ExternalIPPoolUtilization {
+	utilization := core.ExternalIPPoolUtilization{
+		Used: uint64(len(used)),
+	}
+
+	r, err := parseRange(cidr)
+	if err != nil {
+		return utilization
+	}
+
+	capacity := new(big.Int).Sub(r.last, r.first)
+	capacity.Add(capacity, big.NewInt(1))
+	if !capacity.IsUint64() {
+		utilization.Capacity = math.MaxUint64
+	} else if capacity.Sign() > 0 {
+		utilization.Capacity = capacity.Uint64()
+	}
+	return utilization
+}
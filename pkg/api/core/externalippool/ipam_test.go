@@ -0,0 +1,84 @@
+package externalippool
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/core"
+)
+
+func TestAllocate(t *testing.T) {
+	used := map[string]core.ExternalIPPoolUsed{
+		"192.168.10.1": {UsedExternalIPID: "eip1"},
+	}
+
+	address, err := Allocate("192.168.10.0/24", used, "192.168.10.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != "192.168.10.3" {
+		t.Fatalf("expected 192.168.10.3, but got %s", address)
+	}
+
+	address, err = Allocate("2001:db8::/64", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != "2001:db8::1" {
+		t.Fatalf("expected 2001:db8::1, but got %s", address)
+	}
+}
+
+func TestAllocateExhausted(t *testing.T) {
+	used := map[string]core.ExternalIPPoolUsed{
+		"192.168.10.1": {UsedExternalIPID: "eip1"},
+		"192.168.10.2": {UsedExternalIPID: "eip2"},
+	}
+
+	if _, err := Allocate("192.168.10.0/30", used); err == nil {
+		t.Fatal("expected error, but got nil")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		address string
+		want    string
+		isError bool
+	}{
+		{"192.168.10.0/24", "192.168.10.100", "192.168.10.100", false},
+		{"192.168.10.0/24", "192.168.10.0", "", true},
+		{"192.168.10.0/24", "192.168.10.255", "", true},
+		{"192.168.10.0/24", "192.168.11.1", "", true},
+		{"192.168.10.0/24", "2001:db8::1", "", true},
+		{"192.168.10.0/24", "foo", "", true},
+		{"2001:db8::/64", "2001:DB8::10", "2001:db8::10", false},
+	}
+
+	for _, tt := range tests {
+		got, err := Validate(tt.cidr, tt.address)
+		if (err != nil) != tt.isError {
+			t.Fatalf("%s in %s: unexpected error %v", tt.address, tt.cidr, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%s in %s: expected %s, but got %s", tt.address, tt.cidr, tt.want, got)
+		}
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	used := map[string]core.ExternalIPPoolUsed{
+		"192.168.10.1": {UsedExternalIPID: "eip1"},
+	}
+
+	u := Utilization("192.168.10.0/24", used)
+	if u.Used != 1 || u.Capacity != 254 {
+		t.Fatalf("unexpected utilization %+v", u)
+	}
+
+	u = Utilization("2001:db8::/48", nil)
+	if u.Capacity != math.MaxUint64 {
+		t.Fatalf("expected capacity to be capped, but got %d", u.Capacity)
+	}
+}
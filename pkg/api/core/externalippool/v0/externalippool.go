@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/externalippool"
 	"github.com/ophum/humstack/pkg/api/meta"
@@ -39,6 +40,9 @@ func (h *ExternalIPPoolHandler) FindAll(ctx *gin.Context) {
 	}
 
 	h.store.List(getKey("")+"/", f)
+	for _, eippool := range eippoolList {
+		setUtilization(eippool)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"externalippools": eippoolList,
@@ -54,6 +58,7 @@ func (h *ExternalIPPoolHandler) Find(ctx *gin.Context) {
 		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ExternalIPPool `%s` is not found.", eippoolID), nil)
 		return
 	}
+	setUtilization(&eippool)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"externalippool": eippool,
@@ -76,7 +81,15 @@ func (h *ExternalIPPoolHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := validateCIDRs(&request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	key := getKey(request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var eippool core.ExternalIPPool
 	err = h.store.Get(key, &eippool)
 	if err == nil {
@@ -84,11 +97,17 @@ func (h *ExternalIPPoolHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// 使用中のアドレスはexternalipの作成・削除時にサーバー側で管理する
+	request.Status.UsedIPv4Addresses = map[string]core.ExternalIPPoolUsed{}
+	request.Status.UsedIPv6Addresses = map[string]core.ExternalIPPoolUsed{}
 
 	request.APIType = meta.APITypeExternalIPPoolV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
+	setUtilization(&request)
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"externalippool": request,
@@ -110,7 +129,15 @@ func (h *ExternalIPPoolHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := validateCIDRs(&request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	key := getKey(request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var eippool core.ExternalIPPool
 	err = h.store.Get(key, &eippool)
 	if err != nil && err.Error() == "Not Found" {
@@ -118,10 +145,27 @@ func (h *ExternalIPPoolHandler) Update(ctx *gin.Context) {
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// 使用中のアドレスが範囲外になるようなcidrの変更はできない
+	if err := validateUsedAddresses(request.Spec.IPv4CIDR, eippool.Status.UsedIPv4Addresses); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := validateUsedAddresses(request.Spec.IPv6CIDR, eippool.Status.UsedIPv6Addresses); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
 
-	h.store.Put(key, request)
+	// uid, generation, deletionTimestamp, 使用中のアドレスはサーバー側で管理する
+	request.UID = eippool.UID
+	request.Generation = meta.NextGeneration(eippool.Generation, eippool.Spec, request.Spec)
+	request.DeletionTimestamp = eippool.DeletionTimestamp
+	request.KeepFinalizers(eippool.Finalizers)
+	request.Status.UsedIPv4Addresses = eippool.Status.UsedIPv4Addresses
+	request.Status.UsedIPv6Addresses = eippool.Status.UsedIPv6Addresses
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
+	setUtilization(&request)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"externalippool": request,
@@ -135,7 +179,73 @@ func (h *ExternalIPPoolHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var eippool core.ExternalIPPool
+	if err := h.store.Get(key, &eippool); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ExternalIPPool `%s` is not found.", eippoolID), nil)
+		return
+	}
+
+	// 割り当て済みのアドレスが残っている場合は削除できない
+	if len(eippool.Status.UsedIPv4Addresses) != 0 || len(eippool.Status.UsedIPv6Addresses) != 0 {
+		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: externalippool `%s` has used addresses.", eippoolID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		eippool.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(eippool.Finalizers) != 0 {
+		eippool.MarkDeletion()
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, eippool)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"externalippool": eippool,
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"externalippool": nil,
+	})
+}
+
+func validateCIDRs(eippool *core.ExternalIPPool) error {
+	for _, cidr := range []string{eippool.Spec.IPv4CIDR, eippool.Spec.IPv6CIDR} {
+		if cidr == "" {
+			continue
+		}
+		if _, err := externalippool.Prefix(cidr); err != nil {
+			return fmt.Errorf("Error: invalid cidr `%s`.", cidr)
+		}
+	}
+	return nil
+}
+
+func validateUsedAddresses(cidr string, used map[string]core.ExternalIPPoolUsed) error {
+	for address, u := range used {
+		if cidr == "" {
+			return fmt.Errorf("Error: can't remove cidr, `%s` is used by externalip `%s`.", address, u.UsedExternalIPID)
+		}
+		if _, err := externalippool.Validate(cidr, address); err != nil {
+			return fmt.Errorf("Error: `%s` used by externalip `%s` is out of `%s`.", address, u.UsedExternalIPID, cidr)
+		}
+	}
+	return nil
+}
+
+// setUtilization はレスポンスに含めるアドレスの使用状況を計算する
+func setUtilization(eippool *core.ExternalIPPool) {
+	eippool.Status.IPv4Utilization = externalippool.Utilization(eippool.Spec.IPv4CIDR, eippool.Status.UsedIPv4Addresses)
+	eippool.Status.IPv6Utilization = externalippool.Utilization(eippool.Spec.IPv6CIDR, eippool.Status.UsedIPv6Addresses)
 }
 
 func getExternalIPPoolID(ctx *gin.Context) string {
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/group"
 	"github.com/ophum/humstack/pkg/api/meta"
@@ -75,7 +76,7 @@ func (h *GroupHandler) Create(ctx *gin.Context) {
 	key := getKey(request.ID)
 	var group core.Group
 	err = h.store.Get(key, &group)
-	if err != nil && err.Error() != "Not Found" {
+	if err == nil {
 		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: group `%s` is already exists.", request.Name), nil)
 		return
 	}
@@ -84,7 +85,11 @@ func (h *GroupHandler) Create(ctx *gin.Context) {
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeGroupV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"group": request,
@@ -117,7 +122,14 @@ func (h *GroupHandler) Update(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.UID = group.UID
+	request.Generation = meta.NextGeneration(group.Generation, group.Spec, request.Spec)
+	request.DeletionTimestamp = group.DeletionTimestamp
+	request.KeepFinalizers(group.Finalizers)
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"group": request,
@@ -131,7 +143,40 @@ func (h *GroupHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var group core.Group
+	if err := h.store.Get(key, &group); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Group `%s` is not found.", groupID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		group.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+	// 所属するリソースはgroupが残っている間しかgarbage collectorが見つけられないので、
+	// Backgroundの場合もgarbage collectorが削除し終えるまでgroupを残す
+	group.AddFinalizer(meta.FinalizerGarbageCollector)
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(group.Finalizers) != 0 {
+		group.MarkDeletion()
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, group)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"group": group,
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"group": nil,
+	})
 }
 
 func getKey(id string) string {
@@ -0,0 +1,40 @@
+package lease
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type LeaseHandlerInterface interface {
+	FindAll(ctx *gin.Context)
+	Find(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+const (
+	basePath = "leases"
+)
+
+type LeaseHandler struct {
+	router *gin.RouterGroup
+	lhi    LeaseHandlerInterface
+}
+
+func NewLeaseHandler(router *gin.RouterGroup, lhi LeaseHandlerInterface) *LeaseHandler {
+	return &LeaseHandler{
+		router: router,
+		lhi:    lhi,
+	}
+}
+
+func (h *LeaseHandler) RegisterHandlers() {
+	le := h.router.Group(basePath)
+	{
+		le.GET("", h.lhi.FindAll)
+		le.GET("/:lease_id", h.lhi.Find)
+		le.POST("", h.lhi.Create)
+		le.PUT("/:lease_id", h.lhi.Update)
+		le.DELETE("/:lease_id", h.lhi.Delete)
+	}
+}
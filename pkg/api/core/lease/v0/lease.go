@@ -0,0 +1,236 @@
+package v0
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/core/lease"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+type LeaseHandler struct {
+	lease.LeaseHandlerInterface
+
+	store store.Store
+}
+
+func NewLeaseHandler(store store.Store) *LeaseHandler {
+	return &LeaseHandler{
+		store: store,
+	}
+}
+
+func (h *LeaseHandler) FindAll(ctx *gin.Context) {
+	leaseList := []*core.Lease{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			lease := &core.Lease{}
+			leaseList = append(leaseList, lease)
+			m = append(m, lease)
+		}
+		return m
+	}
+
+	h.store.List(getKey("")+"/", f)
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"leases": leaseList,
+	})
+}
+
+func (h *LeaseHandler) Find(ctx *gin.Context) {
+	leaseID := getLeaseID(ctx)
+
+	var lease core.Lease
+	err := h.store.Get(getKey(leaseID), &lease)
+	if err != nil && err.Error() == "Not Found" {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Lease `%s` is not found.", leaseID), nil)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"lease": lease,
+	})
+}
+
+func (h *LeaseHandler) Create(ctx *gin.Context) {
+	var request core.Lease
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	// ノードの証明書では自身をholderとするleaseのみ作成できる
+	if request.Spec.HolderIdentity != "" && !auth.AuthorizeNode(ctx, request.Spec.HolderIdentity) {
+		return
+	}
+
+	if err := validate(&request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	key := getKey(request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var l core.Lease
+	err = h.store.Get(key, &l)
+	if err == nil {
+		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Lease `%s` is already exists.", request.ID), nil)
+		return
+	}
+
+	// 時刻はagentごとのずれの影響を受けないようにapiserverの時刻を使う
+	request.Spec.AcquireTime = nil
+	request.Spec.RenewTime = nil
+	request.Spec.LeaseTransitions = 0
+	if request.Spec.HolderIdentity != "" {
+		now := time.Now()
+		request.Spec.AcquireTime = &now
+		request.Spec.RenewTime = &now
+	}
+
+	if request.Name == "" {
+		request.Name = request.ID
+	}
+	request.APIType = meta.APITypeLeaseV0
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
+		"lease": request,
+	})
+}
+
+// Update はleaseの取得・更新・解放を行う
+// 他のagentが保持している期限内のleaseは取得できず409を返す
+// storeのロック内で判定するため、同時に取得しようとしても1つのagentしか成功しない
+func (h *LeaseHandler) Update(ctx *gin.Context) {
+	leaseID := getLeaseID(ctx)
+
+	var request core.Lease
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if leaseID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change Lease ID."), nil)
+		return
+	}
+
+	if err := validate(&request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	key := getKey(leaseID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var l core.Lease
+	err = h.store.Get(key, &l)
+	if err != nil && err.Error() == "Not Found" {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: Lease `%s` is not found.", leaseID), nil)
+		return
+	}
+
+	now := time.Now()
+	holder := request.Spec.HolderIdentity
+	if holder == "" {
+		// 解放できるのは保持しているagentのみ
+		if !auth.AuthorizeNode(ctx, l.Spec.HolderIdentity) {
+			return
+		}
+	} else {
+		if !auth.AuthorizeNode(ctx, holder) {
+			return
+		}
+		if holder != l.Spec.HolderIdentity && !l.IsExpired(now) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Lease `%s` is held by `%s`.", leaseID, l.Spec.HolderIdentity), nil)
+			return
+		}
+	}
+
+	request.Spec.AcquireTime = l.Spec.AcquireTime
+	request.Spec.RenewTime = l.Spec.RenewTime
+	request.Spec.LeaseTransitions = l.Spec.LeaseTransitions
+	switch {
+	case holder == "":
+		request.Spec.AcquireTime = nil
+		request.Spec.RenewTime = nil
+	case holder != l.Spec.HolderIdentity:
+		// 一度でも取得されたことがあればholderの交代として数える
+		if l.Spec.AcquireTime != nil || l.Spec.LeaseTransitions != 0 {
+			request.Spec.LeaseTransitions++
+		}
+		request.Spec.AcquireTime = &now
+		request.Spec.RenewTime = &now
+	default:
+		request.Spec.RenewTime = &now
+	}
+
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	// renewTimeは頻繁に変わるためgenerationは増やさない
+	request.APIType = meta.APITypeLeaseV0
+	request.UID = l.UID
+	request.Generation = l.Generation
+	request.DeletionTimestamp = l.DeletionTimestamp
+	request.KeepFinalizers(l.Finalizers)
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"lease": request,
+	})
+}
+
+func (h *LeaseHandler) Delete(ctx *gin.Context) {
+	leaseID := getLeaseID(ctx)
+
+	key := getKey(leaseID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"lease": nil,
+	})
+}
+
+func validate(lease *core.Lease) error {
+	if lease.ID == "" {
+		return fmt.Errorf("Error: id is empty.")
+	}
+
+	if lease.Spec.LeaseDurationSeconds <= 0 {
+		return fmt.Errorf("Error: leaseDurationSeconds must be greater than 0.")
+	}
+	return nil
+}
+
+func getLeaseID(ctx *gin.Context) string {
+	return ctx.Param("lease_id")
+}
+
+func getKey(name string) string {
+	return filepath.Join("lease", name)
+}
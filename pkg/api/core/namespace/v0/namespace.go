@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/namespace"
 	"github.com/ophum/humstack/pkg/api/meta"
@@ -89,7 +90,11 @@ func (h *NamespaceHandler) Create(ctx *gin.Context) {
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeNamespaceV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"namespace": request,
@@ -128,7 +133,14 @@ func (h *NamespaceHandler) Update(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.UID = ns.UID
+	request.Generation = meta.NextGeneration(ns.Generation, ns.Spec, request.Spec)
+	request.DeletionTimestamp = ns.DeletionTimestamp
+	request.KeepFinalizers(ns.Finalizers)
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"namespace": request,
@@ -143,7 +155,40 @@ func (h *NamespaceHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var ns core.Namespace
+	if err := h.store.Get(key, &ns); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Namespace `%s` is not found.", nsID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		ns.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+	// 所属するリソースはnamespaceが残っている間しかgarbage collectorが見つけられないので、
+	// Backgroundの場合もgarbage collectorが削除し終えるまでnamespaceを残す
+	ns.AddFinalizer(meta.FinalizerGarbageCollector)
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(ns.Finalizers) != 0 {
+		ns.MarkDeletion()
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, ns)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"namespace": ns,
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"namespace": nil,
+	})
 }
 
 func getGroupID(ctx *gin.Context) string {
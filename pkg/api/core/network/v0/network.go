@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/network"
 	"github.com/ophum/humstack/pkg/api/meta"
@@ -97,7 +98,11 @@ func (h *NetworkHandler) Create(ctx *gin.Context) {
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeNetworkV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"network": request,
@@ -130,7 +135,14 @@ func (h *NetworkHandler) Update(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.UID = net.UID
+	request.Generation = meta.NextGeneration(net.Generation, net.Spec, request.Spec)
+	request.DeletionTimestamp = net.DeletionTimestamp
+	request.KeepFinalizers(net.Finalizers)
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"network": request,
@@ -144,7 +156,34 @@ func (h *NetworkHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var net core.Network
+	if err := h.store.Get(key, &net); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Network `%s` is not found.", netID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		net.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(net.Finalizers) != 0 {
+		net.MarkDeletion()
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, net)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"network": net,
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
+
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"network": nil,
 	})
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"time"
+
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 )
@@ -48,9 +50,19 @@ type ExternalIPPoolUsed struct {
 	UsedExternalIPID string `json:"usedExternalIPID" yaml:"usedExternalIPID"`
 }
 
+// ExternalIPPoolUtilization はプール内のアドレスの使用状況
+// IPv6などで範囲が大きい場合、capacityはuint64の最大値で打ち切る
+type ExternalIPPoolUtilization struct {
+	Used     uint64 `json:"used" yaml:"used"`
+	Capacity uint64 `json:"capacity" yaml:"capacity"`
+}
+
 type ExternalIPPoolStatus struct {
 	UsedIPv4Addresses map[string]ExternalIPPoolUsed `json:"usedIPv4Address" yaml:"usedIPv4Address"`
 	UsedIPv6Addresses map[string]ExternalIPPoolUsed `json:"usedIPv6Address" yaml:"usedIPv6Address"`
+	IPv4Utilization   ExternalIPPoolUtilization     `json:"ipv4Utilization" yaml:"ipv4Utilization"`
+	IPv6Utilization   ExternalIPPoolUtilization     `json:"ipv6Utilization" yaml:"ipv6Utilization"`
+	Conditions        []meta.Condition              `json:"conditions" yaml:"conditions"`
 }
 
 type ExternalIPPool struct {
@@ -85,8 +97,14 @@ const (
 	NetworkStateCreating NetworkState = "Creating"
 )
 
+const (
+	// 各ノードのNodeNetworkを作成できたか
+	NetworkConditionReady meta.ConditionType = "Ready"
+)
+
 type NetworkStatus struct {
-	State NetworkState `json:"state" yaml:"state"`
+	State      NetworkState     `json:"state" yaml:"state"`
+	Conditions []meta.Condition `json:"conditions" yaml:"conditions"`
 }
 type Network struct {
 	meta.Meta `json:"meta" yaml:"meta"`
@@ -95,3 +113,60 @@ type Network struct {
 
 	Status NetworkStatus `json:"status" yaml:"status"`
 }
+
+type EventType string
+
+const (
+	EventTypeNormal  EventType = "Normal"
+	EventTypeWarning EventType = "Warning"
+)
+
+type EventSpec struct {
+	InvolvedObject meta.ObjectReference `json:"involvedObject" yaml:"involvedObject"`
+
+	Reason  string    `json:"reason" yaml:"reason"`
+	Message string    `json:"message" yaml:"message"`
+	Type    EventType `json:"type" yaml:"type"`
+
+	// 同じイベントが発生した回数
+	Count          int32     `json:"count" yaml:"count"`
+	FirstTimestamp time.Time `json:"firstTimestamp" yaml:"firstTimestamp"`
+	LastTimestamp  time.Time `json:"lastTimestamp" yaml:"lastTimestamp"`
+
+	ReportingNode string `json:"reportingNode" yaml:"reportingNode"`
+}
+
+type Event struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec EventSpec `json:"spec" yaml:"spec"`
+}
+
+type LeaseSpec struct {
+	// leaseを保持しているagentのノード名
+	// 空の場合は誰も保持していない
+	HolderIdentity string `json:"holderIdentity" yaml:"holderIdentity"`
+	// renewTimeからこの秒数更新されなければ他のagentが取得できる
+	LeaseDurationSeconds int32 `json:"leaseDurationSeconds" yaml:"leaseDurationSeconds"`
+
+	// acquireTime, renewTime, leaseTransitionsはapiserverが設定する
+	AcquireTime      *time.Time `json:"acquireTime" yaml:"acquireTime"`
+	RenewTime        *time.Time `json:"renewTime" yaml:"renewTime"`
+	LeaseTransitions int32      `json:"leaseTransitions" yaml:"leaseTransitions"`
+}
+
+type Lease struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec LeaseSpec `json:"spec" yaml:"spec"`
+}
+
+// IsExpired はnowの時点でleaseの期限が切れているかを返す
+// holderがいない場合も期限切れとして扱う
+func (l *Lease) IsExpired(now time.Time) bool {
+	if l.Spec.HolderIdentity == "" || l.Spec.RenewTime == nil {
+		return true
+	}
+	duration := time.Duration(l.Spec.LeaseDurationSeconds) * time.Second
+	return !now.Before(l.Spec.RenewTime.Add(duration))
+}
@@ -0,0 +1,109 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Checker は異常な場合にerrorを返す
+type Checker func() error
+
+type check struct {
+	name    string
+	checker Checker
+}
+
+type HealthHandler struct {
+	checks []check
+}
+
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{}
+}
+
+// AddReadinessCheck は/readyzで確認する項目を追加する
+func (h *HealthHandler) AddReadinessCheck(name string, checker Checker) {
+	h.checks = append(h.checks, check{
+		name:    name,
+		checker: checker,
+	})
+}
+
+func (h *HealthHandler) RegisterHandlers(router gin.IRouter) {
+	router.GET("/healthz", h.Healthz)
+	router.GET("/readyz", h.Readyz)
+}
+
+// Healthz はプロセスが応答できるかどうかのみを返す
+func (h *HealthHandler) Healthz(ctx *gin.Context) {
+	ctx.String(http.StatusOK, "ok")
+}
+
+func (h *HealthHandler) Readyz(ctx *gin.Context) {
+	code := http.StatusOK
+	results := map[string]string{}
+	for _, c := range h.checks {
+		if err := c.checker(); err != nil {
+			code = http.StatusServiceUnavailable
+			results[c.name] = err.Error()
+			continue
+		}
+		results[c.name] = "ok"
+	}
+
+	ctx.JSON(code, gin.H{
+		"checks": results,
+	})
+}
+
+// NotifierMonitor はstoreからの変更通知が配信され続けているかを監視する
+type NotifierMonitor struct {
+	notifier     chan string
+	stallTimeout time.Duration
+
+	mutex sync.Mutex
+	// 配信中の通知を受け取った時刻、配信中でなければゼロ値
+	busySince time.Time
+}
+
+func NewNotifierMonitor(notifier chan string, stallTimeout time.Duration) *NotifierMonitor {
+	return &NotifierMonitor{
+		notifier:     notifier,
+		stallTimeout: stallTimeout,
+	}
+}
+
+// Received は通知を受け取って配信を始める時に呼ぶ
+func (m *NotifierMonitor) Received() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.busySince = time.Now()
+}
+
+// Delivered は配信が終わった時に呼ぶ
+func (m *NotifierMonitor) Delivered() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.busySince = time.Time{}
+}
+
+func (m *NotifierMonitor) Check() error {
+	m.mutex.Lock()
+	busySince := m.busySince
+	m.mutex.Unlock()
+
+	if !busySince.IsZero() && time.Since(busySince) > m.stallTimeout {
+		return fmt.Errorf("notifier stalled for %s", time.Since(busySince).Round(time.Second))
+	}
+
+	// キューが溢れているとstoreへの書き込みがブロックされる
+	if cap(m.notifier) > 0 && len(m.notifier) == cap(m.notifier) {
+		return fmt.Errorf("notifier queue is full (%d)", len(m.notifier))
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadyz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHealthHandler()
+
+	var storeErr error
+	h.AddReadinessCheck("store", func() error {
+		return storeErr
+	})
+
+	r := gin.New()
+	h.RegisterHandlers(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	storeErr = errors.New("closed")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	// 依存先の状態によらずhealthzは成功する
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+func TestNotifierMonitor(t *testing.T) {
+	notifier := make(chan string, 1)
+	m := NewNotifierMonitor(notifier, time.Millisecond)
+
+	if err := m.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Received()
+	time.Sleep(time.Millisecond * 5)
+	if err := m.Check(); err == nil {
+		t.Fatal("expected stalled error")
+	}
+
+	m.Delivered()
+	if err := m.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier <- "data"
+	if err := m.Check(); err == nil {
+		t.Fatal("expected queue full error")
+	}
+}
@@ -0,0 +1,89 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+type ConditionType string
+
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// Condition はStateだけでは分からない処理の各段階の状態
+type Condition struct {
+	Type    ConditionType   `json:"type" yaml:"type"`
+	Status  ConditionStatus `json:"status" yaml:"status"`
+	Reason  string          `json:"reason" yaml:"reason"`
+	Message string          `json:"message" yaml:"message"`
+	// statusが変わった時刻
+	LastTransitionTime time.Time `json:"lastTransitionTime" yaml:"lastTransitionTime"`
+	// 処理したリソースのgeneration
+	ObservedGeneration int64 `json:"observedGeneration" yaml:"observedGeneration"`
+}
+
+func FindCondition(conditions []Condition, conditionType ConditionType) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+func IsConditionTrue(conditions []Condition, conditionType ConditionType) bool {
+	c := FindCondition(conditions, conditionType)
+	return c != nil && c.Status == ConditionTrue
+}
+
+// SetCondition は同じtypeのconditionを置き換え、変更があった場合にtrueを返す
+// lastTransitionTimeはstatusが変わった場合のみ更新する
+func SetCondition(conditions *[]Condition, condition Condition) bool {
+	old := FindCondition(*conditions, condition.Type)
+	if old == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = time.Now()
+		}
+		*conditions = append(*conditions, condition)
+		return true
+	}
+
+	if old.Status == condition.Status {
+		condition.LastTransitionTime = old.LastTransitionTime
+	} else if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = time.Now()
+	}
+
+	if *old == condition {
+		return false
+	}
+	*old = condition
+	return true
+}
+
+// RemoveCondition はtypeのconditionを削除し、削除した場合にtrueを返す
+func RemoveCondition(conditions *[]Condition, conditionType ConditionType) bool {
+	for i := range *conditions {
+		if (*conditions)[i].Type == conditionType {
+			*conditions = append((*conditions)[:i], (*conditions)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// NextGeneration はspecが変更された場合のみgenerationを進める
+func NextGeneration(generation int64, oldSpec, newSpec interface{}) int64 {
+	oldJSON, oldErr := json.Marshal(oldSpec)
+	newJSON, newErr := json.Marshal(newSpec)
+	if oldErr == nil && newErr == nil && bytes.Equal(oldJSON, newJSON) {
+		return generation
+	}
+	return generation + 1
+}
@@ -0,0 +1,67 @@
+package meta
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetCondition(t *testing.T) {
+	conditions := []Condition{}
+
+	if !SetCondition(&conditions, Condition{Type: "Booted", Status: ConditionFalse, Reason: "PowerOff"}) {
+		t.Fatal("new condition must be changed")
+	}
+	transitioned := conditions[0].LastTransitionTime
+	if transitioned.IsZero() {
+		t.Fatal("lastTransitionTime is not set")
+	}
+
+	if SetCondition(&conditions, Condition{Type: "Booted", Status: ConditionFalse, Reason: "PowerOff"}) {
+		t.Fatal("same condition must not be changed")
+	}
+
+	// statusが同じ場合はlastTransitionTimeを変更しない
+	if !SetCondition(&conditions, Condition{Type: "Booted", Status: ConditionFalse, Reason: "QemuFailed"}) {
+		t.Fatal("reason is changed")
+	}
+	if !conditions[0].LastTransitionTime.Equal(transitioned) {
+		t.Fatal("lastTransitionTime must not be changed")
+	}
+
+	later := transitioned.Add(time.Minute)
+	SetCondition(&conditions, Condition{Type: "Booted", Status: ConditionTrue, LastTransitionTime: later})
+	if len(conditions) != 1 || !IsConditionTrue(conditions, "Booted") || !conditions[0].LastTransitionTime.Equal(later) {
+		t.Fatalf("unexpected conditions: %+v", conditions)
+	}
+}
+
+func TestRemoveCondition(t *testing.T) {
+	conditions := []Condition{
+		{Type: "Scheduled", Status: ConditionTrue},
+		{Type: "Booted", Status: ConditionTrue},
+	}
+
+	if !RemoveCondition(&conditions, "Scheduled") {
+		t.Fatal("condition must be removed")
+	}
+	if RemoveCondition(&conditions, "Scheduled") {
+		t.Fatal("removed condition must not be found")
+	}
+	if len(conditions) != 1 || conditions[0].Type != "Booted" {
+		t.Fatalf("unexpected conditions: %+v", conditions)
+	}
+}
+
+func TestNextGeneration(t *testing.T) {
+	type spec struct {
+		Vcpus string
+		Disks map[string]string
+	}
+
+	if g := NextGeneration(1, spec{Vcpus: "1"}, spec{Vcpus: "1"}); g != 1 {
+		t.Fatalf("unexpected generation: %d", g)
+	}
+	if g := NextGeneration(1, spec{Vcpus: "1"}, spec{Vcpus: "2"}); g != 2 {
+		t.Fatalf("unexpected generation: %d", g)
+	}
+}
@@ -0,0 +1,14 @@
+package meta
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsDryRun はリクエストに `?dryRun=true` が指定されているかを返す
+// dryRunの場合、ハンドラーは検証とデフォルト値の設定を行った結果を返すが、storeには書き込まない
+func IsDryRun(ctx *gin.Context) bool {
+	dryRun, err := strconv.ParseBool(ctx.Query("dryRun"))
+	return err == nil && dryRun
+}
@@ -0,0 +1,107 @@
+package meta
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// StatusReason はapiserverが返すエラーの種類
+type StatusReason string
+
+const (
+	StatusReasonNotFound  StatusReason = "NotFound"
+	StatusReasonConflict  StatusReason = "Conflict"
+	StatusReasonInvalid   StatusReason = "Invalid"
+	StatusReasonForbidden StatusReason = "Forbidden"
+	StatusReasonInternal  StatusReason = "Internal"
+	StatusReasonUnknown   StatusReason = "Unknown"
+)
+
+// APIError はapiserverのエラーレスポンス
+// クライアントはレスポンスの `reason` からこのエラーを復元する
+type APIError struct {
+	Code    int
+	Reason  StatusReason
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// NewAPIError はステータスコードに対応するreasonのエラーを作成する
+func NewAPIError(code int, message string) *APIError {
+	return &APIError{
+		Code:    code,
+		Reason:  ReasonForCode(code),
+		Message: message,
+	}
+}
+
+func NewNotFound(format string, args ...interface{}) *APIError {
+	return NewAPIError(http.StatusNotFound, fmt.Sprintf(format, args...))
+}
+
+func NewConflict(format string, args ...interface{}) *APIError {
+	return NewAPIError(http.StatusConflict, fmt.Sprintf(format, args...))
+}
+
+func NewInvalid(format string, args ...interface{}) *APIError {
+	return NewAPIError(http.StatusBadRequest, fmt.Sprintf(format, args...))
+}
+
+func NewForbidden(format string, args ...interface{}) *APIError {
+	return NewAPIError(http.StatusForbidden, fmt.Sprintf(format, args...))
+}
+
+func NewInternal(format string, args ...interface{}) *APIError {
+	return NewAPIError(http.StatusInternalServerError, fmt.Sprintf(format, args...))
+}
+
+// ReasonForCode はステータスコードに対応するreasonを返す
+func ReasonForCode(code int) StatusReason {
+	switch {
+	case code == http.StatusNotFound:
+		return StatusReasonNotFound
+	case code == http.StatusConflict:
+		return StatusReasonConflict
+	case code == http.StatusBadRequest, code == http.StatusUnprocessableEntity:
+		return StatusReasonInvalid
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return StatusReasonForbidden
+	case code >= http.StatusInternalServerError:
+		return StatusReasonInternal
+	}
+	return StatusReasonUnknown
+}
+
+// ReasonForError はerrがAPIErrorであればそのreasonを返す
+// ラップされたエラーも辿る
+func ReasonForError(err error) StatusReason {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Reason
+	}
+	return StatusReasonUnknown
+}
+
+func IsNotFound(err error) bool {
+	return ReasonForError(err) == StatusReasonNotFound
+}
+
+func IsConflict(err error) bool {
+	return ReasonForError(err) == StatusReasonConflict
+}
+
+func IsInvalid(err error) bool {
+	return ReasonForError(err) == StatusReasonInvalid
+}
+
+func IsForbidden(err error) bool {
+	return ReasonForError(err) == StatusReasonForbidden
+}
+
+func IsInternal(err error) bool {
+	return ReasonForError(err) == StatusReasonInternal
+}
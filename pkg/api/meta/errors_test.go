@@ -0,0 +1,48 @@
+package meta
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestReasonForCode(t *testing.T) {
+	tests := []struct {
+		code int
+		want StatusReason
+	}{
+		{http.StatusNotFound, StatusReasonNotFound},
+		{http.StatusConflict, StatusReasonConflict},
+		{http.StatusBadRequest, StatusReasonInvalid},
+		{http.StatusForbidden, StatusReasonForbidden},
+		{http.StatusServiceUnavailable, StatusReasonInternal},
+		{http.StatusTooManyRequests, StatusReasonUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := ReasonForCode(tt.code); got != tt.want {
+			t.Fatalf("%d: expected %s, but got %s", tt.code, tt.want, got)
+		}
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	err := NewNotFound("VirtualMachine `%s` is not found.", "vm1")
+	if !IsNotFound(err) || IsConflict(err) {
+		t.Fatalf("unexpected reason: %s", ReasonForError(err))
+	}
+	if err.Error() != "VirtualMachine `vm1` is not found." {
+		t.Fatalf("unexpected message: %s", err.Error())
+	}
+
+	// ラップされていても判定できる
+	if !IsNotFound(errors.Wrap(err, "get vm")) {
+		t.Fatal("wrapped error must be NotFound")
+	}
+
+	if IsNotFound(fmt.Errorf("Not Found")) || IsNotFound(nil) {
+		t.Fatal("non APIError must not be NotFound")
+	}
+}
@@ -0,0 +1,67 @@
+package meta
+
+import "time"
+
+// IsDeleting はDELETEが要求され、finalizerの処理待ちであるかを返す
+func (m *Meta) IsDeleting() bool {
+	return m.DeletionTimestamp != nil
+}
+
+// MarkDeletion はdeletionTimestampをセットする
+// すでにセットされている場合は変更しない
+func (m *Meta) MarkDeletion() {
+	if m.DeletionTimestamp != nil {
+		return
+	}
+	now := time.Now()
+	m.DeletionTimestamp = &now
+}
+
+// IsRemovable はstoreから削除してよい状態かを返す
+func (m *Meta) IsRemovable() bool {
+	return m.IsDeleting() && len(m.Finalizers) == 0
+}
+
+func (m *Meta) HasFinalizer(name string) bool {
+	for _, f := range m.Finalizers {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFinalizer はfinalizerを追加し、変更があった場合にtrueを返す
+// 削除中のリソースには追加しない
+func (m *Meta) AddFinalizer(name string) bool {
+	if m.IsDeleting() || m.HasFinalizer(name) {
+		return false
+	}
+	m.Finalizers = append(m.Finalizers, name)
+	return true
+}
+
+// RemoveFinalizer はfinalizerを取り除き、変更があった場合にtrueを返す
+func (m *Meta) RemoveFinalizer(name string) bool {
+	finalizers := []string{}
+	for _, f := range m.Finalizers {
+		if f != name {
+			finalizers = append(finalizers, f)
+		}
+	}
+
+	if len(finalizers) == len(m.Finalizers) {
+		return false
+	}
+	m.Finalizers = finalizers
+	return true
+}
+
+// KeepFinalizers は更新のリクエストでfinalizersが省略された(null)場合に保存されているstoredを引き継ぐ
+// finalizersを含まないマニフェストでの更新でagentのfinalizerが外れないようにする
+// 全て外す場合は空の配列を指定する
+func (m *Meta) KeepFinalizers(stored []string) {
+	if m.Finalizers == nil {
+		m.Finalizers = stored
+	}
+}
@@ -0,0 +1,18 @@
+package meta
+
+// LabelSelector はlabelsでリソースを選ぶ
+type LabelSelector struct {
+	// 全てのkeyとvalueが一致するリソースを選ぶ
+	MatchLabels map[string]string `json:"matchLabels" yaml:"matchLabels"`
+}
+
+// Matches はlabelsがselectorに一致するかどうかを返す
+// MatchLabelsが空の場合は全てに一致する
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for k, v := range s.MatchLabels {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
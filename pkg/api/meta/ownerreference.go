@@ -0,0 +1,84 @@
+package meta
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeletionPropagation string
+
+const (
+	// 所有者を先に削除し、依存するリソースはgarbage collectorが後から削除する
+	DeletionPropagationBackground DeletionPropagation = "Background"
+	// 依存するリソースが全て削除されるまで所有者の削除を待つ
+	DeletionPropagationForeground DeletionPropagation = "Foreground"
+)
+
+const (
+	FinalizerForegroundDeletion = "foregroundDeletion"
+	// groupとnamespaceの削除時に付け、所属するリソースが全て消えたらgarbage collectorが外す
+	FinalizerGarbageCollector = "garbageCollector"
+)
+
+// GetDeletionPropagation はDELETEリクエストのpropagationPolicyを返す
+// 指定がない場合はBackground
+func GetDeletionPropagation(ctx *gin.Context) DeletionPropagation {
+	if DeletionPropagation(ctx.Query("propagationPolicy")) == DeletionPropagationForeground {
+		return DeletionPropagationForeground
+	}
+	return DeletionPropagationBackground
+}
+
+func NewOwnerReference(owner Meta) OwnerReference {
+	return NewObjectReference(owner)
+}
+
+func NewObjectReference(m Meta) ObjectReference {
+	return ObjectReference{
+		APIType:   m.APIType,
+		Group:     m.Group,
+		Namespace: m.Namespace,
+		ID:        m.ID,
+		UID:       m.UID,
+	}
+}
+
+// Refers は参照がownerを指しているかを返す
+// uidが記録されていない古い参照はidのみで比較する
+func (r ObjectReference) Refers(owner Meta) bool {
+	if r.APIType != owner.APIType ||
+		r.Group != owner.Group ||
+		r.Namespace != owner.Namespace ||
+		r.ID != owner.ID {
+		return false
+	}
+	return r.UID == "" || r.UID == owner.UID
+}
+
+// 旧形式({"meta": {...}})のownerReferenceも読めるようにする
+func (r *ObjectReference) UnmarshalJSON(data []byte) error {
+	type objectReference ObjectReference
+	ref := struct {
+		objectReference
+		Meta *Meta `json:"meta"`
+	}{}
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return err
+	}
+
+	*r = ObjectReference(ref.objectReference)
+	if ref.Meta != nil && r.ID == "" {
+		*r = NewObjectReference(*ref.Meta)
+	}
+	return nil
+}
+
+func (m *Meta) IsOwnedBy(owner Meta) bool {
+	for _, ref := range m.OwnerReferences {
+		if ref.Refers(owner) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,15 +1,25 @@
 package meta
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
 )
 
 func ResponseJSON(ctx *gin.Context, code int, err error, data interface{}) {
 	if err != nil {
+		// APIErrorでない場合はステータスコードからreasonを決める
+		reason := ReasonForCode(code)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			reason = apiErr.Reason
+		}
+
 		ctx.JSON(code, gin.H{
-			"code":  code,
-			"error": err.Error(),
-			"data":  data,
+			"code":   code,
+			"error":  err.Error(),
+			"reason": reason,
+			"data":   data,
 		})
 		return
 	}
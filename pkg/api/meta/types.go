@@ -1,5 +1,7 @@
 package meta
 
+import "time"
+
 type APIType string
 
 const (
@@ -16,37 +18,64 @@ const (
 	APITypeExternalIPPoolV0 APIType = "corev0/externalippool"
 	APITypeExternalIPV0     APIType = "corev0/externalip"
 	APITypeNetworkV0        APIType = "corev0/network"
-)
+	APITypeEventV0          APIType = "corev0/event"
+	APITypeLeaseV0          APIType = "corev0/lease"
 
-type ResourceType string
+	APITypeBlockStorageV1   APIType = "systemv1/blockstorage"
+	APITypeVirtualMachineV1 APIType = "systemv1/virtualmachine"
+	APITypeVirtualRouterV1  APIType = "systemv1/virtualrouter"
+	APITypeImageEntityV1    APIType = "systemv1/imageentity"
 
-type DeleteState string
-
-const (
-	DeleteStateNone   DeleteState = "None"
-	DeleteStateDelete DeleteState = "Delete"
-	DeleteStateDone   DeleteState = "Done"
+	// v0から形が変わらず、apiTypeだけが異なるリソース
+	APITypeNodeV1           APIType = "systemv1/node"
+	APITypeNodeNetworkV1    APIType = "systemv1/nodenetwork"
+	APITypeImageV1          APIType = "systemv1/image"
+	APITypeImageTagV1       APIType = "systemv1/imagetag"
+	APITypeNamespaceV1      APIType = "corev1/namespace"
+	APITypeGroupV1          APIType = "corev1/group"
+	APITypeExternalIPPoolV1 APIType = "corev1/externalippool"
+	APITypeExternalIPV1     APIType = "corev1/externalip"
+	APITypeNetworkV1        APIType = "corev1/network"
+	APITypeEventV1          APIType = "corev1/event"
+	APITypeLeaseV1          APIType = "corev1/lease"
 )
 
-type OwnerReference struct {
-	Meta Meta `json:"meta" yaml:"meta"`
+type ResourceType string
+
+// ObjectReference はownerReferencesやイベントなどから参照するリソース
+type ObjectReference struct {
+	APIType   APIType `json:"apiType" yaml:"apiType"`
+	Group     string  `json:"group" yaml:"group"`
+	Namespace string  `json:"namespace" yaml:"namespace"`
+	ID        string  `json:"id" yaml:"id"`
+	UID       string  `json:"uid" yaml:"uid"`
 }
 
+// OwnerReference は所有者への参照で、ObjectReferenceと同じ型
+type OwnerReference = ObjectReference
+
 type Meta struct {
-	ID              string            `json:"id" yaml:"id"`
-	Name            string            `json:"name" yaml:"name"`
-	Namespace       string            `json:"namespace" yaml:"namespace"`
-	Group           string            `json:"group" yaml:"group"`
-	Annotations     map[string]string `json:"annotations" yaml:"annotations"`
-	Labels          map[string]string `json:"labels" yaml:"labels"`
-	ResourceHash    string            `json:"resourceHash" yaml:"resourceHash"`
-	DeleteState     DeleteState       `json:"deleteState" yaml:"deleteState"`
-	APIType         APIType           `json:"apiType" yaml:"apiType"`
-	OwnerReferences []OwnerReference  `json:"ownerReferences" yaml:"ownerReferences"`
+	ID                string            `json:"id" yaml:"id"`
+	UID               string            `json:"uid" yaml:"uid"`
+	Name              string            `json:"name" yaml:"name"`
+	Namespace         string            `json:"namespace" yaml:"namespace"`
+	Group             string            `json:"group" yaml:"group"`
+	Annotations       map[string]string `json:"annotations" yaml:"annotations"`
+	Labels            map[string]string `json:"labels" yaml:"labels"`
+	ResourceHash      string            `json:"resourceHash" yaml:"resourceHash"`
+	Generation        int64             `json:"generation" yaml:"generation"`
+	Finalizers        []string          `json:"finalizers" yaml:"finalizers"`
+	DeletionTimestamp *time.Time        `json:"deletionTimestamp" yaml:"deletionTimestamp"`
+	APIType           APIType           `json:"apiType" yaml:"apiType"`
+	OwnerReferences   []OwnerReference  `json:"ownerReferences" yaml:"ownerReferences"`
+
+	// 更新するたびにapiserverが1つ上げる。0以外を指定して更新した場合、保存されている値と異なれば409を返す
+	// 現在はnodeのみ対応している
+	ResourceVersion int64 `json:"resourceVersion,omitempty" yaml:"resourceVersion,omitempty"`
 }
 
 type Object struct {
-	Meta   Meta `json:"meta" yaml:"meta"`
-	Spec   interface{}
-	Status interface{}
+	Meta   Meta        `json:"meta" yaml:"meta"`
+	Spec   interface{} `json:"spec" yaml:"spec"`
+	Status interface{} `json:"status" yaml:"status"`
 }
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "humstack"
+	subsystem = "apiserver"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by method, route and status code.",
+		},
+		[]string{"method", "route", "code"},
+	)
+
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latencies by method, route and status code.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method", "route", "code"},
+	)
+
+	watchSubscribers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "watch_subscribers",
+			Help:      "Number of active watch subscribers.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, requestDuration, watchSubscribers)
+}
+
+// Middleware はリクエスト数とレイテンシを記録する
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		// パスパラメータごとに系列が増えないようにルートのパターンで集計する
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		code := strconv.Itoa(ctx.Writer.Status())
+		method := ctx.Request.Method
+
+		requestsTotal.WithLabelValues(method, route, code).Inc()
+		requestDuration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler は/metricsのハンドラ
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// IncWatchSubscribers はwatchの購読開始時に呼ぶ
+func IncWatchSubscribers() {
+	watchSubscribers.Inc()
+}
+
+// DecWatchSubscribers はwatchの購読終了時に呼ぶ
+func DecWatchSubscribers() {
+	watchSubscribers.Dec()
+}
+
+// RegisterNotifierQueueDepth はstoreからの通知キューに溜まっている件数を公開する
+func RegisterNotifierQueueDepth(notifier chan string) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "notifier_queue_depth",
+			Help:      "Number of store notifications waiting to be broadcast.",
+		},
+		func() float64 {
+			return float64(len(notifier))
+		},
+	))
+}
+
+// ObjectCounter はAPITypeごとのリソース数を返す
+type ObjectCounter interface {
+	CountObjects() (map[meta.APIType]int, error)
+}
+
+type objectCollector struct {
+	counter ObjectCounter
+	desc    *prometheus.Desc
+	errors  prometheus.Counter
+}
+
+// RegisterObjectCounter はscrapeのたびにAPITypeごとのリソース数を集計して公開する
+func RegisterObjectCounter(counter ObjectCounter) {
+	prometheus.MustRegister(&objectCollector{
+		counter: counter,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "objects"),
+			"Number of stored objects by api type.",
+			[]string{"api_type"},
+			nil,
+		),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "object_count_errors_total",
+			Help:      "Number of failures while counting stored objects.",
+		}),
+	})
+}
+
+func (c *objectCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+	c.errors.Describe(ch)
+}
+
+func (c *objectCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.counter.CountObjects()
+	if err != nil {
+		c.errors.Inc()
+	} else {
+		for apiType, n := range counts {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), string(apiType))
+		}
+	}
+	c.errors.Collect(ch)
+}
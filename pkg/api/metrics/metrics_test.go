@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/api/v0/groups/:group_id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNotFound)
+	})
+
+	for _, id := range []string{"group1", "group2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v0/groups/"+id, nil))
+	}
+
+	// パスパラメータが違ってもルートのパターンで集計される
+	n := testutil.ToFloat64(requestsTotal.WithLabelValues(http.MethodGet, "/api/v0/groups/:group_id", "404"))
+	if n != 2 {
+		t.Fatalf("unexpected request count: %v", n)
+	}
+}
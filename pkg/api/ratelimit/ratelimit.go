@@ -0,0 +1,202 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+type RouteClass string
+
+const (
+	RouteClassRead    RouteClass = "read"
+	RouteClassWrite   RouteClass = "write"
+	RouteClassWatch   RouteClass = "watch"
+	RouteClassConsole RouteClass = "console"
+)
+
+// Limit はトークンバケットの設定
+// QPSが0以下の場合は制限しない
+type Limit struct {
+	QPS   float64
+	Burst int
+}
+
+type Config struct {
+	Limits map[RouteClass]Limit
+	// 使われなくなったバケットを破棄するまでの時間
+	IdleTimeout time.Duration
+}
+
+// Classify はリクエストのルートの種類を返す
+func Classify(ctx *gin.Context) RouteClass {
+	path := ctx.FullPath()
+	if path == "" {
+		path = ctx.Request.URL.Path
+	}
+
+	switch {
+	case strings.Contains(path, "/watches"):
+		return RouteClassWatch
+	case strings.HasSuffix(path, "/ws"),
+		strings.HasSuffix(path, "/console"),
+		strings.HasSuffix(path, "/download"):
+		return RouteClassConsole
+	}
+
+	switch ctx.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return RouteClassWrite
+	}
+	return RouteClassRead
+}
+
+// clientKey は認証済みであればidentity、そうでなければ送信元IPを返す
+// User-AgentやX-Forwarded-Forはクライアントが自由に変えられるので使わない
+func clientKey(ctx *gin.Context) string {
+	if identity, ok := auth.GetIdentity(ctx); ok && identity.Name != "" {
+		return "identity:" + identity.Name
+	}
+
+	host, _, err := net.SplitHostPort(ctx.Request.RemoteAddr)
+	if err != nil {
+		host = ctx.Request.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	lastUsed time.Time
+}
+
+// take はトークンを1つ消費する
+// 足りない場合は次のトークンが貯まるまでの時間を返す
+func (b *bucket) take(limit Limit, now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.QPS)
+	b.last = now
+	b.lastUsed = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / limit.QPS * float64(time.Second))
+	return false, wait
+}
+
+type Limiter struct {
+	config Config
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	lastGC  time.Time
+}
+
+func NewLimiter(config Config) *Limiter {
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = time.Minute * 10
+	}
+	return &Limiter{
+		config:  config,
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+// Allow はclassのリクエストを受け付けるかどうかを返す
+// 受け付けない場合は再試行までの時間を返す
+func (l *Limiter) Allow(class RouteClass, key string) (bool, time.Duration) {
+	limit, ok := l.config.Limits[class]
+	if !ok || limit.QPS <= 0 {
+		return true, 0
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.gc(now)
+
+	k := string(class) + "/" + key
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{
+			tokens: float64(limit.Burst),
+			last:   now,
+		}
+		l.buckets[k] = b
+	}
+
+	return b.take(limit, now)
+}
+
+func (l *Limiter) gc(now time.Time) {
+	if now.Sub(l.lastGC) < l.config.IdleTimeout {
+		return
+	}
+	l.lastGC = now
+
+	for k, b := range l.buckets {
+		if now.Sub(b.lastUsed) >= l.config.IdleTimeout {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// Middleware はクライアントとルートの種類ごとにリクエストを制限する
+// probeとmetricsが制限されないように、APIのルートだけに使う
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		class := Classify(ctx)
+		ok, wait := l.Allow(class, clientKey(ctx))
+		if ok {
+			ctx.Next()
+			return
+		}
+
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+		meta.ResponseJSON(ctx, http.StatusTooManyRequests, fmt.Errorf("Error: too many %s requests.", class), nil)
+		ctx.Abort()
+	}
+}
+
+// MaxBodySize はmaxBytesより大きいリクエストボディを拒否する
+// 0以下の場合は制限しない
+func MaxBodySize(maxBytes int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if maxBytes <= 0 {
+			ctx.Next()
+			return
+		}
+
+		if ctx.Request.ContentLength > maxBytes {
+			meta.ResponseJSON(ctx, http.StatusRequestEntityTooLarge, fmt.Errorf("Error: request body is larger than %d bytes.", maxBytes), nil)
+			ctx.Abort()
+			return
+		}
+
+		// Content-Lengthが無い場合も読み込みを制限する
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBytes)
+		ctx.Next()
+	}
+}
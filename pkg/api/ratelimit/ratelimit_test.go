@@ -0,0 +1,180 @@
+package ratelimit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/auth"
+)
+
+func newTestRouter(l *Limiter, maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MaxBodySize(maxBytes))
+	r.Use(l.Middleware())
+	r.GET("/api/v0/groups", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	r.POST("/api/v0/groups", func(ctx *gin.Context) {
+		ctx.Status(http.StatusCreated)
+	})
+	return r
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(Config{
+		Limits: map[RouteClass]Limit{
+			RouteClassWrite: {QPS: 0.5, Burst: 2},
+		},
+	})
+	l.now = func() time.Time { return now }
+	r := newTestRouter(l, 0)
+
+	post := func(remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v0/groups", nil)
+		req.RemoteAddr = remoteAddr
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := post("192.0.2.1:1234"); w.Code != http.StatusCreated {
+			t.Fatalf("unexpected status: %d", w.Code)
+		}
+	}
+
+	w := post("192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("unexpected Retry-After: %s", w.Header().Get("Retry-After"))
+	}
+
+	// 送信元ごとにバケットが分かれる
+	if w := post("192.0.2.2:1234"); w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	// 制限の無いルートは影響を受けない
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v0/groups", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	now = now.Add(time.Second * 2)
+	if w := post("192.0.2.1:1234"); w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+func TestLimiterIgnoresClientHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := NewLimiter(Config{
+		Limits: map[RouteClass]Limit{
+			RouteClassRead: {QPS: 1, Burst: 1},
+		},
+	})
+	l.now = func() time.Time { return time.Unix(0, 0) }
+	r := gin.New()
+	r.Use(auth.ClientCertAuthentication())
+	r.Use(l.Middleware())
+	r.GET("/api/v0/groups", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	get := func(commonName string, header http.Header) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v0/groups", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if commonName != "" {
+			req.TLS = &tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{
+					{Subject: pkix.Name{CommonName: commonName}},
+				}},
+			}
+		}
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 証明書がある場合はUser-Agentを変えても同じバケットを使う
+	if code := get("core:node1", http.Header{"User-Agent": {"agent1"}}); code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if code := get("core:node1", http.Header{"User-Agent": {"agent2"}}); code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status: %d", code)
+	}
+
+	// 証明書がない場合はX-Forwarded-Forを変えても接続元のアドレスで制限する
+	if code := get("", http.Header{"X-Forwarded-For": {"198.51.100.1"}}); code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	for _, header := range []http.Header{
+		{"X-Forwarded-For": {"198.51.100.2"}},
+		{"X-Real-Ip": {"198.51.100.3"}},
+		{"User-Agent": {"agent3"}},
+	} {
+		if code := get("", header); code != http.StatusTooManyRequests {
+			t.Fatalf("%v: unexpected status: %d", header, code)
+		}
+	}
+
+	// 別の証明書は別のバケットを使う
+	if code := get("node:node1", nil); code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	r := newTestRouter(NewLimiter(Config{}), 8)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v0/groups", strings.NewReader("0123456789")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v0/groups", strings.NewReader("0123")))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		method string
+		path   string
+		want   RouteClass
+	}{
+		{http.MethodGet, "/api/v0/groups/g/namespaces/ns/virtualmachines", RouteClassRead},
+		{http.MethodDelete, "/api/v0/groups/g/namespaces/ns/virtualmachines/vm", RouteClassWrite},
+		{http.MethodGet, "/api/v0/watches", RouteClassWatch},
+		{http.MethodGet, "/api/v0/groups/g/namespaces/ns/virtualmachines/vm/ws", RouteClassConsole},
+		{http.MethodGet, "/api/v0/groups/g/namespaces/ns/blockstorages/bs/download", RouteClassConsole},
+	}
+	for _, tt := range tests {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(tt.method, tt.path, nil)
+		if got := Classify(ctx); got != tt.want {
+			t.Errorf("%s %s: got %s, want %s", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package scheme
+
+import (
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/imagetag"
+)
+
+func init() {
+	// core
+	Register(&Resource{
+		APIType: meta.APITypeGroupV0,
+		Scope:   ScopeCluster,
+		Plural:  "groups",
+		Names:   []string{"group"},
+		New:     func() interface{} { return &core.Group{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeNamespaceV0,
+		Scope:   ScopeGroup,
+		Plural:  "namespaces",
+		Names:   []string{"namespace", "ns"},
+		New:     func() interface{} { return &core.Namespace{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeExternalIPPoolV0,
+		Scope:   ScopeCluster,
+		Plural:  "externalippools",
+		Names:   []string{"externalippool", "eippool"},
+		New:     func() interface{} { return &core.ExternalIPPool{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeExternalIPV0,
+		Scope:   ScopeCluster,
+		Plural:  "externalips",
+		Names:   []string{"externalip", "eip"},
+		New:     func() interface{} { return &core.ExternalIP{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeNetworkV0,
+		Scope:   ScopeNamespace,
+		Plural:  "networks",
+		Names:   []string{"network", "net"},
+		New:     func() interface{} { return &core.Network{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeEventV0,
+		Scope:   ScopeCluster,
+		Plural:  "events",
+		Names:   []string{"event"},
+		New:     func() interface{} { return &core.Event{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeLeaseV0,
+		Scope:   ScopeCluster,
+		Plural:  "leases",
+		Names:   []string{"lease"},
+		New:     func() interface{} { return &core.Lease{} },
+	})
+
+	// system
+	Register(&Resource{
+		APIType: meta.APITypeNodeV0,
+		Scope:   ScopeCluster,
+		Plural:  "nodes",
+		Names:   []string{"node"},
+		New:     func() interface{} { return &system.Node{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeNodeNetworkV0,
+		Scope:   ScopeNamespace,
+		Plural:  "nodenetworks",
+		Names:   []string{"nodenetwork"},
+		New:     func() interface{} { return &system.NodeNetwork{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeBlockStorageV0,
+		Scope:   ScopeNamespace,
+		Plural:  "blockstorages",
+		Names:   []string{"blockstorage", "bs"},
+		New:     func() interface{} { return &system.BlockStorage{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeVirtualMachineV0,
+		Scope:   ScopeNamespace,
+		Plural:  "virtualmachines",
+		Names:   []string{"virtualmachine", "vm"},
+		New:     func() interface{} { return &system.VirtualMachine{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeVirtualRouterV0,
+		Scope:   ScopeNamespace,
+		Plural:  "virtualrouters",
+		Names:   []string{"virtualrouter", "vr"},
+		New:     func() interface{} { return &system.VirtualRouter{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeImageV0,
+		Scope:   ScopeGroup,
+		Plural:  "images",
+		Names:   []string{"image"},
+		New:     func() interface{} { return &system.Image{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeImageEntityV0,
+		Scope:   ScopeGroup,
+		Plural:  "imageentities",
+		Names:   []string{"imageentity", "ie"},
+		New:     func() interface{} { return &system.ImageEntity{} },
+	})
+	Register(&Resource{
+		APIType: meta.APITypeImageTagV0,
+		Scope:   ScopeGroup,
+		Plural:  "imagetags",
+		Names:   []string{"imagetag", "it"},
+		New:     func() interface{} { return &system.ImageTag{} },
+		// idはimageNameとtagから決まる
+		Default: func(obj interface{}) {
+			it := obj.(*system.ImageTag)
+			if it.ID == "" {
+				it.ID = imagetag.GetID(it.Spec.ImageName, it.Spec.Tag)
+			}
+		},
+	})
+}
@@ -0,0 +1,197 @@
+// Package scheme はapiTypeとGoの型、URL上のスコープを対応付ける
+// humcliやdynamic clientのように全てのリソースを同じように扱う処理はここを参照する
+// 新しいリソースを追加する場合は register.go に登録する
+package scheme
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Scope はリソースがどの単位で区切られているか
+type Scope int
+
+const (
+	// /api/v0/<plural>/<id>
+	ScopeCluster Scope = iota
+	// /api/v0/groups/<group>/<plural>/<id>
+	ScopeGroup
+	// /api/v0/groups/<group>/namespaces/<namespace>/<plural>/<id>
+	ScopeNamespace
+)
+
+// Resource は1つのapiTypeの登録情報
+type Resource struct {
+	APIType meta.APIType
+	Scope   Scope
+
+	// URLのパスとListのレスポンスで使う名前 e.g. virtualmachines
+	Plural string
+
+	// humcliで `<name>/<id>` として指定できる名前
+	Names []string
+
+	// 空のオブジェクトを返す
+	New func() interface{}
+
+	// 作成・更新の前に省略された値を埋める。nilの場合は何もしない
+	Default func(obj interface{})
+}
+
+// Kind はapiTypeのリソース名を返す
+// apiserverのレスポンスではこの名前でリソースが返る
+// e.g. systemv0/virtualmachine -> virtualmachine
+func (r *Resource) Kind() string {
+	return string(r.APIType)[strings.LastIndex(string(r.APIType), "/")+1:]
+}
+
+// Version はapiTypeのバージョンを返す
+// e.g. systemv0/virtualmachine -> v0
+func (r *Resource) Version() string {
+	prefix := string(r.APIType)[:strings.Index(string(r.APIType), "/")]
+	return prefix[strings.LastIndex(prefix, "v"):]
+}
+
+// PathElements はapiserverのパスを `/api/<version>` 以降の要素で返す
+// idを省略した場合はList, Createのパスになる
+func (r *Resource) PathElements(groupID, namespaceID string, id ...string) []string {
+	elements := []string{}
+	switch r.Scope {
+	case ScopeNamespace:
+		elements = append(elements, "groups", groupID, "namespaces", namespaceID)
+	case ScopeGroup:
+		elements = append(elements, "groups", groupID)
+	}
+	elements = append(elements, r.Plural)
+	return append(elements, id...)
+}
+
+// Reference はスコープに含まれないgroup, namespaceを除いた参照を返す
+func (r *Resource) Reference(groupID, namespaceID, id string) meta.ObjectReference {
+	ref := meta.ObjectReference{
+		APIType: r.APIType,
+		ID:      id,
+	}
+	switch r.Scope {
+	case ScopeNamespace:
+		ref.Namespace = namespaceID
+		fallthrough
+	case ScopeGroup:
+		ref.Group = groupID
+	}
+	return ref
+}
+
+var (
+	resources   = []*Resource{}
+	byAPIType   = map[meta.APIType]*Resource{}
+	byName      = map[string]*Resource{}
+	errNotFound = errors.New("unknown apiType")
+)
+
+// Register はリソースを登録する
+// apiTypeや名前が重複している場合はpanicする
+func Register(r *Resource) {
+	if _, ok := byAPIType[r.APIType]; ok {
+		panic(fmt.Sprintf("scheme: apiType `%s` is already registered", r.APIType))
+	}
+	for _, name := range r.Names {
+		if _, ok := byName[name]; ok {
+			panic(fmt.Sprintf("scheme: name `%s` is already registered", name))
+		}
+	}
+
+	resources = append(resources, r)
+	byAPIType[r.APIType] = r
+	for _, name := range r.Names {
+		byName[name] = r
+	}
+}
+
+// Resources は登録されている全てのリソースを登録順に返す
+func Resources() []*Resource {
+	return append([]*Resource{}, resources...)
+}
+
+// Lookup はapiTypeのリソースを返す
+func Lookup(apiType meta.APIType) (*Resource, error) {
+	r, ok := byAPIType[apiType]
+	if !ok {
+		return nil, errors.Wrapf(errNotFound, "`%s`", apiType)
+	}
+	return r, nil
+}
+
+// LookupName はhumcliで指定された名前のリソースを返す
+// 大文字小文字は区別しない
+func LookupName(name string) (*Resource, error) {
+	r, ok := byName[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown kind `%s`", name)
+	}
+	return r, nil
+}
+
+// IsUnknownAPIType はerrが登録されていないapiTypeのエラーかどうかを返す
+func IsUnknownAPIType(err error) bool {
+	return errors.Cause(err) == errNotFound
+}
+
+// New はapiTypeの空のオブジェクトを返す
+func New(apiType meta.APIType) (interface{}, error) {
+	r, err := Lookup(apiType)
+	if err != nil {
+		return nil, err
+	}
+	return r.New(), nil
+}
+
+// FromObject はobjをapiTypeのGoの型に変換し、省略された値を埋める
+// objはマニフェストをデコードしたものでもToObjectで変換したものでもよい
+func FromObject(obj *meta.Object) (interface{}, error) {
+	r, err := Lookup(obj.Meta.APIType)
+	if err != nil {
+		return nil, err
+	}
+
+	// yamlでデコードしたobjはmap[interface{}]interface{}を含むため、yamlを経由する
+	buf, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	v := r.New()
+	if err := yaml.Unmarshal(buf, v); err != nil {
+		return nil, errors.Wrapf(err, "decode %s", obj.Meta.APIType)
+	}
+
+	if r.Default != nil {
+		r.Default(v)
+	}
+	return v, nil
+}
+
+// ToObject はGoの型のリソースをmeta.Objectに変換する
+func ToObject(v interface{}) (*meta.Object, error) {
+	buf, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	obj := &meta.Object{}
+	if err := yaml.Unmarshal(buf, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// DefaultObject は省略された値を埋めたobjのコピーを返す
+func DefaultObject(obj *meta.Object) (*meta.Object, error) {
+	v, err := FromObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	return ToObject(v)
+}
@@ -0,0 +1,155 @@
+package scheme
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLookup(t *testing.T) {
+	r, err := Lookup(meta.APITypeVirtualMachineV0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Kind() != "virtualmachine" || r.Version() != "v0" {
+		t.Fatalf("unexpected kind or version: %s %s", r.Kind(), r.Version())
+	}
+
+	byName, err := LookupName("VM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byName != r {
+		t.Fatalf("unexpected resource: %s", byName.APIType)
+	}
+
+	if _, err := Lookup("systemv0/unknown"); !IsUnknownAPIType(err) {
+		t.Fatalf("expected unknown apiType, but got %v", err)
+	}
+	if _, err := LookupName("unknown"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestPathElements(t *testing.T) {
+	cases := []struct {
+		apiType meta.APIType
+		want    []string
+	}{
+		{meta.APITypeNodeV0, []string{"nodes", "id"}},
+		{meta.APITypeImageV0, []string{"groups", "g", "images", "id"}},
+		{meta.APITypeBlockStorageV0, []string{"groups", "g", "namespaces", "ns", "blockstorages", "id"}},
+	}
+	for _, c := range cases {
+		r, err := Lookup(c.apiType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.PathElements("g", "ns", "id"); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected %v, but got %v", c.apiType, c.want, got)
+		}
+	}
+}
+
+func TestReference(t *testing.T) {
+	r, err := Lookup(meta.APITypeImageV0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// groupスコープのリソースはnamespaceを含まない
+	ref := r.Reference("g", "ns", "id")
+	if ref.Group != "g" || ref.Namespace != "" || ref.ID != "id" {
+		t.Fatalf("unexpected reference: %+v", ref)
+	}
+}
+
+func TestResources(t *testing.T) {
+	for _, r := range Resources() {
+		v := r.New()
+		obj, err := ToObject(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj.Meta.APIType = r.APIType
+		if _, err := FromObject(obj); err != nil {
+			t.Errorf("%s: %s", r.APIType, err.Error())
+		}
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	manifest := `
+meta:
+  apiType: systemv0/virtualmachine
+  id: vm1
+  group: g
+  namespace: ns
+spec:
+  requestVcpus: 1000m
+  nics:
+    - networkID: net1
+      ipv4Address: 10.0.0.1
+`
+	obj := &meta.Object{}
+	if err := yaml.Unmarshal([]byte(manifest), obj); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := FromObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, ok := v.(*system.VirtualMachine)
+	if !ok {
+		t.Fatalf("unexpected type: %T", v)
+	}
+	if vm.ID != "vm1" || vm.Spec.RequestVcpus != "1000m" || vm.Spec.NICs[0].NetworkID != "net1" {
+		t.Fatalf("unexpected vm: %+v", vm)
+	}
+
+	// meta.Objectに戻しても値が変わらない
+	now := time.Now().UTC().Truncate(time.Second)
+	vm.DeletionTimestamp = &now
+	obj, err = ToObject(vm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = FromObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := yaml.Marshal(vm)
+	got, _ := yaml.Marshal(v)
+	if string(got) != string(want) {
+		t.Fatalf("expected %s, but got %s", want, got)
+	}
+	if !v.(*system.VirtualMachine).DeletionTimestamp.Equal(now) {
+		t.Fatalf("unexpected deletionTimestamp: %s", v.(*system.VirtualMachine).DeletionTimestamp)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	obj := &meta.Object{
+		Meta: meta.Meta{
+			APIType: meta.APITypeImageTagV0,
+			Group:   "g",
+		},
+		Spec: map[string]interface{}{
+			"imageName": "ubuntu",
+			"tag":       "latest",
+		},
+	}
+
+	obj, err := DefaultObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Meta.ID != "ubuntu:latest" {
+		t.Fatalf("unexpected id: %s", obj.Meta.ID)
+	}
+}
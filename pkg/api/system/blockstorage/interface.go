@@ -21,6 +21,10 @@ const (
 	basePath = "groups/:group_id/namespaces/:namespace_id/blockstorages"
 )
 
+// AnnotationNodeName はBSを割り当てたノードのannotation
+// ノードの証明書ではこのノードのリソースのみ変更できる
+const AnnotationNodeName = "blockstoragev0/node_name"
+
 func NewBlockStorageHandler(router *gin.RouterGroup, bshi BlockStorageHandlerInterface) *BlockStorageHandler {
 	return &BlockStorageHandler{
 		router: router,
@@ -1,31 +1,73 @@
 package v0
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/conversion"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/api/system/blockstorage"
 	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 )
 
 type BlockStorageHandler struct {
 	blockstorage.BlockStorageHandlerInterface
 
 	store store.Store
+	codec Codec
+
+	// agentのダウンロードAPIへプロキシする際のtransport
+	proxyTransport *http.Transport
+}
+
+// Codec はリクエストとレスポンスのbodyをv0のBlockStorageと相互に変換する
+type Codec struct {
+	Decode func(ctx *gin.Context) (*system.BlockStorage, error)
+	Encode func(bs *system.BlockStorage) interface{}
+	// UpdateStatusでrequestのstatusをbsに反映する
+	SetStatus func(bs, request *system.BlockStorage)
+}
+
+var codecV0 = Codec{
+	Decode: func(ctx *gin.Context) (*system.BlockStorage, error) {
+		request := &system.BlockStorage{}
+		err := ctx.Bind(request)
+		return request, err
+	},
+	Encode: func(bs *system.BlockStorage) interface{} {
+		return bs
+	},
+	SetStatus: func(bs, request *system.BlockStorage) {
+		bs.Status = request.Status
+	},
 }
 
 func NewBlockStorageHandler(store store.Store) *BlockStorageHandler {
+	return NewBlockStorageHandlerWithCodec(store, codecV0)
+}
+
+// NewBlockStorageHandlerWithCodec はv0以外のバージョンのハンドラを作る
+func NewBlockStorageHandlerWithCodec(store store.Store, codec Codec) *BlockStorageHandler {
 	return &BlockStorageHandler{
-		store: store,
+		store:          store,
+		codec:          codec,
+		proxyTransport: tlsutil.NewTransport(nil),
 	}
 }
 
+func (h *BlockStorageHandler) SetProxyTLSConfig(config *tls.Config) {
+	h.proxyTransport = tlsutil.NewTransport(config)
+}
+
 func (h *BlockStorageHandler) FindAll(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
@@ -42,8 +84,13 @@ func (h *BlockStorageHandler) FindAll(ctx *gin.Context) {
 
 	h.store.List(getKey(groupID, nsID, ""), f)
 
+	res := make([]interface{}, 0, len(bsList))
+	for _, bs := range bsList {
+		res = append(res, h.codec.Encode(bs))
+	}
+
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"blockstorages": bsList,
+		"blockstorages": res,
 	})
 
 }
@@ -59,7 +106,7 @@ func (h *BlockStorageHandler) Find(ctx *gin.Context) {
 	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"blockstorage": bs,
+		"blockstorage": h.codec.Encode(&bs),
 	})
 }
 
@@ -73,8 +120,7 @@ func (h *BlockStorageHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	var request system.BlockStorage
-	err = ctx.Bind(&request)
+	request, err := h.codec.Decode(ctx)
 	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
@@ -104,19 +150,31 @@ func (h *BlockStorageHandler) Create(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
+	// namespaceが削除されたらgarbage collectorで削除されるようにする
+	if ns.UID != "" && !request.IsOwnedBy(ns.Meta) {
+		request.OwnerReferences = append(request.OwnerReferences, meta.NewOwnerReference(ns.Meta))
+	}
+
 	request.APIType = meta.APITypeBlockStorageV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if err := conversion.Check(h.store, key, request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
-		"blockstorage": request,
+		"blockstorage": h.codec.Encode(request),
 	})
 }
 
 func (h *BlockStorageHandler) Update(ctx *gin.Context) {
 	groupID, nsID, bsID := getIDs(ctx)
 
-	var request system.BlockStorage
-	err := ctx.Bind(&request)
+	request, err := h.codec.Decode(ctx)
 	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
@@ -144,18 +202,43 @@ func (h *BlockStorageHandler) Update(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	var bs system.BlockStorage
+	if err := h.store.Get(key, &bs); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: BlockStorage `%s` is not found.", request.ID), nil)
+		return
+	}
+	if !auth.AuthorizeNodeObject(ctx, "BlockStorage", bs.ID, bs.Annotations[blockstorage.AnnotationNodeName], request.Annotations[blockstorage.AnnotationNodeName]) {
+		return
+	}
+
+	var ns core.Namespace
+	if err := h.store.Get(filepath.Join("namespace", groupID, nsID), &ns); err == nil && ns.UID != "" && !request.IsOwnedBy(ns.Meta) {
+		request.OwnerReferences = append(request.OwnerReferences, meta.NewOwnerReference(ns.Meta))
+	}
+
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.UID = bs.UID
+	request.Generation = meta.NextGeneration(bs.Generation, bs.Spec, request.Spec)
+	request.DeletionTimestamp = bs.DeletionTimestamp
+	request.KeepFinalizers(bs.Finalizers)
+	if err := conversion.Check(h.store, key, request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
-		"blockstorage": request,
+		"blockstorage": h.codec.Encode(request),
 	})
 }
 
 func (h *BlockStorageHandler) UpdateStatus(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
-	var request system.BlockStorage
-	if err := ctx.Bind(&request); err != nil {
+	request, err := h.codec.Decode(ctx)
+	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
 	}
@@ -170,12 +253,21 @@ func (h *BlockStorageHandler) UpdateStatus(ctx *gin.Context) {
 		meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
 		return
 	}
+	if !auth.AuthorizeNodeObject(ctx, "BlockStorage", bs.ID, bs.Annotations[blockstorage.AnnotationNodeName]) {
+		return
+	}
 
-	bs.Status = request.Status
-	h.store.Put(key, bs)
+	h.codec.SetStatus(&bs, request)
+	if err := conversion.Check(h.store, key, bs); err != nil {
+		meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, bs)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
-		"blockstorage": bs,
+		"blockstorage": h.codec.Encode(&bs),
 	})
 }
 
@@ -186,7 +278,37 @@ func (h *BlockStorageHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var bs system.BlockStorage
+	if err := h.store.Get(key, &bs); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("BlockStorage `%s` is not found.", bsID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		bs.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(bs.Finalizers) != 0 {
+		bs.MarkDeletion()
+		if err := conversion.Check(h.store, key, bs); err != nil {
+			meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+			return
+		}
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, bs)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"blockstorage": h.codec.Encode(&bs),
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"blockstorage": nil,
@@ -212,13 +334,21 @@ func (h *BlockStorageHandler) ProxyDownloadAPI(ctx *gin.Context) {
 		return
 	}
 
+	scheme, ok := bs.Annotations["bs-download-scheme"]
+	if !ok {
+		scheme = "http"
+	}
+
 	director := func(req *http.Request) {
-		req.URL.Scheme = "http"
+		req.URL.Scheme = scheme
 		req.URL.Host = target
 		req.Host = target
 	}
 
-	proxy := &httputil.ReverseProxy{Director: director}
+	proxy := &httputil.ReverseProxy{
+		Director:  director,
+		Transport: h.proxyTransport,
+	}
 	proxy.ServeHTTP(ctx.Writer, ctx.Request)
 }
 
@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/system"
+	bsv0 "github.com/ophum/humstack/pkg/api/system/blockstorage/v0"
+	systemv1 "github.com/ophum/humstack/pkg/api/system/v1"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// NewBlockStorageHandler はbodyだけをv1の形にしたハンドラを返す
+// statusのdownloadはv0ではannotationなのでUpdateStatusでも反映する
+func NewBlockStorageHandler(store store.Store) *bsv0.BlockStorageHandler {
+	return bsv0.NewBlockStorageHandlerWithCodec(store, bsv0.Codec{
+		Decode: func(ctx *gin.Context) (*system.BlockStorage, error) {
+			request := &systemv1.BlockStorage{}
+			if err := ctx.Bind(request); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertBlockStorageToV0(request), nil
+		},
+		Encode: func(bs *system.BlockStorage) interface{} {
+			return systemv1.ConvertBlockStorageFromV0(bs)
+		},
+		SetStatus: systemv1.SetBlockStorageStatusV0,
+	})
+}
@@ -1,30 +1,43 @@
 package v0
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/api/system/image"
+	"github.com/ophum/humstack/pkg/api/system/imageentity"
+	"github.com/ophum/humstack/pkg/api/system/imagetag"
 	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 )
 
 type ImageHandler struct {
 	image.ImageHandlerInterface
 
 	store store.Store
+
+	// agentのダウンロードAPIへプロキシする際のtransport
+	proxyTransport *http.Transport
 }
 
 func NewImageHandler(store store.Store) *ImageHandler {
 	return &ImageHandler{
-		store: store,
+		store:          store,
+		proxyTransport: tlsutil.NewTransport(nil),
 	}
 }
 
+func (h *ImageHandler) SetProxyTLSConfig(config *tls.Config) {
+	h.proxyTransport = tlsutil.NewTransport(config)
+}
+
 func (h *ImageHandler) FindAll(ctx *gin.Context) {
 	groupID, _ := getIDs(ctx)
 
@@ -88,8 +101,14 @@ func (h *ImageHandler) Create(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
+	request.Group = groupID
 	request.APIType = meta.APITypeImageV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+		h.ownImageEntities(&request, nil)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"image": request,
@@ -120,7 +139,24 @@ func (h *ImageHandler) Update(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	var im system.Image
+	if err := h.store.Get(key, &im); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: Image `%s` is not found.", request.ID), nil)
+		return
+	}
+
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.Group = groupID
+	request.APIType = im.APIType
+	request.UID = im.UID
+	request.Generation = meta.NextGeneration(im.Generation, im.Spec, request.Spec)
+	request.DeletionTimestamp = im.DeletionTimestamp
+	request.KeepFinalizers(im.Finalizers)
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+		// EntityMapから外したImageEntityの所有者からも外す
+		h.ownImageEntities(&request, &im)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"image": request,
@@ -134,7 +170,33 @@ func (h *ImageHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var im system.Image
+	if err := h.store.Get(key, &im); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Image `%s` is not found.", imID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		im.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(im.Finalizers) != 0 {
+		im.MarkDeletion()
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, im)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"image": im,
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"image": nil,
@@ -155,7 +217,7 @@ func (h *ImageHandler) ProxyDownloadAPI(ctx *gin.Context) {
 		return
 	}
 
-	imageEntityID, ok := image.Spec.EntityMap[tag]
+	imageEntityID, ok := h.resolveImageEntityID(groupID, &image, tag)
 	if !ok {
 		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("image tag not found"), gin.H{})
 		return
@@ -174,17 +236,52 @@ func (h *ImageHandler) ProxyDownloadAPI(ctx *gin.Context) {
 		return
 	}
 
+	scheme, ok := imageEntity.Annotations["image-entity-download-scheme"]
+	if !ok {
+		scheme = "http"
+	}
+
 	director := func(req *http.Request) {
-		req.URL.Scheme = "http"
+		req.URL.Scheme = scheme
 		req.URL.Host = target
 		req.Host = target
 	}
 
-	proxy := &httputil.ReverseProxy{Director: director}
+	proxy := &httputil.ReverseProxy{
+		Director:  director,
+		Transport: h.proxyTransport,
+	}
 	proxy.ServeHTTP(ctx.Writer, ctx.Request)
 
 }
 
+// ownImageEntities はEntityMapで参照しているImageEntityの所有者をimageに合わせる
+// previousは更新前のimageで、参照しなくなったImageEntityの所有者からimageを外す
+func (h *ImageHandler) ownImageEntities(image, previous *system.Image) {
+	imageEntityIDs := []string{}
+	for _, imageEntityID := range image.Spec.EntityMap {
+		imageEntityIDs = append(imageEntityIDs, imageEntityID)
+	}
+	if previous != nil {
+		for _, imageEntityID := range previous.Spec.EntityMap {
+			imageEntityIDs = append(imageEntityIDs, imageEntityID)
+		}
+	}
+	imageentity.ReconcileOwners(h.store, image.Group, imageEntityIDs...)
+}
+
+// resolveImageEntityID はimageのtagが指すimageEntityのIDを返す
+// ImageTagがない場合はEntityMapを参照する
+func (h *ImageHandler) resolveImageEntityID(groupID string, image *system.Image, tag string) (string, bool) {
+	var it system.ImageTag
+	if err := h.store.Get(filepath.Join("imagetag", groupID, imagetag.GetID(image.ID, tag)), &it); err == nil && imagetag.Matches(&it, image.ID, tag) {
+		return it.Spec.ImageEntityID, true
+	}
+
+	imageEntityID, ok := image.Spec.EntityMap[tag]
+	return imageEntityID, ok
+}
+
 func getIDs(ctx *gin.Context) (groupID, imID string) {
 	groupID = ctx.Param("group_id")
 	imID = ctx.Param("image_id")
@@ -19,6 +19,10 @@ const (
 	basePath = "groups/:group_id/imageentities"
 )
 
+// AnnotationNodeName はImageEntityを作成したノードのannotation
+// ノードの証明書ではこのノードのリソースのみ変更できる
+const AnnotationNodeName = "imageentityv0/node_name"
+
 func NewImageEntityHandler(router *gin.RouterGroup, iehi ImageEntityHandlerInterface) *ImageEntityHandler {
 	return &ImageEntityHandler{
 		router: router,
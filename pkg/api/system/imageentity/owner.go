@@ -0,0 +1,130 @@
+package imageentity
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// ReconcileOwners はImageEntityの所有者のimageを、EntityMapとimagetagで参照しているimageに合わせる
+// imageから参照されているImageEntityが、imageの削除後にgarbage collectorで消えるようにするためのもの
+// 参照されなくなったImageEntityは、imageを削除しても消えないように所有者から外す
+// image, imagetagを更新した後に、変更前と変更後に参照していたImageEntityを指定して呼び出す
+// ImageEntityが存在しない場合は何もしない
+func ReconcileOwners(s store.Store, groupID string, imageEntityIDs ...string) {
+	for _, imageEntityID := range imageEntityIDs {
+		key := filepath.Join("imageentities", groupID, imageEntityID)
+		s.Lock(key)
+
+		var ie system.ImageEntity
+		if err := s.Get(key, &ie); err == nil && !ie.IsDeleting() {
+			refs := OwnerReferences(s, groupID, imageEntityID, ie.OwnerReferences, ie.OwnerReferences)
+			if !reflect.DeepEqual(refs, ie.OwnerReferences) {
+				ie.OwnerReferences = refs
+				s.Put(key, ie)
+			}
+		}
+
+		s.Unlock(key)
+	}
+}
+
+// OwnerReferences はrefsのimage以外の所有者に、imageEntityIDを参照しているimageの所有者を加えたものを返す
+// imageの所有者はクライアントが指定したものではなく、EntityMapとimagetagから決める
+// storedは保存されている所有者で、削除された、または削除中のimageの参照はimageと一緒に消えるように残す
+func OwnerReferences(s store.Store, groupID, imageEntityID string, refs, stored []meta.OwnerReference) []meta.OwnerReference {
+	images := listImages(s, groupID)
+
+	owners := []meta.OwnerReference{}
+	for _, ref := range refs {
+		if ref.APIType != meta.APITypeImageV0 {
+			owners = append(owners, ref)
+		}
+	}
+	for _, ref := range stored {
+		if ref.APIType != meta.APITypeImageV0 {
+			continue
+		}
+		if im, ok := images[ref.ID]; ok && ref.Refers(im.Meta) && !im.IsDeleting() {
+			continue
+		}
+		owners = appendOwner(owners, ref)
+	}
+
+	// 所有者の順番が変わって更新し続けないようにIDの順に並べる
+	ids := []string{}
+	for id := range images {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if im := images[id]; refersImageEntity(im, imageEntityID) {
+			owners = appendOwner(owners, meta.NewOwnerReference(im.Meta))
+		}
+	}
+	for _, it := range listImageTags(s, groupID) {
+		if it.Spec.ImageEntityID != imageEntityID {
+			continue
+		}
+		if im, ok := images[it.Spec.ImageName]; ok {
+			owners = appendOwner(owners, meta.NewOwnerReference(im.Meta))
+		}
+	}
+	return owners
+}
+
+func refersImageEntity(im *system.Image, imageEntityID string) bool {
+	for _, id := range im.Spec.EntityMap {
+		if id == imageEntityID {
+			return true
+		}
+	}
+	return false
+}
+
+func appendOwner(refs []meta.OwnerReference, ref meta.OwnerReference) []meta.OwnerReference {
+	for _, r := range refs {
+		if r == ref {
+			return refs
+		}
+	}
+	return append(refs, ref)
+}
+
+// listImages はgroupのimageをIDごとに返す
+func listImages(s store.Store, groupID string) map[string]*system.Image {
+	imList := []*system.Image{}
+	s.List(filepath.Join("image", groupID)+"/", func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			im := &system.Image{}
+			imList = append(imList, im)
+			m = append(m, im)
+		}
+		return m
+	})
+
+	images := map[string]*system.Image{}
+	for _, im := range imList {
+		images[im.ID] = im
+	}
+	return images
+}
+
+func listImageTags(s store.Store, groupID string) []*system.ImageTag {
+	itList := []*system.ImageTag{}
+	s.List(filepath.Join("imagetag", groupID)+"/", func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			it := &system.ImageTag{}
+			itList = append(itList, it)
+			m = append(m, it)
+		}
+		return m
+	})
+	return itList
+}
@@ -6,8 +6,12 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/conversion"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/blockstorage"
 	"github.com/ophum/humstack/pkg/api/system/imageentity"
 	"github.com/ophum/humstack/pkg/store"
 )
@@ -16,11 +20,35 @@ type ImageEntityHandler struct {
 	imageentity.ImageEntityHandlerInterface
 
 	store store.Store
+	codec Codec
+}
+
+// Codec はAPIのバージョンごとのbodyの読み書き
+type Codec struct {
+	Decode func(ctx *gin.Context) (*system.ImageEntity, error)
+	Encode func(im *system.ImageEntity) interface{}
+}
+
+var codecV0 = Codec{
+	Decode: func(ctx *gin.Context) (*system.ImageEntity, error) {
+		request := &system.ImageEntity{}
+		err := ctx.Bind(request)
+		return request, err
+	},
+	Encode: func(im *system.ImageEntity) interface{} {
+		return im
+	},
 }
 
 func NewImageEntityHandler(store store.Store) *ImageEntityHandler {
+	return NewImageEntityHandlerWithCodec(store, codecV0)
+}
+
+// NewImageEntityHandlerWithCodec はcodecの形でbodyを読み書きするハンドラを作る
+func NewImageEntityHandlerWithCodec(store store.Store, codec Codec) *ImageEntityHandler {
 	return &ImageEntityHandler{
 		store: store,
+		codec: codec,
 	}
 }
 
@@ -40,8 +68,13 @@ func (h *ImageEntityHandler) FindAll(ctx *gin.Context) {
 
 	h.store.List(getKey(groupID, ""), f)
 
+	res := make([]interface{}, 0, len(imList))
+	for _, im := range imList {
+		res = append(res, h.codec.Encode(im))
+	}
+
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"imageentities": imList,
+		"imageentities": res,
 	})
 
 }
@@ -57,15 +90,14 @@ func (h *ImageEntityHandler) Find(ctx *gin.Context) {
 	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"imageentity": im,
+		"imageentity": h.codec.Encode(&im),
 	})
 }
 
 func (h *ImageEntityHandler) Create(ctx *gin.Context) {
 	groupID, _ := getIDs(ctx)
 
-	var request system.ImageEntity
-	err := ctx.Bind(&request)
+	request, err := h.codec.Decode(ctx)
 	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
@@ -88,18 +120,25 @@ func (h *ImageEntityHandler) Create(ctx *gin.Context) {
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeImageEntityV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if err := conversion.Check(h.store, key, request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
-		"imageentity": request,
+		"imageentity": h.codec.Encode(request),
 	})
 }
 
 func (h *ImageEntityHandler) Update(ctx *gin.Context) {
 	groupID, imID := getIDs(ctx)
 
-	var request system.ImageEntity
-	err := ctx.Bind(&request)
+	request, err := h.codec.Decode(ctx)
 	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
@@ -119,10 +158,33 @@ func (h *ImageEntityHandler) Update(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	var im system.ImageEntity
+	if err := h.store.Get(key, &im); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: ImageEntity `%s` is not found.", request.ID), nil)
+		return
+	}
+	if !auth.AuthorizeNodeObject(ctx, "ImageEntity", im.ID, h.nodeNames(&im, request)...) {
+		return
+	}
+
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	// imageの所有者はリクエストによらず、EntityMapとimagetagで参照しているimageにする
+	request.OwnerReferences = imageentity.OwnerReferences(h.store, groupID, request.ID, request.OwnerReferences, im.OwnerReferences)
+
+	request.UID = im.UID
+	request.Generation = meta.NextGeneration(im.Generation, im.Spec, request.Spec)
+	request.DeletionTimestamp = im.DeletionTimestamp
+	request.KeepFinalizers(im.Finalizers)
+	if err := conversion.Check(h.store, key, request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
-		"imageentity": request,
+		"imageentity": h.codec.Encode(request),
 	})
 }
 
@@ -133,13 +195,60 @@ func (h *ImageEntityHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var im system.ImageEntity
+	if err := h.store.Get(key, &im); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ImageEntity `%s` is not found.", imID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		im.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(im.Finalizers) != 0 {
+		im.MarkDeletion()
+		if err := conversion.Check(h.store, key, im); err != nil {
+			meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+			return
+		}
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, im)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"imageentity": h.codec.Encode(&im),
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"imageentity": nil,
 	})
 }
 
+// nodeNames はImageEntityを変更できるノードを返す
+// ノードが決まるまではコピー元のBSを割り当てたノードとする
+func (h *ImageEntityHandler) nodeNames(stored, request *system.ImageEntity) []string {
+	nodeName := stored.Annotations[imageentity.AnnotationNodeName]
+	if nodeName == "" {
+		var bs system.BlockStorage
+		h.store.Get(filepath.Join("blockstorage", stored.Group, stored.Spec.Source.Namespace, stored.Spec.Source.BlockStorageID), &bs)
+		nodeName = bs.Annotations[blockstorage.AnnotationNodeName]
+	}
+
+	nodeNames := []string{nodeName}
+	if n, ok := request.Annotations[imageentity.AnnotationNodeName]; ok {
+		nodeNames = append(nodeNames, n)
+	}
+	return nodeNames
+}
+
 func getIDs(ctx *gin.Context) (groupID, imID string) {
 	groupID = ctx.Param("group_id")
 	imID = ctx.Param("image_entity_id")
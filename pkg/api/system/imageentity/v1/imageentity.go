@@ -0,0 +1,25 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/system"
+	iev0 "github.com/ophum/humstack/pkg/api/system/imageentity/v0"
+	systemv1 "github.com/ophum/humstack/pkg/api/system/v1"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// NewImageEntityHandler はv0のハンドラにv1のcodecを設定したもの
+func NewImageEntityHandler(store store.Store) *iev0.ImageEntityHandler {
+	return iev0.NewImageEntityHandlerWithCodec(store, iev0.Codec{
+		Decode: func(ctx *gin.Context) (*system.ImageEntity, error) {
+			request := &systemv1.ImageEntity{}
+			if err := ctx.Bind(request); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertImageEntityToV0(request), nil
+		},
+		Encode: func(im *system.ImageEntity) interface{} {
+			return systemv1.ConvertImageEntityFromV0(im)
+		},
+	})
+}
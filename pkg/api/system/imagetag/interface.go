@@ -0,0 +1,67 @@
+package imagetag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+type ImageTagHandlerInterface interface {
+	FindAll(ctx *gin.Context)
+	Find(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+type ImageTagHandler struct {
+	router *gin.RouterGroup
+	ithi   ImageTagHandlerInterface
+}
+
+const (
+	basePath = "groups/:group_id/imagetags"
+)
+
+func NewImageTagHandler(router *gin.RouterGroup, ithi ImageTagHandlerInterface) *ImageTagHandler {
+	return &ImageTagHandler{
+		router: router,
+		ithi:   ithi,
+	}
+}
+
+func (h *ImageTagHandler) RegisterHandlers() {
+	it := h.router.Group(basePath)
+	{
+		it.GET("", h.ithi.FindAll)
+		it.GET("/:image_tag_id", h.ithi.Find)
+		it.POST("", h.ithi.Create)
+		it.PUT("/:image_tag_id", h.ithi.Update)
+		it.DELETE("/:image_tag_id", h.ithi.Delete)
+	}
+}
+
+// Separator はIDのimageNameとtagの区切り
+// imageNameとtagには含められないので、IDから一意にimageNameとtagが決まる
+const Separator = ":"
+
+// GetID はimageNameとtagからImageTagのIDを返す
+func GetID(imageName, tag string) string {
+	return imageName + Separator + tag
+}
+
+// ValidateName はimageNameまたはtagにIDで使えない文字が含まれていないかを確認する
+// "/" はstoreのkeyの区切りになるので使えない
+func ValidateName(field, name string) error {
+	if strings.Contains(name, Separator) || strings.Contains(name, "/") {
+		return fmt.Errorf("Error: %s `%s` must not contain `%s` or `/`.", field, name, Separator)
+	}
+	return nil
+}
+
+// Matches はitがimageNameのtagを指すかを返す
+func Matches(it *system.ImageTag, imageName, tag string) bool {
+	return it.Spec.ImageName == imageName && it.Spec.Tag == tag
+}
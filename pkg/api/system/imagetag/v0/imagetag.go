@@ -0,0 +1,304 @@
+package v0
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/imageentity"
+	"github.com/ophum/humstack/pkg/api/system/imagetag"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+const (
+	// 保持するtagの履歴の数
+	historyLimit = 10
+)
+
+type ImageTagHandler struct {
+	imagetag.ImageTagHandlerInterface
+
+	store store.Store
+}
+
+func NewImageTagHandler(store store.Store) *ImageTagHandler {
+	return &ImageTagHandler{
+		store: store,
+	}
+}
+
+func (h *ImageTagHandler) FindAll(ctx *gin.Context) {
+	groupID, _ := getIDs(ctx)
+
+	itList := []*system.ImageTag{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			it := &system.ImageTag{}
+			itList = append(itList, it)
+			m = append(m, it)
+		}
+		return m
+	}
+
+	h.store.List(getKey(groupID, "")+"/", f)
+
+	// imageNameが指定された場合はそのimageのtagのみ返す
+	if imageName := ctx.Query("imageName"); imageName != "" {
+		filtered := []*system.ImageTag{}
+		for _, it := range itList {
+			if it.Spec.ImageName == imageName {
+				filtered = append(filtered, it)
+			}
+		}
+		itList = filtered
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"imagetags": itList,
+	})
+}
+
+func (h *ImageTagHandler) Find(ctx *gin.Context) {
+	groupID, itID := getIDs(ctx)
+
+	var it system.ImageTag
+	err := h.store.Get(getKey(groupID, itID), &it)
+	if err != nil && err.Error() == "Not Found" {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ImageTag `%s` is not found.", itID), nil)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"imagetag": it,
+	})
+}
+
+func (h *ImageTagHandler) Create(ctx *gin.Context) {
+	groupID, _ := getIDs(ctx)
+
+	var request system.ImageTag
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	// IDは `<imageName>:<tag>` に固定する
+	if request.ID == "" {
+		request.ID = imagetag.GetID(request.Spec.ImageName, request.Spec.Tag)
+	}
+	if request.Name == "" {
+		request.Name = request.ID
+	}
+	request.Group = groupID
+
+	image, code, err := h.validate(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, code, err, nil)
+		return
+	}
+
+	key := getKey(groupID, request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var it system.ImageTag
+	err = h.store.Get(key, &it)
+	if err == nil {
+		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ImageTag `%s` is already exists.", request.ID), nil)
+		return
+	}
+
+	// imageが削除されたらtagも削除されるようにする
+	if !request.IsOwnedBy(image.Meta) {
+		request.OwnerReferences = append(request.OwnerReferences, meta.NewOwnerReference(image.Meta))
+	}
+
+	// 履歴はサーバー側で管理する
+	request.Status.History = []system.ImageTagHistory{
+		{
+			ImageEntityID: request.Spec.ImageEntityID,
+			TaggedAt:      time.Now(),
+		},
+	}
+
+	request.APIType = meta.APITypeImageTagV0
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+		// tagが指すimageEntityもimageと一緒に削除されるようにする
+		imageentity.ReconcileOwners(h.store, groupID, request.Spec.ImageEntityID)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
+		"imagetag": request,
+	})
+}
+
+func (h *ImageTagHandler) Update(ctx *gin.Context) {
+	groupID, itID := getIDs(ctx)
+
+	var request system.ImageTag
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if itID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change ImageTag ID."), nil)
+		return
+	}
+	request.Group = groupID
+
+	image, code, err := h.validate(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, code, err, nil)
+		return
+	}
+
+	key := getKey(groupID, request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var it system.ImageTag
+	if err := h.store.Get(key, &it); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: ImageTag `%s` is not found.", request.ID), nil)
+		return
+	}
+
+	if it.Spec.Immutable {
+		if !request.Spec.Immutable {
+			meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't make immutable ImageTag `%s` mutable.", request.ID), nil)
+			return
+		}
+		if request.Spec.ImageEntityID != it.Spec.ImageEntityID {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ImageTag `%s` is immutable.", request.ID), nil)
+			return
+		}
+	}
+
+	if !request.IsOwnedBy(image.Meta) {
+		request.OwnerReferences = append(request.OwnerReferences, meta.NewOwnerReference(image.Meta))
+	}
+
+	// tagを移動した場合は履歴に残す
+	request.Status.History = it.Status.History
+	if request.Spec.ImageEntityID != it.Spec.ImageEntityID {
+		request.Status.History = append(request.Status.History, system.ImageTagHistory{
+			ImageEntityID: request.Spec.ImageEntityID,
+			TaggedAt:      time.Now(),
+		})
+		if len(request.Status.History) > historyLimit {
+			request.Status.History = request.Status.History[len(request.Status.History)-historyLimit:]
+		}
+	}
+
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.UID = it.UID
+	request.Generation = meta.NextGeneration(it.Generation, it.Spec, request.Spec)
+	request.DeletionTimestamp = it.DeletionTimestamp
+	request.KeepFinalizers(it.Finalizers)
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+		// tagを移動した場合は移動前のimageEntityの所有者からimageを外す
+		imageentity.ReconcileOwners(h.store, groupID, request.Spec.ImageEntityID, it.Spec.ImageEntityID)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"imagetag": request,
+	})
+}
+
+func (h *ImageTagHandler) Delete(ctx *gin.Context) {
+	groupID, itID := getIDs(ctx)
+
+	key := getKey(groupID, itID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var it system.ImageTag
+	if err := h.store.Get(key, &it); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ImageTag `%s` is not found.", itID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		it.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(it.Finalizers) != 0 {
+		it.MarkDeletion()
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, it)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"imagetag": it,
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+		imageentity.ReconcileOwners(h.store, groupID, it.Spec.ImageEntityID)
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"imagetag": nil,
+	})
+}
+
+// validate はtagが指すimageとimageEntityが存在するかを確認し、imageを返す
+// 失敗した場合はレスポンスのステータスコードとエラーを返す
+func (h *ImageTagHandler) validate(it *system.ImageTag) (*system.Image, int, error) {
+	if it.Spec.ImageName == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("Error: imageName is empty.")
+	}
+	if it.Spec.Tag == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("Error: tag is empty.")
+	}
+	if err := imagetag.ValidateName("imageName", it.Spec.ImageName); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if err := imagetag.ValidateName("tag", it.Spec.Tag); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if it.ID != imagetag.GetID(it.Spec.ImageName, it.Spec.Tag) {
+		return nil, http.StatusBadRequest, fmt.Errorf("Error: id must be `%s`.", imagetag.GetID(it.Spec.ImageName, it.Spec.Tag))
+	}
+	if it.Spec.ImageEntityID == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("Error: imageEntityID is empty.")
+	}
+
+	var image system.Image
+	if err := h.store.Get(filepath.Join("image", it.Group, it.Spec.ImageName), &image); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("Error: Image `%s` is not found.", it.Spec.ImageName)
+	}
+
+	var imageEntity system.ImageEntity
+	if err := h.store.Get(filepath.Join("imageentities", it.Group, it.Spec.ImageEntityID), &imageEntity); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("Error: ImageEntity `%s` is not found.", it.Spec.ImageEntityID)
+	}
+
+	return &image, http.StatusOK, nil
+}
+
+func getIDs(ctx *gin.Context) (groupID, itID string) {
+	groupID = ctx.Param("group_id")
+	itID = ctx.Param("image_tag_id")
+	return groupID, itID
+}
+
+func getKey(groupID, id string) string {
+	return filepath.Join("imagetag", groupID, id)
+}
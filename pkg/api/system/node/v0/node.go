@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/auth"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/api/system/node"
@@ -69,6 +71,11 @@ func (h *NodeHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	// ノードの証明書では自身のノードのみ変更できる
+	if !auth.AuthorizeNode(ctx, request.ID) {
+		return
+	}
+
 	err = h.validate(&request)
 	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
@@ -76,6 +83,9 @@ func (h *NodeHandler) Create(ctx *gin.Context) {
 	}
 
 	key := getKey(request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var node system.Node
 	err = h.store.Get(key, &node)
 	if err == nil {
@@ -83,11 +93,13 @@ func (h *NodeHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
-
 	request.APIType = meta.APITypeNodeV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	request.ResourceVersion = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"node": request,
@@ -110,6 +122,10 @@ func (h *NodeHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if !auth.AuthorizeNode(ctx, nodeID) {
+		return
+	}
+
 	err = h.validate(&request)
 	if err != nil {
 		if err.Error() != "Error: id is duplicated." {
@@ -119,18 +135,34 @@ func (h *NodeHandler) Update(ctx *gin.Context) {
 		}
 	}
 
+	// 読んでから書き込むまでの間に他の更新が入らないようにする
 	key := getKey(nodeID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var node system.Node
-	err = h.store.Get(key, &node)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Node `%s` is not found.", request.Name), nil)
+	if err := h.store.Get(key, &node); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: Node `%s` is not found.", nodeID), nil)
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// node agent, nodelifecycle agent, humcliが同じnodeを更新するので、古いnodeでの上書きを拒否する
+	if request.ResourceVersion != 0 && request.ResourceVersion != node.ResourceVersion {
+		meta.ResponseJSON(ctx, http.StatusConflict, meta.NewConflict(
+			"Error: Node `%s` has been modified, resourceVersion is %d but got %d.",
+			nodeID, node.ResourceVersion, request.ResourceVersion), nil)
+		return
+	}
 
-	h.store.Put(key, request)
+	// uid, generation, resourceVersion, deletionTimestampはサーバー側で管理する
+	request.UID = node.UID
+	request.Generation = meta.NextGeneration(node.Generation, node.Spec, request.Spec)
+	request.ResourceVersion = node.ResourceVersion + 1
+	request.DeletionTimestamp = node.DeletionTimestamp
+	request.KeepFinalizers(node.Finalizers)
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"node": request,
@@ -140,11 +172,42 @@ func (h *NodeHandler) Update(ctx *gin.Context) {
 func (h *NodeHandler) Delete(ctx *gin.Context) {
 	nodeID := getNodeID(ctx)
 
+	if !auth.AuthorizeNode(ctx, nodeID) {
+		return
+	}
+
 	key := getKey(nodeID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var node system.Node
+	if err := h.store.Get(key, &node); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Node `%s` is not found.", nodeID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		node.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(node.Finalizers) != 0 {
+		node.MarkDeletion()
+		node.ResourceVersion++
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, node)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"node": node,
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"node": nil,
@@ -21,6 +21,10 @@ const (
 	basePath = "groups/:group_id/namespaces/:namespace_id/nodenetworks"
 )
 
+// AnnotationNodeName はNodeNetworkを作成するノードのannotation
+// ノードの証明書ではこのノードのリソースのみ変更できる
+const AnnotationNodeName = "nodenetworkv0/node_name"
+
 func NewNodeNetworkHandler(router *gin.RouterGroup, nhi NodeNetworkHandlerInterface) *NodeNetworkHandler {
 	return &NodeNetworkHandler{
 		router: router,
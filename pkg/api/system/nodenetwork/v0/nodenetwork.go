@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/auth"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
@@ -98,7 +100,11 @@ func (h *NodeNetworkHandler) Create(ctx *gin.Context) {
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeNodeNetworkV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"nodenetwork": request,
@@ -127,11 +133,21 @@ func (h *NodeNetworkHandler) Update(ctx *gin.Context) {
 		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: NodeNetwork `%s` is not found in Namespace `%s`.", netID, nsID), nil)
 		return
 	}
+	if !auth.AuthorizeNodeObject(ctx, "NodeNetwork", netID, net.Annotations[nodenetwork.AnnotationNodeName], request.Annotations[nodenetwork.AnnotationNodeName]) {
+		return
+	}
 
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.UID = net.UID
+	request.Generation = meta.NextGeneration(net.Generation, net.Spec, request.Spec)
+	request.DeletionTimestamp = net.DeletionTimestamp
+	request.KeepFinalizers(net.Finalizers)
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"nodenetwork": request,
@@ -145,7 +161,34 @@ func (h *NodeNetworkHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var net system.NodeNetwork
+	if err := h.store.Get(key, &net); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("NodeNetwork `%s` is not found.", netID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		net.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(net.Finalizers) != 0 {
+		net.MarkDeletion()
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, net)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"nodenetwork": net,
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
+
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"nodenetwork": nil,
 	})
@@ -1,6 +1,8 @@
 package system
 
 import (
+	"time"
+
 	"github.com/ophum/humstack/pkg/api/meta"
 )
 
@@ -22,15 +24,17 @@ const (
 	NetworkStateDeleting  NodeNetworkState = "Deleting"
 )
 
-type NodeNetworkStatusLog struct {
-	NodeID   string `json:"nodeID" yaml:"nodeID"`
-	Datetime string `json:"datetime" yaml:"datetime"`
-	Log      string `json:"log" yaml:"log"`
-}
+const (
+	// ノード上にインターフェースを作成できたか
+	NodeNetworkConditionReady meta.ConditionType = "Ready"
+)
+
+// ログはstatusではなく、agentがイベントとして記録する
+// 以前のlogsは読み込まれず、nodenetwork agentが次に更新した時に消える
 type NodeNetworkStatus struct {
 	State              NodeNetworkState             `json:"state" yaml:"state"`
 	AttachedInterfaces map[string]VirtualMachineNIC `json:"attachedInterfaces" yaml:"attachedInterfaces"`
-	Logs               []NodeNetworkStatusLog       `json:"logs" yaml:"logs"`
+	Conditions         []meta.Condition             `json:"conditions" yaml:"conditions"`
 }
 
 type NodeNetwork struct {
@@ -58,8 +62,14 @@ const (
 	ImageEntityStateDeleting  ImageEntityState = "Deleting"
 )
 
+const (
+	// イメージファイルを作成できたか
+	ImageEntityConditionImageReady meta.ConditionType = "ImageReady"
+)
+
 type ImageEntityStatus struct {
-	State ImageEntityState `json:"state" yaml:"state"`
+	State      ImageEntityState `json:"state" yaml:"state"`
+	Conditions []meta.Condition `json:"conditions" yaml:"conditions"`
 }
 type ImageEntity struct {
 	meta.Meta `json:"meta" yaml:"meta"`
@@ -69,6 +79,8 @@ type ImageEntity struct {
 }
 
 type ImageSpec struct {
+	// Deprecated: ImageTagを使う
+	// ImageTagが見つからない場合のみ参照する
 	EntityMap map[string]string `json:"entityMap" yaml:"entityMap"`
 }
 
@@ -78,6 +90,36 @@ type Image struct {
 	Spec ImageSpec `json:"spec" yaml:"spec"`
 }
 
+type ImageTagSpec struct {
+	ImageName     string `json:"imageName" yaml:"imageName"`
+	Tag           string `json:"tag" yaml:"tag"`
+	ImageEntityID string `json:"imageEntityID" yaml:"imageEntityID"`
+
+	// trueの場合はimageEntityIDを変更できない
+	// 一度trueにすると戻せない
+	Immutable bool `json:"immutable" yaml:"immutable"`
+}
+
+// ImageTagHistory はtagが指していたimageEntity
+type ImageTagHistory struct {
+	ImageEntityID string    `json:"imageEntityID" yaml:"imageEntityID"`
+	TaggedAt      time.Time `json:"taggedAt" yaml:"taggedAt"`
+}
+
+type ImageTagStatus struct {
+	// 古い順に並ぶ。最後の要素が現在のimageEntity
+	History []ImageTagHistory `json:"history" yaml:"history"`
+}
+
+// ImageTag はimageのtagとimageEntityを対応付ける
+// IDは `<imageName>:<tag>` とする
+type ImageTag struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec   ImageTagSpec   `json:"spec" yaml:"spec"`
+	Status ImageTagStatus `json:"status" yaml:"status"`
+}
+
 type BlockStorageFromBaseImage struct {
 	ImageName string `json:"imageName" yaml:"imageName"`
 	Tag       string `json:"tag" yaml:"tag"`
@@ -126,8 +168,16 @@ const (
 	BlockStorageStateError       BlockStorageState = "Error"
 )
 
+const (
+	// ディスクの作成、コピー、ダウンロードが完了したか
+	BlockStorageConditionProvisioned meta.ConditionType = "Provisioned"
+	// 配置されているノードのagentが動いているか
+	BlockStorageConditionNodeReady meta.ConditionType = "NodeReady"
+)
+
 type BlockStorageStatus struct {
-	State BlockStorageState `json:"state" yaml:"state"`
+	State      BlockStorageState `json:"state" yaml:"state"`
+	Conditions []meta.Condition  `json:"conditions" yaml:"conditions"`
 }
 type BlockStorage struct {
 	meta.Meta `json:"meta" yaml:"meta"`
@@ -157,6 +207,36 @@ const (
 	VirtualMachineActionStatePowerOff VirtualMachineActionState = "PowerOff"
 )
 
+// VirtualMachineAffinityScope はaffinityの対象になるVMの範囲
+type VirtualMachineAffinityScope string
+
+const (
+	// 同じnamespaceのVMのみ
+	VirtualMachineAffinityScopeNamespace VirtualMachineAffinityScope = "Namespace"
+	// 同じgroupの全てのnamespaceのVM
+	VirtualMachineAffinityScopeGroup VirtualMachineAffinityScope = "Group"
+)
+
+type VirtualMachineAffinityTerm struct {
+	LabelSelector meta.LabelSelector `json:"labelSelector" yaml:"labelSelector"`
+	// 省略時はNamespace
+	Scope VirtualMachineAffinityScope `json:"scope" yaml:"scope"`
+}
+
+type WeightedVirtualMachineAffinityTerm struct {
+	// 1から100。大きいほど優先する
+	Weight int32 `json:"weight" yaml:"weight"`
+
+	VirtualMachineAffinityTerm `json:",inline" yaml:",inline"`
+}
+
+type VirtualMachineAffinityRules struct {
+	// 全てを満たすノードにのみ配置する
+	Required []VirtualMachineAffinityTerm `json:"required" yaml:"required"`
+	// 満たすノードを優先する
+	Preferred []WeightedVirtualMachineAffinityTerm `json:"preferred" yaml:"preferred"`
+}
+
 type VirtualMachineSpec struct {
 	UUID string `json:"uuid" yaml:"uuid"`
 
@@ -175,6 +255,13 @@ type VirtualMachineSpec struct {
 	LoginUsers []*VirtualMachineLoginUser `json:"loginUsers" yaml:"loginUsers"`
 
 	ActionState VirtualMachineActionState `json:"actionState" yaml:"actionState"`
+
+	// labelsが全て一致するノードにのみ配置する
+	NodeSelector map[string]string `json:"nodeSelector" yaml:"nodeSelector"`
+	// labelSelectorに一致するVMと同じノードに配置する
+	Affinity VirtualMachineAffinityRules `json:"affinity" yaml:"affinity"`
+	// labelSelectorに一致するVMと別のノードに配置する
+	AntiAffinity VirtualMachineAffinityRules `json:"antiAffinity" yaml:"antiAffinity"`
 }
 
 type VirtualMachineState string
@@ -186,8 +273,22 @@ const (
 	VirtualMachineStateStopped  VirtualMachineState = "Stopped"
 )
 
+const (
+	// schedulerがノードを割り当てたか
+	VirtualMachineConditionScheduled meta.ConditionType = "Scheduled"
+	// 全てのBlockStorageがActiveになっているか
+	VirtualMachineConditionStorageReady meta.ConditionType = "StorageReady"
+	// 全てのNICのネットワークがノード上に作成されているか
+	VirtualMachineConditionNetworkReady meta.ConditionType = "NetworkReady"
+	// qemuプロセスが起動しているか
+	VirtualMachineConditionBooted meta.ConditionType = "Booted"
+	// 割り当てられたノードのagentが動いているか
+	VirtualMachineConditionNodeReady meta.ConditionType = "NodeReady"
+)
+
 type VirtualMachineStatus struct {
-	State VirtualMachineState `json:"state" yaml:"state"`
+	State      VirtualMachineState `json:"state" yaml:"state"`
+	Conditions []meta.Condition    `json:"conditions" yaml:"conditions"`
 }
 type VirtualMachine struct {
 	meta.Meta `json:"meta" yaml:"meta"`
@@ -197,9 +298,14 @@ type VirtualMachine struct {
 }
 
 type NodeSpec struct {
-	Address     string `json:"address" yaml:"address"`
+	Address string `json:"address" yaml:"address"`
+	// Deprecated: node agentがstatus.allocatableを設定する
+	// allocatableを設定しない古いagentのノードではschedulerが上限として使う
 	LimitVcpus  string `json:"limitVcpus" yaml:"limitVcpus"`
 	LimitMemory string `json:"limitMemory" yaml:"limitMemory"`
+	LimitDisk   string `json:"limitDisk" yaml:"limitDisk"`
+	// trueの場合、schedulerは新しいVMを割り当てない(cordon)
+	Unschedulable bool `json:"unschedulable" yaml:"unschedulable"`
 }
 
 type NodeState string
@@ -209,11 +315,55 @@ const (
 	NodeStateReady    NodeState = "Ready"
 )
 
+const (
+	NodeConditionReady meta.ConditionType = "Ready"
+)
+
+// NodeHugePages はページサイズごとのhugepagesの数
+type NodeHugePages struct {
+	// e.g. 2048K, 1G
+	PageSize string `json:"pageSize" yaml:"pageSize"`
+	Total    int64  `json:"total" yaml:"total"`
+	Free     int64  `json:"free" yaml:"free"`
+}
+
+// NodeDisk はディレクトリがあるファイルシステムの容量
+type NodeDisk struct {
+	Path  string `json:"path" yaml:"path"`
+	Total string `json:"total" yaml:"total"`
+	Free  string `json:"free" yaml:"free"`
+}
+
+// NodeCapacity はnode agentが検出したノードのリソース
+type NodeCapacity struct {
+	Vcpus     string          `json:"vcpus" yaml:"vcpus"`
+	Memory    string          `json:"memory" yaml:"memory"`
+	HugePages []NodeHugePages `json:"hugePages" yaml:"hugePages"`
+	// /dev/kvmがあるか
+	KVM      bool     `json:"kvm" yaml:"kvm"`
+	CPUModel string   `json:"cpuModel" yaml:"cpuModel"`
+	CPUFlags []string `json:"cpuFlags" yaml:"cpuFlags"`
+	// blockStorageDirPath, imageDirPathのファイルシステム
+	BlockStorageDisk NodeDisk `json:"blockStorageDisk" yaml:"blockStorageDisk"`
+	ImageDisk        NodeDisk `json:"imageDisk" yaml:"imageDisk"`
+}
+
+// NodeAllocatable はcapacityから予約分を引き、overcommitの倍率をかけたもの
+// schedulerがVMとLocalのblockstorageを割り当てる上限
+type NodeAllocatable struct {
+	Vcpus  string `json:"vcpus" yaml:"vcpus"`
+	Memory string `json:"memory" yaml:"memory"`
+	Disk   string `json:"disk" yaml:"disk"`
+}
+
 type NodeStatus struct {
-	State           NodeState `json:"state" yaml:"state"`
-	RequestedVcpus  string    `json:"requestedVcpus" yaml:"requestedVcpus"`
-	RequestedMemory string    `json:"requestedMemory" yaml:"requestedMemory"`
-	RequestedDisk   string    `json:"requestedDisk" yaml:"requestedDisk"`
+	State           NodeState        `json:"state" yaml:"state"`
+	Capacity        NodeCapacity     `json:"capacity" yaml:"capacity"`
+	Allocatable     NodeAllocatable  `json:"allocatable" yaml:"allocatable"`
+	RequestedVcpus  string           `json:"requestedVcpus" yaml:"requestedVcpus"`
+	RequestedMemory string           `json:"requestedMemory" yaml:"requestedMemory"`
+	RequestedDisk   string           `json:"requestedDisk" yaml:"requestedDisk"`
+	Conditions      []meta.Condition `json:"conditions" yaml:"conditions"`
 }
 
 type Node struct {
@@ -270,8 +420,16 @@ const (
 	VirtualRouterStateRunning VirtualRouterState = "Running"
 )
 
+const (
+	// netnsとインターフェースを作成できたか
+	VirtualRouterConditionReady meta.ConditionType = "Ready"
+	// 割り当てられたノードのagentが動いているか
+	VirtualRouterConditionNodeReady meta.ConditionType = "NodeReady"
+)
+
 type VirtualRouterStatus struct {
-	State VirtualRouterState `json:"state" yaml:"state"`
+	State      VirtualRouterState `json:"state" yaml:"state"`
+	Conditions []meta.Condition   `json:"conditions" yaml:"conditions"`
 }
 
 type VirtualRouter struct {
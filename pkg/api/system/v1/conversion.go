@@ -0,0 +1,296 @@
+package v1
+
+import (
+	"strconv"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+// v0でフィールドの代わりに使われているannotation
+const (
+	annotationBlockStorageType         = "blockstoragev0/type"
+	annotationBlockStorageNodeName     = "blockstoragev0/node_name"
+	annotationBlockStorageDownloadHost = "bs-download-host"
+	annotationBlockStorageDownloadSch  = "bs-download-scheme"
+
+	annotationCephPoolName  = "ceph-pool-name"
+	annotationCephImageName = "ceph-image-name"
+
+	annotationImageEntityType         = "imageentityv0/type"
+	annotationImageEntityNodeName     = "imageentityv0/node_name"
+	annotationImageEntityDownloadHost = "image-entity-download-host"
+	annotationImageEntityDownloadSch  = "image-entity-download-scheme"
+
+	annotationVirtualMachineNodeName   = "virtualmachinev0/node_name"
+	annotationVirtualMachineArch       = "virtualmachinev0/arch"
+	annotationVirtualMachinePID        = "virtualmachinev0/pid"
+	annotationVirtualMachineVNCDisplay = "virtualmachinev0/vnc_display_number"
+	annotationVirtualMachineVNCHost    = "virtualmachinev0/vnc_websocket_host"
+	annotationVirtualMachineVNCScheme  = "virtualmachinev0/vnc_websocket_scheme"
+	annotationVirtualRouterNodeName    = "virtualrouterv0/node_name"
+)
+
+// annotations はv0のannotationとフィールドの相互変換に使う
+type annotations map[string]string
+
+func copyAnnotations(src map[string]string) annotations {
+	if src == nil {
+		return nil
+	}
+	dst := annotations{}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// pop はkeyの値を取り出してannotationからは取り除く
+// 空の値はv1のフィールドでは省略と区別できないので、v0に戻した時に失われないようにannotationに残す
+func (a annotations) pop(key string) (string, bool) {
+	v, ok := a[key]
+	if !ok || v == "" {
+		return "", false
+	}
+	delete(a, key)
+	return v, true
+}
+
+// set は空でない値のみannotationに入れる
+func (a *annotations) set(key, value string) {
+	if value == "" {
+		return
+	}
+	if *a == nil {
+		*a = annotations{}
+	}
+	(*a)[key] = value
+}
+
+// convertMeta はapiTypeを書き換え、変換元と共有しないannotationを返す
+func convertMeta(m meta.Meta, apiType meta.APIType) (meta.Meta, annotations) {
+	a := copyAnnotations(m.Annotations)
+	m.APIType = apiType
+	return m, a
+}
+
+func popDownloadEndpoint(a annotations, hostKey, schemeKey string) *DownloadEndpoint {
+	host, hostOk := a.pop(hostKey)
+	scheme, schemeOk := a.pop(schemeKey)
+	if !hostOk && !schemeOk {
+		return nil
+	}
+	return &DownloadEndpoint{
+		Host:   host,
+		Scheme: scheme,
+	}
+}
+
+func popCephBackend(a annotations) *CephBackend {
+	pool, poolOk := a.pop(annotationCephPoolName)
+	image, imageOk := a.pop(annotationCephImageName)
+	if !poolOk && !imageOk {
+		return nil
+	}
+	return &CephBackend{
+		PoolName:  pool,
+		ImageName: image,
+	}
+}
+
+func ConvertBlockStorageFromV0(in *system.BlockStorage) *BlockStorage {
+	m, a := convertMeta(in.Meta, meta.APITypeBlockStorageV1)
+
+	out := &BlockStorage{
+		Spec: BlockStorageSpec{
+			BlockStorageSpec: in.Spec,
+		},
+		Status: BlockStorageStatus{
+			BlockStorageStatus: in.Status,
+		},
+	}
+
+	backendType, _ := a.pop(annotationBlockStorageType)
+	out.Spec.Backend.Type = StorageBackendType(backendType)
+	out.Spec.Backend.Ceph = popCephBackend(a)
+	out.Spec.Placement.NodeName, _ = a.pop(annotationBlockStorageNodeName)
+	out.Status.Download = popDownloadEndpoint(a, annotationBlockStorageDownloadHost, annotationBlockStorageDownloadSch)
+
+	m.Annotations = a
+	out.Meta = m
+	return out
+}
+
+func ConvertBlockStorageToV0(in *BlockStorage) *system.BlockStorage {
+	m, a := convertMeta(in.Meta, meta.APITypeBlockStorageV0)
+
+	a.set(annotationBlockStorageType, string(in.Spec.Backend.Type))
+	if in.Spec.Backend.Ceph != nil {
+		a.set(annotationCephPoolName, in.Spec.Backend.Ceph.PoolName)
+		a.set(annotationCephImageName, in.Spec.Backend.Ceph.ImageName)
+	}
+	a.set(annotationBlockStorageNodeName, in.Spec.Placement.NodeName)
+	if in.Status.Download != nil {
+		a.set(annotationBlockStorageDownloadHost, in.Status.Download.Host)
+		a.set(annotationBlockStorageDownloadSch, in.Status.Download.Scheme)
+	}
+
+	m.Annotations = a
+	return &system.BlockStorage{
+		Meta:   m,
+		Spec:   in.Spec.BlockStorageSpec,
+		Status: in.Status.BlockStorageStatus,
+	}
+}
+
+// SetBlockStorageStatusV0 はv1のstatusをv0に変換したsrcのstatusをdstに設定する
+// statusの代わりに使われているannotationもsrcに合わせる
+func SetBlockStorageStatusV0(dst, src *system.BlockStorage) {
+	dst.Status = src.Status
+	for _, key := range []string{annotationBlockStorageDownloadHost, annotationBlockStorageDownloadSch} {
+		if v, ok := src.Annotations[key]; ok {
+			if dst.Annotations == nil {
+				dst.Annotations = map[string]string{}
+			}
+			dst.Annotations[key] = v
+		} else {
+			delete(dst.Annotations, key)
+		}
+	}
+}
+
+func ConvertImageEntityFromV0(in *system.ImageEntity) *ImageEntity {
+	m, a := convertMeta(in.Meta, meta.APITypeImageEntityV1)
+
+	out := &ImageEntity{
+		Spec: ImageEntitySpec{
+			ImageEntitySpec: in.Spec,
+		},
+		Status: ImageEntityStatus{
+			ImageEntityStatus: in.Status,
+		},
+	}
+
+	backendType, _ := a.pop(annotationImageEntityType)
+	out.Spec.Backend.Type = StorageBackendType(backendType)
+	out.Spec.Backend.Ceph = popCephBackend(a)
+	out.Status.NodeName, _ = a.pop(annotationImageEntityNodeName)
+	out.Status.Download = popDownloadEndpoint(a, annotationImageEntityDownloadHost, annotationImageEntityDownloadSch)
+
+	m.Annotations = a
+	out.Meta = m
+	return out
+}
+
+func ConvertImageEntityToV0(in *ImageEntity) *system.ImageEntity {
+	m, a := convertMeta(in.Meta, meta.APITypeImageEntityV0)
+
+	a.set(annotationImageEntityType, string(in.Spec.Backend.Type))
+	if in.Spec.Backend.Ceph != nil {
+		a.set(annotationCephPoolName, in.Spec.Backend.Ceph.PoolName)
+		a.set(annotationCephImageName, in.Spec.Backend.Ceph.ImageName)
+	}
+	a.set(annotationImageEntityNodeName, in.Status.NodeName)
+	if in.Status.Download != nil {
+		a.set(annotationImageEntityDownloadHost, in.Status.Download.Host)
+		a.set(annotationImageEntityDownloadSch, in.Status.Download.Scheme)
+	}
+
+	m.Annotations = a
+	return &system.ImageEntity{
+		Meta:   m,
+		Spec:   in.Spec.ImageEntitySpec,
+		Status: in.Status.ImageEntityStatus,
+	}
+}
+
+func ConvertVirtualMachineFromV0(in *system.VirtualMachine) *VirtualMachine {
+	m, a := convertMeta(in.Meta, meta.APITypeVirtualMachineV1)
+
+	out := &VirtualMachine{
+		Spec: VirtualMachineSpec{
+			VirtualMachineSpec: in.Spec,
+		},
+		Status: VirtualMachineStatus{
+			VirtualMachineStatus: in.Status,
+		},
+	}
+
+	out.Spec.Arch, _ = a.pop(annotationVirtualMachineArch)
+	out.Spec.Placement.NodeName, _ = a.pop(annotationVirtualMachineNodeName)
+
+	// 数値として解釈できない場合はannotationのまま残す
+	if pid, err := strconv.ParseInt(a[annotationVirtualMachinePID], 10, 64); err == nil {
+		out.Status.PID = pid
+		delete(a, annotationVirtualMachinePID)
+	}
+
+	host, hostOk := a.pop(annotationVirtualMachineVNCHost)
+	scheme, schemeOk := a.pop(annotationVirtualMachineVNCScheme)
+	display, displayErr := strconv.ParseInt(a[annotationVirtualMachineVNCDisplay], 10, 32)
+	if displayErr == nil {
+		delete(a, annotationVirtualMachineVNCDisplay)
+	}
+	if hostOk || schemeOk || displayErr == nil {
+		out.Status.Console = &VirtualMachineConsole{
+			DisplayNumber:   int32(display),
+			WebSocketHost:   host,
+			WebSocketScheme: scheme,
+		}
+	}
+
+	m.Annotations = a
+	out.Meta = m
+	return out
+}
+
+func ConvertVirtualMachineToV0(in *VirtualMachine) *system.VirtualMachine {
+	m, a := convertMeta(in.Meta, meta.APITypeVirtualMachineV0)
+
+	a.set(annotationVirtualMachineArch, in.Spec.Arch)
+	a.set(annotationVirtualMachineNodeName, in.Spec.Placement.NodeName)
+	if in.Status.PID != 0 {
+		a.set(annotationVirtualMachinePID, strconv.FormatInt(in.Status.PID, 10))
+	}
+	if in.Status.Console != nil {
+		a.set(annotationVirtualMachineVNCDisplay, strconv.FormatInt(int64(in.Status.Console.DisplayNumber), 10))
+		a.set(annotationVirtualMachineVNCHost, in.Status.Console.WebSocketHost)
+		a.set(annotationVirtualMachineVNCScheme, in.Status.Console.WebSocketScheme)
+	}
+
+	m.Annotations = a
+	return &system.VirtualMachine{
+		Meta:   m,
+		Spec:   in.Spec.VirtualMachineSpec,
+		Status: in.Status.VirtualMachineStatus,
+	}
+}
+
+func ConvertVirtualRouterFromV0(in *system.VirtualRouter) *VirtualRouter {
+	m, a := convertMeta(in.Meta, meta.APITypeVirtualRouterV1)
+
+	out := &VirtualRouter{
+		Spec: VirtualRouterSpec{
+			VirtualRouterSpec: in.Spec,
+		},
+		Status: in.Status,
+	}
+	out.Spec.Placement.NodeName, _ = a.pop(annotationVirtualRouterNodeName)
+
+	m.Annotations = a
+	out.Meta = m
+	return out
+}
+
+func ConvertVirtualRouterToV0(in *VirtualRouter) *system.VirtualRouter {
+	m, a := convertMeta(in.Meta, meta.APITypeVirtualRouterV0)
+
+	a.set(annotationVirtualRouterNodeName, in.Spec.Placement.NodeName)
+
+	m.Annotations = a
+	return &system.VirtualRouter{
+		Meta:   m,
+		Spec:   in.Spec.VirtualRouterSpec,
+		Status: in.Status,
+	}
+}
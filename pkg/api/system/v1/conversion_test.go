@@ -0,0 +1,156 @@
+package v1
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+func TestConvertVirtualMachine(t *testing.T) {
+	v0 := &system.VirtualMachine{
+		Meta: meta.Meta{
+			ID:      "vm1",
+			APIType: meta.APITypeVirtualMachineV0,
+			Annotations: map[string]string{
+				"virtualmachinev0/node_name":            "node1",
+				"virtualmachinev0/arch":                 "aarch64",
+				"virtualmachinev0/pid":                  "1234",
+				"virtualmachinev0/vnc_display_number":   "3",
+				"virtualmachinev0/vnc_websocket_host":   "192.168.0.1:6903",
+				"virtualmachinev0/vnc_websocket_scheme": "wss",
+				"virtualmachinev0/ignore":               "true",
+			},
+		},
+		Spec: system.VirtualMachineSpec{
+			RequestVcpus: "1000m",
+		},
+		Status: system.VirtualMachineStatus{
+			State: system.VirtualMachineStateRunning,
+		},
+	}
+
+	v1 := ConvertVirtualMachineFromV0(v0)
+	if v1.APIType != meta.APITypeVirtualMachineV1 {
+		t.Fatalf("unexpected apiType: %s", v1.APIType)
+	}
+	if v1.Spec.Placement.NodeName != "node1" || v1.Spec.Arch != "aarch64" || v1.Status.PID != 1234 {
+		t.Fatalf("unexpected v1: %+v", v1)
+	}
+	if v1.Status.Console == nil || v1.Status.Console.DisplayNumber != 3 || v1.Status.Console.WebSocketScheme != "wss" {
+		t.Fatalf("unexpected console: %+v", v1.Status.Console)
+	}
+	// フィールドに移していないannotationは残る
+	if !reflect.DeepEqual(v1.Annotations, map[string]string{"virtualmachinev0/ignore": "true"}) {
+		t.Fatalf("unexpected annotations: %v", v1.Annotations)
+	}
+
+	// 変換元のannotationは変更しない
+	if len(v0.Annotations) != 7 {
+		t.Fatalf("source annotations changed: %v", v0.Annotations)
+	}
+
+	if got := ConvertVirtualMachineToV0(v1); !reflect.DeepEqual(got, v0) {
+		t.Fatalf("round trip mismatch:\n%+v\n%+v", got, v0)
+	}
+}
+
+func TestConvertBlockStorage(t *testing.T) {
+	v0 := &system.BlockStorage{
+		Meta: meta.Meta{
+			ID:      "bs1",
+			APIType: meta.APITypeBlockStorageV0,
+			Annotations: map[string]string{
+				"blockstoragev0/type":      "Ceph",
+				"blockstoragev0/node_name": "node1",
+				"ceph-pool-name":           "pool",
+				"ceph-image-name":          "image",
+			},
+		},
+		Spec: system.BlockStorageSpec{
+			RequestSize: "1G",
+			LimitSize:   "10G",
+		},
+	}
+
+	v1 := ConvertBlockStorageFromV0(v0)
+	if v1.Spec.Backend.Type != StorageBackendTypeCeph ||
+		v1.Spec.Backend.Ceph == nil ||
+		v1.Spec.Backend.Ceph.PoolName != "pool" ||
+		v1.Spec.Placement.NodeName != "node1" {
+		t.Fatalf("unexpected v1: %+v", v1)
+	}
+	if v1.Status.Download != nil {
+		t.Fatalf("unexpected download: %+v", v1.Status.Download)
+	}
+
+	if got := ConvertBlockStorageToV0(v1); !reflect.DeepEqual(got, v0) {
+		t.Fatalf("round trip mismatch:\n%+v\n%+v", got, v0)
+	}
+}
+
+func TestSetBlockStorageStatusV0(t *testing.T) {
+	dst := &system.BlockStorage{
+		Meta: meta.Meta{
+			ID: "bs1",
+			Annotations: map[string]string{
+				"blockstoragev0/node_name": "node1",
+				"bs-download-scheme":       "https",
+			},
+		},
+	}
+
+	src := ConvertBlockStorageToV0(&BlockStorage{
+		Meta: meta.Meta{ID: "bs1"},
+		Status: BlockStorageStatus{
+			BlockStorageStatus: system.BlockStorageStatus{State: system.BlockStorageStateActive},
+			Download:           &DownloadEndpoint{Host: "192.168.0.1:8082"},
+		},
+	})
+	SetBlockStorageStatusV0(dst, src)
+
+	// specの代わりのannotationは変更しない
+	expected := map[string]string{
+		"blockstoragev0/node_name": "node1",
+		"bs-download-host":         "192.168.0.1:8082",
+	}
+	if dst.Status.State != system.BlockStorageStateActive || !reflect.DeepEqual(dst.Annotations, expected) {
+		t.Fatalf("unexpected blockstorage: %+v", dst)
+	}
+}
+
+func TestConvertKeepsEmptyAnnotations(t *testing.T) {
+	vm := &system.VirtualMachine{
+		Meta: meta.Meta{
+			ID:      "vm1",
+			APIType: meta.APITypeVirtualMachineV0,
+			Annotations: map[string]string{
+				"virtualmachinev0/node_name":          "",
+				"virtualmachinev0/vnc_websocket_host": "",
+				"virtualmachinev0/ignore":             "",
+			},
+		},
+	}
+	v1 := ConvertVirtualMachineFromV0(vm)
+	if v1.Status.Console != nil {
+		t.Fatalf("unexpected console: %+v", v1.Status.Console)
+	}
+	if got := ConvertVirtualMachineToV0(v1); !reflect.DeepEqual(got, vm) {
+		t.Fatalf("round trip mismatch:\n%+v\n%+v", got, vm)
+	}
+
+	bs := &system.BlockStorage{
+		Meta: meta.Meta{
+			ID:      "bs1",
+			APIType: meta.APITypeBlockStorageV0,
+			Annotations: map[string]string{
+				"blockstoragev0/type":      "Ceph",
+				"blockstoragev0/node_name": "",
+			},
+		},
+	}
+	if got := ConvertBlockStorageToV0(ConvertBlockStorageFromV0(bs)); !reflect.DeepEqual(got, bs) {
+		t.Fatalf("round trip mismatch:\n%+v\n%+v", got, bs)
+	}
+}
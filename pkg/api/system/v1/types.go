@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+// v0でannotationに入れていた情報をフィールドとして持つ
+// 変更のない部分はv0の型をそのまま使う
+
+// Placement はリソースを配置するノード
+type Placement struct {
+	NodeName string `json:"nodeName" yaml:"nodeName"`
+}
+
+// DownloadEndpoint はagentのダウンロードAPIの場所
+type DownloadEndpoint struct {
+	Host   string `json:"host" yaml:"host"`
+	Scheme string `json:"scheme" yaml:"scheme"`
+}
+
+type StorageBackendType string
+
+const (
+	StorageBackendTypeLocal StorageBackendType = "Local"
+	StorageBackendTypeCeph  StorageBackendType = "Ceph"
+)
+
+type CephBackend struct {
+	PoolName  string `json:"poolName" yaml:"poolName"`
+	ImageName string `json:"imageName" yaml:"imageName"`
+}
+
+type StorageBackend struct {
+	Type StorageBackendType `json:"type" yaml:"type"`
+	// TypeがCephの場合にagentが設定する
+	Ceph *CephBackend `json:"ceph,omitempty" yaml:"ceph,omitempty"`
+}
+
+type BlockStorageSpec struct {
+	system.BlockStorageSpec `json:",inline" yaml:",inline"`
+
+	Backend   StorageBackend `json:"backend" yaml:"backend"`
+	Placement Placement      `json:"placement" yaml:"placement"`
+}
+
+type BlockStorageStatus struct {
+	system.BlockStorageStatus `json:",inline" yaml:",inline"`
+
+	Download *DownloadEndpoint `json:"download,omitempty" yaml:"download,omitempty"`
+}
+
+type BlockStorage struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec   BlockStorageSpec   `json:"spec" yaml:"spec"`
+	Status BlockStorageStatus `json:"status" yaml:"status"`
+}
+
+type ImageEntitySpec struct {
+	system.ImageEntitySpec `json:",inline" yaml:",inline"`
+
+	Backend StorageBackend `json:"backend" yaml:"backend"`
+}
+
+type ImageEntityStatus struct {
+	system.ImageEntityStatus `json:",inline" yaml:",inline"`
+
+	// イメージを作成したノード
+	NodeName string            `json:"nodeName" yaml:"nodeName"`
+	Download *DownloadEndpoint `json:"download,omitempty" yaml:"download,omitempty"`
+}
+
+type ImageEntity struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec   ImageEntitySpec   `json:"spec" yaml:"spec"`
+	Status ImageEntityStatus `json:"status" yaml:"status"`
+}
+
+type VirtualMachineSpec struct {
+	system.VirtualMachineSpec `json:",inline" yaml:",inline"`
+
+	Arch      string    `json:"arch" yaml:"arch"`
+	Placement Placement `json:"placement" yaml:"placement"`
+}
+
+type VirtualMachineConsole struct {
+	DisplayNumber   int32  `json:"displayNumber" yaml:"displayNumber"`
+	WebSocketHost   string `json:"webSocketHost" yaml:"webSocketHost"`
+	WebSocketScheme string `json:"webSocketScheme" yaml:"webSocketScheme"`
+}
+
+type VirtualMachineStatus struct {
+	system.VirtualMachineStatus `json:",inline" yaml:",inline"`
+
+	PID int64 `json:"pid" yaml:"pid"`
+	// 起動後にagentが設定する
+	Console *VirtualMachineConsole `json:"console,omitempty" yaml:"console,omitempty"`
+}
+
+type VirtualMachine struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec   VirtualMachineSpec   `json:"spec" yaml:"spec"`
+	Status VirtualMachineStatus `json:"status" yaml:"status"`
+}
+
+type VirtualRouterSpec struct {
+	system.VirtualRouterSpec `json:",inline" yaml:",inline"`
+
+	Placement Placement `json:"placement" yaml:"placement"`
+}
+
+type VirtualRouter struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec   VirtualRouterSpec          `json:"spec" yaml:"spec"`
+	Status system.VirtualRouterStatus `json:"status" yaml:"status"`
+}
@@ -0,0 +1,54 @@
+package virtualmachine
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+// AuthorizeNode はノードの証明書では、そのノードに割り当てられたVMのみ変更できるようにする
+// 移動元のノードはqemuの停止を確認した後にprevious_node_nameから自身を外すので、その変更だけは許可する
+func AuthorizeNode(ctx *gin.Context, stored, request *system.VirtualMachine) bool {
+	if identity, ok := auth.GetIdentity(ctx); ok && identity.NodeName != "" && isFenced(stored, request, identity.NodeName) {
+		return true
+	}
+	return auth.AuthorizeNodeObject(ctx, "VirtualMachine", stored.ID, stored.Annotations[AnnotationNodeName], request.Annotations[AnnotationNodeName])
+}
+
+// isFenced はrequestがstoredのprevious_node_nameからnodeNameを外しただけの変更であればtrueを返す
+func isFenced(stored, request *system.VirtualMachine, nodeName string) bool {
+	previous := []string{}
+	found := false
+	for _, n := range strings.Split(stored.Annotations[AnnotationPreviousNodeName], ",") {
+		if n == nodeName {
+			found = true
+			continue
+		}
+		previous = append(previous, n)
+	}
+	if !found {
+		return false
+	}
+
+	want := map[string]string{}
+	for k, v := range stored.Annotations {
+		want[k] = v
+	}
+	delete(want, AnnotationPreviousNodeName)
+	if len(previous) != 0 {
+		want[AnnotationPreviousNodeName] = strings.Join(previous, ",")
+	}
+
+	got := map[string]string{}
+	for k, v := range request.Annotations {
+		got[k] = v
+	}
+
+	return reflect.DeepEqual(want, got) &&
+		reflect.DeepEqual(stored.Labels, request.Labels) &&
+		reflect.DeepEqual(stored.Spec, request.Spec) &&
+		reflect.DeepEqual(stored.Status, request.Status)
+}
@@ -18,6 +18,14 @@ const (
 	basePath = "groups/:group_id/namespaces/:namespace_id/virtualmachines"
 )
 
+const (
+	// AnnotationNodeName はVMを割り当てたノードのannotation
+	// ノードの証明書ではこのノードのリソースのみ変更できる
+	AnnotationNodeName = "virtualmachinev0/node_name"
+	// AnnotationPreviousNodeName は停止を確認していない移動元のノードのannotation
+	AnnotationPreviousNodeName = "virtualmachinev0/previous_node_name"
+)
+
 type VirtualMachineHandler struct {
 	router *gin.RouterGroup
 	vmhi   VirtualMachineHandlerInterface
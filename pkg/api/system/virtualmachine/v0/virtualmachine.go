@@ -1,6 +1,7 @@
 package v0
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,7 +9,10 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/koding/websocketproxy"
+	"github.com/ophum/humstack/pkg/api/conversion"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/api/system/virtualmachine"
@@ -19,14 +23,50 @@ type VirtualMachineHandler struct {
 	virtualmachine.VirtualMachineHandlerInterface
 
 	store store.Store
+	codec Codec
+
+	// agentのVNC websocketへプロキシする際のTLS設定
+	proxyTLSConfig *tls.Config
+}
+
+// Codec はリクエストとレスポンスのbodyをAPIのバージョンの形に変換する
+// storeにはv0の形で読み書きし、v1ではCodecだけを差し替える
+type Codec struct {
+	Decode func(ctx *gin.Context) (*system.VirtualMachine, error)
+	Encode func(vm *system.VirtualMachine) interface{}
+	// consoleのURLに使うAPIのバージョン
+	Version string
+}
+
+var codecV0 = Codec{
+	Decode: func(ctx *gin.Context) (*system.VirtualMachine, error) {
+		request := &system.VirtualMachine{}
+		err := ctx.Bind(request)
+		return request, err
+	},
+	Encode: func(vm *system.VirtualMachine) interface{} {
+		return vm
+	},
+	Version: "v0",
 }
 
 func NewVirtualMachineHandler(store store.Store) *VirtualMachineHandler {
+	return NewVirtualMachineHandlerWithCodec(store, codecV0)
+}
+
+// NewVirtualMachineHandlerWithCodec はv0以外のバージョンのハンドラを作る
+// storeはv0の形で読み書きするものを渡す
+func NewVirtualMachineHandlerWithCodec(store store.Store, codec Codec) *VirtualMachineHandler {
 	return &VirtualMachineHandler{
 		store: store,
+		codec: codec,
 	}
 }
 
+func (h *VirtualMachineHandler) SetProxyTLSConfig(config *tls.Config) {
+	h.proxyTLSConfig = config
+}
+
 func (h *VirtualMachineHandler) FindAll(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
@@ -42,8 +82,13 @@ func (h *VirtualMachineHandler) FindAll(ctx *gin.Context) {
 	}
 	h.store.List(getKey(groupID, nsID, ""), f)
 
+	res := make([]interface{}, 0, len(vmList))
+	for _, vm := range vmList {
+		res = append(res, h.codec.Encode(vm))
+	}
+
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"virtualmachines": vmList,
+		"virtualmachines": res,
 	})
 
 }
@@ -59,15 +104,14 @@ func (h *VirtualMachineHandler) Find(ctx *gin.Context) {
 	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"virtualmachine": vm,
+		"virtualmachine": h.codec.Encode(&vm),
 	})
 }
 
 func (h *VirtualMachineHandler) Create(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
-	var request system.VirtualMachine
-	err := ctx.Bind(&request)
+	request, err := h.codec.Decode(ctx)
 	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
@@ -78,6 +122,11 @@ func (h *VirtualMachineHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := virtualmachine.ValidatePlacement(&request.Spec); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	key := getKey(groupID, nsID, request.ID)
 	var vm system.VirtualMachine
 	err = h.store.Get(key, &vm)
@@ -90,18 +139,25 @@ func (h *VirtualMachineHandler) Create(ctx *gin.Context) {
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeVirtualMachineV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if err := conversion.Check(h.store, key, request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
-		"virtualmachine": request,
+		"virtualmachine": h.codec.Encode(request),
 	})
 }
 
 func (h *VirtualMachineHandler) Update(ctx *gin.Context) {
 	groupID, nsID, vmID := getIDs(ctx)
 
-	var request system.VirtualMachine
-	err := ctx.Bind(&request)
+	request, err := h.codec.Decode(ctx)
 	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
@@ -112,6 +168,11 @@ func (h *VirtualMachineHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := virtualmachine.ValidatePlacement(&request.Spec); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	key := getKey(groupID, nsID, request.ID)
 	var vm system.VirtualMachine
 	err = h.store.Get(key, &vm)
@@ -119,14 +180,28 @@ func (h *VirtualMachineHandler) Update(ctx *gin.Context) {
 		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualMachine `%s` is not found.", request.Name), nil)
 		return
 	}
+	if !virtualmachine.AuthorizeNode(ctx, &vm, request) {
+		return
+	}
 
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.UID = vm.UID
+	request.Generation = meta.NextGeneration(vm.Generation, vm.Spec, request.Spec)
+	request.DeletionTimestamp = vm.DeletionTimestamp
+	request.KeepFinalizers(vm.Finalizers)
+	if err := conversion.Check(h.store, key, request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
-		"virtualmachine": request,
+		"virtualmachine": h.codec.Encode(request),
 	})
 }
 
@@ -137,7 +212,37 @@ func (h *VirtualMachineHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var vm system.VirtualMachine
+	if err := h.store.Get(key, &vm); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("VirtualMachine `%s` is not found.", vmID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		vm.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(vm.Finalizers) != 0 {
+		vm.MarkDeletion()
+		if err := conversion.Check(h.store, key, vm); err != nil {
+			meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+			return
+		}
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, vm)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"virtualmachine": h.codec.Encode(&vm),
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"virtualmachine": nil,
@@ -146,7 +251,7 @@ func (h *VirtualMachineHandler) Delete(ctx *gin.Context) {
 
 func (h *VirtualMachineHandler) OpenConsole(ctx *gin.Context) {
 	groupID, nsID, vmID := getIDs(ctx)
-	ctx.Redirect(307, fmt.Sprintf("/static/vnc.html?path=api/v0/groups/%s/namespaces/%s/virtualmachines/%s/ws", groupID, nsID, vmID))
+	ctx.Redirect(307, fmt.Sprintf("/static/vnc.html?path=api/%s/groups/%s/namespaces/%s/virtualmachines/%s/ws", h.codec.Version, groupID, nsID, vmID))
 }
 
 func (h *VirtualMachineHandler) ConsoleWebSocketProxy(ctx *gin.Context) {
@@ -172,8 +277,13 @@ func (h *VirtualMachineHandler) ConsoleWebSocketProxy(ctx *gin.Context) {
 		return
 	}
 
+	scheme, ok := vm.Annotations["virtualmachinev0/vnc_websocket_scheme"]
+	if !ok {
+		scheme = "ws"
+	}
+
 	backendURL := &url.URL{
-		Scheme: "ws",
+		Scheme: scheme,
 		Host:   backendHost,
 		Path:   "/",
 	}
@@ -185,6 +295,11 @@ func (h *VirtualMachineHandler) ConsoleWebSocketProxy(ctx *gin.Context) {
 		Director: func(req *http.Request, out http.Header) {
 			out.Set("Host", req.Host)
 		},
+		Dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+			TLSClientConfig:  h.proxyTLSConfig,
+		},
 	}
 
 	ws.ServeHTTP(ctx.Writer, ctx.Request)
@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/system"
+	systemv1 "github.com/ophum/humstack/pkg/api/system/v1"
+	vmv0 "github.com/ophum/humstack/pkg/api/system/virtualmachine/v0"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// NewVirtualMachineHandler はv0のハンドラのbodyだけをv1の形にしたものを返す
+// storeはv0の形で読み書きするものを渡す
+func NewVirtualMachineHandler(store store.Store) *vmv0.VirtualMachineHandler {
+	return vmv0.NewVirtualMachineHandlerWithCodec(store, vmv0.Codec{
+		Decode: func(ctx *gin.Context) (*system.VirtualMachine, error) {
+			request := &systemv1.VirtualMachine{}
+			if err := ctx.Bind(request); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertVirtualMachineToV0(request), nil
+		},
+		Encode: func(vm *system.VirtualMachine) interface{} {
+			return systemv1.ConvertVirtualMachineFromV0(vm)
+		},
+		Version: "v1",
+	})
+}
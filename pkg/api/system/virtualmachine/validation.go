@@ -0,0 +1,55 @@
+package virtualmachine
+
+import (
+	"fmt"
+
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+// ValidatePlacement はnodeSelector, affinity, antiAffinityが正しいかを確認する
+// v0, v1のどちらのハンドラでも同じ内容を確認する
+func ValidatePlacement(spec *system.VirtualMachineSpec) error {
+	for k := range spec.NodeSelector {
+		if k == "" {
+			return fmt.Errorf("Error: nodeSelector has an empty key.")
+		}
+	}
+
+	rules := []struct {
+		name  string
+		rules system.VirtualMachineAffinityRules
+	}{
+		{name: "affinity", rules: spec.Affinity},
+		{name: "antiAffinity", rules: spec.AntiAffinity},
+	}
+	for _, r := range rules {
+		for i, term := range r.rules.Required {
+			if err := validateAffinityTerm(&term); err != nil {
+				return fmt.Errorf("Error: %s.required[%d]: %s", r.name, i, err.Error())
+			}
+		}
+		for i, term := range r.rules.Preferred {
+			if term.Weight < 1 || term.Weight > 100 {
+				return fmt.Errorf("Error: %s.preferred[%d]: weight must be between 1 and 100.", r.name, i)
+			}
+			if err := validateAffinityTerm(&term.VirtualMachineAffinityTerm); err != nil {
+				return fmt.Errorf("Error: %s.preferred[%d]: %s", r.name, i, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func validateAffinityTerm(term *system.VirtualMachineAffinityTerm) error {
+	// 空のselectorは全てのVMに一致してしまうので指定を誤っている可能性が高い
+	if len(term.LabelSelector.MatchLabels) == 0 {
+		return fmt.Errorf("labelSelector.matchLabels is empty.")
+	}
+
+	switch term.Scope {
+	case "", system.VirtualMachineAffinityScopeNamespace, system.VirtualMachineAffinityScopeGroup:
+	default:
+		return fmt.Errorf("scope `%s` is invalid, must be `Namespace` or `Group`.", term.Scope)
+	}
+	return nil
+}
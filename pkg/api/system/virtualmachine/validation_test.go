@@ -0,0 +1,88 @@
+package virtualmachine
+
+import (
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+func TestValidatePlacement(t *testing.T) {
+	web := system.VirtualMachineAffinityTerm{
+		LabelSelector: meta.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+	}
+
+	tests := []struct {
+		name    string
+		spec    system.VirtualMachineSpec
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			spec: system.VirtualMachineSpec{
+				NodeSelector: map[string]string{"zone": "a"},
+				Affinity: system.VirtualMachineAffinityRules{
+					Required: []system.VirtualMachineAffinityTerm{web},
+				},
+				AntiAffinity: system.VirtualMachineAffinityRules{
+					Preferred: []system.WeightedVirtualMachineAffinityTerm{
+						{Weight: 100, VirtualMachineAffinityTerm: system.VirtualMachineAffinityTerm{
+							LabelSelector: web.LabelSelector,
+							Scope:         system.VirtualMachineAffinityScopeGroup,
+						}},
+					},
+				},
+			},
+		},
+		{
+			name: "empty nodeSelector key",
+			spec: system.VirtualMachineSpec{
+				NodeSelector: map[string]string{"": "a"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty labelSelector",
+			spec: system.VirtualMachineSpec{
+				AntiAffinity: system.VirtualMachineAffinityRules{
+					Required: []system.VirtualMachineAffinityTerm{{}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid scope",
+			spec: system.VirtualMachineSpec{
+				Affinity: system.VirtualMachineAffinityRules{
+					Required: []system.VirtualMachineAffinityTerm{
+						{LabelSelector: web.LabelSelector, Scope: "Node"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid weight",
+			spec: system.VirtualMachineSpec{
+				Affinity: system.VirtualMachineAffinityRules{
+					Preferred: []system.WeightedVirtualMachineAffinityTerm{
+						{Weight: 0, VirtualMachineAffinityTerm: web},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePlacement(&tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -21,6 +21,10 @@ const (
 	basePath = "groups/:group_id/namespaces/:namespace_id/virtualrouters"
 )
 
+// AnnotationNodeName はVRを割り当てたノードのannotation
+// ノードの証明書ではこのノードのリソースのみ変更できる
+const AnnotationNodeName = "virtualrouterv0/node_name"
+
 func NewVirtualRouterHandler(router *gin.RouterGroup, vrhi VirtualRouterHandlerInterface) *VirtualRouterHandler {
 	return &VirtualRouterHandler{
 		router: router,
@@ -7,6 +7,9 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/conversion"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
@@ -18,11 +21,35 @@ type VirtualRouterHandler struct {
 	virtualrouter.VirtualRouterHandlerInterface
 
 	store store.Store
+	codec Codec
+}
+
+// Codec はbodyとv0のVirtualRouterを変換する
+type Codec struct {
+	Decode func(ctx *gin.Context) (*system.VirtualRouter, error)
+	Encode func(vr *system.VirtualRouter) interface{}
+}
+
+var codecV0 = Codec{
+	Decode: func(ctx *gin.Context) (*system.VirtualRouter, error) {
+		request := &system.VirtualRouter{}
+		err := ctx.Bind(request)
+		return request, err
+	},
+	Encode: func(vr *system.VirtualRouter) interface{} {
+		return vr
+	},
 }
 
 func NewVirtualRouterHandler(store store.Store) *VirtualRouterHandler {
+	return NewVirtualRouterHandlerWithCodec(store, codecV0)
+}
+
+// NewVirtualRouterHandlerWithCodec はv1などのbodyを扱うハンドラを作る
+func NewVirtualRouterHandlerWithCodec(store store.Store, codec Codec) *VirtualRouterHandler {
 	return &VirtualRouterHandler{
 		store: store,
+		codec: codec,
 	}
 }
 
@@ -42,8 +69,13 @@ func (h *VirtualRouterHandler) FindAll(ctx *gin.Context) {
 
 	h.store.List(getKey(groupID, nsID, ""), f)
 
+	res := make([]interface{}, 0, len(vrList))
+	for _, vr := range vrList {
+		res = append(res, h.codec.Encode(vr))
+	}
+
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"virtualrouters": vrList,
+		"virtualrouters": res,
 	})
 }
 
@@ -58,16 +90,14 @@ func (h *VirtualRouterHandler) Find(ctx *gin.Context) {
 	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"virtualrouter": vr,
+		"virtualrouter": h.codec.Encode(&vr),
 	})
 }
 
 func (h *VirtualRouterHandler) Create(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
-	var request system.VirtualRouter
-
-	err := ctx.Bind(&request)
+	request, err := h.codec.Decode(ctx)
 	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
@@ -98,18 +128,25 @@ func (h *VirtualRouterHandler) Create(ctx *gin.Context) {
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeVirtualRouterV0
-	h.store.Put(key, request)
+	request.UID = uuid.New().String()
+	request.Generation = 1
+	if err := conversion.Check(h.store, key, request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
-		"virtualrouter": request,
+		"virtualrouter": h.codec.Encode(request),
 	})
 }
 
 func (h *VirtualRouterHandler) Update(ctx *gin.Context) {
 	groupID, nsID, vrID := getIDs(ctx)
 
-	var request system.VirtualRouter
-	err := ctx.Bind(&request)
+	request, err := h.codec.Decode(ctx)
 	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
@@ -127,14 +164,28 @@ func (h *VirtualRouterHandler) Update(ctx *gin.Context) {
 		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: VirtualRouter `%s` is not found in Namespace `%s`.", vrID, nsID), nil)
 		return
 	}
+	if !auth.AuthorizeNodeObject(ctx, "VirtualRouter", vrID, vr.Annotations[virtualrouter.AnnotationNodeName], request.Annotations[virtualrouter.AnnotationNodeName]) {
+		return
+	}
 
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	// uid, generation, deletionTimestampはサーバー側で管理する
+	request.UID = vr.UID
+	request.Generation = meta.NextGeneration(vr.Generation, vr.Spec, request.Spec)
+	request.DeletionTimestamp = vr.DeletionTimestamp
+	request.KeepFinalizers(vr.Finalizers)
+	if err := conversion.Check(h.store, key, request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if !meta.IsDryRun(ctx) {
+		h.store.Put(key, request)
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"virtualrouter": request,
+		"virtualrouter": h.codec.Encode(request),
 	})
 }
 
@@ -145,7 +196,38 @@ func (h *VirtualRouterHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	var vr system.VirtualRouter
+	if err := h.store.Get(key, &vr); err != nil {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("VirtualRouter `%s` is not found.", vrID), nil)
+		return
+	}
+
+	// Foregroundの場合は依存するリソースが消えるまで削除を待つ
+	if meta.GetDeletionPropagation(ctx) == meta.DeletionPropagationForeground {
+		vr.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(vr.Finalizers) != 0 {
+		vr.MarkDeletion()
+		if err := conversion.Check(h.store, key, vr); err != nil {
+			meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+			return
+		}
+		if !meta.IsDryRun(ctx) {
+			h.store.Put(key, vr)
+		}
+
+		meta.ResponseJSON(ctx, http.StatusAccepted, nil, gin.H{
+			"virtualrouter": h.codec.Encode(&vr),
+		})
+		return
+	}
+
+	if !meta.IsDryRun(ctx) {
+		h.store.Delete(key)
+	}
+
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"virtualrouter": nil,
 	})
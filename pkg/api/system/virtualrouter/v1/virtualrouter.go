@@ -0,0 +1,25 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/system"
+	systemv1 "github.com/ophum/humstack/pkg/api/system/v1"
+	vrv0 "github.com/ophum/humstack/pkg/api/system/virtualrouter/v0"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// NewVirtualRouterHandler はv1のbodyをv0に変換して扱うハンドラを返す
+func NewVirtualRouterHandler(store store.Store) *vrv0.VirtualRouterHandler {
+	return vrv0.NewVirtualRouterHandlerWithCodec(store, vrv0.Codec{
+		Decode: func(ctx *gin.Context) (*system.VirtualRouter, error) {
+			request := &systemv1.VirtualRouter{}
+			if err := ctx.Bind(request); err != nil {
+				return nil, err
+			}
+			return systemv1.ConvertVirtualRouterToV0(request), nil
+		},
+		Encode: func(vr *system.VirtualRouter) interface{} {
+			return systemv1.ConvertVirtualRouterFromV0(vr)
+		},
+	})
+}
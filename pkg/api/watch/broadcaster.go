@@ -0,0 +1,77 @@
+package watch
+
+import (
+	"sync"
+)
+
+// Broadcaster はstoreの変更通知を全てのwatchのリクエストに配信する
+// watchはリクエストごとに購読し、接続が切れたら購読をやめる
+type Broadcaster struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription は1つのwatchのリクエストの購読
+type Subscription struct {
+	c    chan string
+	done chan struct{}
+	once sync.Once
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// C は配信された通知を受け取るチャネルを返す
+func (s *Subscription) C() <-chan string {
+	return s.c
+}
+
+// Subscribe は通知の購読を開始する
+// 使い終わったら必ずUnsubscribeを呼ぶ
+func (b *Broadcaster) Subscribe() *Subscription {
+	s := &Subscription{
+		c:    make(chan string),
+		done: make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// Unsubscribe は購読をやめる
+// 配信中でも待たずに抜けられるよう、先にdoneを閉じる
+func (b *Broadcaster) Unsubscribe(s *Subscription) {
+	s.once.Do(func() {
+		close(s.done)
+	})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, s)
+}
+
+// Broadcast は全ての購読に通知を配信する
+// 受け取りが遅い購読がある場合は受け取るか購読をやめるまで待つ
+func (b *Broadcaster) Broadcast(notice string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for s := range b.subscribers {
+		select {
+		case s.c <- notice:
+		case <-s.done:
+		}
+	}
+}
+
+// Len は購読の数を返す
+func (b *Broadcaster) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
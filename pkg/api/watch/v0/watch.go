@@ -3,9 +3,11 @@ package v0
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/conversion"
+	"github.com/ophum/humstack/pkg/api/metrics"
 	"github.com/ophum/humstack/pkg/api/watch"
 	"github.com/ophum/humstack/pkg/store/leveldb"
 )
@@ -13,23 +15,25 @@ import (
 type WatchHandler struct {
 	watch.WatchHandlerInterface
 
-	notifiers map[string](chan string)
+	broadcaster *watch.Broadcaster
+
+	// 通知するデータのAPIバージョン
+	version conversion.Version
 }
 
-func NewWatchHandler(notifiers map[string](chan string)) *WatchHandler {
+func NewWatchHandler(broadcaster *watch.Broadcaster, version conversion.Version) *WatchHandler {
 	return &WatchHandler{
-		notifiers: notifiers,
+		broadcaster: broadcaster,
+		version:     version,
 	}
 }
 
 func (h *WatchHandler) Watch(ctx *gin.Context) {
-	id, err := uuid.NewRandom()
-	if err != nil {
-		return
-	}
-
-	idString := id.String()
-	h.notifiers[idString] = make(chan string)
+	sub := h.broadcaster.Subscribe()
+	// 接続が切れた後は配信を待たせないように購読をやめる
+	defer h.broadcaster.Unsubscribe(sub)
+	metrics.IncWatchSubscribers()
+	defer metrics.DecWatchSubscribers()
 
 	ctx.Header("Content-Type", "text/event-stream")
 	ctx.Header("Cache-Control", "no-cache")
@@ -38,22 +42,57 @@ func (h *WatchHandler) Watch(ctx *gin.Context) {
 
 	apiType := ctx.DefaultQuery("apiType", "")
 
+	// ヘッダを先に送り、クライアントが購読の開始を待てるようにする
 	w := ctx.Writer
-	go func() {
-
-		for s := range h.notifiers[idString] {
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	// Writerはハンドラを抜けると使えなくなるので、ハンドラの中で書き込む
+	done := ctx.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return
+		case s := <-sub.C():
 			noticeData := leveldb.NoticeData{}
-			json.Unmarshal([]byte(s), &noticeData)
-
-			if apiType == "" || apiType == string(noticeData.APIType) {
+			if err := json.Unmarshal([]byte(s), &noticeData); err != nil {
+				continue
+			}
 
-				w.Write([]byte(fmt.Sprintf("data: %s\n\n", s)))
-				w.Flush()
+			noticeData, err := h.convert(noticeData)
+			if err != nil {
+				continue
+			}
+			if apiType != "" && apiType != string(noticeData.APIType) {
+				continue
+			}
+			noticeJSON, err := json.Marshal(noticeData)
+			if err != nil {
+				continue
 			}
 
+			if _, err := w.Write([]byte(fmt.Sprintf("data: %s\n\n", noticeJSON))); err != nil {
+				return
+			}
+			w.Flush()
 		}
+	}
+}
+
+// convert は保存されている形のデータをハンドラのバージョンに変換する
+func (h *WatchHandler) convert(noticeData leveldb.NoticeData) (leveldb.NoticeData, error) {
+	before, err := conversion.Convert(noticeData.Key, []byte(noticeData.Before), h.version)
+	if err != nil {
+		return noticeData, err
+	}
+	after, err := conversion.Convert(noticeData.Key, []byte(noticeData.After), h.version)
+	if err != nil {
+		return noticeData, err
+	}
 
-	}()
-	<-ctx.Done()
+	noticeData.APIType = conversion.APIType(noticeData.Key, h.version, noticeData.APIType)
+	noticeData.Before = string(before)
+	noticeData.After = string(after)
 
+	return noticeData, nil
 }
@@ -0,0 +1,290 @@
+package apiserver
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/conversion"
+	"github.com/ophum/humstack/pkg/api/core/event"
+	evv0 "github.com/ophum/humstack/pkg/api/core/event/v0"
+	"github.com/ophum/humstack/pkg/api/core/externalip"
+	eipv0 "github.com/ophum/humstack/pkg/api/core/externalip/v0"
+	"github.com/ophum/humstack/pkg/api/core/externalippool"
+	eippoolv0 "github.com/ophum/humstack/pkg/api/core/externalippool/v0"
+	"github.com/ophum/humstack/pkg/api/core/group"
+	grv0 "github.com/ophum/humstack/pkg/api/core/group/v0"
+	"github.com/ophum/humstack/pkg/api/core/lease"
+	leasev0 "github.com/ophum/humstack/pkg/api/core/lease/v0"
+	"github.com/ophum/humstack/pkg/api/core/namespace"
+	nsv0 "github.com/ophum/humstack/pkg/api/core/namespace/v0"
+	"github.com/ophum/humstack/pkg/api/core/network"
+	netv0 "github.com/ophum/humstack/pkg/api/core/network/v0"
+	"github.com/ophum/humstack/pkg/api/health"
+	"github.com/ophum/humstack/pkg/api/metrics"
+	"github.com/ophum/humstack/pkg/api/ratelimit"
+	"github.com/ophum/humstack/pkg/api/system/blockstorage"
+	bsv0 "github.com/ophum/humstack/pkg/api/system/blockstorage/v0"
+	bsv1 "github.com/ophum/humstack/pkg/api/system/blockstorage/v1"
+	"github.com/ophum/humstack/pkg/api/system/image"
+	imv0 "github.com/ophum/humstack/pkg/api/system/image/v0"
+	"github.com/ophum/humstack/pkg/api/system/imageentity"
+	iev0 "github.com/ophum/humstack/pkg/api/system/imageentity/v0"
+	iev1 "github.com/ophum/humstack/pkg/api/system/imageentity/v1"
+	"github.com/ophum/humstack/pkg/api/system/imagetag"
+	itv0 "github.com/ophum/humstack/pkg/api/system/imagetag/v0"
+	"github.com/ophum/humstack/pkg/api/system/node"
+	nodev0 "github.com/ophum/humstack/pkg/api/system/node/v0"
+	"github.com/ophum/humstack/pkg/api/system/nodenetwork"
+	nodenetv0 "github.com/ophum/humstack/pkg/api/system/nodenetwork/v0"
+	"github.com/ophum/humstack/pkg/api/system/virtualmachine"
+	vmv0 "github.com/ophum/humstack/pkg/api/system/virtualmachine/v0"
+	vmv1 "github.com/ophum/humstack/pkg/api/system/virtualmachine/v1"
+	"github.com/ophum/humstack/pkg/api/system/virtualrouter"
+	vrv0 "github.com/ophum/humstack/pkg/api/system/virtualrouter/v0"
+	vrv1 "github.com/ophum/humstack/pkg/api/system/virtualrouter/v1"
+	"github.com/ophum/humstack/pkg/api/watch"
+	watchv0 "github.com/ophum/humstack/pkg/api/watch/v0"
+	store "github.com/ophum/humstack/pkg/store/leveldb"
+	"github.com/ophum/humstack/pkg/version"
+)
+
+// Config はapiserverの設定
+type Config struct {
+	// LevelDBのディレクトリ
+	DatabasePath string
+	Debug        bool
+
+	// クライアントごとのレート制限, qpsが0の場合は制限しない
+	RateLimits map[ratelimit.RouteClass]ratelimit.Limit
+	// 0の場合は制限しない
+	MaxRequestBodyBytes int64
+
+	// この期間発生していないイベントを削除する
+	EventTTL time.Duration
+
+	// agentのダウンロードAPI, VNC websocketへプロキシする際の設定
+	ProxyTLSConfig *tls.Config
+
+	// nilでない場合は /static で配信する
+	StaticFS http.FileSystem
+}
+
+// Server はstoreとそれを公開するgin.Engine
+// listenはしないので、呼び出し元でHandlerを使って公開する
+type Server struct {
+	router   *gin.Engine
+	store    *store.LevelDBStore
+	notifier chan string
+	done     chan struct{}
+}
+
+func NewServer(config *Config) (*Server, error) {
+	r := gin.Default()
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = []string{"*"}
+	r.Use(cors.New(corsConfig))
+	r.Use(auth.ClientCertAuthentication())
+	r.Use(metrics.Middleware())
+	r.Use(ratelimit.MaxBodySize(config.MaxRequestBodyBytes))
+	// healthz, readyz, metricsは負荷が高い時も応答できるように制限しない
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Limits: config.RateLimits,
+	}).Middleware()
+
+	notifier := make(chan string, 100)
+	s, err := store.NewLevelDBStore(config.DatabasePath, notifier, config.Debug)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		router:   r,
+		store:    s,
+		notifier: notifier,
+		done:     make(chan struct{}),
+	}
+
+	// ハンドラはv0の形で読み書きし、v1はリクエストとレスポンスのbodyだけを変換する
+	sv0 := conversion.NewStore(s, conversion.V0)
+
+	// bloadcasting
+	broadcaster := watch.NewBroadcaster()
+	notifierMonitor := health.NewNotifierMonitor(notifier, time.Second*30)
+	go func() {
+		for n := range notifier {
+			notifierMonitor.Received()
+			broadcaster.Broadcast(n)
+			notifierMonitor.Delivered()
+		}
+	}()
+
+	// 古いバージョンで保存されているリソースを書き換える
+	// 書き込み時に変更が通知されるので配信を始めてから行う
+	migrated, err := conversion.Migrate(s)
+	if err != nil {
+		server.Close()
+		return nil, err
+	}
+	log.Printf("migrated %d objects to storage version %s", migrated, conversion.StorageVersion)
+
+	hh := health.NewHealthHandler()
+	hh.AddReadinessCheck("store", s.Ping)
+	hh.AddReadinessCheck("notifier", notifierMonitor.Check)
+	hh.RegisterHandlers(r)
+	r.GET("/version", version.Handler)
+
+	if config.StaticFS != nil {
+		r.StaticFS("/static", config.StaticFS)
+	}
+	r.GET("/metrics", metrics.Handler())
+
+	grh := grv0.NewGroupHandler(sv0)
+	nsh := nsv0.NewNamespaceHandler(sv0)
+	nnwh := nodenetv0.NewNodeNetworkHandler(sv0)
+	nwh := netv0.NewNetworkHandler(sv0)
+	bsh := bsv0.NewBlockStorageHandler(sv0)
+	bsh.SetProxyTLSConfig(config.ProxyTLSConfig)
+	vmh := vmv0.NewVirtualMachineHandler(sv0)
+	vmh.SetProxyTLSConfig(config.ProxyTLSConfig)
+	vrh := vrv0.NewVirtualRouterHandler(sv0)
+	eippoolh := eippoolv0.NewExternalIPPoolHandler(sv0)
+	eiph := eipv0.NewExternalIPHandler(sv0)
+	imh := imv0.NewImageHandler(sv0)
+	imh.SetProxyTLSConfig(config.ProxyTLSConfig)
+	ieh := iev0.NewImageEntityHandler(sv0)
+	ith := itv0.NewImageTagHandler(sv0)
+	nodeh := nodev0.NewNodeHandler(sv0)
+	watchh := watchv0.NewWatchHandler(broadcaster, conversion.V0)
+	eventh := evv0.NewEventHandler(sv0)
+	leaseh := leasev0.NewLeaseHandler(sv0)
+
+	// 古いイベントを削除する
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-server.done:
+				return
+			case <-ticker.C:
+				if n := eventh.DeleteExpired(config.EventTTL); n != 0 {
+					log.Printf("deleted %d expired events", n)
+				}
+			}
+		}
+	}()
+
+	bsv1h := bsv1.NewBlockStorageHandler(sv0)
+	bsv1h.SetProxyTLSConfig(config.ProxyTLSConfig)
+	vmv1h := vmv1.NewVirtualMachineHandler(sv0)
+	vmv1h.SetProxyTLSConfig(config.ProxyTLSConfig)
+	vrv1h := vrv1.NewVirtualRouterHandler(sv0)
+	iev1h := iev1.NewImageEntityHandler(sv0)
+	watchv1h := watchv0.NewWatchHandler(broadcaster, conversion.V1)
+
+	v0 := r.Group("/api/v0", limiter, auth.RestrictNode())
+	{
+		gri := group.NewGroupHandler(v0, grh)
+		nsi := namespace.NewNamespaceHandler(v0, nsh)
+		nwi := network.NewNetworkHandler(v0, nwh)
+		nnwi := nodenetwork.NewNodeNetworkHandler(v0, nnwh)
+		bsi := blockstorage.NewBlockStorageHandler(v0, bsh)
+		vmi := virtualmachine.NewVirtualMachineHandler(v0, vmh)
+		vri := virtualrouter.NewVirtualRouterHandler(v0, vrh)
+		eippooli := externalippool.NewExternalIPPoolHandler(v0, eippoolh)
+		eipi := externalip.NewExternalIPHandler(v0, eiph)
+		imi := image.NewImageHandler(v0, imh)
+		iei := imageentity.NewImageEntityHandler(v0, ieh)
+		iti := imagetag.NewImageTagHandler(v0, ith)
+		nodei := node.NewNodeHandler(v0, nodeh)
+		watchi := watch.NewWatchHandler(v0, watchh)
+		eventi := event.NewEventHandler(v0, eventh)
+		leasei := lease.NewLeaseHandler(v0, leaseh)
+
+		gri.RegisterHandlers()
+		nsi.RegisterHandlers()
+		nwi.RegisterHandlers()
+		nnwi.RegisterHandlers()
+		bsi.RegisterHandlers()
+		vmi.RegisterHandlers()
+		vri.RegisterHandlers()
+		eippooli.RegisterHandlers()
+		eipi.RegisterHandlers()
+		imi.RegisterHandlers()
+		iei.RegisterHandlers()
+		iti.RegisterHandlers()
+		nodei.RegisterHandlers()
+		watchi.RegisterHandlers()
+		eventi.RegisterHandlers()
+		leasei.RegisterHandlers()
+	}
+
+	v1 := r.Group("/api/v1", limiter, auth.RestrictNode())
+	{
+		bsi := blockstorage.NewBlockStorageHandler(v1, bsv1h)
+		vmi := virtualmachine.NewVirtualMachineHandler(v1, vmv1h)
+		vri := virtualrouter.NewVirtualRouterHandler(v1, vrv1h)
+		iei := imageentity.NewImageEntityHandler(v1, iev1h)
+		watchi := watch.NewWatchHandler(v1, watchv1h)
+
+		bsi.RegisterHandlers()
+		vmi.RegisterHandlers()
+		vri.RegisterHandlers()
+		iei.RegisterHandlers()
+		watchi.RegisterHandlers()
+
+		// v0と形が変わらないリソースはv0のハンドラでapiTypeだけを書き換える
+		renamed := v1.Group("", conversion.RenameAPIType(conversion.V1))
+		gri := group.NewGroupHandler(renamed, grh)
+		nsi := namespace.NewNamespaceHandler(renamed, nsh)
+		nwi := network.NewNetworkHandler(renamed, nwh)
+		nnwi := nodenetwork.NewNodeNetworkHandler(renamed, nnwh)
+		eippooli := externalippool.NewExternalIPPoolHandler(renamed, eippoolh)
+		eipi := externalip.NewExternalIPHandler(renamed, eiph)
+		imi := image.NewImageHandler(renamed, imh)
+		iti := imagetag.NewImageTagHandler(renamed, ith)
+		nodei := node.NewNodeHandler(renamed, nodeh)
+		eventi := event.NewEventHandler(renamed, eventh)
+		leasei := lease.NewLeaseHandler(renamed, leaseh)
+
+		gri.RegisterHandlers()
+		nsi.RegisterHandlers()
+		nwi.RegisterHandlers()
+		nnwi.RegisterHandlers()
+		eippooli.RegisterHandlers()
+		eipi.RegisterHandlers()
+		imi.RegisterHandlers()
+		iti.RegisterHandlers()
+		nodei.RegisterHandlers()
+		eventi.RegisterHandlers()
+		leasei.RegisterHandlers()
+	}
+
+	return server, nil
+}
+
+// Handler はapiserverのhttp.Handlerを返す
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// RegisterMetrics はstoreのメトリクスをprometheusに登録する
+// 同じプロセスで複数回呼ぶとpanicするので、apiserverのプロセスでのみ呼ぶ
+func (s *Server) RegisterMetrics() {
+	metrics.RegisterNotifierQueueDepth(s.notifier)
+	metrics.RegisterObjectCounter(s.store)
+}
+
+// Close はstoreを閉じる
+// 処理中のリクエストがある場合は先にhttpサーバーを止めておく
+func (s *Server) Close() error {
+	close(s.done)
+	err := s.store.Close()
+	close(s.notifier)
+	return err
+}
@@ -0,0 +1,360 @@
+package apiserver_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/ratelimit"
+	"github.com/ophum/humstack/pkg/api/system"
+	systemv1 "github.com/ophum/humstack/pkg/api/system/v1"
+	"github.com/ophum/humstack/pkg/apiserver"
+	humtesting "github.com/ophum/humstack/pkg/testing"
+)
+
+func request(t *testing.T, h *humtesting.Harness, method, path string, body, out interface{}) {
+	t.Helper()
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s:%d%s", h.Address, h.Port, path), bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		t.Fatalf("%s %s: unexpected status %d", method, path, res.StatusCode)
+	}
+	if err := json.NewDecoder(res.Body).Decode(&struct {
+		Data interface{} `json:"data"`
+	}{Data: out}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockStorageV1(t *testing.T) {
+	h := humtesting.Start(t, &humtesting.Options{DisableCoreAgents: true})
+	h.CreateNamespace("group1", "ns1")
+
+	path := "/api/v1/groups/group1/namespaces/ns1/blockstorages"
+	created := struct {
+		BlockStorage *systemv1.BlockStorage `json:"blockstorage"`
+	}{}
+	request(t, h, http.MethodPost, path, &systemv1.BlockStorage{
+		Meta: meta.Meta{ID: "bs1", Name: "bs1", Group: "group1", Namespace: "ns1"},
+		Spec: systemv1.BlockStorageSpec{
+			BlockStorageSpec: system.BlockStorageSpec{RequestSize: "1G", LimitSize: "1G"},
+			Backend:          systemv1.StorageBackend{Type: systemv1.StorageBackendTypeLocal},
+			Placement:        systemv1.Placement{NodeName: "node1"},
+		},
+	}, &created)
+	if created.BlockStorage.APIType != meta.APITypeBlockStorageV1 ||
+		created.BlockStorage.Spec.Placement.NodeName != "node1" ||
+		created.BlockStorage.UID == "" {
+		t.Fatalf("unexpected response: %+v", created.BlockStorage)
+	}
+
+	// v0ではannotationとして見える
+	bs, err := h.Clients.SystemV0().BlockStorage().Get(context.Background(), "group1", "ns1", "bs1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs.Annotations["blockstoragev0/node_name"] != "node1" || bs.Annotations["blockstoragev0/type"] != "Local" {
+		t.Fatalf("unexpected v0 annotations: %v", bs.Annotations)
+	}
+
+	// statusのdownloadはv0のannotationに反映する
+	status := created.BlockStorage
+	status.Status.State = system.BlockStorageStateActive
+	status.Status.Download = &systemv1.DownloadEndpoint{Host: "127.0.0.1:8082", Scheme: "http"}
+	request(t, h, http.MethodPut, path+"/bs1/status", status, &struct{}{})
+
+	bs, err = h.Clients.SystemV0().BlockStorage().Get(context.Background(), "group1", "ns1", "bs1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs.Status.State != system.BlockStorageStateActive || bs.Annotations["bs-download-host"] != "127.0.0.1:8082" {
+		t.Fatalf("unexpected v0 blockstorage: %+v", bs)
+	}
+
+	list := struct {
+		BlockStorages []*systemv1.BlockStorage `json:"blockstorages"`
+	}{}
+	request(t, h, http.MethodGet, path, nil, &list)
+	if len(list.BlockStorages) != 1 ||
+		list.BlockStorages[0].Status.Download == nil ||
+		list.BlockStorages[0].Status.Download.Host != "127.0.0.1:8082" {
+		t.Fatalf("unexpected list: %+v", list.BlockStorages)
+	}
+}
+
+func TestUnchangedResourcesV1(t *testing.T) {
+	h := humtesting.Start(t, &humtesting.Options{DisableCoreAgents: true})
+
+	// v1だけを使うクライアントがnamespaceを作ってVMを作れる
+	group := struct {
+		Group *core.Group `json:"group"`
+	}{}
+	request(t, h, http.MethodPost, "/api/v1/groups", &core.Group{
+		Meta: meta.Meta{ID: "group1", Name: "group1", APIType: meta.APITypeGroupV1},
+	}, &group)
+	if group.Group.APIType != meta.APITypeGroupV1 || group.Group.UID == "" {
+		t.Fatalf("unexpected group: %+v", group.Group)
+	}
+
+	request(t, h, http.MethodPost, "/api/v1/groups/group1/namespaces", &core.Namespace{
+		Meta: meta.Meta{ID: "ns1", Name: "ns1", Group: "group1", APIType: meta.APITypeNamespaceV1},
+	}, &struct{}{})
+
+	vm := struct {
+		VirtualMachine *systemv1.VirtualMachine `json:"virtualmachine"`
+	}{}
+	request(t, h, http.MethodPost, "/api/v1/groups/group1/namespaces/ns1/virtualmachines", &systemv1.VirtualMachine{
+		Meta: meta.Meta{ID: "vm1", Name: "vm1", Group: "group1", Namespace: "ns1"},
+	}, &vm)
+	if vm.VirtualMachine.APIType != meta.APITypeVirtualMachineV1 {
+		t.Fatalf("unexpected virtualmachine: %+v", vm.VirtualMachine)
+	}
+
+	// storeにはv0の形で保存する
+	ns, err := h.Clients.CoreV0().Namespace().Get(context.Background(), "group1", "ns1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns.APIType != meta.APITypeNamespaceV0 {
+		t.Fatalf("unexpected v0 namespace: %+v", ns)
+	}
+
+	list := struct {
+		Namespaces []*core.Namespace `json:"namespaces"`
+	}{}
+	request(t, h, http.MethodGet, "/api/v1/groups/group1/namespaces", nil, &list)
+	if len(list.Namespaces) != 1 || list.Namespaces[0].APIType != meta.APITypeNamespaceV1 {
+		t.Fatalf("unexpected list: %+v", list.Namespaces)
+	}
+}
+
+func TestImageTagID(t *testing.T) {
+	h := humtesting.Start(t, &humtesting.Options{DisableCoreAgents: true})
+	h.CreateNamespace("group1", "ns1")
+
+	ctx := context.Background()
+	if _, err := h.Clients.SystemV0().Image().Create(ctx, &system.Image{
+		Meta: meta.Meta{ID: "ubuntu", Name: "ubuntu", Group: "group1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Clients.SystemV0().ImageEntity().Create(ctx, &system.ImageEntity{
+		Meta: meta.Meta{ID: "entity1", Name: "entity1", Group: "group1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := h.Clients.SystemV0().ImageTag().Create(ctx, &system.ImageTag{
+		Meta: meta.Meta{Group: "group1"},
+		Spec: system.ImageTagSpec{ImageName: "ubuntu", Tag: "20.04", ImageEntityID: "entity1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.ID != "ubuntu:20.04" {
+		t.Fatalf("unexpected id: %s", it.ID)
+	}
+
+	// IDの区切りを含むtagは作れない
+	if _, err := h.Clients.SystemV0().ImageTag().Create(ctx, &system.ImageTag{
+		Meta: meta.Meta{Group: "group1"},
+		Spec: system.ImageTagSpec{ImageName: "ubuntu", Tag: "a:b", ImageEntityID: "entity1"},
+	}); err == nil {
+		t.Fatal("expected error for tag containing separator")
+	}
+
+	got, err := h.Clients.SystemV0().ImageTag().GetByTag(ctx, "group1", "ubuntu", "20.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.ImageEntityID != "entity1" {
+		t.Fatalf("unexpected imagetag: %+v", got)
+	}
+}
+
+func TestRateLimitExemptsProbes(t *testing.T) {
+	s, err := apiserver.NewServer(&apiserver.Config{
+		DatabasePath: t.TempDir(),
+		RateLimits: map[ratelimit.RouteClass]ratelimit.Limit{
+			ratelimit.RouteClassRead: {QPS: 0.001, Burst: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := get("/api/v0/groups"); code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if code := get("/api/v0/groups"); code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	for i := 0; i < 3; i++ {
+		for _, path := range []string{"/healthz", "/readyz", "/metrics", "/version"} {
+			if code := get(path); code == http.StatusTooManyRequests {
+				t.Fatalf("%s: unexpected status: %d", path, code)
+			}
+		}
+	}
+}
+
+func TestNodeResourceVersion(t *testing.T) {
+	h := humtesting.Start(t, &humtesting.Options{DisableCoreAgents: true})
+	ctx := context.Background()
+
+	node, err := h.Clients.SystemV0().Node().Create(ctx, &system.Node{
+		Meta: meta.Meta{ID: "node-rv", Name: "node-rv"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *node
+
+	// cordon
+	node.Spec.Unschedulable = true
+	node, err = h.Clients.SystemV0().Node().Update(ctx, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.ResourceVersion != stale.ResourceVersion+1 {
+		t.Fatalf("unexpected resourceVersion: %d", node.ResourceVersion)
+	}
+
+	// 古いnodeで更新するとcordonを上書きせずに409を返す
+	stale.Status.State = system.NodeStateReady
+	if _, err := h.Clients.SystemV0().Node().Update(ctx, &stale); !meta.IsConflict(err) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	node, err = h.Clients.SystemV0().Node().Get(ctx, "node-rv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !node.Spec.Unschedulable || node.Status.State == system.NodeStateReady {
+		t.Fatalf("node was overwritten: %+v", node)
+	}
+}
+
+func TestUpdateKeepsFinalizers(t *testing.T) {
+	h := humtesting.Start(t, &humtesting.Options{DisableCoreAgents: true})
+	h.CreateNamespace("group1", "ns1")
+	ctx := context.Background()
+
+	net, err := h.Clients.CoreV0().Network().Create(ctx, &core.Network{
+		Meta: meta.Meta{ID: "net1", Name: "net1", Group: "group1", Namespace: "ns1", Finalizers: []string{"test"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// finalizersを含まないマニフェストでupdateしてもagentのfinalizerは残る
+	net.Finalizers = nil
+	net.Labels = map[string]string{"updated": "true"}
+	net, err = h.Clients.CoreV0().Network().Update(ctx, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !net.HasFinalizer("test") || net.Labels["updated"] != "true" {
+		t.Fatalf("unexpected meta: %+v", net.Meta)
+	}
+
+	// agentが外した場合は外れる
+	net.RemoveFinalizer("test")
+	net, err = h.Clients.CoreV0().Network().Update(ctx, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(net.Finalizers) != 0 {
+		t.Fatalf("finalizer is not removed: %v", net.Finalizers)
+	}
+}
+
+func TestNodeCertificateRestriction(t *testing.T) {
+	h := humtesting.Start(t, &humtesting.Options{DisableCoreAgents: true, TLS: true})
+	h.CreateNamespace("group1", "ns1")
+	ctx := context.Background()
+	nodeA := h.NewClients(auth.NodeCommonName("node-a"))
+
+	// 共有のリソースは作成できない
+	if _, err := nodeA.CoreV0().Network().Create(ctx, &core.Network{
+		Meta: meta.Meta{ID: "net1", Name: "net1", Group: "group1", Namespace: "ns1"},
+	}); !meta.IsForbidden(err) {
+		t.Fatalf("expected forbidden, got %v", err)
+	}
+
+	vms := map[string]*system.VirtualMachine{}
+	for id, annotations := range map[string]map[string]string{
+		"vm-a":      {"virtualmachinev0/node_name": "node-a"},
+		"vm-b":      {"virtualmachinev0/node_name": "node-b"},
+		"vm-fenced": {"virtualmachinev0/node_name": "node-b", "virtualmachinev0/previous_node_name": "node-c,node-a"},
+	} {
+		vm, err := h.Clients.SystemV0().VirtualMachine().Create(ctx, &system.VirtualMachine{
+			Meta: meta.Meta{ID: id, Name: id, Group: "group1", Namespace: "ns1", Annotations: annotations},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		vms[id] = vm
+	}
+
+	// 自身のノードのVMは更新できるが、他のノードへは移せない
+	vms["vm-a"].Status.State = system.VirtualMachineStateRunning
+	vm, err := nodeA.SystemV0().VirtualMachine().Update(ctx, vms["vm-a"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.Annotations["virtualmachinev0/node_name"] = "node-b"
+	if _, err := nodeA.SystemV0().VirtualMachine().Update(ctx, vm); !meta.IsForbidden(err) {
+		t.Fatalf("expected forbidden, got %v", err)
+	}
+
+	// 他のノードのVMは更新も削除もできない
+	vms["vm-b"].Status.State = system.VirtualMachineStateStopped
+	if _, err := nodeA.SystemV0().VirtualMachine().Update(ctx, vms["vm-b"]); !meta.IsForbidden(err) {
+		t.Fatalf("expected forbidden, got %v", err)
+	}
+	if err := nodeA.SystemV0().VirtualMachine().Delete(ctx, "group1", "ns1", "vm-a"); !meta.IsForbidden(err) {
+		t.Fatalf("expected forbidden, got %v", err)
+	}
+
+	// 移動元のノードはprevious_node_nameから自身を外すことだけできる
+	fenced := *vms["vm-fenced"]
+	fenced.Spec.UUID = "changed"
+	fenced.Annotations = map[string]string{"virtualmachinev0/node_name": "node-b", "virtualmachinev0/previous_node_name": "node-c"}
+	if _, err := nodeA.SystemV0().VirtualMachine().Update(ctx, &fenced); !meta.IsForbidden(err) {
+		t.Fatalf("expected forbidden, got %v", err)
+	}
+	vms["vm-fenced"].Annotations["virtualmachinev0/previous_node_name"] = "node-c"
+	if _, err := nodeA.SystemV0().VirtualMachine().Update(ctx, vms["vm-fenced"]); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,37 +1,111 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/client/core"
+	"github.com/ophum/humstack/pkg/client/dynamic"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 	"github.com/ophum/humstack/pkg/client/system"
+	"github.com/ophum/humstack/pkg/client/wait"
 	watchv0 "github.com/ophum/humstack/pkg/client/watch/v0"
 )
 
+// Config はapiserverへの接続設定
+// タイムアウト、再送、User-Agent、認証を設定できる
+type Config = rest.Config
+
+// Authenticator はapiserverへのリクエストに認証情報を付ける
+type Authenticator = rest.Authenticator
+
+// AuthenticatorFunc は関数をAuthenticatorとして使う
+type AuthenticatorFunc = rest.AuthenticatorFunc
+
+// BearerToken は `Authorization: Bearer <token>` を付けるAuthenticatorを返す
+func BearerToken(token string) Authenticator {
+	return rest.BearerToken(token)
+}
+
+// BasicAuth はBasic認証のヘッダを付けるAuthenticatorを返す
+func BasicAuth(username, password string) Authenticator {
+	return rest.BasicAuth(username, password)
+}
+
+// DefaultUserAgent はConfig.UserAgentを省略した場合のUser-Agent
+func DefaultUserAgent() string {
+	return rest.DefaultUserAgent()
+}
+
+// Interface はagentが使うapiserverのクライアント
+// agentのテストではpkg/client/fakeの実装に差し替えられる
+type Interface interface {
+	CoreV0() core.CoreV0Interface
+	SystemV0() system.SystemV0Interface
+}
+
+var _ Interface = &Clients{}
+
 type Clients struct {
-	coreV0           *core.CoreV0Clients
-	systemV0         *system.SystemV0Clients
-	watchV0          *watchv0.WatchClient
-	apiServerAddress string
-	apiServerPort    int32
+	client   *rest.Client
+	coreV0   *core.CoreV0Clients
+	systemV0 *system.SystemV0Clients
+	watchV0  *watchv0.WatchClient
+	dynamic  *dynamic.Client
 }
 
 func NewClients(apiServerAddress string, apiServerPort int32) *Clients {
-	return &Clients{
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
+	return NewClientsWithTLS(apiServerAddress, apiServerPort, nil)
+}
 
-		coreV0:   core.NewCoreV0Clients(apiServerAddress, apiServerPort),
-		systemV0: system.NewSystemV0Clients(apiServerAddress, apiServerPort),
-		watchV0:  watchv0.NewWatchClient("http", apiServerAddress, apiServerPort),
+// NewClientsWithTLS はtlsConfigがnilでない場合にhttpsでapiserverに接続する
+func NewClientsWithTLS(apiServerAddress string, apiServerPort int32, tlsConfig *tls.Config) *Clients {
+	return NewClientsWithConfig(&Config{
+		Address:         apiServerAddress,
+		Port:            apiServerPort,
+		TLSClientConfig: tlsConfig,
+	})
+}
+
+// NewClientsWithConfig はconfigの設定でapiserverに接続する
+// 全てのリソースのクライアントで1つの接続を共有する
+func NewClientsWithConfig(config *Config) *Clients {
+	client := rest.New(*config)
+	return &Clients{
+		client:   client,
+		coreV0:   core.NewCoreV0Clients(client),
+		systemV0: system.NewSystemV0Clients(client),
+		watchV0:  watchv0.NewWatchClient(client),
+		dynamic:  dynamic.NewClient(client),
 	}
 }
 
-func (c *Clients) CoreV0() *core.CoreV0Clients {
+// SetDryRun はtrueの場合、作成・更新・削除をapiserverのdryRunで行う
+// 変更を保存せずに結果だけを確認したい場合に使う
+func (c *Clients) SetDryRun(dryRun bool) {
+	c.client.SetDryRun(dryRun)
+}
+
+func (c *Clients) CoreV0() core.CoreV0Interface {
 	return c.coreV0
 }
-func (c *Clients) SystemV0() *system.SystemV0Clients {
+func (c *Clients) SystemV0() system.SystemV0Interface {
 	return c.systemV0
 }
 
 func (c *Clients) WatchV0() *watchv0.WatchClient {
 	return c.watchV0
 }
+
+// Dynamic はapiTypeを問わずmeta.Objectでリソースを操作するクライアントを返す
+func (c *Clients) Dynamic() dynamic.Interface {
+	return c.dynamic
+}
+
+// WaitFor はobjectがpredicateを満たすまで待ち、その時点のリソースを返す
+// watchで変更を受け取り、watchが切れていてもwait.DefaultPollIntervalごとに確認する
+// e.g. clients.WaitFor(ctx, vm, wait.State("Running"))
+func (c *Clients) WaitFor(ctx context.Context, object interface{}, predicate wait.Predicate) (*meta.Object, error) {
+	return wait.NewWaiter(c.dynamic, c.watchV0).For(ctx, object, predicate)
+}
@@ -1,53 +1,64 @@
 package core
 
 import (
+	eventv0 "github.com/ophum/humstack/pkg/client/core/event/v0"
 	eipv0 "github.com/ophum/humstack/pkg/client/core/externalip/v0"
 	eippoolv0 "github.com/ophum/humstack/pkg/client/core/externalippool/v0"
 	grv0 "github.com/ophum/humstack/pkg/client/core/group/v0"
+	leasev0 "github.com/ophum/humstack/pkg/client/core/lease/v0"
 	nsv0 "github.com/ophum/humstack/pkg/client/core/namespace/v0"
 	netv0 "github.com/ophum/humstack/pkg/client/core/network/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type CoreV0Clients struct {
-	apiServerAddress string
-	apiServerPort    int32
-
 	namespaceClient *nsv0.NamespaceClient
 	groupClient     *grv0.GroupClient
 	eippoolClient   *eippoolv0.ExternalIPPoolClient
 	eipClient       *eipv0.ExternalIPClient
 	networkClient   *netv0.NetworkClient
+	eventClient     *eventv0.EventClient
+	leaseClient     *leasev0.LeaseClient
 }
 
-func NewCoreV0Clients(apiServerAddress string, apiServerPort int32) *CoreV0Clients {
+// NewCoreV0Clients はcorev0の全てのクライアントを作成する
+// clientは全てのクライアントで共有する
+func NewCoreV0Clients(client *rest.Client) *CoreV0Clients {
 	return &CoreV0Clients{
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-
-		namespaceClient: nsv0.NewNamespaceClient("http", apiServerAddress, apiServerPort),
-		groupClient:     grv0.NewGroupClient("http", apiServerAddress, apiServerPort),
-		eipClient:       eipv0.NewExternalIPClient("http", apiServerAddress, apiServerPort),
-		eippoolClient:   eippoolv0.NewExternalIPPoolClient("http", apiServerAddress, apiServerPort),
-		networkClient:   netv0.NewNetworkClient("http", apiServerAddress, apiServerPort),
+		namespaceClient: nsv0.NewNamespaceClient(client),
+		groupClient:     grv0.NewGroupClient(client),
+		eipClient:       eipv0.NewExternalIPClient(client),
+		eippoolClient:   eippoolv0.NewExternalIPPoolClient(client),
+		networkClient:   netv0.NewNetworkClient(client),
+		eventClient:     eventv0.NewEventClient(client),
+		leaseClient:     leasev0.NewLeaseClient(client),
 	}
 }
 
-func (c *CoreV0Clients) Namespace() *nsv0.NamespaceClient {
+func (c *CoreV0Clients) Namespace() NamespaceInterface {
 	return c.namespaceClient
 }
 
-func (c *CoreV0Clients) Group() *grv0.GroupClient {
+func (c *CoreV0Clients) Group() GroupInterface {
 	return c.groupClient
 }
 
-func (c *CoreV0Clients) ExternalIPPool() *eippoolv0.ExternalIPPoolClient {
+func (c *CoreV0Clients) ExternalIPPool() ExternalIPPoolInterface {
 	return c.eippoolClient
 }
 
-func (c *CoreV0Clients) ExternalIP() *eipv0.ExternalIPClient {
+func (c *CoreV0Clients) ExternalIP() ExternalIPInterface {
 	return c.eipClient
 }
 
-func (c *CoreV0Clients) Network() *netv0.NetworkClient {
+func (c *CoreV0Clients) Network() NetworkInterface {
 	return c.networkClient
 }
+
+func (c *CoreV0Clients) Event() EventInterface {
+	return c.eventClient
+}
+
+func (c *CoreV0Clients) Lease() LeaseInterface {
+	return c.leaseClient
+}
@@ -0,0 +1,103 @@
+package v0
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+)
+
+type EventClient struct {
+	client *rest.Client
+}
+
+type EventResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		Event core.Event `json:"event"`
+	} `json:"data"`
+}
+
+type EventListResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		EventList []*core.Event `json:"events"`
+	} `json:"data"`
+}
+
+func NewEventClient(client *rest.Client) *EventClient {
+	return &EventClient{
+		client: client,
+	}
+}
+
+func (c *EventClient) Get(ctx context.Context, eventID string) (*core.Event, error) {
+	eventResp := EventResponse{}
+	if err := c.client.Get(ctx, c.getPath(eventID), nil, &eventResp); err != nil {
+		return nil, err
+	}
+
+	return &eventResp.Data.Event, nil
+}
+
+func (c *EventClient) List(ctx context.Context) ([]*core.Event, error) {
+	return c.list(ctx, url.Values{})
+}
+
+// ListByObject はobjectのイベントを返す
+// objectの空のフィールドは絞り込みに使わない
+func (c *EventClient) ListByObject(ctx context.Context, object meta.ObjectReference) ([]*core.Event, error) {
+	query := url.Values{}
+	if object.APIType != "" {
+		query.Set("apiType", string(object.APIType))
+	}
+	if object.Group != "" {
+		query.Set("group", object.Group)
+	}
+	if object.Namespace != "" {
+		query.Set("namespace", object.Namespace)
+	}
+	if object.ID != "" {
+		query.Set("id", object.ID)
+	}
+	return c.list(ctx, query)
+}
+
+func (c *EventClient) list(ctx context.Context, query url.Values) ([]*core.Event, error) {
+	eventResp := EventListResponse{}
+	if err := c.client.Get(ctx, c.getPath(), query, &eventResp); err != nil {
+		return nil, err
+	}
+
+	return eventResp.Data.EventList, nil
+}
+
+func (c *EventClient) Create(ctx context.Context, event *core.Event) (*core.Event, error) {
+	eventResp := EventResponse{}
+	if err := c.client.Post(ctx, c.getPath(), event, &eventResp); err != nil {
+		return nil, err
+	}
+
+	return &eventResp.Data.Event, nil
+}
+
+func (c *EventClient) Update(ctx context.Context, event *core.Event) (*core.Event, error) {
+	eventResp := EventResponse{}
+	if err := c.client.Put(ctx, c.getPath(event.ID), event, &eventResp); err != nil {
+		return nil, err
+	}
+
+	return &eventResp.Data.Event, nil
+}
+
+func (c *EventClient) Delete(ctx context.Context, eventID string) error {
+	return c.client.Delete(ctx, c.getPath(eventID), nil)
+}
+
+func (c *EventClient) getPath(eventID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "events"}, eventID...)...)
+}
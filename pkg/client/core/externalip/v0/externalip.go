@@ -1,20 +1,16 @@
 package v0
 
 import (
-	"encoding/json"
-	"fmt"
-	"path/filepath"
+	"context"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type ExternalIPClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
 type ExternalIPResponse struct {
@@ -33,117 +29,58 @@ type ExternalIPListResponse struct {
 	} `json:"data"`
 }
 
-const (
-	basePathFormat = "api/v0/externalips"
-)
-
-func NewExternalIPClient(scheme, apiServerAddress string, apiServerPort int32) *ExternalIPClient {
+func NewExternalIPClient(client *rest.Client) *ExternalIPClient {
 	return &ExternalIPClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accepted":     "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *ExternalIPClient) Get(eipID string) (*core.ExternalIP, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(eipID))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
+func (c *ExternalIPClient) Get(ctx context.Context, eipID string) (*core.ExternalIP, error) {
 	eipResp := ExternalIPResponse{}
-	err = json.Unmarshal(body, &eipResp)
-	if err != nil {
+	if err := c.client.Get(ctx, c.getPath(eipID), nil, &eipResp); err != nil {
 		return nil, err
 	}
 
 	return &eipResp.Data.ExternalIP, nil
 }
 
-func (c *ExternalIPClient) List() ([]*core.ExternalIP, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(""))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
+func (c *ExternalIPClient) List(ctx context.Context) ([]*core.ExternalIP, error) {
 	eipResp := ExternalIPListResponse{}
-	err = json.Unmarshal(body, &eipResp)
-	if err != nil {
+	if err := c.client.Get(ctx, c.getPath(), nil, &eipResp); err != nil {
 		return nil, err
 	}
+
 	return eipResp.Data.ExternalIPList, nil
 }
 
-func (c *ExternalIPClient) Create(eip *core.ExternalIP) (*core.ExternalIP, error) {
-	body, err := json.Marshal(eip)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(""))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
+func (c *ExternalIPClient) Create(ctx context.Context, eip *core.ExternalIP) (*core.ExternalIP, error) {
 	eipResp := ExternalIPResponse{}
-	err = json.Unmarshal(body, &eipResp)
-	if err != nil {
+	if err := c.client.Post(ctx, c.getPath(), eip, &eipResp); err != nil {
 		return nil, err
 	}
 
-	if resp.IsError() {
-		return nil, fmt.Errorf("error: %+v", eipResp.Error)
-	}
-
 	return &eipResp.Data.ExternalIP, nil
 }
 
-func (c *ExternalIPClient) Update(eip *core.ExternalIP) (*core.ExternalIP, error) {
-	body, err := json.Marshal(eip)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(eip.ID))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
+func (c *ExternalIPClient) Update(ctx context.Context, eip *core.ExternalIP) (*core.ExternalIP, error) {
 	eipResp := ExternalIPResponse{}
-	err = json.Unmarshal(body, &eipResp)
-	if err != nil {
+	if err := c.client.Put(ctx, c.getPath(eip.ID), eip, &eipResp); err != nil {
 		return nil, err
 	}
 
 	return &eipResp.Data.ExternalIP, nil
 }
 
-func (c *ExternalIPClient) Delete(eipID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(eipID))
-	if err != nil {
-		return err
-	}
+func (c *ExternalIPClient) Delete(ctx context.Context, eipID string) error {
+	return c.client.Delete(ctx, c.getPath(eipID), nil)
+}
 
-	return nil
+func (c *ExternalIPClient) DeleteWithPropagation(ctx context.Context, eipID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(eipID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
 }
 
-func (c *ExternalIPClient) getPath(path string) string {
-	return fmt.Sprintf("%s://%s",
-		c.scheme,
-		filepath.Join(
-			fmt.Sprintf("%s:%d",
-				c.apiServerAddress,
-				c.apiServerPort,
-			),
-			basePathFormat,
-			path))
+func (c *ExternalIPClient) getPath(eipID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "externalips"}, eipID...)...)
 }
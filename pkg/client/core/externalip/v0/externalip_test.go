@@ -1,14 +1,14 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
 	"testing"
 
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
-	eippoolv0 "github.com/ophum/humstack/pkg/client/core/externalippool/v0"
+	v0 "github.com/ophum/humstack/pkg/client/core/externalip/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
 const (
@@ -16,12 +16,15 @@ const (
 	eippoolID = "test-eippool-00"
 )
 
-func TestExternalIPCreate(t *testing.T) {
-	eippoolClient := eippoolv0.NewExternalIPPoolClient("http", "localhost", 8080)
-	pool, err := eippoolClient.Create(&core.ExternalIPPool{
+func TestExternalIPClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewExternalIPClient(newRESTClient(h))
+	ctx := context.Background()
+
+	_, err := h.Clients.CoreV0().ExternalIPPool().Create(ctx, &core.ExternalIPPool{
 		Meta: meta.Meta{
 			ID:   eippoolID,
-			Name: "test pool",
+			Name: "test-pool",
 		},
 		Spec: core.ExternalIPPoolSpec{
 			IPv4CIDR:       "192.168.10.0/24",
@@ -34,15 +37,13 @@ func TestExternalIPCreate(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	client := NewExternalIPClient("http", "localhost", 8080)
-
-	eip, err := client.Create(&core.ExternalIP{
+	eip, err := client.Create(ctx, &core.ExternalIP{
 		Meta: meta.Meta{
 			ID:   eipID,
-			Name: "TEST0",
+			Name: "test-eip",
 		},
 		Spec: core.ExternalIPSpec{
-			PoolID:      pool.ID,
+			PoolID:      eippoolID,
 			IPv4Address: "192.168.10.1",
 			IPv4Prefix:  24,
 		},
@@ -50,61 +51,47 @@ func TestExternalIPCreate(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if eip.UID == "" || eip.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", eip.UID, eip.Generation)
+	}
 
-	buf, _ := json.Marshal(eip)
-	log.Println(string(buf))
-}
-
-func TestExternalIPList(t *testing.T) {
-	client := NewExternalIPClient("http", "localhost", 8080)
-
-	eipList, err := client.List()
+	eip, err = client.Get(ctx, eipID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if eip.Spec.IPv4Address != "192.168.10.1" {
+		t.Fatalf("unexpected ipv4Address: %s", eip.Spec.IPv4Address)
+	}
 
-	buf, _ := json.MarshalIndent(eipList, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestExternalIPGet(t *testing.T) {
-	client := NewExternalIPClient("http", "localhost", 8080)
-
-	eip, err := client.Get(eipID)
+	list, err := client.List(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(list) != 1 || list[0].ID != eipID {
+		t.Fatalf("unexpected list: %v", list)
+	}
 
-	buf, _ := json.MarshalIndent(eip, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestExternalIPUpdate(t *testing.T) {
-	client := NewExternalIPClient("http", "localhost", 8080)
-
-	eip, err := client.Update(&core.ExternalIP{
-		Meta: meta.Meta{
-			Name: "TEST00-changed",
-			ID:   eipID,
-		},
-	})
+	eip.Name = "test-eip-updated"
+	eip, err = client.Update(ctx, eip)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if eip.Name != "test-eip-updated" {
+		t.Fatalf("unexpected name: %s", eip.Name)
+	}
 
-	buf, _ := json.Marshal(eip)
-	log.Println(string(buf))
-
-}
-
-func TestExternalIPDelete(t *testing.T) {
-	client := NewExternalIPClient("http", "localhost", 8080)
-
-	err := client.Delete(eipID)
-	if err != nil {
+	if err := client.Delete(ctx, eipID); err != nil {
 		t.Fatal(err)
 	}
+	h.WaitForNotFound("externalip", func(ctx context.Context) error {
+		_, err := client.Get(ctx, eipID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
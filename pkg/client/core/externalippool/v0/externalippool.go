@@ -1,20 +1,16 @@
 package v0
 
 import (
-	"encoding/json"
-	"fmt"
-	"path/filepath"
+	"context"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type ExternalIPPoolClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
 type ExternalIPPoolResponse struct {
@@ -33,117 +29,58 @@ type ExternalIPPoolListResponse struct {
 	} `json:"data"`
 }
 
-const (
-	basePathFormat = "api/v0/externalippools"
-)
-
-func NewExternalIPPoolClient(scheme, apiServerAddress string, apiServerPort int32) *ExternalIPPoolClient {
+func NewExternalIPPoolClient(client *rest.Client) *ExternalIPPoolClient {
 	return &ExternalIPPoolClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accepted":     "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *ExternalIPPoolClient) Get(eippoolID string) (*core.ExternalIPPool, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(eippoolID))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
+func (c *ExternalIPPoolClient) Get(ctx context.Context, eippoolID string) (*core.ExternalIPPool, error) {
 	eippoolResp := ExternalIPPoolResponse{}
-	err = json.Unmarshal(body, &eippoolResp)
-	if err != nil {
+	if err := c.client.Get(ctx, c.getPath(eippoolID), nil, &eippoolResp); err != nil {
 		return nil, err
 	}
 
 	return &eippoolResp.Data.ExternalIPPool, nil
 }
 
-func (c *ExternalIPPoolClient) List() ([]*core.ExternalIPPool, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(""))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
+func (c *ExternalIPPoolClient) List(ctx context.Context) ([]*core.ExternalIPPool, error) {
 	eippoolResp := ExternalIPPoolListResponse{}
-	err = json.Unmarshal(body, &eippoolResp)
-	if err != nil {
+	if err := c.client.Get(ctx, c.getPath(), nil, &eippoolResp); err != nil {
 		return nil, err
 	}
+
 	return eippoolResp.Data.ExternalIPPoolList, nil
 }
 
-func (c *ExternalIPPoolClient) Create(eippool *core.ExternalIPPool) (*core.ExternalIPPool, error) {
-	body, err := json.Marshal(eippool)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(""))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
+func (c *ExternalIPPoolClient) Create(ctx context.Context, eippool *core.ExternalIPPool) (*core.ExternalIPPool, error) {
 	eippoolResp := ExternalIPPoolResponse{}
-	err = json.Unmarshal(body, &eippoolResp)
-	if err != nil {
+	if err := c.client.Post(ctx, c.getPath(), eippool, &eippoolResp); err != nil {
 		return nil, err
 	}
 
-	if resp.IsError() {
-		return nil, fmt.Errorf("error: %+v", eippoolResp.Error)
-	}
-
 	return &eippoolResp.Data.ExternalIPPool, nil
 }
 
-func (c *ExternalIPPoolClient) Update(eippool *core.ExternalIPPool) (*core.ExternalIPPool, error) {
-	body, err := json.Marshal(eippool)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(eippool.ID))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
+func (c *ExternalIPPoolClient) Update(ctx context.Context, eippool *core.ExternalIPPool) (*core.ExternalIPPool, error) {
 	eippoolResp := ExternalIPPoolResponse{}
-	err = json.Unmarshal(body, &eippoolResp)
-	if err != nil {
+	if err := c.client.Put(ctx, c.getPath(eippool.ID), eippool, &eippoolResp); err != nil {
 		return nil, err
 	}
 
 	return &eippoolResp.Data.ExternalIPPool, nil
 }
 
-func (c *ExternalIPPoolClient) Delete(eippoolID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(eippoolID))
-	if err != nil {
-		return err
-	}
+func (c *ExternalIPPoolClient) Delete(ctx context.Context, eippoolID string) error {
+	return c.client.Delete(ctx, c.getPath(eippoolID), nil)
+}
 
-	return nil
+func (c *ExternalIPPoolClient) DeleteWithPropagation(ctx context.Context, eippoolID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(eippoolID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
 }
 
-func (c *ExternalIPPoolClient) getPath(path string) string {
-	return fmt.Sprintf("%s://%s",
-		c.scheme,
-		filepath.Join(
-			fmt.Sprintf("%s:%d",
-				c.apiServerAddress,
-				c.apiServerPort,
-			),
-			basePathFormat,
-			path))
+func (c *ExternalIPPoolClient) getPath(eippoolID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "externalippools"}, eippoolID...)...)
 }
@@ -1,27 +1,27 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
 	"testing"
 
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
+	v0 "github.com/ophum/humstack/pkg/client/core/externalippool/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
-const (
-	eippoolID = "test-eippool-00"
-)
-
-func TestExternalIPPoolCreate(t *testing.T) {
+const eippoolID = "test-eippool-00"
 
-	client := NewExternalIPPoolClient("http", "localhost", 8080)
+func TestExternalIPPoolClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewExternalIPPoolClient(newRESTClient(h))
+	ctx := context.Background()
 
-	eippool, err := client.Create(&core.ExternalIPPool{
+	pool, err := client.Create(ctx, &core.ExternalIPPool{
 		Meta: meta.Meta{
 			ID:   eippoolID,
-			Name: "TEST0",
+			Name: "test-pool",
 		},
 		Spec: core.ExternalIPPoolSpec{
 			IPv4CIDR:       "192.168.10.0/24",
@@ -33,61 +33,47 @@ func TestExternalIPPoolCreate(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if pool.UID == "" || pool.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", pool.UID, pool.Generation)
+	}
 
-	buf, _ := json.Marshal(eippool)
-	log.Println(string(buf))
-}
-
-func TestExternalIPPoolList(t *testing.T) {
-	client := NewExternalIPPoolClient("http", "localhost", 8080)
-
-	eippoolList, err := client.List()
+	pool, err = client.Get(ctx, eippoolID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if pool.Spec.IPv4CIDR != "192.168.10.0/24" {
+		t.Fatalf("unexpected ipv4CIDR: %s", pool.Spec.IPv4CIDR)
+	}
 
-	buf, _ := json.MarshalIndent(eippoolList, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestExternalIPPoolGet(t *testing.T) {
-	client := NewExternalIPPoolClient("http", "localhost", 8080)
-
-	eippool, err := client.Get(eippoolID)
+	list, err := client.List(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(list) != 1 || list[0].ID != eippoolID {
+		t.Fatalf("unexpected list: %v", list)
+	}
 
-	buf, _ := json.MarshalIndent(eippool, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestExternalIPPoolUpdate(t *testing.T) {
-	client := NewExternalIPPoolClient("http", "localhost", 8080)
-
-	eippool, err := client.Update(&core.ExternalIPPool{
-		Meta: meta.Meta{
-			Name: "TEST00-changed",
-			ID:   eippoolID,
-		},
-	})
+	pool.Spec.DefaultGateway = "192.168.10.1"
+	pool, err = client.Update(ctx, pool)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if pool.Spec.DefaultGateway != "192.168.10.1" || pool.Generation != 2 {
+		t.Fatalf("unexpected pool: defaultGateway=%s generation=%d", pool.Spec.DefaultGateway, pool.Generation)
+	}
 
-	buf, _ := json.Marshal(eippool)
-	log.Println(string(buf))
-
-}
-
-func TestExternalIPPoolDelete(t *testing.T) {
-	client := NewExternalIPPoolClient("http", "localhost", 8080)
-
-	err := client.Delete(eippoolID)
-	if err != nil {
+	if err := client.Delete(ctx, eippoolID); err != nil {
 		t.Fatal(err)
 	}
+	h.WaitForNotFound("externalippool", func(ctx context.Context) error {
+		_, err := client.Get(ctx, eippoolID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
@@ -1,21 +1,16 @@
 package v0
 
 import (
-	"encoding/json"
-	"fmt"
-	"path/filepath"
+	"context"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type GroupClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
 type GroupResponse struct {
@@ -34,121 +29,58 @@ type GroupListResponse struct {
 	} `json:"data"`
 }
 
-const (
-	basePath = "api/v0/groups"
-)
-
-func NewGroupClient(scheme, apiServerAddress string, apiServerPort int32) *GroupClient {
+func NewGroupClient(client *rest.Client) *GroupClient {
 	return &GroupClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accepted":     "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *GroupClient) Get(groupID string) (*core.Group, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
+func (c *GroupClient) Get(ctx context.Context, groupID string) (*core.Group, error) {
 	groupResp := GroupResponse{}
-	err = json.Unmarshal(body, &groupResp)
-	if err != nil {
+	if err := c.client.Get(ctx, c.getPath(groupID), nil, &groupResp); err != nil {
 		return nil, err
 	}
 
 	return &groupResp.Data.Group, nil
 }
 
-func (c *GroupClient) List() ([]*core.Group, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(""))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
+func (c *GroupClient) List(ctx context.Context) ([]*core.Group, error) {
 	groupResp := GroupListResponse{}
-	err = json.Unmarshal(body, &groupResp)
-	if err != nil {
+	if err := c.client.Get(ctx, c.getPath(), nil, &groupResp); err != nil {
 		return nil, err
 	}
+
 	return groupResp.Data.GroupList, nil
 }
 
-func (c *GroupClient) Create(group *core.Group) (*core.Group, error) {
-	body, err := json.Marshal(group)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(""))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
+func (c *GroupClient) Create(ctx context.Context, group *core.Group) (*core.Group, error) {
 	groupResp := GroupResponse{}
-	err = json.Unmarshal(body, &groupResp)
-	if err != nil {
+	if err := c.client.Post(ctx, c.getPath(), group, &groupResp); err != nil {
 		return nil, err
 	}
 
-	if resp.IsError() {
-		return nil, fmt.Errorf("error: %+v", groupResp.Error)
-	}
-
 	return &groupResp.Data.Group, nil
 }
 
-func (c *GroupClient) Update(group *core.Group) (*core.Group, error) {
-	body, err := json.Marshal(group)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(group.ID))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
+func (c *GroupClient) Update(ctx context.Context, group *core.Group) (*core.Group, error) {
 	groupResp := GroupResponse{}
-	err = json.Unmarshal(body, &groupResp)
-	if err != nil {
+	if err := c.client.Put(ctx, c.getPath(group.ID), group, &groupResp); err != nil {
 		return nil, err
 	}
 
 	return &groupResp.Data.Group, nil
 }
 
-func (c *GroupClient) Delete(groupID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID))
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (c *GroupClient) Delete(ctx context.Context, groupID string) error {
+	return c.client.Delete(ctx, c.getPath(groupID), nil)
 }
 
-func (c *GroupClient) DeleteState(groupID string) error {
-	group, err := c.Get(groupID)
-	if err != nil {
-		return err
-	}
-
-	group.DeleteState = meta.DeleteStateDelete
-
-	_, err = c.Update(group)
-	return err
+func (c *GroupClient) DeleteWithPropagation(ctx context.Context, groupID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(groupID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
 }
 
-func (c *GroupClient) getPath(path string) string {
-	return fmt.Sprintf("%s://%s", c.scheme, filepath.Join(fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort), basePath, path))
+func (c *GroupClient) getPath(groupID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "groups"}, groupID...)...)
 }
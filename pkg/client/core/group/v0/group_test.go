@@ -1,87 +1,77 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
 	"testing"
 
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
+	v0 "github.com/ophum/humstack/pkg/client/core/group/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
-const (
-	groupID = "test-group-00"
-)
+const groupID = "test-group-00"
 
-func TestGroupCreate(t *testing.T) {
-	client := NewGroupClient("http", "localhost", 8080)
+func TestGroupClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewGroupClient(newRESTClient(h))
+	ctx := context.Background()
 
-	group, err := client.Create(&core.Group{
+	group, err := client.Create(ctx, &core.Group{
 		Meta: meta.Meta{
 			ID:   groupID,
-			Name: "TEST0",
+			Name: "test-group",
 		},
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if group.UID == "" || group.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", group.UID, group.Generation)
+	}
 
-	buf, _ := json.Marshal(group)
-	log.Println(string(buf))
-
-}
-
-func TestGroupList(t *testing.T) {
-	client := NewGroupClient("http", "localhost", 8080)
+	if _, err := client.Create(ctx, group); !meta.IsConflict(err) {
+		t.Fatalf("expected conflict, but got %v", err)
+	}
 
-	groupList, err := client.List()
+	group, err = client.Get(ctx, groupID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if group.Name != "test-group" {
+		t.Fatalf("unexpected name: %s", group.Name)
+	}
 
-	buf, _ := json.MarshalIndent(groupList, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestGroupGet(t *testing.T) {
-	client := NewGroupClient("http", "localhost", 8080)
-
-	group, err := client.Get(groupID)
+	list, err := client.List(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(list) != 1 || list[0].ID != groupID {
+		t.Fatalf("unexpected list: %v", list)
+	}
 
-	buf, _ := json.MarshalIndent(group, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestGroupUpdate(t *testing.T) {
-	client := NewGroupClient("http", "localhost", 8080)
-
-	group, err := client.Update(&core.Group{
-		Meta: meta.Meta{
-			Name: "TEST00-changed",
-			ID:   groupID,
-		},
-	})
+	group.Name = "test-group-updated"
+	group, err = client.Update(ctx, group)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if group.Name != "test-group-updated" {
+		t.Fatalf("unexpected name: %s", group.Name)
+	}
 
-	buf, _ := json.Marshal(group)
-	log.Println(string(buf))
-
-}
-
-func TestGroupDelete(t *testing.T) {
-	client := NewGroupClient("http", "localhost", 8080)
-
-	err := client.Delete(groupID)
-	if err != nil {
+	if err := client.Delete(ctx, groupID); err != nil {
 		t.Fatal(err)
 	}
+	h.WaitForNotFound("group", func(ctx context.Context) error {
+		_, err := client.Get(ctx, groupID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
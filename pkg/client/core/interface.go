@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+// CoreV0Interface はcorev0のクライアント
+// テストではpkg/client/fakeの実装に差し替えられる
+type CoreV0Interface interface {
+	Namespace() NamespaceInterface
+	Group() GroupInterface
+	ExternalIPPool() ExternalIPPoolInterface
+	ExternalIP() ExternalIPInterface
+	Network() NetworkInterface
+	Event() EventInterface
+	Lease() LeaseInterface
+}
+
+type NamespaceInterface interface {
+	Get(ctx context.Context, groupID, namespaceID string) (*core.Namespace, error)
+	List(ctx context.Context, groupID string) ([]*core.Namespace, error)
+	Create(ctx context.Context, namespace *core.Namespace) (*core.Namespace, error)
+	Update(ctx context.Context, namespace *core.Namespace) (*core.Namespace, error)
+	Delete(ctx context.Context, groupID, namespaceID string) error
+	DeleteWithPropagation(ctx context.Context, groupID, namespaceID string, policy meta.DeletionPropagation) error
+}
+
+type GroupInterface interface {
+	Get(ctx context.Context, groupID string) (*core.Group, error)
+	List(ctx context.Context) ([]*core.Group, error)
+	Create(ctx context.Context, group *core.Group) (*core.Group, error)
+	Update(ctx context.Context, group *core.Group) (*core.Group, error)
+	Delete(ctx context.Context, groupID string) error
+	DeleteWithPropagation(ctx context.Context, groupID string, policy meta.DeletionPropagation) error
+}
+
+type ExternalIPPoolInterface interface {
+	Get(ctx context.Context, eippoolID string) (*core.ExternalIPPool, error)
+	List(ctx context.Context) ([]*core.ExternalIPPool, error)
+	Create(ctx context.Context, eippool *core.ExternalIPPool) (*core.ExternalIPPool, error)
+	Update(ctx context.Context, eippool *core.ExternalIPPool) (*core.ExternalIPPool, error)
+	Delete(ctx context.Context, eippoolID string) error
+	DeleteWithPropagation(ctx context.Context, eippoolID string, policy meta.DeletionPropagation) error
+}
+
+type ExternalIPInterface interface {
+	Get(ctx context.Context, eipID string) (*core.ExternalIP, error)
+	List(ctx context.Context) ([]*core.ExternalIP, error)
+	Create(ctx context.Context, eip *core.ExternalIP) (*core.ExternalIP, error)
+	Update(ctx context.Context, eip *core.ExternalIP) (*core.ExternalIP, error)
+	Delete(ctx context.Context, eipID string) error
+	DeleteWithPropagation(ctx context.Context, eipID string, policy meta.DeletionPropagation) error
+}
+
+type NetworkInterface interface {
+	Get(ctx context.Context, groupID, namespaceID, networkID string) (*core.Network, error)
+	List(ctx context.Context, groupID, namespaceID string) ([]*core.Network, error)
+	Create(ctx context.Context, network *core.Network) (*core.Network, error)
+	Update(ctx context.Context, network *core.Network) (*core.Network, error)
+	Delete(ctx context.Context, groupID, namespaceID, networkID string) error
+	DeleteWithPropagation(ctx context.Context, groupID, namespaceID, networkID string, policy meta.DeletionPropagation) error
+}
+
+type EventInterface interface {
+	Get(ctx context.Context, eventID string) (*core.Event, error)
+	List(ctx context.Context) ([]*core.Event, error)
+	ListByObject(ctx context.Context, object meta.ObjectReference) ([]*core.Event, error)
+	Create(ctx context.Context, event *core.Event) (*core.Event, error)
+	Update(ctx context.Context, event *core.Event) (*core.Event, error)
+	Delete(ctx context.Context, eventID string) error
+}
+
+type LeaseInterface interface {
+	Get(ctx context.Context, leaseID string) (*core.Lease, error)
+	List(ctx context.Context) ([]*core.Lease, error)
+	Create(ctx context.Context, lease *core.Lease) (*core.Lease, error)
+	Update(ctx context.Context, lease *core.Lease) (*core.Lease, error)
+	Delete(ctx context.Context, leaseID string) error
+}
+
+var _ CoreV0Interface = &CoreV0Clients{}
@@ -0,0 +1,78 @@
+package v0
+
+import (
+	"context"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+)
+
+type LeaseClient struct {
+	client *rest.Client
+}
+
+type LeaseResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		Lease core.Lease `json:"lease"`
+	} `json:"data"`
+}
+
+type LeaseListResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		LeaseList []*core.Lease `json:"leases"`
+	} `json:"data"`
+}
+
+func NewLeaseClient(client *rest.Client) *LeaseClient {
+	return &LeaseClient{
+		client: client,
+	}
+}
+
+func (c *LeaseClient) Get(ctx context.Context, leaseID string) (*core.Lease, error) {
+	leaseResp := LeaseResponse{}
+	if err := c.client.Get(ctx, c.getPath(leaseID), nil, &leaseResp); err != nil {
+		return nil, err
+	}
+
+	return &leaseResp.Data.Lease, nil
+}
+
+func (c *LeaseClient) List(ctx context.Context) ([]*core.Lease, error) {
+	leaseResp := LeaseListResponse{}
+	if err := c.client.Get(ctx, c.getPath(), nil, &leaseResp); err != nil {
+		return nil, err
+	}
+
+	return leaseResp.Data.LeaseList, nil
+}
+
+func (c *LeaseClient) Create(ctx context.Context, lease *core.Lease) (*core.Lease, error) {
+	leaseResp := LeaseResponse{}
+	if err := c.client.Post(ctx, c.getPath(), lease, &leaseResp); err != nil {
+		return nil, err
+	}
+
+	return &leaseResp.Data.Lease, nil
+}
+
+func (c *LeaseClient) Update(ctx context.Context, lease *core.Lease) (*core.Lease, error) {
+	leaseResp := LeaseResponse{}
+	if err := c.client.Put(ctx, c.getPath(lease.ID), lease, &leaseResp); err != nil {
+		return nil, err
+	}
+
+	return &leaseResp.Data.Lease, nil
+}
+
+func (c *LeaseClient) Delete(ctx context.Context, leaseID string) error {
+	return c.client.Delete(ctx, c.getPath(leaseID), nil)
+}
+
+func (c *LeaseClient) getPath(leaseID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "leases"}, leaseID...)...)
+}
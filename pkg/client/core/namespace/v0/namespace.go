@@ -1,21 +1,16 @@
 package v0
 
 import (
-	"encoding/json"
-	"fmt"
-	"path/filepath"
+	"context"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type NamespaceClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
 type NamespaceResponse struct {
@@ -34,129 +29,58 @@ type NamespaceListResponse struct {
 	} `json:"data"`
 }
 
-const (
-	basePathFormat = "api/v0/groups/%s/namespaces"
-)
-
-func NewNamespaceClient(scheme, apiServerAddress string, apiServerPort int32) *NamespaceClient {
+func NewNamespaceClient(client *rest.Client) *NamespaceClient {
 	return &NamespaceClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accepted":     "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *NamespaceClient) Get(groupID, namespaceID string) (*core.Namespace, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID))
-	if err != nil {
+func (c *NamespaceClient) Get(ctx context.Context, groupID, namespaceID string) (*core.Namespace, error) {
+	nsResp := NamespaceResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID, namespaceID), nil, &nsResp); err != nil {
 		return nil, err
 	}
-	body := resp.Body()
 
-	namespaceResp := NamespaceResponse{}
-	err = json.Unmarshal(body, &namespaceResp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &namespaceResp.Data.Namespace, nil
+	return &nsResp.Data.Namespace, nil
 }
 
-func (c *NamespaceClient) List(groupID string) ([]*core.Namespace, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, ""))
-	if err != nil {
+func (c *NamespaceClient) List(ctx context.Context, groupID string) ([]*core.Namespace, error) {
+	nsResp := NamespaceListResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID), nil, &nsResp); err != nil {
 		return nil, err
 	}
-	body := resp.Body()
 
-	namespaceResp := NamespaceListResponse{}
-	err = json.Unmarshal(body, &namespaceResp)
-	if err != nil {
-		return nil, err
-	}
-	return namespaceResp.Data.NamespaceList, nil
+	return nsResp.Data.NamespaceList, nil
 }
 
-func (c *NamespaceClient) Create(namespace *core.Namespace) (*core.Namespace, error) {
-	body, err := json.Marshal(namespace)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(namespace.Group, ""))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
-	namespaceResp := NamespaceResponse{}
-	err = json.Unmarshal(body, &namespaceResp)
-	if err != nil {
+func (c *NamespaceClient) Create(ctx context.Context, namespace *core.Namespace) (*core.Namespace, error) {
+	nsResp := NamespaceResponse{}
+	if err := c.client.Post(ctx, c.getPath(namespace.Group), namespace, &nsResp); err != nil {
 		return nil, err
 	}
 
-	if resp.IsError() {
-		return nil, fmt.Errorf("error: %+v", namespaceResp.Error)
-	}
-
-	return &namespaceResp.Data.Namespace, nil
+	return &nsResp.Data.Namespace, nil
 }
 
-func (c *NamespaceClient) Update(namespace *core.Namespace) (*core.Namespace, error) {
-	body, err := json.Marshal(namespace)
-	if err != nil {
+func (c *NamespaceClient) Update(ctx context.Context, namespace *core.Namespace) (*core.Namespace, error) {
+	nsResp := NamespaceResponse{}
+	if err := c.client.Put(ctx, c.getPath(namespace.Group, namespace.ID), namespace, &nsResp); err != nil {
 		return nil, err
 	}
 
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(namespace.Group, namespace.ID))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
-	namespaceResp := NamespaceResponse{}
-	err = json.Unmarshal(body, &namespaceResp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &namespaceResp.Data.Namespace, nil
+	return &nsResp.Data.Namespace, nil
 }
 
-func (c *NamespaceClient) Delete(groupID, namespaceID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID))
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (c *NamespaceClient) Delete(ctx context.Context, groupID, namespaceID string) error {
+	return c.client.Delete(ctx, c.getPath(groupID, namespaceID), nil)
 }
 
-func (c *NamespaceClient) DeleteState(groupID, namespaceID string) error {
-	ns, err := c.Get(groupID, namespaceID)
-	if err != nil {
-		return err
-	}
-
-	ns.DeleteState = meta.DeleteStateDelete
-
-	_, err = c.Update(ns)
-	return err
+func (c *NamespaceClient) DeleteWithPropagation(ctx context.Context, groupID, namespaceID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(groupID, namespaceID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
 }
 
-func (c *NamespaceClient) getPath(groupID, path string) string {
-	return fmt.Sprintf("%s://%s",
-		c.scheme,
-		filepath.Join(
-			fmt.Sprintf("%s:%d",
-				c.apiServerAddress,
-				c.apiServerPort,
-			),
-			fmt.Sprintf(basePathFormat, groupID),
-			path))
+func (c *NamespaceClient) getPath(groupID string, namespaceID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "groups", groupID, "namespaces"}, namespaceID...)...)
 }
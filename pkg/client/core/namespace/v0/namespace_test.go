@@ -1,24 +1,27 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
 	"testing"
 
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
-	grv0 "github.com/ophum/humstack/pkg/client/core/group/v0"
+	v0 "github.com/ophum/humstack/pkg/client/core/namespace/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
 const (
-	groupID     = "test-gr"
+	groupID     = "test-group-00"
 	namespaceID = "test-namespace-00"
 )
 
-func TestNamespaceCreate(t *testing.T) {
-	grClient := grv0.NewGroupClient("http", "localhost", 8080)
-	gr, err := grClient.Create(&core.Group{
+func TestNamespaceClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewNamespaceClient(newRESTClient(h))
+	ctx := context.Background()
+
+	_, err := h.Clients.CoreV0().Group().Create(ctx, &core.Group{
 		Meta: meta.Meta{
 			ID:   groupID,
 			Name: "test-group",
@@ -27,74 +30,62 @@ func TestNamespaceCreate(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	client := NewNamespaceClient("http", "localhost", 8080)
 
-	namespace, err := client.Create(&core.Namespace{
+	ns, err := client.Create(ctx, &core.Namespace{
 		Meta: meta.Meta{
 			ID:    namespaceID,
-			Group: gr.ID,
-			Name:  "TEST0",
+			Name:  "test-namespace",
+			Group: groupID,
 		},
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if ns.UID == "" || ns.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", ns.UID, ns.Generation)
+	}
 
-	buf, _ := json.Marshal(namespace)
-	log.Println(string(buf))
-
-}
-
-func TestNamespaceList(t *testing.T) {
-	client := NewNamespaceClient("http", "localhost", 8080)
+	if _, err := client.Create(ctx, ns); !meta.IsConflict(err) {
+		t.Fatalf("expected conflict, but got %v", err)
+	}
 
-	namespaceList, err := client.List(groupID)
+	ns, err = client.Get(ctx, groupID, namespaceID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if ns.Name != "test-namespace" {
+		t.Fatalf("unexpected name: %s", ns.Name)
+	}
 
-	buf, _ := json.MarshalIndent(namespaceList, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestNamespaceGet(t *testing.T) {
-	client := NewNamespaceClient("http", "localhost", 8080)
-
-	namespace, err := client.Get(groupID, namespaceID)
+	list, err := client.List(ctx, groupID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(list) != 1 || list[0].ID != namespaceID {
+		t.Fatalf("unexpected list: %v", list)
+	}
 
-	buf, _ := json.MarshalIndent(namespace, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestNamespaceUpdate(t *testing.T) {
-	client := NewNamespaceClient("http", "localhost", 8080)
-
-	namespace, err := client.Update(&core.Namespace{
-		Meta: meta.Meta{
-			Name: "TEST00-changed",
-			ID:   namespaceID,
-		},
-	})
+	ns.Name = "test-namespace-updated"
+	ns, err = client.Update(ctx, ns)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if ns.Name != "test-namespace-updated" {
+		t.Fatalf("unexpected name: %s", ns.Name)
+	}
 
-	buf, _ := json.Marshal(namespace)
-	log.Println(string(buf))
-
-}
-
-func TestNamespaceDelete(t *testing.T) {
-	client := NewNamespaceClient("http", "localhost", 8080)
-
-	err := client.Delete(groupID, namespaceID)
-	if err != nil {
+	if err := client.Delete(ctx, groupID, namespaceID); err != nil {
 		t.Fatal(err)
 	}
+	h.WaitForNotFound("namespace", func(ctx context.Context) error {
+		_, err := client.Get(ctx, groupID, namespaceID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
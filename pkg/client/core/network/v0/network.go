@@ -1,21 +1,16 @@
 package v0
 
 import (
-	"encoding/json"
-	"fmt"
-	"path/filepath"
+	"context"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type NetworkClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
 type NetworkResponse struct {
@@ -34,127 +29,58 @@ type NetworkListResponse struct {
 	} `json:"data"`
 }
 
-const (
-	basePathFormat = "api/v0/groups/%s/namespaces/%s/networks"
-)
-
-func NewNetworkClient(scheme, apiServerAddress string, apiServerPort int32) *NetworkClient {
+func NewNetworkClient(client *rest.Client) *NetworkClient {
 	return &NetworkClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accepted":     "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *NetworkClient) Get(groupID, namespaceID, networkID string) (*core.Network, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, networkID))
-	if err != nil {
+func (c *NetworkClient) Get(ctx context.Context, groupID, namespaceID, networkID string) (*core.Network, error) {
+	netResp := NetworkResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID, namespaceID, networkID), nil, &netResp); err != nil {
 		return nil, err
 	}
-	body := resp.Body()
 
-	nodeResp := NetworkResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &nodeResp.Data.Network, nil
+	return &netResp.Data.Network, nil
 }
 
-func (c *NetworkClient) List(groupID, namespaceID string) ([]*core.Network, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, ""))
-	if err != nil {
+func (c *NetworkClient) List(ctx context.Context, groupID, namespaceID string) ([]*core.Network, error) {
+	netResp := NetworkListResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID, namespaceID), nil, &netResp); err != nil {
 		return nil, err
 	}
-	body := resp.Body()
 
-	nodeResp := NetworkListResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
-		return nil, err
-	}
-	return nodeResp.Data.NetworkList, nil
+	return netResp.Data.NetworkList, nil
 }
 
-func (c *NetworkClient) Create(network *core.Network) (*core.Network, error) {
-	body, err := json.Marshal(network)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(network.Group, network.Namespace, ""))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
-	nodeResp := NetworkResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
+func (c *NetworkClient) Create(ctx context.Context, network *core.Network) (*core.Network, error) {
+	netResp := NetworkResponse{}
+	if err := c.client.Post(ctx, c.getPath(network.Group, network.Namespace), network, &netResp); err != nil {
 		return nil, err
 	}
 
-	if resp.IsError() {
-		return nil, fmt.Errorf("error: %+v", nodeResp.Error)
-	}
-
-	return &nodeResp.Data.Network, nil
+	return &netResp.Data.Network, nil
 }
 
-func (c *NetworkClient) Update(network *core.Network) (*core.Network, error) {
-	body, err := json.Marshal(network)
-	if err != nil {
+func (c *NetworkClient) Update(ctx context.Context, network *core.Network) (*core.Network, error) {
+	netResp := NetworkResponse{}
+	if err := c.client.Put(ctx, c.getPath(network.Group, network.Namespace, network.ID), network, &netResp); err != nil {
 		return nil, err
 	}
 
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(network.Group, network.Namespace, network.ID))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
-	nodeResp := NetworkResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &nodeResp.Data.Network, nil
+	return &netResp.Data.Network, nil
 }
 
-func (c *NetworkClient) Delete(groupID, namespaceID, networkID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, networkID))
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (c *NetworkClient) Delete(ctx context.Context, groupID, namespaceID, networkID string) error {
+	return c.client.Delete(ctx, c.getPath(groupID, namespaceID, networkID), nil)
 }
 
-func (c *NetworkClient) DeleteState(groupID, namespaceID, networkID string) error {
-	net, err := c.Get(groupID, namespaceID, networkID)
-	if err != nil {
-		return err
-	}
-
-	net.DeleteState = meta.DeleteStateDelete
-
-	_, err = c.Update(net)
-	return err
+func (c *NetworkClient) DeleteWithPropagation(ctx context.Context, groupID, namespaceID, networkID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(groupID, namespaceID, networkID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
 }
 
-func (c *NetworkClient) getPath(groupID, namespaceID, networkID string) string {
-	return fmt.Sprintf("%s://%s",
-		c.scheme,
-		filepath.Join(
-			fmt.Sprintf("%s:%d",
-				c.apiServerAddress, c.apiServerPort),
-			fmt.Sprintf(basePathFormat, groupID, namespaceID),
-			networkID))
+func (c *NetworkClient) getPath(groupID, namespaceID string, networkID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "groups", groupID, "namespaces", namespaceID, "networks"}, networkID...)...)
 }
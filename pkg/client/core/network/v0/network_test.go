@@ -1,16 +1,15 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
 	"testing"
 
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
-	grv0 "github.com/ophum/humstack/pkg/client/core/group/v0"
-	nsv0 "github.com/ophum/humstack/pkg/client/core/namespace/v0"
+	v0 "github.com/ophum/humstack/pkg/client/core/network/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
 const (
@@ -19,108 +18,87 @@ const (
 	networkID   = "test-network-00"
 )
 
-func TestNetworkCreate(t *testing.T) {
-	grClient := grv0.NewGroupClient("http", "localhost", 8080)
-	_, err := grClient.Create(&core.Group{
-		Meta: meta.Meta{
-			ID:   groupID,
-			Name: "test-gr",
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
+func TestNetworkClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewNetworkClient(newRESTClient(h))
+	ctx := context.Background()
 
-	nsClient := nsv0.NewNamespaceClient("http", "localhost", 8080)
-	_, err = nsClient.Create(&core.Namespace{
-		Meta: meta.Meta{
-			ID:    namespaceID,
-			Name:  "test-ns",
-			Group: groupID,
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
+	h.CreateNamespace(groupID, namespaceID)
 
-	client := NewNetworkClient("http", "localhost", 8080)
-
-	net, err := client.Create(&system.Network{
+	net, err := client.Create(ctx, &core.Network{
 		Meta: meta.Meta{
 			ID:        networkID,
 			Name:      "test-network",
 			Namespace: namespaceID,
 			Group:     groupID,
-			Annotations: map[string]string{
-				"networkv0/network_type": "VXLAN",
-			},
 		},
-		Spec: system.NetworkSpec{
-			IPv4CIDR: "10.0.0.0/24",
-			ID:       "100",
+		Spec: core.NetworkSpec{
+			Template: system.NodeNetwork{
+				Meta: meta.Meta{
+					Annotations: map[string]string{
+						"networkv0/network_type": "Bridge",
+					},
+				},
+				Spec: system.NodeNetworkSpec{
+					ID:       "100",
+					IPv4CIDR: "10.0.0.0/24",
+				},
+			},
 		},
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if net.UID == "" || net.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", net.UID, net.Generation)
+	}
 
-	buf, _ := json.Marshal(net)
-	log.Println(string(buf))
-
-}
-
-func TestNetworkList(t *testing.T) {
-	client := NewNetworkClient("http", "localhost", 8080)
+	// network agentが各ノードのnodenetworkを作成する
+	h.WaitFor("nodenetwork to be created", func(ctx context.Context) (bool, error) {
+		list, err := h.Clients.SystemV0().NodeNetwork().List(ctx, groupID, namespaceID)
+		if err != nil {
+			return false, err
+		}
+		return len(list) == len(h.NodeNames), nil
+	})
 
-	netList, err := client.List(groupID, namespaceID)
+	net, err = client.Get(ctx, groupID, namespaceID, networkID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if net.Spec.Template.Spec.IPv4CIDR != "10.0.0.0/24" {
+		t.Fatalf("unexpected ipv4CIDR: %s", net.Spec.Template.Spec.IPv4CIDR)
+	}
 
-	buf, _ := json.MarshalIndent(netList, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestNetworkGet(t *testing.T) {
-	client := NewNetworkClient("http", "localhost", 8080)
-
-	net, err := client.Get(groupID, namespaceID, networkID)
+	list, err := client.List(ctx, groupID, namespaceID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(list) != 1 || list[0].ID != networkID {
+		t.Fatalf("unexpected list: %v", list)
+	}
 
-	buf, _ := json.MarshalIndent(net, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestNetworkUpdate(t *testing.T) {
-	client := NewNetworkClient("http", "localhost", 8080)
-
-	net, err := client.Update(&system.Network{
-		Meta: meta.Meta{
-			ID:        networkID,
-			Name:      "test-network-changed1",
-			Namespace: namespaceID,
-			Group:     groupID,
-		},
-	})
+	net.Name = "test-network-updated"
+	net, err = client.Update(ctx, net)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if net.Name != "test-network-updated" {
+		t.Fatalf("unexpected name: %s", net.Name)
+	}
 
-	buf, _ := json.Marshal(net)
-	log.Println(string(buf))
-
-}
-
-func TestNetworkDelete(t *testing.T) {
-	client := NewNetworkClient("http", "localhost", 8080)
-
-	err := client.Delete(groupID, namespaceID, networkID)
-	if err != nil {
+	if err := client.Delete(ctx, groupID, namespaceID, networkID); err != nil {
 		t.Fatal(err)
 	}
+	h.WaitForNotFound("network", func(ctx context.Context) error {
+		_, err := client.Get(ctx, groupID, namespaceID, networkID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
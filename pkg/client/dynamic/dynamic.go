@@ -0,0 +1,163 @@
+// Package dynamic はschemeに登録されている全てのリソースをmeta.Objectとして扱うクライアント
+// apiTypeごとに処理を書かずにリソースを作成・更新・削除したい場合に使う
+package dynamic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/scheme"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+)
+
+// Interface はapiTypeを問わずリソースを操作する
+type Interface interface {
+	Get(ctx context.Context, ref meta.ObjectReference) (*meta.Object, error)
+	List(ctx context.Context, apiType meta.APIType, groupID, namespaceID string) ([]*meta.Object, error)
+	Create(ctx context.Context, obj *meta.Object) (*meta.Object, error)
+	Update(ctx context.Context, obj *meta.Object) (*meta.Object, error)
+	Delete(ctx context.Context, ref meta.ObjectReference, policy meta.DeletionPropagation) error
+}
+
+var _ Interface = &Client{}
+
+type Client struct {
+	client *rest.Client
+}
+
+// response はapiserverのレスポンス
+// dataにはKind(Listの場合はPlural)をキーとしてリソースが入っている
+type response struct {
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+func NewClient(client *rest.Client) *Client {
+	return &Client{
+		client: client,
+	}
+}
+
+func (c *Client) Get(ctx context.Context, ref meta.ObjectReference) (*meta.Object, error) {
+	r, err := scheme.Lookup(ref.APIType)
+	if err != nil {
+		return nil, err
+	}
+
+	res := response{}
+	if err := c.client.Get(ctx, getPath(r, ref.Group, ref.Namespace, ref.ID), nil, &res); err != nil {
+		return nil, err
+	}
+	return decode(r, res.Data[r.Kind()])
+}
+
+func (c *Client) List(ctx context.Context, apiType meta.APIType, groupID, namespaceID string) ([]*meta.Object, error) {
+	r, err := scheme.Lookup(apiType)
+	if err != nil {
+		return nil, err
+	}
+
+	res := response{}
+	if err := c.client.Get(ctx, getPath(r, groupID, namespaceID), nil, &res); err != nil {
+		return nil, err
+	}
+
+	rawList := []json.RawMessage{}
+	if raw, ok := res.Data[r.Plural]; ok {
+		if err := json.Unmarshal(raw, &rawList); err != nil {
+			return nil, err
+		}
+	}
+
+	list := make([]*meta.Object, 0, len(rawList))
+	for _, raw := range rawList {
+		obj, err := decode(r, raw)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, obj)
+	}
+	return list, nil
+}
+
+func (c *Client) Create(ctx context.Context, obj *meta.Object) (*meta.Object, error) {
+	r, v, err := fromObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	res := response{}
+	if err := c.client.Post(ctx, getPath(r, obj.Meta.Group, obj.Meta.Namespace), v, &res); err != nil {
+		return nil, err
+	}
+	return decode(r, res.Data[r.Kind()])
+}
+
+func (c *Client) Update(ctx context.Context, obj *meta.Object) (*meta.Object, error) {
+	r, v, err := fromObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// idは省略されている場合があるので埋めた後のものを使う
+	m, err := scheme.DefaultObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	res := response{}
+	if err := c.client.Put(ctx, getPath(r, m.Meta.Group, m.Meta.Namespace, m.Meta.ID), v, &res); err != nil {
+		return nil, err
+	}
+	return decode(r, res.Data[r.Kind()])
+}
+
+// Delete はrefのリソースを削除する
+// policyが空の場合はapiserverのデフォルト(Background)になる
+func (c *Client) Delete(ctx context.Context, ref meta.ObjectReference, policy meta.DeletionPropagation) error {
+	r, err := scheme.Lookup(ref.APIType)
+	if err != nil {
+		return err
+	}
+
+	var query url.Values
+	if policy != "" {
+		query = url.Values{
+			"propagationPolicy": []string{string(policy)},
+		}
+	}
+	return c.client.Delete(ctx, getPath(r, ref.Group, ref.Namespace, ref.ID), query)
+}
+
+func getPath(r *scheme.Resource, groupID, namespaceID string, id ...string) string {
+	elements := append([]string{"api", r.Version()}, r.PathElements(groupID, namespaceID, id...)...)
+	return rest.Path(elements...)
+}
+
+// fromObject はobjを送信するためにapiTypeの型に変換する
+func fromObject(obj *meta.Object) (*scheme.Resource, interface{}, error) {
+	r, err := scheme.Lookup(obj.Meta.APIType)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := scheme.FromObject(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, v, nil
+}
+
+// decode はレスポンスのリソースをapiTypeの型を経由してmeta.Objectにする
+func decode(r *scheme.Resource, raw json.RawMessage) (*meta.Object, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%s is not found in response", r.Kind())
+	}
+
+	v := r.New()
+	if err := json.Unmarshal(raw, v); err != nil {
+		return nil, err
+	}
+	return scheme.ToObject(v)
+}
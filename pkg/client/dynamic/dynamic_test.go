@@ -0,0 +1,105 @@
+package dynamic_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/scheme"
+	"github.com/ophum/humstack/pkg/api/system"
+	humtesting "github.com/ophum/humstack/pkg/testing"
+	"gopkg.in/yaml.v2"
+)
+
+const blockStorage = `
+meta:
+  apiType: systemv0/blockstorage
+  id: bs1
+  name: bs1
+  group: group1
+  namespace: ns1
+  annotations:
+    blockstoragev0/node_name: node1
+    blockstoragev0/type: Local
+spec:
+  requestSize: 1G
+  limitSize: 10G
+  from:
+    type: Empty
+`
+
+func TestDynamicClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := h.Clients.Dynamic()
+	ctx := context.Background()
+
+	h.CreateNamespace("group1", "ns1")
+
+	item := &meta.Object{}
+	if err := yaml.Unmarshal([]byte(blockStorage), item); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := client.Create(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Meta.UID == "" || obj.Meta.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", obj.Meta.UID, obj.Meta.Generation)
+	}
+
+	// 型付きのクライアントからも同じものが見える
+	h.WaitForBlockStorageState("group1", "ns1", "bs1", system.BlockStorageStateActive)
+
+	ref := meta.ObjectReference{
+		APIType:   meta.APITypeBlockStorageV0,
+		Group:     "group1",
+		Namespace: "ns1",
+		ID:        "bs1",
+	}
+	obj, err = client.Get(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := scheme.FromObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := v.(*system.BlockStorage)
+	if bs.Spec.LimitSize != "10G" || bs.Status.State != system.BlockStorageStateActive {
+		t.Fatalf("unexpected blockstorage: limitSize=%s state=%s", bs.Spec.LimitSize, bs.Status.State)
+	}
+
+	list, err := client.List(ctx, meta.APITypeBlockStorageV0, "group1", "ns1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Meta.ID != "bs1" {
+		t.Fatalf("unexpected list: %v", list)
+	}
+
+	bs.Spec.LimitSize = "20G"
+	obj, err = scheme.ToObject(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err = client.Update(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Meta.Generation != 2 {
+		t.Fatalf("unexpected generation: %d", obj.Meta.Generation)
+	}
+
+	if err := client.Delete(ctx, ref, meta.DeletionPropagationBackground); err != nil {
+		t.Fatal(err)
+	}
+	h.WaitForNotFound("blockstorage", func(ctx context.Context) error {
+		_, err := client.Get(ctx, ref)
+		return err
+	})
+
+	if _, err := client.Get(ctx, meta.ObjectReference{APIType: "systemv0/unknown", ID: "x"}); !scheme.IsUnknownAPIType(err) {
+		t.Fatalf("expected unknown apiType, but got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+// Package fake はapiserverを使わずにagentをテストするためのclient.Interfaceの実装
+// リソースはMemoryStoreに保存し、行われた操作をActionとして記録する
+package fake
+
+import (
+	"github.com/ophum/humstack/pkg/client"
+	clientcore "github.com/ophum/humstack/pkg/client/core"
+	clientsystem "github.com/ophum/humstack/pkg/client/system"
+)
+
+type Clients struct {
+	tracker  *tracker
+	coreV0   *coreV0Clients
+	systemV0 *systemV0Clients
+}
+
+var _ client.Interface = &Clients{}
+
+// NewClients はobjectsを保存した状態のClientsを作成する
+// objectsは *core.Network のようなリソースのポインタ
+func NewClients(objects ...interface{}) *Clients {
+	t := newTracker()
+	for _, obj := range objects {
+		if err := t.add(obj); err != nil {
+			panic(err)
+		}
+	}
+
+	return &Clients{
+		tracker:  t,
+		coreV0:   &coreV0Clients{tracker: t},
+		systemV0: &systemV0Clients{tracker: t},
+	}
+}
+
+func (c *Clients) CoreV0() clientcore.CoreV0Interface {
+	return c.coreV0
+}
+
+func (c *Clients) SystemV0() clientsystem.SystemV0Interface {
+	return c.systemV0
+}
+
+// Actions はこれまでに行われた操作を古い順に返す
+func (c *Clients) Actions() []Action {
+	return c.tracker.getActions()
+}
+
+// ClearActions は記録した操作を消す
+// テストの準備で行った操作を除きたい場合に使う
+func (c *Clients) ClearActions() {
+	c.tracker.clearActions()
+}
@@ -0,0 +1,364 @@
+package fake
+
+import (
+	"context"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	clientcore "github.com/ophum/humstack/pkg/client/core"
+)
+
+type coreV0Clients struct {
+	tracker *tracker
+}
+
+var _ clientcore.CoreV0Interface = &coreV0Clients{}
+
+func (c *coreV0Clients) Namespace() clientcore.NamespaceInterface {
+	return &namespaceClient{tracker: c.tracker}
+}
+
+func (c *coreV0Clients) Group() clientcore.GroupInterface {
+	return &groupClient{tracker: c.tracker}
+}
+
+func (c *coreV0Clients) ExternalIPPool() clientcore.ExternalIPPoolInterface {
+	return &externalIPPoolClient{tracker: c.tracker}
+}
+
+func (c *coreV0Clients) ExternalIP() clientcore.ExternalIPInterface {
+	return &externalIPClient{tracker: c.tracker}
+}
+
+func (c *coreV0Clients) Network() clientcore.NetworkInterface {
+	return &networkClient{tracker: c.tracker}
+}
+
+func (c *coreV0Clients) Event() clientcore.EventInterface {
+	return &eventClient{tracker: c.tracker}
+}
+
+func (c *coreV0Clients) Lease() clientcore.LeaseInterface {
+	return &leaseClient{tracker: c.tracker}
+}
+
+type namespaceClient struct {
+	tracker *tracker
+}
+
+func (c *namespaceClient) Get(ctx context.Context, groupID, namespaceID string) (*core.Namespace, error) {
+	ns := &core.Namespace{}
+	if err := c.tracker.get(meta.APITypeNamespaceV0, groupID, "", namespaceID, ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+func (c *namespaceClient) List(ctx context.Context, groupID string) ([]*core.Namespace, error) {
+	list := []*core.Namespace{}
+	if err := c.tracker.list(meta.APITypeNamespaceV0, groupID, "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *namespaceClient) Create(ctx context.Context, namespace *core.Namespace) (*core.Namespace, error) {
+	res := &core.Namespace{}
+	if err := c.tracker.create(namespace, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *namespaceClient) Update(ctx context.Context, namespace *core.Namespace) (*core.Namespace, error) {
+	res := &core.Namespace{}
+	if err := c.tracker.update(namespace, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *namespaceClient) Delete(ctx context.Context, groupID, namespaceID string) error {
+	return c.tracker.delete(meta.APITypeNamespaceV0, groupID, "", namespaceID, meta.DeletionPropagationBackground)
+}
+
+func (c *namespaceClient) DeleteWithPropagation(ctx context.Context, groupID, namespaceID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeNamespaceV0, groupID, "", namespaceID, policy)
+}
+
+type groupClient struct {
+	tracker *tracker
+}
+
+func (c *groupClient) Get(ctx context.Context, groupID string) (*core.Group, error) {
+	group := &core.Group{}
+	if err := c.tracker.get(meta.APITypeGroupV0, "", "", groupID, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (c *groupClient) List(ctx context.Context) ([]*core.Group, error) {
+	list := []*core.Group{}
+	if err := c.tracker.list(meta.APITypeGroupV0, "", "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *groupClient) Create(ctx context.Context, group *core.Group) (*core.Group, error) {
+	res := &core.Group{}
+	if err := c.tracker.create(group, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *groupClient) Update(ctx context.Context, group *core.Group) (*core.Group, error) {
+	res := &core.Group{}
+	if err := c.tracker.update(group, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *groupClient) Delete(ctx context.Context, groupID string) error {
+	return c.tracker.delete(meta.APITypeGroupV0, "", "", groupID, meta.DeletionPropagationBackground)
+}
+
+func (c *groupClient) DeleteWithPropagation(ctx context.Context, groupID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeGroupV0, "", "", groupID, policy)
+}
+
+type externalIPPoolClient struct {
+	tracker *tracker
+}
+
+func (c *externalIPPoolClient) Get(ctx context.Context, eippoolID string) (*core.ExternalIPPool, error) {
+	eippool := &core.ExternalIPPool{}
+	if err := c.tracker.get(meta.APITypeExternalIPPoolV0, "", "", eippoolID, eippool); err != nil {
+		return nil, err
+	}
+	return eippool, nil
+}
+
+func (c *externalIPPoolClient) List(ctx context.Context) ([]*core.ExternalIPPool, error) {
+	list := []*core.ExternalIPPool{}
+	if err := c.tracker.list(meta.APITypeExternalIPPoolV0, "", "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *externalIPPoolClient) Create(ctx context.Context, eippool *core.ExternalIPPool) (*core.ExternalIPPool, error) {
+	res := &core.ExternalIPPool{}
+	if err := c.tracker.create(eippool, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *externalIPPoolClient) Update(ctx context.Context, eippool *core.ExternalIPPool) (*core.ExternalIPPool, error) {
+	res := &core.ExternalIPPool{}
+	if err := c.tracker.update(eippool, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *externalIPPoolClient) Delete(ctx context.Context, eippoolID string) error {
+	return c.tracker.delete(meta.APITypeExternalIPPoolV0, "", "", eippoolID, meta.DeletionPropagationBackground)
+}
+
+func (c *externalIPPoolClient) DeleteWithPropagation(ctx context.Context, eippoolID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeExternalIPPoolV0, "", "", eippoolID, policy)
+}
+
+type externalIPClient struct {
+	tracker *tracker
+}
+
+func (c *externalIPClient) Get(ctx context.Context, eipID string) (*core.ExternalIP, error) {
+	eip := &core.ExternalIP{}
+	if err := c.tracker.get(meta.APITypeExternalIPV0, "", "", eipID, eip); err != nil {
+		return nil, err
+	}
+	return eip, nil
+}
+
+func (c *externalIPClient) List(ctx context.Context) ([]*core.ExternalIP, error) {
+	list := []*core.ExternalIP{}
+	if err := c.tracker.list(meta.APITypeExternalIPV0, "", "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *externalIPClient) Create(ctx context.Context, eip *core.ExternalIP) (*core.ExternalIP, error) {
+	res := &core.ExternalIP{}
+	if err := c.tracker.create(eip, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *externalIPClient) Update(ctx context.Context, eip *core.ExternalIP) (*core.ExternalIP, error) {
+	res := &core.ExternalIP{}
+	if err := c.tracker.update(eip, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *externalIPClient) Delete(ctx context.Context, eipID string) error {
+	return c.tracker.delete(meta.APITypeExternalIPV0, "", "", eipID, meta.DeletionPropagationBackground)
+}
+
+func (c *externalIPClient) DeleteWithPropagation(ctx context.Context, eipID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeExternalIPV0, "", "", eipID, policy)
+}
+
+type networkClient struct {
+	tracker *tracker
+}
+
+func (c *networkClient) Get(ctx context.Context, groupID, namespaceID, networkID string) (*core.Network, error) {
+	net := &core.Network{}
+	if err := c.tracker.get(meta.APITypeNetworkV0, groupID, namespaceID, networkID, net); err != nil {
+		return nil, err
+	}
+	return net, nil
+}
+
+func (c *networkClient) List(ctx context.Context, groupID, namespaceID string) ([]*core.Network, error) {
+	list := []*core.Network{}
+	if err := c.tracker.list(meta.APITypeNetworkV0, groupID, namespaceID, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *networkClient) Create(ctx context.Context, network *core.Network) (*core.Network, error) {
+	res := &core.Network{}
+	if err := c.tracker.create(network, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *networkClient) Update(ctx context.Context, network *core.Network) (*core.Network, error) {
+	res := &core.Network{}
+	if err := c.tracker.update(network, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *networkClient) Delete(ctx context.Context, groupID, namespaceID, networkID string) error {
+	return c.tracker.delete(meta.APITypeNetworkV0, groupID, namespaceID, networkID, meta.DeletionPropagationBackground)
+}
+
+func (c *networkClient) DeleteWithPropagation(ctx context.Context, groupID, namespaceID, networkID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeNetworkV0, groupID, namespaceID, networkID, policy)
+}
+
+type eventClient struct {
+	tracker *tracker
+}
+
+func (c *eventClient) Get(ctx context.Context, eventID string) (*core.Event, error) {
+	event := &core.Event{}
+	if err := c.tracker.get(meta.APITypeEventV0, "", "", eventID, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (c *eventClient) List(ctx context.Context) ([]*core.Event, error) {
+	list := []*core.Event{}
+	if err := c.tracker.list(meta.APITypeEventV0, "", "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *eventClient) ListByObject(ctx context.Context, object meta.ObjectReference) ([]*core.Event, error) {
+	list := []*core.Event{}
+	if err := c.tracker.list(meta.APITypeEventV0, "", "", &list); err != nil {
+		return nil, err
+	}
+
+	// apiserverと同じく指定されたものだけで絞り込む
+	res := []*core.Event{}
+	for _, e := range list {
+		obj := e.Spec.InvolvedObject
+		if (object.APIType != "" && obj.APIType != object.APIType) ||
+			(object.Group != "" && obj.Group != object.Group) ||
+			(object.Namespace != "" && obj.Namespace != object.Namespace) ||
+			(object.ID != "" && obj.ID != object.ID) {
+			continue
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (c *eventClient) Create(ctx context.Context, event *core.Event) (*core.Event, error) {
+	res := &core.Event{}
+	if err := c.tracker.create(event, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *eventClient) Update(ctx context.Context, event *core.Event) (*core.Event, error) {
+	res := &core.Event{}
+	if err := c.tracker.update(event, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *eventClient) Delete(ctx context.Context, eventID string) error {
+	return c.tracker.delete(meta.APITypeEventV0, "", "", eventID, meta.DeletionPropagationBackground)
+}
+
+type leaseClient struct {
+	tracker *tracker
+}
+
+func (c *leaseClient) Get(ctx context.Context, leaseID string) (*core.Lease, error) {
+	lease := &core.Lease{}
+	if err := c.tracker.get(meta.APITypeLeaseV0, "", "", leaseID, lease); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (c *leaseClient) List(ctx context.Context) ([]*core.Lease, error) {
+	list := []*core.Lease{}
+	if err := c.tracker.list(meta.APITypeLeaseV0, "", "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *leaseClient) Create(ctx context.Context, lease *core.Lease) (*core.Lease, error) {
+	res := &core.Lease{}
+	if err := c.tracker.create(lease, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *leaseClient) Update(ctx context.Context, lease *core.Lease) (*core.Lease, error) {
+	res := &core.Lease{}
+	if err := c.tracker.update(lease, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *leaseClient) Delete(ctx context.Context, leaseID string) error {
+	return c.tracker.delete(meta.APITypeLeaseV0, "", "", leaseID, meta.DeletionPropagationBackground)
+}
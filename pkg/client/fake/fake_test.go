@@ -0,0 +1,178 @@
+package fake
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+func TestCreateUpdateDelete(t *testing.T) {
+	ctx := context.Background()
+	clients := NewClients()
+	netClient := clients.CoreV0().Network()
+
+	net, err := netClient.Create(ctx, &core.Network{
+		Meta: meta.Meta{
+			ID:        "net1",
+			Group:     "group1",
+			Namespace: "ns1",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.APIType != meta.APITypeNetworkV0 || net.UID == "" || net.Generation != 1 {
+		t.Fatalf("unexpected meta: %+v", net.Meta)
+	}
+
+	if _, err := netClient.Create(ctx, net); !meta.IsConflict(err) {
+		t.Fatalf("expected Conflict, but got %v", err)
+	}
+
+	// specが変わった場合のみgenerationを上げる
+	uid := net.UID
+	net.UID = ""
+	net.Spec.Template.Spec.ID = "100"
+	net, err = netClient.Update(ctx, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.UID != uid || net.Generation != 2 {
+		t.Fatalf("unexpected meta: %+v", net.Meta)
+	}
+	net, err = netClient.Update(ctx, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.Generation != 2 {
+		t.Fatalf("unexpected generation: %d", net.Generation)
+	}
+
+	// finalizerが残っている間は削除中として残る
+	if err := netClient.DeleteWithPropagation(ctx, "group1", "ns1", "net1", meta.DeletionPropagationForeground); err != nil {
+		t.Fatal(err)
+	}
+	net, err = netClient.Get(ctx, "group1", "ns1", "net1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !net.IsDeleting() || !net.HasFinalizer(meta.FinalizerForegroundDeletion) {
+		t.Fatalf("unexpected meta: %+v", net.Meta)
+	}
+
+	net.RemoveFinalizer(meta.FinalizerForegroundDeletion)
+	if _, err := netClient.Update(ctx, net); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := netClient.Get(ctx, "group1", "ns1", "net1"); !meta.IsNotFound(err) {
+		t.Fatalf("expected NotFound, but got %v", err)
+	}
+	if err := netClient.Delete(ctx, "group1", "ns1", "net1"); !meta.IsNotFound(err) {
+		t.Fatalf("expected NotFound, but got %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	clients := NewClients(
+		&system.VirtualMachine{Meta: meta.Meta{ID: "vm2", Group: "group1", Namespace: "ns1"}},
+		&system.VirtualMachine{Meta: meta.Meta{ID: "vm1", Group: "group1", Namespace: "ns1"}},
+		&system.VirtualMachine{Meta: meta.Meta{ID: "vm3", Group: "group1", Namespace: "ns10"}},
+		&system.VirtualMachine{Meta: meta.Meta{ID: "vm4", Group: "group2", Namespace: "ns1"}},
+	)
+
+	vmList, err := clients.SystemV0().VirtualMachine().List(ctx, "group1", "ns1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vmList) != 2 || vmList[0].ID != "vm1" || vmList[1].ID != "vm2" {
+		t.Fatalf("unexpected list: %+v", vmList)
+	}
+	if vmList[0].APIType != meta.APITypeVirtualMachineV0 || vmList[0].UID == "" {
+		t.Fatalf("unexpected meta: %+v", vmList[0].Meta)
+	}
+}
+
+func TestActions(t *testing.T) {
+	ctx := context.Background()
+	clients := NewClients(&system.Node{Meta: meta.Meta{ID: "node1"}})
+
+	node, err := clients.SystemV0().Node().Get(ctx, "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.Labels = map[string]string{"zone": "a"}
+	if _, err := clients.SystemV0().Node().Update(ctx, node); err != nil {
+		t.Fatal(err)
+	}
+	// 記録したリソースは呼び出し元の変更の影響を受けない
+	node.Labels["zone"] = "b"
+
+	actions := clients.Actions()
+	if len(actions) != 2 {
+		t.Fatalf("unexpected actions: %+v", actions)
+	}
+	if actions[0].Verb != VerbGet || actions[0].ID != "node1" {
+		t.Fatalf("unexpected action: %+v", actions[0])
+	}
+	if actions[1].Verb != VerbUpdate || actions[1].APIType != meta.APITypeNodeV0 {
+		t.Fatalf("unexpected action: %+v", actions[1])
+	}
+	if updated := actions[1].Object.(*system.Node); updated.Labels["zone"] != "a" {
+		t.Fatalf("unexpected object: %+v", updated)
+	}
+
+	clients.ClearActions()
+	if len(clients.Actions()) != 0 {
+		t.Fatal("actions are not cleared")
+	}
+}
+
+func TestResolveImageEntityID(t *testing.T) {
+	ctx := context.Background()
+	clients := NewClients(
+		&system.Image{
+			Meta: meta.Meta{ID: "ubuntu", Group: "group1"},
+			Spec: system.ImageSpec{
+				EntityMap: map[string]string{"20.04": "entity1"},
+			},
+		},
+		&system.ImageTag{
+			Meta: meta.Meta{ID: "ubuntu:latest", Group: "group1"},
+			Spec: system.ImageTagSpec{
+				ImageName:     "ubuntu",
+				Tag:           "latest",
+				ImageEntityID: "entity2",
+			},
+		},
+		// IDが一致してもspecが違うtagは使わない
+		&system.ImageTag{
+			Meta: meta.Meta{ID: "ubuntu:20.04", Group: "group1"},
+			Spec: system.ImageTagSpec{
+				ImageName:     "debian",
+				Tag:           "20.04",
+				ImageEntityID: "entity3",
+			},
+		},
+	)
+
+	for tag, expected := range map[string]string{
+		"latest": "entity2",
+		"20.04":  "entity1",
+	} {
+		id, err := clients.SystemV0().ResolveImageEntityID(ctx, "group1", "ubuntu", tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != expected {
+			t.Fatalf("tag %s: expected %s, but got %s", tag, expected, id)
+		}
+	}
+
+	if _, err := clients.SystemV0().ResolveImageEntityID(ctx, "group1", "ubuntu", "18.04"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,437 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/imagetag"
+	clientsystem "github.com/ophum/humstack/pkg/client/system"
+)
+
+type systemV0Clients struct {
+	tracker *tracker
+}
+
+var _ clientsystem.SystemV0Interface = &systemV0Clients{}
+
+func (c *systemV0Clients) Node() clientsystem.NodeInterface {
+	return &nodeClient{tracker: c.tracker}
+}
+
+func (c *systemV0Clients) NodeNetwork() clientsystem.NodeNetworkInterface {
+	return &nodeNetworkClient{tracker: c.tracker}
+}
+
+func (c *systemV0Clients) BlockStorage() clientsystem.BlockStorageInterface {
+	return &blockStorageClient{tracker: c.tracker}
+}
+
+func (c *systemV0Clients) VirtualMachine() clientsystem.VirtualMachineInterface {
+	return &virtualMachineClient{tracker: c.tracker}
+}
+
+func (c *systemV0Clients) VirtualRouter() clientsystem.VirtualRouterInterface {
+	return &virtualRouterClient{tracker: c.tracker}
+}
+
+func (c *systemV0Clients) Image() clientsystem.ImageInterface {
+	return &imageClient{tracker: c.tracker}
+}
+
+func (c *systemV0Clients) ImageEntity() clientsystem.ImageEntityInterface {
+	return &imageEntityClient{tracker: c.tracker}
+}
+
+func (c *systemV0Clients) ImageTag() clientsystem.ImageTagInterface {
+	return &imageTagClient{tracker: c.tracker}
+}
+
+func (c *systemV0Clients) ResolveImageEntityID(ctx context.Context, groupID, imageName, tag string) (string, error) {
+	return clientsystem.ResolveImageEntityID(ctx, c, groupID, imageName, tag)
+}
+
+type nodeClient struct {
+	tracker *tracker
+}
+
+func (c *nodeClient) Get(ctx context.Context, nodeID string) (*system.Node, error) {
+	node := &system.Node{}
+	if err := c.tracker.get(meta.APITypeNodeV0, "", "", nodeID, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (c *nodeClient) List(ctx context.Context) ([]*system.Node, error) {
+	list := []*system.Node{}
+	if err := c.tracker.list(meta.APITypeNodeV0, "", "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *nodeClient) Create(ctx context.Context, node *system.Node) (*system.Node, error) {
+	res := &system.Node{}
+	if err := c.tracker.create(node, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *nodeClient) Update(ctx context.Context, node *system.Node) (*system.Node, error) {
+	res := &system.Node{}
+	if err := c.tracker.update(node, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *nodeClient) Delete(ctx context.Context, nodeID string) error {
+	return c.tracker.delete(meta.APITypeNodeV0, "", "", nodeID, meta.DeletionPropagationBackground)
+}
+
+func (c *nodeClient) DeleteWithPropagation(ctx context.Context, nodeID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeNodeV0, "", "", nodeID, policy)
+}
+
+type nodeNetworkClient struct {
+	tracker *tracker
+}
+
+func (c *nodeNetworkClient) Get(ctx context.Context, groupID, namespaceID, nodenetworkID string) (*system.NodeNetwork, error) {
+	nodeNet := &system.NodeNetwork{}
+	if err := c.tracker.get(meta.APITypeNodeNetworkV0, groupID, namespaceID, nodenetworkID, nodeNet); err != nil {
+		return nil, err
+	}
+	return nodeNet, nil
+}
+
+func (c *nodeNetworkClient) List(ctx context.Context, groupID, namespaceID string) ([]*system.NodeNetwork, error) {
+	list := []*system.NodeNetwork{}
+	if err := c.tracker.list(meta.APITypeNodeNetworkV0, groupID, namespaceID, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *nodeNetworkClient) Create(ctx context.Context, nodenetwork *system.NodeNetwork) (*system.NodeNetwork, error) {
+	res := &system.NodeNetwork{}
+	if err := c.tracker.create(nodenetwork, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *nodeNetworkClient) Update(ctx context.Context, nodenetwork *system.NodeNetwork) (*system.NodeNetwork, error) {
+	res := &system.NodeNetwork{}
+	if err := c.tracker.update(nodenetwork, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *nodeNetworkClient) Delete(ctx context.Context, groupID, namespaceID, nodenetworkID string) error {
+	return c.tracker.delete(meta.APITypeNodeNetworkV0, groupID, namespaceID, nodenetworkID, meta.DeletionPropagationBackground)
+}
+
+func (c *nodeNetworkClient) DeleteWithPropagation(ctx context.Context, groupID, namespaceID, nodenetworkID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeNodeNetworkV0, groupID, namespaceID, nodenetworkID, policy)
+}
+
+type blockStorageClient struct {
+	tracker *tracker
+}
+
+func (c *blockStorageClient) Get(ctx context.Context, groupID, namespaceID, blockStorageID string) (*system.BlockStorage, error) {
+	bs := &system.BlockStorage{}
+	if err := c.tracker.get(meta.APITypeBlockStorageV0, groupID, namespaceID, blockStorageID, bs); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+func (c *blockStorageClient) List(ctx context.Context, groupID, namespaceID string) ([]*system.BlockStorage, error) {
+	list := []*system.BlockStorage{}
+	if err := c.tracker.list(meta.APITypeBlockStorageV0, groupID, namespaceID, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *blockStorageClient) Create(ctx context.Context, blockstorage *system.BlockStorage) (*system.BlockStorage, error) {
+	res := &system.BlockStorage{}
+	if err := c.tracker.create(blockstorage, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *blockStorageClient) Update(ctx context.Context, blockstorage *system.BlockStorage) (*system.BlockStorage, error) {
+	res := &system.BlockStorage{}
+	if err := c.tracker.update(blockstorage, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *blockStorageClient) Delete(ctx context.Context, groupID, namespaceID, blockStorageID string) error {
+	return c.tracker.delete(meta.APITypeBlockStorageV0, groupID, namespaceID, blockStorageID, meta.DeletionPropagationBackground)
+}
+
+func (c *blockStorageClient) DeleteWithPropagation(ctx context.Context, groupID, namespaceID, blockStorageID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeBlockStorageV0, groupID, namespaceID, blockStorageID, policy)
+}
+
+type virtualMachineClient struct {
+	tracker *tracker
+}
+
+func (c *virtualMachineClient) Get(ctx context.Context, groupID, namespaceID, virtualMachineID string) (*system.VirtualMachine, error) {
+	vm := &system.VirtualMachine{}
+	if err := c.tracker.get(meta.APITypeVirtualMachineV0, groupID, namespaceID, virtualMachineID, vm); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+func (c *virtualMachineClient) List(ctx context.Context, groupID, namespaceID string) ([]*system.VirtualMachine, error) {
+	list := []*system.VirtualMachine{}
+	if err := c.tracker.list(meta.APITypeVirtualMachineV0, groupID, namespaceID, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *virtualMachineClient) Create(ctx context.Context, vm *system.VirtualMachine) (*system.VirtualMachine, error) {
+	res := &system.VirtualMachine{}
+	if err := c.tracker.create(vm, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *virtualMachineClient) Update(ctx context.Context, vm *system.VirtualMachine) (*system.VirtualMachine, error) {
+	res := &system.VirtualMachine{}
+	if err := c.tracker.update(vm, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *virtualMachineClient) Delete(ctx context.Context, groupID, namespaceID, virtualMachineID string) error {
+	return c.tracker.delete(meta.APITypeVirtualMachineV0, groupID, namespaceID, virtualMachineID, meta.DeletionPropagationBackground)
+}
+
+func (c *virtualMachineClient) DeleteWithPropagation(ctx context.Context, groupID, namespaceID, virtualMachineID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeVirtualMachineV0, groupID, namespaceID, virtualMachineID, policy)
+}
+
+type virtualRouterClient struct {
+	tracker *tracker
+}
+
+func (c *virtualRouterClient) Get(ctx context.Context, groupID, namespaceID, virtualRouterID string) (*system.VirtualRouter, error) {
+	vr := &system.VirtualRouter{}
+	if err := c.tracker.get(meta.APITypeVirtualRouterV0, groupID, namespaceID, virtualRouterID, vr); err != nil {
+		return nil, err
+	}
+	return vr, nil
+}
+
+func (c *virtualRouterClient) List(ctx context.Context, groupID, namespaceID string) ([]*system.VirtualRouter, error) {
+	list := []*system.VirtualRouter{}
+	if err := c.tracker.list(meta.APITypeVirtualRouterV0, groupID, namespaceID, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *virtualRouterClient) Create(ctx context.Context, vr *system.VirtualRouter) (*system.VirtualRouter, error) {
+	res := &system.VirtualRouter{}
+	if err := c.tracker.create(vr, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *virtualRouterClient) Update(ctx context.Context, vr *system.VirtualRouter) (*system.VirtualRouter, error) {
+	res := &system.VirtualRouter{}
+	if err := c.tracker.update(vr, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *virtualRouterClient) Delete(ctx context.Context, groupID, namespaceID, virtualRouterID string) error {
+	return c.tracker.delete(meta.APITypeVirtualRouterV0, groupID, namespaceID, virtualRouterID, meta.DeletionPropagationBackground)
+}
+
+func (c *virtualRouterClient) DeleteWithPropagation(ctx context.Context, groupID, namespaceID, virtualRouterID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeVirtualRouterV0, groupID, namespaceID, virtualRouterID, policy)
+}
+
+type imageClient struct {
+	tracker *tracker
+}
+
+func (c *imageClient) Get(ctx context.Context, groupID, imageID string) (*system.Image, error) {
+	im := &system.Image{}
+	if err := c.tracker.get(meta.APITypeImageV0, groupID, "", imageID, im); err != nil {
+		return nil, err
+	}
+	return im, nil
+}
+
+func (c *imageClient) List(ctx context.Context, groupID string) ([]*system.Image, error) {
+	list := []*system.Image{}
+	if err := c.tracker.list(meta.APITypeImageV0, groupID, "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *imageClient) Create(ctx context.Context, image *system.Image) (*system.Image, error) {
+	res := &system.Image{}
+	if err := c.tracker.create(image, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *imageClient) Update(ctx context.Context, image *system.Image) (*system.Image, error) {
+	res := &system.Image{}
+	if err := c.tracker.update(image, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *imageClient) Delete(ctx context.Context, groupID, imageID string) error {
+	return c.tracker.delete(meta.APITypeImageV0, groupID, "", imageID, meta.DeletionPropagationBackground)
+}
+
+func (c *imageClient) DeleteWithPropagation(ctx context.Context, groupID, imageID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeImageV0, groupID, "", imageID, policy)
+}
+
+func (c *imageClient) Download(ctx context.Context, groupID, imageID, tag string) (io.ReadCloser, error) {
+	// fakeはimageのファイルを持たない
+	return nil, fmt.Errorf("download is not supported in fake client")
+}
+
+type imageEntityClient struct {
+	tracker *tracker
+}
+
+func (c *imageEntityClient) Get(ctx context.Context, groupID, imageEntityID string) (*system.ImageEntity, error) {
+	ie := &system.ImageEntity{}
+	if err := c.tracker.get(meta.APITypeImageEntityV0, groupID, "", imageEntityID, ie); err != nil {
+		return nil, err
+	}
+	return ie, nil
+}
+
+func (c *imageEntityClient) List(ctx context.Context, groupID string) ([]*system.ImageEntity, error) {
+	list := []*system.ImageEntity{}
+	if err := c.tracker.list(meta.APITypeImageEntityV0, groupID, "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *imageEntityClient) Create(ctx context.Context, imageEntity *system.ImageEntity) (*system.ImageEntity, error) {
+	res := &system.ImageEntity{}
+	if err := c.tracker.create(imageEntity, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *imageEntityClient) Update(ctx context.Context, imageEntity *system.ImageEntity) (*system.ImageEntity, error) {
+	res := &system.ImageEntity{}
+	if err := c.tracker.update(imageEntity, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *imageEntityClient) Delete(ctx context.Context, groupID, imageEntityID string) error {
+	return c.tracker.delete(meta.APITypeImageEntityV0, groupID, "", imageEntityID, meta.DeletionPropagationBackground)
+}
+
+func (c *imageEntityClient) DeleteWithPropagation(ctx context.Context, groupID, imageEntityID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeImageEntityV0, groupID, "", imageEntityID, policy)
+}
+
+type imageTagClient struct {
+	tracker *tracker
+}
+
+func (c *imageTagClient) Get(ctx context.Context, groupID, imageTagID string) (*system.ImageTag, error) {
+	it := &system.ImageTag{}
+	if err := c.tracker.get(meta.APITypeImageTagV0, groupID, "", imageTagID, it); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+func (c *imageTagClient) GetByTag(ctx context.Context, groupID, imageName, tag string) (*system.ImageTag, error) {
+	it, err := c.Get(ctx, groupID, imagetag.GetID(imageName, tag))
+	if err != nil {
+		return nil, err
+	}
+	if !imagetag.Matches(it, imageName, tag) {
+		return nil, meta.NewNotFound("ImageTag `%s` is not found.", imagetag.GetID(imageName, tag))
+	}
+	return it, nil
+}
+
+func (c *imageTagClient) List(ctx context.Context, groupID string) ([]*system.ImageTag, error) {
+	list := []*system.ImageTag{}
+	if err := c.tracker.list(meta.APITypeImageTagV0, groupID, "", &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *imageTagClient) ListByImage(ctx context.Context, groupID, imageName string) ([]*system.ImageTag, error) {
+	list, err := c.List(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*system.ImageTag{}
+	for _, it := range list {
+		if it.Spec.ImageName == imageName {
+			res = append(res, it)
+		}
+	}
+	return res, nil
+}
+
+func (c *imageTagClient) Create(ctx context.Context, imageTag *system.ImageTag) (*system.ImageTag, error) {
+	res := &system.ImageTag{}
+	if err := c.tracker.create(imageTag, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *imageTagClient) Update(ctx context.Context, imageTag *system.ImageTag) (*system.ImageTag, error) {
+	res := &system.ImageTag{}
+	if err := c.tracker.update(imageTag, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *imageTagClient) Delete(ctx context.Context, groupID, imageTagID string) error {
+	return c.tracker.delete(meta.APITypeImageTagV0, groupID, "", imageTagID, meta.DeletionPropagationBackground)
+}
+
+func (c *imageTagClient) DeleteWithPropagation(ctx context.Context, groupID, imageTagID string, policy meta.DeletionPropagation) error {
+	return c.tracker.delete(meta.APITypeImageTagV0, groupID, "", imageTagID, policy)
+}
@@ -0,0 +1,408 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/scheme"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/store/memory"
+)
+
+const (
+	VerbGet    = "get"
+	VerbList   = "list"
+	VerbCreate = "create"
+	VerbUpdate = "update"
+	VerbDelete = "delete"
+)
+
+// Action はfakeのクライアントに対して行われた操作
+type Action struct {
+	Verb      string
+	APIType   meta.APIType
+	Group     string
+	Namespace string
+	ID        string
+
+	// create, updateの場合は保存したリソース
+	Object interface{}
+	// deleteの場合のpropagationPolicy
+	Policy meta.DeletionPropagation
+}
+
+// tracker はリソースをJSONのままMemoryStoreに保存する
+// apiserverと同じようにuid, generation, deletionTimestamp, finalizerを扱う
+type tracker struct {
+	mu      sync.Mutex
+	store   *memory.MemoryStore
+	actions []Action
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		store: memory.NewMemoryStore(),
+	}
+}
+
+// objectKey はリソースの型からapiTypeとgroup, namespace, idを返す
+func objectKey(obj interface{}) (meta.APIType, string, string, string, error) {
+	switch o := obj.(type) {
+	case *core.Group:
+		return meta.APITypeGroupV0, "", "", o.ID, nil
+	case *core.Namespace:
+		return meta.APITypeNamespaceV0, o.Group, "", o.ID, nil
+	case *core.ExternalIPPool:
+		return meta.APITypeExternalIPPoolV0, "", "", o.ID, nil
+	case *core.ExternalIP:
+		return meta.APITypeExternalIPV0, "", "", o.ID, nil
+	case *core.Network:
+		return meta.APITypeNetworkV0, o.Group, o.Namespace, o.ID, nil
+	case *core.Event:
+		return meta.APITypeEventV0, "", "", o.ID, nil
+	case *core.Lease:
+		return meta.APITypeLeaseV0, "", "", o.ID, nil
+	case *system.Node:
+		return meta.APITypeNodeV0, "", "", o.ID, nil
+	case *system.NodeNetwork:
+		return meta.APITypeNodeNetworkV0, o.Group, o.Namespace, o.ID, nil
+	case *system.BlockStorage:
+		return meta.APITypeBlockStorageV0, o.Group, o.Namespace, o.ID, nil
+	case *system.VirtualMachine:
+		return meta.APITypeVirtualMachineV0, o.Group, o.Namespace, o.ID, nil
+	case *system.VirtualRouter:
+		return meta.APITypeVirtualRouterV0, o.Group, o.Namespace, o.ID, nil
+	case *system.Image:
+		return meta.APITypeImageV0, o.Group, "", o.ID, nil
+	case *system.ImageEntity:
+		return meta.APITypeImageEntityV0, o.Group, "", o.ID, nil
+	case *system.ImageTag:
+		return meta.APITypeImageTagV0, o.Group, "", o.ID, nil
+	}
+	return "", "", "", "", fmt.Errorf("unsupported object type %T", obj)
+}
+
+func getKey(apiType meta.APIType, groupID, namespaceID, id string) string {
+	return strings.Join([]string{string(apiType), groupID, namespaceID, id}, "/")
+}
+
+// add はactionを記録せずにリソースを保存する
+func (t *tracker) add(obj interface{}) error {
+	apiType, groupID, namespaceID, id, err := objectKey(obj)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	m := meta.Meta{}
+	if err := decodeMeta(raw, &m); err != nil {
+		return err
+	}
+	if m.APIType == "" {
+		m.APIType = apiType
+	}
+	if m.UID == "" {
+		m.UID = uuid.New().String()
+	}
+	if m.Generation == 0 {
+		m.Generation = 1
+	}
+	raw, err = encodeMeta(raw, &m)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.put(getKey(apiType, groupID, namespaceID, id), raw)
+	return nil
+}
+
+// put はrawをJSONのまま保存する
+// []byteのままではMemoryStoreがbase64の文字列として扱ってしまう
+func (t *tracker) put(key string, raw []byte) {
+	t.store.Put(key, json.RawMessage(raw))
+}
+
+func (t *tracker) record(action Action) {
+	t.actions = append(t.actions, action)
+}
+
+func (t *tracker) get(apiType meta.APIType, groupID, namespaceID, id string, out interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.record(Action{
+		Verb:      VerbGet,
+		APIType:   apiType,
+		Group:     groupID,
+		Namespace: namespaceID,
+		ID:        id,
+	})
+
+	raw, err := t.getRaw(apiType, groupID, namespaceID, id)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func (t *tracker) getRaw(apiType meta.APIType, groupID, namespaceID, id string) (json.RawMessage, error) {
+	var raw json.RawMessage
+	if err := t.store.Get(getKey(apiType, groupID, namespaceID, id), &raw); err != nil {
+		return nil, meta.NewNotFound("%s `%s` is not found.", apiType, id)
+	}
+	return raw, nil
+}
+
+// list はgroup, namespaceのリソースをid順にoutにデコードする
+// outはリソースのポインタのスライスのポインタ
+func (t *tracker) list(apiType meta.APIType, groupID, namespaceID string, out interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.record(Action{
+		Verb:      VerbList,
+		APIType:   apiType,
+		Group:     groupID,
+		Namespace: namespaceID,
+	})
+
+	keys, err := t.store.Keys(getKey(apiType, groupID, namespaceID, ""))
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+
+	list := make([]json.RawMessage, 0, len(keys))
+	for _, key := range keys {
+		var raw json.RawMessage
+		if err := t.store.Get(key, &raw); err != nil {
+			return err
+		}
+		list = append(list, raw)
+	}
+
+	buf, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, out)
+}
+
+func (t *tracker) create(obj, out interface{}) error {
+	apiType, groupID, namespaceID, id, err := objectKey(obj)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return meta.NewInvalid("Error: id is empty.")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := t.getRaw(apiType, groupID, namespaceID, id); err == nil {
+		return meta.NewConflict("Error: %s `%s` is already exists.", apiType, id)
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	m := meta.Meta{}
+	if err := decodeMeta(raw, &m); err != nil {
+		return err
+	}
+	m.APIType = apiType
+	m.UID = uuid.New().String()
+	m.Generation = 1
+	if apiType == meta.APITypeNodeV0 {
+		m.ResourceVersion = 1
+	}
+	raw, err = encodeMeta(raw, &m)
+	if err != nil {
+		return err
+	}
+
+	t.put(getKey(apiType, groupID, namespaceID, id), raw)
+	return t.recordObject(VerbCreate, apiType, groupID, namespaceID, id, raw, out)
+}
+
+func (t *tracker) update(obj, out interface{}) error {
+	apiType, groupID, namespaceID, id, err := objectKey(obj)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	oldRaw, err := t.getRaw(apiType, groupID, namespaceID, id)
+	if err != nil {
+		return err
+	}
+	old := meta.Meta{}
+	if err := decodeMeta(oldRaw, &old); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	m := meta.Meta{}
+	if err := decodeMeta(raw, &m); err != nil {
+		return err
+	}
+
+	// apiserverと同じくnodeは古いresourceVersionでの上書きを拒否する
+	if apiType == meta.APITypeNodeV0 {
+		if m.ResourceVersion != 0 && m.ResourceVersion != old.ResourceVersion {
+			return meta.NewConflict("Error: %s `%s` has been modified, resourceVersion is %d but got %d.", apiType, id, old.ResourceVersion, m.ResourceVersion)
+		}
+		m.ResourceVersion = old.ResourceVersion + 1
+	}
+
+	// uid, generation, deletionTimestampはapiserverと同じく変更させない
+	m.UID = old.UID
+	m.Generation = meta.NextGeneration(old.Generation, decodeSpec(oldRaw), decodeSpec(raw))
+	m.DeletionTimestamp = old.DeletionTimestamp
+	m.KeepFinalizers(old.Finalizers)
+	raw, err = encodeMeta(raw, &m)
+	if err != nil {
+		return err
+	}
+
+	// finalizerが全て外れた削除中のリソースはMemoryStoreが削除する
+	t.put(getKey(apiType, groupID, namespaceID, id), raw)
+	return t.recordObject(VerbUpdate, apiType, groupID, namespaceID, id, raw, out)
+}
+
+func (t *tracker) delete(apiType meta.APIType, groupID, namespaceID, id string, policy meta.DeletionPropagation) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.record(Action{
+		Verb:      VerbDelete,
+		APIType:   apiType,
+		Group:     groupID,
+		Namespace: namespaceID,
+		ID:        id,
+		Policy:    policy,
+	})
+
+	key := getKey(apiType, groupID, namespaceID, id)
+	raw, err := t.getRaw(apiType, groupID, namespaceID, id)
+	if err != nil {
+		return err
+	}
+	m := meta.Meta{}
+	if err := decodeMeta(raw, &m); err != nil {
+		return err
+	}
+
+	if policy == meta.DeletionPropagationForeground {
+		m.AddFinalizer(meta.FinalizerForegroundDeletion)
+	}
+	// apiserverと同じくgroupとnamespaceは所属するリソースが消えるまで残す
+	if apiType == meta.APITypeGroupV0 || apiType == meta.APITypeNamespaceV0 {
+		m.AddFinalizer(meta.FinalizerGarbageCollector)
+	}
+
+	// finalizerが残っている場合は削除要求を記録するだけにする
+	if len(m.Finalizers) != 0 {
+		m.MarkDeletion()
+		if apiType == meta.APITypeNodeV0 {
+			m.ResourceVersion++
+		}
+		raw, err = encodeMeta(raw, &m)
+		if err != nil {
+			return err
+		}
+		t.put(key, raw)
+		return nil
+	}
+
+	t.store.Delete(key)
+	return nil
+}
+
+func (t *tracker) recordObject(verb string, apiType meta.APIType, groupID, namespaceID, id string, raw json.RawMessage, out interface{}) error {
+	if err := json.Unmarshal(raw, out); err != nil {
+		return err
+	}
+
+	// 記録したリソースが呼び出し元の変更の影響を受けないように別にデコードする
+	obj, err := scheme.New(apiType)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return err
+	}
+
+	t.record(Action{
+		Verb:      verb,
+		APIType:   apiType,
+		Group:     groupID,
+		Namespace: namespaceID,
+		ID:        id,
+		Object:    obj,
+	})
+	return nil
+}
+
+func (t *tracker) getActions() []Action {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	actions := make([]Action, len(t.actions))
+	copy(actions, t.actions)
+	return actions
+}
+
+func (t *tracker) clearActions() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.actions = nil
+}
+
+func decodeMeta(raw json.RawMessage, m *meta.Meta) error {
+	obj := struct {
+		Meta *meta.Meta `json:"meta"`
+	}{m}
+	return json.Unmarshal(raw, &obj)
+}
+
+// encodeMeta はrawのmetaをmに置き換える
+func encodeMeta(raw json.RawMessage, m *meta.Meta) (json.RawMessage, error) {
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	obj["meta"] = buf
+	return json.Marshal(obj)
+}
+
+func decodeSpec(raw json.RawMessage) interface{} {
+	obj := struct {
+		Spec interface{} `json:"spec"`
+	}{}
+	json.Unmarshal(raw, &obj)
+	return obj.Spec
+}
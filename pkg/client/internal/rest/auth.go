@@ -0,0 +1,34 @@
+package rest
+
+import (
+	"net/http"
+)
+
+// Authenticator はapiserverへのリクエストに認証情報を付ける
+// 再送するたびに呼ばれるため、期限のあるトークンはここで更新できる
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc は関数をAuthenticatorとして使う
+type AuthenticatorFunc func(req *http.Request) error
+
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BearerToken は `Authorization: Bearer <token>` を付ける
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// BasicAuth はBasic認証のヘッダを付ける
+func BasicAuth(username, password string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
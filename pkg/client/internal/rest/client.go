@@ -0,0 +1,271 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/version"
+)
+
+const (
+	DefaultTimeout = time.Second * 30
+)
+
+// Config はapiserverへの接続設定
+// 0の値はデフォルト値を使う
+type Config struct {
+	// http または https
+	Scheme  string
+	Address string
+	Port    int32
+
+	// nilでない場合はhttpsで接続する
+	TLSClientConfig *tls.Config
+
+	// 1回のリクエストのタイムアウト
+	// ダウンロードやwatchのようなストリームには使わずctxで制御する
+	Timeout time.Duration
+
+	// 再送する最大の回数。負の値の場合は再送しない
+	RetryCount int
+	// 再送までの待ち時間。再送するごとに倍になる
+	RetryWaitTime    time.Duration
+	RetryMaxWaitTime time.Duration
+
+	UserAgent string
+
+	// nilでない場合は全てのリクエストに認証情報を付ける
+	Auth Authenticator
+}
+
+// Client は全てのリソースのクライアントで共有するapiserverへの接続
+type Client struct {
+	baseURL *url.URL
+	client  *resty.Client
+	// ストリーム用。タイムアウトと再送をしない
+	streamClient *http.Client
+	dryRun       bool
+}
+
+// New はconfigのapiserverに接続するClientを作成する
+func New(config Config) *Client {
+	scheme := config.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if config.TLSClientConfig != nil {
+			scheme = "https"
+		}
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	retryCount := config.RetryCount
+	if retryCount == 0 {
+		retryCount = defaultRetryCount
+	} else if retryCount < 0 {
+		retryCount = 0
+	}
+
+	retryWaitTime := config.RetryWaitTime
+	if retryWaitTime == 0 {
+		retryWaitTime = defaultRetryWaitTime
+	}
+
+	retryMaxWaitTime := config.RetryMaxWaitTime
+	if retryMaxWaitTime == 0 {
+		retryMaxWaitTime = defaultRetryMaxWaitTime
+	}
+
+	userAgent := config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent()
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = config.TLSClientConfig
+	transport := &roundTripper{
+		base:      base,
+		userAgent: userAgent,
+		auth:      config.Auth,
+	}
+
+	return &Client{
+		baseURL: &url.URL{
+			Scheme: scheme,
+			Host:   fmt.Sprintf("%s:%d", config.Address, config.Port),
+		},
+		client: resty.New().
+			SetTransport(transport).
+			SetTimeout(timeout).
+			SetRetryCount(retryCount).
+			SetRetryWaitTime(retryWaitTime).
+			SetRetryMaxWaitTime(retryMaxWaitTime).
+			AddRetryCondition(shouldRetry).
+			SetRetryAfter(retryAfter).
+			SetHeader("Content-Type", "application/json").
+			SetHeader("Accept", "application/json"),
+		streamClient: &http.Client{
+			Transport: transport,
+		},
+	}
+}
+
+// DefaultUserAgent は `humstack/<version> (<os>/<arch>)` を返す
+func DefaultUserAgent() string {
+	return fmt.Sprintf("humstack/%s (%s/%s)", version.Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// SetDryRun はtrueの場合、全てのリクエストに `?dryRun=true` を付ける
+// apiserverは作成・更新・削除の結果を返すが保存しない
+func (c *Client) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// Path は各要素をエスケープしてapiserverのパスを作成する
+// 空の要素は省略せずに残し、リクエストする時にエラーにする
+// 一覧のパスはIDを空にせず、要素に含めないこと
+func Path(elements ...string) string {
+	escaped := make([]string, 0, len(elements))
+	for _, e := range elements {
+		escaped = append(escaped, url.PathEscape(e))
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+// checkPath はパスに空の要素が含まれている場合にエラーを返す
+// IDが空のGetで一覧を取得するなど、意図しないリソースへリクエストしないようにする
+func checkPath(path string) error {
+	for _, e := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if e == "" {
+			return fmt.Errorf("path `%s` has an empty element", path)
+		}
+	}
+	return nil
+}
+
+// URL はpathとqueryからリクエスト先のURLを作成する
+func (c *Client) URL(path string, query url.Values) string {
+	u := *c.baseURL
+	u.RawPath = path
+	if p, err := url.PathUnescape(path); err == nil {
+		u.Path = p
+	}
+
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	if c.dryRun {
+		q.Set("dryRun", "true")
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Get はpathのリソースを取得してoutにデコードする
+func (c *Client) Get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, query, nil, out)
+}
+
+// Post はbodyを送信して返ってきたリソースをoutにデコードする
+func (c *Client) Post(ctx context.Context, path string, body, out interface{}) error {
+	return c.do(ctx, http.MethodPost, path, nil, body, out)
+}
+
+// Put はbodyを送信して返ってきたリソースをoutにデコードする
+func (c *Client) Put(ctx context.Context, path string, body, out interface{}) error {
+	return c.do(ctx, http.MethodPut, path, nil, body, out)
+}
+
+// Delete はpathのリソースを削除する
+func (c *Client) Delete(ctx context.Context, path string, query url.Values) error {
+	return c.do(ctx, http.MethodDelete, path, query, nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	if err := checkPath(path); err != nil {
+		return err
+	}
+
+	req := c.client.R().SetContext(ctx)
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req.SetBody(buf)
+	}
+
+	res, err := req.Execute(method, c.URL(path, query))
+	if err != nil {
+		return err
+	}
+	if err := CheckResponse(res); err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(res.Body(), out)
+}
+
+// Stream はpathをGETしてレスポンスのbodyを返す
+// 大きなファイルのダウンロードに使うためタイムアウトせず、再送もしない
+func (c *Client) Stream(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL(path, query), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.streamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHTTPResponse(res); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// HTTPClient はタイムアウトしないhttp.Clientを返す
+// watchのようにrestyを使えないストリームで、TLSや認証の設定を共有するために使う
+func (c *Client) HTTPClient() *http.Client {
+	return c.streamClient
+}
+
+// roundTripper は全てのリクエストにUser-Agentと認証情報を付ける
+type roundTripper struct {
+	base      http.RoundTripper
+	userAgent string
+	auth      Authenticator
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripperはリクエストを変更してはいけないためコピーする
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", rt.userAgent)
+	if rt.auth != nil {
+		if err := rt.auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	return rt.base.RoundTrip(req)
+}
@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+const (
+	defaultRetryCount       = 5
+	defaultRetryWaitTime    = time.Millisecond * 500
+	defaultRetryMaxWaitTime = time.Second * 30
+)
+
+// shouldRetry は再送するかを返す
+// 429はapiserverが処理していないため全てのメソッドで再送する
+// 通信エラーと502, 503, 504は処理されたか分からないため冪等なメソッドのみ再送する
+func shouldRetry(res *resty.Response, err error) bool {
+	if isTooManyRequests(res, err) {
+		return true
+	}
+
+	if res == nil || res.Request == nil || !isIdempotent(res.Request.Method) {
+		return false
+	}
+
+	if err != nil {
+		return isNetworkError(err)
+	}
+
+	switch res.StatusCode() {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func isTooManyRequests(res *resty.Response, err error) bool {
+	return err == nil && res != nil && res.StatusCode() == http.StatusTooManyRequests
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isNetworkError は接続できない、タイムアウトしたなどの通信エラーであればtrueを返す
+// ctxのキャンセルや認証情報の作成に失敗した場合は再送しない
+func isNetworkError(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryAfter はRetry-Afterヘッダの秒数を待ち時間とする
+// ヘッダが無い場合は0を返し、restyの指数バックオフを使う
+func retryAfter(_ *resty.Client, res *resty.Response) (time.Duration, error) {
+	if res == nil {
+		return 0, nil
+	}
+
+	v := res.Header().Get("Retry-After")
+	if v == "" {
+		return 0, nil
+	}
+
+	if sec, err := strconv.Atoi(v); err == nil && sec > 0 {
+		return time.Duration(sec) * time.Second, nil
+	}
+	if t, err := http.ParseTime(v); err == nil && time.Until(t) > 0 {
+		return time.Until(t), nil
+	}
+	return 0, nil
+}
+
+type errorResponse struct {
+	Code   int               `json:"code"`
+	Error  interface{}       `json:"error"`
+	Reason meta.StatusReason `json:"reason"`
+}
+
+// CheckResponse はapiserverがエラーを返した場合に*meta.APIErrorを返す
+// reasonを返さない古いapiserverの場合はステータスコードから判断する
+func CheckResponse(res *resty.Response) error {
+	if !res.IsError() {
+		return nil
+	}
+	return newAPIError(res.StatusCode(), res.Body())
+}
+
+func checkHTTPResponse(res *http.Response) error {
+	if res.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	return newAPIError(res.StatusCode, body)
+}
+
+func newAPIError(code int, body []byte) error {
+	message := http.StatusText(code)
+	errRes := errorResponse{}
+	if err := json.Unmarshal(body, &errRes); err == nil && errRes.Error != nil {
+		message = fmt.Sprint(errRes.Error)
+	}
+
+	apiErr := meta.NewAPIError(code, message)
+	if errRes.Reason != "" {
+		apiErr.Reason = errRes.Reason
+	}
+	return apiErr
+}
@@ -0,0 +1,266 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+func newTestClient(t *testing.T, srv *httptest.Server, config Config) *Client {
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Address = host
+	config.Port = int32(p)
+	if config.RetryWaitTime == 0 {
+		config.RetryWaitTime = time.Millisecond
+	}
+	return New(config)
+}
+
+func TestClientRetriesTooManyRequests(t *testing.T) {
+	requests := 0
+	var retriedAt time.Duration
+	start := time.Now()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		retriedAt = time.Since(start)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	// 429はPOSTでも再送する
+	client := newTestClient(t, srv, Config{})
+	if err := client.Post(context.Background(), Path("api", "v0", "groups"), map[string]string{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Fatalf("unexpected request count: %d", requests)
+	}
+	if retriedAt < time.Second {
+		t.Fatalf("retried before Retry-After: %s", retriedAt)
+	}
+}
+
+func TestClientRetriesOnlyIdempotentRequests(t *testing.T) {
+	requests := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.Method]++
+		if requests[r.Method] == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{})
+	if err := client.Get(context.Background(), Path("api", "v0", "groups"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if requests[http.MethodGet] != 2 {
+		t.Fatalf("unexpected GET count: %d", requests[http.MethodGet])
+	}
+
+	// 処理されたか分からないため再送しない
+	err := client.Post(context.Background(), Path("api", "v0", "groups"), map[string]string{}, nil)
+	if !meta.IsInternal(err) {
+		t.Fatalf("expected Internal, but got %v", err)
+	}
+	if requests[http.MethodPost] != 1 {
+		t.Fatalf("unexpected POST count: %d", requests[http.MethodPost])
+	}
+}
+
+func TestClientStopsRetryingWhenContextIsCanceled(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{
+		RetryWaitTime:    time.Second,
+		RetryMaxWaitTime: time.Second,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	start := time.Now()
+	err := client.Get(ctx, Path("api", "v0", "groups"), nil, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second/2 {
+		t.Fatalf("retried after context was canceled: %s", elapsed)
+	}
+	if requests != 1 {
+		t.Fatalf("unexpected request count: %d", requests)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 200)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{
+		Timeout:    time.Millisecond * 50,
+		RetryCount: -1,
+	})
+	if err := client.Get(context.Background(), Path("api", "v0", "groups"), nil, nil); err == nil {
+		t.Fatal("expected timeout")
+	}
+}
+
+func TestClientEscapesPath(t *testing.T) {
+	var gotPath, gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotQuery = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{})
+	client.SetDryRun(true)
+
+	path := Path("api", "v0", "groups", "group 1", "namespaces", "ns/1", "virtualmachines")
+	if err := client.Delete(context.Background(), path, map[string][]string{"propagationPolicy": {"Foreground"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPath != "/api/v0/groups/group%201/namespaces/ns%2F1/virtualmachines" {
+		t.Fatalf("unexpected path: %s", gotPath)
+	}
+	if gotQuery != "dryRun=true&propagationPolicy=Foreground" {
+		t.Fatalf("unexpected query: %s", gotQuery)
+	}
+}
+
+func TestClientRejectsEmptyPathElement(t *testing.T) {
+	requested := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{})
+
+	// IDが空のGetで一覧を取得しない
+	if err := client.Get(context.Background(), Path("api", "v0", "groups", ""), nil, nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := client.Delete(context.Background(), Path("api", "v0", "groups", "", "namespaces", "ns1"), nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if requested {
+		t.Fatal("request must not be sent")
+	}
+}
+
+func TestClientSetsUserAgentAndAuth(t *testing.T) {
+	var userAgent, authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{
+		Auth: BearerToken("token1"),
+	})
+	if err := client.Get(context.Background(), Path("api", "v0", "groups"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if userAgent != DefaultUserAgent() {
+		t.Fatalf("unexpected User-Agent: %s", userAgent)
+	}
+	if authorization != "Bearer token1" {
+		t.Fatalf("unexpected Authorization: %s", authorization)
+	}
+
+	// 認証情報を作成できない場合は送信しない
+	client = newTestClient(t, srv, Config{
+		Auth: AuthenticatorFunc(func(req *http.Request) error {
+			return fmt.Errorf("token is expired")
+		}),
+	})
+	if err := client.Get(context.Background(), Path("api", "v0", "groups"), nil, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/notfound", func(ctx *gin.Context) {
+		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("VirtualMachine `vm1` is not found."), nil)
+	})
+	r.GET("/conflict", func(ctx *gin.Context) {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, meta.NewConflict("ImageTag `ubuntu.latest` is immutable."), nil)
+	})
+	r.GET("/ok", func(ctx *gin.Context) {
+		meta.ResponseJSON(ctx, http.StatusOK, nil, nil)
+	})
+	r.GET("/plain", func(ctx *gin.Context) {
+		ctx.String(http.StatusInternalServerError, "panic")
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{})
+	ctx := context.Background()
+
+	err := client.Get(ctx, "/notfound", nil, nil)
+	if !meta.IsNotFound(err) {
+		t.Fatalf("expected NotFound, but got %v", err)
+	}
+	if err.Error() != "VirtualMachine `vm1` is not found." {
+		t.Fatalf("unexpected message: %s", err.Error())
+	}
+
+	// レスポンスのreasonを優先する
+	if err := client.Get(ctx, "/conflict", nil, nil); !meta.IsConflict(err) {
+		t.Fatalf("expected Conflict, but got %v", err)
+	}
+
+	if err := client.Get(ctx, "/ok", nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// JSONでない場合はステータスコードから判断する
+	if err := client.Get(ctx, "/plain", nil, nil); !meta.IsInternal(err) {
+		t.Fatalf("expected Internal, but got %v", err)
+	}
+
+	// ストリームでも同じエラーを返す
+	if _, err := client.Stream(ctx, "/notfound", nil); !meta.IsNotFound(err) {
+		t.Fatalf("expected NotFound, but got %v", err)
+	}
+}
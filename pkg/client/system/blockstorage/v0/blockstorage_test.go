@@ -1,62 +1,38 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"log"
+	"context"
 	"testing"
 
-	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
-	grv0 "github.com/ophum/humstack/pkg/client/core/group/v0"
-	nsv0 "github.com/ophum/humstack/pkg/client/core/namespace/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	v0 "github.com/ophum/humstack/pkg/client/system/blockstorage/v0"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
 const (
-	imageURL               = "http://192.168.20.2:8082/bionic-server-cloudimg-amd64.img"
-	groupID                = "test-group-01"
-	groupFromHTTPID        = "test-group-02"
-	namespaceID            = "test-namespace-01"
-	namespaceFromHTTPID    = "test-namespace-02"
-	blockStorageID         = "test-blockstorage-00"
-	blockStorageFromHTTPID = "test-blockstorage-01"
+	groupID        = "test-group-00"
+	namespaceID    = "test-namespace-00"
+	blockStorageID = "test-bs-00"
 )
 
-func TestBlockStorageCreateEmpty(t *testing.T) {
+func TestBlockStorageClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewBlockStorageClient(newRESTClient(h))
+	ctx := context.Background()
 
-	grClient := grv0.NewGroupClient("http", "localhost", 8080)
-	gr, err := grClient.Create(&core.Group{
-		Meta: meta.Meta{
-			ID:   groupID,
-			Name: "test-group",
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	nsClient := nsv0.NewNamespaceClient("http", "localhost", 8080)
-	ns, err := nsClient.Create(&core.Namespace{
-		Meta: meta.Meta{
-			ID:    namespaceID,
-			Group: gr.ID,
-			Name:  "test-ns",
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
+	h.CreateNamespace(groupID, namespaceID)
 
-	client := NewBlockStorageClient("http", "localhost", 8080)
-	bs, err := client.Create(&system.BlockStorage{
+	bs, err := client.Create(ctx, &system.BlockStorage{
 		Meta: meta.Meta{
 			ID:        blockStorageID,
 			Name:      "test-bs",
-			Namespace: ns.ID,
-			Group:     gr.ID,
+			Namespace: namespaceID,
+			Group:     groupID,
 			Annotations: map[string]string{
 				"blockstoragev0/type":      "Local",
-				"blockstoragev0/node_name": "X1Carbon",
+				"blockstoragev0/node_name": h.NodeName,
 			},
 		},
 		Spec: system.BlockStorageSpec{
@@ -70,92 +46,41 @@ func TestBlockStorageCreateEmpty(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if bs.UID == "" || bs.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", bs.UID, bs.Generation)
+	}
 
-	buf, _ := json.Marshal(bs)
-	log.Println(string(buf))
-}
-
-func TestBlockStorageCreateHTTP(t *testing.T) {
+	bs = h.WaitForBlockStorageState(groupID, namespaceID, blockStorageID, system.BlockStorageStateActive)
 
-	grClient := grv0.NewGroupClient("http", "localhost", 8080)
-	gr, err := grClient.Create(&core.Group{
-		Meta: meta.Meta{
-			ID:   groupFromHTTPID,
-			Name: "test-group-from-http",
-		},
-	})
-	nsClient := nsv0.NewNamespaceClient("http", "localhost", 8080)
-	_, err = nsClient.Create(&core.Namespace{
-		Meta: meta.Meta{
-			ID:    namespaceFromHTTPID,
-			Name:  "test-ns2",
-			Group: gr.ID,
-		},
-	})
+	list, err := client.List(ctx, groupID, namespaceID)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	client := NewBlockStorageClient("http", "localhost", 8080)
-	bs, err := client.Create(&system.BlockStorage{
-		Meta: meta.Meta{
-			ID:        blockStorageFromHTTPID,
-			Name:      "test-bs-from-http",
-			Namespace: namespaceFromHTTPID,
-			Group:     gr.ID,
-			Annotations: map[string]string{
-				"blockstoragev0/type":      "Local",
-				"blockstoragev0/node_name": "developvbox",
-			},
-		},
-		Spec: system.BlockStorageSpec{
-			RequestSize: "10G",
-			LimitSize:   "10G",
-			From: system.BlockStorageFrom{
-				Type: system.BlockStorageFromTypeHTTP,
-				HTTP: system.BlockStorageFromHTTP{
-					URL: imageURL,
-				},
-			},
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
+	if len(list) != 1 || list[0].ID != blockStorageID {
+		t.Fatalf("unexpected list: %v", list)
 	}
 
-	buf, _ := json.Marshal(bs)
-	log.Println(string(buf))
-}
-func TestBlockStorageList(t *testing.T) {
-	client := NewBlockStorageClient("http", "localhost", 8080)
-
-	bsList, err := client.List(groupID, namespaceID)
+	bs.Spec.LimitSize = "20G"
+	bs, err = client.Update(ctx, bs)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	buf, _ := json.MarshalIndent(bsList, "", "  ")
-	log.Println(string(buf))
-}
-
-func TestBlockStorageGet(t *testing.T) {
-	client := NewBlockStorageClient("http", "localhost", 8080)
-
-	bsList, err := client.Get(groupFromHTTPID, namespaceFromHTTPID, blockStorageFromHTTPID)
-	if err != nil {
-		t.Fatal(err)
+	if bs.Spec.LimitSize != "20G" || bs.Generation != 2 {
+		t.Fatalf("unexpected blockstorage: limitSize=%s generation=%d", bs.Spec.LimitSize, bs.Generation)
 	}
 
-	buf, _ := json.MarshalIndent(bsList, "", "  ")
-	log.Println(string(buf))
-}
-
-func TestBlockStorageDeleteState(t *testing.T) {
-	client := NewBlockStorageClient("http", "localhost", 8080)
-
-	err := client.DeleteState(groupID, namespaceID, blockStorageID)
-	if err != nil {
+	if err := client.Delete(ctx, groupID, namespaceID, blockStorageID); err != nil {
 		t.Fatal(err)
 	}
+	h.WaitForNotFound("blockstorage", func(ctx context.Context) error {
+		_, err := client.Get(ctx, groupID, namespaceID, blockStorageID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
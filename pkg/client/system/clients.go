@@ -1,9 +1,15 @@
 package system
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 	bsv0 "github.com/ophum/humstack/pkg/client/system/blockstorage/v0"
 	imv0 "github.com/ophum/humstack/pkg/client/system/image/v0"
 	iev0 "github.com/ophum/humstack/pkg/client/system/imageentity/v0"
+	itv0 "github.com/ophum/humstack/pkg/client/system/imagetag/v0"
 	nodev0 "github.com/ophum/humstack/pkg/client/system/node/v0"
 	nodenetv0 "github.com/ophum/humstack/pkg/client/system/nodenetwork/v0"
 	vmv0 "github.com/ophum/humstack/pkg/client/system/virtualmachine/v0"
@@ -11,9 +17,6 @@ import (
 )
 
 type SystemV0Clients struct {
-	apiServerAddress string
-	apiServerPort    int32
-
 	nodeClient           *nodev0.NodeClient
 	nodeNetworkClient    *nodenetv0.NodeNetworkClient
 	blockstorageClient   *bsv0.BlockStorageClient
@@ -21,48 +24,80 @@ type SystemV0Clients struct {
 	virtualrouterClient  *vrv0.VirtualRouterClient
 	imageClient          *imv0.ImageClient
 	imageEntityClient    *iev0.ImageEntityClient
+	imageTagClient       *itv0.ImageTagClient
 }
 
-func NewSystemV0Clients(apiServerAddress string, apiServerPort int32) *SystemV0Clients {
-	nodeClient := nodev0.NewNodeClient("http", apiServerAddress, apiServerPort)
+// NewSystemV0Clients はsystemv0の全てのクライアントを作成する
+// clientは全てのクライアントで共有する
+func NewSystemV0Clients(client *rest.Client) *SystemV0Clients {
 	return &SystemV0Clients{
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-
-		nodeClient:           nodeClient,
-		nodeNetworkClient:    nodenetv0.NewNodeNetworkClient("http", apiServerAddress, apiServerPort),
-		blockstorageClient:   bsv0.NewBlockStorageClient("http", apiServerAddress, apiServerPort),
-		virtualmachineClient: vmv0.NewVirtualMachineClient("http", apiServerAddress, apiServerPort),
-		virtualrouterClient:  vrv0.NewVirtualRouterClient("http", apiServerAddress, apiServerPort),
-		imageClient:          imv0.NewImageClient("http", apiServerAddress, apiServerPort),
-		imageEntityClient:    iev0.NewImageEntityClient("http", apiServerAddress, apiServerPort),
+		nodeClient:           nodev0.NewNodeClient(client),
+		nodeNetworkClient:    nodenetv0.NewNodeNetworkClient(client),
+		blockstorageClient:   bsv0.NewBlockStorageClient(client),
+		virtualmachineClient: vmv0.NewVirtualMachineClient(client),
+		virtualrouterClient:  vrv0.NewVirtualRouterClient(client),
+		imageClient:          imv0.NewImageClient(client),
+		imageEntityClient:    iev0.NewImageEntityClient(client),
+		imageTagClient:       itv0.NewImageTagClient(client),
 	}
 }
 
-func (c *SystemV0Clients) Node() *nodev0.NodeClient {
+func (c *SystemV0Clients) Node() NodeInterface {
 	return c.nodeClient
 }
 
-func (c *SystemV0Clients) NodeNetwork() *nodenetv0.NodeNetworkClient {
+func (c *SystemV0Clients) NodeNetwork() NodeNetworkInterface {
 	return c.nodeNetworkClient
 }
 
-func (c *SystemV0Clients) BlockStorage() *bsv0.BlockStorageClient {
+func (c *SystemV0Clients) BlockStorage() BlockStorageInterface {
 	return c.blockstorageClient
 }
 
-func (c *SystemV0Clients) VirtualMachine() *vmv0.VirtualMachineClient {
+func (c *SystemV0Clients) VirtualMachine() VirtualMachineInterface {
 	return c.virtualmachineClient
 }
 
-func (c *SystemV0Clients) VirtualRouter() *vrv0.VirtualRouterClient {
+func (c *SystemV0Clients) VirtualRouter() VirtualRouterInterface {
 	return c.virtualrouterClient
 }
 
-func (c *SystemV0Clients) Image() *imv0.ImageClient {
+func (c *SystemV0Clients) Image() ImageInterface {
 	return c.imageClient
 }
 
-func (c *SystemV0Clients) ImageEntity() *iev0.ImageEntityClient {
+func (c *SystemV0Clients) ImageEntity() ImageEntityInterface {
 	return c.imageEntityClient
 }
+
+func (c *SystemV0Clients) ImageTag() ImageTagInterface {
+	return c.imageTagClient
+}
+
+// ResolveImageEntityID はimageのtagが指すimageEntityのIDを返す
+func (c *SystemV0Clients) ResolveImageEntityID(ctx context.Context, groupID, imageName, tag string) (string, error) {
+	return ResolveImageEntityID(ctx, c, groupID, imageName, tag)
+}
+
+// ResolveImageEntityID はcを使ってimageのtagが指すimageEntityのIDを解決する
+// ImageTagがない場合はImageSpec.EntityMapを参照する
+func ResolveImageEntityID(ctx context.Context, c SystemV0Interface, groupID, imageName, tag string) (string, error) {
+	it, err := c.ImageTag().GetByTag(ctx, groupID, imageName, tag)
+	if err == nil {
+		return it.Spec.ImageEntityID, nil
+	}
+	if !meta.IsNotFound(err) {
+		return "", err
+	}
+
+	image, err := c.Image().Get(ctx, groupID, imageName)
+	if err != nil {
+		return "", err
+	}
+
+	imageEntityID, ok := image.Spec.EntityMap[tag]
+	if !ok {
+		return "", fmt.Errorf("tag `%s` of image `%s` is not found", tag, imageName)
+	}
+	return imageEntityID, nil
+}
@@ -1,23 +1,17 @@
 package v0
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"io"
-	"log"
-	"net/http"
-	"path/filepath"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type ImageClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
 type ImageResponse struct {
@@ -36,131 +30,64 @@ type ImageListResponse struct {
 	} `json:"data"`
 }
 
-const (
-	basePathFormat = "api/v0/groups/%s/images"
-)
-
-func NewImageClient(scheme, apiServerAddress string, apiServerPort int32) *ImageClient {
+func NewImageClient(client *rest.Client) *ImageClient {
 	return &ImageClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accepted":     "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *ImageClient) Get(groupID, imageID string) (*system.Image, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, imageID))
-	if err != nil {
+func (c *ImageClient) Get(ctx context.Context, groupID, imageID string) (*system.Image, error) {
+	imResp := ImageResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID, imageID), nil, &imResp); err != nil {
 		return nil, err
 	}
-	body := resp.Body()
 
-	nodeResp := ImageResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &nodeResp.Data.Image, nil
+	return &imResp.Data.Image, nil
 }
 
-func (c *ImageClient) List(groupID string) ([]*system.Image, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, ""))
-	if err != nil {
+func (c *ImageClient) List(ctx context.Context, groupID string) ([]*system.Image, error) {
+	imResp := ImageListResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID), nil, &imResp); err != nil {
 		return nil, err
 	}
-	body := resp.Body()
 
-	nodeResp := ImageListResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
-		return nil, err
-	}
-	return nodeResp.Data.ImageList, nil
+	return imResp.Data.ImageList, nil
 }
 
-func (c *ImageClient) Create(image *system.Image) (*system.Image, error) {
-	body, err := json.Marshal(image)
-	if err != nil {
+func (c *ImageClient) Create(ctx context.Context, image *system.Image) (*system.Image, error) {
+	imResp := ImageResponse{}
+	if err := c.client.Post(ctx, c.getPath(image.Group), image, &imResp); err != nil {
 		return nil, err
 	}
-	log.Println(string(body))
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(image.Group, ""))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
-	nodeResp := ImageResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	log.Println(err)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.IsError() {
-		return nil, fmt.Errorf("error: %+v", nodeResp.Error)
-	}
 
-	return &nodeResp.Data.Image, nil
+	return &imResp.Data.Image, nil
 }
 
-func (c *ImageClient) Update(image *system.Image) (*system.Image, error) {
-	body, err := json.Marshal(image)
-	if err != nil {
-		return nil, err
-	}
-
-	fmt.Println(string(body))
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(image.Group, image.ID))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
-	nodeResp := ImageResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
+func (c *ImageClient) Update(ctx context.Context, image *system.Image) (*system.Image, error) {
+	imResp := ImageResponse{}
+	if err := c.client.Put(ctx, c.getPath(image.Group, image.ID), image, &imResp); err != nil {
 		return nil, err
 	}
 
-	return &nodeResp.Data.Image, nil
+	return &imResp.Data.Image, nil
 }
 
-func (c *ImageClient) Delete(groupID, imageID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, imageID))
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (c *ImageClient) Delete(ctx context.Context, groupID, imageID string) error {
+	return c.client.Delete(ctx, c.getPath(groupID, imageID), nil)
 }
 
-func (c *ImageClient) Download(groupID, imageID, tag string) (io.ReadCloser, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/tags/%s/download", c.getPath(groupID, imageID), tag))
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("not found")
-	}
+func (c *ImageClient) DeleteWithPropagation(ctx context.Context, groupID, imageID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(groupID, imageID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
+}
 
-	return resp.Body, nil
+// Download はimageのtagが指すimageEntityのファイルを返す
+// 呼び出し側でCloseしなければならない
+func (c *ImageClient) Download(ctx context.Context, groupID, imageID, tag string) (io.ReadCloser, error) {
+	return c.client.Stream(ctx, c.getPath(groupID, imageID)+rest.Path("tags", tag, "download"), nil)
 }
 
-func (c *ImageClient) getPath(groupID, imageID string) string {
-	return fmt.Sprintf("%s://%s",
-		c.scheme,
-		filepath.Join(
-			fmt.Sprintf("%s:%d",
-				c.apiServerAddress, c.apiServerPort),
-			fmt.Sprintf(basePathFormat, groupID),
-			imageID))
+func (c *ImageClient) getPath(groupID string, imageID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "groups", groupID, "images"}, imageID...)...)
 }
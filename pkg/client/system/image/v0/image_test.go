@@ -1,131 +1,85 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"os"
+	"context"
 	"testing"
 
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	v0 "github.com/ophum/humstack/pkg/client/system/image/v0"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
 const (
-	groupID = "test-group-00"
-	imageID = "test-image-00"
+	groupID     = "test-group-00"
+	namespaceID = "test-namespace-00"
+	imageID     = "test-image-00"
 )
 
-func TestImageCreate(t *testing.T) {
-	//grClient := grv0.NewGroupClient("http", "localhost", 8080)
-	//_, err := grClient.Create(&core.Group{
-	//	Meta: meta.Meta{
-	//		ID:   groupID,
-	//		Name: "test-gr",
-	//	},
-	//})
-	//if err != nil {
-	//	t.Fatal(err)
-	//}
+func TestImageClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewImageClient(newRESTClient(h))
+	ctx := context.Background()
 
-	client := NewImageClient("http", "localhost", 8080)
+	h.CreateNamespace(groupID, namespaceID)
 
-	net, err := client.Create(&system.Image{
+	image, err := client.Create(ctx, &system.Image{
 		Meta: meta.Meta{
-			ID:          imageID,
-			Name:        "test-image",
-			Group:       groupID,
-			Annotations: map[string]string{},
+			ID:    imageID,
+			Name:  "test-image",
+			Group: groupID,
 		},
 		Spec: system.ImageSpec{
 			EntityMap: map[string]string{
 				"latest": "testentity",
-				"0.1":    "testentity2",
 			},
 		},
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	buf, _ := json.Marshal(net)
-	log.Println(string(buf))
-
-}
-
-func TestImageList(t *testing.T) {
-	client := NewImageClient("http", "localhost", 8080)
-
-	netList, err := client.List(groupID)
-	if err != nil {
-		t.Fatal(err)
+	if image.UID == "" || image.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", image.UID, image.Generation)
 	}
 
-	buf, _ := json.MarshalIndent(netList, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestImageGet(t *testing.T) {
-	client := NewImageClient("http", "localhost", 8080)
-
-	net, err := client.Get(groupID, imageID)
+	image, err = client.Get(ctx, groupID, imageID)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	buf, _ := json.MarshalIndent(net, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestImageUpdate(t *testing.T) {
-	client := NewImageClient("http", "localhost", 8080)
-
-	net, err := client.Update(&system.Image{
-		Meta: meta.Meta{
-			ID:    imageID,
-			Name:  "test-image-changed1",
-			Group: groupID,
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
+	if image.Spec.EntityMap["latest"] != "testentity" {
+		t.Fatalf("unexpected entityMap: %v", image.Spec.EntityMap)
 	}
 
-	buf, _ := json.Marshal(net)
-	log.Println(string(buf))
-
-}
-
-func TestImageDelete(t *testing.T) {
-	client := NewImageClient("http", "localhost", 8080)
-
-	err := client.Delete(groupID, imageID)
+	list, err := client.List(ctx, groupID)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-}
-
-func TestImageDownload(t *testing.T) {
-	client := NewImageClient("http", "localhost", 8080)
-
-	file, err := os.Create("/tmp/test1.img")
-	if err != nil {
-		t.Fatal(err)
+	if len(list) != 1 || list[0].ID != imageID {
+		t.Fatalf("unexpected list: %v", list)
 	}
-	defer file.Close()
 
-	stream, err := client.Download("group1", "test-image-00", "test")
+	image.Spec.EntityMap["0.1"] = "testentity2"
+	image, err = client.Update(ctx, image)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer stream.Close()
+	if image.Spec.EntityMap["0.1"] != "testentity2" || image.Generation != 2 {
+		t.Fatalf("unexpected image: entityMap=%v generation=%d", image.Spec.EntityMap, image.Generation)
+	}
 
-	if _, err := io.Copy(file, stream); err != nil {
-		log.Fatal(err)
+	if err := client.Delete(ctx, groupID, imageID); err != nil {
+		t.Fatal(err)
 	}
+	h.WaitForNotFound("image", func(ctx context.Context) error {
+		_, err := client.Get(ctx, groupID, imageID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
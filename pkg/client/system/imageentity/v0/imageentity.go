@@ -1,21 +1,16 @@
 package v0
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"path/filepath"
+	"context"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type ImageEntityClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
 type ImageEntityResponse struct {
@@ -34,118 +29,58 @@ type ImageEntityListResponse struct {
 	} `json:"data"`
 }
 
-const (
-	basePathFormat = "api/v0/groups/%s/imageentities"
-)
-
-func NewImageEntityClient(scheme, apiServerAddress string, apiServerPort int32) *ImageEntityClient {
+func NewImageEntityClient(client *rest.Client) *ImageEntityClient {
 	return &ImageEntityClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accepted":     "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *ImageEntityClient) Get(groupID, imageEntityID string) (*system.ImageEntity, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, imageEntityID))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
-	nodeResp := ImageEntityResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
+func (c *ImageEntityClient) Get(ctx context.Context, groupID, imageEntityID string) (*system.ImageEntity, error) {
+	ieResp := ImageEntityResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID, imageEntityID), nil, &ieResp); err != nil {
 		return nil, err
 	}
 
-	return &nodeResp.Data.ImageEntity, nil
+	return &ieResp.Data.ImageEntity, nil
 }
 
-func (c *ImageEntityClient) List(groupID string) ([]*system.ImageEntity, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, ""))
-	if err != nil {
+func (c *ImageEntityClient) List(ctx context.Context, groupID string) ([]*system.ImageEntity, error) {
+	ieResp := ImageEntityListResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID), nil, &ieResp); err != nil {
 		return nil, err
 	}
-	body := resp.Body()
 
-	nodeResp := ImageEntityListResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
-		return nil, err
-	}
-	return nodeResp.Data.ImageEntityList, nil
+	return ieResp.Data.ImageEntityList, nil
 }
 
-func (c *ImageEntityClient) Create(imageEntity *system.ImageEntity) (*system.ImageEntity, error) {
-	body, err := json.Marshal(imageEntity)
-	if err != nil {
-		return nil, err
-	}
-	log.Println(string(body))
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(imageEntity.Group, ""))
-	if err != nil {
+func (c *ImageEntityClient) Create(ctx context.Context, imageEntity *system.ImageEntity) (*system.ImageEntity, error) {
+	ieResp := ImageEntityResponse{}
+	if err := c.client.Post(ctx, c.getPath(imageEntity.Group), imageEntity, &ieResp); err != nil {
 		return nil, err
 	}
-	body = resp.Body()
 
-	nodeResp := ImageEntityResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	log.Println(err)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.IsError() {
-		return nil, fmt.Errorf("error: %+v", nodeResp.Error)
-	}
-
-	return &nodeResp.Data.ImageEntity, nil
+	return &ieResp.Data.ImageEntity, nil
 }
 
-func (c *ImageEntityClient) Update(imageEntity *system.ImageEntity) (*system.ImageEntity, error) {
-	body, err := json.Marshal(imageEntity)
-	if err != nil {
-		return nil, err
-	}
-
-	fmt.Println(string(body))
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(imageEntity.Group, imageEntity.ID))
-	if err != nil {
+func (c *ImageEntityClient) Update(ctx context.Context, imageEntity *system.ImageEntity) (*system.ImageEntity, error) {
+	ieResp := ImageEntityResponse{}
+	if err := c.client.Put(ctx, c.getPath(imageEntity.Group, imageEntity.ID), imageEntity, &ieResp); err != nil {
 		return nil, err
 	}
-	body = resp.Body()
 
-	nodeResp := ImageEntityResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &nodeResp.Data.ImageEntity, nil
+	return &ieResp.Data.ImageEntity, nil
 }
 
-func (c *ImageEntityClient) Delete(groupID, imageEntityID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, imageEntityID))
-	if err != nil {
-		return err
-	}
+func (c *ImageEntityClient) Delete(ctx context.Context, groupID, imageEntityID string) error {
+	return c.client.Delete(ctx, c.getPath(groupID, imageEntityID), nil)
+}
 
-	return nil
+func (c *ImageEntityClient) DeleteWithPropagation(ctx context.Context, groupID, imageEntityID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(groupID, imageEntityID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
 }
 
-func (c *ImageEntityClient) getPath(groupID, imageEntityID string) string {
-	return fmt.Sprintf("%s://%s",
-		c.scheme,
-		filepath.Join(
-			fmt.Sprintf("%s:%d",
-				c.apiServerAddress, c.apiServerPort),
-			fmt.Sprintf(basePathFormat, groupID),
-			imageEntityID))
+func (c *ImageEntityClient) getPath(groupID string, imageEntityID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "groups", groupID, "imageentities"}, imageEntityID...)...)
 }
@@ -1,45 +1,39 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
 	"testing"
 
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	v0 "github.com/ophum/humstack/pkg/client/system/imageentity/v0"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
 const (
-	groupID = "test-group-00"
-	imageID = "test-image-00"
+	groupID       = "test-group-00"
+	namespaceID   = "test-namespace-00"
+	imageEntityID = "test-imageentity-00"
 )
 
-func TestImageEntityCreate(t *testing.T) {
-	//grClient := grv0.NewGroupClient("http", "localhost", 8080)
-	//_, err := grClient.Create(&core.Group{
-	//	Meta: meta.Meta{
-	//		ID:   groupID,
-	//		Name: "test-gr",
-	//	},
-	//})
-	//if err != nil {
-	//	t.Fatal(err)
-	//}
+func TestImageEntityClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewImageEntityClient(newRESTClient(h))
+	ctx := context.Background()
 
-	client := NewImageEntityClient("http", "localhost", 8080)
+	h.CreateNamespace(groupID, namespaceID)
 
-	net, err := client.Create(&system.ImageEntity{
+	entity, err := client.Create(ctx, &system.ImageEntity{
 		Meta: meta.Meta{
-			ID:          imageID,
-			Name:        "test-image",
-			Group:       groupID,
-			Annotations: map[string]string{},
+			ID:    imageEntityID,
+			Name:  "test-imageentity",
+			Group: groupID,
 		},
 		Spec: system.ImageEntitySpec{
 			Hash: "hogehoge",
 			Source: system.ImageEntitySource{
-				Namespace:      "testns",
+				Namespace:      namespaceID,
 				BlockStorageID: "testbs",
 			},
 		},
@@ -47,63 +41,47 @@ func TestImageEntityCreate(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if entity.UID == "" || entity.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", entity.UID, entity.Generation)
+	}
 
-	buf, _ := json.Marshal(net)
-	log.Println(string(buf))
-
-}
-
-func TestImageEntityList(t *testing.T) {
-	client := NewImageEntityClient("http", "localhost", 8080)
-
-	netList, err := client.List(groupID)
+	entity, err = client.Get(ctx, groupID, imageEntityID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if entity.Spec.Hash != "hogehoge" {
+		t.Fatalf("unexpected hash: %s", entity.Spec.Hash)
+	}
 
-	buf, _ := json.MarshalIndent(netList, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestImageEntityGet(t *testing.T) {
-	client := NewImageEntityClient("http", "localhost", 8080)
-
-	net, err := client.Get(groupID, imageID)
+	list, err := client.List(ctx, groupID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(list) != 1 || list[0].ID != imageEntityID {
+		t.Fatalf("unexpected list: %v", list)
+	}
 
-	buf, _ := json.MarshalIndent(net, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestImageEntityUpdate(t *testing.T) {
-	client := NewImageEntityClient("http", "localhost", 8080)
-
-	net, err := client.Update(&system.ImageEntity{
-		Meta: meta.Meta{
-			ID:    imageID,
-			Name:  "test-image-changed1",
-			Group: groupID,
-		},
-	})
+	entity.Spec.Hash = "fugafuga"
+	entity, err = client.Update(ctx, entity)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if entity.Spec.Hash != "fugafuga" || entity.Generation != 2 {
+		t.Fatalf("unexpected imageentity: hash=%s generation=%d", entity.Spec.Hash, entity.Generation)
+	}
 
-	buf, _ := json.Marshal(net)
-	log.Println(string(buf))
-
-}
-
-func TestImageEntityDelete(t *testing.T) {
-	client := NewImageEntityClient("http", "localhost", 8080)
-
-	err := client.Delete(groupID, imageID)
-	if err != nil {
+	if err := client.Delete(ctx, groupID, imageEntityID); err != nil {
 		t.Fatal(err)
 	}
+	h.WaitForNotFound("imageentity", func(ctx context.Context) error {
+		_, err := client.Get(ctx, groupID, imageEntityID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
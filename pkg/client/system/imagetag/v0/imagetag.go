@@ -0,0 +1,112 @@
+package v0
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/imagetag"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+)
+
+type ImageTagClient struct {
+	client *rest.Client
+}
+
+type ImageTagResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		ImageTag system.ImageTag `json:"imagetag"`
+	} `json:"data"`
+}
+
+type ImageTagListResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		ImageTagList []*system.ImageTag `json:"imagetags"`
+	} `json:"data"`
+}
+
+func NewImageTagClient(client *rest.Client) *ImageTagClient {
+	return &ImageTagClient{
+		client: client,
+	}
+}
+
+func (c *ImageTagClient) Get(ctx context.Context, groupID, imageTagID string) (*system.ImageTag, error) {
+	itResp := ImageTagResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID, imageTagID), nil, &itResp); err != nil {
+		return nil, err
+	}
+
+	return &itResp.Data.ImageTag, nil
+}
+
+// GetByTag はimageNameとtagからImageTagを取得する
+// IDが一致してもspecが違う場合は見つからなかったものとする
+func (c *ImageTagClient) GetByTag(ctx context.Context, groupID, imageName, tag string) (*system.ImageTag, error) {
+	it, err := c.Get(ctx, groupID, imagetag.GetID(imageName, tag))
+	if err != nil {
+		return nil, err
+	}
+	if !imagetag.Matches(it, imageName, tag) {
+		return nil, meta.NewNotFound("ImageTag `%s` is not found.", imagetag.GetID(imageName, tag))
+	}
+
+	return it, nil
+}
+
+func (c *ImageTagClient) List(ctx context.Context, groupID string) ([]*system.ImageTag, error) {
+	return c.list(ctx, groupID, url.Values{})
+}
+
+// ListByImage はimageNameのimageのtagのみ返す
+func (c *ImageTagClient) ListByImage(ctx context.Context, groupID, imageName string) ([]*system.ImageTag, error) {
+	return c.list(ctx, groupID, url.Values{
+		"imageName": []string{imageName},
+	})
+}
+
+func (c *ImageTagClient) list(ctx context.Context, groupID string, query url.Values) ([]*system.ImageTag, error) {
+	itResp := ImageTagListResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID), query, &itResp); err != nil {
+		return nil, err
+	}
+
+	return itResp.Data.ImageTagList, nil
+}
+
+func (c *ImageTagClient) Create(ctx context.Context, imageTag *system.ImageTag) (*system.ImageTag, error) {
+	itResp := ImageTagResponse{}
+	if err := c.client.Post(ctx, c.getPath(imageTag.Group), imageTag, &itResp); err != nil {
+		return nil, err
+	}
+
+	return &itResp.Data.ImageTag, nil
+}
+
+func (c *ImageTagClient) Update(ctx context.Context, imageTag *system.ImageTag) (*system.ImageTag, error) {
+	itResp := ImageTagResponse{}
+	if err := c.client.Put(ctx, c.getPath(imageTag.Group, imageTag.ID), imageTag, &itResp); err != nil {
+		return nil, err
+	}
+
+	return &itResp.Data.ImageTag, nil
+}
+
+func (c *ImageTagClient) Delete(ctx context.Context, groupID, imageTagID string) error {
+	return c.client.Delete(ctx, c.getPath(groupID, imageTagID), nil)
+}
+
+func (c *ImageTagClient) DeleteWithPropagation(ctx context.Context, groupID, imageTagID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(groupID, imageTagID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
+}
+
+func (c *ImageTagClient) getPath(groupID string, imageTagID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "groups", groupID, "imagetags"}, imageTagID...)...)
+}
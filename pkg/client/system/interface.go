@@ -0,0 +1,102 @@
+package system
+
+import (
+	"context"
+	"io"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+// SystemV0Interface はsystemv0のクライアント
+// テストではpkg/client/fakeの実装に差し替えられる
+type SystemV0Interface interface {
+	Node() NodeInterface
+	NodeNetwork() NodeNetworkInterface
+	BlockStorage() BlockStorageInterface
+	VirtualMachine() VirtualMachineInterface
+	VirtualRouter() VirtualRouterInterface
+	Image() ImageInterface
+	ImageEntity() ImageEntityInterface
+	ImageTag() ImageTagInterface
+
+	// ResolveImageEntityID はimageのtagが指すimageEntityのIDを返す
+	ResolveImageEntityID(ctx context.Context, groupID, imageName, tag string) (string, error)
+}
+
+type NodeInterface interface {
+	Get(ctx context.Context, nodeID string) (*system.Node, error)
+	List(ctx context.Context) ([]*system.Node, error)
+	Create(ctx context.Context, node *system.Node) (*system.Node, error)
+	Update(ctx context.Context, node *system.Node) (*system.Node, error)
+	Delete(ctx context.Context, nodeID string) error
+	DeleteWithPropagation(ctx context.Context, nodeID string, policy meta.DeletionPropagation) error
+}
+
+type NodeNetworkInterface interface {
+	Get(ctx context.Context, groupID, namespaceID, nodenetworkID string) (*system.NodeNetwork, error)
+	List(ctx context.Context, groupID, namespaceID string) ([]*system.NodeNetwork, error)
+	Create(ctx context.Context, nodenetwork *system.NodeNetwork) (*system.NodeNetwork, error)
+	Update(ctx context.Context, nodenetwork *system.NodeNetwork) (*system.NodeNetwork, error)
+	Delete(ctx context.Context, groupID, namespaceID, nodenetworkID string) error
+	DeleteWithPropagation(ctx context.Context, groupID, namespaceID, nodenetworkID string, policy meta.DeletionPropagation) error
+}
+
+type BlockStorageInterface interface {
+	Get(ctx context.Context, groupID, namespaceID, blockStorageID string) (*system.BlockStorage, error)
+	List(ctx context.Context, groupID, namespaceID string) ([]*system.BlockStorage, error)
+	Create(ctx context.Context, blockstorage *system.BlockStorage) (*system.BlockStorage, error)
+	Update(ctx context.Context, blockstorage *system.BlockStorage) (*system.BlockStorage, error)
+	Delete(ctx context.Context, groupID, namespaceID, blockStorageID string) error
+	DeleteWithPropagation(ctx context.Context, groupID, namespaceID, blockStorageID string, policy meta.DeletionPropagation) error
+}
+
+type VirtualMachineInterface interface {
+	Get(ctx context.Context, groupID, namespaceID, virtualMachineID string) (*system.VirtualMachine, error)
+	List(ctx context.Context, groupID, namespaceID string) ([]*system.VirtualMachine, error)
+	Create(ctx context.Context, vm *system.VirtualMachine) (*system.VirtualMachine, error)
+	Update(ctx context.Context, vm *system.VirtualMachine) (*system.VirtualMachine, error)
+	Delete(ctx context.Context, groupID, namespaceID, virtualMachineID string) error
+	DeleteWithPropagation(ctx context.Context, groupID, namespaceID, virtualMachineID string, policy meta.DeletionPropagation) error
+}
+
+type VirtualRouterInterface interface {
+	Get(ctx context.Context, groupID, namespaceID, virtualRouterID string) (*system.VirtualRouter, error)
+	List(ctx context.Context, groupID, namespaceID string) ([]*system.VirtualRouter, error)
+	Create(ctx context.Context, vr *system.VirtualRouter) (*system.VirtualRouter, error)
+	Update(ctx context.Context, vr *system.VirtualRouter) (*system.VirtualRouter, error)
+	Delete(ctx context.Context, groupID, namespaceID, virtualRouterID string) error
+	DeleteWithPropagation(ctx context.Context, groupID, namespaceID, virtualRouterID string, policy meta.DeletionPropagation) error
+}
+
+type ImageInterface interface {
+	Get(ctx context.Context, groupID, imageID string) (*system.Image, error)
+	List(ctx context.Context, groupID string) ([]*system.Image, error)
+	Create(ctx context.Context, image *system.Image) (*system.Image, error)
+	Update(ctx context.Context, image *system.Image) (*system.Image, error)
+	Delete(ctx context.Context, groupID, imageID string) error
+	DeleteWithPropagation(ctx context.Context, groupID, imageID string, policy meta.DeletionPropagation) error
+	Download(ctx context.Context, groupID, imageID, tag string) (io.ReadCloser, error)
+}
+
+type ImageEntityInterface interface {
+	Get(ctx context.Context, groupID, imageEntityID string) (*system.ImageEntity, error)
+	List(ctx context.Context, groupID string) ([]*system.ImageEntity, error)
+	Create(ctx context.Context, imageEntity *system.ImageEntity) (*system.ImageEntity, error)
+	Update(ctx context.Context, imageEntity *system.ImageEntity) (*system.ImageEntity, error)
+	Delete(ctx context.Context, groupID, imageEntityID string) error
+	DeleteWithPropagation(ctx context.Context, groupID, imageEntityID string, policy meta.DeletionPropagation) error
+}
+
+type ImageTagInterface interface {
+	Get(ctx context.Context, groupID, imageTagID string) (*system.ImageTag, error)
+	GetByTag(ctx context.Context, groupID, imageName, tag string) (*system.ImageTag, error)
+	List(ctx context.Context, groupID string) ([]*system.ImageTag, error)
+	ListByImage(ctx context.Context, groupID, imageName string) ([]*system.ImageTag, error)
+	Create(ctx context.Context, imageTag *system.ImageTag) (*system.ImageTag, error)
+	Update(ctx context.Context, imageTag *system.ImageTag) (*system.ImageTag, error)
+	Delete(ctx context.Context, groupID, imageTagID string) error
+	DeleteWithPropagation(ctx context.Context, groupID, imageTagID string, policy meta.DeletionPropagation) error
+}
+
+var _ SystemV0Interface = &SystemV0Clients{}
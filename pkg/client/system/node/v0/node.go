@@ -1,21 +1,16 @@
 package v0
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"path/filepath"
+	"context"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type NodeClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
 type NodeResponse struct {
@@ -34,110 +29,58 @@ type NodeListResponse struct {
 	} `json:"data"`
 }
 
-const (
-	basePath = "api/v0/nodes"
-)
-
-func NewNodeClient(scheme, apiServerAddress string, apiServerPort int32) *NodeClient {
+func NewNodeClient(client *rest.Client) *NodeClient {
 	return &NodeClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accepted":     "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *NodeClient) Get(nodeID string) (*system.Node, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(nodeID))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
+func (c *NodeClient) Get(ctx context.Context, nodeID string) (*system.Node, error) {
 	nodeResp := NodeResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
+	if err := c.client.Get(ctx, c.getPath(nodeID), nil, &nodeResp); err != nil {
 		return nil, err
 	}
 
 	return &nodeResp.Data.Node, nil
 }
 
-func (c *NodeClient) List() ([]*system.Node, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(""))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
+func (c *NodeClient) List(ctx context.Context) ([]*system.Node, error) {
 	nodeResp := NodeListResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
+	if err := c.client.Get(ctx, c.getPath(), nil, &nodeResp); err != nil {
 		return nil, err
 	}
+
 	return nodeResp.Data.NodeList, nil
 }
 
-func (c *NodeClient) Create(node *system.Node) (*system.Node, error) {
-	body, err := json.Marshal(node)
-	if err != nil {
-		return nil, err
-	}
-	log.Println(string(body))
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(""))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
+func (c *NodeClient) Create(ctx context.Context, node *system.Node) (*system.Node, error) {
 	nodeResp := NodeResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
+	if err := c.client.Post(ctx, c.getPath(), node, &nodeResp); err != nil {
 		return nil, err
 	}
 
-	if resp.IsError() {
-		return nil, fmt.Errorf("error: %+v", nodeResp.Error)
-	}
-
 	return &nodeResp.Data.Node, nil
 }
 
-func (c *NodeClient) Update(node *system.Node) (*system.Node, error) {
-	body, err := json.Marshal(node)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(node.ID))
-	if err != nil {
-		return nil, err
-	}
-	body = resp.Body()
-
+func (c *NodeClient) Update(ctx context.Context, node *system.Node) (*system.Node, error) {
 	nodeResp := NodeResponse{}
-	err = json.Unmarshal(body, &nodeResp)
-	if err != nil {
+	if err := c.client.Put(ctx, c.getPath(node.ID), node, &nodeResp); err != nil {
 		return nil, err
 	}
 
 	return &nodeResp.Data.Node, nil
 }
 
-func (c *NodeClient) Delete(nodeID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(nodeID))
-	if err != nil {
-		return err
-	}
+func (c *NodeClient) Delete(ctx context.Context, nodeID string) error {
+	return c.client.Delete(ctx, c.getPath(nodeID), nil)
+}
 
-	return nil
+func (c *NodeClient) DeleteWithPropagation(ctx context.Context, nodeID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(nodeID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
 }
 
-func (c *NodeClient) getPath(path string) string {
-	return fmt.Sprintf("%s://%s", c.scheme, filepath.Join(fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort), basePath, path))
+func (c *NodeClient) getPath(nodeID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "nodes"}, nodeID...)...)
 }
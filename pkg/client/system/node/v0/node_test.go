@@ -1,93 +1,79 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
 	"testing"
 
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	v0 "github.com/ophum/humstack/pkg/client/system/node/v0"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
-func TestNodeCreate(t *testing.T) {
-	client := NewNodeClient("http", "localhost", 8080)
+const nodeID = "test-node-00"
 
-	node, err := client.Create(&system.Node{
+func TestNodeClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewNodeClient(newRESTClient(h))
+	ctx := context.Background()
+
+	node, err := client.Create(ctx, &system.Node{
 		Meta: meta.Meta{
+			ID:   nodeID,
 			Name: "test-node",
 		},
 		Spec: system.NodeSpec{
-			LimitMemory: "8Gi",
-			LimitVcpus:  "10000m",
+			Address:     "192.168.0.10",
+			LimitVcpus:  "4",
+			LimitMemory: "8G",
 		},
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if node.UID == "" || node.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", node.UID, node.Generation)
+	}
 
-	buf, _ := json.Marshal(node)
-	log.Println(string(buf))
-
-}
-
-func TestNodeList(t *testing.T) {
-	client := NewNodeClient("http", "localhost", 8080)
-
-	nodeList, err := client.List()
+	node, err = client.Get(ctx, nodeID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if node.Spec.Address != "192.168.0.10" {
+		t.Fatalf("unexpected address: %s", node.Spec.Address)
+	}
 
-	buf, _ := json.MarshalIndent(nodeList, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestNodeGet(t *testing.T) {
-	client := NewNodeClient("http", "localhost", 8080)
-
-	node, err := client.Get("6839fa9d-f6dd-45c9-8e89-6d8f08565dec")
+	// harnessが登録したノードも含まれる
+	list, err := client.List(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(list) != len(h.NodeNames)+1 {
+		t.Fatalf("unexpected list: %v", list)
+	}
 
-	buf, _ := json.MarshalIndent(node, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestNodeUpdate(t *testing.T) {
-	client := NewNodeClient("http", "localhost", 8080)
-
-	node, err := client.Update(&system.Node{
-		Meta: meta.Meta{
-			ID:   "37825cc2-10b6-4208-9f8d-ec11ee75f9b3",
-			Name: "test-node-changed1",
-		},
-		Spec: system.NodeSpec{
-			LimitMemory: "16Gi",
-			LimitVcpus:  "10000m",
-		},
-		Status: system.NodeStatus{
-			State: system.NodeStateNotReady,
-		},
-	})
+	node.Spec.LimitMemory = "16G"
+	node, err = client.Update(ctx, node)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if node.Spec.LimitMemory != "16G" || node.Generation != 2 {
+		t.Fatalf("unexpected node: limitMemory=%s generation=%d", node.Spec.LimitMemory, node.Generation)
+	}
 
-	buf, _ := json.Marshal(node)
-	log.Println(string(buf))
-
-}
-
-func TestNodeDelete(t *testing.T) {
-	client := NewNodeClient("http", "localhost", 8080)
-
-	err := client.Delete("37825cc2-10b6-4208-9f8d-ec11ee75f9b3")
-	if err != nil {
+	if err := client.Delete(ctx, nodeID); err != nil {
 		t.Fatal(err)
 	}
+	h.WaitForNotFound("node", func(ctx context.Context) error {
+		_, err := client.Get(ctx, nodeID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
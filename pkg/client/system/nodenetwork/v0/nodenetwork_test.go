@@ -1,126 +1,89 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
 	"testing"
 
-	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
-	grv0 "github.com/ophum/humstack/pkg/client/core/group/v0"
-	nsv0 "github.com/ophum/humstack/pkg/client/core/namespace/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	v0 "github.com/ophum/humstack/pkg/client/system/nodenetwork/v0"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
 const (
-	groupID     = "test-group-00"
-	namespaceID = "test-namespace-00"
-	networkID   = "test-network-00"
+	groupID       = "test-group-00"
+	namespaceID   = "test-namespace-00"
+	nodeNetworkID = "test-nodenetwork-00"
 )
 
-func TestNetworkCreate(t *testing.T) {
-	grClient := grv0.NewGroupClient("http", "localhost", 8080)
-	_, err := grClient.Create(&core.Group{
-		Meta: meta.Meta{
-			ID:   groupID,
-			Name: "test-gr",
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
+func TestNodeNetworkClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewNodeNetworkClient(newRESTClient(h))
+	ctx := context.Background()
 
-	nsClient := nsv0.NewNamespaceClient("http", "localhost", 8080)
-	_, err = nsClient.Create(&core.Namespace{
-		Meta: meta.Meta{
-			ID:    namespaceID,
-			Name:  "test-ns",
-			Group: groupID,
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
+	h.CreateNamespace(groupID, namespaceID)
 
-	client := NewNetworkClient("http", "localhost", 8080)
-
-	net, err := client.Create(&system.Network{
+	nodeNet, err := client.Create(ctx, &system.NodeNetwork{
 		Meta: meta.Meta{
-			ID:        networkID,
-			Name:      "test-network",
+			ID:        nodeNetworkID,
+			Name:      "test-nodenetwork",
 			Namespace: namespaceID,
 			Group:     groupID,
 			Annotations: map[string]string{
-				"networkv0/network_type": "VXLAN",
+				"nodenetworkv0/network_type": "Bridge",
+				"nodenetworkv0/node_name":    h.NodeName,
 			},
 		},
-		Spec: system.NetworkSpec{
-			IPv4CIDR: "10.0.0.0/24",
+		Spec: system.NodeNetworkSpec{
 			ID:       "100",
+			IPv4CIDR: "10.0.0.0/24",
 		},
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	buf, _ := json.Marshal(net)
-	log.Println(string(buf))
-
-}
-
-func TestNetworkList(t *testing.T) {
-	client := NewNetworkClient("http", "localhost", 8080)
-
-	netList, err := client.List(groupID, namespaceID)
-	if err != nil {
-		t.Fatal(err)
+	if nodeNet.UID == "" || nodeNet.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", nodeNet.UID, nodeNet.Generation)
 	}
 
-	buf, _ := json.MarshalIndent(netList, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestNetworkGet(t *testing.T) {
-	client := NewNetworkClient("http", "localhost", 8080)
+	h.WaitFor("nodenetwork to be available", func(ctx context.Context) (bool, error) {
+		nodeNet, err = client.Get(ctx, groupID, namespaceID, nodeNetworkID)
+		if err != nil {
+			return false, err
+		}
+		return nodeNet.Status.State == system.NetworkStateAvailable, nil
+	})
 
-	net, err := client.Get(groupID, namespaceID, networkID)
+	list, err := client.List(ctx, groupID, namespaceID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(list) != 1 || list[0].ID != nodeNetworkID {
+		t.Fatalf("unexpected list: %v", list)
+	}
 
-	buf, _ := json.MarshalIndent(net, "", "  ")
-	fmt.Println(string(buf))
-
-}
-
-func TestNetworkUpdate(t *testing.T) {
-	client := NewNetworkClient("http", "localhost", 8080)
-
-	net, err := client.Update(&system.Network{
-		Meta: meta.Meta{
-			ID:        networkID,
-			Name:      "test-network-changed1",
-			Namespace: namespaceID,
-			Group:     groupID,
-		},
-	})
+	nodeNet.Spec.IPv4CIDR = "10.0.1.0/24"
+	nodeNet, err = client.Update(ctx, nodeNet)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if nodeNet.Spec.IPv4CIDR != "10.0.1.0/24" || nodeNet.Generation != 2 {
+		t.Fatalf("unexpected nodenetwork: ipv4CIDR=%s generation=%d", nodeNet.Spec.IPv4CIDR, nodeNet.Generation)
+	}
 
-	buf, _ := json.Marshal(net)
-	log.Println(string(buf))
-
-}
-
-func TestNetworkDelete(t *testing.T) {
-	client := NewNetworkClient("http", "localhost", 8080)
-
-	err := client.Delete(groupID, namespaceID, networkID)
-	if err != nil {
+	if err := client.Delete(ctx, groupID, namespaceID, nodeNetworkID); err != nil {
 		t.Fatal(err)
 	}
+	h.WaitForNotFound("nodenetwork", func(ctx context.Context) error {
+		_, err := client.Get(ctx, groupID, namespaceID, nodeNetworkID)
+		return err
+	})
+}
 
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
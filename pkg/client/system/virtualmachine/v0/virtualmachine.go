@@ -1,21 +1,16 @@
 package v0
 
 import (
-	"encoding/json"
-	"fmt"
-	"path/filepath"
+	"context"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 )
 
 type VirtualMachineClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
 type VirtualMachineResponse struct {
@@ -34,128 +29,58 @@ type VirtualMachineListResponse struct {
 	} `json:"data"`
 }
 
-const (
-	basePathFormat = "api/v0/groups/%s/namespaces/%s/virtualmachines"
-)
-
-func NewVirtualMachineClient(scheme, apiServerAddress string, apiServerPort int32) *VirtualMachineClient {
+func NewVirtualMachineClient(client *rest.Client) *VirtualMachineClient {
 	return &VirtualMachineClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accept":       "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *VirtualMachineClient) Get(groupID, namespaceID, virtualMachineID string) (*system.VirtualMachine, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, virtualMachineID))
-	if err != nil {
-		return nil, err
-	}
-	body := resp.Body()
-
+func (c *VirtualMachineClient) Get(ctx context.Context, groupID, namespaceID, virtualMachineID string) (*system.VirtualMachine, error) {
 	vmRes := VirtualMachineResponse{}
-	err = json.Unmarshal(body, &vmRes)
-	if err != nil {
+	if err := c.client.Get(ctx, c.getPath(groupID, namespaceID, virtualMachineID), nil, &vmRes); err != nil {
 		return nil, err
 	}
 
 	return &vmRes.Data.VirtualMachine, nil
 }
 
-func (c *VirtualMachineClient) List(groupID, namespaceID string) ([]*system.VirtualMachine, error) {
-	res, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, ""))
-	if err != nil {
-		return nil, err
-	}
-	body := res.Body()
-
-	vmListRes := VirtualMachineListResponse{}
-	err = json.Unmarshal(body, &vmListRes)
-	if err != nil {
+func (c *VirtualMachineClient) List(ctx context.Context, groupID, namespaceID string) ([]*system.VirtualMachine, error) {
+	vmRes := VirtualMachineListResponse{}
+	if err := c.client.Get(ctx, c.getPath(groupID, namespaceID), nil, &vmRes); err != nil {
 		return nil, err
 	}
 
-	return vmListRes.Data.VirtualMachineList, nil
+	return vmRes.Data.VirtualMachineList, nil
 }
 
-func (c *VirtualMachineClient) Create(vm *system.VirtualMachine) (*system.VirtualMachine, error) {
-	body, err := json.Marshal(vm)
-	if err != nil {
-		return nil, err
-	}
-
-	res, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(vm.Group, vm.Namespace, ""))
-	if err != nil {
-		return nil, err
-	}
-	body = res.Body()
-
+func (c *VirtualMachineClient) Create(ctx context.Context, vm *system.VirtualMachine) (*system.VirtualMachine, error) {
 	vmRes := VirtualMachineResponse{}
-	err = json.Unmarshal(body, &vmRes)
-	if err != nil {
+	if err := c.client.Post(ctx, c.getPath(vm.Group, vm.Namespace), vm, &vmRes); err != nil {
 		return nil, err
 	}
 
-	if res.IsError() {
-		return nil, fmt.Errorf("error")
-	}
-
 	return &vmRes.Data.VirtualMachine, nil
 }
 
-func (c *VirtualMachineClient) Update(vm *system.VirtualMachine) (*system.VirtualMachine, error) {
-	body, err := json.Marshal(vm)
-	if err != nil {
-		return nil, err
-	}
-
-	res, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(vm.Group, vm.Namespace, vm.ID))
-	if err != nil {
-		return nil, err
-	}
-	body = res.Body()
-
+func (c *VirtualMachineClient) Update(ctx context.Context, vm *system.VirtualMachine) (*system.VirtualMachine, error) {
 	vmRes := VirtualMachineResponse{}
-	err = json.Unmarshal(body, &vmRes)
-	if err != nil {
+	if err := c.client.Put(ctx, c.getPath(vm.Group, vm.Namespace, vm.ID), vm, &vmRes); err != nil {
 		return nil, err
 	}
 
 	return &vmRes.Data.VirtualMachine, nil
 }
 
-func (c *VirtualMachineClient) Delete(groupID, namespaceID, virtualMachineID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, virtualMachineID))
-	if err != nil {
-		return err
-	}
-	return err
-
+func (c *VirtualMachineClient) Delete(ctx context.Context, groupID, namespaceID, virtualMachineID string) error {
+	return c.client.Delete(ctx, c.getPath(groupID, namespaceID, virtualMachineID), nil)
 }
 
-func (c *VirtualMachineClient) DeleteState(groupID, namespaceID, virtualMachineID string) error {
-	vm, err := c.Get(groupID, namespaceID, virtualMachineID)
-	if err != nil {
-		return err
-	}
-
-	vm.DeleteState = meta.DeleteStateDelete
-
-	_, err = c.Update(vm)
-	return err
+func (c *VirtualMachineClient) DeleteWithPropagation(ctx context.Context, groupID, namespaceID, virtualMachineID string, policy meta.DeletionPropagation) error {
+	return c.client.Delete(ctx, c.getPath(groupID, namespaceID, virtualMachineID), url.Values{
+		"propagationPolicy": []string{string(policy)},
+	})
 }
 
-func (c *VirtualMachineClient) getPath(groupID, namespaceID, virtualMachineID string) string {
-	return fmt.Sprintf("%s://%s",
-		c.scheme,
-		filepath.Join(
-			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
-			fmt.Sprintf(basePathFormat, groupID, namespaceID),
-			virtualMachineID,
-		))
+func (c *VirtualMachineClient) getPath(groupID, namespaceID string, virtualMachineID ...string) string {
+	return rest.Path(append([]string{"api", "v0", "groups", groupID, "namespaces", namespaceID, "virtualmachines"}, virtualMachineID...)...)
 }
@@ -1,148 +1,93 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"log"
+	"context"
 	"testing"
 
-	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
-	grv0 "github.com/ophum/humstack/pkg/client/core/group/v0"
-	nsv0 "github.com/ophum/humstack/pkg/client/core/namespace/v0"
-	bsv0 "github.com/ophum/humstack/pkg/client/system/blockstorage/v0"
-	netv0 "github.com/ophum/humstack/pkg/client/system/network/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	v0 "github.com/ophum/humstack/pkg/client/system/virtualmachine/v0"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
 const (
-	imageURL = "http://localhost:8082/focal-server-cloudimg-amd64.img"
-	nodeName = "X1Carbon"
+	groupID          = "test-group-00"
+	namespaceID      = "test-namespace-00"
+	virtualMachineID = "test-vm-00"
 )
 
-func TestVirtualMachineCreate(t *testing.T) {
-	grClient := grv0.NewGroupClient("http", "localhost", 8080)
-	gr, err := grClient.Create(&core.Group{
+// vmが使うnetworkとblockstorage
+const manifest = `
+meta:
+  apiType: corev0/group
+  id: test-group-00
+  name: test-group
+---
+meta:
+  apiType: corev0/namespace
+  id: test-namespace-00
+  name: test-namespace
+  group: test-group-00
+---
+meta:
+  apiType: corev0/network
+  id: test-network-00
+  name: test-network
+  group: test-group-00
+  namespace: test-namespace-00
+spec:
+  template:
+    meta:
+      annotations:
+        networkv0/network_type: Bridge
+    spec:
+      id: "100"
+      ipv4CIDR: 10.0.0.0/24
+---
+meta:
+  apiType: systemv0/blockstorage
+  id: test-bs-00
+  name: test-bs
+  group: test-group-00
+  namespace: test-namespace-00
+  annotations:
+    blockstoragev0/node_name: node1
+    blockstoragev0/type: Local
+spec:
+  requestSize: 10G
+  limitSize: 10G
+  from:
+    type: Empty
+`
+
+func TestVirtualMachineClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewVirtualMachineClient(newRESTClient(h))
+	ctx := context.Background()
+
+	h.Apply(manifest)
+
+	vm, err := client.Create(ctx, &system.VirtualMachine{
 		Meta: meta.Meta{
-			ID:   "test-gr",
-			Name: "test-gr",
-		},
-	})
-	nsClient := nsv0.NewNamespaceClient("http", "localhost", 8080)
-	ns, err := nsClient.Create(&core.Namespace{
-		Meta: meta.Meta{
-			ID:    "test-ns",
-			Name:  "test-ns",
-			Group: gr.ID,
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	bsClient := bsv0.NewBlockStorageClient("http", "localhost", 8080)
-	bs, err := bsClient.Create(&system.BlockStorage{
-		Meta: meta.Meta{
-			ID:        "test-bs",
-			Name:      "test-bs",
-			Namespace: ns.ID,
-			Group:     gr.ID,
-			Annotations: map[string]string{
-				"blockstoragev0/type":      "Local",
-				"blockstoragev0/node_name": nodeName,
-			},
-		},
-		Spec: system.BlockStorageSpec{
-			RequestSize: "10G",
-			LimitSize:   "10G",
-			From: system.BlockStorageFrom{
-				Type: system.BlockStorageFromTypeHTTP,
-				HTTP: system.BlockStorageFromHTTP{
-					URL: imageURL,
-				},
-			},
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	bs2, err := bsClient.Create(&system.BlockStorage{
-		Meta: meta.Meta{
-			ID:        "test-bs2",
-			Name:      "test-bs2",
-			Namespace: ns.ID,
-			Group:     gr.ID,
-			Annotations: map[string]string{
-				"blockstoragev0/type":      "Local",
-				"blockstoragev0/node_name": nodeName,
-			},
-		},
-		Spec: system.BlockStorageSpec{
-			RequestSize: "10G",
-			LimitSize:   "10G",
-			From: system.BlockStorageFrom{
-				Type: system.BlockStorageFromTypeEmpty,
-			},
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	netClient := netv0.NewNetworkClient("http", "localhost", 8080)
-	net, err := netClient.Create(&system.Network{
-		Meta: meta.Meta{
-			ID:        "test-net",
-			Name:      "test-net",
-			Namespace: ns.ID,
-			Group:     gr.ID,
-			Annotations: map[string]string{
-				"networkv0/network_type":    "Bridge",
-				"networkv0/default_gateway": "10.0.0.254/24",
-			},
-		},
-		Spec: system.NetworkSpec{
-			ID:       "100",
-			IPv4CIDR: "10.0.0.0/24",
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	client := NewVirtualMachineClient("http", "localhost", 8080)
-	vm, err := client.Create(&system.VirtualMachine{
-		Meta: meta.Meta{
-			ID:        "test-vm",
+			ID:        virtualMachineID,
 			Name:      "test-vm",
-			Namespace: ns.ID,
-			Group:     gr.ID,
+			Namespace: namespaceID,
+			Group:     groupID,
 			Annotations: map[string]string{
-				"virtualmachinev0/node_name": nodeName,
+				"virtualmachinev0/node_name": h.NodeName,
 			},
 		},
 		Spec: system.VirtualMachineSpec{
-			RequestMemory: "1G",
-			LimitMemory:   "1G",
-			RequestVcpus:  "2000m",
-			LimitVcpus:    "2000m",
-			BlockStorageIDs: []string{
-				bs.ID,
-				bs2.ID,
-			},
+			RequestVcpus:    "1000m",
+			LimitVcpus:      "1000m",
+			RequestMemory:   "1G",
+			LimitMemory:     "1G",
+			BlockStorageIDs: []string{"test-bs-00"},
 			NICs: []*system.VirtualMachineNIC{
 				{
-					NetworkID:      net.ID,
-					IPv4Address:    "10.0.0.1",
-					DefaultGateway: "10.0.0.254",
-					Nameservers: []string{
-						"8.8.8.8",
-					},
-				},
-			},
-			LoginUsers: []*system.VirtualMachineLoginUser{
-				{
-					Username: "hum",
-					SSHAuthorizedKeys: []string{
-						"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABgQCsf7CDppU1lSzUbsmszAXX/rAXdGxB71i93IsZtV4omO/uRz/z6dLIsBidf9vIqcEfCFTFR00ULC+GKULTNz2LOaGnGsDS28Bi5u+cx90+BCAzEg6cBwPIYmdZgASsjMmRvI/r+xR/gNxq2RCR8Gl8y5voAWoU8aezRUxf1Ra3KljMd1dbIFGJxgzNiwqN3yL0tr9zActw/Q7yBWKWi1c5sW2QZLAnSj/WWTSGGm0Ad88Aq22DakwN6itUkS6XNhr4YKehLVm90fIojrCrtZmClULAlnUk5lbdzou4jiETsZz3zk/q76ZQ3ugk+G00kcx9v6ElLkAFv2ZZqzWbMvUz6J0k2SzkAIbcBDz+aq2sXeY04FaIOFPiH41+DTQXCtOskWkaJBMKLTE/Z83nSyQGr9If2F/PbnuxGkwiZzeZaLWxqI2SebhLR5jPETgfhB1y83RP6u8Jq5+9BUURFqpb8mfG/riTnAj0ZR4Li23+/hWhc8We+fVB1BxdbWyRn/M= hum@X1Carbon",
-					},
+					NetworkID:   "test-network-00",
+					IPv4Address: "10.0.0.1",
 				},
 			},
 			ActionState: system.VirtualMachineActionStatePowerOn,
@@ -151,62 +96,42 @@ func TestVirtualMachineCreate(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if vm.UID == "" || vm.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", vm.UID, vm.Generation)
+	}
 
-	buf, _ := json.MarshalIndent(vm, "", "  ")
-	log.Println(string(buf))
-}
-
-func TestVirtualMachinePowerOff(t *testing.T) {
-	client := NewVirtualMachineClient("http", "localhost", 8080)
+	vm = h.WaitForVirtualMachineState(groupID, namespaceID, virtualMachineID, system.VirtualMachineStateRunning)
 
-	vm, err := client.Get("test-gr", "test-ns", "test-vm")
+	list, err := client.List(ctx, groupID, namespaceID)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	vm.Spec.ActionState = system.VirtualMachineActionStatePowerOff
-	newVM, err := client.Update(vm)
-	if err != nil {
-		t.Fatal(err)
+	if len(list) != 1 || list[0].ID != virtualMachineID {
+		t.Fatalf("unexpected list: %v", list)
 	}
 
-	buf, _ := json.MarshalIndent(newVM, "", "  ")
-	log.Println(string(buf))
-}
-
-func TestVirtualMachinePowerOn(t *testing.T) {
-	client := NewVirtualMachineClient("http", "localhost", 8080)
-
-	vm, err := client.Get("test-gr", "test-ns", "test-vm")
+	vm.Spec.ActionState = system.VirtualMachineActionStatePowerOff
+	vm, err = client.Update(ctx, vm)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	vm.Spec.ActionState = system.VirtualMachineActionStatePowerOn
-	newVM, err := client.Update(vm)
-	if err != nil {
-		t.Fatal(err)
+	if vm.Generation != 2 {
+		t.Fatalf("unexpected generation: %d", vm.Generation)
 	}
+	h.WaitForVirtualMachineState(groupID, namespaceID, virtualMachineID, system.VirtualMachineStateStopped)
 
-	buf, _ := json.MarshalIndent(newVM, "", "  ")
-	log.Println(string(buf))
-}
-
-func TestVirtualMachineDeleteState(t *testing.T) {
-	client := NewVirtualMachineClient("http", "localhost", 8080)
-
-	err := client.DeleteState("test-gr", "test-ns", "test-vm")
-	if err != nil {
+	if err := client.Delete(ctx, groupID, namespaceID, virtualMachineID); err != nil {
 		t.Fatal(err)
 	}
-
+	h.WaitForNotFound("virtualmachine", func(ctx context.Context) error {
+		_, err := client.Get(ctx, groupID, namespaceID, virtualMachineID)
+		return err
+	})
 }
 
-func TestVirtualMachineDelete(t *testing.T) {
-	client := NewVirtualMachineClient("http", "localhost", 8080)
-
-	err := client.Delete("test-gr", "test-ns", "test-vm")
-	if err != nil {
-		t.Fatal(err)
-	}
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
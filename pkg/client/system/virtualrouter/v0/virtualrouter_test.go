@@ -1,113 +1,99 @@
-package v0
+package v0_test
 
 import (
-	"encoding/json"
-	"log"
+	"context"
 	"testing"
 
-	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
-	eipv0 "github.com/ophum/humstack/pkg/client/core/externalip/v0"
-	eippoolv0 "github.com/ophum/humstack/pkg/client/core/externalippool/v0"
-	grv0 "github.com/ophum/humstack/pkg/client/core/group/v0"
-	nsv0 "github.com/ophum/humstack/pkg/client/core/namespace/v0"
-	netv0 "github.com/ophum/humstack/pkg/client/system/network/v0"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
+	v0 "github.com/ophum/humstack/pkg/client/system/virtualrouter/v0"
+	humtesting "github.com/ophum/humstack/pkg/testing"
 )
 
 const (
-	imageURL = "http://localhost:8082/focal-server-cloudimg-amd64.img"
-	nodeName = "X1Carbon"
+	groupID         = "test-group-00"
+	namespaceID     = "test-namespace-00"
+	virtualRouterID = "test-vr-00"
 )
 
-func TestVirtualRouterCreate(t *testing.T) {
-	grClient := grv0.NewGroupClient("http", "localhost", 8080)
-	gr, err := grClient.Create(&core.Group{
-		Meta: meta.Meta{
-			ID:   "test-gr",
-			Name: "test-gr",
-		},
-	})
-	nsClient := nsv0.NewNamespaceClient("http", "localhost", 8080)
-	ns, err := nsClient.Create(&core.Namespace{
-		Meta: meta.Meta{
-			ID:    "test-ns",
-			Name:  "test-ns",
-			Group: gr.ID,
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
+// virtualrouterが使うnetworkとexternalip
+const manifest = `
+meta:
+  apiType: corev0/group
+  id: test-group-00
+  name: test-group
+---
+meta:
+  apiType: corev0/namespace
+  id: test-namespace-00
+  name: test-namespace
+  group: test-group-00
+---
+meta:
+  apiType: corev0/network
+  id: test-network-00
+  name: test-network
+  group: test-group-00
+  namespace: test-namespace-00
+spec:
+  template:
+    meta:
+      annotations:
+        networkv0/network_type: Bridge
+    spec:
+      id: "100"
+      ipv4CIDR: 10.0.0.0/24
+---
+meta:
+  apiType: corev0/externalippool
+  id: test-eippool-00
+  name: test-eippool
+spec:
+  ipv4CIDR: 192.168.10.0/24
+  ipv6CIDR: fc00::/64
+  bridgeName: exBr
+  defaultGateway: 192.168.10.254
+---
+meta:
+  apiType: corev0/externalip
+  id: test-eip-00
+  name: test-eip
+spec:
+  poolID: test-eippool-00
+  ipv4Address: 192.168.10.1
+  ipv4Prefix: 24
+`
 
-	netClient := netv0.NewNetworkClient("http", "localhost", 8080)
-	net, err := netClient.Create(&system.Network{
-		Meta: meta.Meta{
-			ID:        "test-net",
-			Name:      "test-net",
-			Namespace: ns.ID,
-			Group:     gr.ID,
-			Annotations: map[string]string{
-				"networkv0/network_type": "Bridge",
-			},
-		},
-		Spec: system.NetworkSpec{
-			ID:       "100",
-			IPv4CIDR: "10.0.0.0/24",
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	eippoolClient := eippoolv0.NewExternalIPPoolClient("http", "localhost", 8080)
-	eippool, err := eippoolClient.Create(&core.ExternalIPPool{
-		Meta: meta.Meta{
-			ID:   "testeippool",
-			Name: "test eippool",
-		},
-		Spec: core.ExternalIPPoolSpec{
-			IPv4CIDR:       "192.168.10.0/24",
-			IPv6CIDR:       "fc00::/64",
-			BridgeName:     "exBr",
-			DefaultGateway: "192.168.10.254",
-		},
-	})
-	eipClient := eipv0.NewExternalIPClient("http", "localhost", 8080)
-	eip, err := eipClient.Create(&core.ExternalIP{
-		Meta: meta.Meta{
-			ID:   "eip",
-			Name: "eip",
-		},
-		Spec: core.ExternalIPSpec{
-			PoolID:      eippool.ID,
-			IPv4Address: "192.168.10.1",
-			IPv4Prefix:  24,
-		},
-	})
+func TestVirtualRouterClient(t *testing.T) {
+	h := humtesting.Start(t, nil)
+	client := v0.NewVirtualRouterClient(newRESTClient(h))
+	ctx := context.Background()
 
-	client := NewVirtualRouterClient("http", "localhost", 8080)
-	vr, err := client.Create(&system.VirtualRouter{
+	h.Apply(manifest)
+
+	vr, err := client.Create(ctx, &system.VirtualRouter{
 		Meta: meta.Meta{
-			ID:        "test-vr",
+			ID:        virtualRouterID,
 			Name:      "test-vr",
-			Namespace: ns.ID,
-			Group:     gr.ID,
+			Namespace: namespaceID,
+			Group:     groupID,
 			Annotations: map[string]string{
-				"virtualrouterv0/node_name": nodeName,
+				"virtualrouterv0/node_name": h.NodeName,
 			},
 		},
 		Spec: system.VirtualRouterSpec{
 			ExternalGateway: "192.168.10.254",
 			ExternalIPs: []system.VirtualRouterExternalIP{
 				{
-					ExternalIPID:            eip.ID,
+					ExternalIPID:            "test-eip-00",
 					BindInternalIPv4Address: "10.0.0.1",
 				},
 			},
 			NATGatewayIP: "192.168.10.100/24",
 			NICs: []system.VirtualRouterNIC{
 				{
-					NetworkID:   net.ID,
+					NetworkID:   "test-network-00",
 					IPv4Address: "10.0.0.254/24",
 				},
 			},
@@ -117,29 +103,58 @@ func TestVirtualRouterCreate(t *testing.T) {
 					SrcNetwork: "10.0.0.0/24",
 				},
 			},
-			DNATRules: []system.DNATRule{
-				{
-					DestPort:      10022,
-					ToDestAddress: "10.0.0.2",
-					ToDestPort:    22,
-				},
-			},
 		},
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if vr.UID == "" || vr.Generation != 1 {
+		t.Fatalf("unexpected meta: uid=%s generation=%d", vr.UID, vr.Generation)
+	}
 
-	buf, _ := json.MarshalIndent(vr, "", "  ")
-	log.Println(string(buf))
-}
+	h.WaitFor("virtualrouter to be running", func(ctx context.Context) (bool, error) {
+		vr, err = client.Get(ctx, groupID, namespaceID, virtualRouterID)
+		if err != nil {
+			return false, err
+		}
+		return vr.Status.State == system.VirtualRouterStateRunning, nil
+	})
 
-func TestVirtualRouterDeleteState(t *testing.T) {
-	client := NewVirtualRouterClient("http", "localhost", 8080)
+	list, err := client.List(ctx, groupID, namespaceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != virtualRouterID {
+		t.Fatalf("unexpected list: %v", list)
+	}
 
-	err := client.DeleteState("test-gr", "test-ns", "test-vr")
+	vr.Spec.DNATRules = []system.DNATRule{
+		{
+			DestPort:      10022,
+			ToDestAddress: "10.0.0.2",
+			ToDestPort:    22,
+		},
+	}
+	vr, err = client.Update(ctx, vr)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(vr.Spec.DNATRules) != 1 || vr.Generation != 2 {
+		t.Fatalf("unexpected virtualrouter: dnatRules=%v generation=%d", vr.Spec.DNATRules, vr.Generation)
+	}
 
+	if err := client.Delete(ctx, groupID, namespaceID, virtualRouterID); err != nil {
+		t.Fatal(err)
+	}
+	h.WaitForNotFound("virtualrouter", func(ctx context.Context) error {
+		_, err := client.Get(ctx, groupID, namespaceID, virtualRouterID)
+		return err
+	})
+}
+
+func newRESTClient(h *humtesting.Harness) *rest.Client {
+	return rest.New(rest.Config{
+		Address: h.Address,
+		Port:    h.Port,
+	})
 }
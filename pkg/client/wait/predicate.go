@@ -0,0 +1,135 @@
+package wait
+
+import (
+	"fmt"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"gopkg.in/yaml.v2"
+)
+
+// Predicate はリソースが待っている状態になったかどうかを返す
+// リソースが存在しない場合、objはnilになる
+// エラーを返した場合は待つのをやめる
+type Predicate func(obj *meta.Object) (bool, error)
+
+// readyStates はリソースが使える状態になったとみなすstate
+var readyStates = map[meta.APIType][]string{
+	meta.APITypeNetworkV0:        {string(core.NetworkStateActive)},
+	meta.APITypeNodeV0:           {string(system.NodeStateReady)},
+	meta.APITypeNodeNetworkV0:    {string(system.NetworkStateAvailable)},
+	meta.APITypeBlockStorageV0:   {string(system.BlockStorageStateActive), string(system.BlockStorageStateUsed)},
+	meta.APITypeVirtualMachineV0: {string(system.VirtualMachineStateRunning)},
+	meta.APITypeVirtualRouterV0:  {string(system.VirtualRouterStateRunning)},
+	meta.APITypeImageEntityV0:    {string(system.ImageEntityStateAvailable)},
+}
+
+// failedStates はこれ以上待っても目的の状態にならないstate
+var failedStates = map[meta.APIType][]string{
+	meta.APITypeBlockStorageV0: {string(system.BlockStorageStateError)},
+}
+
+// status はstatusの中でapiTypeによらず共通の部分
+type status struct {
+	State      string           `yaml:"state"`
+	Conditions []meta.Condition `yaml:"conditions"`
+}
+
+func getStatus(obj *meta.Object) (*status, error) {
+	buf, err := yaml.Marshal(obj.Status)
+	if err != nil {
+		return nil, err
+	}
+	s := &status{}
+	if err := yaml.Unmarshal(buf, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkFailed はobjが失敗したstateの場合にエラーを返す
+// 失敗したstate自体を待っている場合はエラーにしない
+func checkFailed(obj *meta.Object, s *status, wants []string) error {
+	if contains(wants, s.State) {
+		return nil
+	}
+	if contains(failedStates[obj.Meta.APIType], s.State) {
+		return fmt.Errorf("%s `%s` is %s", obj.Meta.APIType, obj.Meta.ID, s.State)
+	}
+	return nil
+}
+
+// Exists はリソースが存在する場合にtrueを返す
+func Exists() Predicate {
+	return func(obj *meta.Object) (bool, error) {
+		return obj != nil, nil
+	}
+}
+
+// Deleted はリソースが削除された場合にtrueを返す
+func Deleted() Predicate {
+	return func(obj *meta.Object) (bool, error) {
+		return obj == nil, nil
+	}
+}
+
+// State はstatus.stateがstatesのいずれかになった場合にtrueを返す
+// blockstorageのErrorのように失敗したstateになった場合はエラーを返す
+func State(states ...string) Predicate {
+	return func(obj *meta.Object) (bool, error) {
+		if obj == nil {
+			return false, nil
+		}
+		s, err := getStatus(obj)
+		if err != nil {
+			return false, err
+		}
+		if err := checkFailed(obj, s, states); err != nil {
+			return false, err
+		}
+		return contains(states, s.State), nil
+	}
+}
+
+// Condition はconditionTypeのconditionがconditionStatusになった場合にtrueを返す
+func Condition(conditionType meta.ConditionType, conditionStatus meta.ConditionStatus) Predicate {
+	return func(obj *meta.Object) (bool, error) {
+		if obj == nil {
+			return false, nil
+		}
+		s, err := getStatus(obj)
+		if err != nil {
+			return false, err
+		}
+		if err := checkFailed(obj, s, nil); err != nil {
+			return false, err
+		}
+		c := meta.FindCondition(s.Conditions, conditionType)
+		return c != nil && c.Status == conditionStatus, nil
+	}
+}
+
+// Ready はリソースが使える状態になった場合にtrueを返す
+// stateを持たないリソースは存在すればtrueを返す
+func Ready() Predicate {
+	return func(obj *meta.Object) (bool, error) {
+		if obj == nil {
+			return false, nil
+		}
+		states, ok := readyStates[obj.Meta.APIType]
+		if !ok {
+			return true, nil
+		}
+		return State(states...)(obj)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+// Package wait はリソースが目的の状態になるまで待つ
+// watchで変更を受け取るたびに確認し、watchが切れている間も一定間隔で確認する
+package wait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/scheme"
+	"github.com/ophum/humstack/pkg/client/dynamic"
+)
+
+// DefaultPollInterval はwatchで通知が来なくても確認する間隔
+const DefaultPollInterval = time.Second * 5
+
+// Watcher はapiTypeのリソースの変更を通知する
+// before, afterはNoticeDataのJSON文字列
+type Watcher interface {
+	Watch(ctx context.Context, apiType string, f func(before, after interface{})) error
+}
+
+// Getter はリソースを取得する。dynamic.Interfaceが満たす
+type Getter interface {
+	Get(ctx context.Context, ref meta.ObjectReference) (*meta.Object, error)
+}
+
+var _ Getter = dynamic.Interface(nil)
+
+type Waiter struct {
+	client  Getter
+	watcher Watcher
+
+	pollInterval time.Duration
+}
+
+// NewWaiter はwatcherがnilの場合はポーリングだけで確認する
+func NewWaiter(client Getter, watcher Watcher) *Waiter {
+	return &Waiter{
+		client:       client,
+		watcher:      watcher,
+		pollInterval: DefaultPollInterval,
+	}
+}
+
+// SetPollInterval はポーリングの間隔を変更する
+func (w *Waiter) SetPollInterval(interval time.Duration) {
+	w.pollInterval = interval
+}
+
+// For はobjectがpredicateを満たすまで待ち、その時点のリソースを返す
+// objectにはmeta.ObjectReference, *meta.Object, apiTypeの型のリソースを指定できる
+// 削除を待った場合はnilを返す
+// 待つ時間はctxで指定する。タイムアウトした場合はcontext.DeadlineExceededを含むエラーを返す
+func (w *Waiter) For(ctx context.Context, object interface{}, predicate Predicate) (*meta.Object, error) {
+	ref, err := Reference(object)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	notified := make(chan struct{}, 1)
+	if w.watcher != nil {
+		go w.watcher.Watch(ctx, string(ref.APIType), func(before, after interface{}) {
+			if !isTarget(ref, before) && !isTarget(ref, after) {
+				return
+			}
+			select {
+			case notified <- struct{}{}:
+			default:
+			}
+		})
+	}
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		obj, err := w.client.Get(ctx, ref)
+		if err != nil && !meta.IsNotFound(err) {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("wait for %s `%s`: %w", ref.APIType, ref.ID, ctx.Err())
+			}
+			return nil, err
+		}
+		if meta.IsNotFound(err) {
+			obj = nil
+		}
+
+		ok, err := predicate(obj)
+		if err != nil {
+			return obj, err
+		}
+		if ok {
+			return obj, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return obj, fmt.Errorf("wait for %s `%s`: %w", ref.APIType, ref.ID, ctx.Err())
+		case <-notified:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reference はobjectの参照を返す
+func Reference(object interface{}) (meta.ObjectReference, error) {
+	switch o := object.(type) {
+	case meta.ObjectReference:
+		return o, nil
+	case *meta.ObjectReference:
+		return *o, nil
+	case *meta.Object:
+		return reference(o)
+	}
+
+	obj, err := scheme.ToObject(object)
+	if err != nil {
+		return meta.ObjectReference{}, err
+	}
+	return reference(obj)
+}
+
+func reference(obj *meta.Object) (meta.ObjectReference, error) {
+	r, err := scheme.Lookup(obj.Meta.APIType)
+	if err != nil {
+		return meta.ObjectReference{}, err
+	}
+	return r.Reference(obj.Meta.Group, obj.Meta.Namespace, obj.Meta.ID), nil
+}
+
+// isTarget は通知されたリソースがrefかどうかを返す
+func isTarget(ref meta.ObjectReference, data interface{}) bool {
+	s, ok := data.(string)
+	if !ok || s == "" {
+		return false
+	}
+
+	obj := struct {
+		Meta meta.Meta `json:"meta"`
+	}{}
+	if err := json.Unmarshal([]byte(s), &obj); err != nil {
+		return false
+	}
+	// refにはスコープに含まれないgroup, namespaceが入っていないので空の場合は比較しない
+	return obj.Meta.ID == ref.ID &&
+		(ref.Group == "" || obj.Meta.Group == ref.Group) &&
+		(ref.Namespace == "" || obj.Meta.Namespace == ref.Namespace)
+}
@@ -0,0 +1,175 @@
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/wait"
+	humtesting "github.com/ophum/humstack/pkg/testing"
+)
+
+func TestWaiter(t *testing.T) {
+	h := humtesting.Start(t, &humtesting.Options{DisableCoreAgents: true})
+	h.CreateNamespace("group1", "ns1")
+
+	// ポーリングしないのでwatchで通知されないと終わらない
+	w := wait.NewWaiter(h.Clients.Dynamic(), h.Clients.WatchV0())
+	w.SetPollInterval(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	bs, err := h.Clients.SystemV0().BlockStorage().Create(ctx, &system.BlockStorage{
+		Meta: meta.Meta{
+			ID:        "bs1",
+			Name:      "bs1",
+			Group:     "group1",
+			Namespace: "ns1",
+			Annotations: map[string]string{
+				"blockstoragev0/node_name": h.NodeName,
+				"blockstoragev0/type":      "Local",
+			},
+		},
+		Spec: system.BlockStorageSpec{
+			RequestSize: "1G",
+			LimitSize:   "1G",
+			From: system.BlockStorageFrom{
+				Type: system.BlockStorageFromTypeEmpty,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := w.For(ctx, bs, wait.Ready())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Meta.ID != "bs1" {
+		t.Fatalf("unexpected object: %s", obj.Meta.ID)
+	}
+	if _, err := w.For(ctx, bs, wait.State(string(system.BlockStorageStateActive))); err != nil {
+		t.Fatal(err)
+	}
+
+	// 存在しない状態は待ち続けてタイムアウトする
+	short, shortCancel := context.WithTimeout(ctx, time.Millisecond*200)
+	defer shortCancel()
+	if _, err := w.For(short, bs, wait.State("Unknown")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, but got %v", err)
+	}
+
+	if err := h.Clients.SystemV0().BlockStorage().Delete(ctx, "group1", "ns1", "bs1"); err != nil {
+		t.Fatal(err)
+	}
+	ref := meta.ObjectReference{
+		APIType:   meta.APITypeBlockStorageV0,
+		Group:     "group1",
+		Namespace: "ns1",
+		ID:        "bs1",
+	}
+	obj, err = h.Clients.WaitFor(ctx, ref, wait.Deleted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj != nil {
+		t.Fatalf("expected nil, but got %v", obj)
+	}
+}
+
+func TestPredicate(t *testing.T) {
+	newObject := func(apiType meta.APIType, status interface{}) *meta.Object {
+		return &meta.Object{
+			Meta:   meta.Meta{APIType: apiType, ID: "foo"},
+			Status: status,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		predicate wait.Predicate
+		obj       *meta.Object
+		want      bool
+		wantErr   bool
+	}{
+		{
+			name:      "exists",
+			predicate: wait.Exists(),
+			obj:       newObject(meta.APITypeGroupV0, nil),
+			want:      true,
+		},
+		{
+			name:      "deleted",
+			predicate: wait.Deleted(),
+			obj:       nil,
+			want:      true,
+		},
+		{
+			name:      "state matched",
+			predicate: wait.State("Running"),
+			obj:       newObject(meta.APITypeVirtualMachineV0, system.VirtualMachineStatus{State: system.VirtualMachineStateRunning}),
+			want:      true,
+		},
+		{
+			name:      "state not found",
+			predicate: wait.State("Running"),
+			obj:       nil,
+			want:      false,
+		},
+		{
+			name:      "blockstorage error",
+			predicate: wait.State(string(system.BlockStorageStateActive)),
+			obj:       newObject(meta.APITypeBlockStorageV0, system.BlockStorageStatus{State: system.BlockStorageStateError}),
+			wantErr:   true,
+		},
+		{
+			name:      "wait for blockstorage error",
+			predicate: wait.State(string(system.BlockStorageStateError)),
+			obj:       newObject(meta.APITypeBlockStorageV0, system.BlockStorageStatus{State: system.BlockStorageStateError}),
+			want:      true,
+		},
+		{
+			name:      "ready used blockstorage",
+			predicate: wait.Ready(),
+			obj:       newObject(meta.APITypeBlockStorageV0, system.BlockStorageStatus{State: system.BlockStorageStateUsed}),
+			want:      true,
+		},
+		{
+			name:      "ready pending virtualmachine",
+			predicate: wait.Ready(),
+			obj:       newObject(meta.APITypeVirtualMachineV0, system.VirtualMachineStatus{State: system.VirtualMachineStatePending}),
+			want:      false,
+		},
+		{
+			name:      "ready without state",
+			predicate: wait.Ready(),
+			obj:       newObject(meta.APITypeGroupV0, nil),
+			want:      true,
+		},
+		{
+			name:      "condition",
+			predicate: wait.Condition("Scheduled", meta.ConditionFalse),
+			obj: newObject(meta.APITypeVirtualMachineV0, system.VirtualMachineStatus{
+				Conditions: []meta.Condition{{Type: "Scheduled", Status: meta.ConditionFalse}},
+			}),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.predicate(tt.obj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,65 +1,48 @@
 package v0
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"log"
-	"path/filepath"
+	"net/url"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/client/internal/rest"
 	"github.com/ophum/humstack/pkg/store/leveldb"
 	"github.com/r3labs/sse"
+	"gopkg.in/cenkalti/backoff.v1"
 )
 
 type WatchClient struct {
-	scheme           string
-	apiServerAddress string
-	apiServerPort    int32
-	client           *resty.Client
-	headers          map[string]string
+	client *rest.Client
 }
 
-const (
-	basePathFormat = "api/v0/watches%s"
-)
-
-func NewWatchClient(scheme, apiServerAddress string, apiServerPort int32) *WatchClient {
+func NewWatchClient(client *rest.Client) *WatchClient {
 	return &WatchClient{
-		scheme:           scheme,
-		apiServerAddress: apiServerAddress,
-		apiServerPort:    apiServerPort,
-		client:           resty.New(),
-		headers: map[string]string{
-			"Content-Type": "application/json",
-			"Accept":       "application/json",
-		},
+		client: client,
 	}
 }
 
-func (c *WatchClient) Watch(apiType string, f func(before interface{}, after interface{})) error {
-	log.Println("start")
-	client := sse.NewClient(c.getPath(apiType))
-
-	client.Subscribe("", func(msg *sse.Event) {
+// Watch はctxがキャンセルされるまでapiTypeのリソースの変更をfに通知する
+func (c *WatchClient) Watch(ctx context.Context, apiType string, f func(before interface{}, after interface{})) error {
+	client := sse.NewClient(c.getURL(apiType))
+	// TLSと認証の設定を共有する。ストリームのためタイムアウトしない
+	client.Connection = c.client.HTTPClient()
+	// 切断された場合はctxがキャンセルされるまで再接続し続ける
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = 0
+	client.ReconnectStrategy = backoff.WithContext(b, ctx)
+
+	return client.SubscribeWithContext(ctx, "", func(msg *sse.Event) {
 		var noticeData leveldb.NoticeData
 		json.Unmarshal(msg.Data, &noticeData)
 
 		f(noticeData.Before, noticeData.After)
 	})
-
-	return nil
 }
 
-func (c *WatchClient) getPath(apiType string) string {
-	query := ""
+func (c *WatchClient) getURL(apiType string) string {
+	query := url.Values{}
 	if apiType != "" {
-		query = "?apiType=" + apiType
+		query.Set("apiType", apiType)
 	}
-	return fmt.Sprintf("%s://%s",
-		c.scheme,
-		filepath.Join(
-			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
-			fmt.Sprintf(basePathFormat, query),
-		))
-
+	return c.client.URL(rest.Path("api", "v0", "watches"), query)
 }
@@ -1,67 +1,73 @@
 package cmd
 
 import (
-	"io"
+	"context"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/client"
 	"github.com/ophum/humstack/pkg/humcli/cmd/apply"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 )
 
 func init() {
 	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only validate on apiserver, nothing is stored")
 }
 
 var applyCmd = &cobra.Command{
 	Use: "apply",
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		applyFuncMap := map[meta.APIType]func(d *yaml.Decoder, clients *client.Clients, debug bool) error{
-			meta.APITypeGroupV0:          apply.ApplyGroup,
-			meta.APITypeNamespaceV0:      apply.ApplyNamespace,
-			meta.APITypeExternalIPPoolV0: apply.ApplyExternalIPPool,
-			meta.APITypeExternalIPV0:     apply.ApplyExternalIP,
-			meta.APITypeBlockStorageV0:   apply.ApplyBlockStorage,
-			meta.APITypeImageV0:          apply.ApplyImage,
-			meta.APITypeImageEntityV0:    apply.ApplyImageEntity,
-			meta.APITypeNetworkV0:        apply.ApplyNetwork,
-			meta.APITypeVirtualMachineV0: apply.ApplyVirtualMachine,
-			meta.APITypeVirtualRouterV0:  apply.ApplyVirtualRouter,
-			meta.APITypeNodeNetworkV0:    apply.ApplyNodeNetwork,
+		ctx := cmd.Context()
+		clients := newClients()
+		clients.SetDryRun(dryRun)
+
+		suffix := ""
+		if dryRun {
+			suffix = " (dry run)"
 		}
 
 		for _, file := range args {
-			f, err := os.Open(file)
+			err := applyManifest(ctx, file, clients, func(item *meta.Object, res *apply.Result) {
+				if res.Live == nil {
+					log.Printf("%s created%s\n", objectKey(&item.Meta), suffix)
+				} else {
+					log.Printf("%s updated%s\n", objectKey(&item.Meta), suffix)
+				}
+
+				if debug {
+					printYAML(res.Applied)
+				}
+			})
 			if err != nil {
-				log.Fatal(err.Error())
+				log.Fatal(err)
 			}
-			defer f.Close()
+		}
+	},
+}
 
-			decode := yaml.NewDecoder(f)
+// applyManifest はfileに含まれるリソースを順にapplyし、結果をfに渡す
+func applyManifest(ctx context.Context, file string, clients *client.Clients, f func(item *meta.Object, res *apply.Result)) error {
+	fp, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
 
-			var item meta.Object
-			for decode.Decode(&item) == nil {
-				r, w := io.Pipe()
-				e := yaml.NewEncoder(w)
-				go func() {
-					e.Encode(item)
-					e.Close()
-					w.Close()
-				}()
+	return apply.Manifest(ctx, fp, clients, f)
+}
 
-				d := yaml.NewDecoder(r)
-				if f, ok := applyFuncMap[item.Meta.APIType]; ok {
-					if err := f(d, clients, debug); err != nil {
-						log.Fatal(err)
-					}
-				}
-				// 初期化しておく
-				item = meta.Object{}
-			}
+// objectKey はログに表示するためのリソースのキーを返す
+// e.g. group1/ns1/systemv0/virtualmachine/vm1
+func objectKey(m *meta.Meta) string {
+	keys := []string{}
+	for _, k := range []string{m.Group, m.Namespace, string(m.APIType), m.ID} {
+		if k != "" {
+			keys = append(keys, k)
 		}
-	},
+	}
+	return filepath.Join(keys...)
 }
@@ -0,0 +1,96 @@
+package apply
+
+import (
+	"context"
+	"io"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/scheme"
+	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/client/dynamic"
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest はrに含まれるリソースを順にapplyし、結果をfに渡す
+// schemeに登録されていないapiTypeのリソースが含まれている場合はエラーを返す
+func Manifest(ctx context.Context, r io.Reader, clients *client.Clients, f func(item *meta.Object, res *Result)) error {
+	return ForEach(r, func(item *meta.Object) error {
+		// fにはidなどの省略された値を埋めたものを渡す
+		obj, err := scheme.DefaultObject(item)
+		if err != nil {
+			return err
+		}
+		res, err := Object(ctx, clients.Dynamic(), obj)
+		if err != nil {
+			return err
+		}
+		f(obj, res)
+		return nil
+	})
+}
+
+// ForEach はrに含まれるリソースを順にデコードしてfに渡す
+// 空のドキュメントは無視する
+func ForEach(r io.Reader, f func(item *meta.Object) error) error {
+	decode := yaml.NewDecoder(r)
+
+	for {
+		item := meta.Object{}
+		if err := decode.Decode(&item); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if item.Meta.APIType == "" && item.Meta.ID == "" && item.Spec == nil {
+			continue
+		}
+
+		if err := f(&item); err != nil {
+			return err
+		}
+	}
+}
+
+// Object はitemが存在しなければ作成し、存在すれば更新する
+func Object(ctx context.Context, client dynamic.Interface, item *meta.Object) (*Result, error) {
+	r, err := scheme.Lookup(item.Meta.APIType)
+	if err != nil {
+		return nil, err
+	}
+
+	// imagetagのidのように省略できる値を埋めておく
+	obj, err := scheme.DefaultObject(item)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := client.Get(ctx, r.Reference(obj.Meta.Group, obj.Meta.Namespace, obj.Meta.ID))
+	if err != nil && !meta.IsNotFound(err) {
+		return nil, err
+	}
+
+	result := &Result{}
+	var applied *meta.Object
+	if meta.IsNotFound(err) {
+		applied, err = client.Create(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// finalizerはagentが管理しているので引き継ぐ
+		obj.Meta.Finalizers = old.Meta.Finalizers
+		applied, err = client.Update(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+		if result.Live, err = scheme.FromObject(old); err != nil {
+			return nil, err
+		}
+	}
+
+	if result.Applied, err = scheme.FromObject(applied); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,9 @@
+package apply
+
+// Result はapplyする前後のリソース
+// apiTypeのGoの型で入っている
+type Result struct {
+	// 新しく作成した場合はnil
+	Live    interface{}
+	Applied interface{}
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+// formatConditions はconditionsを `Type=Status` の形式で1行ずつ表示する
+// Trueでないものは理由も表示する
+func formatConditions(conditions []meta.Condition) string {
+	lines := []string{}
+	for _, c := range conditions {
+		line := fmt.Sprintf("%s=%s", c.Type, c.Status)
+		if c.Status != meta.ConditionTrue && c.Reason != "" {
+			line += fmt.Sprintf("(%s)", c.Reason)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
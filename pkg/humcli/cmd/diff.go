@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/humcli/cmd/apply"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
+
+// applyした場合との差分をdry runで確認する
+// 差分がある場合はexit status 1で終了する
+var diffCmd = &cobra.Command{
+	Use: "diff",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		clients := newClients()
+		clients.SetDryRun(true)
+
+		hasDiff := false
+		for _, file := range args {
+			err := applyManifest(ctx, file, clients, func(item *meta.Object, res *apply.Result) {
+				key := objectKey(&item.Meta)
+				live, err := marshalWithoutStatus(res.Live)
+				if err != nil {
+					log.Fatal(errors.Wrap(err, "marshal live").Error())
+				}
+				applied, err := marshalWithoutStatus(res.Applied)
+				if err != nil {
+					log.Fatal(errors.Wrap(err, "marshal applied").Error())
+				}
+
+				diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+					A:        difflib.SplitLines(live),
+					B:        difflib.SplitLines(applied),
+					FromFile: "live/" + key,
+					ToFile:   "manifest/" + key,
+					Context:  3,
+				})
+				if err != nil {
+					log.Fatal(errors.Wrap(err, "diff").Error())
+				}
+				if diff != "" {
+					hasDiff = true
+					fmt.Print(diff)
+				}
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if hasDiff {
+			os.Exit(1)
+		}
+	},
+}
+
+// marshalWithoutStatus はstatusを除いてyamlにする
+// statusはagentが管理するため差分に含めない
+func marshalWithoutStatus(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	var obj yaml.MapSlice
+	if err := yaml.Unmarshal(b, &obj); err != nil {
+		return "", err
+	}
+
+	filtered := yaml.MapSlice{}
+	for _, item := range obj {
+		if item.Key == "status" {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+
+	b, err = yaml.Marshal(filtered)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
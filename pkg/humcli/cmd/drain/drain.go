@@ -0,0 +1,396 @@
+package drain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/scheme"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/client/wait"
+	"github.com/pkg/errors"
+)
+
+// agent, schedulerと同じannotation
+const (
+	annotationVirtualMachineNodeName = "virtualmachinev0/node_name"
+	annotationBlockStorageNodeName   = "blockstoragev0/node_name"
+	annotationBlockStorageType       = "blockstoragev0/type"
+	annotationVirtualRouterNodeName  = "virtualrouterv0/node_name"
+
+	blockStorageTypeLocal = "Local"
+)
+
+// maxConflictRetries はnodeの更新が競合した場合に取得し直す回数
+const maxConflictRetries = 3
+
+// Options はdrainの動作
+type Options struct {
+	// trueの場合、Localのblockstorageを使っていないVMを停止した後に別のノードで起動し直す
+	// falseの場合は停止するだけで、ノードの割り当ては変えない
+	Migrate bool
+	// VMが停止するまで待つ時間。0の場合はctxが終わるまで待つ
+	VirtualMachineStopTimeout time.Duration
+}
+
+// Progress はdrainの途中経過
+type Progress struct {
+	Object  meta.ObjectReference
+	Message string
+}
+
+// Result はdrainで処理したリソースの数
+type Result struct {
+	Migrated   int
+	PoweredOff int
+	Relocated  int
+}
+
+// Cordon はノードにVMを割り当てないようにする。unschedulableがfalseの場合は元に戻す
+// 既に同じ値の場合は更新しない
+func Cordon(ctx context.Context, clients client.Interface, nodeName string, unschedulable bool) (*system.Node, error) {
+	for i := 0; ; i++ {
+		node, err := clients.SystemV0().Node().Get(ctx, nodeName)
+		if err != nil {
+			return nil, err
+		}
+		if node.Spec.Unschedulable == unschedulable {
+			return node, nil
+		}
+
+		node.Spec.Unschedulable = unschedulable
+		updated, err := clients.SystemV0().Node().Update(ctx, node)
+		// node agentなどが同時にnodeを更新した場合は取得し直す
+		if meta.IsConflict(err) && i < maxConflictRetries {
+			continue
+		}
+		return updated, err
+	}
+}
+
+// Node はノードをcordonし、ノードのVMを停止または別のノードに移し、virtualrouterを別のノードに移す
+// 各リソースの処理が完了するまで待ち、途中経過をfに渡す
+// 待っている間にctxがキャンセルされた場合はエラーを返す。ノードはcordonしたままにする
+func Node(ctx context.Context, clients client.Interface, nodeName string, opts *Options, f func(p *Progress)) (*Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if _, err := Cordon(ctx, clients, nodeName, true); err != nil {
+		return nil, errors.Wrap(err, "cordon node")
+	}
+
+	d := &drainer{
+		clients:  clients,
+		nodeName: nodeName,
+		opts:     opts,
+		progress: f,
+		result:   &Result{},
+	}
+	if err := d.drainVirtualMachines(ctx); err != nil {
+		return d.result, err
+	}
+	if err := d.drainVirtualRouters(ctx); err != nil {
+		return d.result, err
+	}
+	return d.result, nil
+}
+
+type drainer struct {
+	clients  client.Interface
+	nodeName string
+	opts     *Options
+	progress func(p *Progress)
+	result   *Result
+}
+
+func (d *drainer) report(ref meta.ObjectReference, format string, args ...interface{}) {
+	if d.progress == nil {
+		return
+	}
+	d.progress(&Progress{
+		Object:  ref,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// forEachNamespace は全てのgroup, namespaceに対してfを呼ぶ
+func (d *drainer) forEachNamespace(ctx context.Context, f func(groupID, namespaceID string) error) error {
+	grList, err := d.clients.CoreV0().Group().List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "list groups")
+	}
+	for _, group := range grList {
+		nsList, err := d.clients.CoreV0().Namespace().List(ctx, group.ID)
+		if err != nil {
+			return errors.Wrap(err, "list namespaces")
+		}
+		for _, ns := range nsList {
+			if err := f(group.ID, ns.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// waiter はwatchで変更を受け取りながら待てるクライアント。client.Clientsが満たす
+type waiter interface {
+	WaitFor(ctx context.Context, object interface{}, predicate wait.Predicate) (*meta.Object, error)
+}
+
+// waitFor はrefがpredicateを満たすまで待つ
+// clientsがwatchできない場合はポーリングだけで確認する
+func (d *drainer) waitFor(ctx context.Context, ref meta.ObjectReference, predicate wait.Predicate) (*meta.Object, error) {
+	if w, ok := d.clients.(waiter); ok {
+		return w.WaitFor(ctx, ref, predicate)
+	}
+	return wait.NewWaiter(&getter{clients: d.clients}, nil).For(ctx, ref, predicate)
+}
+
+// waitForStopped はVMが停止するまでVirtualMachineStopTimeoutの間待つ
+func (d *drainer) waitForStopped(ctx context.Context, ref meta.ObjectReference) error {
+	timeout := d.opts.VirtualMachineStopTimeout
+	waitCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	_, err := d.waitFor(waitCtx, ref, wait.State(string(system.VirtualMachineStateStopped)))
+	if err != nil && ctx.Err() == nil && waitCtx.Err() != nil {
+		d.report(ref, "did not stop within %s", timeout)
+		return fmt.Errorf("did not stop within %s", timeout)
+	}
+	return err
+}
+
+// getter はclient.Interfaceでdrainが待つリソースを取得する
+type getter struct {
+	clients client.Interface
+}
+
+func (g *getter) Get(ctx context.Context, ref meta.ObjectReference) (*meta.Object, error) {
+	switch ref.APIType {
+	case meta.APITypeVirtualMachineV0:
+		vm, err := g.clients.SystemV0().VirtualMachine().Get(ctx, ref.Group, ref.Namespace, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return scheme.ToObject(vm)
+	case meta.APITypeVirtualRouterV0:
+		vr, err := g.clients.SystemV0().VirtualRouter().Get(ctx, ref.Group, ref.Namespace, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		return scheme.ToObject(vr)
+	}
+	return nil, fmt.Errorf("unsupported apiType `%s`", ref.APIType)
+}
+
+func virtualMachineReference(vm *system.VirtualMachine) meta.ObjectReference {
+	return meta.ObjectReference{
+		APIType:   meta.APITypeVirtualMachineV0,
+		Group:     vm.Group,
+		Namespace: vm.Namespace,
+		ID:        vm.ID,
+	}
+}
+
+func (d *drainer) drainVirtualMachines(ctx context.Context) error {
+	return d.forEachNamespace(ctx, func(groupID, namespaceID string) error {
+		vmList, err := d.clients.SystemV0().VirtualMachine().List(ctx, groupID, namespaceID)
+		if err != nil {
+			return errors.Wrap(err, "list virtualmachines")
+		}
+		for _, vm := range vmList {
+			if vm.Annotations[annotationVirtualMachineNodeName] != d.nodeName || vm.IsDeleting() {
+				continue
+			}
+			if err := d.drainVirtualMachine(ctx, vm); err != nil {
+				return errors.Wrapf(err, "virtualmachine `%s/%s/%s`", vm.Group, vm.Namespace, vm.ID)
+			}
+		}
+		return nil
+	})
+}
+
+// drainVirtualMachine はVMを停止し、Migrateの場合は割り当てを外して起動し直す
+// Localのblockstorageはノードから動かせないので、使っているVMは停止するだけにする
+func (d *drainer) drainVirtualMachine(ctx context.Context, vm *system.VirtualMachine) error {
+	ref := virtualMachineReference(vm)
+	running := vm.Spec.ActionState == system.VirtualMachineActionStatePowerOn
+
+	local, err := d.localBlockStorages(ctx, vm)
+	if err != nil {
+		return err
+	}
+	migrate := d.opts.Migrate && len(local) == 0
+
+	if running {
+		d.report(ref, "powering off")
+		vm.Spec.ActionState = system.VirtualMachineActionStatePowerOff
+		if _, err := d.clients.SystemV0().VirtualMachine().Update(ctx, vm); err != nil {
+			return err
+		}
+		// ノードのagentが停止するまで待ってから割り当てを外す
+		if err := d.waitForStopped(ctx, ref); err != nil {
+			return err
+		}
+	}
+
+	if !migrate {
+		if d.opts.Migrate {
+			d.report(ref, "powered off, local blockstorages %v can't be migrated", local)
+		} else {
+			d.report(ref, "powered off")
+		}
+		d.result.PoweredOff++
+		return nil
+	}
+
+	vm, err = d.clients.SystemV0().VirtualMachine().Get(ctx, vm.Group, vm.Namespace, vm.ID)
+	if err != nil {
+		return err
+	}
+	delete(vm.Annotations, annotationVirtualMachineNodeName)
+	if running {
+		vm.Spec.ActionState = system.VirtualMachineActionStatePowerOn
+	}
+	if _, err := d.clients.SystemV0().VirtualMachine().Update(ctx, vm); err != nil {
+		return err
+	}
+
+	// 停止していたVMは起動した時にschedulerが割り当てる
+	if !running {
+		d.report(ref, "unassigned from node")
+		d.result.Migrated++
+		return nil
+	}
+
+	d.report(ref, "waiting to be rescheduled")
+	obj, err := d.waitFor(ctx, ref, wait.State(string(system.VirtualMachineStateRunning)))
+	if err != nil {
+		return err
+	}
+	d.report(ref, "running on node `%s`", obj.Meta.Annotations[annotationVirtualMachineNodeName])
+	d.result.Migrated++
+	return nil
+}
+
+// localBlockStorages はVMが使っているLocalのblockstorageのうち、ノードに配置済みのもののidを返す
+func (d *drainer) localBlockStorages(ctx context.Context, vm *system.VirtualMachine) ([]string, error) {
+	ids := []string{}
+	for _, id := range vm.Spec.BlockStorageIDs {
+		bs, err := d.clients.SystemV0().BlockStorage().Get(ctx, vm.Group, vm.Namespace, id)
+		if err != nil {
+			if meta.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "get blockstorage `%s`", id)
+		}
+		if bs.Annotations[annotationBlockStorageType] == blockStorageTypeLocal &&
+			bs.Annotations[annotationBlockStorageNodeName] != "" {
+			ids = append(ids, bs.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (d *drainer) drainVirtualRouters(ctx context.Context) error {
+	nodes, err := d.clients.SystemV0().Node().List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "list nodes")
+	}
+
+	routers := map[string]int{}
+	vrList := []*system.VirtualRouter{}
+	err = d.forEachNamespace(ctx, func(groupID, namespaceID string) error {
+		list, err := d.clients.SystemV0().VirtualRouter().List(ctx, groupID, namespaceID)
+		if err != nil {
+			return errors.Wrap(err, "list virtualrouters")
+		}
+		for _, vr := range list {
+			nodeName := vr.Annotations[annotationVirtualRouterNodeName]
+			routers[nodeName]++
+			if nodeName == d.nodeName && !vr.IsDeleting() {
+				vrList = append(vrList, vr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, vr := range vrList {
+		target := selectRouterNode(nodes, d.nodeName, routers)
+		if target == "" {
+			return fmt.Errorf("no node is available for virtualrouter `%s/%s/%s`", vr.Group, vr.Namespace, vr.ID)
+		}
+		if err := d.relocateVirtualRouter(ctx, vr, target); err != nil {
+			return errors.Wrapf(err, "virtualrouter `%s/%s/%s`", vr.Group, vr.Namespace, vr.ID)
+		}
+		routers[target]++
+	}
+	return nil
+}
+
+// selectRouterNode はvirtualrouterが最も少ないReadyのノードを返す
+func selectRouterNode(nodes []*system.Node, drained string, routers map[string]int) string {
+	candidates := []string{}
+	for _, n := range nodes {
+		if n.ID == drained || n.Spec.Unschedulable ||
+			n.Status.State != system.NodeStateReady ||
+			!meta.IsConditionTrue(n.Status.Conditions, system.NodeConditionReady) {
+			continue
+		}
+		candidates = append(candidates, n.ID)
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if routers[candidates[i]] != routers[candidates[j]] {
+			return routers[candidates[i]] < routers[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+	return candidates[0]
+}
+
+// relocateVirtualRouter はvirtualrouterを別のノードに割り当て、そのノードで動くまで待つ
+// 元のノードのagentは割り当てが外れたvirtualrouterのnetnsを削除する
+func (d *drainer) relocateVirtualRouter(ctx context.Context, vr *system.VirtualRouter, nodeName string) error {
+	ref := meta.ObjectReference{
+		APIType:   meta.APITypeVirtualRouterV0,
+		Group:     vr.Group,
+		Namespace: vr.Namespace,
+		ID:        vr.ID,
+	}
+	d.report(ref, "relocating to node `%s`", nodeName)
+
+	vr.Annotations[annotationVirtualRouterNodeName] = nodeName
+	vr.Status.State = system.VirtualRouterStatePending
+	meta.SetCondition(&vr.Status.Conditions, meta.Condition{
+		Type:               system.VirtualRouterConditionReady,
+		Status:             meta.ConditionFalse,
+		Reason:             "Relocating",
+		Message:            fmt.Sprintf("relocating from node `%s` to `%s`.", d.nodeName, nodeName),
+		ObservedGeneration: vr.Generation,
+	})
+	if _, err := d.clients.SystemV0().VirtualRouter().Update(ctx, vr); err != nil {
+		return err
+	}
+
+	if _, err := d.waitFor(ctx, ref, wait.State(string(system.VirtualRouterStateRunning))); err != nil {
+		return err
+	}
+	d.report(ref, "running on node `%s`", nodeName)
+	d.result.Relocated++
+	return nil
+}
@@ -0,0 +1,238 @@
+package drain_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client/fake"
+	"github.com/ophum/humstack/pkg/humcli/cmd/drain"
+	humtesting "github.com/ophum/humstack/pkg/testing"
+)
+
+const manifest = `
+meta:
+  apiType: systemv0/blockstorage
+  id: bs1
+  name: bs1
+  group: group1
+  namespace: ns1
+  annotations:
+    blockstoragev0/node_name: node1
+    blockstoragev0/type: Local
+spec:
+  requestSize: 1G
+  limitSize: 1G
+  from:
+    type: Empty
+---
+meta:
+  apiType: systemv0/virtualmachine
+  id: vm1
+  name: vm1
+  group: group1
+  namespace: ns1
+  annotations:
+    virtualmachinev0/node_name: node1
+spec:
+  requestVcpus: 1000m
+  limitVcpus: 1000m
+  requestMemory: 1G
+  limitMemory: 1G
+  actionState: PowerOn
+---
+meta:
+  apiType: systemv0/virtualmachine
+  id: vm2
+  name: vm2
+  group: group1
+  namespace: ns1
+  annotations:
+    virtualmachinev0/node_name: node1
+spec:
+  requestVcpus: 1000m
+  limitVcpus: 1000m
+  requestMemory: 1G
+  limitMemory: 1G
+  blockStorageIDs:
+    - bs1
+  actionState: PowerOn
+---
+meta:
+  apiType: systemv0/virtualrouter
+  id: vr1
+  name: vr1
+  group: group1
+  namespace: ns1
+  annotations:
+    virtualrouterv0/node_name: node1
+`
+
+func TestNode(t *testing.T) {
+	h := humtesting.Start(t, &humtesting.Options{NodeNames: []string{"node1", "node2"}})
+	h.CreateNamespace("group1", "ns1")
+	h.Apply(manifest)
+	h.WaitForVirtualMachineState("group1", "ns1", "vm1", system.VirtualMachineStateRunning)
+	h.WaitForVirtualMachineState("group1", "ns1", "vm2", system.VirtualMachineStateRunning)
+	h.WaitFor("virtualrouter to be running", func(ctx context.Context) (bool, error) {
+		vr, err := h.Clients.SystemV0().VirtualRouter().Get(ctx, "group1", "ns1", "vr1")
+		if err != nil {
+			return false, err
+		}
+		return vr.Status.State == system.VirtualRouterStateRunning, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	progress := []string{}
+	res, err := drain.Node(ctx, h.Clients, "node1", &drain.Options{Migrate: true}, func(p *drain.Progress) {
+		progress = append(progress, p.Object.ID+": "+p.Message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Migrated != 1 || res.PoweredOff != 1 || res.Relocated != 1 {
+		t.Fatalf("unexpected result: %+v, progress: %v", res, progress)
+	}
+
+	node, err := h.Clients.SystemV0().Node().Get(ctx, "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !node.Spec.Unschedulable {
+		t.Fatal("expected node1 to be cordoned")
+	}
+
+	// Localのblockstorageを使っていないVMは別のノードで起動し直す
+	vm1, err := h.Clients.SystemV0().VirtualMachine().Get(ctx, "group1", "ns1", "vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm1.Annotations["virtualmachinev0/node_name"] != "node2" || vm1.Status.State != system.VirtualMachineStateRunning {
+		t.Fatalf("unexpected vm1: %+v", vm1)
+	}
+
+	// Localのblockstorageを使っているVMは停止するだけ
+	vm2, err := h.Clients.SystemV0().VirtualMachine().Get(ctx, "group1", "ns1", "vm2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm2.Annotations["virtualmachinev0/node_name"] != "node1" || vm2.Status.State != system.VirtualMachineStateStopped {
+		t.Fatalf("unexpected vm2: %+v", vm2)
+	}
+
+	vr, err := h.Clients.SystemV0().VirtualRouter().Get(ctx, "group1", "ns1", "vr1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vr.Annotations["virtualrouterv0/node_name"] != "node2" || vr.Status.State != system.VirtualRouterStateRunning {
+		t.Fatalf("unexpected vr1: %+v", vr)
+	}
+
+	if _, err := drain.Cordon(ctx, h.Clients, "node1", false); err != nil {
+		t.Fatal(err)
+	}
+	node, err = h.Clients.SystemV0().Node().Get(ctx, "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Spec.Unschedulable {
+		t.Fatal("expected node1 to be uncordoned")
+	}
+}
+
+func newFakeClients(vms ...*system.VirtualMachine) *fake.Clients {
+	objects := []interface{}{
+		&system.Node{Meta: meta.Meta{ID: "node1"}},
+		&core.Group{Meta: meta.Meta{ID: "group1"}},
+		&core.Namespace{Meta: meta.Meta{ID: "ns1", Group: "group1"}},
+	}
+	for _, vm := range vms {
+		objects = append(objects, vm)
+	}
+	return fake.NewClients(objects...)
+}
+
+func newVirtualMachine(id string, actionState system.VirtualMachineActionState, state system.VirtualMachineState) *system.VirtualMachine {
+	return &system.VirtualMachine{
+		Meta: meta.Meta{
+			ID:        id,
+			Group:     "group1",
+			Namespace: "ns1",
+			Annotations: map[string]string{
+				"virtualmachinev0/node_name": "node1",
+			},
+		},
+		Spec: system.VirtualMachineSpec{
+			ActionState: actionState,
+		},
+		Status: system.VirtualMachineStatus{
+			State: state,
+		},
+	}
+}
+
+func TestNodeVirtualMachineStopTimeout(t *testing.T) {
+	// VMを停止するagentがいないので停止を待つ間にタイムアウトする
+	clients := newFakeClients(newVirtualMachine("vm1", system.VirtualMachineActionStatePowerOn, system.VirtualMachineStateRunning))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	progress := []string{}
+	_, err := drain.Node(ctx, clients, "node1", &drain.Options{
+		Migrate:                   true,
+		VirtualMachineStopTimeout: time.Millisecond * 100,
+	}, func(p *drain.Progress) {
+		progress = append(progress, p.Object.ID+": "+p.Message)
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "group1/ns1/vm1") || !strings.Contains(err.Error(), "did not stop within 100ms") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("expected to give up before the context is done")
+	}
+	if progress[len(progress)-1] != "vm1: did not stop within 100ms" {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+
+	node, err := clients.SystemV0().Node().Get(ctx, "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !node.Spec.Unschedulable {
+		t.Fatal("expected node1 to stay cordoned")
+	}
+}
+
+func TestNodeStoppedVirtualMachine(t *testing.T) {
+	// 停止しているVMは割り当てを外すだけで待たない
+	clients := newFakeClients(newVirtualMachine("vm1", system.VirtualMachineActionStatePowerOff, system.VirtualMachineStateStopped))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	res, err := drain.Node(ctx, clients, "node1", &drain.Options{Migrate: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Migrated != 1 || res.PoweredOff != 0 || res.Relocated != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	vm, err := clients.SystemV0().VirtualMachine().Get(ctx, "group1", "ns1", "vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vm.Annotations["virtualmachinev0/node_name"]; ok || vm.Spec.ActionState != system.VirtualMachineActionStatePowerOff {
+		t.Fatalf("unexpected vm1: %+v", vm)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -24,8 +23,9 @@ var getBlockStorageCmd = &cobra.Command{
 		"bs",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		bsList, err := clients.SystemV0().BlockStorage().List(group, namespace)
+		ctx := cmd.Context()
+		clients := newClients()
+		bsList, err := clients.SystemV0().BlockStorage().List(ctx, group, namespace)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -54,11 +54,12 @@ var getBlockStorageCmd = &cobra.Command{
 				"Type",
 				"FromType",
 				"Status",
+				"Conditions",
 			})
 			for _, bs := range bsList {
 				state := string(bs.Status.State)
-				if bs.DeleteState != "" {
-					state = string(bs.DeleteState)
+				if bs.IsDeleting() {
+					state = "Deleting"
 				}
 				table.Append([]string{
 					bs.ID,
@@ -69,6 +70,7 @@ var getBlockStorageCmd = &cobra.Command{
 					bs.Annotations[agentbsv0.BlockStorageV0AnnotationType],
 					string(bs.Spec.From.Type),
 					state,
+					formatConditions(bs.Status.Conditions),
 				})
 			}
 
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+func init() {
+	getCmd.AddCommand(getEventCmd)
+}
+
+var getEventCmd = &cobra.Command{
+	Use: "event [<kind>/<id>]",
+	Aliases: []string{
+		"events",
+		"ev",
+	},
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		clients := newClients()
+
+		var eventList []*core.Event
+		var err error
+		if len(args) == 0 {
+			eventList, err = clients.CoreV0().Event().List(ctx)
+		} else {
+			var ref meta.ObjectReference
+			ref, err = parseObjectReference(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			eventList, err = clients.CoreV0().Event().ListByObject(ctx, ref)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		sort.Slice(eventList, func(i, j int) bool {
+			return eventList[i].Spec.LastTimestamp.Before(eventList[j].Spec.LastTimestamp)
+		})
+
+		switch output {
+		case "json":
+			out, err := json.MarshalIndent(eventList, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		case "yaml":
+			out, err := yaml.Marshal(eventList)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		default:
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{
+				"Last Seen",
+				"Type",
+				"Reason",
+				"Object",
+				"Count",
+				"Node",
+				"Message",
+			})
+			now := time.Now()
+			for _, e := range eventList {
+				obj := e.Spec.InvolvedObject
+				table.Append([]string{
+					now.Sub(e.Spec.LastTimestamp).Round(time.Second).String(),
+					string(e.Spec.Type),
+					e.Spec.Reason,
+					fmt.Sprintf("%s/%s", obj.APIType, obj.ID),
+					fmt.Sprint(e.Spec.Count),
+					e.Spec.ReportingNode,
+					e.Spec.Message,
+				})
+			}
+
+			table.Render()
+		}
+	},
+}
@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,9 @@ var getExternalIPCmd = &cobra.Command{
 		"eip",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		eipList, err := clients.CoreV0().ExternalIP().List()
+		ctx := cmd.Context()
+		clients := newClients()
+		eipList, err := clients.CoreV0().ExternalIP().List(ctx)
 		if err != nil {
 			log.Fatal(err)
 		}
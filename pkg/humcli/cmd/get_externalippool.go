@@ -4,9 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +24,9 @@ var getExternalIPPoolCmd = &cobra.Command{
 		"eippool",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		eippoolList, err := clients.CoreV0().ExternalIPPool().List()
+		ctx := cmd.Context()
+		clients := newClients()
+		eippoolList, err := clients.CoreV0().ExternalIPPool().List(ctx)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -49,6 +51,8 @@ var getExternalIPPoolCmd = &cobra.Command{
 				"Bridge",
 				"IPv4 CIDR",
 				"IPv6 CIDR",
+				"IPv4 Used",
+				"IPv6 Used",
 			})
 			for _, eippool := range eippoolList {
 				table.Append([]string{
@@ -56,6 +60,8 @@ var getExternalIPPoolCmd = &cobra.Command{
 					eippool.Spec.BridgeName,
 					eippool.Spec.IPv4CIDR,
 					eippool.Spec.IPv6CIDR,
+					formatUtilization(eippool.Status.IPv4Utilization),
+					formatUtilization(eippool.Status.IPv6Utilization),
 				})
 			}
 
@@ -63,3 +69,13 @@ var getExternalIPPoolCmd = &cobra.Command{
 		}
 	},
 }
+
+func formatUtilization(u core.ExternalIPPoolUtilization) string {
+	if u.Capacity == 0 {
+		return "-"
+	}
+	if u.Capacity == math.MaxUint64 {
+		return fmt.Sprintf("%d/-", u.Used)
+	}
+	return fmt.Sprintf("%d/%d (%.1f%%)", u.Used, u.Capacity, float64(u.Used)/float64(u.Capacity)*100)
+}
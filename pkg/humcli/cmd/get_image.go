@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -21,8 +20,9 @@ var getImageCmd = &cobra.Command{
 	Use:     "image",
 	Aliases: []string{},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		imList, err := clients.SystemV0().Image().List(group)
+		ctx := cmd.Context()
+		clients := newClients()
+		imList, err := clients.SystemV0().Image().List(ctx, group)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -47,10 +47,27 @@ var getImageCmd = &cobra.Command{
 				"Name",
 				"Tags (EntityID)",
 			})
+			itList, err := clients.SystemV0().ImageTag().List(ctx, group)
+			if err != nil {
+				log.Fatal(err)
+			}
+
 			for _, im := range imList {
 
 				tags := ""
+				tagged := map[string]bool{}
+				for _, it := range itList {
+					if it.Spec.ImageName != im.ID {
+						continue
+					}
+					tags += fmt.Sprintf("%s (%s)\n", it.Spec.Tag, it.Spec.ImageEntityID)
+					tagged[it.Spec.Tag] = true
+				}
+				// ImageTagがないものはEntityMapのtagを表示する
 				for tag, entityID := range im.Spec.EntityMap {
+					if tagged[tag] {
+						continue
+					}
 					tags += fmt.Sprintf("%s (%s)\n", tag, entityID)
 				}
 
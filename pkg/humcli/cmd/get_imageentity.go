@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,9 @@ var getImageEntityCmd = &cobra.Command{
 		"ie",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		ieList, err := clients.SystemV0().ImageEntity().List(group)
+		ctx := cmd.Context()
+		clients := newClients()
+		ieList, err := clients.SystemV0().ImageEntity().List(ctx, group)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -50,11 +50,12 @@ var getImageEntityCmd = &cobra.Command{
 				"Source(ns/bs)",
 				"Status",
 				"Hash",
+				"Conditions",
 			})
 			for _, ie := range ieList {
 				state := string(ie.Status.State)
-				if ie.DeleteState != "" {
-					state = string(ie.DeleteState)
+				if ie.IsDeleting() {
+					state = "Deleting"
 				}
 				table.Append([]string{
 					ie.ID,
@@ -64,6 +65,7 @@ var getImageEntityCmd = &cobra.Command{
 						ie.Spec.Source.BlockStorageID),
 					state,
 					ie.Spec.Hash,
+					formatConditions(ie.Status.Conditions),
 				})
 			}
 
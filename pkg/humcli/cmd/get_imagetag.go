@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+func init() {
+	getCmd.AddCommand(getImageTagCmd)
+}
+
+var getImageTagCmd = &cobra.Command{
+	Use: "imagetag [<image name>]",
+	Aliases: []string{
+		"imagetags",
+		"it",
+	},
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		clients := newClients()
+
+		var itList []*system.ImageTag
+		var err error
+		if len(args) == 1 {
+			itList, err = clients.SystemV0().ImageTag().ListByImage(ctx, group, args[0])
+		} else {
+			itList, err = clients.SystemV0().ImageTag().List(ctx, group)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch output {
+		case "json":
+			out, err := json.MarshalIndent(itList, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		case "yaml":
+			out, err := yaml.Marshal(itList)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		default:
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{
+				"Image",
+				"Tag",
+				"ImageEntity",
+				"Immutable",
+				"History",
+			})
+			for _, it := range itList {
+				// 新しい順に表示する
+				history := []string{}
+				for i := len(it.Status.History) - 1; i >= 0; i-- {
+					h := it.Status.History[i]
+					history = append(history, fmt.Sprintf("%s (%s)", h.ImageEntityID, h.TaggedAt.Format("2006-01-02 15:04:05")))
+				}
+
+				table.Append([]string{
+					it.Spec.ImageName,
+					it.Spec.Tag,
+					it.Spec.ImageEntityID,
+					fmt.Sprintf("%t", it.Spec.Immutable),
+					strings.Join(history, "\n"),
+				})
+			}
+
+			table.Render()
+		}
+	},
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+func init() {
+	getCmd.AddCommand(getLeaseCmd)
+}
+
+var getLeaseCmd = &cobra.Command{
+	Use: "lease",
+	Aliases: []string{
+		"leases",
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		clients := newClients()
+		leaseList, err := clients.CoreV0().Lease().List(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch output {
+		case "json":
+			out, err := json.MarshalIndent(leaseList, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		case "yaml":
+			out, err := yaml.Marshal(leaseList)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		default:
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{
+				"Name",
+				"Holder",
+				"Last Renewed",
+				"Transitions",
+			})
+			now := time.Now()
+			for _, l := range leaseList {
+				renewed := ""
+				if l.Spec.RenewTime != nil {
+					renewed = now.Sub(*l.Spec.RenewTime).Round(time.Second).String()
+				}
+				table.Append([]string{
+					l.Name,
+					l.Spec.HolderIdentity,
+					renewed,
+					fmt.Sprint(l.Spec.LeaseTransitions),
+				})
+			}
+
+			table.Render()
+		}
+	},
+}
@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,9 @@ var getNetworkCmd = &cobra.Command{
 		"net",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		netList, err := clients.CoreV0().Network().List(group, namespace)
+		ctx := cmd.Context()
+		clients := newClients()
+		netList, err := clients.CoreV0().Network().List(ctx, group, namespace)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -50,6 +50,7 @@ var getNetworkCmd = &cobra.Command{
 				"IPv4CIDR",
 				"IPv6CIDR",
 				"Network ID",
+				"Conditions",
 			})
 			for _, n := range netList {
 				table.Append([]string{
@@ -58,6 +59,7 @@ var getNetworkCmd = &cobra.Command{
 					n.Spec.Template.Spec.IPv4CIDR,
 					n.Spec.Template.Spec.IPv6CIDR,
 					n.Spec.Template.Spec.ID,
+					formatConditions(n.Status.Conditions),
 				})
 			}
 
@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -20,8 +20,9 @@ func init() {
 var getNodeCmd = &cobra.Command{
 	Use: "node",
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		nodeList, err := clients.SystemV0().Node().List()
+		ctx := cmd.Context()
+		clients := newClients()
+		nodeList, err := clients.SystemV0().Node().List(ctx)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -43,14 +44,21 @@ var getNodeCmd = &cobra.Command{
 			table := tablewriter.NewWriter(os.Stdout)
 			table.SetHeader([]string{
 				"Name",
-				"LimitVcpus",
-				"LimitMemory",
+				"Vcpus",
+				"Memory",
+				"Disk",
+				"Schedulable",
+				"Conditions",
 			})
 			for _, n := range nodeList {
+				// allocatableを設定しない古いagentのノードはlimitを表示する
 				table.Append([]string{
 					n.Name,
-					n.Spec.LimitVcpus,
-					n.Spec.LimitMemory,
+					formatAllocatable(n.Status.Allocatable.Vcpus, n.Spec.LimitVcpus),
+					formatAllocatable(n.Status.Allocatable.Memory, n.Spec.LimitMemory),
+					formatAllocatable(n.Status.Allocatable.Disk, n.Spec.LimitDisk),
+					strconv.FormatBool(!n.Spec.Unschedulable),
+					formatConditions(n.Status.Conditions),
 				})
 			}
 
@@ -58,3 +66,10 @@ var getNodeCmd = &cobra.Command{
 		}
 	},
 }
+
+func formatAllocatable(allocatable, limit string) string {
+	if allocatable != "" {
+		return allocatable
+	}
+	return limit
+}
@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,9 @@ var getNodeNetworkCmd = &cobra.Command{
 		"nodenet",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		netList, err := clients.SystemV0().NodeNetwork().List(group, namespace)
+		ctx := cmd.Context()
+		clients := newClients()
+		netList, err := clients.SystemV0().NodeNetwork().List(ctx, group, namespace)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -52,6 +52,7 @@ var getNodeNetworkCmd = &cobra.Command{
 				"Network ID",
 				"Status",
 				"Node",
+				"Conditions",
 			})
 			for _, n := range netList {
 				table.Append([]string{
@@ -62,6 +63,7 @@ var getNodeNetworkCmd = &cobra.Command{
 					n.Spec.ID,
 					string(n.Status.State),
 					"",
+					formatConditions(n.Status.Conditions),
 				})
 			}
 
@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -25,8 +24,9 @@ var getVirtualMachineCmd = &cobra.Command{
 		"vmachine",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		vmList, err := clients.SystemV0().VirtualMachine().List(group, namespace)
+		ctx := cmd.Context()
+		clients := newClients()
+		vmList, err := clients.SystemV0().VirtualMachine().List(ctx, group, namespace)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -53,6 +53,7 @@ var getVirtualMachineCmd = &cobra.Command{
 				"Vcpus(Limit/Req)\nMemory(Limit/Req)",
 				"Node",
 				"UUID",
+				"Conditions",
 			})
 			for _, vm := range vmList {
 				table.Append([]string{
@@ -66,6 +67,7 @@ var getVirtualMachineCmd = &cobra.Command{
 						vm.Spec.RequestMemory),
 					vm.Annotations[agentvmv0.VirtualMachineV0AnnotationNodeName],
 					vm.Spec.UUID,
+					formatConditions(vm.Status.Conditions),
 				})
 			}
 
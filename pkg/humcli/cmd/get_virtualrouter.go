@@ -7,12 +7,12 @@ import (
 	"os"
 	"strings"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
 	"github.com/olekukonko/tablewriter"
 	agentvrv0 "github.com/ophum/humstack/pkg/agents/system/virtualrouter"
+	"github.com/ophum/humstack/pkg/api/meta"
 )
 
 func init() {
@@ -27,8 +27,9 @@ var getVirtualRouterCmd = &cobra.Command{
 	},
 
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		vrList, err := clients.SystemV0().VirtualRouter().List(group, namespace)
+		ctx := cmd.Context()
+		clients := newClients()
+		vrList, err := clients.SystemV0().VirtualRouter().List(ctx, group, namespace)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -55,11 +56,16 @@ var getVirtualRouterCmd = &cobra.Command{
 				"NAT Gateway IP",
 				"EIP => Local",
 				"Node",
+				"Conditions",
 			})
 			for _, vr := range vrList {
 				eips := []string{}
 				for _, eip := range vr.Spec.ExternalIPs {
-					e, err := clients.CoreV0().ExternalIP().Get(eip.ExternalIPID)
+					e, err := clients.CoreV0().ExternalIP().Get(ctx, eip.ExternalIPID)
+					if meta.IsNotFound(err) {
+						eips = append(eips, fmt.Sprintf("%s(not found) => %s", eip.ExternalIPID, eip.BindInternalIPv4Address))
+						continue
+					}
 					if err != nil {
 						log.Fatal(err)
 					}
@@ -73,6 +79,7 @@ var getVirtualRouterCmd = &cobra.Command{
 					vr.Spec.NATGatewayIP,
 					strings.Join(eips, "\n"),
 					vr.Annotations[agentvrv0.VirtualRouterV0AnnotationNodeName],
+					formatConditions(vr.Status.Conditions),
 				})
 			}
 
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ophum/humstack/pkg/humcli/cmd/drain"
+	"github.com/spf13/cobra"
+)
+
+var (
+	drainMigrate       bool
+	drainTimeout       time.Duration
+	drainVMStopTimeout time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(nodeCmd)
+	nodeCmd.AddCommand(nodeCordonCmd)
+	nodeCmd.AddCommand(nodeUncordonCmd)
+	nodeCmd.AddCommand(nodeDrainCmd)
+
+	nodeDrainCmd.Flags().BoolVar(&drainMigrate, "migrate", false, "restart virtualmachines without local blockstorages on other nodes")
+	nodeDrainCmd.Flags().DurationVar(&drainTimeout, "timeout", time.Minute*10, "give up draining after this duration")
+	nodeDrainCmd.Flags().DurationVar(&drainVMStopTimeout, "vm-stop-timeout", time.Minute*3, "give up when a virtualmachine does not stop within this duration")
+}
+
+var nodeCmd = &cobra.Command{
+	Use: "node",
+}
+
+// nodeCordonCmd はノードに新しいVMを割り当てないようにする
+var nodeCordonCmd = &cobra.Command{
+	Use:  "cordon <node>",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := drain.Cordon(cmd.Context(), newClients(), args[0], true); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("node/%s cordoned\n", args[0])
+	},
+}
+
+var nodeUncordonCmd = &cobra.Command{
+	Use:  "uncordon <node>",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := drain.Cordon(cmd.Context(), newClients(), args[0], false); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("node/%s uncordoned\n", args[0])
+	},
+}
+
+// nodeDrainCmd はノードをcordonし、VMとvirtualrouterをノードから退避する
+// e.g. humcli node drain node1 --migrate --timeout 30m
+var nodeDrainCmd = &cobra.Command{
+	Use:  "drain <node>",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(cmd.Context(), drainTimeout)
+		defer cancel()
+
+		res, err := drain.Node(ctx, newClients(), args[0], &drain.Options{
+			Migrate:                   drainMigrate,
+			VirtualMachineStopTimeout: drainVMStopTimeout,
+		}, func(p *drain.Progress) {
+			fmt.Printf("%s/%s/%s/%s: %s\n", p.Object.APIType, p.Object.Group, p.Object.Namespace, p.Object.ID, p.Message)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("node/%s drained: %d migrated, %d powered off, %d virtualrouters relocated\n",
+			args[0], res.Migrated, res.PoweredOff, res.Relocated)
+	},
+}
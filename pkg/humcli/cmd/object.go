@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/scheme"
+	"github.com/ophum/humstack/pkg/humcli/cmd/apply"
+)
+
+// parseObjectReference は `vm/foo` のような指定を--group, --namespaceと合わせてリソースの参照にする
+// kindにはschemeに登録されている名前を指定できる
+func parseObjectReference(s string) (meta.ObjectReference, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return meta.ObjectReference{}, fmt.Errorf("object must be `<kind>/<id>`, but got `%s`", s)
+	}
+
+	r, err := scheme.LookupName(parts[0])
+	if err != nil {
+		return meta.ObjectReference{}, err
+	}
+	return r.Reference(group, namespace, parts[1]), nil
+}
+
+// forEachObject はfileに含まれるリソースを順にfに渡す
+func forEachObject(file string, f func(item *meta.Object) error) error {
+	fp, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	return apply.ForEach(fp, f)
+}
+
+// printObject はobjをapiTypeのGoの型にしてyamlで表示する
+func printObject(obj *meta.Object) {
+	v, err := scheme.FromObject(obj)
+	if err != nil {
+		printYAML(obj)
+		return
+	}
+	printYAML(v)
+}
@@ -1,8 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -18,11 +26,28 @@ var (
 	group            string
 	namespace        string
 	debug            bool
+	dryRun           bool
 	output           string
+	requestTimeout   time.Duration
 )
 
+// Execute はSIGINT, SIGTERMを受け取るとリクエストやwatchをキャンセルする
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		select {
+		case <-sigCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
@@ -35,7 +60,17 @@ func init() {
 	rootCmd.PersistentFlags().Int32Var(&apiServerPort, "api-server-port", 8080, "apiserver Port")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "debug mode")
 	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "table", "output format, `table` or `json` or `yaml`")
+	rootCmd.PersistentFlags().DurationVar(&requestTimeout, "request-timeout", 0, "timeout of each request to apiserver (default 30s)")
 
+	// configファイルでは tls.caFile のように指定する
+	rootCmd.PersistentFlags().String("ca-file", "", "ca bundle to verify apiserver certificate")
+	rootCmd.PersistentFlags().String("cert-file", "", "client certificate file")
+	rootCmd.PersistentFlags().String("key-file", "", "client private key file")
+	rootCmd.PersistentFlags().Bool("insecure-skip-verify", false, "skip verifying apiserver certificate")
+	viper.BindPFlag("tls.caFile", rootCmd.PersistentFlags().Lookup("ca-file"))
+	viper.BindPFlag("tls.certFile", rootCmd.PersistentFlags().Lookup("cert-file"))
+	viper.BindPFlag("tls.keyFile", rootCmd.PersistentFlags().Lookup("key-file"))
+	viper.BindPFlag("tls.insecureSkipVerify", rootCmd.PersistentFlags().Lookup("insecure-skip-verify"))
 }
 
 func initConfig() {
@@ -51,3 +86,22 @@ func initConfig() {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+func newClients() *client.Clients {
+	tlsConfig, err := tlsutil.NewClientTLSConfig(&tlsutil.ClientConfig{
+		CAFile:             viper.GetString("tls.caFile"),
+		CertFile:           viper.GetString("tls.certFile"),
+		KeyFile:            viper.GetString("tls.keyFile"),
+		InsecureSkipVerify: viper.GetBool("tls.insecureSkipVerify"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return client.NewClientsWithConfig(&client.Config{
+		Address:         apiServerAddress,
+		Port:            apiServerPort,
+		TLSClientConfig: tlsConfig,
+		Timeout:         requestTimeout,
+	})
+}
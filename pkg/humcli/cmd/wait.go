@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client/wait"
+	"github.com/spf13/cobra"
+)
+
+var (
+	waitFor     string
+	waitTimeout time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+
+	waitCmd.Flags().StringVar(&waitFor, "for", "ready", "`ready`, `delete`, `state=<state>` or `condition=<type>[=<status>]`")
+	waitCmd.Flags().DurationVar(&waitTimeout, "timeout", time.Minute*5, "give up waiting after this duration")
+}
+
+// waitCmd は指定したリソースが全て--forの状態になるまで待つ
+// e.g. humcli wait --for=state=Running vm/foo --timeout 5m
+var waitCmd = &cobra.Command{
+	Use:  "wait <kind>/<id>...",
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		predicate, err := parseWaitFor(waitFor)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		refs := []meta.ObjectReference{}
+		for _, arg := range args {
+			ref, err := parseObjectReference(arg)
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			refs = append(refs, ref)
+		}
+
+		// タイムアウトは全てのリソースを合わせた時間
+		ctx, cancel := context.WithTimeout(cmd.Context(), waitTimeout)
+		defer cancel()
+
+		clients := newClients()
+		for i, ref := range refs {
+			if _, err := clients.WaitFor(ctx, ref, predicate); err != nil {
+				log.Fatal(err.Error())
+			}
+			fmt.Printf("%s: %s\n", args[i], waitFor)
+		}
+	},
+}
+
+// parseWaitFor は--forの値をPredicateにする
+func parseWaitFor(s string) (wait.Predicate, error) {
+	parts := strings.SplitN(s, "=", 2)
+	switch parts[0] {
+	case "ready":
+		if len(parts) == 1 {
+			return wait.Ready(), nil
+		}
+	case "delete":
+		if len(parts) == 1 {
+			return wait.Deleted(), nil
+		}
+	case "state":
+		if len(parts) == 2 && parts[1] != "" {
+			return wait.State(parts[1]), nil
+		}
+	case "condition":
+		if len(parts) == 2 && parts[1] != "" {
+			// statusを省略した場合はTrueを待つ
+			c := strings.SplitN(parts[1], "=", 2)
+			status := meta.ConditionTrue
+			if len(c) == 2 {
+				status = meta.ConditionStatus(c[1])
+			}
+			return wait.Condition(meta.ConditionType(c[0]), status), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid --for `%s`", s)
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"log"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 )
 
@@ -14,17 +13,20 @@ func init() {
 var watchCmd = &cobra.Command{
 	Use: "watch",
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients("localhost", 8080)
+		clients := newClients()
 
 		apiType := ""
 		if len(args) > 0 {
 			apiType = args[0]
 		}
-		clients.WatchV0().Watch(apiType, func(before, after interface{}) {
+		err := clients.WatchV0().Watch(cmd.Context(), apiType, func(before, after interface{}) {
 			log.Println("WATCH")
 			log.Printf("BEFORE: %+v", before)
 			log.Printf("AFTER: %+v", after)
 		})
+		if err != nil && cmd.Context().Err() == nil {
+			log.Fatal(err)
+		}
 
 	},
 }
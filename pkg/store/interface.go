@@ -3,6 +3,7 @@ package store
 type Store interface {
 	List(prefix string, f func(n int) []interface{}) error
 	Get(key string, v interface{}) error
+	Keys(prefix string) ([]string, error)
 	Put(key string, data interface{})
 	Delete(key string)
 	Lock(key string)
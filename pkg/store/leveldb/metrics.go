@@ -0,0 +1,26 @@
+package leveldb
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var operationDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "humstack",
+		Subsystem: "leveldb_store",
+		Name:      "operation_duration_seconds",
+		Help:      "LevelDBStore operation latencies by operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16),
+	},
+	[]string{"operation"},
+)
+
+func init() {
+	prometheus.MustRegister(operationDuration)
+}
+
+func observeOperation(operation string, start time.Time) {
+	operationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/syndtr/goleveldb/leveldb"
@@ -47,6 +48,8 @@ func (s *LevelDBStore) Close() error {
 }
 
 func (s *LevelDBStore) List(prefix string, f func(n int) []interface{}) error {
+	defer observeOperation("list", time.Now())
+
 	iter := s.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
 	defer iter.Release()
 	listJSON := [][]byte{}
@@ -73,6 +76,8 @@ func (s *LevelDBStore) List(prefix string, f func(n int) []interface{}) error {
 }
 
 func (s *LevelDBStore) Get(key string, v interface{}) error {
+	defer observeOperation("get", time.Now())
+
 	dataJSON, err := s.db.Get([]byte(key), nil)
 	if err != nil {
 		if err == leveldbErrors.ErrNotFound {
@@ -84,7 +89,23 @@ func (s *LevelDBStore) Get(key string, v interface{}) error {
 	return json.Unmarshal(dataJSON, v)
 }
 
+func (s *LevelDBStore) Keys(prefix string) ([]string, error) {
+	defer observeOperation("keys", time.Now())
+
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+
+	keys := []string{}
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+
+	return keys, iter.Error()
+}
+
 func (s *LevelDBStore) Put(key string, data interface{}) {
+	defer observeOperation("put", time.Now())
+
 	before, err := s.db.Get([]byte(key), nil)
 	if err != nil {
 		if err != leveldbErrors.ErrNotFound {
@@ -97,6 +118,18 @@ func (s *LevelDBStore) Put(key string, data interface{}) {
 		return
 	}
 
+	// 削除中でfinalizerが全て外れたリソースは書き込まずに削除する
+	after := meta.Object{}
+	if err := json.Unmarshal(dataJSON, &after); err != nil {
+		return
+	}
+	if after.Meta.IsRemovable() {
+		if len(before) != 0 {
+			s.Delete(key)
+		}
+		return
+	}
+
 	err = s.db.Put([]byte(key), dataJSON, nil)
 	if err != nil {
 		return
@@ -132,6 +165,8 @@ func (s *LevelDBStore) Put(key string, data interface{}) {
 }
 
 func (s *LevelDBStore) Delete(key string) {
+	defer observeOperation("delete", time.Now())
+
 	before, err := s.db.Get([]byte(key), nil)
 	if err != nil {
 		if err != leveldbErrors.ErrNotFound {
@@ -169,6 +204,35 @@ func (s *LevelDBStore) Delete(key string) {
 	s.notifier <- string(noticeJSON)
 }
 
+// Ping はDBが読み取り可能かどうかを確認する
+func (s *LevelDBStore) Ping() error {
+	_, err := s.db.GetProperty("leveldb.num-files-at-level0")
+	return err
+}
+
+// CountObjects はAPITypeごとのリソース数を返す
+func (s *LevelDBStore) CountObjects() (map[meta.APIType]int, error) {
+	defer observeOperation("count", time.Now())
+
+	iter := s.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	counts := map[meta.APIType]int{}
+	for iter.Next() {
+		obj := struct {
+			Meta struct {
+				APIType meta.APIType `json:"apiType"`
+			} `json:"meta"`
+		}{}
+		if err := json.Unmarshal(iter.Value(), &obj); err != nil {
+			return nil, err
+		}
+		counts[obj.Meta.APIType]++
+	}
+
+	return counts, iter.Error()
+}
+
 func (s *LevelDBStore) Lock(key string) {
 	if _, ok := s.lockTable[key]; !ok {
 		s.lockTable[key] = &sync.RWMutex{}
@@ -3,11 +3,11 @@ package memory
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
 	"reflect"
 	"strings"
 	"sync"
+
+	"github.com/ophum/humstack/pkg/api/meta"
 )
 
 type MemoryStore struct {
@@ -43,24 +43,36 @@ func (s *MemoryStore) Get(key string, v interface{}) error {
 	if d, ok := s.data[key]; ok {
 		a := reflect.Indirect(reflect.ValueOf(v))
 		a.Set(reflect.ValueOf(d))
-		log.Println(v)
 		return nil
 	}
 	return errors.New("Not Found")
 }
 
+func (s *MemoryStore) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	for k := range s.data {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
 func (s *MemoryStore) Put(key string, data interface{}) {
+	// 削除中でfinalizerが全て外れたリソースは書き込まずに削除する
+	if dataJSON, err := json.Marshal(data); err == nil {
+		obj := meta.Object{}
+		if err := json.Unmarshal(dataJSON, &obj); err == nil && obj.Meta.IsRemovable() {
+			s.Delete(key)
+			return
+		}
+	}
+
 	s.data[key] = data
-	buf, _ := json.MarshalIndent(s.data, "", "  ")
-	fmt.Println("============= PUT DATA ==============")
-	fmt.Println(string(buf))
 }
 
 func (s *MemoryStore) Delete(key string) {
 	delete(s.data, key)
-	buf, _ := json.MarshalIndent(s.data, "", "  ")
-	fmt.Println("============= DEL DATA ==============")
-	fmt.Println(string(buf))
 }
 
 func (s *MemoryStore) Lock(key string) {
@@ -0,0 +1,238 @@
+package testing
+
+import (
+	"context"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client"
+	"go.uber.org/zap"
+)
+
+// fakeSystemAgent はsystem agentの代わりにノードのリソースのstatusを更新する
+// VMの起動やディスクの作成などは行わず、すぐに完了したものとして扱う
+type fakeSystemAgent struct {
+	client   *client.Clients
+	nodeName string
+	logger   *zap.Logger
+}
+
+func newFakeSystemAgent(client *client.Clients, nodeName string, logger *zap.Logger) *fakeSystemAgent {
+	return &fakeSystemAgent{
+		client:   client,
+		nodeName: nodeName,
+		logger:   logger,
+	}
+}
+
+// registerNode はノードをReadyの状態で登録する
+func (a *fakeSystemAgent) registerNode(ctx context.Context) error {
+	node := &system.Node{
+		Meta: meta.Meta{
+			ID:   a.nodeName,
+			Name: a.nodeName,
+		},
+		Spec: system.NodeSpec{
+			Address: "127.0.0.1",
+		},
+	}
+	node, err := a.client.SystemV0().Node().Create(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	node.Status.State = system.NodeStateReady
+	node.Status.Capacity = system.NodeCapacity{
+		Vcpus:  "16000m",
+		Memory: "64G",
+		KVM:    true,
+	}
+	node.Status.Allocatable = system.NodeAllocatable{
+		Vcpus:  "16000m",
+		Memory: "64G",
+	}
+	meta.SetCondition(&node.Status.Conditions, meta.Condition{
+		Type:               system.NodeConditionReady,
+		Status:             meta.ConditionTrue,
+		Reason:             "FakeAgentReady",
+		ObservedGeneration: node.Generation,
+	})
+	_, err = a.client.SystemV0().Node().Update(ctx, node)
+	return err
+}
+
+func (a *fakeSystemAgent) Run(ctx context.Context) {
+	ticker := time.NewTicker(fakeAgentInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.reconcile(ctx); err != nil && ctx.Err() == nil {
+				a.logger.Error(
+					"reconcile",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+			}
+		}
+	}
+}
+
+func (a *fakeSystemAgent) reconcile(ctx context.Context) error {
+	grList, err := a.client.CoreV0().Group().List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range grList {
+		nsList, err := a.client.CoreV0().Namespace().List(ctx, group.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, ns := range nsList {
+			if err := a.syncNodeNetworks(ctx, group.ID, ns.ID); err != nil {
+				return err
+			}
+			if err := a.syncBlockStorages(ctx, group.ID, ns.ID); err != nil {
+				return err
+			}
+			if err := a.syncVirtualMachines(ctx, group.ID, ns.ID); err != nil {
+				return err
+			}
+			if err := a.syncVirtualRouters(ctx, group.ID, ns.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *fakeSystemAgent) syncNodeNetworks(ctx context.Context, groupID, namespaceID string) error {
+	nodeNetList, err := a.client.SystemV0().NodeNetwork().List(ctx, groupID, namespaceID)
+	if err != nil {
+		return err
+	}
+
+	for _, nodeNet := range nodeNetList {
+		if nodeNet.Annotations["nodenetworkv0/node_name"] != a.nodeName ||
+			nodeNet.IsDeleting() ||
+			nodeNet.Status.State == system.NetworkStateAvailable {
+			continue
+		}
+
+		nodeNet.Status.State = system.NetworkStateAvailable
+		meta.SetCondition(&nodeNet.Status.Conditions, meta.Condition{
+			Type:               system.NodeNetworkConditionReady,
+			Status:             meta.ConditionTrue,
+			Reason:             "FakeAgentSynced",
+			ObservedGeneration: nodeNet.Generation,
+		})
+		if _, err := a.client.SystemV0().NodeNetwork().Update(ctx, nodeNet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *fakeSystemAgent) syncBlockStorages(ctx context.Context, groupID, namespaceID string) error {
+	bsList, err := a.client.SystemV0().BlockStorage().List(ctx, groupID, namespaceID)
+	if err != nil {
+		return err
+	}
+
+	for _, bs := range bsList {
+		if bs.Annotations["blockstoragev0/node_name"] != a.nodeName || bs.IsDeleting() {
+			continue
+		}
+
+		// 作成中の状態のものは作成が終わったことにする
+		switch bs.Status.State {
+		case "",
+			system.BlockStorageStateQueued,
+			system.BlockStorageStatePending,
+			system.BlockStorageStateCopying,
+			system.BlockStorageStateDownloading:
+		default:
+			continue
+		}
+
+		bs.Status.State = system.BlockStorageStateActive
+		meta.SetCondition(&bs.Status.Conditions, meta.Condition{
+			Type:               system.BlockStorageConditionProvisioned,
+			Status:             meta.ConditionTrue,
+			Reason:             "FakeAgentProvisioned",
+			ObservedGeneration: bs.Generation,
+		})
+		if _, err := a.client.SystemV0().BlockStorage().Update(ctx, bs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *fakeSystemAgent) syncVirtualMachines(ctx context.Context, groupID, namespaceID string) error {
+	vmList, err := a.client.SystemV0().VirtualMachine().List(ctx, groupID, namespaceID)
+	if err != nil {
+		return err
+	}
+
+	for _, vm := range vmList {
+		if vm.Annotations["virtualmachinev0/node_name"] != a.nodeName || vm.IsDeleting() {
+			continue
+		}
+
+		state := system.VirtualMachineStateRunning
+		booted := meta.ConditionTrue
+		if vm.Spec.ActionState == system.VirtualMachineActionStatePowerOff {
+			state = system.VirtualMachineStateStopped
+			booted = meta.ConditionFalse
+		}
+		if vm.Status.State == state {
+			continue
+		}
+
+		vm.Status.State = state
+		meta.SetCondition(&vm.Status.Conditions, meta.Condition{
+			Type:               system.VirtualMachineConditionBooted,
+			Status:             booted,
+			Reason:             "FakeAgentSynced",
+			ObservedGeneration: vm.Generation,
+		})
+		if _, err := a.client.SystemV0().VirtualMachine().Update(ctx, vm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *fakeSystemAgent) syncVirtualRouters(ctx context.Context, groupID, namespaceID string) error {
+	vrList, err := a.client.SystemV0().VirtualRouter().List(ctx, groupID, namespaceID)
+	if err != nil {
+		return err
+	}
+
+	for _, vr := range vrList {
+		if vr.Annotations["virtualrouterv0/node_name"] != a.nodeName ||
+			vr.IsDeleting() ||
+			vr.Status.State == system.VirtualRouterStateRunning {
+			continue
+		}
+
+		vr.Status.State = system.VirtualRouterStateRunning
+		meta.SetCondition(&vr.Status.Conditions, meta.Condition{
+			Type:               system.VirtualRouterConditionReady,
+			Status:             meta.ConditionTrue,
+			Reason:             "FakeAgentSynced",
+			ObservedGeneration: vr.Generation,
+		})
+		if _, err := a.client.SystemV0().VirtualRouter().Update(ctx, vr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
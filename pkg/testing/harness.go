@@ -0,0 +1,319 @@
+// Package testing はapiserverとagentを1つのプロセスで動かしてテストするためのもの
+// KVM, Ceph, rootがなくても動くように、system agentはstatusを更新するだけのfakeを使う
+package testing
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/agents/core/garbagecollector"
+	"github.com/ophum/humstack/pkg/agents/core/group"
+	"github.com/ophum/humstack/pkg/agents/core/namespace"
+	"github.com/ophum/humstack/pkg/agents/core/network"
+	"github.com/ophum/humstack/pkg/agents/core/scheduler"
+	"github.com/ophum/humstack/pkg/agents/event"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/apiserver"
+	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/humcli/cmd/apply"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultNodeName    = "node1"
+	DefaultWaitTimeout = time.Second * 30
+
+	// TLSの場合にHarness.Clientsが使う証明書のCommonName
+	AdminCommonName = "admin"
+
+	// fakeのsystem agentがreconcileする間隔
+	fakeAgentInterval = time.Millisecond * 100
+)
+
+// Options はHarnessで動かすものの設定
+type Options struct {
+	// fakeのsystem agentを動かすノード。省略時は DefaultNodeName のみ
+	NodeNames []string
+
+	// trueの場合はgroup, namespace, network, garbage collector, schedulerのagentを動かさない
+	DisableCoreAgents bool
+
+	// WaitForの待ち時間。省略時は DefaultWaitTimeout
+	WaitTimeout time.Duration
+
+	// 省略時はログを出さない
+	Logger *zap.Logger
+
+	// trueの場合はapiserverをmTLSで起動する
+	// fakeのsystem agentは `node:<ノード名>`、core agentは `core:<1つ目のノード名>` の証明書で接続する
+	TLS bool
+}
+
+// Harness はランダムなポートで動いているapiserverとagent
+type Harness struct {
+	t testing.TB
+
+	Address string
+	Port    int32
+	Clients *client.Clients
+
+	// 1つ目のノード
+	NodeName  string
+	NodeNames []string
+
+	waitTimeout time.Duration
+	dir         string
+	ca          *certificateAuthority
+	server      *apiserver.Server
+	httpServer  *httptest.Server
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	stopOnce    sync.Once
+}
+
+// Start は一時ディレクトリのLevelDBを使うapiserverとagentを起動する
+// テストの終了時に停止し、一時ディレクトリを削除する
+func Start(t testing.TB, opts *Options) *Harness {
+	t.Helper()
+
+	if opts == nil {
+		opts = &Options{}
+	}
+	nodeNames := opts.NodeNames
+	if len(nodeNames) == 0 {
+		nodeNames = []string{DefaultNodeName}
+	}
+	waitTimeout := opts.WaitTimeout
+	if waitTimeout == 0 {
+		waitTimeout = DefaultWaitTimeout
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	dir, err := ioutil.TempDir("", "humstack-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Harness{
+		t:           t,
+		NodeName:    nodeNames[0],
+		NodeNames:   nodeNames,
+		waitTimeout: waitTimeout,
+		dir:         dir,
+	}
+	t.Cleanup(h.Stop)
+
+	h.server, err = apiserver.NewServer(&apiserver.Config{
+		DatabasePath: dir,
+		EventTTL:     time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ポートは空いているものを使う
+	h.httpServer = httptest.NewUnstartedServer(h.server.Handler())
+	if opts.TLS {
+		if err := h.startTLS(); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		h.httpServer.Start()
+	}
+	host, port, err := net.SplitHostPort(h.httpServer.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Address = host
+	h.Port = int32(p)
+
+	h.Clients = h.NewClients(AdminCommonName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	// ノードはfakeのagentが動く前に登録しておく
+	for _, nodeName := range nodeNames {
+		agent := newFakeSystemAgent(h.NewClients(auth.NodeCommonName(nodeName)), nodeName, logger.With(zap.String("node", nodeName)))
+		if err := agent.registerNode(ctx); err != nil {
+			t.Fatal(err)
+		}
+		h.run(func() { agent.Run(ctx) })
+	}
+
+	if !opts.DisableCoreAgents {
+		coreClients := h.NewClients(auth.CoreCommonName(h.NodeName))
+		recorder := event.NewRecorder(coreClients, h.NodeName, logger.With(zap.Namespace("EventRecorder")))
+
+		grAgent := group.NewGroupAgent(coreClients, logger.With(zap.Namespace("GroupAgent")))
+		nsAgent := namespace.NewNamespaceAgent(coreClients, logger.With(zap.Namespace("NamespaceAgent")))
+		netAgent := network.NewNetworkAgent(coreClients, logger.With(zap.Namespace("NetworkAgent")))
+		gcAgent := garbagecollector.NewGarbageCollectorAgent(coreClients, logger.With(zap.Namespace("GarbageCollectorAgent")))
+		schedAgent := scheduler.NewSchedulerAgent(coreClients, &scheduler.SchedulerAgentConfig{
+			ScoringStrategy: scheduler.ScoringStrategyLeastAllocated,
+		}, logger.With(zap.Namespace("SchedulerAgent")))
+
+		grAgent.SetEventRecorder(recorder)
+		nsAgent.SetEventRecorder(recorder)
+		netAgent.SetEventRecorder(recorder)
+		gcAgent.SetEventRecorder(recorder)
+		schedAgent.SetEventRecorder(recorder)
+
+		h.run(func() { grAgent.Run(ctx) })
+		h.run(func() { nsAgent.Run(ctx) })
+		h.run(func() { netAgent.Run(ctx) })
+		h.run(func() { gcAgent.Run(ctx) })
+		h.run(func() { schedAgent.Run(ctx) })
+	}
+
+	return h
+}
+
+// startTLS はCAを作り、クライアント証明書を必須にしてapiserverを起動する
+func (h *Harness) startTLS() error {
+	ca, err := newCertificateAuthority()
+	if err != nil {
+		return err
+	}
+	cert, err := ca.issue("apiserver")
+	if err != nil {
+		return err
+	}
+
+	h.ca = ca
+	h.httpServer.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+	h.httpServer.StartTLS()
+	return nil
+}
+
+// NewClients はapiserverに接続するclientを返す
+// TLSの場合はcommonNameのクライアント証明書を使う。そうでない場合はcommonNameを使わない
+func (h *Harness) NewClients(commonName string) *client.Clients {
+	h.t.Helper()
+
+	config := &client.Config{
+		Address:       h.Address,
+		Port:          h.Port,
+		RetryWaitTime: time.Millisecond * 10,
+	}
+	if h.ca != nil {
+		cert, err := h.ca.issue(commonName)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		config.TLSClientConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			RootCAs:      h.ca.pool,
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+	return client.NewClientsWithConfig(config)
+}
+
+func (h *Harness) run(f func()) {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		f()
+	}()
+}
+
+// Stop はagentとapiserverを止めて一時ディレクトリを削除する
+// Startでテストの終了時に呼ぶように登録しているので、途中で止めたい場合のみ呼ぶ
+func (h *Harness) Stop() {
+	h.stopOnce.Do(func() {
+		if h.cancel != nil {
+			h.cancel()
+		}
+		h.wg.Wait()
+
+		if h.httpServer != nil {
+			h.httpServer.Close()
+		}
+		if h.server != nil {
+			if err := h.server.Close(); err != nil {
+				h.t.Error(err)
+			}
+		}
+		os.RemoveAll(h.dir)
+	})
+}
+
+// Apply はyamlのマニフェストに含まれるリソースをhumcli applyと同じようにapplyする
+func (h *Harness) Apply(manifest string) []*apply.Result {
+	h.t.Helper()
+
+	results := []*apply.Result{}
+	err := apply.Manifest(context.Background(), strings.NewReader(manifest), h.Clients, func(item *meta.Object, res *apply.Result) {
+		results = append(results, res)
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return results
+}
+
+// ApplyFile はfileのマニフェストをapplyする
+func (h *Harness) ApplyFile(file string) []*apply.Result {
+	h.t.Helper()
+
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return h.Apply(string(buf))
+}
+
+// CreateNamespace はgroupとnamespaceを作成する
+// groupが既に存在する場合はnamespaceのみ作成する
+func (h *Harness) CreateNamespace(groupID, namespaceID string) {
+	h.t.Helper()
+
+	ctx := context.Background()
+	_, err := h.Clients.CoreV0().Group().Create(ctx, &core.Group{
+		Meta: meta.Meta{
+			ID:   groupID,
+			Name: groupID,
+		},
+	})
+	if err != nil && !meta.IsConflict(err) {
+		h.t.Fatal(err)
+	}
+
+	_, err = h.Clients.CoreV0().Namespace().Create(ctx, &core.Namespace{
+		Meta: meta.Meta{
+			ID:    namespaceID,
+			Name:  namespaceID,
+			Group: groupID,
+		},
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package testing
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client"
+)
+
+const manifest = `
+meta:
+  apiType: corev0/group
+  id: group1
+  name: group1
+---
+meta:
+  apiType: corev0/namespace
+  id: ns1
+  name: ns1
+  group: group1
+---
+meta:
+  apiType: corev0/network
+  id: net1
+  name: net1
+  group: group1
+  namespace: ns1
+spec:
+  template:
+    meta:
+      annotations:
+        networkv0/network_type: Bridge
+    spec:
+      id: "100"
+      ipv4CIDR: 10.0.0.0/24
+---
+meta:
+  apiType: systemv0/blockstorage
+  id: bs1
+  name: bs1
+  group: group1
+  namespace: ns1
+  annotations:
+    blockstoragev0/node_name: node1
+    blockstoragev0/type: Local
+spec:
+  requestSize: 1G
+  limitSize: 10G
+  from:
+    type: Empty
+---
+meta:
+  apiType: systemv0/virtualmachine
+  id: vm1
+  name: vm1
+  group: group1
+  namespace: ns1
+  annotations:
+    virtualmachinev0/node_name: node1
+spec:
+  requestVcpus: 1000m
+  limitVcpus: 1000m
+  requestMemory: 1G
+  limitMemory: 1G
+  blockStorageIDs:
+    - bs1
+  nics:
+    - networkID: net1
+      ipv4Address: 10.0.0.1
+  actionState: PowerOn
+`
+
+func TestHarness(t *testing.T) {
+	h := Start(t, nil)
+
+	node, err := h.Clients.SystemV0().Node().Get(context.Background(), DefaultNodeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Status.State != system.NodeStateReady {
+		t.Fatalf("unexpected node state: %s", node.Status.State)
+	}
+
+	if results := h.Apply(manifest); len(results) != 5 {
+		t.Fatalf("unexpected results: %d", len(results))
+	}
+
+	h.WaitForBlockStorageState("group1", "ns1", "bs1", system.BlockStorageStateActive)
+	h.WaitForVirtualMachineState("group1", "ns1", "vm1", system.VirtualMachineStateRunning)
+
+	// core agentがノードごとのnodenetworkを作成する
+	h.WaitFor("nodenetwork to be available", func(ctx context.Context) (bool, error) {
+		list, err := h.Clients.SystemV0().NodeNetwork().List(ctx, "group1", "ns1")
+		if err != nil {
+			return false, err
+		}
+		return len(list) == 1 && list[0].Status.State == system.NetworkStateAvailable, nil
+	})
+
+	if err := h.Clients.SystemV0().VirtualMachine().Delete(context.Background(), "group1", "ns1", "vm1"); err != nil {
+		t.Fatal(err)
+	}
+	h.WaitForNotFound("virtualmachine", func(ctx context.Context) error {
+		_, err := h.Clients.SystemV0().VirtualMachine().Get(ctx, "group1", "ns1", "vm1")
+		return err
+	})
+
+	h.Stop()
+	if _, err := h.Clients.CoreV0().Group().Get(context.Background(), "group1"); err == nil || meta.IsNotFound(err) {
+		t.Fatalf("expected connection error, but got %v", err)
+	}
+}
+
+func TestHarnessTLS(t *testing.T) {
+	h := Start(t, &Options{TLS: true})
+
+	h.Apply(manifest)
+	h.WaitForVirtualMachineState("group1", "ns1", "vm1", system.VirtualMachineStateRunning)
+
+	// クライアント証明書がない場合は接続できない
+	c := client.NewClientsWithConfig(&client.Config{
+		Address:         h.Address,
+		Port:            h.Port,
+		TLSClientConfig: &tls.Config{RootCAs: h.ca.pool},
+		RetryCount:      -1,
+	})
+	if _, err := c.CoreV0().Group().Get(context.Background(), "group1"); err == nil || meta.IsNotFound(err) {
+		t.Fatalf("expected connection error, but got %v", err)
+	}
+}
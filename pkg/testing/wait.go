@@ -0,0 +1,88 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+const waitInterval = time.Millisecond * 100
+
+// WaitFor はconditionがtrueを返すまで待つ
+// エラーを返した場合やタイムアウトした場合はテストを失敗させる
+func (h *Harness) WaitFor(description string, condition func(ctx context.Context) (bool, error)) {
+	h.t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.waitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(waitInterval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := condition(ctx)
+		if err != nil {
+			h.t.Fatalf("wait for %s: %s", description, err.Error())
+		}
+		if ok {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			h.t.Fatalf("wait for %s: timed out after %s", description, h.waitTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForNotFound はgetがNotFoundを返すまで待つ
+func (h *Harness) WaitForNotFound(description string, get func(ctx context.Context) error) {
+	h.t.Helper()
+
+	h.WaitFor(description+" to be deleted", func(ctx context.Context) (bool, error) {
+		err := get(ctx)
+		if err == nil {
+			return false, nil
+		}
+		if meta.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+// WaitForVirtualMachineState はvirtualmachineがstateになるまで待ち、その時点のvirtualmachineを返す
+func (h *Harness) WaitForVirtualMachineState(groupID, namespaceID, vmID string, state system.VirtualMachineState) *system.VirtualMachine {
+	h.t.Helper()
+
+	var vm *system.VirtualMachine
+	h.WaitFor(fmt.Sprintf("virtualmachine `%s` to be %s", vmID, state), func(ctx context.Context) (bool, error) {
+		var err error
+		vm, err = h.Clients.SystemV0().VirtualMachine().Get(ctx, groupID, namespaceID, vmID)
+		if err != nil {
+			return false, err
+		}
+		return vm.Status.State == state, nil
+	})
+	return vm
+}
+
+// WaitForBlockStorageState はblockstorageがstateになるまで待ち、その時点のblockstorageを返す
+func (h *Harness) WaitForBlockStorageState(groupID, namespaceID, bsID string, state system.BlockStorageState) *system.BlockStorage {
+	h.t.Helper()
+
+	var bs *system.BlockStorage
+	h.WaitFor(fmt.Sprintf("blockstorage `%s` to be %s", bsID, state), func(ctx context.Context) (bool, error) {
+		var err error
+		bs, err = h.Clients.SystemV0().BlockStorage().Get(ctx, groupID, namespaceID, bsID)
+		if err != nil {
+			return false, err
+		}
+		return bs.Status.State == state, nil
+	})
+	return bs
+}
@@ -1,8 +1,15 @@
 package cloudinit
 
-import "testing"
+import (
+	"os/exec"
+	"testing"
+)
 
 func TestOutput(t *testing.T) {
+	if _, err := exec.LookPath("cloud-localds"); err != nil {
+		t.Skip("cloud-localds not found")
+	}
+
 	metaData := MetaData{
 		InstanceID:    "test",
 		LocalHostName: "test",
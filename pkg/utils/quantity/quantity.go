@@ -0,0 +1,80 @@
+package quantity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	UnitGigabyte = 'G'
+	UnitMegabyte = 'M'
+	UnitKilobyte = 'K'
+	UnitMilli    = 'm'
+)
+
+// ParseMilliVcpus は `1000m` や `1.5` のようなvcpu数をミリ単位で返す。空の場合は0を返す
+func ParseMilliVcpus(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if s[len(s)-1] == UnitMilli {
+		n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid vcpus `%s`", s)
+		}
+		return n, nil
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid vcpus `%s`", s)
+	}
+	return int64(n * 1000), nil
+}
+
+// ParseBytes は `1G` や `512M` のような容量をバイト数で返す。空の場合は0を返す
+func ParseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	number, unit := s, int64(1)
+	switch s[len(s)-1] {
+	case UnitGigabyte:
+		unit = 1024 * 1024 * 1024
+	case UnitMegabyte:
+		unit = 1024 * 1024
+	case UnitKilobyte:
+		unit = 1024
+	}
+	if unit != 1 {
+		number = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size `%s`", s)
+	}
+	return n * unit, nil
+}
+
+// FormatMilliVcpus はミリ単位のvcpu数を `1500m` の形にする
+func FormatMilliVcpus(n int64) string {
+	return fmt.Sprintf("%dm", n)
+}
+
+// FormatBytes はバイト数を割り切れる最も大きい単位で表す
+func FormatBytes(b int64) string {
+	switch {
+	case b%(1024*1024*1024) == 0:
+		return fmt.Sprintf("%dG", b/(1024*1024*1024))
+	case b%(1024*1024) == 0:
+		return fmt.Sprintf("%dM", b/(1024*1024))
+	case b%1024 == 0:
+		return fmt.Sprintf("%dK", b/1024)
+	}
+	return fmt.Sprintf("%d", b)
+}
@@ -0,0 +1,71 @@
+package quantity
+
+import "testing"
+
+func TestParseMilliVcpus(t *testing.T) {
+	tests := map[string]int64{
+		"":      0,
+		"1500m": 1500,
+		"2":     2000,
+		"1.5":   1500,
+	}
+	for in, expected := range tests {
+		got, err := ParseMilliVcpus(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("%s: expected %d, but got %d", in, expected, got)
+		}
+	}
+
+	for _, in := range []string{"two", "1.5m", "1G"} {
+		if _, err := ParseMilliVcpus(in); err == nil {
+			t.Fatalf("%s: expected error", in)
+		}
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	tests := map[string]int64{
+		"":     0,
+		"1024": 1024,
+		"2K":   2 * 1024,
+		"512M": 512 * 1024 * 1024,
+		"1G":   1024 * 1024 * 1024,
+	}
+	for in, expected := range tests {
+		got, err := ParseBytes(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("%s: expected %d, but got %d", in, expected, got)
+		}
+	}
+
+	for _, in := range []string{"1T", "1.5G", "G"} {
+		if _, err := ParseBytes(in); err == nil {
+			t.Fatalf("%s: expected error", in)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := FormatMilliVcpus(1500); got != "1500m" {
+		t.Fatalf("unexpected vcpus: %s", got)
+	}
+
+	tests := map[int64]string{
+		0:                      "0G",
+		1023:                   "1023",
+		2048:                   "2K",
+		512 * 1024 * 1024:      "512M",
+		3 * 1024 * 1024 * 1024: "3G",
+	}
+	for in, expected := range tests {
+		if got := FormatBytes(in); got != expected {
+			t.Fatalf("%d: expected %s, but got %s", in, expected, got)
+		}
+	}
+}
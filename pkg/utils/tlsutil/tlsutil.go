@@ -0,0 +1,156 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type ServerConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// 指定した場合はクライアント証明書を検証する
+	ClientCAFile      string `yaml:"clientCAFile"`
+	RequireClientCert bool   `yaml:"requireClientCert"`
+}
+
+type ClientConfig struct {
+	// サーバー証明書を検証するCAバンドル
+	CAFile string `yaml:"caFile"`
+	// mTLSで使うクライアント証明書
+	CertFile           string `yaml:"certFile"`
+	KeyFile            string `yaml:"keyFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+func (c *ServerConfig) Enabled() bool {
+	return c != nil && c.CertFile != "" && c.KeyFile != ""
+}
+
+func (c *ClientConfig) Enabled() bool {
+	return c != nil && (c.CAFile != "" || c.CertFile != "" || c.InsecureSkipVerify)
+}
+
+func NewServerTLSConfig(c *ServerConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "load server certificate")
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile != "" {
+		pool, err := loadCertPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if c.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return config, nil
+}
+
+// NewClientTLSConfig はClientConfigから*tls.Configを作る
+// 設定されていない場合はnilを返す
+func NewClientTLSConfig(c *ClientConfig) (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "load client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// LoadClientCertificate はクライアント証明書を読み込む
+// 設定されていない場合はnilを返す
+func LoadClientCertificate(c *ClientConfig) (*x509.Certificate, error) {
+	if c == nil || c.CertFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "load client certificate")
+	}
+
+	return x509.ParseCertificate(cert.Certificate[0])
+}
+
+// ListenAndServe はServerConfigが有効な場合はTLSで、そうでない場合はHTTPで待ち受ける
+func ListenAndServe(addr string, handler http.Handler, c *ServerConfig) error {
+	if !c.Enabled() {
+		return http.ListenAndServe(addr, handler)
+	}
+
+	config, err := NewServerTLSConfig(c)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: config,
+	}
+	// 証明書はTLSConfigで読み込み済み
+	return server.ListenAndServeTLS("", "")
+}
+
+// NewTransport はhttp.DefaultTransportと同じ設定でconfigを使うtransportを返す
+// 使わなくなった接続を閉じるため、リクエストごとに作らずに使い回す
+func NewTransport(config *tls.Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = config
+	return t
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read ca file")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in `%s`", caFile)
+	}
+	return pool, nil
+}
+
+func (c *ServerConfig) Scheme() string {
+	if c.Enabled() {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,176 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if isCA {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+"-key.pem")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "humstack-ca", nil, true)
+	caFile, _ := ca.write(t, dir, "ca")
+	serverCertFile, serverKeyFile := newTestCert(t, "apiserver", ca, false).write(t, dir, "server")
+	clientCertFile, clientKeyFile := newTestCert(t, "node:node1", ca, false).write(t, dir, "client")
+
+	serverConfig, err := NewServerTLSConfig(&ServerConfig{
+		CertFile:          serverCertFile,
+		KeyFile:           serverKeyFile,
+		ClientCAFile:      caFile,
+		RequireClientCert: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+	}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	clientConfig, err := NewClientTLSConfig(&ClientConfig{
+		CAFile:   caFile,
+		CertFile: clientCertFile,
+		KeyFile:  clientKeyFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+	res, err := c.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "node:node1" {
+		t.Fatalf("unexpected common name: %s", body)
+	}
+
+	// クライアント証明書がない場合は拒否される
+	noCertConfig, err := NewClientTLSConfig(&ClientConfig{
+		CAFile: caFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = &http.Client{Transport: &http.Transport{TLSClientConfig: noCertConfig}}
+	if res, err := c.Get(server.URL); err == nil {
+		res.Body.Close()
+		t.Fatal("expected handshake error without client certificate")
+	}
+}
+
+func TestNewClientTLSConfigDisabled(t *testing.T) {
+	config, err := NewClientTLSConfig(&ClientConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != nil {
+		t.Fatal("expected nil config")
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	config := &tls.Config{}
+	transport := NewTransport(config)
+	if transport.TLSClientConfig != config {
+		t.Fatal("expected tls config to be set")
+	}
+	// 使っていない接続を閉じる
+	if transport.IdleConnTimeout == 0 {
+		t.Fatal("expected IdleConnTimeout")
+	}
+	if transport == http.DefaultTransport {
+		t.Fatal("expected a copy of DefaultTransport")
+	}
+}
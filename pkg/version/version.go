@@ -0,0 +1,35 @@
+package version
+
+import (
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ビルド時に -ldflags "-X github.com/ophum/humstack/pkg/version.Version=..." で埋め込む
+var (
+	Version   = "dev"
+	Revision  = ""
+	BuildDate = ""
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Revision:  Revision,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// Handler は/versionのハンドラ
+func Handler(ctx *gin.Context) {
+	ctx.JSON(200, Get())
+}